@@ -17,30 +17,293 @@ package actions
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
 
 	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
 	"github.com/Netflix/p2plab/metadata"
 	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 )
 
-func Parse(objects map[string]cid.Cid, a string) (p2plab.Action, error) {
-	return &dummyAction{objects[a].String()}, nil
+// Parse parses an action of the form "<verb> [<object>] [<key>=<value> ...]".
+// A bare object name is shorthand for "get <object>".
+//
+// Supported actions are:
+//
+//	get <object>[,<object>...] [popularity=uniform|zipf] [exponent=<s>] [weights=<w>,...] [count=<n>] [replicas=<n>]
+//	get-range <object> [path=<path>] [offset=<bytes>] [length=<bytes>]
+//	gateway-get <object> [path=<path>] [gateways=<n>]
+//	pin <object> [type=direct|recursive] [depth=<n>]
+//	churn [percent=<n>] [mode=disconnect|kill] [after=<duration>] [every=<duration>] [downtime=<duration>]
+//	publish <topic> [count=<n>] [size=<bytes>] [interval=<duration>] [after=<duration>]
+//	subscribe <topic> [count=<n>] [publishers=<n>] [timeout=<duration>] [after=<duration>]
+//	provide <object>
+//	findprovs <object> [count=<n>] [timeout=<duration>]
+//	findpeer [peers=<n>] [timeout=<duration>]
+//	load <object> [rate=<n>] [duration=<duration>] [evict=true|false] [after=<duration>]
+//	add [count=<n>] [size=<bytes>] [layout=balanced|trickle] [chunker=<chunker>] [raw-leaves=true|false] [hash=<func>] [max-links=<n>] [after=<duration>]
+//	mutate <object> [path=<path>] [mode=append|replace] [size=<bytes>] [topic=<topic>] [layout=balanced|trickle] [chunker=<chunker>] [raw-leaves=true|false] [after=<duration>]
+//	follow <topic> [count=<n>] [timeout=<duration>]
+//	exec command=<command>|script=<object> [args=<arg>,<arg>...]
+//	restart-peers [clear=true|false] [after=<duration>]
+//	update-peer-config <setting>=<value> [<setting>=<value> ...] [after=<duration>]
+//
+// Subscribers expect count messages from each of the given number of
+// publishers, and count any they don't receive before the timeout as lost.
+//
+// Churn makes existing nodes leave and rejoin their peers, and never adds new
+// nodes to the cluster.
+//
+// The settings of update-peer-config are transports, muxers, security,
+// routing, bitswap-provide, bitswap-search-delay, bitswap-task-workers,
+// bitswap-trace and collectors.
+//
+// Nodes only exec commands allowed by labapp's --exec-allow, and only exec
+// scripts if labapp has --exec-scripts.
+//
+// Any action also accepts deadline=<duration>, failing its tasks if they run
+// longer, and on-failure=abort|continue|retry:<n>, the failure policy of its
+// tasks.
+//
+// Actions that make random choices use rng.
+func Parse(objects map[string]cid.Cid, a string, rng *rand.Rand) (p2plab.Action, error) {
+	fields, deadline, onFailure, err := parsePolicyArgs(strings.Fields(a))
+	if err != nil {
+		return nil, errors.Wrapf(err, "action %q", a)
+	}
+	if len(fields) == 0 {
+		return nil, errors.Wrap(errdefs.ErrInvalidArgument, "action must not be empty")
+	}
+
+	action, err := parse(objects, a, fields, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline > 0 || onFailure != "" {
+		return &policyAction{
+			Action:    action,
+			deadline:  deadline,
+			onFailure: onFailure,
+		}, nil
+	}
+	return action, nil
 }
 
-type dummyAction struct {
+// parse parses the fields of an action without the arguments that apply to
+// any action.
+func parse(objects map[string]cid.Cid, a string, fields []string, rng *rand.Rand) (p2plab.Action, error) {
+	verb, args := metadata.TaskGet, fields
+	switch metadata.TaskType(fields[0]) {
+	case metadata.TaskGet, metadata.TaskPin, metadata.TaskChurn, metadata.TaskPublish, metadata.TaskSubscribe,
+		metadata.TaskProvide, metadata.TaskFindProvs, metadata.TaskFindPeer, metadata.TaskLoad, metadata.TaskGetRange,
+		metadata.TaskAdd, metadata.TaskRestart, metadata.TaskUpdateConfig, metadata.TaskGatewayGet,
+		metadata.TaskExec, metadata.TaskMutate, metadata.TaskFollow:
+		verb, args = metadata.TaskType(fields[0]), fields[1:]
+	}
+
+	switch verb {
+	case metadata.TaskGet:
+		cids, kvs, err := parseObjectsArgs(objects, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseGetAction(cids, kvs, rng)
+	case metadata.TaskGetRange:
+		c, kvs, err := parseObjectArgs(objects, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseGetRangeAction(c, kvs)
+	case metadata.TaskGatewayGet:
+		c, kvs, err := parseObjectArgs(objects, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseGatewayGetAction(c, kvs, rng)
+	case metadata.TaskPin:
+		c, kvs, err := parseObjectArgs(objects, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parsePinAction(c, kvs)
+	case metadata.TaskChurn:
+		kvs, err := parseArgs(args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseChurnAction(kvs, rng)
+	case metadata.TaskPublish, metadata.TaskSubscribe:
+		action, err := parsePubsubAction(verb, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return action, nil
+	case metadata.TaskProvide, metadata.TaskFindProvs:
+		c, kvs, err := parseObjectArgs(objects, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseDHTAction(verb, c, kvs)
+	case metadata.TaskFindPeer:
+		kvs, err := parseArgs(args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseFindPeerAction(kvs, rng)
+	case metadata.TaskLoad:
+		c, kvs, err := parseObjectArgs(objects, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseLoadAction(c, kvs)
+	case metadata.TaskAdd:
+		kvs, err := parseArgs(args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseAddAction(kvs)
+	case metadata.TaskMutate:
+		c, kvs, err := parseObjectArgs(objects, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseMutateAction(c, kvs)
+	case metadata.TaskFollow:
+		action, err := parseFollowAction(args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return action, nil
+	case metadata.TaskExec:
+		kvs, err := parseArgs(args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseExecAction(objects, kvs)
+	case metadata.TaskRestart, metadata.TaskUpdateConfig:
+		kvs, err := parseArgs(args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "action %q", a)
+		}
+		return parseRestartAction(verb, kvs)
+	default:
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized action %q", verb)
+	}
+}
+
+// parseObjectArgs resolves the object named by the first argument and parses
+// the remaining arguments.
+func parseObjectArgs(objects map[string]cid.Cid, args []string) (cid.Cid, map[string]string, error) {
+	if len(args) == 0 {
+		return cid.Undef, nil, errors.Wrap(errdefs.ErrInvalidArgument, "object must be provided")
+	}
+
+	c, ok := objects[args[0]]
+	if !ok {
+		return cid.Undef, nil, errors.Wrapf(errdefs.ErrNotFound, "object %q", args[0])
+	}
+
+	kvs, err := parseArgs(args[1:])
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+
+	return c, kvs, nil
+}
+
+// parseObjectsArgs resolves the comma separated objects named by the first
+// argument and parses the remaining arguments.
+func parseObjectsArgs(objects map[string]cid.Cid, args []string) ([]cid.Cid, map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.Wrap(errdefs.ErrInvalidArgument, "object must be provided")
+	}
+
+	var cids []cid.Cid
+	for _, name := range strings.Split(args[0], ",") {
+		c, ok := objects[name]
+		if !ok {
+			return nil, nil, errors.Wrapf(errdefs.ErrNotFound, "object %q", name)
+		}
+		cids = append(cids, c)
+	}
+
+	kvs, err := parseArgs(args[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cids, kvs, nil
+}
+
+// parseArgs parses arguments of the form key=value.
+func parseArgs(args []string) (map[string]string, error) {
+	kvs := make(map[string]string)
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "argument %q must be of the form key=value", arg)
+		}
+		kvs[parts[0]] = parts[1]
+	}
+	return kvs, nil
+}
+
+type pinAction struct {
 	subject string
+	pinType metadata.PinType
+	depth   int
+}
+
+func parsePinAction(c cid.Cid, kvs map[string]string) (*pinAction, error) {
+	a := &pinAction{
+		subject: c.String(),
+		pinType: metadata.PinRecursive,
+		depth:   -1,
+	}
+
+	for key, value := range kvs {
+		switch key {
+		case "type":
+			a.pinType = metadata.PinType(value)
+			switch a.pinType {
+			case metadata.PinDirect, metadata.PinRecursive:
+			default:
+				return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized pin type %q", value)
+			}
+		case "depth":
+			var err error
+			a.depth, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "pin depth %q is not an integer", value)
+			}
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized pin argument %q", key)
+		}
+	}
+
+	if a.pinType == metadata.PinDirect {
+		a.depth = 0
+	}
+
+	return a, nil
 }
 
-func (a *dummyAction) String() string {
-	return fmt.Sprintf("get %q", a.subject)
+func (a *pinAction) String() string {
+	return fmt.Sprintf("pin %q type=%s depth=%d", a.subject, a.pinType, a.depth)
 }
 
-func (a *dummyAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+func (a *pinAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
 	taskMap := make(map[string]metadata.Task)
 	for _, n := range ns {
 		taskMap[n.Metadata().ID] = metadata.Task{
-			Type:    metadata.TaskGet,
+			Type:    metadata.TaskPin,
 			Subject: a.subject,
+			PinType: a.pinType,
+			Depth:   a.depth,
 		}
 	}
 	return taskMap, nil
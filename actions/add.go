@@ -0,0 +1,112 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// addAction imports locally generated files into the blockstore of the
+// selected nodes, measuring how fast they are chunked and stored.
+type addAction struct {
+	count     int
+	size      int64
+	layout    string
+	chunker   string
+	rawLeaves bool
+	hashFunc  string
+	maxLinks  int
+	after     time.Duration
+}
+
+func parseAddAction(kvs map[string]string) (*addAction, error) {
+	a := &addAction{
+		count: 1,
+		size:  16 << 20,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "count":
+			a.count, err = strconv.Atoi(value)
+			if err == nil && a.count <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "size":
+			a.size, err = parseSize(value)
+			if err == nil && a.size <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "layout":
+			a.layout = value
+			switch a.layout {
+			case "balanced", "trickle":
+			default:
+				err = errors.Errorf("must be %q or %q", "balanced", "trickle")
+			}
+		case "chunker":
+			a.chunker = value
+		case "raw-leaves":
+			a.rawLeaves, err = strconv.ParseBool(value)
+		case "hash":
+			a.hashFunc = value
+		case "max-links":
+			a.maxLinks, err = strconv.Atoi(value)
+			if err == nil && a.maxLinks <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "after":
+			a.after, err = time.ParseDuration(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized add argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "add argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *addAction) String() string {
+	return fmt.Sprintf("add count=%d size=%d layout=%q chunker=%q raw-leaves=%t hash=%q max-links=%d after=%s", a.count, a.size, a.layout, a.chunker, a.rawLeaves, a.hashFunc, a.maxLinks, a.after)
+}
+
+func (a *addAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:      metadata.TaskAdd,
+			Delay:     a.after,
+			Count:     a.count,
+			Size:      int(a.size),
+			Layout:    a.layout,
+			Chunker:   a.chunker,
+			RawLeaves: a.rawLeaves,
+			HashFunc:  a.hashFunc,
+			MaxLinks:  a.maxLinks,
+		}
+	}
+	return taskMap, nil
+}
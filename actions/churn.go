@@ -0,0 +1,103 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// churnAction takes a percentage of the selected nodes offline during a stage,
+// one every interval, and optionally brings them back after a downtime.
+//
+// Churn only simulates nodes of the cluster leaving and rejoining the peers
+// they were connected to. It never adds nodes to the cluster, so nodes
+// joining mid-benchmark for the first time are not simulated; a node that
+// rejoins keeps the blocks it had before it left.
+type churnAction struct {
+	percent  float64
+	mode     metadata.ChurnMode
+	after    time.Duration
+	every    time.Duration
+	downtime time.Duration
+	rng      *rand.Rand
+}
+
+func parseChurnAction(kvs map[string]string, rng *rand.Rand) (*churnAction, error) {
+	a := &churnAction{
+		percent: 10,
+		mode:    metadata.ChurnDisconnect,
+		rng:     rng,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "percent":
+			a.percent, err = strconv.ParseFloat(value, 64)
+			if err == nil && (a.percent < 0 || a.percent > 100) {
+				err = errors.Errorf("must be between 0 and 100")
+			}
+		case "mode":
+			a.mode = metadata.ChurnMode(value)
+			switch a.mode {
+			case metadata.ChurnDisconnect, metadata.ChurnKill:
+			default:
+				err = errors.Errorf("must be %q or %q", metadata.ChurnDisconnect, metadata.ChurnKill)
+			}
+		case "after":
+			a.after, err = time.ParseDuration(value)
+		case "every":
+			a.every, err = time.ParseDuration(value)
+		case "downtime":
+			a.downtime, err = time.ParseDuration(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized churn argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "churn argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *churnAction) String() string {
+	return fmt.Sprintf("churn percent=%g mode=%s after=%s every=%s downtime=%s", a.percent, a.mode, a.after, a.every, a.downtime)
+}
+
+func (a *churnAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	count := int(math.Ceil(a.percent / 100 * float64(len(ns))))
+
+	taskMap := make(map[string]metadata.Task)
+	for i, j := range a.rng.Perm(len(ns))[:count] {
+		taskMap[ns[j].Metadata().ID] = metadata.Task{
+			Type:     metadata.TaskChurn,
+			Subject:  string(a.mode),
+			Delay:    a.after + time.Duration(i)*a.every,
+			Downtime: a.downtime,
+		}
+	}
+	return taskMap, nil
+}
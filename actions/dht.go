@@ -0,0 +1,164 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// dhtAction provides or finds the providers of an object through the DHT.
+type dhtAction struct {
+	verb    metadata.TaskType
+	subject string
+	count   int
+	timeout time.Duration
+}
+
+func parseDHTAction(verb metadata.TaskType, c cid.Cid, kvs map[string]string) (*dhtAction, error) {
+	a := &dhtAction{
+		verb:    verb,
+		subject: c.String(),
+		count:   1,
+		timeout: time.Minute,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch {
+		case key == "count" && verb == metadata.TaskFindProvs:
+			a.count, err = strconv.Atoi(value)
+			if err == nil && a.count <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case key == "timeout" && verb == metadata.TaskFindProvs:
+			a.timeout, err = time.ParseDuration(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized %s argument %q", verb, key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "%s argument %s=%q: %s", verb, key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *dhtAction) String() string {
+	if a.verb == metadata.TaskProvide {
+		return fmt.Sprintf("provide %q", a.subject)
+	}
+	return fmt.Sprintf("findprovs %q count=%d timeout=%s", a.subject, a.count, a.timeout)
+}
+
+func (a *dhtAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		task := metadata.Task{
+			Type:    a.verb,
+			Subject: a.subject,
+		}
+		if a.verb == metadata.TaskFindProvs {
+			task.Count = a.count
+			task.Timeout = a.timeout
+		}
+		taskMap[n.Metadata().ID] = task
+	}
+	return taskMap, nil
+}
+
+// findPeerAction has each selected node look up other selected nodes through
+// the DHT.
+type findPeerAction struct {
+	peers   int
+	timeout time.Duration
+	rng     *rand.Rand
+}
+
+func parseFindPeerAction(kvs map[string]string, rng *rand.Rand) (*findPeerAction, error) {
+	a := &findPeerAction{
+		peers:   1,
+		timeout: time.Minute,
+		rng:     rng,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "peers":
+			a.peers, err = strconv.Atoi(value)
+			if err == nil && a.peers <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "timeout":
+			a.timeout, err = time.ParseDuration(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized findpeer argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "findpeer argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *findPeerAction) String() string {
+	return fmt.Sprintf("findpeer peers=%d timeout=%s", a.peers, a.timeout)
+}
+
+func (a *findPeerAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	ids := make([]string, len(ns))
+	for i, n := range ns {
+		info, err := n.PeerInfo(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get peer info for node %q", n.Metadata().ID)
+		}
+		ids[i] = info.ID.Pretty()
+	}
+
+	taskMap := make(map[string]metadata.Task)
+	for i, n := range ns {
+		var targets []string
+		for _, j := range a.rng.Perm(len(ns)) {
+			if len(targets) == a.peers {
+				break
+			}
+			if j != i {
+				targets = append(targets, ids[j])
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:    metadata.TaskFindPeer,
+			Subject: strings.Join(targets, ","),
+			Timeout: a.timeout,
+		}
+	}
+	return taskMap, nil
+}
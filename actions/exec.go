@@ -0,0 +1,84 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// execAction runs a command on nodes, either one the nodes allow or a script
+// object, for measurements that p2plab does not support natively. Like any
+// object, a script is retrieved from the cluster, so it must be seeded first.
+type execAction struct {
+	subject string
+	script  bool
+	args    []string
+}
+
+func parseExecAction(objects map[string]cid.Cid, kvs map[string]string) (*execAction, error) {
+	a := &execAction{}
+	for key, value := range kvs {
+		switch key {
+		case "command":
+			a.subject = value
+		case "script":
+			c, ok := objects[value]
+			if !ok {
+				return nil, errors.Wrapf(errdefs.ErrNotFound, "object %q", value)
+			}
+			a.subject, a.script = c.String(), true
+		case "args":
+			a.args = strings.Split(value, ",")
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized exec argument %q", key)
+		}
+	}
+
+	_, hasCommand := kvs["command"]
+	_, hasScript := kvs["script"]
+	if hasCommand == hasScript {
+		return nil, errors.Wrap(errdefs.ErrInvalidArgument, "exec must have exactly one of command or script")
+	}
+
+	return a, nil
+}
+
+func (a *execAction) String() string {
+	if a.script {
+		return fmt.Sprintf("exec script=%q args=%q", a.subject, a.args)
+	}
+	return fmt.Sprintf("exec command=%q args=%q", a.subject, a.args)
+}
+
+func (a *execAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:    metadata.TaskExec,
+			Subject: a.subject,
+			Script:  a.script,
+			Args:    a.args,
+		}
+	}
+	return taskMap, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// gatewayGetAction retrieves an object through the HTTP gateway of other
+// nodes, the path taken by end users that don't run a peer themselves. Some of
+// the nodes are chosen as gateways, and each of the rest retrieves the object
+// through one of them.
+type gatewayGetAction struct {
+	subject  string
+	path     string
+	gateways int
+	rng      *rand.Rand
+}
+
+func parseGatewayGetAction(c cid.Cid, kvs map[string]string, rng *rand.Rand) (*gatewayGetAction, error) {
+	a := &gatewayGetAction{
+		subject:  c.String(),
+		gateways: 1,
+		rng:      rng,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "path":
+			a.path = value
+		case "gateways":
+			a.gateways, err = strconv.Atoi(value)
+			if err == nil && a.gateways <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized gateway-get argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "gateway-get argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *gatewayGetAction) String() string {
+	return fmt.Sprintf("gateway-get %q path=%q gateways=%d", a.subject, a.path, a.gateways)
+}
+
+func (a *gatewayGetAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	if len(ns) <= a.gateways {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "gateway-get needs more than %d nodes for %d gateways", len(ns), a.gateways)
+	}
+
+	perm := a.rng.Perm(len(ns))
+	var gateways []string
+	for _, i := range perm[:a.gateways] {
+		md := ns[i].Metadata()
+		gateways = append(gateways, fmt.Sprintf("http://%s", net.JoinHostPort(md.Address, strconv.Itoa(md.AppPort))))
+	}
+
+	taskMap := make(map[string]metadata.Task)
+	for _, i := range perm[a.gateways:] {
+		taskMap[ns[i].Metadata().ID] = metadata.Task{
+			Type:    metadata.TaskGatewayGet,
+			Subject: a.subject,
+			Path:    a.path,
+			Gateway: gateways[a.rng.Intn(len(gateways))],
+		}
+	}
+	return taskMap, nil
+}
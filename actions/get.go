@@ -0,0 +1,224 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// popularity is a distribution of requests over objects.
+type popularity string
+
+var (
+	// popularityUniform requests every object equally.
+	popularityUniform popularity = "uniform"
+
+	// popularityZipf requests the object ranked k with a weight proportional
+	// to 1/k^s, in the order the objects are listed.
+	popularityZipf popularity = "zipf"
+)
+
+// getAction retrieves objects. When given several objects, each node
+// retrieves count of them chosen according to their weights. Alternatively,
+// each object is placed on a number of random replicas among the nodes.
+type getAction struct {
+	subjects []string
+	weights  []float64
+	count    int
+	replicas int
+	rng      *rand.Rand
+}
+
+func parseGetAction(cids []cid.Cid, kvs map[string]string, rng *rand.Rand) (*getAction, error) {
+	a := &getAction{
+		count: 1,
+		rng:   rng,
+	}
+	for _, c := range cids {
+		a.subjects = append(a.subjects, c.String())
+	}
+
+	var (
+		dist     = popularityUniform
+		exponent = 1.0
+	)
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "popularity":
+			dist = popularity(value)
+			switch dist {
+			case popularityUniform, popularityZipf:
+			default:
+				err = errors.Errorf("must be %q or %q", popularityUniform, popularityZipf)
+			}
+		case "exponent":
+			exponent, err = strconv.ParseFloat(value, 64)
+			if err == nil && exponent <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "weights":
+			for _, w := range strings.Split(value, ",") {
+				var weight float64
+				weight, err = strconv.ParseFloat(w, 64)
+				if err != nil {
+					break
+				}
+				if weight < 0 {
+					err = errors.Errorf("must not be negative")
+					break
+				}
+				a.weights = append(a.weights, weight)
+			}
+			if err == nil && len(a.weights) != len(cids) {
+				err = errors.Errorf("must have a weight for each of the %d objects", len(cids))
+			}
+		case "count":
+			a.count, err = strconv.Atoi(value)
+			if err == nil && (a.count <= 0 || a.count > len(cids)) {
+				err = errors.Errorf("must be between 1 and the number of objects")
+			}
+		case "replicas":
+			a.replicas, err = strconv.Atoi(value)
+			if err == nil && a.replicas <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized get argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "get argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	if a.replicas > 0 {
+		for _, key := range []string{"popularity", "exponent", "weights", "count"} {
+			if _, ok := kvs[key]; ok {
+				return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "get argument %q cannot be used with replicas", key)
+			}
+		}
+	}
+
+	if a.weights == nil {
+		a.weights = make([]float64, len(cids))
+		for i := range a.weights {
+			a.weights[i] = 1
+			if dist == popularityZipf {
+				a.weights[i] = 1 / math.Pow(float64(i+1), exponent)
+			}
+		}
+	}
+
+	return a, nil
+}
+
+func (a *getAction) String() string {
+	if a.replicas > 0 {
+		return fmt.Sprintf("get %q replicas=%d", strings.Join(a.subjects, ","), a.replicas)
+	}
+	if len(a.subjects) == 1 {
+		return fmt.Sprintf("get %q", a.subjects[0])
+	}
+	return fmt.Sprintf("get %q weights=%v count=%d", strings.Join(a.subjects, ","), a.weights, a.count)
+}
+
+func (a *getAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	if a.replicas > 0 {
+		return a.place(ns)
+	}
+
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		subjects := a.subjects
+		if len(a.subjects) > 1 {
+			subjects = a.sample()
+		}
+		if len(subjects) == 0 {
+			continue
+		}
+
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:    metadata.TaskGet,
+			Subject: strings.Join(subjects, ","),
+		}
+	}
+	return taskMap, nil
+}
+
+// place assigns each subject to replicas distinct random nodes.
+func (a *getAction) place(ns []p2plab.Node) (map[string]metadata.Task, error) {
+	if a.replicas > len(ns) {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "cannot place %d replicas on %d nodes", a.replicas, len(ns))
+	}
+
+	subjectsByNode := make(map[string][]string)
+	for _, subject := range a.subjects {
+		for _, i := range a.rng.Perm(len(ns))[:a.replicas] {
+			id := ns[i].Metadata().ID
+			subjectsByNode[id] = append(subjectsByNode[id], subject)
+		}
+	}
+
+	taskMap := make(map[string]metadata.Task)
+	for id, subjects := range subjectsByNode {
+		taskMap[id] = metadata.Task{
+			Type:    metadata.TaskGet,
+			Subject: strings.Join(subjects, ","),
+		}
+	}
+	return taskMap, nil
+}
+
+// sample chooses count distinct subjects, each with a probability
+// proportional to its weight among the subjects not yet chosen.
+func (a *getAction) sample() []string {
+	weights := append([]float64(nil), a.weights...)
+
+	var subjects []string
+	for len(subjects) < a.count {
+		var total float64
+		for _, w := range weights {
+			total += w
+		}
+		if total == 0 {
+			break
+		}
+
+		r := a.rng.Float64() * total
+		for i, w := range weights {
+			if w == 0 {
+				continue
+			}
+			r -= w
+			if r < 0 {
+				subjects = append(subjects, a.subjects[i])
+				weights[i] = 0
+				break
+			}
+		}
+	}
+	return subjects
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// loadAction retrieves an object at a sustained rate for a duration.
+type loadAction struct {
+	subject  string
+	rate     float64
+	duration time.Duration
+	evict    bool
+	after    time.Duration
+}
+
+func parseLoadAction(c cid.Cid, kvs map[string]string) (*loadAction, error) {
+	a := &loadAction{
+		subject:  c.String(),
+		rate:     1,
+		duration: time.Minute,
+		evict:    true,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "rate":
+			a.rate, err = strconv.ParseFloat(value, 64)
+			if err == nil && a.rate <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "duration":
+			a.duration, err = time.ParseDuration(value)
+		case "evict":
+			a.evict, err = strconv.ParseBool(value)
+		case "after":
+			a.after, err = time.ParseDuration(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized load argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "load argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *loadAction) String() string {
+	return fmt.Sprintf("load %q rate=%g duration=%s evict=%t after=%s", a.subject, a.rate, a.duration, a.evict, a.after)
+}
+
+func (a *loadAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:     metadata.TaskLoad,
+			Subject:  a.subject,
+			Delay:    a.after,
+			Rate:     a.rate,
+			Duration: a.duration,
+			Evict:    a.evict,
+		}
+	}
+	return taskMap, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// policyAction sets the deadline and failure policy of the tasks of an
+// action.
+type policyAction struct {
+	p2plab.Action
+	deadline  time.Duration
+	onFailure string
+}
+
+// parsePolicyArgs removes the deadline and on-failure arguments that apply to
+// any action from the fields of an action.
+func parsePolicyArgs(fields []string) ([]string, time.Duration, string, error) {
+	var (
+		rest      []string
+		deadline  time.Duration
+		onFailure string
+	)
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			rest = append(rest, field)
+			continue
+		}
+
+		key, value := parts[0], parts[1]
+		var err error
+		switch key {
+		case "deadline":
+			deadline, err = time.ParseDuration(value)
+			if err == nil && deadline <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "on-failure":
+			onFailure = value
+			_, err = metadata.ParseFailurePolicy(value)
+		default:
+			rest = append(rest, field)
+		}
+		if err != nil {
+			return nil, 0, "", errors.Wrapf(errdefs.ErrInvalidArgument, "argument %s=%q: %s", key, value, err)
+		}
+	}
+	return rest, deadline, onFailure, nil
+}
+
+func (a *policyAction) String() string {
+	return fmt.Sprintf("%s deadline=%s on-failure=%q", a.Action, a.deadline, a.onFailure)
+}
+
+func (a *policyAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap, err := a.Action.Tasks(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, task := range taskMap {
+		task.Deadline = a.deadline
+		task.OnFailure = a.onFailure
+		taskMap[id] = task
+	}
+	return taskMap, nil
+}
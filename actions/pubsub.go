@@ -0,0 +1,123 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// pubsubAction publishes messages to, or subscribes to messages from, a
+// gossipsub topic.
+type pubsubAction struct {
+	verb       metadata.TaskType
+	topic      string
+	count      int
+	publishers int
+	size       int
+	interval   time.Duration
+	timeout    time.Duration
+	after      time.Duration
+}
+
+func parsePubsubAction(verb metadata.TaskType, args []string) (*pubsubAction, error) {
+	if len(args) == 0 {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "%s topic must be provided", verb)
+	}
+
+	kvs, err := parseArgs(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	a := &pubsubAction{
+		verb:       verb,
+		topic:      args[0],
+		count:      10,
+		publishers: 1,
+		size:       1024,
+		interval:   100 * time.Millisecond,
+		timeout:    time.Minute,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch {
+		case key == "count":
+			a.count, err = strconv.Atoi(value)
+			if err == nil && a.count <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case key == "publishers" && verb == metadata.TaskSubscribe:
+			a.publishers, err = strconv.Atoi(value)
+			if err == nil && a.publishers <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case key == "after":
+			a.after, err = time.ParseDuration(value)
+		case key == "size" && verb == metadata.TaskPublish:
+			a.size, err = strconv.Atoi(value)
+			if err == nil && a.size < 16 {
+				err = errors.Errorf("must be at least 16 bytes")
+			}
+		case key == "interval" && verb == metadata.TaskPublish:
+			a.interval, err = time.ParseDuration(value)
+		case key == "timeout" && verb == metadata.TaskSubscribe:
+			a.timeout, err = time.ParseDuration(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized %s argument %q", verb, key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "%s argument %s=%q: %s", verb, key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *pubsubAction) String() string {
+	if a.verb == metadata.TaskPublish {
+		return fmt.Sprintf("publish %q count=%d size=%d interval=%s after=%s", a.topic, a.count, a.size, a.interval, a.after)
+	}
+	return fmt.Sprintf("subscribe %q count=%d publishers=%d timeout=%s after=%s", a.topic, a.count, a.publishers, a.timeout, a.after)
+}
+
+func (a *pubsubAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		task := metadata.Task{
+			Type:    a.verb,
+			Subject: a.topic,
+			Delay:   a.after,
+			Count:   a.count,
+		}
+		if a.verb == metadata.TaskPublish {
+			task.Size = a.size
+			task.Interval = a.interval
+		} else {
+			task.Publishers = a.publishers
+			task.Timeout = a.timeout
+		}
+		taskMap[n.Metadata().ID] = task
+	}
+	return taskMap, nil
+}
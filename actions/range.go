@@ -0,0 +1,88 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// getRangeAction retrieves part of an object, either the DAG at a path within
+// it or a byte range of the file there.
+type getRangeAction struct {
+	subject string
+	path    string
+	offset  int64
+	length  int64
+}
+
+func parseGetRangeAction(c cid.Cid, kvs map[string]string) (*getRangeAction, error) {
+	a := &getRangeAction{
+		subject: c.String(),
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "path":
+			a.path = value
+		case "offset":
+			a.offset, err = parseSize(value)
+		case "length":
+			a.length, err = parseSize(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized get-range argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "get-range argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+// parseSize parses a number of bytes such as "4096" or "10MB".
+func parseSize(value string) (int64, error) {
+	size, err := humanize.ParseBytes(value)
+	if err != nil {
+		return 0, err
+	}
+	return int64(size), nil
+}
+
+func (a *getRangeAction) String() string {
+	return fmt.Sprintf("get-range %q path=%q offset=%d length=%d", a.subject, a.path, a.offset, a.length)
+}
+
+func (a *getRangeAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:    metadata.TaskGetRange,
+			Subject: a.subject,
+			Path:    a.path,
+			Offset:  a.offset,
+			Length:  a.length,
+		}
+	}
+	return taskMap, nil
+}
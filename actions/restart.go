@@ -0,0 +1,96 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// restartAction restarts the peers of the selected nodes, optionally with
+// settings applied to their peer definitions.
+type restartAction struct {
+	verb     metadata.TaskType
+	clear    bool
+	settings []string
+	after    time.Duration
+}
+
+func parseRestartAction(verb metadata.TaskType, kvs map[string]string) (*restartAction, error) {
+	a := &restartAction{
+		verb: verb,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch {
+		case key == "after":
+			a.after, err = time.ParseDuration(value)
+		case verb == metadata.TaskRestart && key == "clear":
+			a.clear, err = strconv.ParseBool(value)
+		case verb == metadata.TaskUpdateConfig:
+			a.settings = append(a.settings, fmt.Sprintf("%s=%s", key, value))
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized %s argument %q", verb, key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "%s argument %s=%q: %s", verb, key, value, err)
+		}
+	}
+
+	if verb == metadata.TaskUpdateConfig {
+		if len(a.settings) == 0 {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "%s requires at least one setting", verb)
+		}
+		sort.Strings(a.settings)
+
+		// Validate the settings before any peer is restarted with them.
+		_, err := metadata.UpdatePeerDefinition(metadata.DefaultPeerDefinition, a.settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func (a *restartAction) String() string {
+	if a.verb == metadata.TaskUpdateConfig {
+		return fmt.Sprintf("%s %s after=%s", a.verb, strings.Join(a.settings, " "), a.after)
+	}
+	return fmt.Sprintf("%s clear=%t after=%s", a.verb, a.clear, a.after)
+}
+
+func (a *restartAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:    a.verb,
+			Subject: strings.Join(a.settings, " "),
+			Delay:   a.after,
+			Evict:   a.clear,
+		}
+	}
+	return taskMap, nil
+}
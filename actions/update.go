@@ -0,0 +1,174 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// mutateAction changes a file within an object the selected nodes already
+// have, then provides and announces the new root so that followers retrieve
+// the update. The chunking options should match the object's so that only the
+// changed blocks are new.
+type mutateAction struct {
+	subject   string
+	path      string
+	mode      metadata.MutationMode
+	size      int64
+	topic     string
+	layout    string
+	chunker   string
+	rawLeaves bool
+	after     time.Duration
+}
+
+func parseMutateAction(c cid.Cid, kvs map[string]string) (*mutateAction, error) {
+	a := &mutateAction{
+		subject: c.String(),
+		mode:    metadata.MutationAppend,
+		size:    256 << 10,
+		topic:   "updates",
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "path":
+			a.path = value
+		case "mode":
+			a.mode = metadata.MutationMode(value)
+			switch a.mode {
+			case metadata.MutationAppend, metadata.MutationReplace:
+			default:
+				err = errors.Errorf("must be %q or %q", metadata.MutationAppend, metadata.MutationReplace)
+			}
+		case "size":
+			a.size, err = parseSize(value)
+			if err == nil && a.size <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "topic":
+			a.topic = value
+		case "layout":
+			a.layout = value
+		case "chunker":
+			a.chunker = value
+		case "raw-leaves":
+			a.rawLeaves, err = strconv.ParseBool(value)
+		case "after":
+			a.after, err = time.ParseDuration(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized mutate argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "mutate argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *mutateAction) String() string {
+	return fmt.Sprintf("mutate %q path=%q mode=%s size=%d topic=%q layout=%q chunker=%q raw-leaves=%t after=%s", a.subject, a.path, a.mode, a.size, a.topic, a.layout, a.chunker, a.rawLeaves, a.after)
+}
+
+func (a *mutateAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:      metadata.TaskMutate,
+			Subject:   a.subject,
+			Path:      a.path,
+			Mutation:  a.mode,
+			Size:      int(a.size),
+			Topic:     a.topic,
+			Delay:     a.after,
+			Layout:    a.layout,
+			Chunker:   a.chunker,
+			RawLeaves: a.rawLeaves,
+		}
+	}
+	return taskMap, nil
+}
+
+// followAction retrieves the updates announced on a topic by mutate actions.
+type followAction struct {
+	topic   string
+	count   int
+	timeout time.Duration
+}
+
+func parseFollowAction(args []string) (*followAction, error) {
+	if len(args) == 0 {
+		return nil, errors.Wrap(errdefs.ErrInvalidArgument, "follow topic must be provided")
+	}
+
+	kvs, err := parseArgs(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	a := &followAction{
+		topic:   args[0],
+		count:   1,
+		timeout: time.Minute,
+	}
+
+	for key, value := range kvs {
+		var err error
+		switch key {
+		case "count":
+			a.count, err = strconv.Atoi(value)
+			if err == nil && a.count <= 0 {
+				err = errors.Errorf("must be positive")
+			}
+		case "timeout":
+			a.timeout, err = time.ParseDuration(value)
+		default:
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized follow argument %q", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "follow argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *followAction) String() string {
+	return fmt.Sprintf("follow %q count=%d timeout=%s", a.topic, a.count, a.timeout)
+}
+
+func (a *followAction) Tasks(ctx context.Context, ns []p2plab.Node) (map[string]metadata.Task, error) {
+	taskMap := make(map[string]metadata.Task)
+	for _, n := range ns {
+		taskMap[n.Metadata().ID] = metadata.Task{
+			Type:    metadata.TaskFollow,
+			Subject: a.topic,
+			Count:   a.count,
+			Timeout: a.timeout,
+		}
+	}
+	return taskMap, nil
+}
@@ -16,6 +16,7 @@ package p2plab
 
 import (
 	"context"
+	"time"
 
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -40,6 +41,9 @@ type ControlAPI interface {
 
 	// Build returns an implementation of Build API.
 	Build() BuildAPI
+
+	// Schedule returns an implementation of Schedule API.
+	Schedule() ScheduleAPI
 }
 
 type AgentAPI interface {
@@ -47,6 +51,21 @@ type AgentAPI interface {
 
 	Update(ctx context.Context, id, link string, pdef metadata.PeerDefinition) error
 
+	// ClockOffset estimates how far the node's clock is ahead of the local
+	// clock.
+	ClockOffset(ctx context.Context) (metadata.ClockOffset, error)
+
+	// HostSamples returns the resource utilization of the node's host sampled
+	// after a time in the node's clock.
+	HostSamples(ctx context.Context, since time.Time) ([]metadata.HostSample, error)
+
+	// Impair replaces the network impairments applied to the node's traffic.
+	// Passing no rules removes all impairments.
+	Impair(ctx context.Context, rules []metadata.NetworkRule) error
+
+	// Logs calls fn with the lines logged by the node's labagent and labapp.
+	Logs(ctx context.Context, fn func(metadata.LogLine) error, opts ...LogsOption) error
+
 	// SSH creates a SSH connection to the node.
 	SSH(ctx context.Context, opts ...SSHOption) error
 }
@@ -56,6 +75,17 @@ type AppAPI interface {
 
 	Report(ctx context.Context) (metadata.ReportNode, error)
 
+	// Conns returns the connections the node's peer has open.
+	Conns(ctx context.Context) ([]metadata.ReportPeerConn, error)
+
+	// StartProfile starts capturing a profile of the node's labapp. Heap
+	// profiles need not be started.
+	StartProfile(ctx context.Context, kind metadata.ProfileKind) error
+
+	// StopProfile stops capturing a profile of the node's labapp and returns
+	// it in the pprof format.
+	StopProfile(ctx context.Context, kind metadata.ProfileKind) ([]byte, error)
+
 	// Run executes an task on the node.
 	Run(ctx context.Context, task metadata.Task) error
 }
@@ -25,15 +25,48 @@ type BenchmarkAPI interface {
 	// Create creates a benchmark of a scenario on a cluster.
 	Create(ctx context.Context, cluster, scenario string, opts ...StartBenchmarkOption) (id string, err error)
 
+	// Attach streams the logs of a running benchmark from when it started
+	// until it completes, resuming the stream if it breaks. Nothing is
+	// streamed for a benchmark that is not running.
+	Attach(ctx context.Context, id string) error
+
 	// Get returns a benchmark.
 	Get(ctx context.Context, id string) (Benchmark, error)
 
+	// Status returns how far a benchmark has progressed and when it is
+	// estimated to complete.
+	Status(ctx context.Context, id string) (metadata.BenchmarkProgress, error)
+
 	Label(ctx context.Context, ids, adds, removes []string) ([]Benchmark, error)
 
 	// List returns available benchmarks.
 	List(ctx context.Context, opts ...ListOption) ([]Benchmark, error)
 
 	Remove(ctx context.Context, ids ...string) error
+
+	// Cancel aborts a running benchmark, which is marked as aborted with the
+	// partial reports of its nodes.
+	Cancel(ctx context.Context, id string) error
+
+	// Pin marks a benchmark as the baseline of its scenario, which later
+	// benchmarks of the scenario are compared against.
+	Pin(ctx context.Context, id string) error
+
+	// Unpin clears the baseline of a benchmark's scenario if it is the
+	// benchmark.
+	Unpin(ctx context.Context, id string) error
+
+	// Publish uploads the rendered report and raw data of a benchmark to
+	// labd's publisher, and records their shareable links in the benchmark.
+	Publish(ctx context.Context, id string) (Benchmark, error)
+
+	// Compare compares the report of a base benchmark to the report of a head
+	// benchmark of the same scenario.
+	Compare(ctx context.Context, base, head string, opts ...CompareOption) (metadata.ReportComparison, error)
+
+	// Diff compares the nodes of a base benchmark to the nodes of a head
+	// benchmark, matched by the queries that selected them and their labels.
+	Diff(ctx context.Context, base, head string, opts ...DiffOption) (metadata.ReportDiff, error)
 }
 
 // Benchmark is an execution of a scenario on a cluster.
@@ -43,12 +76,33 @@ type Benchmark interface {
 	Metadata() metadata.Benchmark
 
 	Report(ctx context.Context) (metadata.Report, error)
+
+	// Trace returns the sequence of tasks executed by the benchmark.
+	Trace(ctx context.Context) (metadata.Trace, error)
+
+	// Profile returns a profile captured from a node's labapp while the
+	// benchmark ran, in the pprof format.
+	Profile(ctx context.Context, node string, kind metadata.ProfileKind) ([]byte, error)
 }
 
 type StartBenchmarkOption func(*StartBenchmarkSettings) error
 
 type StartBenchmarkSettings struct {
 	NoReset bool
+
+	// Replay is the ID of a benchmark whose trace is replayed instead of
+	// running the scenario's stages.
+	Replay string
+
+	// Labels are added to the benchmark when it is created.
+	Labels []string
+
+	// Detach keeps running the benchmark when the request is cancelled.
+	Detach bool
+
+	// Started is called with the ID of the benchmark once labd has started
+	// it, before it completes.
+	Started func(id string)
 }
 
 func WithBenchmarkNoReset() StartBenchmarkOption {
@@ -57,3 +111,88 @@ func WithBenchmarkNoReset() StartBenchmarkOption {
 		return nil
 	}
 }
+
+// WithBenchmarkReplay replays the trace of a benchmark with the same scenario
+// and seed, so that the cluster executes the identical sequence of tasks.
+func WithBenchmarkReplay(id string) StartBenchmarkOption {
+	return func(s *StartBenchmarkSettings) error {
+		s.Replay = id
+		return nil
+	}
+}
+
+// WithBenchmarkLabels adds labels to the benchmark when it is created, such as
+// the git branch or pull request it benchmarks, so that it can be queried
+// later.
+func WithBenchmarkLabels(labels ...string) StartBenchmarkOption {
+	return func(s *StartBenchmarkSettings) error {
+		s.Labels = append(s.Labels, labels...)
+		return nil
+	}
+}
+
+// WithBenchmarkDetach keeps labd running the benchmark if the request is
+// cancelled, such as when labctl is interrupted. A detached benchmark can
+// still be cancelled with its cancel API.
+func WithBenchmarkDetach() StartBenchmarkOption {
+	return func(s *StartBenchmarkSettings) error {
+		s.Detach = true
+		return nil
+	}
+}
+
+// WithBenchmarkStarted calls fn with the ID of the benchmark once labd has
+// started it, so that it can be followed before it completes.
+func WithBenchmarkStarted(fn func(id string)) StartBenchmarkOption {
+	return func(s *StartBenchmarkSettings) error {
+		s.Started = fn
+		return nil
+	}
+}
+
+type CompareOption func(*CompareSettings) error
+
+type CompareSettings struct {
+	// Significance is the p-value below which a change is significant, or
+	// zero for metadata.DefaultSignificance.
+	Significance float64
+}
+
+// WithCompareSignificance sets the p-value below which a change between the
+// benchmarks is significant.
+func WithCompareSignificance(significance float64) CompareOption {
+	return func(s *CompareSettings) error {
+		s.Significance = significance
+		return nil
+	}
+}
+
+type DiffOption func(*DiffSettings) error
+
+type DiffSettings struct {
+	// By is how nodes are matched and compared, or empty for
+	// metadata.DiffByNode.
+	By metadata.DiffGrouping
+
+	// Threshold is the relative change beyond which a metric changed, or
+	// zero for metadata.DefaultDiffThreshold.
+	Threshold float64
+}
+
+// WithDiffGrouping sets how the nodes of the benchmarks are matched and
+// compared.
+func WithDiffGrouping(by metadata.DiffGrouping) DiffOption {
+	return func(s *DiffSettings) error {
+		s.By = by
+		return nil
+	}
+}
+
+// WithDiffThreshold sets the relative change beyond which a metric of a node
+// changed.
+func WithDiffThreshold(threshold float64) DiffOption {
+	return func(s *DiffSettings) error {
+		s.Threshold = threshold
+		return nil
+	}
+}
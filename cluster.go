@@ -25,15 +25,42 @@ type ClusterAPI interface {
 	// Create deploys a cluster.
 	Create(ctx context.Context, name string, opts ...CreateClusterOption) (id string, err error)
 
+	// Plan validates a cluster and returns what would be created for it
+	// without provisioning anything.
+	Plan(ctx context.Context, name string, opts ...CreateClusterOption) (NodeGroupPlan, error)
+
+	// Checkout returns the id of a warm cluster from the pool that matches the
+	// cluster definition, or an empty id if there are none available. Pooled
+	// clusters keep the name they were created with, so if a name is given only
+	// the pooled cluster of that name is checked out.
+	Checkout(ctx context.Context, name string, opts ...CreateClusterOption) (id string, err error)
+
+	// Return puts clusters back into the pool instead of destroying them.
+	Return(ctx context.Context, names ...string) error
+
 	// Get returns a cluster.
 	Get(ctx context.Context, name string) (Cluster, error)
 
-	// Label adds/removes labels to/from clusters.
-	Label(ctx context.Context, names, adds, removes []string) ([]Cluster, error)
+	// Label adds/removes labels to/from clusters, along with the clusters
+	// matching the query of opts.
+	Label(ctx context.Context, names, adds, removes []string, opts ...ListOption) ([]Cluster, error)
 
 	// List returns available clusters.
 	List(ctx context.Context, opts ...ListOption) ([]Cluster, error)
 
+	// Pause stops the instances of clusters without destroying them.
+	Pause(ctx context.Context, names ...string) error
+
+	// Resume starts the instances of paused clusters and reconnects their
+	// peers.
+	Resume(ctx context.Context, names ...string) error
+
+	// Cost returns the estimated and accrued cost of all clusters.
+	Cost(ctx context.Context) (metadata.CostReport, error)
+
+	// Health checks whether the agent of each node of a cluster responds.
+	Health(ctx context.Context, name string) (metadata.ClusterHealth, error)
+
 	// Remove destroys clusters permanently.
 	Remove(ctx context.Context, names ...string) error
 }
@@ -60,7 +87,17 @@ type CreateClusterSettings struct {
 	Size              int
 	InstanceType      string
 	Region            string
+	Image             string
+	Retries           int
+	Tolerance         float64
 	ClusterDefinition metadata.ClusterDefinition
+
+	// Detach keeps creating the cluster when the request is cancelled.
+	Detach bool
+
+	// Started is called with the ID of the cluster once labd has started
+	// creating it, before it is created.
+	Started func(id string)
 }
 
 func WithClusterDefinition(definition string) CreateClusterOption {
@@ -91,6 +128,58 @@ func WithClusterRegion(region string) CreateClusterOption {
 	}
 }
 
+func WithClusterImage(image string) CreateClusterOption {
+	return func(s *CreateClusterSettings) error {
+		s.Image = image
+		return nil
+	}
+}
+
+// WithClusterRetries sets the number of times failed nodes are replaced
+// during provisioning.
+func WithClusterRetries(retries int) CreateClusterOption {
+	return func(s *CreateClusterSettings) error {
+		s.Retries = retries
+		return nil
+	}
+}
+
+// WithClusterTolerance sets the fraction of nodes that may fail to provision
+// before the cluster creation fails.
+func WithClusterTolerance(tolerance float64) CreateClusterOption {
+	return func(s *CreateClusterSettings) error {
+		s.Tolerance = tolerance
+		return nil
+	}
+}
+
+// WithParsedClusterDefinition sets the cluster definition to create, instead
+// of reading it from a file.
+func WithParsedClusterDefinition(cdef metadata.ClusterDefinition) CreateClusterOption {
+	return func(s *CreateClusterSettings) error {
+		s.ClusterDefinition = cdef
+		return nil
+	}
+}
+
+// WithClusterDetach keeps labd creating the cluster if the request is
+// cancelled, such as when labctl is interrupted.
+func WithClusterDetach() CreateClusterOption {
+	return func(s *CreateClusterSettings) error {
+		s.Detach = true
+		return nil
+	}
+}
+
+// WithClusterStarted calls fn with the ID of the cluster once labd has
+// started creating it.
+func WithClusterStarted(fn func(id string)) CreateClusterOption {
+	return func(s *CreateClusterSettings) error {
+		s.Started = fn
+		return nil
+	}
+}
+
 type ListOption func(*ListSettings) error
 
 type ListSettings struct {
@@ -64,6 +64,12 @@ func App(ctx context.Context) *cli.App {
 			Value:  "debug",
 			EnvVar: "LABAGENT_LOG_LEVEL",
 		},
+		cli.StringFlag{
+			Name:   "network-interface",
+			Usage:  "network interface to apply network impairments to",
+			Value:  "eth0",
+			EnvVar: "LABAGENT_NETWORK_INTERFACE",
+		},
 		cli.StringFlag{
 			Name:   "downloader.s3.region",
 			Usage:  "region for s3 downloader",
@@ -92,6 +98,7 @@ func agentAction(c *cli.Context) error {
 				Region: c.String("downloader.s3.region"),
 			},
 		}),
+		labagent.WithNetworkInterface(c.String("network-interface")),
 	)
 	if err != nil {
 		return err
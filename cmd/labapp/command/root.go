@@ -84,6 +84,47 @@ func App(ctx context.Context) *cli.App {
 			Usage:  "routing for libp2p [nil, kaddht]",
 			EnvVar: "LABAPP_LIBP2P_ROUTING",
 		},
+		cli.StringFlag{
+			Name:   "libp2p-network-stack",
+			Usage:  "ip stack for libp2p to listen on [ipv4, ipv6, dual]",
+			Value:  "ipv4",
+			EnvVar: "LABAPP_LIBP2P_NETWORK_STACK",
+		},
+		cli.BoolFlag{
+			Name:   "bitswap-no-provide",
+			Usage:  "disable announcing blocks received by bitswap",
+			EnvVar: "LABAPP_BITSWAP_NO_PROVIDE",
+		},
+		cli.DurationFlag{
+			Name:   "bitswap-search-delay",
+			Usage:  "delay before bitswap searches for providers",
+			EnvVar: "LABAPP_BITSWAP_SEARCH_DELAY",
+		},
+		cli.IntFlag{
+			Name:   "bitswap-task-workers",
+			Usage:  "number of workers sending blocks to peers concurrently",
+			EnvVar: "LABAPP_BITSWAP_TASK_WORKERS",
+		},
+		cli.BoolFlag{
+			Name:   "bitswap-trace",
+			Usage:  "record every bitswap message sent and received in reports",
+			EnvVar: "LABAPP_BITSWAP_TRACE",
+		},
+		cli.StringSliceFlag{
+			Name:   "collectors",
+			Usage:  "metrics collectors whose metrics are merged into reports",
+			EnvVar: "LABAPP_COLLECTORS",
+		},
+		cli.StringSliceFlag{
+			Name:   "exec-allow",
+			Usage:  "commands that exec actions may run",
+			EnvVar: "LABAPP_EXEC_ALLOW",
+		},
+		cli.BoolFlag{
+			Name:   "exec-scripts",
+			Usage:  "allow exec actions to run scripts retrieved from the cluster",
+			EnvVar: "LABAPP_EXEC_SCRIPTS",
+		},
 		cli.StringFlag{
 			Name:   "log-level,l",
 			Usage:  "set the logging level [debug, info, warn, error, fatal, panic, none]",
@@ -126,12 +167,23 @@ func appAction(c *cli.Context) error {
 		}
 	}
 
-	app, err := labapp.New(ctx, root, c.GlobalString("address"), c.GlobalInt("libp2p-port"), zerolog.Ctx(ctx), metadata.PeerDefinition{
+	pdef := metadata.PeerDefinition{
 		Transports:         c.GlobalStringSlice("libp2p-transports"),
 		Muxers:             c.GlobalStringSlice("libp2p-muxers"),
 		SecurityTransports: c.GlobalStringSlice("libp2p-security-transports"),
 		Routing:            c.GlobalString("libp2p-routing"),
-	})
+		NetworkStack:       metadata.NetworkStack(c.GlobalString("libp2p-network-stack")),
+		BitswapNoProvide:   c.GlobalBool("bitswap-no-provide"),
+		BitswapSearchDelay: c.GlobalDuration("bitswap-search-delay"),
+		BitswapTaskWorkers: c.GlobalInt("bitswap-task-workers"),
+		BitswapTrace:       c.GlobalBool("bitswap-trace"),
+		Collectors:         c.GlobalStringSlice("collectors"),
+	}
+
+	app, err := labapp.New(ctx, root, c.GlobalString("address"), c.GlobalInt("libp2p-port"), zerolog.Ctx(ctx), pdef,
+		labapp.WithExecAllow(c.GlobalStringSlice("exec-allow")),
+		labapp.WithExecScripts(c.GlobalBool("exec-scripts")),
+	)
 	if err != nil {
 		return err
 	}
@@ -15,10 +15,20 @@
 package command
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/pkg/defutil"
 	"github.com/Netflix/p2plab/printer"
 	"github.com/Netflix/p2plab/query"
 	"github.com/rs/zerolog"
@@ -30,18 +40,70 @@ var benchmarkCommand = cli.Command{
 	Aliases: []string{"b"},
 	Usage:   "Manage benchmarks.",
 	Subcommands: []cli.Command{
+		{
+			Name:      "cancel",
+			Usage:     "Aborts a running benchmark, keeping the partial reports of its nodes.",
+			ArgsUsage: "<id>",
+			Action:    cancelBenchmarkAction,
+		},
+		{
+			Name:      "compare",
+			Aliases:   []string{"c"},
+			Usage:     "Compares the reports of two benchmarks of the same scenario, by default against the pinned baseline.",
+			ArgsUsage: "[<base>] <head>",
+			Action:    compareBenchmarksAction,
+			Flags: []cli.Flag{
+				&cli.Float64Flag{
+					Name:  "significance",
+					Usage: "The p-value below which a change is significant.",
+					Value: metadata.DefaultSignificance,
+				},
+				&cli.BoolFlag{
+					Name:  "cached",
+					Usage: "Compares the locally cached reports without contacting labd.",
+				},
+			},
+		},
+		{
+			Name:      "diff",
+			Usage:     "Compares the nodes of two benchmarks to find the nodes or regions that regressed, by default against the pinned baseline.",
+			ArgsUsage: "[<base>] <head>",
+			Action:    diffBenchmarksAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "by",
+					Usage: "Compares each node, the nodes of each query, or the nodes of each label such as a region, either node, query or label.",
+					Value: string(metadata.DiffByNode),
+				},
+				&cli.Float64Flag{
+					Name:  "threshold",
+					Usage: "The relative change beyond which a metric changed.",
+					Value: metadata.DefaultDiffThreshold,
+				},
+			},
+		},
 		{
 			Name:      "create",
 			Aliases:   []string{"s"},
 			Usage:     "Benchmarks a scenario on a cluster.",
 			ArgsUsage: "<cluster> <scenario>",
 			Action:    createBenchmarkAction,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.BoolFlag{
 					Name:  "no-reset",
 					Usage: "Skips resetting the cluster to maintain a stale state",
 				},
-			},
+				&cli.StringSliceFlag{
+					Name:  "label,l",
+					Usage: "Adds a label to the benchmark, such as branch=main or pr=123.",
+				},
+			}, waitFlags(waitStarted, "connected", "completed")...),
+		},
+		{
+			Name:      "attach",
+			Usage:     "Streams the logs of a running benchmark until it completes, even if it was started elsewhere.",
+			ArgsUsage: "<id>",
+			Action:    attachBenchmarkAction,
 		},
 		{
 			Name:      "inspect",
@@ -73,12 +135,28 @@ var benchmarkCommand = cli.Command{
 			Usage:     "List benchmarks",
 			ArgsUsage: " ",
 			Action:    listBenchmarkAction,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:  "query,q",
 					Usage: "Runs a query to filter the listed benchmarks.",
 				},
-			},
+				&cli.IntFlag{
+					Name:  "limit,n",
+					Usage: "Lists only the most recently created benchmarks.",
+				},
+				&cli.StringFlag{
+					Name:  "view",
+					Usage: "Lists benchmarks with the query and limit of a saved view.",
+				},
+				&cli.StringFlag{
+					Name:  "save-view",
+					Usage: "Saves the query and limit as a view with a name.",
+				},
+				&cli.BoolFlag{
+					Name:  "cached",
+					Usage: "Lists the locally cached benchmarks without contacting labd.",
+				},
+			}, watchFlags...),
 		},
 		{
 			Name:      "report",
@@ -86,6 +164,165 @@ var benchmarkCommand = cli.Command{
 			Usage:     "Display a benchmark's report.",
 			ArgsUsage: "<id>",
 			Action:    benchmarkReportAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format,f",
+					Usage: "Format of the report, either text or html.",
+					Value: "text",
+				},
+				&cli.StringFlag{
+					Name:  "out,o",
+					Usage: "Writes the report to a file instead of stdout.",
+				},
+			},
+		},
+		{
+			Name:      "render",
+			Usage:     "Display a report saved to a file, without contacting labd.",
+			ArgsUsage: "<file>",
+			Action:    renderBenchmarkAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format,f",
+					Usage: "Format of the report, either text or html.",
+					Value: "text",
+				},
+				&cli.StringFlag{
+					Name:  "out,o",
+					Usage: "Writes the report to a file instead of stdout.",
+				},
+				&cli.StringFlag{
+					Name:  "benchmark,b",
+					Usage: "Reads the benchmark of the report from a file saved by benchmark inspect.",
+				},
+			},
+		},
+		{
+			Name:      "export",
+			Aliases:   []string{"e"},
+			Usage:     "Exports a benchmark's results as flat rows for external analysis.",
+			ArgsUsage: "<id>",
+			Action:    exportBenchmarkAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format,f",
+					Usage: "Format of the rows, either csv or jsonl.",
+					Value: string(printer.ExportCSV),
+				},
+				&cli.StringFlag{
+					Name:  "data,d",
+					Usage: "Results to export, either nodes, series, hosts, bandwidth, actions or bitswap.",
+					Value: "nodes",
+				},
+				&cli.StringFlag{
+					Name:  "out,o",
+					Usage: "Writes the rows to a file instead of stdout.",
+				},
+			},
+		},
+		{
+			Name:      "pin",
+			Usage:     "Marks a benchmark as the baseline that later benchmarks of its scenario are compared against.",
+			ArgsUsage: "<id>",
+			Action:    pinBenchmarkAction,
+		},
+		{
+			Name:      "unpin",
+			Usage:     "Clears the baseline of a benchmark's scenario.",
+			ArgsUsage: "<id>",
+			Action:    unpinBenchmarkAction,
+		},
+		{
+			Name:      "profiles",
+			Usage:     "Lists the profiles captured from a benchmark's nodes, or writes the profile of a node for go tool pprof.",
+			ArgsUsage: "<id>",
+			Action:    benchmarkProfilesAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "node,n",
+					Usage: "Writes the profile of a node.",
+				},
+				&cli.StringFlag{
+					Name:  "kind,k",
+					Usage: "Kind of the written profile, either cpu or heap.",
+					Value: string(metadata.ProfileCPU),
+				},
+				&cli.StringFlag{
+					Name:  "out,o",
+					Usage: "Writes the profile to a file instead of stdout.",
+				},
+			},
+		},
+		{
+			Name:      "publish",
+			Usage:     "Uploads the report and raw data of a benchmark to labd's object store and displays shareable links.",
+			ArgsUsage: "<id>",
+			Action:    publishBenchmarkAction,
+		},
+		{
+			Name:      "replay",
+			Usage:     "Replays the trace of a benchmark on a cluster.",
+			ArgsUsage: "<cluster> <benchmark>",
+			Action:    replayBenchmarkAction,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "no-reset",
+					Usage: "Skips resetting the cluster to maintain a stale state",
+				},
+			},
+		},
+		{
+			Name:      "status",
+			Usage:     "Displays the progress of a benchmark and when it is estimated to complete.",
+			ArgsUsage: "<id>",
+			Action:    benchmarkStatusAction,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "watch,w",
+					Usage: "Refreshes the progress until the benchmark completes.",
+				},
+				&cli.DurationFlag{
+					Name:  "interval",
+					Usage: "How often the progress is refreshed when watching.",
+					Value: 2 * time.Second,
+				},
+			},
+		},
+		{
+			Name:      "topology",
+			Usage:     "Displays the connections between nodes when a benchmark started and ended.",
+			ArgsUsage: "<id>",
+			Action:    benchmarkTopologyAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format,f",
+					Usage: "Format of the topology, either json or dot for Graphviz.",
+					Value: "json",
+				},
+				&cli.StringFlag{
+					Name:  "out,o",
+					Usage: "Writes the topology to a file instead of stdout.",
+				},
+			},
+		},
+		{
+			Name:      "trace",
+			Aliases:   []string{"t"},
+			Usage:     "Displays the sequence of tasks executed by a benchmark.",
+			ArgsUsage: "<id>",
+			Action:    benchmarkTraceAction,
+		},
+		{
+			Name:      "views",
+			Usage:     "Lists or removes the saved views of benchmark list.",
+			ArgsUsage: " ",
+			Action:    benchmarkViewsAction,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "remove,rm",
+					Usage: "Removes a saved view.",
+				},
+			},
 		},
 		{
 			Name:      "remove",
@@ -97,9 +334,11 @@ var benchmarkCommand = cli.Command{
 	},
 }
 
-func createBenchmarkAction(c *cli.Context) error {
-	if c.NArg() != 2 {
-		return errors.New("cluster and scenario name must be provided")
+// compareBenchmarksAction prints the comparison of two benchmarks, and returns
+// an error if the head benchmark significantly regressed.
+func compareBenchmarksAction(c *cli.Context) error {
+	if c.NArg() != 1 && c.NArg() != 2 {
+		return errors.New("head benchmark id must be provided, optionally after a base benchmark id")
 	}
 
 	p, err := CommandPrinter(c, printer.OutputTable)
@@ -107,46 +346,127 @@ func createBenchmarkAction(c *cli.Context) error {
 		return err
 	}
 
-	control, err := ResolveControl(c)
+	var (
+		base, head string
+		comparison metadata.ReportComparison
+	)
+	if c.NArg() == 2 {
+		base, head = c.Args().Get(0), c.Args().Get(1)
+	} else {
+		head = c.Args().First()
+	}
+
+	ctx := cliutil.CommandContext(c)
+	if c.Bool("cached") {
+		comparison, err = compareCachedBenchmarks(base, head, c.Float64("significance"))
+		if err != nil {
+			return err
+		}
+		base = comparison.Base
+	} else {
+		control, err := ResolveControl(c)
+		if err != nil {
+			return err
+		}
+
+		if base == "" {
+			base, err = scenarioBaseline(ctx, control, head)
+			if err != nil {
+				return err
+			}
+		}
+
+		comparison, err = control.Benchmark().Compare(ctx, base, head, p2plab.WithCompareSignificance(c.Float64("significance")))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = p.Print(comparison)
 	if err != nil {
 		return err
 	}
 
-	ctx := cliutil.CommandContext(c)
-	cluster, scenario := c.Args().Get(0), c.Args().Get(1)
+	regressions := comparison.Regressions()
+	if len(regressions) > 0 {
+		return fmt.Errorf("benchmark %q regressed %d metrics from %q", head, len(regressions), base)
+	}
 
-	var opts []p2plab.StartBenchmarkOption
-	if c.Bool("no-reset") {
-		opts = append(opts, p2plab.WithBenchmarkNoReset())
+	return nil
+}
+
+// diffBenchmarksAction prints the metrics of the nodes of two benchmarks that
+// changed, and returns an error if any node of the head benchmark regressed.
+func diffBenchmarksAction(c *cli.Context) error {
+	if c.NArg() != 1 && c.NArg() != 2 {
+		return errors.New("head benchmark id must be provided, optionally after a base benchmark id")
 	}
 
-	id, err := control.Benchmark().Create(ctx, cluster, scenario, opts...)
+	p, err := CommandPrinter(c, printer.OutputTable)
 	if err != nil {
 		return err
 	}
 
-	benchmark, err := control.Benchmark().Get(ctx, id)
+	control, err := ResolveControl(c)
 	if err != nil {
 		return err
 	}
-	zerolog.Ctx(ctx).Info().Msgf("Completed benchmark %q", benchmark.Metadata().ID)
 
-	report, err := benchmark.Report(ctx)
+	ctx := cliutil.CommandContext(c)
+	var base, head string
+	if c.NArg() == 2 {
+		base, head = c.Args().Get(0), c.Args().Get(1)
+	} else {
+		head = c.Args().First()
+		base, err = scenarioBaseline(ctx, control, head)
+		if err != nil {
+			return err
+		}
+	}
+
+	diff, err := control.Benchmark().Diff(ctx, base, head,
+		p2plab.WithDiffGrouping(metadata.DiffGrouping(c.String("by"))),
+		p2plab.WithDiffThreshold(c.Float64("threshold")),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = p.Print(diff)
 	if err != nil {
 		return err
 	}
 
-	return p.Print(report)
+	regressions := diff.Regressions()
+	if len(regressions) > 0 {
+		return fmt.Errorf("benchmark %q regressed on %d %ss from %q", head, len(regressions), diff.By, base)
+	}
+
+	return nil
 }
 
-func inspectBenchmarkAction(c *cli.Context) error {
-	if c.NArg() != 1 {
-		return errors.New("benchmark id must be provided")
+// scenarioBaseline returns the pinned baseline of a benchmark's scenario.
+func scenarioBaseline(ctx context.Context, control p2plab.ControlAPI, id string) (string, error) {
+	benchmark, err := control.Benchmark().Get(ctx, id)
+	if err != nil {
+		return "", err
 	}
 
-	p, err := CommandPrinter(c, printer.OutputJSON)
+	scenario, err := control.Scenario().Get(ctx, benchmark.Metadata().Scenario.ID)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	baseline := scenario.Metadata().Baseline
+	if baseline == "" {
+		return "", fmt.Errorf("scenario %q has no pinned baseline", scenario.ID())
+	}
+	return baseline, nil
+}
+
+func cancelBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
 	}
 
 	control, err := ResolveControl(c)
@@ -156,45 +476,62 @@ func inspectBenchmarkAction(c *cli.Context) error {
 
 	ctx := cliutil.CommandContext(c)
 	id := c.Args().First()
-	benchmark, err := control.Benchmark().Get(ctx, id)
+	err = control.Benchmark().Cancel(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	return p.Print(benchmark.Metadata())
+	zerolog.Ctx(ctx).Info().Msgf("Cancelled benchmark %q", id)
+	return nil
 }
 
-func labelBenchmarksAction(c *cli.Context) error {
-	var ids []string
-	for i := 0; i < c.NArg(); i++ {
-		ids = append(ids, c.Args().Get(i))
+func pinBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
 	}
 
-	p, err := CommandPrinter(c, printer.OutputTable)
+	control, err := ResolveControl(c)
 	if err != nil {
 		return err
 	}
 
-	control, err := ResolveControl(c)
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	err = control.Benchmark().Pin(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	ctx := cliutil.CommandContext(c)
-	benchmarks, err := control.Benchmark().Label(ctx, ids, c.StringSlice("add"), c.StringSlice("remove"))
+	zerolog.Ctx(ctx).Info().Msgf("Pinned benchmark %q as baseline", id)
+	return nil
+}
+
+func unpinBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	control, err := ResolveControl(c)
 	if err != nil {
 		return err
 	}
 
-	l := make([]interface{}, len(benchmarks))
-	for i, b := range benchmarks {
-		l[i] = b.Metadata()
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	err = control.Benchmark().Unpin(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	return p.Print(l)
+	zerolog.Ctx(ctx).Info().Msgf("Unpinned benchmark %q as baseline", id)
+	return nil
 }
 
-func listBenchmarkAction(c *cli.Context) error {
+func createBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("cluster and scenario name must be provided")
+	}
+
 	p, err := CommandPrinter(c, printer.OutputTable)
 	if err != nil {
 		return err
@@ -205,31 +542,67 @@ func listBenchmarkAction(c *cli.Context) error {
 		return err
 	}
 
-	var opts []p2plab.ListOption
 	ctx := cliutil.CommandContext(c)
-	if c.IsSet("query") {
-		q, err := query.Parse(ctx, c.String("query"))
-		if err != nil {
-			return err
+	cluster, scenario := c.Args().Get(0), c.Args().Get(1)
+
+	var opts []p2plab.StartBenchmarkOption
+	if c.Bool("no-reset") {
+		opts = append(opts, p2plab.WithBenchmarkNoReset())
+	}
+	if len(c.StringSlice("label")) > 0 {
+		opts = append(opts, p2plab.WithBenchmarkLabels(c.StringSlice("label")...))
+	}
+
+	condition, err := waitCondition(c, waitStarted, "connected", "completed")
+	if err != nil {
+		return err
+	}
+
+	// A benchmark is connected once its cluster is connected and its first
+	// trial is running.
+	var reached func(ctx context.Context, id string) (bool, error)
+	if condition == "connected" {
+		reached = func(ctx context.Context, id string) (bool, error) {
+			progress, err := control.Benchmark().Status(ctx, id)
+			if err != nil {
+				return false, err
+			}
+			return progress.Status != metadata.BenchmarkPlanning, nil
 		}
+	}
 
-		opts = append(opts, p2plab.WithQuery(q.String()))
+	reporter, err := newProgressReporter(c, benchmarkProgress(control))
+	if err != nil {
+		return err
 	}
+	defer reporter.stop()
 
-	benchmarks, err := control.Benchmark().List(ctx, opts...)
+	id, completed, err := waitFor(c, condition, func(ctx context.Context, started func(id string)) (string, error) {
+		return control.Benchmark().Create(ctx, cluster, scenario, append(opts,
+			p2plab.WithBenchmarkDetach(),
+			p2plab.WithBenchmarkStarted(func(id string) {
+				reporter.start(id)
+				started(id)
+			}),
+		)...)
+	}, reached)
 	if err != nil {
 		return err
 	}
+	reporter.stop()
 
-	l := make([]interface{}, len(benchmarks))
-	for i, b := range benchmarks {
-		l[i] = b.Metadata()
+	if !completed {
+		progress, err := control.Benchmark().Status(ctx, id)
+		if err != nil {
+			return err
+		}
+		return p.Print(progress)
 	}
 
-	return p.Print(l)
+	return printBenchmarkReport(ctx, control, p, id, c.GlobalBool("quiet"))
 }
 
-func benchmarkReportAction(c *cli.Context) error {
+func attachBenchmarkAction(c *cli.Context) error {
 	if c.NArg() != 1 {
 		return errors.New("benchmark id must be provided")
 	}
@@ -244,25 +617,605 @@ func benchmarkReportAction(c *cli.Context) error {
 		return err
 	}
 
-	ctx := cliutil.CommandContext(c)
-	id := c.Args().First()
-	benchmark, err := control.Benchmark().Get(ctx, id)
+	reporter, err := newProgressReporter(c, benchmarkProgress(control))
 	if err != nil {
 		return err
 	}
+	defer reporter.stop()
 
-	report, err := benchmark.Report(ctx)
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	reporter.start(id)
+	err = control.Benchmark().Attach(ctx, id)
+	reporter.stop()
 	if err != nil {
+		if ctx.Err() != nil {
+			return detached(id, "completed", "interrupted")
+		}
 		return err
 	}
 
-	return p.Print(report)
+	return printBenchmarkReport(ctx, control, p, id, c.GlobalBool("quiet"))
 }
 
-func removeBenchmarksAction(c *cli.Context) error {
-	var ids []string
-	for i := 0; i < c.NArg(); i++ {
-		ids = append(ids, c.Args().Get(i))
+func replayBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("cluster and benchmark id must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	reporter, err := newProgressReporter(c, benchmarkProgress(control))
+	if err != nil {
+		return err
+	}
+	defer reporter.stop()
+
+	ctx := cliutil.CommandContext(c)
+	cluster, replay := c.Args().Get(0), c.Args().Get(1)
+
+	opts := []p2plab.StartBenchmarkOption{
+		p2plab.WithBenchmarkReplay(replay),
+		p2plab.WithBenchmarkStarted(reporter.start),
+	}
+	if c.Bool("no-reset") {
+		opts = append(opts, p2plab.WithBenchmarkNoReset())
+	}
+
+	id, err := control.Benchmark().Create(ctx, cluster, "", opts...)
+	if err != nil {
+		return err
+	}
+	reporter.stop()
+
+	return printBenchmarkReport(ctx, control, p, id, c.GlobalBool("quiet"))
+}
+
+// printBenchmarkReport prints the report of a completed benchmark, or only
+// its ID when quiet, and returns an error if it did not meet its
+// expectations.
+func printBenchmarkReport(ctx context.Context, control p2plab.ControlAPI, p printer.Printer, id string, quiet bool) error {
+	benchmark, err := control.Benchmark().Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	zerolog.Ctx(ctx).Info().Msgf("Completed benchmark %q", benchmark.Metadata().ID)
+
+	report, err := benchmark.Report(ctx)
+	if err != nil {
+		return err
+	}
+	cacheBenchmark(ctx, benchmark.Metadata(), &report)
+
+	if quiet {
+		err = p.Print(benchmark.Metadata())
+	} else {
+		err = p.Print(report)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Regressions from the pinned baseline are logged rather than printed, so
+	// that they do not interleave with the report in other output formats.
+	m := benchmark.Metadata()
+	if m.Baseline != "" {
+		comparison, err := control.Benchmark().Compare(ctx, m.Baseline, m.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, mc := range comparison.Regressions() {
+			zerolog.Ctx(ctx).Warn().Str("base", mc.Base).Str("head", mc.Head).Str("delta", mc.Delta).Msgf("Regressed %s from baseline", mc.Metric)
+		}
+		if len(comparison.Regressions()) == 0 {
+			zerolog.Ctx(ctx).Info().Msgf("No regressions from baseline %q", m.Baseline)
+		}
+	}
+
+	if !metadata.ExpectationsPassed(report.Summary.Expectations) {
+		return withExitCode(ExitExpectations, fmt.Errorf("benchmark %q did not meet expectations", benchmark.Metadata().ID))
+	}
+
+	return nil
+}
+
+func inspectBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputJSON)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	benchmark, err := control.Benchmark().Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return p.Print(benchmark.Metadata())
+}
+
+func labelBenchmarksAction(c *cli.Context) error {
+	var ids []string
+	for i := 0; i < c.NArg(); i++ {
+		ids = append(ids, c.Args().Get(i))
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	benchmarks, err := control.Benchmark().Label(ctx, ids, c.StringSlice("add"), c.StringSlice("remove"))
+	if err != nil {
+		return err
+	}
+
+	l := make([]interface{}, len(benchmarks))
+	for i, b := range benchmarks {
+		l[i] = b.Metadata()
+	}
+
+	return p.Print(l)
+}
+
+func listBenchmarkAction(c *cli.Context) error {
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	var control p2plab.ControlAPI
+	if !c.Bool("cached") {
+		control, err = ResolveControl(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	var view metadata.BenchmarkView
+	if c.IsSet("view") {
+		view, err = GetView(c.String("view"))
+		if err != nil {
+			return err
+		}
+	}
+	if c.IsSet("query") {
+		view.Query = c.String("query")
+	}
+	if c.IsSet("limit") {
+		view.Limit = c.Int("limit")
+	}
+
+	var opts []p2plab.ListOption
+	ctx := cliutil.CommandContext(c)
+	if view.Query != "" {
+		q, err := query.Parse(ctx, view.Query)
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, p2plab.WithQuery(q.String()))
+	}
+
+	if c.IsSet("save-view") {
+		view.Name = c.String("save-view")
+		err = SaveView(view)
+		if err != nil {
+			return err
+		}
+		zerolog.Ctx(ctx).Info().Msgf("Saved view %q", view.Name)
+	}
+
+	return printList(c, p, func(ctx context.Context) ([]interface{}, error) {
+		var (
+			benchmarks []metadata.Benchmark
+			err        error
+		)
+		if control == nil {
+			benchmarks, err = listCachedBenchmarks(ctx, view.Query)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			bs, err := control.Benchmark().List(ctx, opts...)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, b := range bs {
+				benchmarks = append(benchmarks, b.Metadata())
+				cacheBenchmark(ctx, b.Metadata(), nil)
+			}
+		}
+
+		if view.Limit > 0 && len(benchmarks) > view.Limit {
+			sort.SliceStable(benchmarks, func(i, j int) bool {
+				return benchmarks[i].CreatedAt.After(benchmarks[j].CreatedAt)
+			})
+			benchmarks = benchmarks[:view.Limit]
+		}
+
+		l := make([]interface{}, len(benchmarks))
+		for i, b := range benchmarks {
+			l[i] = b
+		}
+		return l, nil
+	})
+}
+
+func benchmarkViewsAction(c *cli.Context) error {
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	for _, name := range c.StringSlice("remove") {
+		err = RemoveView(name)
+		if err != nil {
+			return err
+		}
+		zerolog.Ctx(ctx).Info().Msgf("Removed view %q", name)
+	}
+
+	views, err := ListViews()
+	if err != nil {
+		return err
+	}
+
+	l := make([]interface{}, len(views))
+	for i, v := range views {
+		l[i] = v
+	}
+	return p.Print(l)
+}
+
+func benchmarkReportAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	benchmark, err := control.Benchmark().Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	report, err := benchmark.Report(ctx)
+	if err != nil {
+		return err
+	}
+	cacheBenchmark(ctx, benchmark.Metadata(), &report)
+
+	return writeReport(c, p, benchmark.Metadata(), report)
+}
+
+// renderBenchmarkAction renders a report saved by benchmark report or
+// publish without contacting labd.
+func renderBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("report file must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	filename := c.Args().First()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var report metadata.Report
+	err = defutil.Decode(filename, content, &report)
+	if err != nil {
+		return err
+	}
+
+	// Reports don't record their benchmark, so it is read from a file saved
+	// by benchmark inspect if given, or else named after the report.
+	var benchmark metadata.Benchmark
+	if c.IsSet("benchmark") {
+		content, err := ioutil.ReadFile(c.String("benchmark"))
+		if err != nil {
+			return err
+		}
+
+		err = defutil.Decode(c.String("benchmark"), content, &benchmark)
+		if err != nil {
+			return err
+		}
+	} else {
+		benchmark.ID = reportName(filename)
+	}
+
+	return writeReport(c, p, benchmark, report)
+}
+
+// reportName names a report after its file, or after its directory for
+// reports published as <id>/report.json.
+func reportName(filename string) string {
+	name := ExtractNameFromFilename(filename)
+	if name == "report" {
+		dir := filepath.Base(filepath.Dir(filename))
+		if dir != "." && dir != string(filepath.Separator) {
+			return dir
+		}
+	}
+	return name
+}
+
+// writeReport writes the report of a benchmark in the format of the format
+// flag, to stdout or the file of the out flag.
+func writeReport(c *cli.Context, p printer.Printer, benchmark metadata.Benchmark, report metadata.Report) error {
+	switch c.String("format") {
+	case "text":
+		if c.String("out") == "" {
+			return p.Print(report)
+		}
+		return errors.New("text reports are only printed to stdout")
+	case "html":
+		w := io.Writer(os.Stdout)
+		if dest := c.String("out"); dest != "" {
+			f, err := os.Create(dest)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return printer.WriteHTMLReport(w, benchmark, report)
+	default:
+		return fmt.Errorf("unknown report format %q", c.String("format"))
+	}
+}
+
+// exportBenchmarkAction writes the per-node metrics, sampled series, executed
+// tasks or traced bitswap messages of a benchmark as CSV or JSON lines.
+func exportBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	benchmark, err := control.Benchmark().Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var table printer.ExportTable
+	switch c.String("data") {
+	case "nodes", "series", "hosts", "bandwidth", "bitswap":
+		report, err := benchmark.Report(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch c.String("data") {
+		case "nodes":
+			table = printer.ExportNodes(report)
+		case "series":
+			table = printer.ExportSeries(report)
+		case "hosts":
+			table = printer.ExportHosts(report)
+		case "bandwidth":
+			table = printer.ExportBandwidth(report)
+		case "bitswap":
+			table, err = printer.ExportBitswap(report)
+			if err != nil {
+				return err
+			}
+		}
+	case "actions":
+		trace, err := benchmark.Trace(ctx)
+		if err != nil {
+			return err
+		}
+		table = printer.ExportActions(trace)
+	default:
+		return fmt.Errorf("unknown export data %q", c.String("data"))
+	}
+
+	w := io.Writer(os.Stdout)
+	if dest := c.String("out"); dest != "" {
+		f, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return table.Write(w, printer.ExportFormat(c.String("format")))
+}
+
+// benchmarkTopologyAction prints the connection graph captured by a benchmark,
+// either as JSON or as a Graphviz graph.
+func benchmarkTopologyAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputJSON)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	benchmark, err := control.Benchmark().Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	report, err := benchmark.Report(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch c.String("format") {
+	case "json":
+		if c.String("out") == "" {
+			return p.Print(report.Topology)
+		}
+		return errors.New("json topologies are only printed to stdout")
+	case "dot":
+		w := io.Writer(os.Stdout)
+		if dest := c.String("out"); dest != "" {
+			f, err := os.Create(dest)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return printer.WriteTopologyDOT(w, report.Topology)
+	default:
+		return fmt.Errorf("unknown topology format %q", c.String("format"))
+	}
+}
+
+// benchmarkProfilesAction prints the digests of the profiles of each node, or
+// writes the profile of a node if one is given.
+func benchmarkProfilesAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputJSON)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	benchmark, err := control.Benchmark().Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	node := c.String("node")
+	if node == "" {
+		report, err := benchmark.Report(ctx)
+		if err != nil {
+			return err
+		}
+
+		profiles := make(map[string][]metadata.ReportProfile)
+		for id, reportNode := range report.Nodes {
+			if len(reportNode.Profiles) > 0 {
+				profiles[id] = reportNode.Profiles
+			}
+		}
+		return p.Print(profiles)
+	}
+
+	profile, err := benchmark.Profile(ctx, node, metadata.ProfileKind(c.String("kind")))
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if dest := c.String("out"); dest != "" {
+		f, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err = w.Write(profile)
+	return err
+}
+
+func benchmarkTraceAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputJSON)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	benchmark, err := control.Benchmark().Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	trace, err := benchmark.Trace(ctx)
+	if err != nil {
+		return err
+	}
+
+	return p.Print(trace)
+}
+
+func removeBenchmarksAction(c *cli.Context) error {
+	var ids []string
+	for i := 0; i < c.NArg(); i++ {
+		ids = append(ids, c.Args().Get(i))
 	}
 
 	control, err := ResolveControl(c)
@@ -278,3 +1231,77 @@ func removeBenchmarksAction(c *cli.Context) error {
 
 	return nil
 }
+
+func publishBenchmarkAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	benchmark, err := control.Benchmark().Publish(ctx, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	published := benchmark.Metadata().Published
+	var names []string
+	for name := range published {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("%s\t%s\n", name, published[name])
+	}
+	return nil
+}
+
+func benchmarkStatusAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("benchmark id must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	id := c.Args().First()
+	for {
+		progress, err := control.Benchmark().Status(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("watch") {
+			// Clear the terminal so that the progress is redrawn in place.
+			fmt.Print("\033[H\033[2J")
+		}
+
+		err = p.Print(progress)
+		if err != nil {
+			return err
+		}
+
+		if !c.Bool("watch") || progress.Done() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.Duration("interval")):
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/query"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// cachePath returns the directory where labctl caches the benchmarks and
+// reports it fetches, so that they can be listed and compared when labd is
+// unreachable. It is either LABCTL_CACHE or benchmarks in labctl's user
+// cache directory, and has the layout labd publishes results in, so that
+// published results can be copied into it.
+func cachePath() (string, error) {
+	path := os.Getenv("LABCTL_CACHE")
+	if path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "labctl", "benchmarks"), nil
+}
+
+// cacheBenchmark caches the metadata of a benchmark, and its report if it is
+// not nil. The cache is only a fallback, so failing to write it is logged
+// rather than failing the command.
+func cacheBenchmark(ctx context.Context, benchmark metadata.Benchmark, report *metadata.Report) {
+	files := map[string]interface{}{
+		"benchmark.json": &benchmark,
+	}
+	if report != nil {
+		files["report.json"] = report
+	}
+
+	for name, v := range files {
+		err := writeCacheFile(benchmark.ID, name, v)
+		if err != nil {
+			zerolog.Ctx(ctx).Debug().Err(err).Msgf("Failed to cache %s of benchmark %q", name, benchmark.ID)
+		}
+	}
+}
+
+func writeCacheFile(id, name string, v interface{}) error {
+	dir, err := cachePath()
+	if err != nil {
+		return err
+	}
+
+	dir = filepath.Join(dir, id)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name), content, 0644)
+}
+
+func readCacheFile(id, name string, v interface{}) error {
+	dir, err := cachePath()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, id, name)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.Wrapf(errdefs.ErrNotFound, "%s of benchmark %q is not cached", name, id)
+		}
+		return err
+	}
+
+	err = json.Unmarshal(content, v)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse cached %q", path)
+	}
+	return nil
+}
+
+// cachedBenchmark returns the cached metadata of a benchmark. Benchmarks
+// copied from published results only have a report, so they only have an ID.
+func cachedBenchmark(id string) (metadata.Benchmark, error) {
+	var benchmark metadata.Benchmark
+	err := readCacheFile(id, "benchmark.json", &benchmark)
+	if errdefs.IsNotFound(err) {
+		_, err = cachedReport(id)
+		if err != nil {
+			return benchmark, err
+		}
+		return metadata.Benchmark{ID: id}, nil
+	}
+	return benchmark, err
+}
+
+// cachedReport returns the cached report of a benchmark.
+func cachedReport(id string) (metadata.Report, error) {
+	var report metadata.Report
+	err := readCacheFile(id, "report.json", &report)
+	return report, err
+}
+
+// cachedBenchmarks returns the metadata of the cached benchmarks sorted by
+// ID.
+func cachedBenchmarks() ([]metadata.Benchmark, error) {
+	dir, err := cachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var benchmarks []metadata.Benchmark
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+
+		benchmark, err := cachedBenchmark(info.Name())
+		if err != nil {
+			if errdefs.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		benchmarks = append(benchmarks, benchmark)
+	}
+
+	sort.Slice(benchmarks, func(i, j int) bool {
+		return benchmarks[i].ID < benchmarks[j].ID
+	})
+	return benchmarks, nil
+}
+
+// listCachedBenchmarks returns the cached benchmarks matching a query, or all
+// of them if the query is empty.
+func listCachedBenchmarks(ctx context.Context, q string) ([]metadata.Benchmark, error) {
+	benchmarks, err := cachedBenchmarks()
+	if err != nil || q == "" {
+		return benchmarks, err
+	}
+
+	var ls []p2plab.Labeled
+	for _, b := range benchmarks {
+		ls = append(ls, query.NewLabeled(b.ID, b.Labels))
+	}
+
+	mset, err := query.Execute(ctx, ls, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []metadata.Benchmark
+	for _, b := range benchmarks {
+		if mset.Contains(b.ID) {
+			matched = append(matched, b)
+		}
+	}
+	return matched, nil
+}
+
+// compareCachedBenchmarks compares the cached reports of two benchmarks. If
+// base is empty, the head is compared against the baseline that was pinned
+// when it completed.
+func compareCachedBenchmarks(base, head string, significance float64) (metadata.ReportComparison, error) {
+	var comparison metadata.ReportComparison
+	hb, err := cachedBenchmark(head)
+	if err != nil {
+		return comparison, err
+	}
+
+	if base == "" {
+		if hb.Baseline == "" {
+			return comparison, errors.Wrapf(errdefs.ErrNotFound, "cached benchmark %q has no baseline", head)
+		}
+		base = hb.Baseline
+	}
+
+	bb, err := cachedBenchmark(base)
+	if err != nil {
+		return comparison, err
+	}
+
+	// Benchmarks copied from published results have no scenario to check.
+	if bb.Scenario.ID != "" && hb.Scenario.ID != "" && bb.Scenario.ID != hb.Scenario.ID {
+		return comparison, errors.Wrapf(errdefs.ErrInvalidArgument, "benchmark %q is of scenario %q but %q is of scenario %q", base, bb.Scenario.ID, head, hb.Scenario.ID)
+	}
+
+	var reports []metadata.Report
+	for _, id := range []string{base, head} {
+		report, err := cachedReport(id)
+		if err != nil {
+			return comparison, err
+		}
+		reports = append(reports, report)
+	}
+
+	comparison = metadata.CompareReports(reports[0], reports[1], significance)
+	comparison.Base, comparison.Head = base, head
+	return comparison, nil
+}
@@ -15,12 +15,22 @@
 package command
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/labd/controlapi"
+	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/cliutil"
 	"github.com/Netflix/p2plab/printer"
 	"github.com/Netflix/p2plab/query"
+	"github.com/hako/durafmt"
+	"github.com/olekukonko/tablewriter"
 	"github.com/rs/zerolog"
 	"github.com/urfave/cli"
 )
@@ -34,9 +44,9 @@ var clusterCommand = cli.Command{
 			Name:      "create",
 			Aliases:   []string{"c"},
 			Usage:     "Creates a new cluster.",
-			ArgsUsage: "<name>",
+			ArgsUsage: "[name]",
 			Action:    createClusterAction,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:  "definition,d",
 					Usage: "Create cluster from a cluster definition.",
@@ -56,7 +66,39 @@ var clusterCommand = cli.Command{
 					Usage: "AWS Region to deploy to.",
 					Value: "us-west-2",
 				},
-			},
+				&cli.StringFlag{
+					Name:  "image",
+					Usage: "Base image to launch nodes with, defaults to the provider's labagent image.",
+				},
+				&cli.IntFlag{
+					Name:  "retries",
+					Usage: "Number of times to replace nodes that fail to provision.",
+				},
+				&cli.Float64Flag{
+					Name:  "tolerance",
+					Usage: "Fraction of nodes that may fail to provision, creating a degraded cluster instead of failing.",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Validates the cluster and prints the resources that would be created without provisioning anything.",
+				},
+				&cli.BoolFlag{
+					Name:  "pool",
+					Usage: "Checks out a warm cluster with the same definition from the pool if one is available. With a name, only the pooled cluster of that name is checked out, otherwise the name is optional.",
+				},
+			}, waitFlags(waitStarted, "healthy")...),
+		},
+		{
+			Name:      "cost",
+			Usage:     "Displays the estimated and accrued cost of all clusters.",
+			ArgsUsage: " ",
+			Action:    costClustersAction,
+		},
+		{
+			Name:      "diff",
+			Usage:     "Shows how a cluster definition file differs from a cluster's definition.",
+			ArgsUsage: "<name> <definition>",
+			Action:    diffClusterAction,
 		},
 		{
 			Name:      "inspect",
@@ -69,9 +111,17 @@ var clusterCommand = cli.Command{
 			Name:      "label",
 			Aliases:   []string{"l"},
 			Usage:     "Add or remove labels from clusters.",
-			ArgsUsage: " ",
+			ArgsUsage: "[name...]",
 			Action:    labelClustersAction,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "query,q",
+					Usage: "Runs a query to label the matching clusters.",
+				},
+				&cli.StringFlag{
+					Name:  "from-file",
+					Usage: "Labels the clusters named in a file, one per line, or - for stdin.",
+				},
 				&cli.StringSliceFlag{
 					Name:  "add",
 					Usage: "Adds a label.",
@@ -88,25 +138,59 @@ var clusterCommand = cli.Command{
 			Usage:     "List clusters.",
 			ArgsUsage: " ",
 			Action:    listClusterAction,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:  "query,q",
 					Usage: "Runs a query to filter the listed clusters.",
 				},
-			},
+			}, watchFlags...),
+		},
+		{
+			Name:      "pause",
+			ArgsUsage: "[<name> ...]",
+			Usage:     "Stop the instances of clusters without destroying them.",
+			Action:    pauseClustersAction,
+		},
+		{
+			Name:      "resume",
+			ArgsUsage: "[<name> ...]",
+			Usage:     "Start the instances of paused clusters.",
+			Action:    resumeClustersAction,
+		},
+		{
+			Name:      "return",
+			ArgsUsage: "[<name> ...]",
+			Usage:     "Return clusters to the pool instead of destroying them.",
+			Action:    returnClustersAction,
 		},
 		{
 			Name:      "remove",
 			ArgsUsage: "[<name> ...]",
-			Aliases:   []string{"rm"},
+			Aliases:   []string{"rm", "destroy"},
 			Usage:     "Remove clusters.",
 			Action:    removeClustersAction,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "all",
+					Usage: "Removes every cluster, after listing them and their cost for confirmation.",
+				},
+				&cli.StringFlag{
+					Name:  "older-than",
+					Usage: "Removes only the clusters created longer ago than a duration, such as 2d or 12h. Requires --all.",
+				},
+				&cli.BoolFlag{
+					Name:  "force",
+					Usage: "Removes the clusters without confirmation.",
+				},
+			},
 		},
 	},
 }
 
 func createClusterAction(c *cli.Context) error {
-	if c.NArg() != 1 {
+	// Without a name, any pooled cluster with the same definition may be
+	// checked out, but there is nothing to name a new cluster.
+	if c.NArg() > 1 || (c.NArg() == 0 && !c.Bool("pool")) {
 		return errors.New("cluster name must be provided")
 	}
 
@@ -131,21 +215,84 @@ func createClusterAction(c *cli.Context) error {
 			p2plab.WithClusterSize(c.Int("size")),
 			p2plab.WithClusterInstanceType(c.String("instance-type")),
 			p2plab.WithClusterRegion(c.String("region")),
+			p2plab.WithClusterImage(c.String("image")),
 		)
 	}
 
-	name := c.Args().First()
-	id, err := control.Cluster().Create(ctx, name, options...)
+	options = append(options,
+		p2plab.WithClusterRetries(c.Int("retries")),
+		p2plab.WithClusterTolerance(c.Float64("tolerance")),
+	)
+
+	if c.Bool("dry-run") {
+		p, err := CommandPrinter(c, printer.OutputJSON)
+		if err != nil {
+			return err
+		}
+
+		plan, err := control.Cluster().Plan(ctx, c.Args().First(), options...)
+		if err != nil {
+			return err
+		}
+
+		return p.Print(plan)
+	}
+
+	condition, err := waitCondition(c, waitStarted, "healthy")
 	if err != nil {
 		return err
 	}
 
+	var (
+		id        string
+		completed = true
+	)
+	if c.Bool("pool") {
+		id, err = control.Cluster().Checkout(ctx, c.Args().First(), options...)
+		if err != nil {
+			return withExitCode(ExitProvisioning, err)
+		}
+	}
+
+	if id != "" {
+		zerolog.Ctx(ctx).Info().Msgf("Checked out cluster %q from pool", id)
+	} else if c.NArg() == 0 {
+		return errors.New("no pooled cluster matches the definition, a name must be provided to create one")
+	} else {
+		reporter, err := newProgressReporter(c, clusterProgress(control))
+		if err != nil {
+			return err
+		}
+		defer reporter.stop()
+
+		// A cluster is healthy once labd has finished creating it.
+		name := c.Args().First()
+		id, completed, err = waitFor(c, condition, func(ctx context.Context, started func(id string)) (string, error) {
+			id, err := control.Cluster().Create(ctx, name, append(options,
+				p2plab.WithClusterDetach(),
+				p2plab.WithClusterStarted(func(id string) {
+					reporter.start(id)
+					started(id)
+				}),
+			)...)
+			return id, withExitCode(ExitProvisioning, err)
+		}, nil)
+		if err != nil {
+			return err
+		}
+		reporter.stop()
+	}
+
 	cluster, err := control.Cluster().Get(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	zerolog.Ctx(ctx).Info().Msgf("Created cluster %q", cluster.Metadata().ID)
+	if completed {
+		zerolog.Ctx(ctx).Info().Msgf("Created cluster %q", cluster.Metadata().ID)
+	} else {
+		zerolog.Ctx(ctx).Info().Msgf("Creating cluster %q", cluster.Metadata().ID)
+	}
 	return p.Print(cluster.Metadata())
 }
 
@@ -174,12 +321,74 @@ func inspectClusterAction(c *cli.Context) error {
 	return p.Print(cluster.Metadata())
 }
 
+func diffClusterAction(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("cluster name and definition must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	cdef, err := controlapi.ReadClusterDefinition(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	cluster, err := control.Cluster().Get(ctx, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	changes, err := metadata.DiffDefinitions(cluster.Metadata().Definition, cdef)
+	if err != nil {
+		return err
+	}
+
+	return printChanges(ctx, p, changes)
+}
+
+func costClustersAction(c *cli.Context) error {
+	p, err := CommandPrinter(c, printer.OutputJSON)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	report, err := control.Cluster().Cost(ctx)
+	if err != nil {
+		return err
+	}
+
+	return p.Print(report)
+}
+
 func labelClustersAction(c *cli.Context) error {
 	var names []string
 	for i := 0; i < c.NArg(); i++ {
 		names = append(names, c.Args().Get(i))
 	}
 
+	if c.IsSet("from-file") {
+		ids, err := readIDs(c.String("from-file"))
+		if err != nil {
+			return err
+		}
+		names = append(names, ids...)
+	}
+
 	p, err := CommandPrinter(c, printer.OutputTable)
 	if err != nil {
 		return err
@@ -190,8 +399,20 @@ func labelClustersAction(c *cli.Context) error {
 		return err
 	}
 
+	var opts []p2plab.ListOption
 	ctx := cliutil.CommandContext(c)
-	cs, err := control.Cluster().Label(ctx, names, c.StringSlice("add"), c.StringSlice("remove"))
+	if c.IsSet("query") {
+		q, err := query.Parse(ctx, c.String("query"))
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, p2plab.WithQuery(q.String()))
+	} else if len(names) == 0 {
+		return errors.New("cluster names or a query must be provided")
+	}
+
+	cs, err := control.Cluster().Label(ctx, names, c.StringSlice("add"), c.StringSlice("remove"), opts...)
 	if err != nil {
 		return err
 	}
@@ -226,20 +447,175 @@ func listClusterAction(c *cli.Context) error {
 		opts = append(opts, p2plab.WithQuery(q.String()))
 	}
 
-	cs, err := control.Cluster().List(ctx, opts...)
+	return printList(c, p, func(ctx context.Context) ([]interface{}, error) {
+		cs, err := control.Cluster().List(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		l := make([]interface{}, len(cs))
+		for i, c := range cs {
+			l[i] = c.Metadata()
+		}
+		return l, nil
+	})
+}
+
+func removeClustersAction(c *cli.Context) error {
+	var names []string
+	for i := 0; i < c.NArg(); i++ {
+		names = append(names, c.Args().Get(i))
+	}
+
+	if c.Bool("all") && len(names) > 0 {
+		return errors.New("cluster names cannot be provided with --all")
+	}
+	if c.IsSet("older-than") && !c.Bool("all") {
+		return errors.New("--older-than can only be used with --all")
+	}
+
+	control, err := ResolveControl(c)
 	if err != nil {
 		return err
 	}
 
-	l := make([]interface{}, len(cs))
-	for i, c := range cs {
-		l[i] = c.Metadata()
+	ctx := cliutil.CommandContext(c)
+	if c.Bool("all") {
+		var olderThan time.Duration
+		if c.IsSet("older-than") {
+			olderThan, err = parseAge(c.String("older-than"))
+			if err != nil {
+				return err
+			}
+		}
+
+		clusters, err := removableClusters(ctx, control, olderThan)
+		if err != nil {
+			return err
+		}
+
+		if len(clusters) == 0 {
+			zerolog.Ctx(ctx).Info().Msg("No clusters to remove")
+			return nil
+		}
+
+		if !c.Bool("force") {
+			err = confirmRemoveClusters(clusters)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, cluster := range clusters {
+			names = append(names, cluster.ID)
+		}
 	}
 
-	return p.Print(l)
+	err = control.Cluster().Remove(ctx, names...)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
-func removeClustersAction(c *cli.Context) error {
+// removableClusters returns the clusters created longer ago than olderThan,
+// including those whose destruction is stuck so that it is retried. Clusters
+// owned by the pool or running a benchmark are skipped and logged, as they
+// must be drained or destroyed by name.
+func removableClusters(ctx context.Context, control p2plab.ControlAPI, olderThan time.Duration) ([]metadata.Cluster, error) {
+	cs, err := control.Cluster().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := control.Benchmark().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inUse := make(map[string]string)
+	for _, b := range bs {
+		m := b.Metadata()
+		switch m.Status {
+		case metadata.BenchmarkPlanning, metadata.BenchmarkRunning:
+			inUse[m.Cluster.ID] = m.ID
+		}
+	}
+
+	var clusters []metadata.Cluster
+	for _, c := range cs {
+		m := c.Metadata()
+		if m.Status == metadata.ClusterDestroyed {
+			continue
+		}
+		if time.Since(m.CreatedAt) < olderThan {
+			continue
+		}
+
+		logger := zerolog.Ctx(ctx).With().Str("cluster", m.ID).Logger()
+		if m.Status == metadata.ClusterPooled {
+			logger.Info().Msg("Skipping cluster owned by the pool")
+			continue
+		}
+		if id, ok := inUse[m.ID]; ok {
+			logger.Info().Str("benchmark", id).Msg("Skipping cluster running a benchmark")
+			continue
+		}
+
+		clusters = append(clusters, m)
+	}
+	return clusters, nil
+}
+
+// confirmRemoveClusters lists the clusters to remove with the hourly cost
+// their removal frees, and asks for confirmation on stdin. Paused clusters
+// free nothing as they don't accrue cost. Without a terminal to ask on, the
+// removal is refused so that scripts must pass --force.
+func confirmRemoveClusters(clusters []metadata.Cluster) error {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("refusing to remove %d clusters without confirmation, use --force to remove them non-interactively", len(clusters))
+	}
+
+	var freed float64
+	table := tablewriter.NewWriter(os.Stderr)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"ID", "STATUS", "AGE", "HOURLY"})
+	for _, cluster := range clusters {
+		hourly := cluster.Cost.Hourly
+		if cluster.Status == metadata.ClusterPaused {
+			hourly = 0
+		}
+		freed += hourly
+
+		table.Append([]string{
+			cluster.ID,
+			string(cluster.Status),
+			durafmt.Parse(time.Since(cluster.CreatedAt).Round(time.Minute)).String(),
+			fmt.Sprintf("$%.4f", hourly),
+		})
+	}
+	table.Render()
+
+	fmt.Fprintf(os.Stderr, "Removing %d clusters frees an estimated $%.4f per hour. Continue? [y/N] ", len(clusters), freed)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errors.New("removal of clusters was not confirmed")
+	}
+}
+
+func pauseClustersAction(c *cli.Context) error {
 	var names []string
 	for i := 0; i < c.NArg(); i++ {
 		names = append(names, c.Args().Get(i))
@@ -251,10 +627,35 @@ func removeClustersAction(c *cli.Context) error {
 	}
 
 	ctx := cliutil.CommandContext(c)
-	err = control.Cluster().Remove(ctx, names...)
+	return control.Cluster().Pause(ctx, names...)
+}
+
+func resumeClustersAction(c *cli.Context) error {
+	var names []string
+	for i := 0; i < c.NArg(); i++ {
+		names = append(names, c.Args().Get(i))
+	}
+
+	control, err := ResolveControl(c)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	ctx := cliutil.CommandContext(c)
+	return control.Cluster().Resume(ctx, names...)
+}
+
+func returnClustersAction(c *cli.Context) error {
+	var names []string
+	for i := 0; i < c.NArg(); i++ {
+		names = append(names, c.Args().Get(i))
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	return control.Cluster().Return(ctx, names...)
 }
@@ -15,13 +15,17 @@
 package command
 
 import (
+	"bufio"
 	"context"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/cliutil"
 	"github.com/Netflix/p2plab/pkg/httputil"
 	"github.com/Netflix/p2plab/pkg/logutil"
@@ -60,28 +64,36 @@ func AttachAppContext(ctx context.Context, app *cli.App) {
 		return nil
 	}
 
+	withContext := func(name string, before cli.BeforeFunc) cli.BeforeFunc {
+		return func(c *cli.Context) error {
+			if before != nil {
+				if err := before(c); err != nil {
+					return err
+				}
+			}
+
+			span = tracer.StartSpan(name)
+			span.SetTag("command", strings.Join(os.Args, " "))
+
+			ctx = logger.WithContext(ctx)
+			ctx = logutil.WithLogWriter(ctx, writer)
+			ctx = opentracing.ContextWithSpan(ctx, span)
+
+			c.App.Metadata["context"] = ctx
+			return nil
+		}
+	}
+
 	for i, cmd := range app.Commands {
+		// Commands without subcommands, such as dashboard, run on their own.
+		if len(cmd.Subcommands) == 0 {
+			app.Commands[i].Before = withContext(cmd.Name, cmd.Before)
+			continue
+		}
+
 		for j, subcmd := range cmd.Subcommands {
-			func(before cli.BeforeFunc) {
-				name := strings.Join([]string{cmd.Name, subcmd.Name}, " ")
-				app.Commands[i].Subcommands[j].Before = func(c *cli.Context) error {
-					if before != nil {
-						if err := before(c); err != nil {
-							return err
-						}
-					}
-
-					span = tracer.StartSpan(name)
-					span.SetTag("command", strings.Join(os.Args, " "))
-
-					ctx = logger.WithContext(ctx)
-					ctx = logutil.WithLogWriter(ctx, writer)
-					ctx = opentracing.ContextWithSpan(ctx, span)
-
-					c.App.Metadata["context"] = ctx
-					return nil
-				}
-			}(subcmd.Before)
+			name := strings.Join([]string{cmd.Name, subcmd.Name}, " ")
+			app.Commands[i].Subcommands[j].Before = withContext(name, subcmd.Before)
 		}
 	}
 
@@ -102,14 +114,9 @@ func AttachAppContext(ctx context.Context, app *cli.App) {
 
 func AttachAppClient(app *cli.App) {
 	app.Before = cliutil.JoinBefore(app.Before, func(c *cli.Context) error {
-		var opts []httputil.ClientOption
-		if c.GlobalString("log-level") == "debug" {
-			logger, _, err := newLogger(c)
-			if err != nil {
-				return err
-			}
-
-			opts = append(opts, httputil.WithLogger(logger))
+		opts, err := clientOptions(c)
+		if err != nil {
+			return err
 		}
 
 		client, err := httputil.NewClient(httputil.NewHTTPClient(), opts...)
@@ -122,7 +129,25 @@ func AttachAppClient(app *cli.App) {
 	})
 }
 
+func clientOptions(c *cli.Context) ([]httputil.ClientOption, error) {
+	var opts []httputil.ClientOption
+	if c.GlobalString("log-level") == "debug" {
+		logger, _, err := newLogger(c)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, httputil.WithLogger(logger))
+	}
+	return opts, nil
+}
+
+// CommandPrinter returns the printer of the output flag, or the ID printer
+// when quiet so that only the IDs of resources are printed.
 func CommandPrinter(c *cli.Context, auto printer.OutputType) (printer.Printer, error) {
+	if c.GlobalBool("quiet") {
+		return printer.GetPrinter(printer.OutputID, auto)
+	}
 	return printer.GetPrinter(printer.OutputType(c.GlobalString("output")), auto)
 }
 
@@ -145,6 +170,9 @@ func newLogger(c *cli.Context) (*zerolog.Logger, io.Writer, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	if c.GlobalBool("quiet") && level < zerolog.ErrorLevel {
+		level = zerolog.ErrorLevel
+	}
 
 	logger := zerolog.New(out).
 		Level(level).
@@ -156,3 +184,66 @@ func newLogger(c *cli.Context) (*zerolog.Logger, io.Writer, error) {
 func ExtractNameFromFilename(filename string) string {
 	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
 }
+
+// readIDs returns the IDs listed in a file, one per line, skipping blank
+// lines and comments starting with #. A filename of "-" reads stdin.
+func readIDs(filename string) ([]string, error) {
+	r := os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		id := strings.TrimSpace(scanner.Text())
+		if id == "" || strings.HasPrefix(id, "#") {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read ids from %q", filename)
+	}
+	return ids, nil
+}
+
+// printChanges prints the changes between two definitions, or logs that
+// there are none.
+func printChanges(ctx context.Context, p printer.Printer, changes []metadata.FieldChange) error {
+	if len(changes) == 0 {
+		zerolog.Ctx(ctx).Info().Msg("No changes")
+		return nil
+	}
+
+	l := make([]interface{}, len(changes))
+	for i, change := range changes {
+		l[i] = change
+	}
+	return p.Print(l)
+}
+
+// parseAge parses a duration like time.ParseDuration, but also accepts a
+// number of days such as "2d", as ages are usually much longer than hours.
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil || days < 0 {
+			return 0, errors.Wrapf(errdefs.ErrInvalidArgument, "invalid age %q", s)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.Wrapf(errdefs.ErrInvalidArgument, "invalid age %q", s)
+	}
+	return d, nil
+}
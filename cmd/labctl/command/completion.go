@@ -0,0 +1,226 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var completionCommand = cli.Command{
+	Name:      "completion",
+	Usage:     "Prints a shell completion script that completes resource names from labd.",
+	ArgsUsage: "<bash|zsh|fish>",
+	Action:    completionAction,
+}
+
+// completionScripts complete labctl by running it with
+// --generate-bash-completion, which lists the subcommands or resource names
+// that may come next.
+var completionScripts = map[string]string{
+	"bash": `_labctl_complete() {
+  local cur opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  opts=$(${COMP_WORDS[@]:0:$COMP_CWORD} --generate-bash-completion 2>/dev/null)
+  COMPREPLY=($(compgen -W "${opts}" -- ${cur}))
+  return 0
+}
+complete -o bashdefault -o default -F _labctl_complete labctl
+`,
+	"zsh": `#compdef labctl
+_labctl_complete() {
+  local -a opts
+  opts=("${(@f)$(${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  _describe 'values' opts
+}
+compdef _labctl_complete labctl
+`,
+	"fish": `function __labctl_complete
+  eval (commandline -opc) --generate-bash-completion 2>/dev/null
+end
+complete -c labctl -f -a '(__labctl_complete)'
+`,
+}
+
+func completionAction(c *cli.Context) error {
+	script, ok := completionScripts[c.Args().First()]
+	if !ok {
+		return errors.New("shell must be one of bash, zsh or fish")
+	}
+
+	fmt.Print(script)
+	return nil
+}
+
+// completionTimeout bounds how long completing a resource name waits for
+// labd, so that an unreachable labd doesn't hang the shell.
+const completionTimeout = 2 * time.Second
+
+// placeholderKinds are the kinds of resources named by the placeholders of a
+// command's ArgsUsage. The "name" and "id" placeholders name a resource of
+// the kind of the command's parent, such as "cluster inspect <name>".
+var placeholderKinds = map[string]string{
+	"cluster":   "cluster",
+	"scenario":  "scenario",
+	"benchmark": "benchmark",
+	"base":      "benchmark",
+	"head":      "benchmark",
+}
+
+// AttachAppCompletion enables shell completion, completing the arguments of
+// each subcommand with the names of the resources they expect.
+func AttachAppCompletion(app *cli.App) {
+	app.EnableBashCompletion = true
+
+	for i, cmd := range app.Commands {
+		for j, subcmd := range cmd.Subcommands {
+			kinds, variadic := argumentKinds(cmd.Name, subcmd.ArgsUsage)
+			if strings.Join(kinds, "") == "" {
+				continue
+			}
+			app.Commands[i].Subcommands[j].BashComplete = completeResources(kinds, variadic)
+		}
+	}
+}
+
+// argumentKinds returns the kind of resource of each argument in a command's
+// ArgsUsage, or an empty kind for arguments that aren't resources, and
+// whether its last argument may be repeated.
+func argumentKinds(parent, usage string) (kinds []string, variadic bool) {
+	for _, field := range strings.Fields(usage) {
+		if strings.Contains(field, "...") {
+			variadic = true
+			if strings.Trim(field, ".]") == "" {
+				continue
+			}
+		}
+
+		name := strings.Trim(field, "[]<>.")
+		kind, ok := placeholderKinds[name]
+		if !ok && (name == "name" || name == "id") {
+			kind = parent
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, variadic
+}
+
+func completeResources(kinds []string, variadic bool) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		n := c.NArg()
+		if n >= len(kinds) {
+			if !variadic {
+				return
+			}
+			n = len(kinds) - 1
+		}
+
+		control, err := ResolveControl(c)
+		if err != nil {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+		defer cancel()
+
+		// Errors are ignored so that completion falls back to the shell's
+		// default.
+		names, _ := listResourceNames(ctx, control, kinds[n], c.Args().First())
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	}
+}
+
+// listResourceNames returns the names of the resources of a kind. Nodes are
+// listed from the cluster given as the command's first argument, and the
+// contexts of config commands are saved locally.
+func listResourceNames(ctx context.Context, control p2plab.ControlAPI, kind, cluster string) ([]string, error) {
+	var names []string
+	switch kind {
+	case "cluster":
+		cs, err := control.Cluster().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cs {
+			names = append(names, c.ID())
+		}
+	case "node":
+		ns, err := control.Node().List(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range ns {
+			names = append(names, n.ID())
+		}
+	case "scenario":
+		ss, err := control.Scenario().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			names = append(names, s.ID())
+		}
+	case "benchmark":
+		bs, err := control.Benchmark().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bs {
+			names = append(names, b.ID())
+		}
+	case "experiment":
+		es, err := control.Experiment().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range es {
+			names = append(names, e.ID())
+		}
+	case "schedule":
+		ss, err := control.Schedule().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			names = append(names, s.Metadata().ID)
+		}
+	case "config":
+		lcs, err := ListContexts()
+		if err != nil {
+			return nil, err
+		}
+		for _, lc := range lcs {
+			names = append(names, lc.Name)
+		}
+	case "build":
+		bs, err := control.Build().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bs {
+			names = append(names, b.ID())
+		}
+	}
+	return names, nil
+}
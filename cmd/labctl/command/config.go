@@ -0,0 +1,141 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/printer"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli"
+)
+
+var configCommand = cli.Command{
+	Name:  "config",
+	Usage: "Manage the labd endpoints that labctl sends commands to.",
+	Subcommands: []cli.Command{
+		{
+			Name:      "set-context",
+			Usage:     "Saves the address and credentials of a labd endpoint as a context.",
+			ArgsUsage: "<name>",
+			Action:    setContextAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "address",
+					Usage: "Address of labd.",
+				},
+				&cli.StringFlag{
+					Name:  "token",
+					Usage: "Bearer token sent to labd, for labs behind an authenticating proxy.",
+				},
+			},
+		},
+		{
+			Name:      "use-context",
+			Usage:     "Sends later commands to the labd endpoint of a context.",
+			ArgsUsage: "<name>",
+			Action:    useContextAction,
+		},
+		{
+			Name:      "get-contexts",
+			Aliases:   []string{"ls"},
+			Usage:     "Lists the saved contexts.",
+			ArgsUsage: " ",
+			Action:    getContextsAction,
+		},
+		{
+			Name:      "delete-context",
+			Aliases:   []string{"rm"},
+			Usage:     "Removes a saved context.",
+			ArgsUsage: "<name>",
+			Action:    deleteContextAction,
+		},
+	},
+}
+
+func setContextAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("context name must be provided")
+	}
+
+	lc := metadata.LabContext{
+		Name:    c.Args().First(),
+		Address: c.String("address"),
+		Token:   c.String("token"),
+	}
+	err := SaveContext(lc)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	zerolog.Ctx(ctx).Info().Msgf("Saved context %q", lc.Name)
+	return nil
+}
+
+func useContextAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("context name must be provided")
+	}
+
+	name := c.Args().First()
+	err := UseContext(name)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	zerolog.Ctx(ctx).Info().Msgf("Switched to context %q", name)
+	return nil
+}
+
+func getContextsAction(c *cli.Context) error {
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	contexts, err := ListContexts()
+	if err != nil {
+		return err
+	}
+
+	// Tokens are never printed, only whether a context has one.
+	l := make([]interface{}, len(contexts))
+	for i, lc := range contexts {
+		if lc.Token != "" {
+			lc.Token = "redacted"
+		}
+		l[i] = lc
+	}
+	return p.Print(l)
+}
+
+func deleteContextAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("context name must be provided")
+	}
+
+	name := c.Args().First()
+	err := RemoveContext(name)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	zerolog.Ctx(ctx).Info().Msgf("Removed context %q", name)
+	return nil
+}
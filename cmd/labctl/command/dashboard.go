@@ -0,0 +1,149 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/printer"
+	"github.com/urfave/cli"
+)
+
+var dashboardCommand = cli.Command{
+	Name:    "dashboard",
+	Aliases: []string{"dash"},
+	Usage:   "Displays the health of clusters, running benchmarks and recent results, refreshing live.",
+	Action:  dashboardAction,
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "How often the dashboard is refreshed.",
+			Value: 5 * time.Second,
+		},
+		&cli.IntFlag{
+			Name:  "recent",
+			Usage: "Number of recently completed benchmarks to display.",
+			Value: 5,
+		},
+		&cli.BoolFlag{
+			Name:  "once",
+			Usage: "Displays the dashboard once instead of refreshing it.",
+		},
+	},
+}
+
+func dashboardAction(c *cli.Context) error {
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	for {
+		dashboard, err := collectDashboard(ctx, control, c.Int("recent"))
+		if err != nil {
+			return err
+		}
+
+		if !c.Bool("once") {
+			// Clear the terminal so that the dashboard is redrawn in place.
+			fmt.Print("\033[H\033[2J")
+		}
+
+		err = p.Print(dashboard)
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("once") {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.Duration("interval")):
+		}
+	}
+}
+
+// collectDashboard takes a snapshot of the lab. Only clusters whose agents
+// are expected to be running are healthchecked.
+func collectDashboard(ctx context.Context, control p2plab.ControlAPI, recent int) (metadata.Dashboard, error) {
+	dashboard := metadata.Dashboard{
+		Health: make(map[string]metadata.ClusterHealth),
+	}
+
+	clusters, err := control.Cluster().List(ctx)
+	if err != nil {
+		return dashboard, err
+	}
+
+	for _, cluster := range clusters {
+		m := cluster.Metadata()
+		switch m.Status {
+		case metadata.ClusterDestroying, metadata.ClusterDestroyed:
+			continue
+		case metadata.ClusterCreated, metadata.ClusterDegraded, metadata.ClusterPooled:
+			health, err := control.Cluster().Health(ctx, m.ID)
+			if err != nil {
+				return dashboard, err
+			}
+			dashboard.Health[m.ID] = health
+		}
+		dashboard.Clusters = append(dashboard.Clusters, m)
+	}
+
+	benchmarks, err := control.Benchmark().List(ctx)
+	if err != nil {
+		return dashboard, err
+	}
+
+	var completed []metadata.Benchmark
+	for _, benchmark := range benchmarks {
+		m := benchmark.Metadata()
+		switch m.Status {
+		case metadata.BenchmarkPlanning, metadata.BenchmarkRunning:
+			progress, err := control.Benchmark().Status(ctx, m.ID)
+			if err != nil {
+				return dashboard, err
+			}
+			dashboard.Running = append(dashboard.Running, progress)
+		default:
+			completed = append(completed, m)
+		}
+	}
+
+	sort.SliceStable(completed, func(i, j int) bool {
+		return completed[i].UpdatedAt.After(completed[j].UpdatedAt)
+	})
+	if len(completed) > recent {
+		completed = completed[:recent]
+	}
+	dashboard.Recent = completed
+
+	return dashboard, nil
+}
@@ -56,6 +56,16 @@ var debugCommand = cli.Command{
 					Usage: "address for labapp's HTTP server",
 					Value: "http://localhost:7003",
 				},
+				&cli.StringFlag{
+					Name:  "pin-type",
+					Usage: "type of pin for pin tasks",
+					Value: string(metadata.PinRecursive),
+				},
+				&cli.IntFlag{
+					Name:  "depth",
+					Usage: "depth of links to pin for recursive pin tasks, negative for unlimited",
+					Value: -1,
+				},
 			},
 		},
 	},
@@ -96,6 +106,8 @@ func runTaskAction(c *cli.Context) error {
 	err = app.Run(ctx, metadata.Task{
 		Type:    metadata.TaskType(c.Args().Get(0)),
 		Subject: c.Args().Get(1),
+		PinType: metadata.PinType(c.String("pin-type")),
+		Depth:   c.Int("depth"),
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,85 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"github.com/Netflix/p2plab/errdefs"
+)
+
+// Exit codes of labctl, so that CI pipelines can tell failures apart.
+const (
+	// ExitError is any failure not covered by a more specific exit code.
+	ExitError = 1
+
+	// ExitValidation is an invalid definition or argument.
+	ExitValidation = 2
+
+	// ExitProvisioning is a cluster that failed to be created.
+	ExitProvisioning = 3
+
+	// ExitExpectations is a benchmark that completed without meeting the
+	// expectations of its scenario.
+	ExitExpectations = 4
+)
+
+// exitError is an error that labctl exits with a specific code for.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Cause() error {
+	return e.err
+}
+
+// withExitCode returns an error that labctl exits with code for, unless err
+// is nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code, err}
+}
+
+// ExitCode returns the code labctl should exit with for an error returned by
+// a command.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	// Invalid definitions and arguments are validation failures wherever they
+	// are found.
+	if errdefs.IsInvalidArgument(err) {
+		return ExitValidation
+	}
+
+	for err != nil {
+		if e, ok := err.(*exitError); ok {
+			return e.code
+		}
+
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return ExitError
+}
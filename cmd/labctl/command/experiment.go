@@ -16,6 +16,7 @@ package command
 
 import (
 	"errors"
+	"io/ioutil"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/experiments"
@@ -42,6 +43,14 @@ var experimentCommand = cli.Command{
 					Name:  "name",
 					Usage: "Name of the experiment, by default takes the name of the experiment definition.",
 				},
+				&cli.StringFlag{
+					Name:  "template",
+					Usage: "Scenario definition file executed as a template with the variables of each trial.",
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Usage: "Maximum number of trials to run at once, overriding the experiment definition.",
+				},
 			},
 		},
 		{
@@ -81,6 +90,28 @@ var experimentCommand = cli.Command{
 				},
 			},
 		},
+		{
+			Name:      "report",
+			Aliases:   []string{"r"},
+			Usage:     "Summarizes a metric of an experiment's trials by the values of its variables.",
+			ArgsUsage: "<id>",
+			Action:    reportExperimentAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "metric,m",
+					Usage: "Metric to summarize, named as in expectations.",
+					Value: "totalTime",
+				},
+				&cli.StringFlag{
+					Name:  "rows",
+					Usage: "Variable whose values are the rows, by default the first variable.",
+				},
+				&cli.StringFlag{
+					Name:  "columns",
+					Usage: "Variable whose values are the columns, by default the second variable.",
+				},
+			},
+		},
 		{
 			Name:      "remove",
 			Aliases:   []string{"rm"},
@@ -112,6 +143,22 @@ func createExperimentAction(c *cli.Context) error {
 		return err
 	}
 
+	if c.IsSet("template") {
+		content, err := ioutil.ReadFile(c.String("template"))
+		if err != nil {
+			return err
+		}
+		edef.ScenarioTemplate = string(content)
+	}
+	if c.IsSet("concurrency") {
+		edef.Concurrency = c.Int("concurrency")
+	}
+
+	err = edef.Validate()
+	if err != nil {
+		return err
+	}
+
 	control, err := ResolveControl(c)
 	if err != nil {
 		return err
@@ -152,6 +199,48 @@ func inspectExperimentAction(c *cli.Context) error {
 	return p.Print(experiment.Metadata())
 }
 
+func reportExperimentAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("experiment id must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	report, err := control.Experiment().Report(ctx, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if c.GlobalString("output") == string(printer.OutputJSON) {
+		return p.Print(report)
+	}
+
+	rows, columns := c.String("rows"), c.String("columns")
+	for _, name := range report.Variables {
+		if rows == "" && name != columns {
+			rows = name
+		} else if columns == "" && !c.IsSet("columns") && name != rows {
+			columns = name
+		}
+	}
+
+	pivot, err := report.Pivot(c.String("metric"), rows, columns)
+	if err != nil {
+		return err
+	}
+
+	return p.Print(pivot)
+}
+
 func labelExperimentsAction(c *cli.Context) error {
 	p, err := CommandPrinter(c, printer.OutputTable)
 	if err != nil {
@@ -0,0 +1,178 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// contextsPath returns the path of the file where lab contexts are saved,
+// which is either LABCTL_CONTEXTS or contexts.json in labctl's user config
+// directory.
+func contextsPath() (string, error) {
+	path := os.Getenv("LABCTL_CONTEXTS")
+	if path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "labctl", "contexts.json"), nil
+}
+
+func readContexts() (map[string]metadata.LabContext, error) {
+	path, err := contextsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := make(map[string]metadata.LabContext)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return contexts, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &contexts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse contexts %q", path)
+	}
+	return contexts, nil
+}
+
+// writeContexts saves the contexts readable only by the user, since they may
+// have tokens.
+func writeContexts(contexts map[string]metadata.LabContext) error {
+	path, err := contextsPath()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(contexts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// GetContext returns a saved context, or the current context if name is
+// empty. If there is no current context, an empty context is returned.
+func GetContext(name string) (metadata.LabContext, error) {
+	contexts, err := readContexts()
+	if err != nil {
+		return metadata.LabContext{}, err
+	}
+
+	if name == "" {
+		for _, lc := range contexts {
+			if lc.Current {
+				return lc, nil
+			}
+		}
+		return metadata.LabContext{}, nil
+	}
+
+	lc, ok := contexts[name]
+	if !ok {
+		return metadata.LabContext{}, errors.Wrapf(errdefs.ErrNotFound, "context %q", name)
+	}
+	return lc, nil
+}
+
+// SaveContext saves a context, replacing any context with the same name but
+// keeping whether it is current.
+func SaveContext(lc metadata.LabContext) error {
+	if lc.Name == "" {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "context must have a name")
+	}
+	if lc.Address == "" {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "context must have an address")
+	}
+
+	contexts, err := readContexts()
+	if err != nil {
+		return err
+	}
+
+	lc.Current = contexts[lc.Name].Current
+	contexts[lc.Name] = lc
+	return writeContexts(contexts)
+}
+
+// UseContext makes a saved context the current context.
+func UseContext(name string) error {
+	contexts, err := readContexts()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := contexts[name]; !ok {
+		return errors.Wrapf(errdefs.ErrNotFound, "context %q", name)
+	}
+
+	for n, lc := range contexts {
+		lc.Current = n == name
+		contexts[n] = lc
+	}
+	return writeContexts(contexts)
+}
+
+// RemoveContext removes a saved context.
+func RemoveContext(name string) error {
+	contexts, err := readContexts()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := contexts[name]; !ok {
+		return errors.Wrapf(errdefs.ErrNotFound, "context %q", name)
+	}
+	delete(contexts, name)
+	return writeContexts(contexts)
+}
+
+// ListContexts returns the saved contexts sorted by name.
+func ListContexts() ([]metadata.LabContext, error) {
+	contexts, err := readContexts()
+	if err != nil {
+		return nil, err
+	}
+
+	var l []metadata.LabContext
+	for _, lc := range contexts {
+		l = append(l, lc)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+	return l, nil
+}
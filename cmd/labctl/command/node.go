@@ -15,8 +15,13 @@
 package command
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/metadata"
@@ -24,6 +29,7 @@ import (
 	"github.com/Netflix/p2plab/printer"
 	"github.com/Netflix/p2plab/query"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/urfave/cli"
 )
 
@@ -43,9 +49,17 @@ var nodeCommand = cli.Command{
 			Name:      "label",
 			Aliases:   []string{"l"},
 			Usage:     "Add or remove labels from nodes.",
-			ArgsUsage: "<cluster>",
+			ArgsUsage: "<cluster> [id...]",
 			Action:    labelNodesAction,
 			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "query,q",
+					Usage: "Runs a query to label the matching nodes.",
+				},
+				cli.StringFlag{
+					Name:  "from-file",
+					Usage: "Labels the nodes listed in a file, one ID per line, or - for stdin.",
+				},
 				cli.StringSliceFlag{
 					Name:  "add",
 					Usage: "Adds a label.",
@@ -62,12 +76,12 @@ var nodeCommand = cli.Command{
 			Usage:     "List nodes.",
 			ArgsUsage: "<cluster>",
 			Action:    listNodeAction,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringFlag{
 					Name:  "query,q",
 					Usage: "Runs a query to filter the listed nodes.",
 				},
-			},
+			}, watchFlags...),
 		},
 		{
 			Name:      "update",
@@ -109,8 +123,46 @@ var nodeCommand = cli.Command{
 		{
 			Name:      "ssh",
 			Usage:     "SSH into a node.",
-			ArgsUsage: "<cluster> <id>",
+			ArgsUsage: "<cluster> [id]",
 			Action:    sshNodeAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "query,q",
+					Usage: "Runs a query that matches the node to SSH into.",
+				},
+			},
+		},
+		{
+			Name:      "logs",
+			Usage:     "Print the logs of nodes.",
+			ArgsUsage: "<cluster>",
+			Action:    logsNodeAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "query,q",
+					Usage: "Runs a query to filter the nodes whose logs are printed.",
+				},
+				cli.BoolFlag{
+					Name:  "follow,f",
+					Usage: "Keep printing lines as they are logged.",
+				},
+				cli.DurationFlag{
+					Name:  "since",
+					Usage: "Only print lines logged within a duration, such as 10m.",
+				},
+			},
+		},
+		{
+			Name:      "port-forward",
+			Usage:     "Forward a local port to a port on a node.",
+			ArgsUsage: "<cluster> [id] <[local:]remote>",
+			Action:    portForwardNodeAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "query,q",
+					Usage: "Runs a query that matches the node to forward to.",
+				},
+			},
 		},
 	},
 }
@@ -156,14 +208,34 @@ func labelNodesAction(c *cli.Context) error {
 		ids = append(ids, c.Args().Get(i))
 	}
 
+	if c.IsSet("from-file") {
+		fileIDs, err := readIDs(c.String("from-file"))
+		if err != nil {
+			return err
+		}
+		ids = append(ids, fileIDs...)
+	}
+
 	control, err := ResolveControl(c)
 	if err != nil {
 		return err
 	}
 
+	var opts []p2plab.ListOption
 	ctx := cliutil.CommandContext(c)
+	if c.IsSet("query") {
+		q, err := query.Parse(ctx, c.String("query"))
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, p2plab.WithQuery(q.String()))
+	} else if len(ids) == 0 {
+		return errors.New("node ids or a query must be provided")
+	}
+
 	cluster := c.Args().First()
-	nodes, err := control.Node().Label(ctx, cluster, ids, c.StringSlice("add"), c.StringSlice("remove"))
+	nodes, err := control.Node().Label(ctx, cluster, ids, c.StringSlice("add"), c.StringSlice("remove"), opts...)
 	if err != nil {
 		return err
 	}
@@ -277,21 +349,65 @@ func listNodeAction(c *cli.Context) error {
 	}
 
 	cluster := c.Args().First()
-	nodes, err := control.Node().List(ctx, cluster, opts...)
+	return printList(c, p, func(ctx context.Context) ([]interface{}, error) {
+		nodes, err := control.Node().List(ctx, cluster, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		l := make([]interface{}, len(nodes))
+		for i, n := range nodes {
+			l[i] = n.Metadata()
+		}
+		return l, nil
+	})
+}
+
+func logsNodeAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("cluster id must be provided")
+	}
+
+	control, err := ResolveControl(c)
 	if err != nil {
 		return err
 	}
 
-	l := make([]interface{}, len(nodes))
-	for i, n := range nodes {
-		l[i] = n.Metadata()
+	ctx := cliutil.CommandContext(c)
+	var opts []p2plab.LogsOption
+	if c.IsSet("query") {
+		q, err := query.Parse(ctx, c.String("query"))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, p2plab.WithLogsQuery(q.String()))
+	}
+	if c.IsSet("since") {
+		opts = append(opts, p2plab.WithLogsSince(time.Now().Add(-c.Duration("since"))))
+	}
+	if c.Bool("follow") {
+		opts = append(opts, p2plab.WithFollow())
 	}
 
-	return p.Print(l)
+	// Lines are prefixed by their node and source, padded so that the lines
+	// of different nodes line up.
+	var width int
+	return control.Node().Logs(ctx, c.Args().First(), func(line metadata.LogLine) error {
+		prefix := fmt.Sprintf("%s %s", line.Node, line.Source)
+		if len(prefix) > width {
+			width = len(prefix)
+		}
+		_, err := fmt.Fprintf(os.Stdout, "%-*s | %s\n", width, prefix, line.Text)
+		return err
+	}, opts...)
 }
 
 func sshNodeAction(c *cli.Context) error {
-	if c.NArg() != 2 {
+	if c.IsSet("query") {
+		if c.NArg() != 1 {
+			return errors.New("cluster id must be provided")
+		}
+	} else if c.NArg() != 2 {
 		return errors.New("cluster id and node id must be provided")
 	}
 
@@ -301,7 +417,12 @@ func sshNodeAction(c *cli.Context) error {
 	}
 
 	ctx := cliutil.CommandContext(c)
-	node, err := control.Node().Get(ctx, c.Args().Get(0), c.Args().Get(1))
+	var node p2plab.Node
+	if c.IsSet("query") {
+		node, err = matchNode(ctx, control, c.Args().First(), c.String("query"))
+	} else {
+		node, err = control.Node().Get(ctx, c.Args().Get(0), c.Args().Get(1))
+	}
 	if err != nil {
 		return err
 	}
@@ -313,3 +434,77 @@ func sshNodeAction(c *cli.Context) error {
 
 	return nil
 }
+
+func portForwardNodeAction(c *cli.Context) error {
+	if c.IsSet("query") {
+		if c.NArg() != 2 {
+			return errors.New("cluster id and ports must be provided")
+		}
+	} else if c.NArg() != 3 {
+		return errors.New("cluster id, node id and ports must be provided")
+	}
+
+	local, remote, err := parsePorts(c.Args().Get(c.NArg() - 1))
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	var node p2plab.Node
+	if c.IsSet("query") {
+		node, err = matchNode(ctx, control, c.Args().First(), c.String("query"))
+	} else {
+		node, err = control.Node().Get(ctx, c.Args().Get(0), c.Args().Get(1))
+	}
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("node", node.ID()).Int("local", local).Int("remote", remote).Msg("Forwarding port")
+	return node.SSH(ctx, p2plab.WithPortForward(local, remote))
+}
+
+// parsePorts parses a "local:remote" port pair, or a single port forwarded to
+// the same port locally.
+func parsePorts(ports string) (local, remote int, err error) {
+	parts := strings.SplitN(ports, ":", 2)
+	for i, part := range parts {
+		port, err := strconv.Atoi(part)
+		if err != nil || port <= 0 || port > 65535 {
+			return 0, 0, errors.Errorf("invalid port %q in %q", part, ports)
+		}
+		if i == 0 {
+			local = port
+		}
+		remote = port
+	}
+	return local, remote, nil
+}
+
+// matchNode returns the only node of a cluster that matches a query.
+func matchNode(ctx context.Context, control p2plab.ControlAPI, cluster, q string) (p2plab.Node, error) {
+	qry, err := query.Parse(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := control.Node().List(ctx, cluster, p2plab.WithQuery(qry.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes) != 1 {
+		var ids []string
+		for _, n := range nodes {
+			ids = append(ids, n.ID())
+		}
+		return nil, errors.Errorf("query must match exactly one node but matched %d: %s", len(nodes), strings.Join(ids, ", "))
+	}
+
+	return nodes[0], nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli"
+)
+
+// progressInterval is how often labd is polled for the progress of an
+// operation.
+const progressInterval = time.Second
+
+// progressFunc returns the progress of the operation on a resource.
+type progressFunc func(ctx context.Context, id string) (metadata.ProgressEvent, error)
+
+// progressReporter prints the progress of an operation as a line of JSON on
+// stdout whenever it changes, so that wrappers and CI systems can follow it
+// without parsing logs.
+type progressReporter struct {
+	ctx      context.Context
+	progress progressFunc
+	enc      *json.Encoder
+
+	mu     sync.Mutex
+	id     string
+	last   metadata.ProgressEvent
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newProgressReporter returns a reporter of the progress of an operation, or
+// nil if the progress flag does not ask for progress events. A nil reporter
+// reports nothing.
+func newProgressReporter(c *cli.Context, progress progressFunc) (*progressReporter, error) {
+	switch c.GlobalString("progress") {
+	case "", "none":
+		return nil, nil
+	case "json":
+	default:
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unknown progress format %q", c.GlobalString("progress"))
+	}
+
+	return &progressReporter{
+		ctx:      cliutil.CommandContext(c),
+		progress: progress,
+		enc:      json.NewEncoder(os.Stdout),
+	}, nil
+}
+
+// start starts polling the progress of the operation on a resource.
+func (r *progressReporter) start(id string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.id, r.cancel, r.done = id, cancel, make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			r.report(ctx, id)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stop stops polling and reports the progress the operation ended with. It
+// does nothing if polling already stopped.
+func (r *progressReporter) stop() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	id, cancel, done := r.id, r.cancel, r.done
+	r.cancel = nil
+	r.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+
+	if r.ctx.Err() == nil {
+		r.report(r.ctx, id)
+	}
+}
+
+func (r *progressReporter) report(ctx context.Context, id string) {
+	evt, err := r.progress(ctx, id)
+	if err != nil {
+		if ctx.Err() == nil {
+			zerolog.Ctx(ctx).Debug().Err(err).Msgf("Failed to get progress of %q", id)
+		}
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Events are only printed when the progress changes.
+	if evt == r.last {
+		return
+	}
+	r.last = evt
+
+	evt.Time = time.Now().UTC()
+	err = r.enc.Encode(&evt)
+	if err != nil {
+		zerolog.Ctx(ctx).Debug().Err(err).Msg("Failed to print progress")
+	}
+}
+
+// clusterProgress returns the progress of creating a cluster, as how many of
+// its nodes are provisioned.
+func clusterProgress(control p2plab.ControlAPI) progressFunc {
+	return func(ctx context.Context, id string) (metadata.ProgressEvent, error) {
+		cluster, err := control.Cluster().Get(ctx, id)
+		if err != nil {
+			return metadata.ProgressEvent{}, err
+		}
+
+		nodes, err := control.Node().List(ctx, id)
+		if err != nil {
+			return metadata.ProgressEvent{}, err
+		}
+
+		m := cluster.Metadata()
+		evt := metadata.ProgressEvent{
+			Kind:       "cluster",
+			ID:         m.ID,
+			Status:     string(m.Status),
+			Nodes:      len(nodes),
+			NodesTotal: m.Definition.Size(),
+		}
+		switch m.Status {
+		case metadata.ClusterCreating, metadata.ClusterConnecting:
+		default:
+			evt.Percent = 100
+		}
+		return evt, nil
+	}
+}
+
+// benchmarkProgress returns the progress of a running benchmark.
+func benchmarkProgress(control p2plab.ControlAPI) progressFunc {
+	return func(ctx context.Context, id string) (metadata.ProgressEvent, error) {
+		progress, err := control.Benchmark().Status(ctx, id)
+		if err != nil {
+			return metadata.ProgressEvent{}, err
+		}
+
+		return metadata.ProgressEvent{
+			Kind:         "benchmark",
+			ID:           progress.ID,
+			Status:       string(progress.Status),
+			Stage:        strings.Join(progress.Stages, ","),
+			Percent:      progress.Percent(),
+			Trial:        progress.Trial,
+			Trials:       progress.Trials,
+			Actions:      progress.ActionsCompleted,
+			ActionsTotal: progress.ActionsTotal,
+		}, nil
+	}
+}
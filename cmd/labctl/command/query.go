@@ -0,0 +1,80 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/printer"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var queryCommand = cli.Command{
+	Name:    "query",
+	Aliases: []string{"q"},
+	Usage:   "Debug queries.",
+	Subcommands: []cli.Command{
+		{
+			Name:      "explain",
+			Aliases:   []string{"x"},
+			Usage:     "Shows how a query is parsed and the nodes each part of it matches.",
+			ArgsUsage: "<query>",
+			Action:    explainQueryAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "cluster,c",
+					Usage: "Cluster whose nodes are matched.",
+				},
+				cli.StringFlag{
+					Name:  "scenario,s",
+					Usage: "Scenario whose selections the query may reference.",
+				},
+			},
+		},
+	},
+}
+
+func explainQueryAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("query must be provided")
+	}
+	if c.String("cluster") == "" {
+		return errors.New("cluster must be provided")
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	var opts []p2plab.ExplainOption
+	if c.IsSet("scenario") {
+		opts = append(opts, p2plab.WithExplainScenario(c.String("scenario")))
+	}
+
+	ctx := cliutil.CommandContext(c)
+	explanation, err := control.Node().Explain(ctx, c.String("cluster"), c.Args().First(), opts...)
+	if err != nil {
+		return err
+	}
+
+	return p.Print(explanation)
+}
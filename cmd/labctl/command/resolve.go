@@ -19,15 +19,55 @@ import (
 	"github.com/Netflix/p2plab/labagent/agentapi"
 	"github.com/Netflix/p2plab/labapp/appapi"
 	"github.com/Netflix/p2plab/labd/controlapi"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/httputil"
 	"github.com/urfave/cli"
 )
 
 func ResolveControl(c *cli.Context) (p2plab.ControlAPI, error) {
-	api := controlapi.New(CommandClient(c), c.GlobalString("address"))
+	lc, err := ResolveContext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	client := CommandClient(c)
+	if lc.Token != "" {
+		opts, err := clientOptions(c)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, httputil.WithHeader("Authorization", "Bearer "+lc.Token))
+		client, err = httputil.NewClient(httputil.NewHTTPClient(), opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	api := controlapi.New(client, lc.Address)
 	// TODO: healthcheck
 	return api, nil
 }
 
+// ResolveContext returns the labd endpoint that commands are sent to. An
+// explicit --address takes precedence over the context named by --context,
+// which takes precedence over the current context.
+func ResolveContext(c *cli.Context) (metadata.LabContext, error) {
+	if c.GlobalIsSet("address") {
+		return metadata.LabContext{Address: c.GlobalString("address")}, nil
+	}
+
+	lc, err := GetContext(c.GlobalString("context"))
+	if err != nil {
+		return lc, err
+	}
+
+	if lc.Address == "" {
+		lc.Address = c.GlobalString("address")
+	}
+	return lc, nil
+}
+
 func ResolveAgent(c *cli.Context, addr string) (p2plab.AgentAPI, error) {
 	api := agentapi.New(CommandClient(c), addr)
 	// TODO: healthcheck
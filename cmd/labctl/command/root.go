@@ -32,6 +32,11 @@ func App(ctx context.Context) *cli.App {
 			Value:  "http://127.0.0.1:7001",
 			EnvVar: "LABCTL_ADDRESS",
 		},
+		cli.StringFlag{
+			Name:   "context",
+			Usage:  "name of a saved context to send the command to instead of the current context",
+			EnvVar: "LABCTL_CONTEXT",
+		},
 		cli.StringFlag{
 			Name:   "log-level",
 			Usage:  "set the logging level [debug, info, warn, error, fatal, panic]",
@@ -44,9 +49,20 @@ func App(ctx context.Context) *cli.App {
 			Value:  "console",
 			EnvVar: "LABCTL_LOG_WRITER",
 		},
+		cli.BoolFlag{
+			Name:   "quiet,q",
+			Usage:  "only print the IDs of resources and log errors, such as the ID of a benchmark",
+			EnvVar: "LABCTL_QUIET",
+		},
+		cli.StringFlag{
+			Name:   "progress",
+			Usage:  "set the format of progress events printed to stdout while waiting for clusters and benchmarks [none, json]",
+			Value:  "none",
+			EnvVar: "LABCTL_PROGRESS",
+		},
 		cli.StringFlag{
 			Name:   "output,o",
-			Usage:  "set the output printer [auto, id, unix, json, table]",
+			Usage:  "set the output printer [auto, id, unix, json, yaml, table, wide]",
 			Value:  "auto",
 			EnvVar: "LABCTL_OUTPUT",
 		},
@@ -54,11 +70,17 @@ func App(ctx context.Context) *cli.App {
 	app.Commands = []cli.Command{
 		clusterCommand,
 		nodeCommand,
+		queryCommand,
+		dashboardCommand,
+		runCommand,
 		scenarioCommand,
 		benchmarkCommand,
 		experimentCommand,
 		buildCommand,
+		scheduleCommand,
 		debugCommand,
+		configCommand,
+		completionCommand,
 	}
 
 	// Setup tracers and context.
@@ -67,5 +89,8 @@ func App(ctx context.Context) *cli.App {
 	// Setup http client.
 	AttachAppClient(app)
 
+	// Setup shell completion.
+	AttachAppCompletion(app)
+
 	return app
 }
@@ -0,0 +1,166 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/printer"
+	"github.com/Netflix/p2plab/scenarios"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli"
+)
+
+var runCommand = cli.Command{
+	Name:      "run",
+	Usage:     "Creates a cluster and a scenario, benchmarks the scenario and prints its report.",
+	ArgsUsage: " ",
+	Action:    runAction,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "cluster",
+			Usage: "Cluster definition of the cluster to create.",
+		},
+		&cli.StringFlag{
+			Name:  "scenario",
+			Usage: "Scenario definition to benchmark.",
+		},
+		&cli.StringFlag{
+			Name:  "name",
+			Usage: "Prefix of the names of the cluster and scenario, by default takes the name of the scenario definition.",
+		},
+		&cli.StringSliceFlag{
+			Name:  "var",
+			Usage: "Sets a variable referenced by the scenario definition in the form key=value.",
+		},
+		&cli.StringSliceFlag{
+			Name:  "label,l",
+			Usage: "Adds a label to the benchmark, such as branch=main or pr=123.",
+		},
+		&cli.BoolFlag{
+			Name:  "destroy",
+			Usage: "Destroys the cluster and removes the scenario when the run ends, even if it failed.",
+		},
+	},
+}
+
+// runAction runs the whole lifecycle of a benchmark. The cluster and scenario
+// are named after the run so that concurrent runs, such as the jobs of a CI
+// pipeline, don't collide, and the benchmark is labeled run=<name> so that
+// the benchmarks of all runs can be listed together.
+func runAction(c *cli.Context) error {
+	if c.String("cluster") == "" || c.String("scenario") == "" {
+		return errors.New("cluster and scenario definitions must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	clusterReporter, err := newProgressReporter(c, clusterProgress(control))
+	if err != nil {
+		return err
+	}
+	defer clusterReporter.stop()
+
+	benchmarkReporter, err := newProgressReporter(c, benchmarkProgress(control))
+	if err != nil {
+		return err
+	}
+	defer benchmarkReporter.stop()
+
+	vars, err := parseVars(c.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+
+	sdef, err := scenarios.Parse(c.String("scenario"), vars)
+	if err != nil {
+		return err
+	}
+
+	name := c.String("name")
+	if name == "" {
+		name = ExtractNameFromFilename(c.String("scenario"))
+	}
+	id := fmt.Sprintf("%s-%d", name, time.Now().Unix())
+
+	ctx := cliutil.CommandContext(c)
+	logger := zerolog.Ctx(ctx)
+
+	scenario, err := control.Scenario().Create(ctx, id, sdef)
+	if err != nil {
+		return err
+	}
+	logger.Info().Msgf("Created scenario %q", scenario.ID())
+
+	if c.Bool("destroy") {
+		defer func() {
+			// The run's context may have been cancelled, but the scenario and
+			// cluster should still be torn down.
+			dctx := logger.WithContext(context.Background())
+
+			err := control.Scenario().Remove(dctx, id)
+			if err != nil {
+				logger.Error().Err(err).Msgf("Failed to remove scenario %q", id)
+			}
+		}()
+	}
+
+	// A cluster that failed to be created may still have nodes to destroy.
+	cluster, err := control.Cluster().Create(ctx, id,
+		p2plab.WithClusterDefinition(c.String("cluster")),
+		p2plab.WithClusterStarted(clusterReporter.start),
+	)
+	clusterReporter.stop()
+	if c.Bool("destroy") {
+		defer func() {
+			dctx := logger.WithContext(context.Background())
+
+			logger.Info().Msgf("Destroying cluster %q", id)
+			err := control.Cluster().Remove(dctx, id)
+			if err != nil {
+				logger.Error().Err(err).Msgf("Failed to destroy cluster %q", id)
+			}
+		}()
+	}
+	if err != nil {
+		return withExitCode(ExitProvisioning, err)
+	}
+	logger.Info().Msgf("Created cluster %q", cluster)
+
+	labels := append([]string{fmt.Sprintf("run=%s", name)}, c.StringSlice("label")...)
+	bid, err := control.Benchmark().Create(ctx, id, id,
+		p2plab.WithBenchmarkLabels(labels...),
+		p2plab.WithBenchmarkStarted(benchmarkReporter.start),
+	)
+	if err != nil {
+		return err
+	}
+	benchmarkReporter.stop()
+
+	return printBenchmarkReport(ctx, control, p, bid, c.GlobalBool("quiet"))
+}
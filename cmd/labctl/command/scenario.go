@@ -16,9 +16,16 @@ package command
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/experiments"
+	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/pkg/defutil"
 	"github.com/Netflix/p2plab/printer"
 	"github.com/Netflix/p2plab/query"
 	"github.com/Netflix/p2plab/scenarios"
@@ -42,6 +49,39 @@ var scenarioCommand = cli.Command{
 					Name:  "name",
 					Usage: "Name of the scenario, by default takes the name of the scenario definition.",
 				},
+				&cli.StringSliceFlag{
+					Name:  "var",
+					Usage: "Sets a variable referenced by the scenario definition in the form key=value.",
+				},
+				&cli.StringFlag{
+					Name:  "matrix",
+					Usage: "Creates a scenario named <name>-<n> for each set of independent variables in an experiment definition.",
+				},
+			},
+		},
+		{
+			Name:      "diff",
+			Usage:     "Shows how a scenario definition file differs from a scenario's definition.",
+			ArgsUsage: "<name> <definition>",
+			Action:    diffScenarioAction,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "var",
+					Usage: "Sets a variable referenced by the scenario definition in the form key=value.",
+				},
+			},
+		},
+		{
+			Name:      "init",
+			Usage:     "Writes a commented starter scenario definition to a YAML file or stdout.",
+			ArgsUsage: "[filename]",
+			Action:    initScenarioAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "template,t",
+					Usage: fmt.Sprintf("Kind of scenario to start from, one of: %s.", strings.Join(scenarios.TemplateNames(), ", ")),
+					Value: "bitswap",
+				},
 			},
 		},
 		{
@@ -88,9 +128,54 @@ var scenarioCommand = cli.Command{
 			ArgsUsage: "[<name> ...]",
 			Action:    removeScenariosAction,
 		},
+		{
+			Name:      "validate",
+			Aliases:   []string{"v"},
+			Usage:     "Validates a scenario definition file without creating it.",
+			ArgsUsage: "<filename>",
+			Action:    validateScenarioAction,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "var",
+					Usage: "Sets a variable referenced by the scenario definition in the form key=value.",
+				},
+			},
+		},
 	},
 }
 
+func initScenarioAction(c *cli.Context) error {
+	content, err := scenarios.Template(c.String("template"))
+	if err != nil {
+		return err
+	}
+
+	if c.NArg() == 0 {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+
+	filename := c.Args().First()
+	if !defutil.IsYAML(filename) {
+		return fmt.Errorf("scenario templates are YAML, so %q must have a .yaml or .yml extension", filename)
+	}
+
+	// Never overwrite a scenario that may have been edited.
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(content)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(cliutil.CommandContext(c)).Info().Msgf("Wrote %s scenario to %q", c.String("template"), filename)
+	return f.Close()
+}
+
 func createScenarioAction(c *cli.Context) error {
 	if c.NArg() != 1 {
 		return errors.New("scenario definition must be provided")
@@ -107,24 +192,113 @@ func createScenarioAction(c *cli.Context) error {
 		name = ExtractNameFromFilename(filename)
 	}
 
-	sdef, err := scenarios.Parse(filename)
+	vars, err := parseVars(c.StringSlice("var"))
 	if err != nil {
 		return err
 	}
 
+	if c.String("matrix") == "" {
+		sdef, err := scenarios.Parse(filename, vars)
+		if err != nil {
+			return err
+		}
+
+		control, err := ResolveControl(c)
+		if err != nil {
+			return err
+		}
+
+		ctx := cliutil.CommandContext(c)
+		scenario, err := control.Scenario().Create(ctx, name, sdef)
+		if err != nil {
+			return err
+		}
+
+		zerolog.Ctx(ctx).Info().Msgf("Created scenario %q", scenario.Metadata().ID)
+		return p.Print(scenario.Metadata())
+	}
+
+	edef, err := experiments.Parse(c.String("matrix"))
+	if err != nil {
+		return err
+	}
+
+	// Parse every scenario before creating any, so that a bad set of
+	// variables doesn't leave the sweep partially created.
+	var sdefs []metadata.ScenarioDefinition
+	for i, iv := range edef.Variables() {
+		ivars := make(map[string]interface{})
+		for k, v := range vars {
+			ivars[k] = v
+		}
+		for k, v := range iv {
+			ivars[k] = v
+		}
+
+		sdef, err := scenarios.Parse(filename, ivars)
+		if err != nil {
+			return fmt.Errorf("independent variable %d: %s", i, err)
+		}
+		sdefs = append(sdefs, sdef)
+	}
+
 	control, err := ResolveControl(c)
 	if err != nil {
 		return err
 	}
 
 	ctx := cliutil.CommandContext(c)
-	scenario, err := control.Scenario().Create(ctx, name, sdef)
+	var l []interface{}
+	for i, sdef := range sdefs {
+		scenario, err := control.Scenario().Create(ctx, fmt.Sprintf("%s-%d", name, i), sdef)
+		if err != nil {
+			return err
+		}
+
+		zerolog.Ctx(ctx).Info().Msgf("Created scenario %q", scenario.Metadata().ID)
+		l = append(l, scenario.Metadata())
+	}
+
+	return p.Print(l)
+}
+
+func diffScenarioAction(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("scenario name and definition must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
 	if err != nil {
 		return err
 	}
 
-	zerolog.Ctx(ctx).Info().Msgf("Created scenario %q", scenario.Metadata().ID)
-	return p.Print(scenario.Metadata())
+	vars, err := parseVars(c.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+
+	sdef, err := scenarios.Parse(c.Args().Get(1), vars)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	scenario, err := control.Scenario().Get(ctx, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	changes, err := metadata.DiffDefinitions(scenario.Metadata().Definition, sdef)
+	if err != nil {
+		return err
+	}
+
+	return printChanges(ctx, p, changes)
 }
 
 func inspectScenarioAction(c *cli.Context) error {
@@ -237,3 +411,80 @@ func removeScenariosAction(c *cli.Context) error {
 	zerolog.Ctx(ctx).Info().Strs("names", names).Msg("Removed scenarios")
 	return nil
 }
+
+func validateScenarioAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("scenario definition must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	vars, err := parseVars(c.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+
+	filename := c.Args().First()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	sdef, err := scenarios.ParseContent(filename, content, vars)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	diagnostics, err := control.Scenario().Validate(ctx, sdef)
+	if err != nil {
+		return err
+	}
+
+	// Diagnostics are located in the rendered definition, whose lines match
+	// the file's unless a variable spans many lines.
+	rendered, err := scenarios.Render(filename, content, vars)
+	if err != nil {
+		return err
+	}
+	for i, d := range diagnostics {
+		diagnostics[i].Line = defutil.Line(rendered, d.Path)
+	}
+
+	l := make([]interface{}, len(diagnostics))
+	for i, d := range diagnostics {
+		l[i] = d
+	}
+
+	err = p.Print(l)
+	if err != nil {
+		return err
+	}
+
+	if metadata.HasErrors(diagnostics) {
+		return withExitCode(ExitValidation, errors.New("scenario definition is invalid"))
+	}
+
+	return nil
+}
+
+// parseVars parses scenario variables of the form key=value.
+func parseVars(kvs []string) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	for _, kv := range kvs {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("variable %q must be of the form key=value", kv)
+		}
+		vars[parts[0]] = parts[1]
+	}
+	return vars, nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/printer"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli"
+)
+
+var scheduleCommand = cli.Command{
+	Name:    "schedule",
+	Aliases: []string{"sch"},
+	Usage:   "Manage schedules that benchmark a scenario periodically.",
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Aliases:   []string{"c"},
+			Usage:     "Creates a new schedule from a definition file.",
+			ArgsUsage: "<filename>",
+			Action:    createScheduleAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "name",
+					Usage: "Name of the schedule, by default takes the name of the schedule definition.",
+				},
+			},
+		},
+		{
+			Name:      "inspect",
+			Aliases:   []string{"i"},
+			Usage:     "Displays detailed information on a schedule.",
+			ArgsUsage: "<name>",
+			Action:    inspectScheduleAction,
+		},
+		{
+			Name:      "list",
+			Aliases:   []string{"ls"},
+			Usage:     "List schedules.",
+			ArgsUsage: " ",
+			Action:    listScheduleAction,
+		},
+		{
+			Name:      "remove",
+			Aliases:   []string{"rm"},
+			Usage:     "Remove schedules.",
+			ArgsUsage: "[<name> ...]",
+			Action:    removeSchedulesAction,
+		},
+		{
+			Name:      "run",
+			Aliases:   []string{"r"},
+			Usage:     "Runs a benchmark of a schedule now and alerts on regressions.",
+			ArgsUsage: "<name>",
+			Action:    runScheduleAction,
+		},
+	},
+}
+
+func createScheduleAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("schedule definition must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputID)
+	if err != nil {
+		return err
+	}
+
+	filename := c.Args().First()
+	name := c.String("name")
+	if name == "" {
+		name = ExtractNameFromFilename(filename)
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var sdef metadata.ScheduleDefinition
+	err = json.Unmarshal(content, &sdef)
+	if err != nil {
+		return err
+	}
+
+	err = sdef.Validate()
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	schedule, err := control.Schedule().Create(ctx, name, sdef)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Msgf("Created schedule %q", schedule.Metadata().ID)
+	return p.Print(schedule.Metadata())
+}
+
+func inspectScheduleAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("schedule name must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputJSON)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	schedule, err := control.Schedule().Get(ctx, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return p.Print(schedule.Metadata())
+}
+
+func listScheduleAction(c *cli.Context) error {
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	schedules, err := control.Schedule().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	l := make([]interface{}, len(schedules))
+	for i, s := range schedules {
+		l[i] = s.Metadata()
+	}
+
+	return p.Print(l)
+}
+
+func removeSchedulesAction(c *cli.Context) error {
+	var names []string
+	for i := 0; i < c.NArg(); i++ {
+		names = append(names, c.Args().Get(i))
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	err = control.Schedule().Remove(ctx, names...)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Strs("names", names).Msg("Removed schedules")
+	return nil
+}
+
+func runScheduleAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("schedule name must be provided")
+	}
+
+	p, err := CommandPrinter(c, printer.OutputTable)
+	if err != nil {
+		return err
+	}
+
+	control, err := ResolveControl(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := cliutil.CommandContext(c)
+	id, err := control.Schedule().Run(ctx, c.Args().First())
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return errors.New("schedule did not start a benchmark")
+	}
+
+	return printBenchmarkReport(ctx, control, p, id, c.GlobalBool("quiet"))
+}
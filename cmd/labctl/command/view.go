@@ -0,0 +1,142 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// viewsPath returns the path of the file where benchmark views are saved, which is
+// either LABCTL_VIEWS or views.json in labctl's user config directory.
+func viewsPath() (string, error) {
+	path := os.Getenv("LABCTL_VIEWS")
+	if path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "labctl", "views.json"), nil
+}
+
+func readViews() (map[string]metadata.BenchmarkView, error) {
+	path, err := viewsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make(map[string]metadata.BenchmarkView)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return views, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &views)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse views %q", path)
+	}
+	return views, nil
+}
+
+func writeViews(views map[string]metadata.BenchmarkView) error {
+	path, err := viewsPath()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(views, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// GetView returns a saved view.
+func GetView(name string) (metadata.BenchmarkView, error) {
+	views, err := readViews()
+	if err != nil {
+		return metadata.BenchmarkView{}, err
+	}
+
+	view, ok := views[name]
+	if !ok {
+		return metadata.BenchmarkView{}, errors.Wrapf(errdefs.ErrNotFound, "view %q", name)
+	}
+	return view, nil
+}
+
+// SaveView saves a view, replacing any view with the same name.
+func SaveView(view metadata.BenchmarkView) error {
+	if view.Name == "" {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "view must have a name")
+	}
+
+	views, err := readViews()
+	if err != nil {
+		return err
+	}
+
+	views[view.Name] = view
+	return writeViews(views)
+}
+
+// RemoveView removes a saved view.
+func RemoveView(name string) error {
+	views, err := readViews()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := views[name]; !ok {
+		return errors.Wrapf(errdefs.ErrNotFound, "view %q", name)
+	}
+	delete(views, name)
+	return writeViews(views)
+}
+
+// ListViews returns the saved views sorted by name.
+func ListViews() ([]metadata.BenchmarkView, error) {
+	views, err := readViews()
+	if err != nil {
+		return nil, err
+	}
+
+	var l []metadata.BenchmarkView
+	for _, view := range views {
+		l = append(l, view)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+	return l, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli"
+)
+
+// waitStarted is the wait condition of every asynchronous command that is
+// reached as soon as labd starts the operation.
+const waitStarted = "started"
+
+// waitFlags are the flags of commands that start an operation on labd and
+// wait until it reaches one of conditions, by default the last one.
+func waitFlags(conditions ...string) []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolTFlag{
+			Name:  "wait",
+			Usage: "Waits for the operation to reach --wait-for, otherwise returns once it has started.",
+		},
+		&cli.StringFlag{
+			Name:  "wait-for",
+			Usage: fmt.Sprintf("Condition to wait for, one of: %s.", strings.Join(conditions, ", ")),
+			Value: conditions[len(conditions)-1],
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Stops waiting after a duration, leaving the operation running on labd.",
+		},
+	}
+}
+
+// waitCondition returns the condition to wait for, which is started when
+// waiting is disabled.
+func waitCondition(c *cli.Context, conditions ...string) (string, error) {
+	if !c.BoolT("wait") {
+		return waitStarted, nil
+	}
+
+	condition := c.String("wait-for")
+	for _, cond := range conditions {
+		if condition == cond {
+			return condition, nil
+		}
+	}
+	return "", errors.Wrapf(errdefs.ErrInvalidArgument, "unknown wait condition %q, must be one of: %s", condition, strings.Join(conditions, ", "))
+}
+
+// waitFor starts an operation on labd and waits until it reaches condition.
+// start must call started with the ID of the operation once labd has started
+// it, and return once the operation completes. reached reports whether the
+// operation reached condition while it is still running, or is nil if the
+// condition is only reached when it completes.
+//
+// The operation is started detached, so interrupting labctl or timing out
+// only stops waiting and leaves the operation running on labd. waitFor
+// returns whether the operation completed, as opposed to having only reached
+// condition.
+func waitFor(c *cli.Context, condition string, start func(ctx context.Context, started func(id string)) (string, error), reached func(ctx context.Context, id string) (bool, error)) (id string, completed bool, err error) {
+	ctx := cliutil.CommandContext(c)
+
+	startCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		id  string
+		err error
+	}
+	startedCh := make(chan string, 1)
+	doneCh := make(chan result, 1)
+	go func() {
+		id, err := start(startCtx, func(id string) {
+			startedCh <- id
+		})
+		doneCh <- result{id, err}
+	}()
+
+	var timeout <-chan time.Time
+	if c.Duration("timeout") > 0 {
+		timer := time.NewTimer(c.Duration("timeout"))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var poll <-chan time.Time
+	for {
+		select {
+		case r := <-doneCh:
+			if r.err != nil && ctx.Err() != nil {
+				return id, false, detached(id, condition, "interrupted")
+			}
+			return r.id, r.err == nil, r.err
+		case id = <-startedCh:
+			zerolog.Ctx(ctx).Debug().Str("id", id).Msg("Operation started")
+			if condition == waitStarted {
+				return id, false, nil
+			}
+			if reached != nil {
+				ticker := time.NewTicker(time.Second)
+				defer ticker.Stop()
+				poll = ticker.C
+			}
+		case <-poll:
+			ok, err := reached(ctx, id)
+			if err != nil {
+				return id, false, err
+			}
+			if ok {
+				return id, false, nil
+			}
+		case <-timeout:
+			return id, false, detached(id, condition, "timed out")
+		case <-ctx.Done():
+			return id, false, detached(id, condition, "interrupted")
+		}
+	}
+}
+
+// detached returns the error of a command that stopped waiting for an
+// operation that is still running on labd.
+func detached(id, condition, reason string) error {
+	if id == "" {
+		return errors.Errorf("%s before labd started the operation", reason)
+	}
+	return errors.Errorf("%s waiting for %q to be %s, it is still running on labd", reason, id, condition)
+}
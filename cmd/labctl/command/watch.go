@@ -0,0 +1,82 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/printer"
+	"github.com/urfave/cli"
+)
+
+// watchFlags are the flags of list commands that can redraw their list as it
+// changes.
+var watchFlags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:  "watch,w",
+		Usage: "Redraws the list whenever it changes.",
+	},
+	&cli.DurationFlag{
+		Name:  "interval",
+		Usage: "How often labd is polled for changes when watching.",
+		Value: 2 * time.Second,
+	},
+}
+
+// printList prints the resources returned by list. When watching, labd is
+// polled until the command is interrupted, and the list is only redrawn when
+// the resources change so that it doesn't flicker.
+func printList(c *cli.Context, p printer.Printer, list func(ctx context.Context) ([]interface{}, error)) error {
+	ctx := cliutil.CommandContext(c)
+
+	var last []byte
+	for {
+		l, err := list(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !c.Bool("watch") {
+			return p.Print(l)
+		}
+
+		content, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(content, last) {
+			// Clear the terminal so that the list is redrawn in place.
+			fmt.Print("\033[H\033[2J")
+
+			err = p.Print(l)
+			if err != nil {
+				return err
+			}
+			last = content
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.Duration("interval")):
+		}
+	}
+}
@@ -41,6 +41,6 @@ func main() {
 	app := command.App(ctx)
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "labctl: %s\n", err)
-		os.Exit(1)
+		os.Exit(command.ExitCode(err))
 	}
 }
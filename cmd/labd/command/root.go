@@ -21,7 +21,14 @@ import (
 	"github.com/Netflix/p2plab/downloaders"
 	"github.com/Netflix/p2plab/downloaders/s3downloader"
 	"github.com/Netflix/p2plab/labd"
+	"github.com/Netflix/p2plab/labd/notifier"
+	"github.com/Netflix/p2plab/labd/retention"
 	"github.com/Netflix/p2plab/pkg/cliutil"
+	"github.com/Netflix/p2plab/providers"
+	"github.com/Netflix/p2plab/providers/static"
+	"github.com/Netflix/p2plab/providers/terraform"
+	"github.com/Netflix/p2plab/publishers"
+	"github.com/Netflix/p2plab/publishers/s3publisher"
 	"github.com/Netflix/p2plab/uploaders"
 	"github.com/Netflix/p2plab/uploaders/fileuploader"
 	"github.com/Netflix/p2plab/uploaders/s3uploader"
@@ -61,10 +68,55 @@ func App(ctx context.Context) *cli.App {
 		},
 		cli.StringFlag{
 			Name:   "provider,p",
-			Usage:  "set the provider to create node groups [inmemory, terraform]",
+			Usage:  "set the provider to create node groups [inmemory, terraform, static]",
 			Value:  "inmemory",
 			EnvVar: "LABD_PROVIDER",
 		},
+		cli.StringFlag{
+			Name:   "provider.static.inventory",
+			Usage:  "path to a JSON inventory of hosts for the static provider",
+			EnvVar: "LABD_PROVIDER_STATIC_INVENTORY",
+		},
+		cli.StringFlag{
+			Name:   "provider.terraform.lab-id",
+			Usage:  "lab ID tagged on resources provisioned by the terraform provider",
+			Value:  "p2plab",
+			EnvVar: "LABD_PROVIDER_TERRAFORM_LAB_ID",
+		},
+		cli.StringSliceFlag{
+			Name:   "provider.terraform.labd-cidr",
+			Usage:  "address labd reaches nodes from, allowed through the security groups of clusters with connectivity rules, defaults to the host's addresses",
+			EnvVar: "LABD_PROVIDER_TERRAFORM_LABD_CIDR",
+		},
+		cli.DurationFlag{
+			Name:   "reaper.interval",
+			Usage:  "interval to reconcile provider infrastructure against cluster metadata, 0 to disable",
+			Value:  0,
+			EnvVar: "LABD_REAPER_INTERVAL",
+		},
+		cli.BoolFlag{
+			Name:   "reaper.destroy",
+			Usage:  "destroy orphaned infrastructure instead of only reporting it",
+			EnvVar: "LABD_REAPER_DESTROY",
+		},
+		cli.DurationFlag{
+			Name:   "retention.age",
+			Usage:  "age after which benchmark reports are downsampled, 0 to keep every sample",
+			Value:  0,
+			EnvVar: "LABD_RETENTION_AGE",
+		},
+		cli.DurationFlag{
+			Name:   "retention.resolution",
+			Usage:  "interval between the samples kept in downsampled benchmark reports",
+			Value:  retention.DefaultResolution,
+			EnvVar: "LABD_RETENTION_RESOLUTION",
+		},
+		cli.DurationFlag{
+			Name:   "retention.interval",
+			Usage:  "interval to check for benchmark reports to downsample",
+			Value:  retention.DefaultInterval,
+			EnvVar: "LABD_RETENTION_INTERVAL",
+		},
 		cli.StringFlag{
 			Name:   "uploader,u",
 			Usage:  "set the uploader to use to distribute p2p app binaries [file, s3]",
@@ -92,6 +144,62 @@ func App(ctx context.Context) *cli.App {
 			Value:  ":7000",
 			EnvVar: "LABD_UPLOADER_FILE_ADDRESS",
 		},
+		cli.StringFlag{
+			Name:   "publisher",
+			Usage:  "set the publisher to share benchmark results with, or none to disable [s3, gcs]",
+			EnvVar: "LABD_PUBLISHER",
+		},
+		cli.StringFlag{
+			Name:   "publisher.bucket",
+			Usage:  "bucket name for publisher",
+			EnvVar: "LABD_PUBLISHER_BUCKET",
+		},
+		cli.StringFlag{
+			Name:   "publisher.prefix",
+			Usage:  "bucket prefix for publisher",
+			EnvVar: "LABD_PUBLISHER_PREFIX",
+		},
+		cli.StringFlag{
+			Name:   "publisher.region",
+			Usage:  "region for publisher",
+			EnvVar: "LABD_PUBLISHER_REGION",
+		},
+		cli.StringFlag{
+			Name:   "publisher.endpoint",
+			Usage:  "endpoint of an s3 compatible object store for publisher",
+			EnvVar: "LABD_PUBLISHER_ENDPOINT",
+		},
+		cli.StringFlag{
+			Name:   "publisher.base-url",
+			Usage:  "public URL that the bucket is served under, instead of presigning links",
+			EnvVar: "LABD_PUBLISHER_BASE_URL",
+		},
+		cli.DurationFlag{
+			Name:   "publisher.expiry",
+			Usage:  "duration that presigned links remain valid",
+			Value:  s3publisher.DefaultExpiry,
+			EnvVar: "LABD_PUBLISHER_EXPIRY",
+		},
+		cli.StringSliceFlag{
+			Name:   "notify.webhook",
+			Usage:  "URL that a summary of each completed benchmark is posted to as JSON",
+			EnvVar: "LABD_NOTIFY_WEBHOOK",
+		},
+		cli.StringSliceFlag{
+			Name:   "notify.slack",
+			Usage:  "URL of a Slack incoming webhook that a summary of each completed benchmark is posted to",
+			EnvVar: "LABD_NOTIFY_SLACK",
+		},
+		cli.StringSliceFlag{
+			Name:   "notify.metric",
+			Usage:  "headline metric of the summary of benchmarks, named as in expectations",
+			EnvVar: "LABD_NOTIFY_METRIC",
+		},
+		cli.StringFlag{
+			Name:   "notify.base-url",
+			Usage:  "URL that labd is reachable at, which summaries link the report to unless it was published",
+			EnvVar: "LABD_NOTIFY_BASE_URL",
+		},
 		cli.StringFlag{
 			Name:   "downloader.s3.region",
 			Usage:  "region for s3 downloader",
@@ -117,6 +225,17 @@ func daemonAction(c *cli.Context) error {
 	daemon, err := labd.New(root, c.GlobalString("address"), zerolog.Ctx(ctx),
 		labd.WithLibp2pPort(c.GlobalInt("libp2p-port")),
 		labd.WithProvider(c.GlobalString("provider")),
+		labd.WithProviderSettings(providers.ProviderSettings{
+			Static: static.StaticProviderSettings{
+				Inventory: c.GlobalString("provider.static.inventory"),
+			},
+			Terraform: terraform.TerraformProviderSettings{
+				LabID:     c.GlobalString("provider.terraform.lab-id"),
+				LabdCIDRs: c.GlobalStringSlice("provider.terraform.labd-cidr"),
+			},
+		}),
+		labd.WithReaper(c.GlobalDuration("reaper.interval"), c.GlobalBool("reaper.destroy")),
+		labd.WithRetention(c.GlobalDuration("retention.age"), c.GlobalDuration("retention.resolution"), c.GlobalDuration("retention.interval")),
 		labd.WithUploader(c.GlobalString("uploader")),
 		labd.WithUploaderSettings(uploaders.UploaderSettings{
 			S3: s3uploader.S3UploaderSettings{
@@ -128,6 +247,23 @@ func daemonAction(c *cli.Context) error {
 				Address: c.GlobalString("uploader.file.address"),
 			},
 		}),
+		labd.WithPublisher(c.GlobalString("publisher")),
+		labd.WithPublisherSettings(publishers.PublisherSettings{
+			S3: s3publisher.S3PublisherSettings{
+				Bucket:   c.GlobalString("publisher.bucket"),
+				Prefix:   c.GlobalString("publisher.prefix"),
+				Region:   c.GlobalString("publisher.region"),
+				Endpoint: c.GlobalString("publisher.endpoint"),
+				BaseURL:  c.GlobalString("publisher.base-url"),
+				Expiry:   c.GlobalDuration("publisher.expiry"),
+			},
+		}),
+		labd.WithNotifierSettings(notifier.Settings{
+			Webhooks: c.GlobalStringSlice("notify.webhook"),
+			Slacks:   c.GlobalStringSlice("notify.slack"),
+			Metrics:  c.GlobalStringSlice("notify.metric"),
+			BaseURL:  c.GlobalString("notify.base-url"),
+		}),
 		labd.WithDownloaderSettings(downloaders.DownloaderSettings{
 			S3: s3downloader.S3DownloaderSettings{
 				Region: c.String("downloader.s3.region"),
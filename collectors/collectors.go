@@ -0,0 +1,61 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/collectors/datastore"
+	"github.com/Netflix/p2plab/collectors/exec"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+)
+
+// New returns the metrics collector of a spec, either "datastore" for the
+// size of the peer's datastore under root, or "exec:<command> [args...]" for
+// the metrics printed by a command. Commands must be in allow, the commands
+// that exec tasks may run.
+func New(root, spec string, allow []string) (p2plab.MetricsCollector, error) {
+	name, args := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, args = spec[:i], spec[i+1:]
+	}
+
+	switch name {
+	case "datastore":
+		return datastore.New(root), nil
+	case "exec":
+		fields := strings.Fields(args)
+		if len(fields) == 0 {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "collector %q has no command", spec)
+		}
+		if !allowed(fields[0], allow) {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "command %q is not allowed on this node", fields[0])
+		}
+		return exec.New(fields[0], fields[1:]), nil
+	default:
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized collector %q", spec)
+	}
+}
+
+func allowed(command string, allow []string) bool {
+	for _, a := range allow {
+		if command == a {
+			return true
+		}
+	}
+	return false
+}
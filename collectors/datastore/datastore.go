@@ -0,0 +1,64 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+)
+
+type collector struct {
+	root string
+}
+
+// New returns a metrics collector of the size on disk of the datastore under
+// root, reported as the gauges "bytes" and "files".
+func New(root string) p2plab.MetricsCollector {
+	return &collector{root: root}
+}
+
+func (c *collector) Name() string {
+	return "datastore"
+}
+
+func (c *collector) Collect(ctx context.Context) ([]metadata.CustomMetric, error) {
+	var size, files int64
+	err := filepath.Walk(c.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files may be removed by garbage collection during the walk.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+			files++
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []metadata.CustomMetric{
+		{Name: "bytes", Kind: metadata.CustomGauge, Value: float64(size)},
+		{Name: "files", Kind: metadata.CustomGauge, Value: float64(files)},
+	}, nil
+}
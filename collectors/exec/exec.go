@@ -0,0 +1,65 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+type collector struct {
+	command string
+	args    []string
+}
+
+// New returns a metrics collector that runs a command each time a report is
+// collected. The command prints a JSON array of metadata.CustomMetric, and the
+// collector is named after the command's base name.
+func New(command string, args []string) p2plab.MetricsCollector {
+	return &collector{
+		command: command,
+		args:    args,
+	}
+}
+
+func (c *collector) Name() string {
+	return filepath.Base(c.command)
+}
+
+func (c *collector) Collect(ctx context.Context) ([]metadata.CustomMetric, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.command, c.args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "command %q failed: %s", c.command, strings.TrimSpace(stderr.String()))
+	}
+
+	var metrics []metadata.CustomMetric
+	err = json.Unmarshal(out, &metrics)
+	if err != nil {
+		return nil, errors.Wrapf(err, "command %q printed invalid metrics", c.command)
+	}
+	return metrics, nil
+}
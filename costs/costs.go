@@ -0,0 +1,75 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package costs
+
+import (
+	"github.com/Netflix/p2plab/metadata"
+)
+
+var (
+	// OnDemandPrices are the hourly on-demand prices in USD for instance types
+	// in us-east-1. Other regions are priced with RegionMultipliers.
+	OnDemandPrices = map[string]float64{
+		"t2.micro":   0.0116,
+		"t2.small":   0.023,
+		"t2.medium":  0.0464,
+		"t2.large":   0.0928,
+		"t3.micro":   0.0104,
+		"t3.small":   0.0208,
+		"t3.medium":  0.0416,
+		"t3.large":   0.0832,
+		"m5.large":   0.096,
+		"m5.xlarge":  0.192,
+		"m5.2xlarge": 0.384,
+		"c5.large":   0.085,
+		"c5.xlarge":  0.17,
+		"c5.2xlarge": 0.34,
+		"r5.large":   0.126,
+		"r5.xlarge":  0.252,
+	}
+
+	// RegionMultipliers scale the us-east-1 prices to other regions.
+	RegionMultipliers = map[string]float64{
+		"us-east-1": 1.0,
+		"us-west-2": 1.0,
+		"eu-west-1": 1.11,
+	}
+)
+
+// HourlyPrice returns the estimated hourly price of an instance type in a
+// region. Unknown instance types, such as those of a static inventory, are
+// free.
+func HourlyPrice(instanceType, region string) float64 {
+	price, ok := OnDemandPrices[instanceType]
+	if !ok {
+		return 0
+	}
+
+	multiplier, ok := RegionMultipliers[region]
+	if !ok {
+		multiplier = 1.0
+	}
+
+	return price * multiplier
+}
+
+// EstimateHourly returns the estimated hourly cost of a cluster definition.
+func EstimateHourly(cdef metadata.ClusterDefinition) float64 {
+	var sum float64
+	for _, group := range cdef.Groups {
+		sum += float64(group.Size) * HourlyPrice(group.InstanceType, group.Region)
+	}
+	return sum
+}
@@ -17,11 +17,13 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"time"
 
 	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/pkg/httputil"
 	"github.com/Netflix/p2plab/pkg/logutil"
 	"github.com/Netflix/p2plab/pkg/traceutil"
 	"github.com/gorilla/mux"
@@ -37,6 +39,8 @@ type Daemon struct {
 	routers []Router
 	tracer  opentracing.Tracer
 	closers []io.Closer
+
+	idempotency *idempotencyCache
 }
 
 func New(service, addr string, logger *zerolog.Logger, routers ...Router) (*Daemon, error) {
@@ -45,6 +49,8 @@ func New(service, addr string, logger *zerolog.Logger, routers ...Router) (*Daem
 		addr:    addr,
 		logger:  logger,
 		routers: routers,
+
+		idempotency: newIdempotencyCache(),
 	}
 	return d, nil
 }
@@ -101,7 +107,14 @@ func (d *Daemon) createMux(routers ...Router) *mux.Router {
 
 func (d *Daemon) createHTTPHandler(handler Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := d.logger.WithContext(r.Context())
+		logger := d.logger
+		ctx := r.Context()
+		if id := r.Header.Get(traceutil.ActionHeader); id != "" {
+			ctx = traceutil.WithActionID(ctx, id)
+			actionLogger := logger.With().Str("action", id).Logger()
+			logger = &actionLogger
+		}
+		ctx = logger.WithContext(ctx)
 		ctx = traceutil.WithTracer(ctx, d.tracer)
 		r = r.WithContext(ctx)
 
@@ -110,9 +123,18 @@ func (d *Daemon) createHTTPHandler(handler Handler) http.HandlerFunc {
 			vars = make(map[string]string)
 		}
 
-		err := handler(ctx, w, r, vars)
+		var err error
+		if key := r.Header.Get(httputil.IdempotencyHeader); key != "" {
+			// Keys are only unique to the endpoint they are sent to.
+			key = fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, key)
+			err = d.idempotency.serve(ctx, key, w, func(ctx context.Context, w http.ResponseWriter) error {
+				return handler(ctx, w, r.WithContext(ctx), vars)
+			})
+		} else {
+			err = handler(ctx, w, r, vars)
+		}
 		if err != nil {
-			d.logger.Debug().Err(err).Msg("failed request")
+			logger.Debug().Err(err).Msg("failed request")
 			if errdefs.IsAlreadyExists(err) {
 				http.Error(w, err.Error(), http.StatusConflict)
 			} else if errdefs.IsNotFound(err) {
@@ -21,10 +21,15 @@ import (
 	"github.com/Netflix/p2plab/daemon"
 )
 
-type router struct{}
+// Check returns an error if the daemon is unhealthy.
+type Check func() error
 
-func New() daemon.Router {
-	return &router{}
+type router struct {
+	checks []Check
+}
+
+func New(checks ...Check) daemon.Router {
+	return &router{checks}
 }
 
 func (s *router) Routes() []daemon.Route {
@@ -35,6 +40,13 @@ func (s *router) Routes() []daemon.Route {
 }
 
 func (s *router) healthcheck(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	for _, check := range s.checks {
+		err := check()
+		if err != nil {
+			return err
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 	return nil
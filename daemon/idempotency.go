@@ -0,0 +1,172 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyTTL is how long the response of a request with an idempotency
+// key is kept for retries of the request.
+const idempotencyTTL = time.Hour
+
+// idempotencyCache keeps the requests with an idempotency key that were
+// served, so that a retry of a request that was acted on is answered with the
+// status and headers of the original response, such as the ID of the resource
+// it created, rather than acted on again.
+type idempotencyCache struct {
+	mu       sync.Mutex
+	requests map[string]*idempotentRequest
+}
+
+type idempotentRequest struct {
+	done    chan struct{}
+	status  int
+	header  http.Header
+	failed  bool
+	expires time.Time
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{
+		requests: make(map[string]*idempotentRequest),
+	}
+}
+
+// serve calls handler for the first request with a key, and answers the
+// requests with the same key with its response status and headers. Requests
+// with the same key wait for the first one to complete, and if it fails, the
+// next one is handled instead.
+//
+// The first request is handled with a detached context, as its client is
+// expected to retry if it disconnects, and the retry must wait for the
+// request to complete rather than handle it again.
+func (c *idempotencyCache) serve(ctx context.Context, key string, w http.ResponseWriter, handler func(ctx context.Context, w http.ResponseWriter) error) error {
+	for {
+		req, first := c.begin(key)
+		if first {
+			sw := &statusWriter{ResponseWriter: w}
+			err := handler(Detach(ctx), sw)
+			c.end(key, req, sw.status, w.Header(), err)
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-req.done:
+		}
+
+		if !req.failed {
+			replay(w, req.status, req.header)
+			return nil
+		}
+	}
+}
+
+func (c *idempotencyCache) begin(key string) (*idempotentRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, req := range c.requests {
+		if !req.expires.IsZero() && now.After(req.expires) {
+			delete(c.requests, k)
+		}
+	}
+
+	req, ok := c.requests[key]
+	if ok {
+		return req, false
+	}
+
+	req = &idempotentRequest{
+		done: make(chan struct{}),
+	}
+	c.requests[key] = req
+	return req, true
+}
+
+func (c *idempotencyCache) end(key string, req *idempotentRequest, status int, header http.Header, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		req.failed = true
+		delete(c.requests, key)
+	} else {
+		req.status = status
+		req.header = header.Clone()
+		req.expires = time.Now().Add(idempotencyTTL)
+	}
+	close(req.done)
+}
+
+// replay writes the status and headers of an earlier response without its
+// body. Trailers are written after the header as they originally were.
+func replay(w http.ResponseWriter, status int, header http.Header) {
+	trailers := make(map[string]struct{})
+	for _, k := range header.Values("Trailer") {
+		trailers[http.CanonicalHeaderKey(k)] = struct{}{}
+	}
+
+	for k, v := range header {
+		if _, ok := trailers[k]; ok || k == "Content-Length" {
+			continue
+		}
+		w.Header()[k] = v
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	for k := range trailers {
+		w.Header()[k] = header[k]
+	}
+}
+
+// statusWriter records the status of a response, which is implicitly OK if
+// the body is written before the header.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush flushes the response if it can be, as streamed responses such as logs
+// are flushed as they are written.
+func (w *statusWriter) Flush() {
+	f, ok := w.ResponseWriter.(http.Flusher)
+	if ok {
+		f.Flush()
+	}
+}
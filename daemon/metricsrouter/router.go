@@ -0,0 +1,43 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsrouter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Netflix/p2plab/daemon"
+	"github.com/Netflix/p2plab/pkg/metrics"
+)
+
+type router struct {
+	reg *metrics.Registry
+}
+
+func New(reg *metrics.Registry) daemon.Router {
+	return &router{reg}
+}
+
+func (s *router) Routes() []daemon.Route {
+	return []daemon.Route{
+		// GET
+		daemon.NewGetRoute("/metrics", s.getMetrics),
+	}
+}
+
+func (s *router) getMetrics(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	return s.reg.Write(w)
+}
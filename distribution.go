@@ -65,6 +65,16 @@ type Uploader interface {
 	Close() error
 }
 
+// Publisher publishes benchmark results to an object store, so that they can
+// be shared outside the lab network.
+type Publisher interface {
+	// Publish uploads a file under a key and returns a URL that it can be
+	// retrieved from.
+	Publish(ctx context.Context, key, contentType string, r io.Reader) (url string, err error)
+
+	Close() error
+}
+
 // Download downlaods artifacts from an external distribution mechanism.
 type Downloader interface {
 	// Download downloads an artifact with an abstract link.
@@ -20,13 +20,18 @@ import (
 	"github.com/Netflix/p2plab/metadata"
 )
 
-// ExperimentAPI is an unimplemented layer to run experiments, a collection
-// of benchmarks while varying some aspect.
+// ExperimentAPI is a layer to run experiments, a collection of benchmarks
+// while varying some aspect.
 type ExperimentAPI interface {
+	// Create runs an experiment to completion, benchmarking every combination
+	// of its variables on a cluster of its own.
 	Create(ctx context.Context, id string, edef metadata.ExperimentDefinition) (Experiment, error)
 
 	Get(ctx context.Context, id string) (Experiment, error)
 
+	// Report returns the metrics of the benchmark of every completed trial.
+	Report(ctx context.Context, id string) (metadata.ExperimentReport, error)
+
 	Label(ctx context.Context, ids, adds, removes []string) ([]Experiment, error)
 
 	List(ctx context.Context, opts ...ListOption) ([]Experiment, error)
@@ -0,0 +1,229 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package experiments
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/scenarios"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Run runs every trial of an experiment, each on its own cluster that is
+// destroyed once its benchmark completes. At most the definition's
+// concurrency of trials run at once. The experiment is updated as each trial
+// progresses, and a trial that fails does not stop the others.
+func Run(ctx context.Context, db metadata.DB, control p2plab.ControlAPI, experiment metadata.Experiment) (metadata.Experiment, error) {
+	edef := experiment.Definition
+	ivs := edef.Variables()
+
+	experiment.Trials = make([]metadata.ExperimentTrial, len(ivs))
+	for i, iv := range ivs {
+		experiment.Trials[i] = metadata.ExperimentTrial{
+			Variables: iv,
+			Status:    metadata.ExperimentPending,
+		}
+	}
+
+	// Trials update their own entry of the experiment, which is written back
+	// as a whole.
+	var mu sync.Mutex
+	update := func(i int, fn func(trial *metadata.ExperimentTrial)) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fn(&experiment.Trials[i])
+		var err error
+		experiment, err = db.UpdateExperiment(ctx, experiment)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("failed to update experiment")
+		}
+	}
+
+	experiment.Status = metadata.ExperimentRunning
+	experiment, err := db.UpdateExperiment(ctx, experiment)
+	if err != nil {
+		return experiment, err
+	}
+
+	concurrency := edef.Concurrency
+	if concurrency == 0 {
+		concurrency = 1
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for i, iv := range ivs {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, iv metadata.IndependentVariable) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			name := fmt.Sprintf("%s-%d", experiment.ID, i)
+			logger := zerolog.Ctx(ctx).With().Int("trial", i+1).Int("trials", len(ivs)).Logger()
+			tctx := logger.WithContext(ctx)
+
+			update(i, func(trial *metadata.ExperimentTrial) {
+				trial.Status = metadata.ExperimentRunning
+			})
+
+			logger.Info().Interface("vars", iv).Msg("Starting trial")
+			bid, err := runTrial(tctx, control, experiment.ID, name, edef, iv)
+			update(i, func(trial *metadata.ExperimentTrial) {
+				trial.Benchmark = bid
+				trial.Status = metadata.ExperimentDone
+				if err != nil {
+					trial.Status = metadata.ExperimentError
+					trial.Error = err.Error()
+				}
+			})
+			if err != nil {
+				logger.Error().Err(err).Msg("Trial failed")
+				return
+			}
+			logger.Info().Str("bid", bid).Msg("Completed trial")
+		}(i, iv)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	experiment.Status = metadata.ExperimentDone
+	for _, trial := range experiment.Trials {
+		if trial.Status != metadata.ExperimentDone {
+			experiment.Status = metadata.ExperimentError
+		}
+	}
+
+	experiment, err = db.UpdateExperiment(ctx, experiment)
+	if err != nil {
+		return experiment, err
+	}
+
+	if ctx.Err() != nil {
+		return experiment, ctx.Err()
+	}
+	return experiment, nil
+}
+
+func runTrial(ctx context.Context, control p2plab.ControlAPI, eid, name string, edef metadata.ExperimentDefinition, iv metadata.IndependentVariable) (string, error) {
+	cdef, err := ClusterDefinition(edef.ClusterDefinition, iv)
+	if err != nil {
+		return "", err
+	}
+
+	sdef, err := ScenarioDefinition(edef, iv)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = control.Scenario().Create(ctx, name, sdef)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create scenario")
+	}
+	defer func() {
+		err := control.Scenario().Remove(ctx, name)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str("scenario", name).Msg("failed to remove scenario")
+		}
+	}()
+
+	_, err = control.Cluster().Create(ctx, name, p2plab.WithParsedClusterDefinition(cdef))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create cluster")
+	}
+	defer func() {
+		err := control.Cluster().Remove(ctx, name)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str("cluster", name).Msg("failed to remove cluster")
+		}
+	}()
+
+	bid, err := control.Benchmark().Create(ctx, name, name,
+		p2plab.WithBenchmarkLabels(fmt.Sprintf("experiment=%s", eid)),
+	)
+	if err != nil {
+		return bid, errors.Wrap(err, "failed to run benchmark")
+	}
+
+	return bid, nil
+}
+
+// ClusterDefinition returns the cluster definition of a trial, with the
+// cluster variables of the trial applied to its cluster groups.
+func ClusterDefinition(cdef metadata.ClusterDefinition, iv metadata.IndependentVariable) (metadata.ClusterDefinition, error) {
+	groups := make([]metadata.ClusterGroup, len(cdef.Groups))
+	copy(groups, cdef.Groups)
+	cdef.Groups = groups
+
+	if len(cdef.Groups) == 0 {
+		cdef.Groups = append(cdef.Groups, metadata.ClusterGroup{})
+	}
+
+	for i, group := range cdef.Groups {
+		pdef := metadata.DefaultPeerDefinition
+		if group.Peer != nil {
+			pdef = *group.Peer
+		}
+
+		if v, ok := iv[metadata.VariableClusterSize]; ok && group.Size == 0 {
+			size, err := strconv.Atoi(fmt.Sprint(v))
+			if err != nil || size <= 0 {
+				return cdef, errors.Wrapf(errdefs.ErrInvalidArgument, "variable %q must be a positive integer, got %v", metadata.VariableClusterSize, v)
+			}
+			group.Size = size
+		}
+		if v, ok := iv[metadata.VariableCommit]; ok {
+			pdef.GitReference = fmt.Sprint(v)
+		}
+
+		if group.Size <= 0 {
+			return cdef, errors.Wrapf(errdefs.ErrInvalidArgument, "cluster group %d must have a size or the %q variable", i, metadata.VariableClusterSize)
+		}
+
+		group.Peer = &pdef
+		cdef.Groups[i] = group
+	}
+
+	return cdef, nil
+}
+
+// ScenarioDefinition returns the scenario definition of a trial, executing
+// the scenario template with the variables of the trial if there is one.
+func ScenarioDefinition(edef metadata.ExperimentDefinition, iv metadata.IndependentVariable) (metadata.ScenarioDefinition, error) {
+	if edef.ScenarioTemplate == "" {
+		return edef.ScenarioDefinition, nil
+	}
+
+	return scenarios.ParseContent("experiment", []byte(edef.ScenarioTemplate), iv)
+}
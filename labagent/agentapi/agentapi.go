@@ -19,6 +19,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/Netflix/p2plab"
@@ -44,14 +45,17 @@ func (a *api) url(endpoint string, v ...interface{}) string {
 	return fmt.Sprintf("%s%s", a.addr, fmt.Sprintf(endpoint, v...))
 }
 
+// Healthcheck retries until the agent is reachable, but an agent that reports
+// itself unhealthy, e.g. because its bootstrap script failed, is not retried.
 func (a *api) Healthcheck(ctx context.Context) bool {
 	req := a.client.NewRequest("GET", a.url("/healthcheck"),
 		httputil.WithRetryWaitMax(5*time.Minute),
 		httputil.WithRetryMax(10),
+		httputil.WithCheckRetry(httputil.RetryTransient),
 	)
 	resp, err := req.Send(ctx)
 	if err != nil {
-		zerolog.Ctx(ctx).Debug().Str("err", err.Error()).Str("addr", a.addr).Msg("unhealthy")
+		zerolog.Ctx(ctx).Warn().Str("err", err.Error()).Str("addr", a.addr).Msg("unhealthy")
 		return false
 	}
 	defer resp.Body.Close()
@@ -59,6 +63,103 @@ func (a *api) Healthcheck(ctx context.Context) bool {
 	return true
 }
 
+// clockSamples is how many clock readings are taken to estimate an offset.
+const clockSamples = 5
+
+// ClockOffset takes several clock readings from the agent and keeps the
+// estimate with the shortest round trip, which is the least skewed by
+// asymmetric network delay.
+func (a *api) ClockOffset(ctx context.Context) (metadata.ClockOffset, error) {
+	var best metadata.ClockOffset
+	for i := 0; i < clockSamples; i++ {
+		req := a.client.NewRequest("GET", a.url("/clock"))
+
+		t0 := time.Now()
+		resp, err := req.Send(ctx)
+		if err != nil {
+			return best, err
+		}
+
+		var reading metadata.ClockReading
+		err = json.NewDecoder(resp.Body).Decode(&reading)
+		resp.Body.Close()
+		if err != nil {
+			return best, err
+		}
+
+		offset := metadata.NewClockOffset(t0, reading, time.Now())
+		if i == 0 || offset.RoundTrip < best.RoundTrip {
+			best = offset
+		}
+	}
+
+	return best, nil
+}
+
+func (a *api) HostSamples(ctx context.Context, since time.Time) ([]metadata.HostSample, error) {
+	req := a.client.NewRequest("GET", a.url("/host/json")).
+		Option("since", since.Format(time.RFC3339Nano))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var samples []metadata.HostSample
+	err = json.NewDecoder(resp.Body).Decode(&samples)
+	if err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+func (a *api) Logs(ctx context.Context, fn func(metadata.LogLine) error, opts ...p2plab.LogsOption) error {
+	var settings p2plab.LogsSettings
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := a.client.NewRequest("GET", a.url("/logs")).
+		Option("since", settings.Since.Format(time.RFC3339Nano))
+	if settings.Follow {
+		req.Option("follow", "true")
+	}
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return DecodeLogs(ctx, resp.Body, fn)
+}
+
+// DecodeLogs calls fn with each line decoded from a stream of lines until the
+// stream ends or ctx is cancelled.
+func DecodeLogs(ctx context.Context, r io.Reader, fn func(metadata.LogLine) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var line metadata.LogLine
+		err := dec.Decode(&line)
+		if err == io.EOF || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = fn(line)
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (a *api) Update(ctx context.Context, id, link string, pdef metadata.PeerDefinition) error {
 	content, err := json.MarshalIndent(&pdef, "", "    ")
 	if err != nil {
@@ -87,6 +188,32 @@ func (a *api) Update(ctx context.Context, id, link string, pdef metadata.PeerDef
 	return nil
 }
 
+func (a *api) Impair(ctx context.Context, rules []metadata.NetworkRule) error {
+	content, err := json.MarshalIndent(&rules, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	req := a.client.NewRequest("PUT", a.url("/network")).
+		Body(bytes.NewReader(content))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	logWriter := logutil.LogWriter(ctx)
+	if logWriter != nil {
+		err = logutil.WriteRemoteLogs(ctx, resp.Body, logWriter)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (a *api) SSH(ctx context.Context, opts ...p2plab.SSHOption) error {
 	return nil
 }
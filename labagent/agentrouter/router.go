@@ -21,26 +21,104 @@ import (
 	"time"
 
 	"github.com/Netflix/p2plab/daemon"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/labagent/hoststats"
+	"github.com/Netflix/p2plab/labagent/logbuffer"
+	"github.com/Netflix/p2plab/labagent/netem"
 	"github.com/Netflix/p2plab/labagent/supervisor"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/logutil"
+	"github.com/Netflix/p2plab/pkg/metrics"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
 type router struct {
 	addr       string
 	supervisor supervisor.Supervisor
+	iface      string
+	host       *hoststats.Sampler
+	logs       *logbuffer.Buffer
+
+	updates        *metrics.Counter
+	updateFailures *metrics.Counter
+	updateDuration *metrics.Histogram
+	networkRules   *metrics.Gauge
 }
 
-func New(addr string, s supervisor.Supervisor) daemon.Router {
-	return &router{addr, s}
+func New(addr string, s supervisor.Supervisor, iface string, host *hoststats.Sampler, logs *logbuffer.Buffer, reg *metrics.Registry) daemon.Router {
+	return &router{
+		addr:           addr,
+		supervisor:     s,
+		iface:          iface,
+		host:           host,
+		logs:           logs,
+		updates:        reg.NewCounter("labagent_updates_total", "Updates of the supervised labapp."),
+		updateFailures: reg.NewCounter("labagent_update_failures_total", "Updates of the supervised labapp that failed."),
+		updateDuration: reg.NewHistogram("labagent_update_duration_seconds", "Time taken to download, build and restart the labapp.", metrics.ExponentialBuckets(0.5, 2, 12)),
+		networkRules:   reg.NewGauge("labagent_network_rules", "Network impairment rules applied to the node's traffic."),
+	}
 }
 
 func (s *router) Routes() []daemon.Route {
 	return []daemon.Route{
+		// GET
+		daemon.NewGetRoute("/clock", s.getClock),
+		daemon.NewGetRoute("/host/json", s.getHost),
+		daemon.NewGetRoute("/logs", s.getLogs),
 		// PUT
 		daemon.NewPutRoute("/update", s.putUpdate),
+		daemon.NewPutRoute("/network", s.putNetwork),
+	}
+}
+
+func (s *router) getClock(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	reading := metadata.ClockReading{Receive: time.Now()}
+	reading.Transmit = time.Now()
+	return daemon.WriteJSON(w, &reading)
+}
+
+func (s *router) getHost(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var since time.Time
+	if v := r.FormValue("since"); v != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "invalid since %q", v)
+		}
 	}
+
+	samples := s.host.Since(since)
+	return daemon.WriteJSON(w, &samples)
+}
+
+// getLogs writes the lines logged after since as JSON, one per line. If
+// follow is set, lines are written as they are logged until the request is
+// cancelled.
+func (s *router) getLogs(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var since time.Time
+	if v := r.FormValue("since"); v != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "invalid since %q", v)
+		}
+	}
+
+	enc := json.NewEncoder(logutil.NewWriteFlusher(w))
+	if r.FormValue("follow") != "true" {
+		for _, line := range s.logs.Since(since) {
+			err := enc.Encode(&line)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return s.logs.Follow(ctx, since, func(line metadata.LogLine) error {
+		return enc.Encode(&line)
+	})
 }
 
 func (s *router) putUpdate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
@@ -57,8 +135,12 @@ func (s *router) putUpdate(ctx context.Context, w http.ResponseWriter, r *http.R
 		return err
 	}
 
+	start := time.Now()
 	err = s.supervisor.Supervise(ctx, id, link, pdef)
+	s.updates.Inc()
+	s.updateDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
+		s.updateFailures.Inc()
 		return err
 	}
 
@@ -67,3 +149,24 @@ func (s *router) putUpdate(ctx context.Context, w http.ResponseWriter, r *http.R
 
 	return nil
 }
+
+func (s *router) putNetwork(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	ctx, logger := logutil.WithResponseLogger(ctx, w)
+	logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Str("iface", s.iface)
+	})
+
+	var rules []metadata.NetworkRule
+	err := json.NewDecoder(r.Body).Decode(&rules)
+	if err != nil {
+		return err
+	}
+
+	err = netem.Apply(ctx, s.iface, rules)
+	if err != nil {
+		return err
+	}
+	s.networkRules.Set(float64(len(rules)))
+
+	return nil
+}
@@ -0,0 +1,323 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hoststats
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultInterval is how often the host is sampled.
+	DefaultInterval = time.Second
+
+	// DefaultRetention is how long samples are kept.
+	DefaultRetention = time.Hour
+
+	// sectorSize is the unit of /proc/diskstats, regardless of the device.
+	sectorSize = 512
+)
+
+// Sampler periodically samples the resource utilization of the host from
+// procfs, and keeps the samples taken within its retention.
+type Sampler struct {
+	root      string
+	interval  time.Duration
+	retention time.Duration
+
+	mu      sync.Mutex
+	samples []metadata.HostSample
+}
+
+// New returns a sampler of the host whose procfs and sysfs are mounted under
+// root, which is "/" outside of containers.
+func New(root string, interval, retention time.Duration) *Sampler {
+	return &Sampler{
+		root:      root,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Run samples the host every interval until ctx is cancelled. Hosts without
+// procfs are not sampled.
+func (s *Sampler) Run(ctx context.Context) {
+	prev, err := s.read()
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Host resources will not be sampled")
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cur, err := s.read()
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to sample host resources")
+			continue
+		}
+
+		sample := cur.usage(prev)
+		prev = cur
+
+		s.mu.Lock()
+		s.samples = append(s.samples, sample)
+		expired := 0
+		for expired < len(s.samples) && sample.Time.Sub(s.samples[expired].Time) > s.retention {
+			expired++
+		}
+		s.samples = s.samples[expired:]
+		s.mu.Unlock()
+	}
+}
+
+// Since returns the samples taken after a time, in the order they were taken.
+func (s *Sampler) Since(t time.Time) []metadata.HostSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var samples []metadata.HostSample
+	for _, sample := range s.samples {
+		if sample.Time.After(t) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// counters are the cumulative counters of the host's resources at a time.
+type counters struct {
+	time time.Time
+
+	cpuBusy  uint64
+	cpuTotal uint64
+
+	memory uint64
+
+	diskRead  uint64
+	diskWrite uint64
+
+	networkReceive  uint64
+	networkTransmit uint64
+}
+
+// usage returns the utilization of the host between prev and c.
+func (c counters) usage(prev counters) metadata.HostSample {
+	sample := metadata.HostSample{Time: c.time}
+	sample.Memory = c.memory
+
+	if c.cpuTotal > prev.cpuTotal && c.cpuBusy >= prev.cpuBusy {
+		total := c.cpuTotal - prev.cpuTotal
+		sample.CPU = float64(c.cpuBusy-prev.cpuBusy) / float64(total)
+	}
+
+	seconds := c.time.Sub(prev.time).Seconds()
+	if seconds <= 0 {
+		return sample
+	}
+	sample.DiskRead = rate(c.diskRead, prev.diskRead, seconds)
+	sample.DiskWrite = rate(c.diskWrite, prev.diskWrite, seconds)
+	sample.NetworkReceive = rate(c.networkReceive, prev.networkReceive, seconds)
+	sample.NetworkTransmit = rate(c.networkTransmit, prev.networkTransmit, seconds)
+	return sample
+}
+
+// rate returns the per second increase of a counter, or zero if it went
+// backwards because a device was removed.
+func rate(cur, prev uint64, seconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+func (s *Sampler) read() (counters, error) {
+	c := counters{time: time.Now()}
+	for _, read := range []struct {
+		path  string
+		parse func(io.Reader, *counters) error
+	}{
+		{"proc/stat", parseStat},
+		{"proc/meminfo", parseMeminfo},
+		{"proc/diskstats", s.parseDiskstats},
+		{"proc/net/dev", parseNetDev},
+	} {
+		path := filepath.Join(s.root, read.path)
+		f, err := os.Open(path)
+		if err != nil {
+			return c, err
+		}
+
+		err = read.parse(f, &c)
+		f.Close()
+		if err != nil {
+			return c, errors.Wrapf(err, "failed to parse %q", path)
+		}
+	}
+	return c, nil
+}
+
+// parseStat reads the time the CPUs spent busy and in total from the
+// aggregate cpu line of /proc/stat. Guest time is already counted as user
+// time, and time waiting for IO is counted as idle.
+func parseStat(r io.Reader, c *counters) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 9 || fields[0] != "cpu" {
+			continue
+		}
+
+		var ticks [8]uint64
+		for i := range ticks {
+			n, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return err
+			}
+			ticks[i] = n
+		}
+
+		for _, n := range ticks {
+			c.cpuTotal += n
+		}
+		idle := ticks[3] + ticks[4]
+		c.cpuBusy = c.cpuTotal - idle
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("no cpu line")
+}
+
+// parseMeminfo reads the memory in use from /proc/meminfo, which is the
+// memory that is not available to be allocated without swapping.
+func parseMeminfo(r io.Reader, c *counters) error {
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) == 3 && fields[2] == "kB" {
+			n *= 1024
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok {
+		return fmt.Errorf("no MemTotal")
+	}
+	available, ok := values["MemAvailable"]
+	if !ok {
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	if available < total {
+		c.memory = total - available
+	}
+	return nil
+}
+
+// parseDiskstats reads the bytes read and written by block devices from
+// /proc/diskstats. Partitions and virtual devices are skipped, so that IO is
+// not counted twice or counted when it never reaches a disk.
+func (s *Sampler) parseDiskstats(r io.Reader, c *counters) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		name := fields[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(s.root, "sys/block", strings.Replace(name, "/", "!", -1)))
+		if err != nil {
+			continue
+		}
+
+		read, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return err
+		}
+		written, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return err
+		}
+		c.diskRead += read * sectorSize
+		c.diskWrite += written * sectorSize
+	}
+	return scanner.Err()
+}
+
+// parseNetDev reads the bytes received and transmitted by network interfaces
+// other than loopback from /proc/net/dev.
+func parseNetDev(r io.Reader, c *counters) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		iface := strings.TrimSpace(parts[0])
+		fields := strings.Fields(parts[1])
+		if iface == "lo" || len(fields) < 9 {
+			continue
+		}
+
+		received, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		transmitted, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return err
+		}
+		c.networkReceive += received
+		c.networkTransmit += transmitted
+	}
+	return scanner.Err()
+}
@@ -17,19 +17,29 @@ package labagent
 import (
 	"context"
 	"io"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/Netflix/p2plab/daemon"
 	"github.com/Netflix/p2plab/daemon/healthcheckrouter"
+	"github.com/Netflix/p2plab/daemon/metricsrouter"
 	"github.com/Netflix/p2plab/downloaders"
 	"github.com/Netflix/p2plab/labagent/agentrouter"
+	"github.com/Netflix/p2plab/labagent/hoststats"
+	"github.com/Netflix/p2plab/labagent/logbuffer"
 	"github.com/Netflix/p2plab/labagent/supervisor"
+	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/httputil"
+	"github.com/Netflix/p2plab/pkg/metrics"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
 type LabAgent struct {
 	daemon  *daemon.Daemon
+	host    *hoststats.Sampler
 	closers []io.Closer
 }
 
@@ -42,6 +52,12 @@ func New(root, addr, appRoot, appAddr string, logger *zerolog.Logger, opts ...La
 		}
 	}
 
+	// Lines logged by the labagent and labapp are kept so they can be read
+	// remotely with labctl node logs.
+	logs := logbuffer.New(logbuffer.DefaultSize)
+	agentLogger := logger.Output(io.MultiWriter(os.Stderr, logs.Writer(metadata.LogSourceLabagent)))
+	logger = &agentLogger
+
 	client, err := httputil.NewClient(httputil.NewHTTPClient(), httputil.WithLogger(logger))
 	if err != nil {
 		return nil, err
@@ -50,15 +66,19 @@ func New(root, addr, appRoot, appAddr string, logger *zerolog.Logger, opts ...La
 	settings.DownloaderSettings.Client = client
 	fs := downloaders.New(filepath.Join(root, "downloaders"), settings.DownloaderSettings)
 
-	s, err := supervisor.New(filepath.Join(root, "supervisor"), appRoot, appAddr, client, fs)
+	s, err := supervisor.New(filepath.Join(root, "supervisor"), appRoot, appAddr, client, fs, logs.Writer(metadata.LogSourceLabapp))
 	if err != nil {
 		return nil, err
 	}
 
+	reg := metrics.NewRegistry()
+	host := hoststats.New("/", hoststats.DefaultInterval, hoststats.DefaultRetention)
+
 	var closers []io.Closer
 	daemon, err := daemon.New("labagent", addr, logger,
-		healthcheckrouter.New(),
-		agentrouter.New(appAddr, s),
+		healthcheckrouter.New(checkBootstrap),
+		metricsrouter.New(reg),
+		agentrouter.New(appAddr, s, settings.NetworkInterface, host, logs, reg),
 	)
 	if err != nil {
 		return nil, err
@@ -67,6 +87,7 @@ func New(root, addr, appRoot, appAddr string, logger *zerolog.Logger, opts ...La
 
 	return &LabAgent{
 		daemon:  daemon,
+		host:    host,
 		closers: closers,
 	}, nil
 }
@@ -82,5 +103,19 @@ func (a *LabAgent) Close() error {
 }
 
 func (a *LabAgent) Serve(ctx context.Context) error {
+	go a.host.Run(ctx)
 	return a.daemon.Serve(ctx)
 }
+
+// checkBootstrap fails the health check if the node's bootstrap script failed,
+// so that the node is replaced rather than used as if it were bootstrapped.
+func checkBootstrap() error {
+	content, err := ioutil.ReadFile(metadata.BootstrapFailedPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return errors.Errorf("bootstrap failed: %s", strings.TrimSpace(string(content)))
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logbuffer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// DefaultSize is how many lines are kept.
+const DefaultSize = 10000
+
+// Buffer keeps the most recent lines logged by the daemons of a node, and
+// lets readers follow the lines logged after them.
+type Buffer struct {
+	size int
+
+	mu    sync.Mutex
+	lines []metadata.LogLine
+	added chan struct{}
+}
+
+// New returns a buffer that keeps the last size lines.
+func New(size int) *Buffer {
+	return &Buffer{
+		size:  size,
+		added: make(chan struct{}),
+	}
+}
+
+// Writer returns a writer that adds each line written to it to the buffer
+// as logged by source.
+func (b *Buffer) Writer(source metadata.LogSource) io.Writer {
+	return &writer{buffer: b, source: source}
+}
+
+// Since returns the lines logged after a time, oldest first.
+func (b *Buffer) Since(t time.Time) []metadata.LogLine {
+	lines, _ := b.since(t)
+	return lines
+}
+
+// Follow calls fn with the lines logged after a time, oldest first, and then
+// with each line as it is logged until ctx is cancelled or fn fails.
+func (b *Buffer) Follow(ctx context.Context, t time.Time, fn func(metadata.LogLine) error) error {
+	for {
+		lines, added := b.since(t)
+		for _, line := range lines {
+			err := fn(line)
+			if err != nil {
+				return err
+			}
+			t = line.Time
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-added:
+		}
+	}
+}
+
+func (b *Buffer) since(t time.Time) ([]metadata.LogLine, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines []metadata.LogLine
+	for _, line := range b.lines {
+		if line.Time.After(t) {
+			lines = append(lines, line)
+		}
+	}
+	return lines, b.added
+}
+
+func (b *Buffer) add(line metadata.LogLine) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Lines are ordered by time, so a line logged within the resolution of
+	// the clock is nudged after the last.
+	if n := len(b.lines); n > 0 && !line.Time.After(b.lines[n-1].Time) {
+		line.Time = b.lines[n-1].Time.Add(time.Nanosecond)
+	}
+
+	b.lines = append(b.lines, line)
+	if len(b.lines) > b.size {
+		b.lines = append(b.lines[:0], b.lines[len(b.lines)-b.size:]...)
+	}
+
+	// Wake up followers by closing the channel they wait on.
+	close(b.added)
+	b.added = make(chan struct{})
+}
+
+type writer struct {
+	buffer *Buffer
+	source metadata.LogSource
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.buffer.add(metadata.LogLine{
+			Source: w.source,
+			Time:   time.Now(),
+			Text:   string(bytes.TrimRight(w.partial[:i], "\r")),
+		})
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netem
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/logutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// unimpairedRate is the rate of traffic classes that are not capped, which
+	// is high enough to never be the bottleneck.
+	unimpairedRate = "100gbit"
+
+	// defaultClass is the traffic class for unimpaired traffic.
+	defaultClass = 1
+)
+
+// Apply replaces the netem rules on a network interface. Each rule gets its
+// own traffic class, and traffic is steered into it by destination address.
+// A rule without destinations impairs all traffic not matched by another rule.
+func Apply(ctx context.Context, iface string, rules []metadata.NetworkRule) error {
+	if iface == "" {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "labagent has no network interface configured for impairments")
+	}
+
+	// Deleting the root qdisc fails when there is none, which is the common
+	// case.
+	_ = tc(ctx, "qdisc", "del", "dev", iface, "root")
+	if len(rules) == 0 {
+		zerolog.Ctx(ctx).Debug().Str("iface", iface).Msg("Removed network impairments")
+		return nil
+	}
+
+	defaultClassID := defaultClass
+	for i, rule := range rules {
+		if len(rule.Destinations) == 0 {
+			defaultClassID = ruleClass(i)
+		}
+	}
+
+	err := tc(ctx, "qdisc", "add", "dev", iface, "root", "handle", "1:", "htb", "default", fmt.Sprintf("%x", defaultClassID))
+	if err != nil {
+		return err
+	}
+
+	err = tc(ctx, "class", "add", "dev", iface, "parent", "1:", "classid", classID(defaultClass), "htb", "rate", unimpairedRate)
+	if err != nil {
+		return err
+	}
+
+	for i, rule := range rules {
+		class := ruleClass(i)
+		err = tc(ctx, "class", "add", "dev", iface, "parent", "1:", "classid", classID(class), "htb", "rate", unimpairedRate)
+		if err != nil {
+			return err
+		}
+
+		args := []string{"qdisc", "add", "dev", iface, "parent", classID(class), "handle", fmt.Sprintf("%x:", class), "netem"}
+		args = append(args, netemArgs(rule.NetworkImpairment)...)
+		err = tc(ctx, args...)
+		if err != nil {
+			return err
+		}
+
+		for _, dst := range rule.Destinations {
+			ip := net.ParseIP(dst)
+			if ip == nil {
+				return errors.Wrapf(errdefs.ErrInvalidArgument, "invalid destination address %q", dst)
+			}
+
+			// Filters of different protocols cannot share a priority, so
+			// IPv6 destinations are matched at a priority of their own.
+			protocol, match, prefix, prio := "ip", "ip", 32, "1"
+			if ip.To4() == nil {
+				protocol, match, prefix, prio = "ipv6", "ip6", 128, "2"
+			}
+
+			err = tc(ctx, "filter", "add", "dev", iface, "protocol", protocol, "parent", "1:", "prio", prio,
+				"u32", "match", match, "dst", fmt.Sprintf("%s/%d", ip, prefix), "flowid", classID(class))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("iface", iface).Int("rules", len(rules)).Msg("Applied network impairments")
+	return nil
+}
+
+func netemArgs(impairment metadata.NetworkImpairment) []string {
+	var args []string
+	if impairment.Latency != "" {
+		args = append(args, "delay", impairment.Latency)
+		if impairment.Jitter != "" {
+			args = append(args, impairment.Jitter)
+		}
+	}
+	if impairment.Loss > 0 {
+		args = append(args, "loss", fmt.Sprintf("%g%%", impairment.Loss))
+	}
+	if impairment.Bandwidth != "" {
+		args = append(args, "rate", impairment.Bandwidth)
+	}
+	return args
+}
+
+// ruleClass returns the traffic class of the i-th rule, leaving room for the
+// default class.
+func ruleClass(i int) int {
+	return defaultClass + 1 + i
+}
+
+func classID(class int) string {
+	return fmt.Sprintf("1:%x", class)
+}
+
+func tc(ctx context.Context, args ...string) error {
+	logger := zerolog.Ctx(ctx).With().Str("exec", "tc").Logger()
+	logWriter := logutil.NewWriter(&logger, zerolog.DebugLevel)
+	defer logWriter.Close()
+
+	cmd := exec.CommandContext(ctx, "tc", args...)
+	cmd.Stdout = logWriter
+	cmd.Stderr = logWriter
+
+	err := cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "failed to run tc %s", strings.Join(args, " "))
+	}
+	return nil
+}
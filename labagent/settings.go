@@ -20,6 +20,10 @@ type LabagentOption func(*LabagentSettings) error
 
 type LabagentSettings struct {
 	DownloaderSettings downloaders.DownloaderSettings
+
+	// NetworkInterface is the interface that network impairments are applied
+	// to. Impairments are rejected if it is empty.
+	NetworkInterface string
 }
 
 func WithDownloaderSettings(settings downloaders.DownloaderSettings) LabagentOption {
@@ -28,3 +32,10 @@ func WithDownloaderSettings(settings downloaders.DownloaderSettings) LabagentOpt
 		return nil
 	}
 }
+
+func WithNetworkInterface(iface string) LabagentOption {
+	return func(s *LabagentSettings) error {
+		s.NetworkInterface = iface
+		return nil
+	}
+}
@@ -47,11 +47,14 @@ type supervisor struct {
 	appPort string
 	client  *httputil.Client
 	fs      *downloaders.Downloaders
+	logs    io.Writer
 	app     *exec.Cmd
 	cancel  func()
 }
 
-func New(root, appRoot, appAddr string, client *httputil.Client, fs *downloaders.Downloaders) (Supervisor, error) {
+// New returns a supervisor of the labapp, which also writes the labapp's
+// output to logs.
+func New(root, appRoot, appAddr string, client *httputil.Client, fs *downloaders.Downloaders, logs io.Writer) (Supervisor, error) {
 	err := os.MkdirAll(root, 0711)
 	if err != nil {
 		return nil, err
@@ -73,6 +76,7 @@ func New(root, appRoot, appAddr string, client *httputil.Client, fs *downloaders
 		appPort: appPort,
 		client:  client,
 		fs:      fs,
+		logs:    logs,
 	}, nil
 }
 
@@ -120,6 +124,24 @@ func (s *supervisor) peerDefinitionToFlags(id string, pdef metadata.PeerDefiniti
 	if pdef.Routing != "" {
 		flags = append(flags, fmt.Sprintf("--libp2p-routing=%s", pdef.Routing))
 	}
+	if pdef.NetworkStack != "" {
+		flags = append(flags, fmt.Sprintf("--libp2p-network-stack=%s", pdef.NetworkStack))
+	}
+	if pdef.BitswapNoProvide {
+		flags = append(flags, "--bitswap-no-provide")
+	}
+	if pdef.BitswapSearchDelay > 0 {
+		flags = append(flags, fmt.Sprintf("--bitswap-search-delay=%s", pdef.BitswapSearchDelay))
+	}
+	if pdef.BitswapTaskWorkers > 0 {
+		flags = append(flags, fmt.Sprintf("--bitswap-task-workers=%d", pdef.BitswapTaskWorkers))
+	}
+	if pdef.BitswapTrace {
+		flags = append(flags, "--bitswap-trace")
+	}
+	for _, collector := range pdef.Collectors {
+		flags = append(flags, fmt.Sprintf("--collectors=%s", collector))
+	}
 
 	return flags
 }
@@ -265,7 +287,7 @@ func (s *supervisor) atomicReplaceBinary(ctx context.Context, link string) error
 }
 
 func (s *supervisor) cmd(ctx context.Context, args ...string) *exec.Cmd {
-	return s.cmdWithStdio(ctx, os.Stdout, os.Stderr, args...)
+	return s.cmdWithStdio(ctx, io.MultiWriter(os.Stdout, s.logs), io.MultiWriter(os.Stderr, s.logs), args...)
 }
 
 func (s *supervisor) cmdWithStdio(ctx context.Context, stdout, stderr io.Writer, args ...string) *exec.Cmd {
@@ -19,6 +19,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/metadata"
@@ -79,6 +80,46 @@ func (a *api) Report(ctx context.Context) (metadata.ReportNode, error) {
 	return report, nil
 }
 
+func (a *api) Conns(ctx context.Context) ([]metadata.ReportPeerConn, error) {
+	var conns []metadata.ReportPeerConn
+
+	req := a.client.NewRequest("GET", a.url("/conns"))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&conns)
+	if err != nil {
+		return nil, err
+	}
+
+	return conns, nil
+}
+
+func (a *api) StartProfile(ctx context.Context, kind metadata.ProfileKind) error {
+	req := a.client.NewRequest("POST", a.url("/profiles/%s/start", kind))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+func (a *api) StopProfile(ctx context.Context, kind metadata.ProfileKind) ([]byte, error) {
+	req := a.client.NewRequest("POST", a.url("/profiles/%s/stop", kind))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
 func (a *api) Run(ctx context.Context, task metadata.Task) error {
 	content, err := json.MarshalIndent(&task, "", "    ")
 	if err != nil {
@@ -0,0 +1,159 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approuter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// ExecPolicy is what a node may run for an exec task.
+type ExecPolicy struct {
+	// Allow are the commands that may be run, by name or path as given in
+	// the task.
+	Allow []string
+
+	// Scripts is whether scripts retrieved from the cluster may be run.
+	Scripts bool
+}
+
+func (s *router) exec(ctx context.Context, task metadata.Task) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.exec")
+	defer span.Finish()
+	span.SetTag("command", task.Subject)
+	span.SetTag("script", task.Script)
+
+	name := task.Subject
+	if task.Script {
+		if !s.execPolicy.Scripts {
+			return errors.Wrap(errdefs.ErrInvalidArgument, "scripts are not allowed on this node")
+		}
+
+		script, err := s.fetchScript(ctx, task.Subject)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(script)
+		name = script
+	} else if !s.allowed(task.Subject) {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "command %q is not allowed on this node", task.Subject)
+	}
+
+	var (
+		stdout = &limitedBuffer{limit: metadata.MaxExecOutput}
+		stderr = &limitedBuffer{limit: 4096}
+	)
+	cmd := exec.CommandContext(ctx, name, task.Args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	result := metadata.ReportExec{
+		Command:  task.Subject,
+		Args:     task.Args,
+		ExitCode: -1,
+		Stdout:   stdout.String(),
+		Time:     time.Since(start),
+		Action:   traceutil.ActionID(ctx),
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	s.peer.RecordExec(result)
+
+	if err != nil {
+		return errors.Wrapf(err, "command %q failed: %s", task.Subject, strings.TrimSpace(stderr.String()))
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("command", task.Subject).Strs("args", task.Args).Dur("time", result.Time).Msg("Ran command")
+	return nil
+}
+
+func (s *router) allowed(command string) bool {
+	for _, allowed := range s.execPolicy.Allow {
+		if command == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchScript retrieves the script object with a given cid into an
+// executable temporary file, and returns its path.
+func (s *router) fetchScript(ctx context.Context, target string) (string, error) {
+	c, err := cid.Parse(target)
+	if err != nil {
+		return "", errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	nd, err := s.peer.Open(ctx, c, "")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to retrieve script %q", target)
+	}
+	defer nd.Close()
+
+	f, ok := nd.(files.File)
+	if !ok {
+		return "", errors.Wrapf(errdefs.ErrInvalidArgument, "script %q is not a file", target)
+	}
+
+	tmp, err := ioutil.TempFile("", "p2plab-exec")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	_, err = io.Copy(tmp, f)
+	if err == nil {
+		err = tmp.Chmod(0700)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+// limitedBuffer is a buffer that discards writes past its limit, without
+// failing them so that the command writing to it is unaffected.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if remaining := b.limit - b.Len(); remaining < len(p) {
+		p = p[:remaining]
+	}
+	b.Buffer.Write(p)
+	return n, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approuter
+
+import (
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/metrics"
+)
+
+// taskMetrics measures the tasks run by the node.
+type taskMetrics struct {
+	tasks    *metrics.Counter
+	failures *metrics.Counter
+	duration *metrics.Histogram
+}
+
+func newTaskMetrics(reg *metrics.Registry) *taskMetrics {
+	return &taskMetrics{
+		tasks:    reg.NewCounter("labapp_tasks_total", "Tasks run by the node.", "task"),
+		failures: reg.NewCounter("labapp_task_failures_total", "Tasks that failed.", "task"),
+		duration: reg.NewHistogram("labapp_task_duration_seconds", "Time taken to run each task, excluding its delay.", metrics.ExponentialBuckets(0.01, 2, 16), "task"),
+	}
+}
+
+// observe records a task that took d.
+func (m *taskMetrics) observe(typ metadata.TaskType, d time.Duration, err error) {
+	m.tasks.Inc(string(typ))
+	if err != nil {
+		m.failures.Inc(string(typ))
+	}
+	m.duration.Observe(d.Seconds(), string(typ))
+}
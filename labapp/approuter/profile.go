@@ -0,0 +1,92 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approuter
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// profiler captures the CPU profile of labapp, of which there can only be one
+// at a time.
+type profiler struct {
+	mu  sync.Mutex
+	cpu *bytes.Buffer
+}
+
+func (s *router) postProfileStart(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	switch metadata.ProfileKind(vars["kind"]) {
+	case metadata.ProfileCPU:
+		s.profiler.mu.Lock()
+		defer s.profiler.mu.Unlock()
+
+		// A profile left running by a benchmark that was aborted is
+		// discarded.
+		if s.profiler.cpu != nil {
+			zerolog.Ctx(ctx).Warn().Msg("Discarding CPU profile that was never stopped")
+			pprof.StopCPUProfile()
+			s.profiler.cpu = nil
+		}
+
+		buf := new(bytes.Buffer)
+		err := pprof.StartCPUProfile(buf)
+		if err != nil {
+			return errors.Wrap(err, "failed to start CPU profile")
+		}
+		s.profiler.cpu = buf
+		return nil
+	case metadata.ProfileHeap:
+		return nil
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized profile %q", vars["kind"])
+	}
+}
+
+func (s *router) postProfileStop(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	switch metadata.ProfileKind(vars["kind"]) {
+	case metadata.ProfileCPU:
+		s.profiler.mu.Lock()
+		defer s.profiler.mu.Unlock()
+
+		if s.profiler.cpu == nil {
+			return errors.Wrap(errdefs.ErrNotFound, "no CPU profile was started")
+		}
+		pprof.StopCPUProfile()
+		buf := s.profiler.cpu
+		s.profiler.cpu = nil
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, err := buf.WriteTo(w)
+		return err
+	case metadata.ProfileHeap:
+		// Collect garbage so that the profile is up to date with the
+		// allocations of the measured stages.
+		runtime.GC()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		return pprof.Lookup("heap").WriteTo(w, 0)
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized profile %q", vars["kind"])
+	}
+}
@@ -17,28 +17,42 @@ package approuter
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/daemon"
 	"github.com/Netflix/p2plab/errdefs"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/peer"
 	"github.com/Netflix/p2plab/pkg/logutil"
+	"github.com/Netflix/p2plab/pkg/metrics"
 	"github.com/Netflix/p2plab/pkg/traceutil"
 	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
 	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
 	multiaddr "github.com/multiformats/go-multiaddr"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
 )
 
 type router struct {
-	peer *peer.Peer
+	peer       *peer.Peer
+	execPolicy ExecPolicy
+	metrics    *taskMetrics
+	profiler   profiler
 }
 
-func New(p *peer.Peer) daemon.Router {
-	return &router{p}
+func New(p *peer.Peer, execPolicy ExecPolicy, reg *metrics.Registry) daemon.Router {
+	return &router{
+		peer:       p,
+		execPolicy: execPolicy,
+		metrics:    newTaskMetrics(reg),
+	}
 }
 
 func (s *router) Routes() []daemon.Route {
@@ -46,8 +60,13 @@ func (s *router) Routes() []daemon.Route {
 		// GET
 		daemon.NewGetRoute("/peerInfo", s.getPeerInfo),
 		daemon.NewGetRoute("/report", s.getReport),
+		daemon.NewGetRoute("/conns", s.getConns),
+		daemon.NewGetRoute("/ipfs/{cid}", s.getGateway),
+		daemon.NewGetRoute("/ipfs/{cid}/{path:.*}", s.getGateway),
 		// POST
 		daemon.NewPostRoute("/run", s.postRunTask),
+		daemon.NewPostRoute("/profiles/{kind}/start", s.postProfileStart),
+		daemon.NewPostRoute("/profiles/{kind}/stop", s.postProfileStop),
 	}
 }
 
@@ -67,6 +86,50 @@ func (s *router) getReport(ctx context.Context, w http.ResponseWriter, r *http.R
 	return daemon.WriteJSON(w, &report)
 }
 
+func (s *router) getConns(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	conns := s.peer.Conns()
+	return daemon.WriteJSON(w, &conns)
+}
+
+func (s *router) getGateway(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	c, err := cid.Parse(vars["cid"])
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	path, err := url.PathUnescape(vars["path"])
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	nd, err := s.peer.Open(ctx, c, path)
+	if err != nil {
+		return err
+	}
+	defer nd.Close()
+
+	switch f := nd.(type) {
+	case files.File:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, err = io.Copy(w, f)
+		return err
+	case files.Directory:
+		entries := []string{}
+		it := f.Entries()
+		for it.Next() {
+			entries = append(entries, it.Name())
+		}
+		if it.Err() != nil {
+			return it.Err()
+		}
+
+		w.Header().Set("Content-Type", peer.GatewayDirectoryContentType)
+		return daemon.WriteJSON(w, &entries)
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "unsupported file type %T", nd)
+	}
+}
+
 func (s *router) postRunTask(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	var task metadata.Task
 	err := json.NewDecoder(r.Body).Decode(&task)
@@ -79,9 +142,74 @@ func (s *router) postRunTask(ctx context.Context, w http.ResponseWriter, r *http
 		return c.Str("task", string(task.Type)).Str("subject", task.Subject)
 	})
 
+	if !task.StartAt.IsZero() {
+		wait := time.Until(task.StartAt)
+		if wait < 0 {
+			logger.Warn().Dur("late", -wait).Msg("Task arrived after its scheduled start")
+		} else {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+
+	if task.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(task.Delay):
+		}
+	}
+
+	// Bitswap messages are keyed by the action of the task while it runs.
+	if id := traceutil.ActionID(ctx); id != "" {
+		defer s.peer.StartAction(id)()
+	}
+
+	start := time.Now()
 	switch task.Type {
 	case metadata.TaskGet:
-		err = s.getFile(ctx, task.Subject)
+		err = s.getFiles(ctx, strings.Split(task.Subject, ","))
+	case metadata.TaskGetRange:
+		err = s.getRange(ctx, task.Subject, task.Path, task.Offset, task.Length)
+	case metadata.TaskGatewayGet:
+		err = s.gatewayGet(ctx, task.Gateway, task.Subject, task.Path)
+	case metadata.TaskMutate:
+		err = s.mutate(ctx, task)
+	case metadata.TaskFollow:
+		err = s.follow(ctx, task.Subject, task.Count, task.Timeout)
+	case metadata.TaskExec:
+		err = s.exec(ctx, task)
+	case metadata.TaskPin:
+		err = s.pin(ctx, task.Subject, task.PinType, task.Depth)
+	case metadata.TaskChurn:
+		err = s.churn(ctx, metadata.ChurnMode(task.Subject), task.Downtime)
+	case metadata.TaskPublish:
+		err = s.publish(ctx, task.Subject, task.Count, task.Size, task.Interval)
+	case metadata.TaskSubscribe:
+		err = s.subscribe(ctx, task.Subject, task.Count, task.Publishers, task.Timeout)
+	case metadata.TaskProvide:
+		err = s.provide(ctx, task.Subject)
+	case metadata.TaskFindProvs:
+		err = s.findProviders(ctx, task.Subject, task.Count, task.Timeout)
+	case metadata.TaskFindPeer:
+		ids := strings.Split(task.Subject, ",")
+		err = s.findPeers(ctx, ids, task.Timeout)
+	case metadata.TaskLoad:
+		err = s.load(ctx, task.Subject, task.Rate, task.Duration, task.Evict)
+	case metadata.TaskTraffic:
+		addrs := strings.Split(task.Subject, ",")
+		err = s.traffic(ctx, addrs, task.Count, task.Rate)
+	case metadata.TaskAdd:
+		err = s.add(ctx, task)
+	case metadata.TaskResetReport:
+		err = s.peer.ResetReport(ctx)
+	case metadata.TaskRestart:
+		err = s.restart(ctx, nil, task.Evict)
+	case metadata.TaskUpdateConfig:
+		err = s.restart(ctx, strings.Fields(task.Subject), false)
 	case metadata.TaskConnect:
 		addrs := strings.Split(task.Subject, ",")
 		err = s.connect(ctx, addrs)
@@ -94,6 +222,7 @@ func (s *router) postRunTask(ctx context.Context, w http.ResponseWriter, r *http
 	default:
 		return errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized task type: %q", task.Type)
 	}
+	s.metrics.observe(task.Type, time.Since(start), err)
 	if err != nil {
 		return err
 	}
@@ -101,6 +230,17 @@ func (s *router) postRunTask(ctx context.Context, w http.ResponseWriter, r *http
 	return nil
 }
 
+func (s *router) getFiles(ctx context.Context, targets []string) error {
+	eg, gctx := errgroup.WithContext(ctx)
+	for _, target := range targets {
+		target := target
+		eg.Go(func() error {
+			return s.getFile(gctx, target)
+		})
+	}
+	return eg.Wait()
+}
+
 func (s *router) getFile(ctx context.Context, target string) error {
 	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.getFile")
 	defer span.Finish()
@@ -120,6 +260,298 @@ func (s *router) getFile(ctx context.Context, target string) error {
 	return nil
 }
 
+func (s *router) getRange(ctx context.Context, target, path string, offset, length int64) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.getRange")
+	defer span.Finish()
+	span.SetTag("cid", target)
+	span.SetTag("path", path)
+	span.SetTag("offset", offset)
+	span.SetTag("length", length)
+
+	c, err := cid.Parse(target)
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	err = s.peer.GetRange(ctx, c, path, offset, length)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("cid", c.String()).Str("path", path).Int64("offset", offset).Int64("length", length).Msg("Retrieved file range")
+	return nil
+}
+
+func (s *router) gatewayGet(ctx context.Context, gateway, target, path string) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.gatewayGet")
+	defer span.Finish()
+	span.SetTag("gateway", gateway)
+	span.SetTag("cid", target)
+	span.SetTag("path", path)
+
+	c, err := cid.Parse(target)
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	err = s.peer.GatewayGet(ctx, gateway, c, path)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("gateway", gateway).Str("cid", c.String()).Str("path", path).Msg("Retrieved file through gateway")
+	return nil
+}
+
+func (s *router) pin(ctx context.Context, target string, pinType metadata.PinType, depth int) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.pin")
+	defer span.Finish()
+	span.SetTag("cid", target)
+	span.SetTag("type", string(pinType))
+	span.SetTag("depth", depth)
+
+	c, err := cid.Parse(target)
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	err = s.peer.Pin(ctx, c, pinType, depth)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("cid", c.String()).Str("type", string(pinType)).Int("depth", depth).Msg("Pinned file")
+	return nil
+}
+
+func (s *router) publish(ctx context.Context, topic string, count, size int, interval time.Duration) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.publish")
+	defer span.Finish()
+	span.SetTag("topic", topic)
+	span.SetTag("count", count)
+
+	err := s.peer.Publish(ctx, topic, count, size, interval)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("topic", topic).Int("count", count).Msg("Published messages")
+	return nil
+}
+
+func (s *router) subscribe(ctx context.Context, topic string, count, publishers int, timeout time.Duration) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.subscribe")
+	defer span.Finish()
+	span.SetTag("topic", topic)
+	span.SetTag("count", count)
+	span.SetTag("publishers", publishers)
+
+	err := s.peer.Subscribe(ctx, topic, count, publishers, timeout)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("topic", topic).Int("count", count).Msg("Received messages")
+	return nil
+}
+
+func (s *router) provide(ctx context.Context, target string) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.provide")
+	defer span.Finish()
+	span.SetTag("cid", target)
+
+	c, err := cid.Parse(target)
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	err = s.peer.Provide(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("cid", c.String()).Msg("Provided file")
+	return nil
+}
+
+func (s *router) findProviders(ctx context.Context, target string, count int, timeout time.Duration) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.findProviders")
+	defer span.Finish()
+	span.SetTag("cid", target)
+	span.SetTag("count", count)
+
+	c, err := cid.Parse(target)
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	infos, err := s.peer.FindProviders(ctx, c, count, timeout)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("cid", c.String()).Int("providers", len(infos)).Msg("Found providers")
+	return nil
+}
+
+func (s *router) findPeers(ctx context.Context, ids []string, timeout time.Duration) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.findPeers")
+	defer span.Finish()
+	span.SetTag("peers", len(ids))
+
+	for _, id := range ids {
+		pid, err := libp2ppeer.Decode(id)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+		}
+
+		_, err = s.peer.FindPeer(ctx, pid, timeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	zerolog.Ctx(ctx).Debug().Int("peers", len(ids)).Msg("Found peers")
+	return nil
+}
+
+func (s *router) load(ctx context.Context, target string, rate float64, duration time.Duration, evict bool) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.load")
+	defer span.Finish()
+	span.SetTag("cid", target)
+	span.SetTag("rate", rate)
+	span.SetTag("duration", duration.String())
+
+	c, err := cid.Parse(target)
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	err = s.peer.Load(ctx, c, rate, duration, evict)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("cid", c.String()).Float64("rate", rate).Dur("duration", duration).Msg("Retrieved file under load")
+	return nil
+}
+
+func (s *router) traffic(ctx context.Context, addrs []string, streams int, rate float64) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.traffic")
+	defer span.Finish()
+	span.SetTag("addrs", len(addrs))
+	span.SetTag("streams", streams)
+	span.SetTag("rate", rate)
+
+	infos, err := parseAddrs(addrs)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Int("peers", len(infos)).Int("streams", streams).Float64("rate", rate).Msg("Sending background traffic")
+	err = s.peer.Traffic(ctx, infos, streams, rate)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Int("peers", len(infos)).Msg("Stopped background traffic")
+	return nil
+}
+
+func (s *router) add(ctx context.Context, task metadata.Task) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.add")
+	defer span.Finish()
+	span.SetTag("count", task.Count)
+	span.SetTag("size", task.Size)
+
+	err := s.peer.Import(ctx, task.Count, int64(task.Size), addOptions(task)...)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Int("count", task.Count).Int("size", task.Size).Msg("Imported files")
+	return nil
+}
+
+func (s *router) mutate(ctx context.Context, task metadata.Task) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.mutate")
+	defer span.Finish()
+	span.SetTag("cid", task.Subject)
+	span.SetTag("path", task.Path)
+	span.SetTag("mutation", string(task.Mutation))
+	span.SetTag("size", task.Size)
+
+	c, err := cid.Parse(task.Subject)
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+	}
+
+	err = s.peer.Mutate(ctx, c, task.Path, task.Mutation, int64(task.Size), task.Topic, addOptions(task)...)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("cid", c.String()).Str("path", task.Path).Str("mutation", string(task.Mutation)).Str("topic", task.Topic).Msg("Mutated file")
+	return nil
+}
+
+func (s *router) follow(ctx context.Context, topic string, count int, timeout time.Duration) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.follow")
+	defer span.Finish()
+	span.SetTag("topic", topic)
+	span.SetTag("count", count)
+
+	err := s.peer.Follow(ctx, topic, count, timeout)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("topic", topic).Int("count", count).Msg("Followed updates")
+	return nil
+}
+
+// addOptions returns the options of a task for how files are converted into
+// DAGs.
+func addOptions(task metadata.Task) []p2plab.AddOption {
+	var opts []p2plab.AddOption
+	if task.Layout != "" {
+		opts = append(opts, p2plab.WithLayout(task.Layout))
+	}
+	if task.Chunker != "" {
+		opts = append(opts, p2plab.WithChunker(task.Chunker))
+	}
+	if task.RawLeaves {
+		opts = append(opts, p2plab.WithRawLeaves(true))
+	}
+	if task.HashFunc != "" {
+		opts = append(opts, p2plab.WithHashFunc(task.HashFunc))
+	}
+	if task.MaxLinks > 0 {
+		opts = append(opts, p2plab.WithMaxLinks(task.MaxLinks))
+	}
+	return opts
+}
+
+func (s *router) restart(ctx context.Context, settings []string, clear bool) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.restart")
+	defer span.Finish()
+	span.SetTag("settings", strings.Join(settings, " "))
+	span.SetTag("clear", clear)
+
+	pdef, err := metadata.UpdatePeerDefinition(s.peer.PeerDefinition(), settings)
+	if err != nil {
+		return err
+	}
+
+	err = s.peer.Restart(ctx, pdef, clear)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Strs("settings", settings).Bool("clear", clear).Msg("Restarted peer")
+	return nil
+}
+
 func (s *router) connect(ctx context.Context, addrs []string) error {
 	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.connect")
 	defer span.Finish()
@@ -181,6 +613,55 @@ func (s *router) disconnect(ctx context.Context, addrs []string) error {
 	return nil
 }
 
+func (s *router) churn(ctx context.Context, mode metadata.ChurnMode, downtime time.Duration) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "approuter.churn")
+	defer span.Finish()
+	span.SetTag("mode", string(mode))
+	span.SetTag("downtime", downtime.String())
+
+	h := s.peer.Host()
+	var infos []libp2ppeer.AddrInfo
+	for _, id := range h.Network().Peers() {
+		infos = append(infos, h.Peerstore().PeerInfo(id))
+	}
+
+	switch mode {
+	case metadata.ChurnDisconnect:
+		for _, info := range infos {
+			err := h.Network().ClosePeer(info.ID)
+			if err != nil {
+				return err
+			}
+		}
+	case metadata.ChurnKill:
+		err := s.peer.Disconnect(ctx, infos)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized churn mode %q", mode)
+	}
+	zerolog.Ctx(ctx).Debug().Int("peers", len(infos)).Msg("Left peers")
+
+	if downtime == 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(downtime):
+	}
+
+	err := s.peer.Connect(ctx, infos)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Int("peers", len(infos)).Msg("Rejoined peers")
+	return nil
+}
+
 func parseAddrs(addrs []string) ([]libp2ppeer.AddrInfo, error) {
 	var mas []multiaddr.Multiaddr
 	for _, addr := range addrs {
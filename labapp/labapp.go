@@ -18,10 +18,13 @@ import (
 	"context"
 	"io"
 
+	"github.com/Netflix/p2plab/collectors"
 	"github.com/Netflix/p2plab/daemon"
+	"github.com/Netflix/p2plab/daemon/metricsrouter"
 	"github.com/Netflix/p2plab/labapp/approuter"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/peer"
+	"github.com/Netflix/p2plab/pkg/metrics"
 	"github.com/rs/zerolog"
 )
 
@@ -31,7 +34,15 @@ type LabApp struct {
 	closers []io.Closer
 }
 
-func New(ctx context.Context, root, addr string, port int, logger *zerolog.Logger, pdef metadata.PeerDefinition) (*LabApp, error) {
+func New(ctx context.Context, root, addr string, port int, logger *zerolog.Logger, pdef metadata.PeerDefinition, opts ...LabappOption) (*LabApp, error) {
+	var settings LabappSettings
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var closers []io.Closer
 	pctx, cancel := context.WithCancel(ctx)
 	p, err := peer.New(pctx, root, port, pdef)
@@ -41,8 +52,24 @@ func New(ctx context.Context, root, addr string, port int, logger *zerolog.Logge
 	}
 	closers = append(closers, &daemon.CancelCloser{Cancel: cancel})
 
+	for _, spec := range pdef.Collectors {
+		c, err := collectors.New(root, spec, settings.ExecAllow)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		p.RegisterCollector(c)
+	}
+
+	reg := metrics.NewRegistry()
+	p.RegisterMetrics(reg, "labapp")
+
 	daemon, err := daemon.New("labapp", addr, logger,
-		approuter.New(p),
+		approuter.New(p, approuter.ExecPolicy{
+			Allow:   settings.ExecAllow,
+			Scripts: settings.ExecScripts,
+		}, reg),
+		metricsrouter.New(reg),
 	)
 	if err != nil {
 		return nil, err
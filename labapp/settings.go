@@ -0,0 +1,40 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labapp
+
+type LabappOption func(*LabappSettings) error
+
+type LabappSettings struct {
+	// ExecAllow are the commands that exec tasks may run.
+	ExecAllow []string
+
+	// ExecScripts is whether exec tasks may run scripts retrieved from the
+	// cluster.
+	ExecScripts bool
+}
+
+func WithExecAllow(commands []string) LabappOption {
+	return func(s *LabappSettings) error {
+		s.ExecAllow = commands
+		return nil
+	}
+}
+
+func WithExecScripts(scripts bool) LabappOption {
+	return func(s *LabappSettings) error {
+		s.ExecScripts = scripts
+		return nil
+	}
+}
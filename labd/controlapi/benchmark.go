@@ -15,8 +15,13 @@
 package controlapi
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strconv"
 	"strings"
 
 	"github.com/Netflix/p2plab"
@@ -24,8 +29,13 @@ import (
 	"github.com/Netflix/p2plab/pkg/httputil"
 	"github.com/Netflix/p2plab/pkg/logutil"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 )
 
+// attachRetries is how many times attaching to a benchmark is resumed after
+// its stream breaks without receiving any lines.
+const attachRetries = 5
+
 type benchmarkAPI struct {
 	client *httputil.Client
 	url    urlFunc
@@ -40,13 +50,22 @@ func (a *benchmarkAPI) Create(ctx context.Context, cluster, scenario string, opt
 		}
 	}
 
-	req := a.client.NewRequest("POST", a.url("/benchmarks/create"), httputil.WithRetryMax(0)).
+	req := a.client.NewRequest("POST", a.url("/benchmarks/create"), httputil.WithIdempotency()).
 		Option("cluster", cluster).
 		Option("scenario", scenario)
 
 	if settings.NoReset {
 		req.Option("no-reset", "true")
 	}
+	if settings.Replay != "" {
+		req.Option("replay", settings.Replay)
+	}
+	if len(settings.Labels) > 0 {
+		req.Option("labels", strings.Join(settings.Labels, ","))
+	}
+	if settings.Detach {
+		req.Option("detach", "true")
+	}
 
 	resp, err := req.Send(ctx)
 	if err != nil {
@@ -54,6 +73,11 @@ func (a *benchmarkAPI) Create(ctx context.Context, cluster, scenario string, opt
 	}
 	defer resp.Body.Close()
 
+	id = resp.Header.Get(ResourceID)
+	if settings.Started != nil {
+		settings.Started(id)
+	}
+
 	logWriter := logutil.LogWriter(ctx)
 	if logWriter != nil {
 		err = logutil.WriteRemoteLogs(ctx, resp.Body, logWriter)
@@ -62,7 +86,74 @@ func (a *benchmarkAPI) Create(ctx context.Context, cluster, scenario string, opt
 		}
 	}
 
-	return resp.Header.Get(ResourceID), nil
+	return id, nil
+}
+
+func (a *benchmarkAPI) Attach(ctx context.Context, id string) error {
+	var (
+		offset  int
+		retries int
+	)
+	for {
+		start := offset
+		done, err := a.attach(ctx, id, &offset)
+		if err != nil || done {
+			return err
+		}
+
+		if offset > start {
+			retries = 0
+		}
+		retries++
+		if retries > attachRetries {
+			return errors.Errorf("stream of benchmark %q broke %d times", id, attachRetries)
+		}
+		zerolog.Ctx(ctx).Debug().Int("offset", offset).Msgf("Resuming stream of benchmark %q", id)
+	}
+}
+
+// attach streams the logs of a benchmark from offset, advancing it for each
+// line received, and returns whether the stream ended rather than broke.
+func (a *benchmarkAPI) attach(ctx context.Context, id string, offset *int) (bool, error) {
+	req := a.client.NewRequest("GET", a.url("/benchmarks/%s/attach", id)).
+		Option("offset", strconv.Itoa(*offset))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if v := resp.Header.Get(StreamOffset); v != "" {
+		*offset, err = strconv.Atoi(v)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid stream offset %q", v)
+		}
+	}
+
+	logWriter := logutil.LogWriter(ctx)
+	rd := bufio.NewReader(resp.Body)
+	for {
+		// A partial line is sent again when the stream is resumed.
+		line, err := rd.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return true, nil
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if err != nil {
+			return false, nil
+		}
+		*offset++
+
+		if logWriter != nil {
+			err = logutil.WriteRemoteLogs(ctx, bytes.NewReader(line), logWriter)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
 }
 
 func (a *benchmarkAPI) Get(ctx context.Context, id string) (p2plab.Benchmark, error) {
@@ -82,6 +173,23 @@ func (a *benchmarkAPI) Get(ctx context.Context, id string) (p2plab.Benchmark, er
 	return &b, nil
 }
 
+func (a *benchmarkAPI) Status(ctx context.Context, id string) (metadata.BenchmarkProgress, error) {
+	var progress metadata.BenchmarkProgress
+	req := a.client.NewRequest("GET", a.url("/benchmarks/%s/status/json", id))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return progress, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&progress)
+	if err != nil {
+		return progress, err
+	}
+
+	return progress, nil
+}
+
 func (a *benchmarkAPI) Label(ctx context.Context, ids, adds, removes []string) ([]p2plab.Benchmark, error) {
 	req := a.client.NewRequest("PUT", a.url("/benchmarks/label")).
 		Option("ids", strings.Join(ids, ","))
@@ -168,6 +276,121 @@ func (a *benchmarkAPI) Remove(ctx context.Context, ids ...string) error {
 	return nil
 }
 
+func (a *benchmarkAPI) Cancel(ctx context.Context, id string) error {
+	req := a.client.NewRequest("PUT", a.url("/benchmarks/%s/cancel", id))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to cancel benchmark")
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+func (a *benchmarkAPI) Pin(ctx context.Context, id string) error {
+	req := a.client.NewRequest("PUT", a.url("/benchmarks/%s/pin", id))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to pin benchmark")
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+func (a *benchmarkAPI) Unpin(ctx context.Context, id string) error {
+	req := a.client.NewRequest("PUT", a.url("/benchmarks/%s/unpin", id))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to unpin benchmark")
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+func (a *benchmarkAPI) Publish(ctx context.Context, id string) (p2plab.Benchmark, error) {
+	req := a.client.NewRequest("PUT", a.url("/benchmarks/%s/publish", id))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to publish benchmark")
+	}
+	defer resp.Body.Close()
+
+	b := benchmark{client: a.client, url: a.url}
+	err = json.NewDecoder(resp.Body).Decode(&b.metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (a *benchmarkAPI) Compare(ctx context.Context, base, head string, opts ...p2plab.CompareOption) (metadata.ReportComparison, error) {
+	var (
+		settings   p2plab.CompareSettings
+		comparison metadata.ReportComparison
+	)
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return comparison, err
+		}
+	}
+
+	req := a.client.NewRequest("GET", a.url("/benchmarks/%s/compare/%s/json", base, head))
+	if settings.Significance != 0 {
+		req.Option("significance", strconv.FormatFloat(settings.Significance, 'g', -1, 64))
+	}
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return comparison, errors.Wrap(err, "failed to compare benchmarks")
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&comparison)
+	if err != nil {
+		return comparison, err
+	}
+
+	return comparison, nil
+}
+
+func (a *benchmarkAPI) Diff(ctx context.Context, base, head string, opts ...p2plab.DiffOption) (metadata.ReportDiff, error) {
+	var (
+		settings p2plab.DiffSettings
+		diff     metadata.ReportDiff
+	)
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return diff, err
+		}
+	}
+
+	req := a.client.NewRequest("GET", a.url("/benchmarks/%s/diff/%s/json", base, head))
+	if settings.By != "" {
+		req.Option("by", string(settings.By))
+	}
+	if settings.Threshold != 0 {
+		req.Option("threshold", strconv.FormatFloat(settings.Threshold, 'g', -1, 64))
+	}
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return diff, errors.Wrap(err, "failed to diff benchmarks")
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&diff)
+	if err != nil {
+		return diff, err
+	}
+
+	return diff, nil
+}
+
 type benchmark struct {
 	client   *httputil.Client
 	metadata metadata.Benchmark
@@ -203,3 +426,32 @@ func (b *benchmark) Report(ctx context.Context) (metadata.Report, error) {
 
 	return report, nil
 }
+
+func (b *benchmark) Trace(ctx context.Context) (metadata.Trace, error) {
+	var trace metadata.Trace
+
+	req := b.client.NewRequest("GET", b.url("/benchmarks/%s/trace/json", b.metadata.ID))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return trace, errors.Wrap(err, "failed to get trace")
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&trace)
+	if err != nil {
+		return trace, err
+	}
+
+	return trace, nil
+}
+
+func (b *benchmark) Profile(ctx context.Context, node string, kind metadata.ProfileKind) ([]byte, error) {
+	req := b.client.NewRequest("GET", b.url("/benchmarks/%s/profiles/%s/%s", b.metadata.ID, node, kind))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get profile")
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
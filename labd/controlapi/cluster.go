@@ -18,11 +18,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"os"
+	"io/ioutil"
 	"strings"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/defutil"
 	"github.com/Netflix/p2plab/pkg/httputil"
 	"github.com/Netflix/p2plab/pkg/logutil"
 	"github.com/pkg/errors"
@@ -47,59 +48,142 @@ func (a *clusterAPI) Create(ctx context.Context, name string, opts ...p2plab.Cre
 		return "", err
 	}
 
-	var cdef metadata.ClusterDefinition
-	if settings.Definition != "" {
-		f, err := os.Open(settings.Definition)
+	cdef, err := newClusterDefinition(settings)
+	if err != nil {
+		return id, err
+	}
+
+	content, err := json.MarshalIndent(&cdef, "", "    ")
+	if err != nil {
+		return id, err
+	}
+
+	req := a.client.NewRequest("POST", a.url("/clusters/create"), httputil.WithIdempotency()).
+		Option("name", name).
+		Body(bytes.NewReader(content))
+	if settings.Detach {
+		req.Option("detach", "true")
+	}
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return id, err
+	}
+	defer resp.Body.Close()
+
+	id = resp.Header.Get(ResourceID)
+	if settings.Started != nil {
+		settings.Started(id)
+	}
+
+	logWriter := logutil.LogWriter(ctx)
+	if logWriter != nil {
+		err = logutil.WriteRemoteLogs(ctx, resp.Body, logWriter)
 		if err != nil {
 			return id, err
 		}
-		defer f.Close()
+	}
 
-		err = json.NewDecoder(f).Decode(&cdef)
+	return id, nil
+}
+
+func (a *clusterAPI) Plan(ctx context.Context, name string, opts ...p2plab.CreateClusterOption) (p2plab.NodeGroupPlan, error) {
+	var (
+		settings p2plab.CreateClusterSettings
+		plan     p2plab.NodeGroupPlan
+	)
+	for _, opt := range opts {
+		err := opt(&settings)
 		if err != nil {
-			return id, err
+			return plan, err
 		}
+	}
 
-		for i, group := range cdef.Groups {
-			if group.Peer == nil {
-				cdef.Groups[i].Peer = &metadata.DefaultPeerDefinition
-			}
-		}
-	} else {
-		cdef.Groups = append(cdef.Groups, metadata.ClusterGroup{
-			Size:         settings.Size,
-			InstanceType: settings.InstanceType,
-			Region:       settings.Region,
-			Peer:         &metadata.DefaultPeerDefinition,
-		})
+	err := metadata.ValidateClusterID(name)
+	if err != nil {
+		return plan, err
+	}
+
+	cdef, err := newClusterDefinition(settings)
+	if err != nil {
+		return plan, err
 	}
 
 	content, err := json.MarshalIndent(&cdef, "", "    ")
 	if err != nil {
-		return id, err
+		return plan, err
 	}
 
-	req := a.client.NewRequest("POST", a.url("/clusters/create"), httputil.WithRetryMax(0)).
+	req := a.client.NewRequest("POST", a.url("/clusters/plan"), httputil.WithRetryMax(0)).
 		Option("name", name).
 		Body(bytes.NewReader(content))
 
 	resp, err := req.Send(ctx)
 	if err != nil {
-		return id, err
+		return plan, err
 	}
 	defer resp.Body.Close()
 
-	logWriter := logutil.LogWriter(ctx)
-	if logWriter != nil {
-		err = logutil.WriteRemoteLogs(ctx, resp.Body, logWriter)
+	err = json.NewDecoder(resp.Body).Decode(&plan)
+	if err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
+
+func (a *clusterAPI) Checkout(ctx context.Context, name string, opts ...p2plab.CreateClusterOption) (id string, err error) {
+	var settings p2plab.CreateClusterSettings
+	for _, opt := range opts {
+		err = opt(&settings)
 		if err != nil {
 			return id, err
 		}
 	}
 
+	if name != "" {
+		err = metadata.ValidateClusterID(name)
+		if err != nil {
+			return id, err
+		}
+	}
+
+	cdef, err := newClusterDefinition(settings)
+	if err != nil {
+		return id, err
+	}
+
+	content, err := json.MarshalIndent(&cdef, "", "    ")
+	if err != nil {
+		return id, err
+	}
+
+	req := a.client.NewRequest("POST", a.url("/clusters/checkout"), httputil.WithIdempotency()).
+		Option("name", name).
+		Body(bytes.NewReader(content))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return id, err
+	}
+	defer resp.Body.Close()
+
 	return resp.Header.Get(ResourceID), nil
 }
 
+func (a *clusterAPI) Return(ctx context.Context, names ...string) error {
+	req := a.client.NewRequest("PUT", a.url("/clusters/return")).
+		Option("names", strings.Join(names, ","))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to return clusters")
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
 func (a *clusterAPI) Get(ctx context.Context, name string) (p2plab.Cluster, error) {
 	req := a.client.NewRequest("GET", a.url("/clusters/%s/json", name))
 	resp, err := req.Send(ctx)
@@ -116,10 +200,21 @@ func (a *clusterAPI) Get(ctx context.Context, name string) (p2plab.Cluster, erro
 	return &c, nil
 }
 
-func (a *clusterAPI) Label(ctx context.Context, names, adds, removes []string) ([]p2plab.Cluster, error) {
+func (a *clusterAPI) Label(ctx context.Context, names, adds, removes []string, opts ...p2plab.ListOption) ([]p2plab.Cluster, error) {
+	var settings p2plab.ListSettings
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	req := a.client.NewRequest("PUT", a.url("/clusters/label")).
 		Option("names", strings.Join(names, ","))
 
+	if settings.Query != "" {
+		req.Option("query", settings.Query)
+	}
 	if len(adds) > 0 {
 		req.Option("adds", strings.Join(adds, ","))
 	}
@@ -189,6 +284,99 @@ func (a *clusterAPI) List(ctx context.Context, opts ...p2plab.ListOption) ([]p2p
 	return clusters, nil
 }
 
+func (a *clusterAPI) Pause(ctx context.Context, names ...string) error {
+	return a.putClusters(ctx, "/clusters/pause", names)
+}
+
+func (a *clusterAPI) Resume(ctx context.Context, names ...string) error {
+	return a.putClusters(ctx, "/clusters/resume", names)
+}
+
+func (a *clusterAPI) putClusters(ctx context.Context, endpoint string, names []string) error {
+	req := a.client.NewRequest("PUT", a.url(endpoint), httputil.WithRetryMax(0)).
+		Option("names", strings.Join(names, ","))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	logWriter := logutil.LogWriter(ctx)
+	if logWriter != nil {
+		err = logutil.WriteRemoteLogs(ctx, resp.Body, logWriter)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *clusterAPI) Cost(ctx context.Context) (metadata.CostReport, error) {
+	var report metadata.CostReport
+	req := a.client.NewRequest("GET", a.url("/clusters/cost"))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+func (a *clusterAPI) Health(ctx context.Context, name string) (metadata.ClusterHealth, error) {
+	var health metadata.ClusterHealth
+	req := a.client.NewRequest("GET", a.url("/clusters/%s/health", name))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return health, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	if err != nil {
+		return health, err
+	}
+
+	return health, nil
+}
+
+func newClusterDefinition(settings p2plab.CreateClusterSettings) (metadata.ClusterDefinition, error) {
+	var cdef metadata.ClusterDefinition
+	if settings.Definition != "" {
+		var err error
+		cdef, err = ReadClusterDefinition(settings.Definition)
+		if err != nil {
+			return cdef, err
+		}
+	} else if len(settings.ClusterDefinition.Groups) > 0 {
+		cdef = settings.ClusterDefinition
+	} else {
+		cdef.Groups = append(cdef.Groups, metadata.ClusterGroup{
+			Size:         settings.Size,
+			InstanceType: settings.InstanceType,
+			Region:       settings.Region,
+			Image:        settings.Image,
+			Peer:         &metadata.DefaultPeerDefinition,
+		})
+	}
+
+	if settings.Retries != 0 {
+		cdef.Retries = settings.Retries
+	}
+	if settings.Tolerance != 0 {
+		cdef.Tolerance = settings.Tolerance
+	}
+
+	return cdef, nil
+}
+
 type Event struct {
 }
 
@@ -271,3 +459,26 @@ func (c *cluster) Update(ctx context.Context, pdef metadata.PeerDefinition, opts
 
 	return ns, nil
 }
+
+// ReadClusterDefinition reads a cluster definition from a JSON or YAML file.
+// Groups without a peer definition are given the default peer definition.
+func ReadClusterDefinition(filename string) (metadata.ClusterDefinition, error) {
+	var cdef metadata.ClusterDefinition
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return cdef, err
+	}
+
+	err = defutil.Decode(filename, content, &cdef)
+	if err != nil {
+		return cdef, err
+	}
+
+	for i, group := range cdef.Groups {
+		if group.Peer == nil {
+			cdef.Groups[i].Peer = &metadata.DefaultPeerDefinition
+		}
+	}
+
+	return cdef, nil
+}
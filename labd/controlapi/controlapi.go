@@ -23,6 +23,9 @@ import (
 
 const (
 	ResourceID = "ResourceID"
+
+	// StreamOffset is the offset of the first line of a resumable stream.
+	StreamOffset = "StreamOffset"
 )
 
 type api struct {
@@ -66,3 +69,7 @@ func (a *api) Experiment() p2plab.ExperimentAPI {
 func (a *api) Build() p2plab.BuildAPI {
 	return &buildAPI{a.client, a.url}
 }
+
+func (a *api) Schedule() p2plab.ScheduleAPI {
+	return &scheduleAPI{a.client, a.url}
+}
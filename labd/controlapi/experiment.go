@@ -23,6 +23,7 @@ import (
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/httputil"
+	"github.com/Netflix/p2plab/pkg/logutil"
 	"github.com/pkg/errors"
 )
 
@@ -37,7 +38,7 @@ func (a *experimentAPI) Create(ctx context.Context, id string, edef metadata.Exp
 		return nil, err
 	}
 
-	req := a.client.NewRequest("POST", a.url("/experiments/create"), httputil.WithRetryMax(0)).
+	req := a.client.NewRequest("POST", a.url("/experiments/create"), httputil.WithIdempotency()).
 		Option("id", id).
 		Body(bytes.NewReader(content))
 
@@ -47,13 +48,15 @@ func (a *experimentAPI) Create(ctx context.Context, id string, edef metadata.Exp
 	}
 	defer resp.Body.Close()
 
-	e := experiment{client: a.client}
-	err = json.NewDecoder(resp.Body).Decode(&e.metadata)
-	if err != nil {
-		return nil, err
+	logWriter := logutil.LogWriter(ctx)
+	if logWriter != nil {
+		err = logutil.WriteRemoteLogs(ctx, resp.Body, logWriter)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return &e, nil
+	return a.Get(ctx, resp.Header.Get(ResourceID))
 }
 
 func (a *experimentAPI) Get(ctx context.Context, id string) (p2plab.Experiment, error) {
@@ -73,6 +76,23 @@ func (a *experimentAPI) Get(ctx context.Context, id string) (p2plab.Experiment,
 	return &e, nil
 }
 
+func (a *experimentAPI) Report(ctx context.Context, id string) (metadata.ExperimentReport, error) {
+	var report metadata.ExperimentReport
+	req := a.client.NewRequest("GET", a.url("/experiments/%s/report/json", id))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
 func (a *experimentAPI) Label(ctx context.Context, ids, adds, removes []string) ([]p2plab.Experiment, error) {
 	req := a.client.NewRequest("PUT", a.url("/experiments/label")).
 		Option("ids", strings.Join(ids, ","))
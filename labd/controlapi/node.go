@@ -18,13 +18,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/labagent/agentapi"
 	"github.com/Netflix/p2plab/labapp/appapi"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/httputil"
+	"github.com/pkg/errors"
 )
 
 type nodeAPI struct {
@@ -49,10 +55,21 @@ func (a *nodeAPI) Get(ctx context.Context, cluster, id string) (p2plab.Node, err
 	return NewNode(a.client, m), nil
 }
 
-func (a *nodeAPI) Label(ctx context.Context, cluster string, ids, adds, removes []string) ([]p2plab.Node, error) {
+func (a *nodeAPI) Label(ctx context.Context, cluster string, ids, adds, removes []string, opts ...p2plab.ListOption) ([]p2plab.Node, error) {
+	var settings p2plab.ListSettings
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	req := a.client.NewRequest("PUT", a.url("/clusters/%s/nodes/label", cluster)).
 		Option("ids", strings.Join(ids, ","))
 
+	if settings.Query != "" {
+		req.Option("query", settings.Query)
+	}
 	if len(adds) > 0 {
 		req.Option("adds", strings.Join(adds, ","))
 	}
@@ -114,6 +131,67 @@ func (a *nodeAPI) List(ctx context.Context, cluster string, opts ...p2plab.ListO
 	return ns, nil
 }
 
+func (a *nodeAPI) Explain(ctx context.Context, cluster, q string, opts ...p2plab.ExplainOption) (metadata.QueryExplanation, error) {
+	var (
+		settings    p2plab.ExplainSettings
+		explanation metadata.QueryExplanation
+	)
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return explanation, err
+		}
+	}
+
+	req := a.client.NewRequest("GET", a.url("/clusters/%s/nodes/explain", cluster)).
+		Option("query", q)
+	if settings.Scenario != "" {
+		req.Option("scenario", settings.Scenario)
+	}
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return explanation, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&explanation)
+	if err != nil {
+		return explanation, err
+	}
+
+	return explanation, nil
+}
+
+func (a *nodeAPI) Logs(ctx context.Context, cluster string, fn func(metadata.LogLine) error, opts ...p2plab.LogsOption) error {
+	var settings p2plab.LogsSettings
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := a.client.NewRequest("GET", a.url("/clusters/%s/nodes/logs", cluster))
+	if settings.Query != "" {
+		req.Option("query", settings.Query)
+	}
+	if !settings.Since.IsZero() {
+		req.Option("since", settings.Since.Format(time.RFC3339Nano))
+	}
+	if settings.Follow {
+		req.Option("follow", "true")
+	}
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return agentapi.DecodeLogs(ctx, resp.Body, fn)
+}
+
 type node struct {
 	p2plab.AgentAPI
 	p2plab.AppAPI
@@ -122,8 +200,8 @@ type node struct {
 
 func NewNode(client *httputil.Client, m metadata.Node) p2plab.Node {
 	return &node{
-		AgentAPI: agentapi.New(client, fmt.Sprintf("http://%s:%d", m.Address, m.AgentPort)),
-		AppAPI:   appapi.New(client, fmt.Sprintf("http://%s:%d", m.Address, m.AppPort)),
+		AgentAPI: agentapi.New(client, fmt.Sprintf("http://%s", net.JoinHostPort(m.Address, strconv.Itoa(m.AgentPort)))),
+		AppAPI:   appapi.New(client, fmt.Sprintf("http://%s", net.JoinHostPort(m.Address, strconv.Itoa(m.AppPort)))),
 		metadata: m,
 	}
 }
@@ -136,6 +214,64 @@ func (n *node) Labels() []string {
 	return n.metadata.Labels
 }
 
+func (n *node) Attributes() map[string]string {
+	return n.metadata.Attributes()
+}
+
 func (n *node) Metadata() metadata.Node {
 	return n.metadata
 }
+
+// SSH opens an interactive shell on the node, or forwards a local port to it.
+// Nodes that are EC2 instances are reached through AWS Systems Manager with
+// the caller's AWS credentials, so they need neither a key pair nor an open
+// SSH port. Other nodes are reached with the ssh client at their address.
+func (n *node) SSH(ctx context.Context, opts ...p2plab.SSHOption) error {
+	var settings p2plab.SSHSettings
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	var cmd *exec.Cmd
+	if strings.HasPrefix(n.metadata.ID, "i-") && n.metadata.Region != "" {
+		cmd = n.ssmCommand(ctx, settings)
+	} else {
+		cmd = n.sshCommand(ctx, settings)
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to node %q", n.metadata.ID)
+	}
+
+	return nil
+}
+
+func (n *node) ssmCommand(ctx context.Context, settings p2plab.SSHSettings) *exec.Cmd {
+	args := []string{"ssm", "start-session",
+		"--target", n.metadata.ID,
+		"--region", n.metadata.Region,
+	}
+	if settings.RemotePort > 0 {
+		args = append(args,
+			"--document-name", "AWS-StartPortForwardingSession",
+			"--parameters", fmt.Sprintf("portNumber=%d,localPortNumber=%d", settings.RemotePort, settings.LocalPort),
+		)
+	}
+	return exec.CommandContext(ctx, "aws", args...)
+}
+
+func (n *node) sshCommand(ctx context.Context, settings p2plab.SSHSettings) *exec.Cmd {
+	var args []string
+	if settings.RemotePort > 0 {
+		args = append(args, "-N", "-L", fmt.Sprintf("%d:localhost:%d", settings.LocalPort, settings.RemotePort))
+	}
+	args = append(args, n.metadata.Address)
+	return exec.CommandContext(ctx, "ssh", args...)
+}
@@ -37,7 +37,7 @@ func (a *scenarioAPI) Create(ctx context.Context, name string, sdef metadata.Sce
 		return nil, err
 	}
 
-	req := a.client.NewRequest("POST", a.url("/scenarios/create")).
+	req := a.client.NewRequest("POST", a.url("/scenarios/create"), httputil.WithIdempotency()).
 		Option("name", name).
 		Body(bytes.NewReader(content))
 
@@ -157,6 +157,30 @@ func (a *scenarioAPI) Remove(ctx context.Context, names ...string) error {
 	return nil
 }
 
+func (a *scenarioAPI) Validate(ctx context.Context, sdef metadata.ScenarioDefinition) ([]metadata.Diagnostic, error) {
+	content, err := json.MarshalIndent(&sdef, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	req := a.client.NewRequest("POST", a.url("/scenarios/validate")).
+		Body(bytes.NewReader(content))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to validate scenario")
+	}
+	defer resp.Body.Close()
+
+	var diagnostics []metadata.Diagnostic
+	err = json.NewDecoder(resp.Body).Decode(&diagnostics)
+	if err != nil {
+		return nil, err
+	}
+
+	return diagnostics, nil
+}
+
 type scenario struct {
 	client   *httputil.Client
 	metadata metadata.Scenario
@@ -0,0 +1,140 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controlapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/httputil"
+	"github.com/Netflix/p2plab/pkg/logutil"
+	"github.com/pkg/errors"
+)
+
+type scheduleAPI struct {
+	client *httputil.Client
+	url    urlFunc
+}
+
+func (a *scheduleAPI) Create(ctx context.Context, name string, sdef metadata.ScheduleDefinition) (p2plab.Schedule, error) {
+	content, err := json.MarshalIndent(&sdef, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	req := a.client.NewRequest("POST", a.url("/schedules/create"), httputil.WithIdempotency()).
+		Option("name", name).
+		Body(bytes.NewReader(content))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var s schedule
+	err = json.NewDecoder(resp.Body).Decode(&s.metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (a *scheduleAPI) Get(ctx context.Context, name string) (p2plab.Schedule, error) {
+	req := a.client.NewRequest("GET", a.url("/schedules/%s/json", name))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var s schedule
+	err = json.NewDecoder(resp.Body).Decode(&s.metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (a *scheduleAPI) List(ctx context.Context) ([]p2plab.Schedule, error) {
+	req := a.client.NewRequest("GET", a.url("/schedules/json"))
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var metadatas []metadata.Schedule
+	err = json.NewDecoder(resp.Body).Decode(&metadatas)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []p2plab.Schedule
+	for _, m := range metadatas {
+		schedules = append(schedules, &schedule{metadata: m})
+	}
+
+	return schedules, nil
+}
+
+func (a *scheduleAPI) Remove(ctx context.Context, names ...string) error {
+	req := a.client.NewRequest("DELETE", a.url("/schedules/delete")).
+		Option("names", strings.Join(names, ","))
+
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to remove schedules")
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+func (a *scheduleAPI) Run(ctx context.Context, name string) (id string, err error) {
+	req := a.client.NewRequest("PUT", a.url("/schedules/%s/run", name), httputil.WithIdempotency())
+	resp, err := req.Send(ctx)
+	if err != nil {
+		return id, err
+	}
+	defer resp.Body.Close()
+
+	// The benchmark ID is a trailer, so the logs must be read before it.
+	logWriter := logutil.LogWriter(ctx)
+	if logWriter == nil {
+		logWriter = ioutil.Discard
+	}
+	err = logutil.WriteRemoteLogs(ctx, resp.Body, logWriter)
+	if err != nil {
+		return id, err
+	}
+
+	return resp.Trailer.Get(ResourceID), nil
+}
+
+type schedule struct {
+	metadata metadata.Schedule
+}
+
+func (s *schedule) Metadata() metadata.Schedule {
+	return s.metadata
+}
@@ -17,23 +17,35 @@ package labd
 import (
 	"context"
 	"io"
+	"net"
 	"path/filepath"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/builder"
 	"github.com/Netflix/p2plab/daemon"
 	"github.com/Netflix/p2plab/daemon/healthcheckrouter"
+	"github.com/Netflix/p2plab/daemon/metricsrouter"
 	"github.com/Netflix/p2plab/downloaders"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/labd/controlapi"
+	"github.com/Netflix/p2plab/labd/notifier"
+	"github.com/Netflix/p2plab/labd/pool"
+	"github.com/Netflix/p2plab/labd/reaper"
+	"github.com/Netflix/p2plab/labd/retention"
 	"github.com/Netflix/p2plab/labd/routers/benchmarkrouter"
 	"github.com/Netflix/p2plab/labd/routers/buildrouter"
 	"github.com/Netflix/p2plab/labd/routers/clusterrouter"
 	"github.com/Netflix/p2plab/labd/routers/experimentrouter"
 	"github.com/Netflix/p2plab/labd/routers/noderouter"
 	"github.com/Netflix/p2plab/labd/routers/scenariorouter"
+	"github.com/Netflix/p2plab/labd/routers/schedulerouter"
+	"github.com/Netflix/p2plab/labd/scheduler"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/peer"
 	"github.com/Netflix/p2plab/pkg/httputil"
+	"github.com/Netflix/p2plab/pkg/metrics"
 	"github.com/Netflix/p2plab/providers"
+	"github.com/Netflix/p2plab/publishers"
 	"github.com/Netflix/p2plab/transformers"
 	"github.com/Netflix/p2plab/uploaders"
 	"github.com/pkg/errors"
@@ -41,10 +53,13 @@ import (
 )
 
 type Labd struct {
-	daemon  *daemon.Daemon
-	seeder  *peer.Peer
-	builder p2plab.Builder
-	closers []io.Closer
+	daemon    *daemon.Daemon
+	seeder    *peer.Peer
+	builder   p2plab.Builder
+	reaper    *reaper.Reaper
+	retention *retention.Retention
+	scheduler *scheduler.Scheduler
+	closers   []io.Closer
 }
 
 func New(root, addr string, logger *zerolog.Logger, opts ...LabdOption) (*Labd, error) {
@@ -83,6 +98,20 @@ func New(root, addr string, logger *zerolog.Logger, opts ...LabdOption) (*Labd,
 	}
 	closers = append(closers, uploader)
 
+	settings.PublisherSettings.Client = client
+	publisher, err := publishers.GetPublisher(settings.Publisher, settings.PublisherSettings)
+	if err != nil {
+		return nil, err
+	}
+	if publisher != nil {
+		closers = append(closers, publisher)
+	}
+
+	notifications, err := notifier.New(client.HTTPClient, settings.NotifierSettings)
+	if err != nil {
+		return nil, err
+	}
+
 	settings.DownloaderSettings.Client = client
 	fs := downloaders.New(filepath.Join(root, "downloaders"), settings.DownloaderSettings)
 
@@ -107,14 +136,25 @@ func New(root, addr string, logger *zerolog.Logger, opts ...LabdOption) (*Labd,
 	ts := transformers.New(filepath.Join(root, "transformers"), client.HTTPClient)
 	closers = append(closers, ts)
 
+	reg := metrics.NewRegistry()
+
+	// Experiments are run through labd's own API, so that their benchmarks are
+	// no different from benchmarks created by labctl.
+	control := controlapi.New(client, localURL(addr))
+	benchmarks := benchmarkrouter.New(db, client, ts, seeder, builder, publisher, notifications, reg)
+	sched := scheduler.New(db, benchmarks, client.HTTPClient)
+	seeder.RegisterMetrics(reg, "labd_seeder")
+
 	daemon, err := daemon.New("labd", addr, logger,
 		healthcheckrouter.New(),
-		clusterrouter.New(db, provider, client),
+		metricsrouter.New(reg),
+		clusterrouter.New(db, provider, client, builder, pool.New(db), reg),
 		noderouter.New(db, client),
 		scenariorouter.New(db),
-		benchmarkrouter.New(db, client, ts, seeder, builder),
-		experimentrouter.New(db, provider, client, ts, seeder, builder),
+		benchmarks,
+		experimentrouter.New(db, control),
 		buildrouter.New(db, uploader, fs),
+		schedulerouter.New(db, sched),
 	)
 	if err != nil {
 		return nil, err
@@ -122,10 +162,22 @@ func New(root, addr string, logger *zerolog.Logger, opts ...LabdOption) (*Labd,
 	closers = append(closers, daemon)
 
 	d := &Labd{
-		daemon:  daemon,
-		seeder:  seeder,
-		builder: builder,
-		closers: closers,
+		daemon:    daemon,
+		seeder:    seeder,
+		builder:   builder,
+		scheduler: sched,
+		closers:   closers,
+	}
+
+	if settings.ReaperInterval > 0 {
+		d.reaper = reaper.New(db, provider, settings.ReaperInterval, settings.ReaperDestroy)
+	}
+
+	if settings.RetentionAge > 0 {
+		if settings.RetentionResolution <= 0 || settings.RetentionInterval <= 0 {
+			return nil, errors.Wrap(errdefs.ErrInvalidArgument, "retention resolution and interval must be positive")
+		}
+		d.retention = retention.New(db, settings.RetentionAge, settings.RetentionResolution, settings.RetentionInterval)
 	}
 
 	return d, nil
@@ -154,5 +206,25 @@ func (d *Labd) Serve(ctx context.Context) error {
 	}
 	zerolog.Ctx(ctx).Info().Strs("addrs", addrs).Msg("IPFS listening")
 
+	if d.reaper != nil {
+		go d.reaper.Run(ctx)
+	}
+	if d.retention != nil {
+		go d.retention.Run(ctx)
+	}
+	go d.scheduler.Run(ctx)
+
 	return d.daemon.Serve(ctx)
 }
+
+// localURL returns the URL that labd listening on addr can reach itself at.
+func localURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
@@ -0,0 +1,258 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// Settings are where and what a notifier notifies.
+type Settings struct {
+	// Webhooks are URLs that notifications are posted to as JSON.
+	Webhooks []string
+
+	// Slacks are the URLs of Slack incoming webhooks that notifications are
+	// posted to as messages.
+	Slacks []string
+
+	// Metrics are the headline metrics of a notification, named as in
+	// expectations. Defaults to metadata.DefaultHeadlineMetrics.
+	Metrics []string
+
+	// BaseURL is the URL that labd can be reached at, which reports link to
+	// unless they have been published.
+	BaseURL string
+}
+
+// Notifier posts a summary of benchmarks to webhooks when they complete, so
+// that long benchmarks need not be watched from a terminal.
+type Notifier struct {
+	client   *http.Client
+	settings Settings
+}
+
+// New returns a notifier, or nil if it has no webhooks to notify.
+func New(client *http.Client, settings Settings) (*Notifier, error) {
+	if len(settings.Webhooks) == 0 && len(settings.Slacks) == 0 {
+		return nil, nil
+	}
+
+	if len(settings.Metrics) == 0 {
+		settings.Metrics = metadata.DefaultHeadlineMetrics
+	}
+	for _, m := range settings.Metrics {
+		err := metadata.ValidateMetric(m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Notifier{
+		client:   client,
+		settings: settings,
+	}, nil
+}
+
+// Notification is the payload posted to webhooks when a benchmark completes.
+type Notification struct {
+	Benchmark string
+	Status    metadata.BenchmarkStatus
+	Cluster   string
+	Scenario  string
+	Labels    []string
+
+	// Error is why the benchmark did not complete, if it did not.
+	Error string `json:",omitempty"`
+
+	TotalTime time.Duration
+
+	// Metrics are the headline metrics of the benchmark's report.
+	Metrics []metadata.MetricValue
+
+	// Failed are the expectations of the scenario that were not met.
+	Failed []metadata.ExpectationResult `json:",omitempty"`
+
+	// Regressions are the metrics that regressed from the scenario's
+	// baseline.
+	Regressions []string `json:",omitempty"`
+
+	// Link is the URL of the benchmark's report.
+	Link string `json:",omitempty"`
+}
+
+// Notify posts a summary of a completed benchmark and its report to every
+// webhook. The report is empty if the benchmark errored before it had one.
+func (n *Notifier) Notify(ctx context.Context, benchmark metadata.Benchmark, report metadata.Report, cause error) error {
+	notification := Notification{
+		Benchmark:   benchmark.ID,
+		Status:      benchmark.Status,
+		Cluster:     benchmark.Cluster.ID,
+		Scenario:    benchmark.Scenario.ID,
+		Labels:      benchmark.Labels,
+		TotalTime:   report.Summary.TotalTime,
+		Regressions: benchmark.Regressions,
+		Link:        n.link(benchmark),
+	}
+	if cause != nil {
+		notification.Error = cause.Error()
+	}
+	if report.Nodes != nil {
+		notification.Metrics = metadata.HeadlineMetrics(report, n.settings.Metrics)
+	}
+	for _, result := range report.Summary.Expectations {
+		if !result.Passed {
+			notification.Failed = append(notification.Failed, result)
+		}
+	}
+
+	var errs []string
+	for _, url := range n.settings.Webhooks {
+		err := Post(ctx, n.client, url, &notification)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("webhook: %s", err))
+		}
+	}
+
+	if len(n.settings.Slacks) > 0 {
+		text := slackText(notification)
+		for _, url := range n.settings.Slacks {
+			err := PostSlack(ctx, n.client, url, text)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("slack: %s", err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Errorf("failed to notify: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// link returns the URL of the published HTML report of a benchmark, or of its
+// JSON report on labd if it has not been published.
+func (n *Notifier) link(benchmark metadata.Benchmark) string {
+	if url, ok := benchmark.Published["report.html"]; ok {
+		return url
+	}
+	if n.settings.BaseURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/benchmarks/%s/report/json", strings.TrimSuffix(n.settings.BaseURL, "/"), benchmark.ID)
+}
+
+func slackText(notification Notification) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Benchmark `%s` of scenario `%s` on cluster `%s` is *%s*", notification.Benchmark, notification.Scenario, notification.Cluster, notification.Status)
+	if notification.TotalTime > 0 {
+		fmt.Fprintf(&text, " after %s", notification.TotalTime.Round(time.Second))
+	}
+	text.WriteString("\n")
+
+	if notification.Error != "" {
+		fmt.Fprintf(&text, "> %s\n", notification.Error)
+	}
+	for _, m := range notification.Metrics {
+		fmt.Fprintf(&text, "• `%s` %s\n", m.Metric, m.Value)
+	}
+	for _, result := range notification.Failed {
+		fmt.Fprintf(&text, "• Expected `%s`, got %s\n", result.Expectation, result.Actual)
+	}
+	if len(notification.Regressions) > 0 {
+		fmt.Fprintf(&text, "• Regressed from baseline: `%s`\n", strings.Join(notification.Regressions, "`, `"))
+	}
+	if notification.Link != "" {
+		fmt.Fprintf(&text, "<%s|Report>\n", notification.Link)
+	}
+	return text.String()
+}
+
+// Alert is the payload posted to a schedule's webhook when a scheduled
+// benchmark regresses.
+type Alert struct {
+	Schedule    string
+	Benchmark   string
+	Baseline    []string
+	Regressions []metadata.MetricComparison
+}
+
+// SendAlert posts an alert to a webhook and as a message to a Slack incoming
+// webhook, skipping either if its URL is empty.
+func SendAlert(ctx context.Context, client *http.Client, webhook, slack string, alert Alert) error {
+	if webhook != "" {
+		err := Post(ctx, client, webhook, &alert)
+		if err != nil {
+			return errors.Wrap(err, "failed to post alert to webhook")
+		}
+	}
+
+	if slack != "" {
+		err := PostSlack(ctx, client, slack, alertText(alert))
+		if err != nil {
+			return errors.Wrap(err, "failed to post alert to slack")
+		}
+	}
+
+	return nil
+}
+
+func alertText(alert Alert) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Benchmark `%s` of schedule `%s` regressed against %d previous benchmarks:\n", alert.Benchmark, alert.Schedule, len(alert.Baseline))
+	for _, mc := range alert.Regressions {
+		fmt.Fprintf(&text, "• `%s` %s → %s (%s, %+.1f%%)\n", mc.Metric, mc.Base, mc.Head, mc.Delta, mc.Change*100)
+	}
+	return text.String()
+}
+
+// PostSlack posts text as a message to a Slack incoming webhook.
+func PostSlack(ctx context.Context, client *http.Client, url, text string) error {
+	return Post(ctx, client, url, map[string]string{"text": text})
+}
+
+// Post posts v as JSON to a webhook.
+func Post(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Pool keeps warm clusters around so that repeated benchmarks on identical
+// topologies can skip provisioning. Clusters are matched by the hash of their
+// cluster definition.
+type Pool struct {
+	db metadata.DB
+	mu sync.Mutex
+}
+
+func New(db metadata.DB) *Pool {
+	return &Pool{db: db}
+}
+
+// Checkout finds a pooled cluster with the same definition and marks it as
+// in use. A cluster's node group is named after it, so it can't be renamed on
+// checkout. Instead if a name is given, only the pooled cluster of that name is
+// checked out, and it is an invalid argument if its definition differs. It
+// returns a not found error if there are no matching clusters.
+func (p *Pool) Checkout(ctx context.Context, name string, cdef metadata.ClusterDefinition) (metadata.Cluster, error) {
+	hash, err := cdef.Hash()
+	if err != nil {
+		return metadata.Cluster{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cs, err := p.db.ListClusters(ctx)
+	if err != nil {
+		return metadata.Cluster{}, err
+	}
+
+	for _, cluster := range cs {
+		if cluster.Status != metadata.ClusterPooled {
+			continue
+		}
+		if name != "" && cluster.ID != name {
+			continue
+		}
+
+		chash, err := cluster.Definition.Hash()
+		if err != nil {
+			return metadata.Cluster{}, err
+		}
+
+		if chash != hash {
+			if name != "" {
+				return metadata.Cluster{}, errors.Wrapf(errdefs.ErrInvalidArgument, "pooled cluster %q has definition %q, not %q", name, chash, hash)
+			}
+			continue
+		}
+
+		ns, err := p.db.ListNodes(ctx, cluster.ID)
+		if err != nil {
+			return metadata.Cluster{}, err
+		}
+
+		cluster.Status = cluster.RunningStatus(len(ns))
+		cluster, err = p.db.UpdateCluster(ctx, cluster)
+		if err != nil {
+			return metadata.Cluster{}, err
+		}
+
+		zerolog.Ctx(ctx).Info().Str("cluster", cluster.ID).Str("hash", hash).Msg("Checked out cluster from pool")
+		return cluster, nil
+	}
+
+	return metadata.Cluster{}, errors.Wrapf(errdefs.ErrNotFound, "no pooled cluster with definition %q", hash)
+}
+
+// Return puts a cluster back into the pool instead of destroying it.
+func (p *Pool) Return(ctx context.Context, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cluster, err := p.db.GetCluster(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if cluster.Status != metadata.ClusterCreated && cluster.Status != metadata.ClusterDegraded {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster %q is %s and cannot be returned to the pool", id, cluster.Status)
+	}
+
+	cluster.Status = metadata.ClusterPooled
+	_, err = p.db.UpdateCluster(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("cluster", id).Msg("Returned cluster to pool")
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reaper
+
+import (
+	"context"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Reaper reconciles the infrastructure provisioned by a provider against the
+// cluster metadata, so that instances leaked by labd crashing mid-provisioning
+// are reported or destroyed.
+type Reaper struct {
+	db       metadata.DB
+	provider p2plab.NodeProvider
+	interval time.Duration
+	destroy  bool
+}
+
+func New(db metadata.DB, provider p2plab.NodeProvider, interval time.Duration, destroy bool) *Reaper {
+	return &Reaper{
+		db:       db,
+		provider: provider,
+		interval: interval,
+		destroy:  destroy,
+	}
+}
+
+// Run reconciles on every interval until the context is cancelled.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.Reconcile(ctx)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("failed to reconcile infrastructure")
+			}
+		}
+	}
+}
+
+// Reconcile returns the IDs of node groups that have no corresponding cluster
+// record. If the reaper is configured to destroy, the orphaned node groups are
+// also destroyed.
+func (r *Reaper) Reconcile(ctx context.Context) ([]string, error) {
+	ids, err := r.provider.ListNodeGroups(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list node groups")
+	}
+
+	cs, err := r.db.ListClusters(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list clusters")
+	}
+
+	// Destroyed clusters are only kept for their accrued cost, so a node group
+	// left behind for one is orphaned.
+	clusterSet := make(map[string]struct{})
+	for _, c := range cs {
+		if c.Status == metadata.ClusterDestroyed {
+			continue
+		}
+		clusterSet[c.ID] = struct{}{}
+	}
+
+	var orphans []string
+	for _, id := range ids {
+		_, ok := clusterSet[id]
+		if ok {
+			continue
+		}
+		orphans = append(orphans, id)
+
+		logger := zerolog.Ctx(ctx).With().Str("cluster", id).Logger()
+		if !r.destroy {
+			logger.Warn().Msg("Found orphaned node group")
+			continue
+		}
+
+		logger.Warn().Msg("Destroying orphaned node group")
+		err = r.provider.DestroyNodeGroup(logger.WithContext(ctx), &p2plab.NodeGroup{ID: id})
+		if err != nil {
+			return orphans, errors.Wrapf(err, "failed to destroy orphaned node group %q", id)
+		}
+	}
+
+	return orphans, nil
+}
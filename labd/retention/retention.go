@@ -0,0 +1,116 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultResolution is the interval between the samples kept in
+	// downsampled reports.
+	DefaultResolution = time.Minute
+
+	// DefaultInterval is how often reports are checked for downsampling.
+	DefaultInterval = time.Hour
+)
+
+// Retention downsamples the reports of benchmarks older than an age, so that
+// the metadata store stays small while the aggregates of old benchmarks are
+// kept for long-term trends.
+type Retention struct {
+	db         metadata.DB
+	age        time.Duration
+	resolution time.Duration
+	interval   time.Duration
+}
+
+func New(db metadata.DB, age, resolution, interval time.Duration) *Retention {
+	return &Retention{
+		db:         db,
+		age:        age,
+		resolution: resolution,
+		interval:   interval,
+	}
+}
+
+// Run applies the retention policy on every interval until the context is
+// cancelled.
+func (r *Retention) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.Apply(ctx)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("failed to apply retention policy")
+			}
+		}
+	}
+}
+
+// Apply downsamples the reports of completed benchmarks created longer than
+// the age ago, and returns the IDs of the benchmarks whose reports were
+// downsampled. Reports are only downsampled once.
+func (r *Retention) Apply(ctx context.Context) ([]string, error) {
+	benchmarks, err := r.db.ListBenchmarks(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list benchmarks")
+	}
+
+	now := time.Now().UTC()
+	var ids []string
+	for _, benchmark := range benchmarks {
+		switch benchmark.Status {
+		case metadata.BenchmarkPlanning, metadata.BenchmarkRunning:
+			continue
+		}
+		if now.Sub(benchmark.CreatedAt) < r.age {
+			continue
+		}
+
+		report, err := r.db.GetReport(ctx, benchmark.ID)
+		if err != nil {
+			// Benchmarks that errored before completing may have no report.
+			if errdefs.IsNotFound(err) {
+				continue
+			}
+			return ids, errors.Wrapf(err, "failed to get report of benchmark %q", benchmark.ID)
+		}
+		if report.Downsampled != nil {
+			continue
+		}
+
+		err = r.db.CreateReport(ctx, benchmark.ID, metadata.DownsampleReport(report, r.resolution, now))
+		if err != nil {
+			return ids, errors.Wrapf(err, "failed to downsample report of benchmark %q", benchmark.ID)
+		}
+		ids = append(ids, benchmark.ID)
+
+		zerolog.Ctx(ctx).Info().Str("bid", benchmark.ID).Dur("resolution", r.resolution).Msg("Downsampled benchmark report")
+	}
+
+	return ids, nil
+}
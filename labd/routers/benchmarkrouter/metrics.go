@@ -0,0 +1,51 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmarkrouter
+
+import (
+	"github.com/Netflix/p2plab/pkg/metrics"
+	"github.com/Netflix/p2plab/scenarios"
+)
+
+// benchmarkMetrics measures the progress of benchmarks.
+type benchmarkMetrics struct {
+	running       *metrics.Gauge
+	benchmarks    *metrics.Counter
+	trials        *metrics.Counter
+	stages        *metrics.Counter
+	stageDuration *metrics.Histogram
+}
+
+func newBenchmarkMetrics(reg *metrics.Registry) *benchmarkMetrics {
+	return &benchmarkMetrics{
+		running:       reg.NewGauge("labd_benchmarks_running", "Benchmarks currently running."),
+		benchmarks:    reg.NewCounter("labd_benchmarks_total", "Benchmarks that finished, by their status.", "status"),
+		trials:        reg.NewCounter("labd_benchmark_trials_total", "Trials of benchmarks that completed."),
+		stages:        reg.NewCounter("labd_benchmark_stages_total", "Stages of benchmarks that completed or were skipped.", "stage", "skipped"),
+		stageDuration: reg.NewHistogram("labd_benchmark_stage_duration_seconds", "Time taken by each stage that ran.", metrics.ExponentialBuckets(0.1, 2, 16), "stage"),
+	}
+}
+
+func (m *benchmarkMetrics) observeStage(name string, execution scenarios.StageExecution) {
+	if execution.End.IsZero() {
+		return
+	}
+	if execution.Skipped {
+		m.stages.Inc(name, "true")
+		return
+	}
+	m.stages.Inc(name, "false")
+	m.stageDuration.Observe(execution.End.Sub(execution.Start).Seconds(), name)
+}
@@ -0,0 +1,205 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmarkrouter
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/scenarios"
+)
+
+// progressTracker tracks the progress of the benchmarks that are running, so
+// that their status can be queried and they can be cancelled before they
+// complete.
+type progressTracker struct {
+	mu         sync.Mutex
+	benchmarks map[string]*benchmarkProgress
+}
+
+type benchmarkProgress struct {
+	metadata.BenchmarkProgress
+
+	running    map[string]struct{}
+	trialStart time.Time
+
+	cancel  context.CancelFunc
+	aborted bool
+
+	// soak is how long the measured stages loop for, in which case the
+	// progress of a trial is measured by time rather than actions.
+	soak time.Duration
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		benchmarks: make(map[string]*benchmarkProgress),
+	}
+}
+
+// track starts tracking a benchmark that is being planned, which is aborted
+// by calling cancel, and returns a function that stops tracking it once it is
+// no longer running.
+func (t *progressTracker) track(id string, trials int, cancel context.CancelFunc) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.benchmarks[id] = &benchmarkProgress{
+		BenchmarkProgress: metadata.BenchmarkProgress{
+			ID:        id,
+			Status:    metadata.BenchmarkPlanning,
+			Trials:    trials,
+			StartedAt: time.Now(),
+		},
+		running: make(map[string]struct{}),
+		cancel:  cancel,
+	}
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.benchmarks, id)
+	}
+}
+
+// startTrial resets the progress of a benchmark for a trial, counting from
+// one, that is about to run a plan. The measured stages of a replay are
+// replaced by the events of its trace.
+func (t *progressTracker) startTrial(id string, trial int, plan metadata.ScenarioPlan, replay *metadata.Trace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.benchmarks[id]
+	if !ok {
+		return
+	}
+
+	p.Status = metadata.BenchmarkRunning
+	p.Trial = trial
+	p.Stages = nil
+	p.StagesCompleted, p.StagesTotal = 0, 0
+	p.ActionsCompleted, p.ActionsTotal = 0, 0
+	p.running = make(map[string]struct{})
+	p.trialStart = time.Now()
+	p.soak = 0
+	if plan.Soak != nil && replay == nil {
+		p.soak = plan.Soak.Duration
+	}
+
+	for _, stage := range plan.Stages {
+		if replay != nil && !stage.Seed && !stage.Warmup {
+			continue
+		}
+		p.StagesTotal++
+		p.ActionsTotal += len(stage.Tasks)
+	}
+	if replay != nil {
+		p.ActionsTotal += len(replay.Events)
+	}
+}
+
+// abort cancels a running benchmark, and returns false if it is not running.
+func (t *progressTracker) abort(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.benchmarks[id]
+	if !ok {
+		return false
+	}
+	p.aborted = true
+	p.cancel()
+	return true
+}
+
+// aborted returns whether a running benchmark has been cancelled.
+func (t *progressTracker) aborted(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.benchmarks[id]
+	return ok && p.aborted
+}
+
+func (t *progressTracker) observeStage(id, name string, execution scenarios.StageExecution) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.benchmarks[id]
+	if !ok {
+		return
+	}
+
+	if execution.End.IsZero() {
+		p.running[name] = struct{}{}
+		return
+	}
+	delete(p.running, name)
+
+	// Soaks loop their stages, which are only counted the first time.
+	if p.StagesCompleted < p.StagesTotal {
+		p.StagesCompleted++
+	}
+}
+
+func (t *progressTracker) observeTask(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.benchmarks[id]
+	if !ok {
+		return
+	}
+	p.ActionsCompleted++
+}
+
+// get returns the progress of a benchmark if it is running, with its ETA
+// estimated from the fraction of the current trial's actions that have
+// completed, or of its soak that has elapsed.
+func (t *progressTracker) get(id string) (metadata.BenchmarkProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.benchmarks[id]
+	if !ok {
+		return metadata.BenchmarkProgress{}, false
+	}
+
+	progress := p.BenchmarkProgress
+	progress.Stages = nil
+	for name := range p.running {
+		progress.Stages = append(progress.Stages, name)
+	}
+	sort.Strings(progress.Stages)
+
+	now := time.Now()
+	var fraction float64
+	switch {
+	case p.Status != metadata.BenchmarkRunning:
+	case p.soak > 0:
+		fraction = now.Sub(p.trialStart).Seconds() / p.soak.Seconds()
+	case p.ActionsTotal > 0:
+		fraction = float64(p.ActionsCompleted) / float64(p.ActionsTotal)
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	progress.Estimate(now, fraction)
+
+	return progress, true
+}
@@ -15,23 +15,33 @@
 package benchmarkrouter
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
 	"os"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/daemon"
+	"github.com/Netflix/p2plab/errdefs"
 	"github.com/Netflix/p2plab/labd/controlapi"
+	"github.com/Netflix/p2plab/labd/notifier"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/nodes"
 	"github.com/Netflix/p2plab/peer"
 	"github.com/Netflix/p2plab/pkg/httputil"
 	"github.com/Netflix/p2plab/pkg/logutil"
+	"github.com/Netflix/p2plab/pkg/metrics"
 	"github.com/Netflix/p2plab/pkg/stringutil"
+	"github.com/Netflix/p2plab/printer"
 	"github.com/Netflix/p2plab/query"
 	"github.com/Netflix/p2plab/reports"
 	"github.com/Netflix/p2plab/scenarios"
@@ -43,15 +53,32 @@ import (
 )
 
 type router struct {
-	db      metadata.DB
-	client  *httputil.Client
-	ts      *transformers.Transformers
-	seeder  *peer.Peer
-	builder p2plab.Builder
+	db        metadata.DB
+	client    *httputil.Client
+	ts        *transformers.Transformers
+	seeder    *peer.Peer
+	builder   p2plab.Builder
+	publisher p2plab.Publisher
+	notifier  *notifier.Notifier
+	metrics   *benchmarkMetrics
+	progress  *progressTracker
+	streams   *streamTracker
 }
 
-func New(db metadata.DB, client *httputil.Client, ts *transformers.Transformers, seeder *peer.Peer, builder p2plab.Builder) daemon.Router {
-	return &router{db, client, ts, seeder, builder}
+// Router is the router for benchmarks, which also runs the benchmarks that
+// labd starts itself.
+type Router interface {
+	daemon.Router
+
+	// RunBenchmark runs a benchmark of a scenario on a cluster in labd's
+	// process, as if it was created through the API, and returns its ID.
+	RunBenchmark(ctx context.Context, cluster, scenario string, opts ...p2plab.StartBenchmarkOption) (string, error)
+}
+
+// New returns a router for benchmarks. The publisher and notifier may be nil
+// if publishing and notifications are disabled.
+func New(db metadata.DB, client *httputil.Client, ts *transformers.Transformers, seeder *peer.Peer, builder p2plab.Builder, publisher p2plab.Publisher, notifier *notifier.Notifier, reg *metrics.Registry) Router {
+	return &router{db, client, ts, seeder, builder, publisher, notifier, newBenchmarkMetrics(reg), newProgressTracker(), newStreamTracker()}
 }
 
 func (s *router) Routes() []daemon.Route {
@@ -60,10 +87,20 @@ func (s *router) Routes() []daemon.Route {
 		daemon.NewGetRoute("/benchmarks/json", s.getBenchmarks),
 		daemon.NewGetRoute("/benchmarks/{id}/json", s.getBenchmarkById),
 		daemon.NewGetRoute("/benchmarks/{id}/report/json", s.getBenchmarkReportById),
+		daemon.NewGetRoute("/benchmarks/{id}/trace/json", s.getBenchmarkTraceById),
+		daemon.NewGetRoute("/benchmarks/{id}/profiles/{node}/{kind}", s.getBenchmarkProfile),
+		daemon.NewGetRoute("/benchmarks/{id}/status/json", s.getBenchmarkStatus),
+		daemon.NewGetRoute("/benchmarks/{id}/attach", s.getBenchmarkAttach),
+		daemon.NewGetRoute("/benchmarks/{id}/compare/{head}/json", s.getBenchmarkComparison),
+		daemon.NewGetRoute("/benchmarks/{id}/diff/{head}/json", s.getBenchmarkDiff),
 		// POST
 		daemon.NewPostRoute("/benchmarks/create", s.postBenchmarksCreate),
 		// PUT
 		daemon.NewPutRoute("/benchmarks/label", s.putBenchmarksLabel),
+		daemon.NewPutRoute("/benchmarks/{id}/pin", s.putBenchmarkPin),
+		daemon.NewPutRoute("/benchmarks/{id}/unpin", s.putBenchmarkUnpin),
+		daemon.NewPutRoute("/benchmarks/{id}/publish", s.putBenchmarkPublish),
+		daemon.NewPutRoute("/benchmarks/{id}/cancel", s.putBenchmarkCancel),
 		// DELETE
 		daemon.NewDeleteRoute("/benchmarks/delete", s.deleteBenchmarks),
 	}
@@ -98,32 +135,294 @@ func (s *router) getBenchmarkReportById(ctx context.Context, w http.ResponseWrit
 	return daemon.WriteJSON(w, &report)
 }
 
-func (s *router) postBenchmarksCreate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	noReset := false
-	if r.FormValue("no-reset") != "" {
+func (s *router) getBenchmarkTraceById(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	id := vars["id"]
+	trace, err := s.db.GetTrace(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return daemon.WriteJSON(w, &trace)
+}
+
+// getBenchmarkProfile returns a profile captured from a node while a
+// benchmark ran, in the pprof format.
+func (s *router) getBenchmarkProfile(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	profile, err := s.db.GetProfile(ctx, vars["id"], vars["node"], metadata.ProfileKind(vars["kind"]))
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, err = w.Write(profile)
+	return err
+}
+
+// getBenchmarkStatus returns the progress of a benchmark, which is complete
+// once the benchmark is no longer running.
+func (s *router) getBenchmarkStatus(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	id := vars["id"]
+	progress, ok := s.progress.get(id)
+	if !ok {
+		benchmark, err := s.db.GetBenchmark(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		progress = metadata.BenchmarkProgress{
+			ID:        benchmark.ID,
+			Status:    benchmark.Status,
+			StartedAt: benchmark.CreatedAt,
+			Elapsed:   benchmark.UpdatedAt.Sub(benchmark.CreatedAt),
+			ETA:       benchmark.UpdatedAt,
+		}
+	}
+
+	return daemon.WriteJSON(w, &progress)
+}
+
+// getBenchmarkAttach streams the logs of a running benchmark from an offset
+// until it completes, so that a client disconnected from the stream can
+// resume it. A benchmark that is not running has nothing to stream.
+func (s *router) getBenchmarkAttach(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	id := vars["id"]
+	offset := 0
+	if v := r.FormValue("offset"); v != "" {
+		var err error
+		offset, err = strconv.Atoi(v)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "invalid offset %q", v)
+		}
+	}
+
+	stream, ok := s.streams.get(id)
+	if !ok {
+		_, err := s.db.GetBenchmark(ctx, id)
+		return err
+	}
+
+	offset = stream.start(offset)
+	w.Header().Set(controlapi.StreamOffset, strconv.Itoa(offset))
+	w.WriteHeader(http.StatusOK)
+
+	// Flush the header so that the client is attached before the next line
+	// is logged.
+	wf := logutil.NewWriteFlusher(w)
+	_, err := wf.Write(nil)
+	if err != nil {
+		return err
+	}
+
+	return stream.follow(ctx, offset, func(line []byte) error {
+		_, err := wf.Write(line)
+		return err
+	})
+}
+
+// getBenchmarkComparison compares the report of a benchmark to the report of
+// a later benchmark of the same scenario.
+func (s *router) getBenchmarkComparison(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	significance := metadata.DefaultSignificance
+	if r.FormValue("significance") != "" {
 		var err error
-		noReset, err = strconv.ParseBool(r.FormValue("no-reset"))
+		significance, err = strconv.ParseFloat(r.FormValue("significance"), 64)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "invalid significance: %s", err)
+		}
+		if significance <= 0 || significance >= 1 {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "significance %g must be between 0 and 1", significance)
+		}
+	}
+
+	ids := []string{vars["id"], vars["head"]}
+	var reports []metadata.Report
+	var scenario string
+	for _, id := range ids {
+		benchmark, err := s.db.GetBenchmark(ctx, id)
 		if err != nil {
 			return err
 		}
+
+		if scenario == "" {
+			scenario = benchmark.Scenario.ID
+		} else if benchmark.Scenario.ID != scenario {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "benchmark %q is of scenario %q but %q is of scenario %q", ids[0], scenario, id, benchmark.Scenario.ID)
+		}
+
+		report, err := s.db.GetReport(ctx, id)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+
+	comparison := metadata.CompareReports(reports[0], reports[1], significance)
+	comparison.Base, comparison.Head = ids[0], ids[1]
+	return daemon.WriteJSON(w, &comparison)
+}
+
+// getBenchmarkDiff compares the nodes of a benchmark to the nodes of another
+// benchmark, matched by their queries and labels.
+func (s *router) getBenchmarkDiff(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	by := metadata.DiffByNode
+	if r.FormValue("by") != "" {
+		by = metadata.DiffGrouping(r.FormValue("by"))
+	}
+	switch by {
+	case metadata.DiffByNode, metadata.DiffByQuery, metadata.DiffByLabel:
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized diff grouping %q", by)
+	}
+
+	threshold := metadata.DefaultDiffThreshold
+	if r.FormValue("threshold") != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(r.FormValue("threshold"), 64)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "invalid threshold: %s", err)
+		}
+		if threshold < 0 {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "threshold %g must not be negative", threshold)
+		}
 	}
 
-	sid := r.FormValue("scenario")
-	scenario, err := s.db.GetScenario(ctx, sid)
+	base, err := s.db.GetReport(ctx, vars["id"])
 	if err != nil {
 		return err
 	}
 
-	cid := r.FormValue("cluster")
-	cluster, err := s.db.GetCluster(ctx, cid)
+	head, err := s.db.GetReport(ctx, vars["head"])
 	if err != nil {
 		return err
 	}
 
-	bid := fmt.Sprintf("%s-%s-%d", cid, sid, time.Now().UnixNano())
-	w.Header().Add(controlapi.ResourceID, bid)
+	diff := reports.DiffReports(base, head, by, threshold)
+	diff.Base, diff.Head = vars["id"], vars["head"]
+	return daemon.WriteJSON(w, &diff)
+}
+
+func (s *router) postBenchmarksCreate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var settings p2plab.StartBenchmarkSettings
+	if r.FormValue("no-reset") != "" {
+		var err error
+		settings.NoReset, err = strconv.ParseBool(r.FormValue("no-reset"))
+		if err != nil {
+			return err
+		}
+	}
+	settings.Replay = r.FormValue("replay")
+	settings.Labels = stringutil.Coalesce(strings.Split(r.FormValue("labels"), ","))
+	settings.Detach = r.FormValue("detach") == "true"
+
+	_, err := s.runBenchmark(ctx, w, r.FormValue("cluster"), r.FormValue("scenario"), settings)
+	return err
+}
+
+func (s *router) RunBenchmark(ctx context.Context, cid, sid string, opts ...p2plab.StartBenchmarkOption) (string, error) {
+	var settings p2plab.StartBenchmarkSettings
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return s.runBenchmark(ctx, nil, cid, sid, settings)
+}
+
+// runBenchmark runs a benchmark and returns its ID, which is empty if it
+// failed before the benchmark started. Its logs are streamed to w, or to the
+// log writer of the context if w is nil.
+func (s *router) runBenchmark(ctx context.Context, w http.ResponseWriter, cid, sid string, settings p2plab.StartBenchmarkSettings) (bid string, rerr error) {
+	noReset, labels := settings.NoReset, settings.Labels
+
+	// A replay runs the scenario of the replayed benchmark, which may have
+	// since been updated or removed.
+	var (
+		scenario   metadata.Scenario
+		replay     *metadata.Trace
+		replaySeed int64
+		err        error
+	)
+	rid := settings.Replay
+	if rid != "" {
+		original, err := s.db.GetBenchmark(ctx, rid)
+		if err != nil {
+			return "", err
+		}
+
+		trace, err := s.db.GetTrace(ctx, rid)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get trace of benchmark %q", rid)
+		}
+
+		// Sample queries of a replay choose the nodes of the original.
+		report, err := s.db.GetReport(ctx, rid)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get report of benchmark %q", rid)
+		}
+
+		scenario = original.Scenario
+		replay = &trace
+		replaySeed = report.Summary.Seed
+	} else {
+		scenario, err = s.db.GetScenario(ctx, sid)
+		if err != nil {
+			return "", err
+		}
+	}
+	sid = scenario.ID
+
+	cluster, err := s.db.GetCluster(ctx, cid)
+	if err != nil {
+		return "", err
+	}
+	if cluster.Status == metadata.ClusterDestroyed {
+		return "", errors.Wrapf(errdefs.ErrInvalidArgument, "cluster %q is destroyed", cid)
+	}
+
+	bid = fmt.Sprintf("%s-%s-%d", cid, sid, time.Now().UnixNano())
+	if w != nil {
+		w.Header().Add(controlapi.ResourceID, bid)
+	}
+
+	// A detached benchmark keeps running if the client disconnects, and can
+	// only be aborted by cancelling it.
+	if settings.Detach {
+		ctx = daemon.Detach(ctx)
+	}
+
+	trials := scenario.Definition.Trials
+	if trials == 0 || replay != nil {
+		trials = 1
+	}
+
+	// A benchmark is aborted by cancelling its context, which cancels the
+	// requests in flight to its nodes. It is tracked before its ID is sent
+	// back so that its status can be queried as soon as it has started.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer s.progress.track(bid, trials, cancel)()
+	if settings.Started != nil {
+		settings.Started(bid)
+	}
+
+	stream, closeStream := s.streams.open(bid)
+	defer closeStream()
 
-	ctx, logger := logutil.WithResponseLogger(ctx, w)
+	var logger *zerolog.Logger
+	if w != nil {
+		ctx, logger = logutil.WithResponseLogger(ctx, w, stream)
+	} else {
+		// Benchmarks run in process are logged to where their caller logs.
+		var writer io.Writer = os.Stderr
+		if lw := logutil.LogWriter(ctx); lw != nil {
+			writer = lw
+		}
+		writer = io.MultiWriter(writer, stream)
+		l := zerolog.Ctx(ctx).Output(writer)
+		ctx, logger = l.WithContext(logutil.WithLogWriter(ctx, writer)), &l
+	}
 	logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
 		return c.Str("bid", bid)
 	})
@@ -131,7 +430,7 @@ func (s *router) postBenchmarksCreate(ctx context.Context, w http.ResponseWriter
 	zerolog.Ctx(ctx).Info().Msg("Retrieving nodes in cluster")
 	mns, err := s.db.ListNodes(ctx, cid)
 	if err != nil {
-		return err
+		return bid, err
 	}
 
 	var ns []p2plab.Node
@@ -142,81 +441,379 @@ func (s *router) postBenchmarksCreate(ctx context.Context, w http.ResponseWriter
 		ns = append(ns, node)
 	}
 
-	if !noReset {
-		err = nodes.Update(ctx, s.builder, ns)
+	// Record the seed even if it was generated so that the benchmark can be
+	// repeated.
+	seed := scenario.Definition.RandomSeed
+	if replay != nil {
+		seed = replaySeed
+	} else if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	// Sample queries and selections choose the same nodes in every trial.
+	ctx = query.WithRandomSeed(ctx, seed)
+	ctx, err = scenarios.ResolveSelections(ctx, scenario.Definition.Selections, lset)
+	if err != nil {
+		return bid, errors.Wrap(err, "failed to resolve selections")
+	}
+
+	// Nodes run the scenario's peer definitions for the benchmark, which can
+	// only take effect when the cluster is reset.
+	pdefs, err := scenarios.PlanPeers(ctx, scenario.Definition.Peers, lset)
+	if err != nil {
+		return bid, errors.Wrap(err, "failed to plan peer definitions")
+	}
+	if noReset && len(pdefs) > 0 {
+		return bid, errors.Wrap(errdefs.ErrInvalidArgument, "scenario peer definitions require resetting the cluster")
+	}
+
+	var seederAddrs []string
+	for _, addr := range s.seeder.Host().Addrs() {
+		seederAddrs = append(seederAddrs, fmt.Sprintf("%s/p2p/%s", addr, s.seeder.Host().ID()))
+	}
+
+	s.metrics.running.Add(1)
+	defer s.metrics.running.Add(-1)
+
+	// A benchmark that does not run to completion is counted as an error.
+	result := metadata.BenchmarkError
+	defer func() {
+		s.metrics.benchmarks.Inc(string(result))
+	}()
+
+	var (
+		benchmark metadata.Benchmark
+		report    metadata.Report
+		trace     metadata.Trace
+		queries   map[string][]string
+		summaries []metadata.ReportTrial
+		profiles  map[string]map[metadata.ProfileKind][]byte
+	)
+
+	// Benchmarks are notified of once they complete, whether or not they
+	// succeeded.
+	defer func() {
+		if s.notifier != nil && benchmark.ID != "" {
+			s.notify(ctx, benchmark, result, rerr)
+		}
+	}()
+
+	// fail marks the benchmark as aborted with the partial reports of its
+	// nodes if it failed because it was cancelled.
+	fail := func(err error, message string) error {
+		if !s.progress.aborted(bid) {
+			return errors.Wrap(err, message)
+		}
+		result = metadata.BenchmarkAborted
+		return s.abortBenchmark(ctx, benchmark, ns, queries)
+	}
+
+	for trial := 0; trial < trials; trial++ {
+		// A replay is planned with the seed of the traced trial so that it
+		// seeds the same objects.
+		trialSeed := scenarios.TrialSeed(seed, trial)
+		if replay != nil {
+			trialSeed = replay.Seed
+		}
+		zerolog.Ctx(ctx).Info().Int("trial", trial+1).Int("trials", trials).Int64("seed", trialSeed).Msg("Starting trial")
+
+		if !noReset {
+			err = nodes.UpdatePeers(ctx, s.builder, ns, pdefs)
+			if err != nil {
+				return bid, fail(err, "failed to update cluster")
+			}
+
+			err = nodes.Connect(ctx, ns)
+			if err != nil {
+				return bid, fail(err, "failed to connect cluster")
+			}
+		}
+
+		zerolog.Ctx(ctx).Info().Msg("Creating scenario plan")
+		rng := rand.New(rand.NewSource(trialSeed))
+		var plan metadata.ScenarioPlan
+		plan, queries, err = scenarios.Plan(ctx, scenario.Definition, s.ts, s.seeder, lset, rng)
 		if err != nil {
-			return errors.Wrap(err, "failed to update cluster")
+			return bid, fail(err, "failed to create scenario plan")
+		}
+
+		s.progress.startTrial(bid, trial+1, plan, replay)
+
+		if trial == 0 {
+			benchmark = metadata.Benchmark{
+				ID:       bid,
+				Status:   metadata.BenchmarkRunning,
+				Cluster:  cluster,
+				Scenario: scenario,
+				Plan:     plan,
+				Labels: []string{
+					bid,
+					cid,
+					sid,
+				},
+			}
+			if rid != "" {
+				benchmark.Labels = append(benchmark.Labels, rid)
+			}
+			benchmark.Labels = append(benchmark.Labels, labels...)
+
+			zerolog.Ctx(ctx).Info().Msg("Creating benchmark metadata")
+			benchmark, err = s.db.CreateBenchmark(ctx, benchmark)
+			if err != nil {
+				return bid, err
+			}
 		}
+		benchmark.Plan = plan
 
-		err = nodes.Connect(ctx, ns)
+		zerolog.Ctx(ctx).Info().Msg("Executing scenario plan")
+		opts := []scenarios.RunOption{
+			scenarios.WithStageFunc(func(name string, execution scenarios.StageExecution) {
+				s.metrics.observeStage(name, execution)
+				s.progress.observeStage(bid, name, execution)
+			}),
+			scenarios.WithTaskFunc(func(id string, task metadata.Task) {
+				s.progress.observeTask(bid)
+			}),
+			scenarios.WithSnapshot(func(ctx context.Context, snapshots []metadata.ReportSnapshot, reportByNodeID map[string]metadata.ReportNode) error {
+				// Interim reports are persisted so that a soak can be
+				// inspected while it runs.
+				last := snapshots[len(snapshots)-1]
+				return s.db.CreateReport(ctx, benchmark.ID, metadata.Report{
+					Summary: metadata.ReportSummary{
+						TotalTime: last.Elapsed,
+						Seed:      trialSeed,
+					},
+					Aggregates: last.Aggregates,
+					Nodes:      reportByNodeID,
+					Queries:    queries,
+					Snapshots:  snapshots,
+				})
+			}),
+		}
+		if replay != nil {
+			opts = append(opts, scenarios.WithReplay(*replay))
+		}
+		if len(scenario.Definition.Profiles) > 0 {
+			opts = append(opts, scenarios.WithProfiles(scenario.Definition.Profiles))
+		}
+
+		execution, err := scenarios.Run(ctx, lset, plan, seederAddrs, opts...)
 		if err != nil {
-			return errors.Wrap(err, "failed to connect cluster")
+			return bid, fail(err, "failed to run scenario plan")
+		}
+		trace = execution.Trace
+		trace.Seed = trialSeed
+
+		report = metadata.Report{
+			Summary: metadata.ReportSummary{
+				TotalTime: execution.End.Sub(execution.Start),
+				Stages:    make(map[string]time.Duration),
+				Seed:      trialSeed,
+			},
+			Nodes:     execution.Report,
+			Queries:   queries,
+			Snapshots: execution.Snapshots,
+			Series:    execution.Series,
+			Topology:  execution.Topology,
+		}
+		report.Aggregates = reports.ComputeAggregates(report.Nodes)
+		report.Bandwidth = reports.ComputeBandwidthMatrix(report.Nodes)
+
+		// Profiles are stored with the benchmark and only their digests are
+		// reported, as only the nodes of the last trial are kept.
+		profiles = execution.Profiles
+		for id, kinds := range profiles {
+			reportNode, ok := report.Nodes[id]
+			if !ok {
+				continue
+			}
+			for _, kind := range scenario.Definition.Profiles {
+				profile, ok := kinds[kind]
+				if ok {
+					reportNode.Profiles = append(reportNode.Profiles, metadata.NewReportProfile(kind, profile))
+				}
+			}
+			report.Nodes[id] = reportNode
+		}
+
+		for name, stage := range execution.Stages {
+			if stage.Skipped {
+				report.Summary.Skipped = append(report.Summary.Skipped, name)
+				continue
+			}
+			report.Summary.Stages[name] = stage.End.Sub(stage.Start)
 		}
+		sort.Strings(report.Summary.Skipped)
+
+		jaegerUI := os.Getenv("JAEGER_UI")
+		if jaegerUI != "" {
+			sc, ok := execution.Span.Context().(jaeger.SpanContext)
+			if ok {
+				report.Summary.Trace = fmt.Sprintf("%s/trace/%s", jaegerUI, sc.TraceID())
+			}
+		}
+
+		summaries = append(summaries, metadata.ReportTrial{
+			Summary:    report.Summary,
+			Aggregates: report.Aggregates,
+		})
+		s.metrics.trials.Inc()
 	}
 
-	zerolog.Ctx(ctx).Info().Msg("Creating scenario plan")
-	plan, queries, err := scenarios.Plan(ctx, scenario.Definition, s.ts, s.seeder, lset)
+	if trials > 1 {
+		report.Trials = summaries
+		report.Summary = reports.SummarizeTrials(summaries)
+		report.Summary.Seed = seed
+
+		report.Statistics = metadata.TrialStatistics(report, metadata.DefaultConfidence)
+		for _, m := range report.Statistics.Metrics {
+			if len(m.Outliers) > 0 {
+				zerolog.Ctx(ctx).Warn().Str("metric", m.Metric).Ints("trials", m.Outliers).Msg("Trials are outliers")
+			}
+		}
+	}
+
+	report.Summary.Expectations, err = metadata.EvaluateExpectations(report, scenario.Definition.Expectations)
 	if err != nil {
-		return errors.Wrap(err, "failed to create scenario plan")
-	}
-
-	benchmark := metadata.Benchmark{
-		ID:       bid,
-		Status:   metadata.BenchmarkRunning,
-		Cluster:  cluster,
-		Scenario: scenario,
-		Plan:     plan,
-		Labels: []string{
-			bid,
-			cid,
-			sid,
-		},
+		return bid, errors.Wrap(err, "failed to evaluate expectations")
+	}
+
+	// Benchmarks of a scenario with a pinned baseline record their regressions
+	// from it, so that they can be seen at a glance when listing benchmarks.
+	if scenario.Baseline != "" && replay == nil {
+		baseline, err := s.db.GetReport(ctx, scenario.Baseline)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str("baseline", scenario.Baseline).Msg("Failed to get report of baseline")
+		} else {
+			comparison := metadata.CompareReports(baseline, report, metadata.DefaultSignificance)
+			benchmark.Baseline = scenario.Baseline
+			for _, m := range comparison.Regressions() {
+				benchmark.Regressions = append(benchmark.Regressions, m.Metric)
+			}
+			zerolog.Ctx(ctx).Info().Str("baseline", benchmark.Baseline).Strs("regressions", benchmark.Regressions).Msg("Compared against baseline")
+		}
+	}
+
+	status := metadata.BenchmarkDone
+	if !metadata.ExpectationsPassed(report.Summary.Expectations) {
+		status = metadata.BenchmarkFailed
+		zerolog.Ctx(ctx).Warn().Msg("Benchmark did not meet expectations")
+	}
+
+	zerolog.Ctx(ctx).Info().Msg("Updating benchmark metadata")
+	err = s.db.Update(ctx, func(tx *bolt.Tx) error {
+		tctx := metadata.WithTransactionContext(ctx, tx)
+
+		err := s.db.CreateReport(tctx, benchmark.ID, report)
+		if err != nil {
+			return errors.Wrap(err, "failed to create report")
+		}
+
+		err = s.db.CreateTrace(tctx, benchmark.ID, trace)
+		if err != nil {
+			return errors.Wrap(err, "failed to create trace")
+		}
+
+		for id, kinds := range profiles {
+			for kind, profile := range kinds {
+				err = s.db.CreateProfile(tctx, benchmark.ID, id, kind, profile)
+				if err != nil {
+					return errors.Wrapf(err, "failed to create %s profile of node %q", kind, id)
+				}
+			}
+		}
+
+		benchmark.Status = status
+		_, err = s.db.UpdateBenchmark(tctx, benchmark)
+		if err != nil {
+			return errors.Wrap(err, "failed to update benchmark")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return bid, err
+	}
+	result = status
+
+	return bid, nil
+}
+
+// notifyTimeout is how long the notifications of a completed benchmark are
+// posted for.
+const notifyTimeout = 30 * time.Second
+
+// notify posts a summary of a completed benchmark and the report it stored,
+// if any, logging any failure to notify.
+func (s *router) notify(ctx context.Context, benchmark metadata.Benchmark, status metadata.BenchmarkStatus, cause error) {
+	// The benchmark's context is cancelled once it completes, so the
+	// notifications are posted with a context of their own.
+	nctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	nctx = zerolog.Ctx(ctx).WithContext(nctx)
+
+	benchmark.Status = status
+	if status != metadata.BenchmarkError {
+		cause = nil
+	}
+
+	report, err := s.db.GetReport(nctx, benchmark.ID)
+	if err != nil && !errdefs.IsNotFound(err) {
+		zerolog.Ctx(nctx).Warn().Err(err).Msg("Failed to get report to notify")
 	}
 
-	zerolog.Ctx(ctx).Info().Msg("Creating benchmark metadata")
-	benchmark, err = s.db.CreateBenchmark(ctx, benchmark)
+	err = s.notifier.Notify(nctx, benchmark, report, cause)
 	if err != nil {
-		return err
+		zerolog.Ctx(nctx).Warn().Err(err).Msg("Failed to notify of benchmark")
+		return
 	}
+	zerolog.Ctx(nctx).Info().Str("status", string(status)).Msg("Notified of benchmark")
+}
 
-	var seederAddrs []string
-	for _, addr := range s.seeder.Host().Addrs() {
-		seederAddrs = append(seederAddrs, fmt.Sprintf("%s/p2p/%s", addr, s.seeder.Host().ID()))
+// abortTimeout is how long the partial reports of an aborted benchmark are
+// collected for.
+const abortTimeout = time.Minute
+
+// abortBenchmark collects the partial reports of the nodes of a cancelled
+// benchmark, and marks it as aborted.
+func (s *router) abortBenchmark(ctx context.Context, benchmark metadata.Benchmark, ns []p2plab.Node, queries map[string][]string) error {
+	zerolog.Ctx(ctx).Warn().Msg("Benchmark was cancelled")
+	if benchmark.ID == "" {
+		return errors.New("benchmark was cancelled before it started")
 	}
 
-	zerolog.Ctx(ctx).Info().Msg("Executing scenario plan")
-	execution, err := scenarios.Run(ctx, lset, plan, seederAddrs)
+	// The benchmark's context is cancelled, so the partial reports are
+	// collected with a context of their own.
+	actx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	actx = zerolog.Ctx(ctx).WithContext(actx)
+
+	zerolog.Ctx(actx).Info().Msg("Collecting partial reports")
+	reportByNodeID, err := nodes.CollectReports(actx, ns)
 	if err != nil {
-		return errors.Wrap(err, "failed to run scenario plan")
+		zerolog.Ctx(actx).Warn().Err(err).Msg("Failed to collect partial reports")
 	}
 
 	report := metadata.Report{
 		Summary: metadata.ReportSummary{
-			TotalTime: execution.End.Sub(execution.Start),
+			TotalTime: time.Since(benchmark.CreatedAt),
 		},
-		Nodes:   execution.Report,
+		Nodes:   reportByNodeID,
 		Queries: queries,
 	}
 	report.Aggregates = reports.ComputeAggregates(report.Nodes)
+	report.Bandwidth = reports.ComputeBandwidthMatrix(report.Nodes)
+	report.Topology.End = nodes.CollectTopology(actx, ns)
 
-	jaegerUI := os.Getenv("JAEGER_UI")
-	if jaegerUI != "" {
-		sc, ok := execution.Span.Context().(jaeger.SpanContext)
-		if ok {
-			report.Summary.Trace = fmt.Sprintf("%s/trace/%s", jaegerUI, sc.TraceID())
-		}
-	}
-
-	zerolog.Ctx(ctx).Info().Msg("Updating benchmark metadata")
-	err = s.db.Update(ctx, func(tx *bolt.Tx) error {
-		tctx := metadata.WithTransactionContext(ctx, tx)
+	err = s.db.Update(actx, func(tx *bolt.Tx) error {
+		tctx := metadata.WithTransactionContext(actx, tx)
 
 		err := s.db.CreateReport(tctx, benchmark.ID, report)
 		if err != nil {
 			return errors.Wrap(err, "failed to create report")
 		}
 
-		benchmark.Status = metadata.BenchmarkDone
+		benchmark.Status = metadata.BenchmarkAborted
 		_, err = s.db.UpdateBenchmark(tctx, benchmark)
 		if err != nil {
 			return errors.Wrap(err, "failed to update benchmark")
@@ -228,7 +825,33 @@ func (s *router) postBenchmarksCreate(ctx context.Context, w http.ResponseWriter
 		return err
 	}
 
-	return nil
+	return errors.Errorf("benchmark %q was aborted", benchmark.ID)
+}
+
+// putBenchmarkCancel aborts a running benchmark. A benchmark that is marked
+// as running but is not running on this labd was interrupted by labd
+// restarting, and is marked as aborted without partial reports.
+func (s *router) putBenchmarkCancel(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	id := vars["id"]
+	if s.progress.abort(id) {
+		zerolog.Ctx(ctx).Info().Str("bid", id).Msg("Cancelled benchmark")
+		return nil
+	}
+
+	benchmark, err := s.db.GetBenchmark(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	switch benchmark.Status {
+	case metadata.BenchmarkPlanning, metadata.BenchmarkRunning:
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "benchmark %q has status %q and is not running", id, benchmark.Status)
+	}
+
+	benchmark.Status = metadata.BenchmarkAborted
+	_, err = s.db.UpdateBenchmark(ctx, benchmark)
+	return err
 }
 
 func (s *router) putBenchmarksLabel(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
@@ -248,6 +871,127 @@ func (s *router) putBenchmarksLabel(ctx context.Context, w http.ResponseWriter,
 	return daemon.WriteJSON(w, &benchmarks)
 }
 
+func (s *router) putBenchmarkPin(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	benchmark, err := s.db.GetBenchmark(ctx, vars["id"])
+	if err != nil {
+		return err
+	}
+
+	switch benchmark.Status {
+	case metadata.BenchmarkDone, metadata.BenchmarkFailed:
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "benchmark %q has status %q and cannot be a baseline", benchmark.ID, benchmark.Status)
+	}
+
+	scenario, err := s.db.GetScenario(ctx, benchmark.Scenario.ID)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("scenario", scenario.ID).Str("bid", benchmark.ID).Msg("Pinning baseline")
+	scenario.Baseline = benchmark.ID
+	scenario, err = s.db.UpdateScenario(ctx, scenario)
+	if err != nil {
+		return err
+	}
+
+	return daemon.WriteJSON(w, &scenario)
+}
+
+func (s *router) putBenchmarkUnpin(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	benchmark, err := s.db.GetBenchmark(ctx, vars["id"])
+	if err != nil {
+		return err
+	}
+
+	scenario, err := s.db.GetScenario(ctx, benchmark.Scenario.ID)
+	if err != nil {
+		return err
+	}
+
+	if scenario.Baseline != benchmark.ID {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "benchmark %q is not the baseline of scenario %q", benchmark.ID, scenario.ID)
+	}
+
+	zerolog.Ctx(ctx).Info().Str("scenario", scenario.ID).Str("bid", benchmark.ID).Msg("Unpinning baseline")
+	scenario.Baseline = ""
+	scenario, err = s.db.UpdateScenario(ctx, scenario)
+	if err != nil {
+		return err
+	}
+
+	return daemon.WriteJSON(w, &scenario)
+}
+
+// publishedFile is a file of a benchmark's results that is published.
+type publishedFile struct {
+	name        string
+	contentType string
+	write       func(w io.Writer) error
+}
+
+func (s *router) putBenchmarkPublish(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if s.publisher == nil {
+		return errors.Wrap(errdefs.ErrUnavailable, "labd has no publisher configured")
+	}
+
+	benchmark, err := s.db.GetBenchmark(ctx, vars["id"])
+	if err != nil {
+		return err
+	}
+
+	report, err := s.db.GetReport(ctx, benchmark.ID)
+	if err != nil {
+		return err
+	}
+
+	files := []publishedFile{
+		{"report.html", "text/html; charset=utf-8", func(w io.Writer) error {
+			return printer.WriteHTMLReport(w, benchmark, report)
+		}},
+		{"report.json", "application/json", func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(&report)
+		}},
+		{"nodes.csv", "text/csv", func(w io.Writer) error {
+			return printer.ExportNodes(report).Write(w, printer.ExportCSV)
+		}},
+	}
+
+	// Benchmarks that failed to run to completion may not have a trace.
+	trace, err := s.db.GetTrace(ctx, benchmark.ID)
+	if err == nil {
+		files = append(files, publishedFile{"trace.json", "application/json", func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(&trace)
+		}})
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	published := make(map[string]string)
+	for _, f := range files {
+		var buf bytes.Buffer
+		err = f.write(&buf)
+		if err != nil {
+			return errors.Wrapf(err, "failed to render %s", f.name)
+		}
+
+		url, err := s.publisher.Publish(ctx, path.Join(benchmark.ID, f.name), f.contentType, &buf)
+		if err != nil {
+			return err
+		}
+		published[f.name] = url
+	}
+
+	zerolog.Ctx(ctx).Info().Str("bid", benchmark.ID).Str("url", published["report.html"]).Msg("Published benchmark")
+	benchmark.Published = published
+	benchmark, err = s.db.UpdateBenchmark(ctx, benchmark)
+	if err != nil {
+		return err
+	}
+
+	return daemon.WriteJSON(w, &benchmark)
+}
+
 func (s *router) deleteBenchmarks(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	ids := strings.Split(r.FormValue("ids"), ",")
 
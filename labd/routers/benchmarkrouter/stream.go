@@ -0,0 +1,164 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmarkrouter
+
+import (
+	"context"
+	"sync"
+)
+
+// streamSize is how many of the most recent log lines of a running benchmark
+// are kept for clients attaching to it.
+const streamSize = 10000
+
+// streamTracker keeps the logs of the benchmarks that are running, so that
+// clients can attach to a benchmark they did not start, and resume from the
+// last line they received if they are disconnected.
+type streamTracker struct {
+	mu      sync.Mutex
+	streams map[string]*logStream
+}
+
+func newStreamTracker() *streamTracker {
+	return &streamTracker{
+		streams: make(map[string]*logStream),
+	}
+}
+
+// open starts keeping the logs of a benchmark that are written to the
+// returned stream, and returns a function that ends the stream once the
+// benchmark is no longer running.
+func (t *streamTracker) open(id string) (*logStream, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stream := &logStream{
+		added: make(chan struct{}),
+	}
+	t.streams[id] = stream
+
+	return stream, func() {
+		t.mu.Lock()
+		delete(t.streams, id)
+		t.mu.Unlock()
+
+		stream.close()
+	}
+}
+
+func (t *streamTracker) get(id string) (*logStream, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stream, ok := t.streams[id]
+	return stream, ok
+}
+
+// logStream is the log of a benchmark, where each line is addressed by its
+// offset from the first line logged.
+type logStream struct {
+	mu     sync.Mutex
+	lines  [][]byte
+	offset int
+	closed bool
+	added  chan struct{}
+}
+
+// Write adds a line to the stream. Loggers write each line in a single call.
+func (s *logStream) Write(p []byte) (int, error) {
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lines = append(s.lines, line)
+	if len(s.lines) > streamSize {
+		n := len(s.lines) - streamSize
+		s.lines = append(s.lines[:0], s.lines[n:]...)
+		s.offset += n
+	}
+
+	// Wake up followers by closing the channel they wait on.
+	close(s.added)
+	s.added = make(chan struct{})
+	return len(p), nil
+}
+
+// start returns the offset that following from offset starts at, which is
+// later if the lines before it are no longer kept.
+func (s *logStream) start(offset int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if offset < s.offset {
+		return s.offset
+	}
+	return offset
+}
+
+// follow calls fn with the lines from offset, and then with each line as it
+// is logged until the stream ends, ctx is cancelled or fn fails.
+func (s *logStream) follow(ctx context.Context, offset int, fn func(line []byte) error) error {
+	for {
+		start, lines, closed, added := s.since(offset)
+		offset = start
+		for _, line := range lines {
+			err := fn(line)
+			if err != nil {
+				return err
+			}
+			offset++
+		}
+
+		if closed {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-added:
+		}
+	}
+}
+
+// since returns the lines from offset, or from the oldest line kept if it is
+// later, along with the offset of the first line returned.
+func (s *logStream) since(offset int) (int, [][]byte, bool, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if offset < s.offset {
+		offset = s.offset
+	}
+	i := offset - s.offset
+	if i > len(s.lines) {
+		i = len(s.lines)
+	}
+
+	lines := make([][]byte, len(s.lines)-i)
+	copy(lines, s.lines[i:])
+	return offset, lines, s.closed, s.added
+}
+
+func (s *logStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	close(s.added)
+	s.added = make(chan struct{})
+}
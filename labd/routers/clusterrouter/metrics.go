@@ -0,0 +1,35 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterrouter
+
+import "github.com/Netflix/p2plab/pkg/metrics"
+
+// clusterMetrics measures the provisioning of clusters.
+type clusterMetrics struct {
+	provisionDuration *metrics.Histogram
+	createDuration    *metrics.Histogram
+	replacedNodes     *metrics.Counter
+	failedNodes       *metrics.Counter
+}
+
+func newClusterMetrics(reg *metrics.Registry) *clusterMetrics {
+	buckets := metrics.ExponentialBuckets(5, 2, 10)
+	return &clusterMetrics{
+		provisionDuration: reg.NewHistogram("labd_cluster_provision_duration_seconds", "Time taken by the provider to create each cluster's node group.", buckets),
+		createDuration:    reg.NewHistogram("labd_cluster_create_duration_seconds", "Time taken to create each cluster until its nodes are healthy.", buckets),
+		replacedNodes:     reg.NewCounter("labd_cluster_replaced_nodes_total", "Nodes replaced because they failed to provision."),
+		failedNodes:       reg.NewCounter("labd_cluster_failed_nodes_total", "Nodes that failed to provision after every retry."),
+	}
+}
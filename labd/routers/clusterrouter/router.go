@@ -18,15 +18,21 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/costs"
 	"github.com/Netflix/p2plab/daemon"
+	"github.com/Netflix/p2plab/errdefs"
 	"github.com/Netflix/p2plab/labd/controlapi"
+	"github.com/Netflix/p2plab/labd/pool"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/nodes"
 	"github.com/Netflix/p2plab/pkg/httputil"
 	"github.com/Netflix/p2plab/pkg/logutil"
+	"github.com/Netflix/p2plab/pkg/metrics"
 	"github.com/Netflix/p2plab/pkg/stringutil"
 	"github.com/Netflix/p2plab/query"
 	"github.com/pkg/errors"
@@ -38,21 +44,31 @@ type router struct {
 	db       metadata.DB
 	provider p2plab.NodeProvider
 	client   *httputil.Client
+	builder  p2plab.Builder
+	pool     *pool.Pool
+	metrics  *clusterMetrics
 }
 
-func New(db metadata.DB, provider p2plab.NodeProvider, client *httputil.Client) daemon.Router {
-	return &router{db, provider, client}
+func New(db metadata.DB, provider p2plab.NodeProvider, client *httputil.Client, builder p2plab.Builder, pool *pool.Pool, reg *metrics.Registry) daemon.Router {
+	return &router{db, provider, client, builder, pool, newClusterMetrics(reg)}
 }
 
 func (s *router) Routes() []daemon.Route {
 	return []daemon.Route{
 		// GET
 		daemon.NewGetRoute("/clusters/json", s.getClusters),
+		daemon.NewGetRoute("/clusters/cost", s.getClustersCost),
 		daemon.NewGetRoute("/clusters/{name}/json", s.getCluster),
+		daemon.NewGetRoute("/clusters/{name}/health", s.getClusterHealth),
 		// POST
 		daemon.NewPostRoute("/clusters/create", s.postClustersCreate),
+		daemon.NewPostRoute("/clusters/checkout", s.postClustersCheckout),
+		daemon.NewPostRoute("/clusters/plan", s.postClustersPlan),
 		// PUT
 		daemon.NewPutRoute("/clusters/label", s.putClustersLabel),
+		daemon.NewPutRoute("/clusters/return", s.putClustersReturn),
+		daemon.NewPutRoute("/clusters/pause", s.putClustersPause),
+		daemon.NewPutRoute("/clusters/resume", s.putClustersResume),
 		// DELETE
 		daemon.NewDeleteRoute("/clusters/delete", s.deleteClusters),
 	}
@@ -64,19 +80,84 @@ func (s *router) getClusters(ctx context.Context, w http.ResponseWriter, r *http
 		return err
 	}
 
+	now := time.Now().UTC()
+	for i, cluster := range matchedClusters {
+		matchedClusters[i].Cost = accrueCost(cluster, now)
+	}
+
 	return daemon.WriteJSON(w, &matchedClusters)
 }
 
+func (s *router) getClustersCost(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	cs, err := s.db.ListClusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	report := metadata.CostReport{
+		Clusters: make(map[string]metadata.ClusterCost),
+	}
+
+	now := time.Now().UTC()
+	for _, cluster := range cs {
+		cost := accrueCost(cluster, now)
+		report.Hourly += cost.Hourly
+		report.Accrued += cost.Accrued
+		report.Clusters[cluster.ID] = cost
+	}
+
+	return daemon.WriteJSON(w, &report)
+}
+
 func (s *router) getCluster(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	id := vars["name"]
 	cluster, err := s.db.GetCluster(ctx, id)
 	if err != nil {
 		return err
 	}
+	cluster.Cost = accrueCost(cluster, time.Now().UTC())
 
 	return daemon.WriteJSON(w, &cluster)
 }
 
+// healthTimeout bounds how long a healthcheck waits for unresponsive agents,
+// which are otherwise retried for minutes while a cluster is provisioned.
+const healthTimeout = 10 * time.Second
+
+func (s *router) getClusterHealth(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	id := vars["name"]
+	_, err := s.db.GetCluster(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	mns, err := s.db.ListNodes(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var ns []p2plab.Node
+	for _, n := range mns {
+		ns = append(ns, controlapi.NewNode(s.client, n))
+	}
+
+	hctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+
+	health := metadata.ClusterHealth{Cluster: id}
+	healthy, unhealthy := nodes.FilterHealthy(hctx, ns)
+	for _, n := range healthy {
+		health.Healthy = append(health.Healthy, n.ID())
+	}
+	for _, n := range unhealthy {
+		health.Unhealthy = append(health.Unhealthy, n.ID())
+	}
+	sort.Strings(health.Healthy)
+	sort.Strings(health.Unhealthy)
+
+	return daemon.WriteJSON(w, &health)
+}
+
 func (s *router) postClustersCreate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	var cdef metadata.ClusterDefinition
 	err := json.NewDecoder(r.Body).Decode(&cdef)
@@ -84,6 +165,11 @@ func (s *router) postClustersCreate(ctx context.Context, w http.ResponseWriter,
 		return err
 	}
 
+	// A detached cluster keeps being created if the client disconnects.
+	if r.FormValue("detach") == "true" {
+		ctx = daemon.Detach(ctx)
+	}
+
 	name := r.FormValue("name")
 	ctx, logger := logutil.WithResponseLogger(ctx, w)
 	logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
@@ -94,6 +180,10 @@ func (s *router) postClustersCreate(ctx context.Context, w http.ResponseWriter,
 		ID:         name,
 		Status:     metadata.ClusterCreating,
 		Definition: cdef,
+		Cost: metadata.ClusterCost{
+			Hourly:    costs.EstimateHourly(cdef),
+			AccruedAt: time.Now().UTC(),
+		},
 		Labels: append([]string{
 			name,
 		}, cdef.GenerateLabels()...),
@@ -106,10 +196,18 @@ func (s *router) postClustersCreate(ctx context.Context, w http.ResponseWriter,
 	w.Header().Add(controlapi.ResourceID, name)
 
 	zerolog.Ctx(ctx).Info().Msg("Creating node group")
+	start := time.Now()
 	ng, err := s.provider.CreateNodeGroup(ctx, name, cdef)
 	if err != nil {
 		return err
 	}
+	s.metrics.provisionDuration.Observe(time.Since(start).Seconds())
+
+	// The network stack is a property of the cluster's network, so every peer
+	// must listen on it.
+	for i := range ng.Nodes {
+		ng.Nodes[i].Peer.NetworkStack = cdef.NetworkStack
+	}
 
 	zerolog.Ctx(ctx).Info().Msg("Updating metadata with new nodes")
 	var mns []metadata.Node
@@ -138,28 +236,298 @@ func (s *router) postClustersCreate(ctx context.Context, w http.ResponseWriter,
 		ns = append(ns, controlapi.NewNode(s.client, n))
 	}
 
-	err = nodes.WaitHealthy(ctx, ns)
-	if err != nil {
-		return err
+	healthy, unhealthy := nodes.FilterHealthy(ctx, ns)
+	for retry := 0; retry < cdef.Retries && len(healthy) < cdef.Size(); retry++ {
+		zerolog.Ctx(ctx).Info().Int("retry", retry+1).Int("healthy", len(healthy)).Int("size", cdef.Size()).Msg("Replacing failed nodes")
+		healthy, unhealthy, err = s.replaceNodes(ctx, ng, cdef, healthy, unhealthy)
+		if err != nil {
+			return err
+		}
+	}
+
+	failures := cdef.Size() - len(healthy)
+	s.metrics.failedNodes.Add(float64(failures))
+	if failures > cdef.MaxFailures() {
+		cluster.Status = metadata.ClusterError
+		_, err = s.db.UpdateCluster(ctx, cluster)
+		if err != nil {
+			return err
+		}
+		return errors.Wrapf(errdefs.ErrUnavailable, "%d of %d nodes failed to provision, exceeding tolerance of %d", failures, cdef.Size(), cdef.MaxFailures())
 	}
 
-	zerolog.Ctx(ctx).Info().Msg("Updating cluster metadata")
 	cluster.Status = metadata.ClusterCreated
+	if failures > 0 {
+		zerolog.Ctx(ctx).Warn().Int("failures", failures).Msg("Cluster is degraded, removing failed nodes")
+		err = s.db.DeleteNodes(ctx, cluster.ID, nodeIDs(unhealthy)...)
+		if err != nil {
+			return err
+		}
+		cluster.Status = metadata.ClusterDegraded
+	}
+
+	zerolog.Ctx(ctx).Info().Msg("Updating cluster metadata")
 	_, err = s.db.UpdateCluster(ctx, cluster)
 	if err != nil {
 		return err
 	}
+	s.metrics.createDuration.Observe(time.Since(start).Seconds())
 
 	return nil
 }
 
-func (s *router) putClustersLabel(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+// replaceNodes asks the provider to replace unhealthy nodes and nodes that
+// never launched, and swaps the replacements into the cluster's metadata.
+func (s *router) replaceNodes(ctx context.Context, ng *p2plab.NodeGroup, cdef metadata.ClusterDefinition, healthy, unhealthy []p2plab.Node) ([]p2plab.Node, []p2plab.Node, error) {
+	var failed []metadata.Node
+	for _, n := range unhealthy {
+		failed = append(failed, n.Metadata())
+	}
+
+	ng.Nodes = nil
+	for _, n := range append(healthy, unhealthy...) {
+		ng.Nodes = append(ng.Nodes, n.Metadata())
+	}
+
+	replacements, err := s.provider.ReplaceNodes(ctx, ng, cdef, failed)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.metrics.replacedNodes.Add(float64(len(replacements)))
+
+	for i := range replacements {
+		replacements[i].Peer.NetworkStack = cdef.NetworkStack
+	}
+
+	var mns []metadata.Node
+	err = s.db.Update(ctx, func(tx *bolt.Tx) error {
+		tctx := metadata.WithTransactionContext(ctx, tx)
+		err := s.db.DeleteNodes(tctx, ng.ID, nodeIDs(unhealthy)...)
+		if err != nil {
+			return err
+		}
+
+		mns, err = s.db.CreateNodes(tctx, ng.ID, replacements)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ns []p2plab.Node
+	for _, n := range mns {
+		ns = append(ns, controlapi.NewNode(s.client, n))
+	}
+
+	replacedHealthy, replacedUnhealthy := nodes.FilterHealthy(ctx, ns)
+	return append(healthy, replacedHealthy...), replacedUnhealthy, nil
+}
+
+func nodeIDs(ns []p2plab.Node) []string {
+	var ids []string
+	for _, n := range ns {
+		ids = append(ids, n.ID())
+	}
+	return ids
+}
+
+func (s *router) postClustersPlan(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var cdef metadata.ClusterDefinition
+	err := json.NewDecoder(r.Body).Decode(&cdef)
+	if err != nil {
+		return err
+	}
+
+	name := r.FormValue("name")
+	cluster := metadata.Cluster{
+		ID:         name,
+		Definition: cdef,
+	}
+
+	err = cluster.Validate()
+	if err != nil {
+		return err
+	}
+
+	// A destroyed cluster's name can be reused, as when it is created.
+	existing, err := s.db.GetCluster(ctx, name)
+	if err == nil {
+		if existing.Status != metadata.ClusterDestroyed {
+			return errors.Wrapf(errdefs.ErrAlreadyExists, "cluster %q", name)
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	plan, err := s.provider.PlanNodeGroup(ctx, name, cdef)
+	if err != nil {
+		return err
+	}
+	plan.HourlyCost = costs.EstimateHourly(cdef)
+
+	return daemon.WriteJSON(w, plan)
+}
+
+func (s *router) postClustersCheckout(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var cdef metadata.ClusterDefinition
+	err := json.NewDecoder(r.Body).Decode(&cdef)
+	if err != nil {
+		return err
+	}
+
+	cluster, err := s.pool.Checkout(ctx, r.FormValue("name"), cdef)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			// An empty response without a resource ID signals that the client
+			// should create a new cluster.
+			return nil
+		}
+		return err
+	}
+	w.Header().Add(controlapi.ResourceID, cluster.ID)
+
+	return daemon.WriteJSON(w, &cluster)
+}
+
+func (s *router) putClustersReturn(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	names := strings.Split(r.FormValue("names"), ",")
+	for _, name := range names {
+		err := s.pool.Return(ctx, name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to return cluster %q", name)
+		}
+	}
+
+	return nil
+}
+
+func (s *router) putClustersPause(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	names := strings.Split(r.FormValue("names"), ",")
+
+	ctx, logger := logutil.WithResponseLogger(ctx, w)
+	for _, name := range names {
+		logger := logger.With().Str("name", name).Logger()
+		ctx = logger.WithContext(ctx)
+
+		cluster, err := s.db.GetCluster(ctx, name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get cluster %q", name)
+		}
+
+		if cluster.Status != metadata.ClusterCreated && cluster.Status != metadata.ClusterDegraded {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster %q is %s and cannot be paused", name, cluster.Status)
+		}
+
+		logger.Info().Msg("Stopping node group")
+		err = s.provider.StopNodeGroup(ctx, cluster.ID, cluster.Definition)
+		if err != nil {
+			return errors.Wrap(err, "failed to stop node group")
+		}
+
+		cluster.Status = metadata.ClusterPaused
+		cluster.Cost = cluster.Cost.Accrue(time.Now().UTC())
+		_, err = s.db.UpdateCluster(ctx, cluster)
+		if err != nil {
+			return errors.Wrap(err, "failed to update cluster status to paused")
+		}
+
+		logger.Info().Msg("Paused cluster")
+	}
+
+	return nil
+}
+
+func (s *router) putClustersResume(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	names := strings.Split(r.FormValue("names"), ",")
+
+	ctx, logger := logutil.WithResponseLogger(ctx, w)
+	for _, name := range names {
+		logger := logger.With().Str("name", name).Logger()
+		ctx = logger.WithContext(ctx)
+
+		cluster, err := s.db.GetCluster(ctx, name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get cluster %q", name)
+		}
+
+		if cluster.Status != metadata.ClusterPaused {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster %q is %s and cannot be resumed", name, cluster.Status)
+		}
+
+		logger.Info().Msg("Starting node group")
+		err = s.provider.StartNodeGroup(ctx, cluster.ID, cluster.Definition)
+		if err != nil {
+			return errors.Wrap(err, "failed to start node group")
+		}
+
+		// Paused time is not billed, so the cost accrues again from now.
+		cluster.Status = metadata.ClusterConnecting
+		cluster.Cost.AccruedAt = time.Now().UTC()
+		cluster, err = s.db.UpdateCluster(ctx, cluster)
+		if err != nil {
+			return errors.Wrap(err, "failed to update cluster status to connecting")
+		}
+
+		mns, err := s.db.ListNodes(ctx, cluster.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to list nodes")
+		}
+
+		var ns []p2plab.Node
+		for _, n := range mns {
+			ns = append(ns, controlapi.NewNode(s.client, n))
+		}
+
+		err = nodes.WaitHealthy(ctx, ns)
+		if err != nil {
+			return err
+		}
+
+		logger.Info().Msg("Restarting peers")
+		err = nodes.Update(ctx, s.builder, ns)
+		if err != nil {
+			return errors.Wrap(err, "failed to update nodes")
+		}
+
+		err = nodes.Connect(ctx, ns)
+		if err != nil {
+			return errors.Wrap(err, "failed to connect nodes")
+		}
+
+		cluster.Status = cluster.RunningStatus(len(mns))
+		_, err = s.db.UpdateCluster(ctx, cluster)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update cluster status to %s", cluster.Status)
+		}
+
+		logger.Info().Msg("Resumed cluster")
+	}
+
+	return nil
+}
+
+func (s *router) putClustersLabel(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	names := stringutil.Coalesce(strings.Split(r.FormValue("names"), ","))
 	addLabels := stringutil.Coalesce(strings.Split(r.FormValue("adds"), ","))
 	removeLabels := stringutil.Coalesce(strings.Split(r.FormValue("removes"), ","))
 
+	// Clusters matching the query are labeled along with the given names, all
+	// in the same transaction.
+	if r.FormValue("query") != "" {
+		matchedClusters, err := s.matchClusters(ctx, r.FormValue("query"))
+		if err != nil {
+			return err
+		}
+
+		for _, c := range matchedClusters {
+			if !stringutil.Contains(names, c.ID) {
+				names = append(names, c.ID)
+			}
+		}
+	}
+
 	var clusters []metadata.Cluster
-	if len(addLabels) > 0 || len(removeLabels) > 0 {
+	if len(names) > 0 && (len(addLabels) > 0 || len(removeLabels) > 0) {
 		var err error
 		clusters, err = s.db.LabelClusters(ctx, names, addLabels, removeLabels)
 		if err != nil {
@@ -185,6 +553,16 @@ func (s *router) deleteClusters(ctx context.Context, w http.ResponseWriter, r *h
 			return errors.Wrapf(err, "failed to get cluster %q", name)
 		}
 
+		// Removing a destroyed cluster again forgets its accrued cost.
+		if cluster.Status == metadata.ClusterDestroyed {
+			logger.Info().Msg("Deleting cluster metadata")
+			err = s.db.DeleteCluster(ctx, cluster.ID)
+			if err != nil {
+				return errors.Wrap(err, "failed to delete cluster metadata")
+			}
+			continue
+		}
+
 		if cluster.Status != metadata.ClusterDestroying {
 			cluster.Status = metadata.ClusterDestroying
 			cluster, err = s.db.UpdateCluster(ctx, cluster)
@@ -209,10 +587,25 @@ func (s *router) deleteClusters(ctx context.Context, w http.ResponseWriter, r *h
 			return errors.Wrap(err, "failed to destroy node group")
 		}
 
-		logger.Info().Msg("Deleting cluster metadata")
-		err = s.db.DeleteCluster(ctx, cluster.ID)
+		var ids []string
+		for _, n := range ns {
+			ids = append(ids, n.ID)
+		}
+
+		logger.Info().Msg("Deleting node metadata")
+		err = s.db.DeleteNodes(ctx, cluster.ID, ids...)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete node metadata")
+		}
+
+		// The cluster record is kept with its final accrued cost so that the
+		// cost report still accounts for it.
+		cluster.Cost = accrueCost(cluster, time.Now().UTC())
+		cluster.Cost.Hourly = 0
+		cluster.Status = metadata.ClusterDestroyed
+		_, err = s.db.UpdateCluster(ctx, cluster)
 		if err != nil {
-			return errors.Wrap(err, "failed to delete cluster metadata")
+			return errors.Wrap(err, "failed to update cluster status to destroyed")
 		}
 
 		logger.Info().Msg("Destroyed cluster")
@@ -221,6 +614,16 @@ func (s *router) deleteClusters(ctx context.Context, w http.ResponseWriter, r *h
 	return nil
 }
 
+// accrueCost returns the cost of a cluster accrued until now. Paused and
+// destroyed clusters don't accrue cost.
+func accrueCost(cluster metadata.Cluster, now time.Time) metadata.ClusterCost {
+	switch cluster.Status {
+	case metadata.ClusterPaused, metadata.ClusterDestroyed:
+		return cluster.Cost
+	}
+	return cluster.Cost.Accrue(now)
+}
+
 func (s *router) matchClusters(ctx context.Context, q string) ([]metadata.Cluster, error) {
 	cs, err := s.db.ListClusters(ctx)
 	if err != nil {
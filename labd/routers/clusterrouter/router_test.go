@@ -0,0 +1,83 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterrouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+// planProvider is a provider that can only plan node groups.
+type planProvider struct {
+	p2plab.NodeProvider
+}
+
+func (p *planProvider) PlanNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*p2plab.NodeGroupPlan, error) {
+	return &p2plab.NodeGroupPlan{ID: id}, nil
+}
+
+func TestPlanClusterName(t *testing.T) {
+	root, err := ioutil.TempDir("", "clusterrouter-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := metadata.NewDB(root)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	cdef := metadata.ClusterDefinition{
+		Groups: []metadata.ClusterGroup{
+			{Size: 1, InstanceType: "t2.micro", Region: "us-west-2"},
+		},
+	}
+	_, err = db.CreateCluster(ctx, metadata.Cluster{ID: "running", Status: metadata.ClusterCreated, Definition: cdef})
+	require.NoError(t, err)
+	_, err = db.CreateCluster(ctx, metadata.Cluster{ID: "destroyed", Status: metadata.ClusterDestroyed, Definition: cdef})
+	require.NoError(t, err)
+
+	s := &router{db: db, provider: &planProvider{}}
+	content, err := json.Marshal(&cdef)
+	require.NoError(t, err)
+
+	plan := func(name string) (*httptest.ResponseRecorder, error) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/clusters/plan?name="+name, bytes.NewReader(content))
+		return w, s.postClustersPlan(ctx, w, r, nil)
+	}
+
+	_, err = plan("running")
+	require.True(t, errdefs.IsAlreadyExists(err), "unexpected error: %v", err)
+
+	for _, name := range []string{"destroyed", "new"} {
+		w, err := plan(name)
+		require.NoError(t, err, name)
+
+		var p p2plab.NodeGroupPlan
+		err = json.Unmarshal(w.Body.Bytes(), &p)
+		require.NoError(t, err, name)
+		require.Equal(t, name, p.ID)
+	}
+}
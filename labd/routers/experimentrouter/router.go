@@ -16,33 +16,32 @@ package experimentrouter
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/daemon"
+	"github.com/Netflix/p2plab/experiments"
+	"github.com/Netflix/p2plab/labd/controlapi"
 	"github.com/Netflix/p2plab/metadata"
-	"github.com/Netflix/p2plab/peer"
-	"github.com/Netflix/p2plab/pkg/httputil"
+	"github.com/Netflix/p2plab/pkg/logutil"
 	"github.com/Netflix/p2plab/pkg/stringutil"
 	"github.com/Netflix/p2plab/query"
-	"github.com/Netflix/p2plab/transformers"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
 type router struct {
-	db       metadata.DB
-	provider p2plab.NodeProvider
-	client   *httputil.Client
-	ts       *transformers.Transformers
-	seeder   *peer.Peer
-	builder  p2plab.Builder
+	db      metadata.DB
+	control p2plab.ControlAPI
 }
 
-func New(db metadata.DB, provider p2plab.NodeProvider, client *httputil.Client, ts *transformers.Transformers, seeder *peer.Peer, builder p2plab.Builder) daemon.Router {
-	return &router{db, provider, client, ts, seeder, builder}
+// New returns a router for experiments, which run their trials through
+// control so that each trial is an ordinary cluster and benchmark.
+func New(db metadata.DB, control p2plab.ControlAPI) daemon.Router {
+	return &router{db, control}
 }
 
 func (s *router) Routes() []daemon.Route {
@@ -50,6 +49,7 @@ func (s *router) Routes() []daemon.Route {
 		// GET
 		daemon.NewGetRoute("/experiments/json", s.getExperiments),
 		daemon.NewGetRoute("/experiments/{id}/json", s.getExperimentByName),
+		daemon.NewGetRoute("/experiments/{id}/report/json", s.getExperimentReport),
 		// POST
 		daemon.NewPostRoute("/experiments/create", s.postExperimentsCreate),
 		// PUT
@@ -78,8 +78,70 @@ func (s *router) getExperimentByName(ctx context.Context, w http.ResponseWriter,
 	return daemon.WriteJSON(w, &experiment)
 }
 
+func (s *router) getExperimentReport(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	experiment, err := s.db.GetExperiment(ctx, vars["id"])
+	if err != nil {
+		return err
+	}
+
+	reportByBenchmark := make(map[string]metadata.Report)
+	for _, trial := range experiment.Trials {
+		if trial.Status != metadata.ExperimentDone {
+			continue
+		}
+
+		report, err := s.db.GetReport(ctx, trial.Benchmark)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get report of benchmark %q", trial.Benchmark)
+		}
+		reportByBenchmark[trial.Benchmark] = report
+	}
+
+	report := metadata.NewExperimentReport(experiment, reportByBenchmark)
+	return daemon.WriteJSON(w, &report)
+}
+
 func (s *router) postExperimentsCreate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	return errors.New("unimplemented")
+	var edef metadata.ExperimentDefinition
+	err := json.NewDecoder(r.Body).Decode(&edef)
+	if err != nil {
+		return err
+	}
+
+	err = edef.Validate()
+	if err != nil {
+		return err
+	}
+
+	id := r.FormValue("id")
+	experiment, err := s.db.CreateExperiment(ctx, metadata.Experiment{
+		ID:         id,
+		Status:     metadata.ExperimentRunning,
+		Definition: edef,
+		Labels:     []string{id},
+	})
+	if err != nil {
+		return err
+	}
+	w.Header().Add(controlapi.ResourceID, id)
+
+	ctx, logger := logutil.WithResponseLogger(ctx, w)
+	logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Str("eid", id)
+	})
+
+	zerolog.Ctx(ctx).Info().Int("trials", len(edef.Variables())).Msg("Running experiment")
+	experiment, err = experiments.Run(ctx, s.db, s.control, experiment)
+	if err != nil {
+		return err
+	}
+
+	if experiment.Status != metadata.ExperimentDone {
+		zerolog.Ctx(ctx).Warn().Msg("Experiment completed with failed trials")
+		return nil
+	}
+	zerolog.Ctx(ctx).Info().Msg("Experiment completed")
+	return nil
 }
 
 func (s *router) putExperimentsLabel(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
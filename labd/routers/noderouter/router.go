@@ -18,14 +18,24 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/daemon"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/labd/controlapi"
 	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/nodes"
 	"github.com/Netflix/p2plab/pkg/httputil"
+	"github.com/Netflix/p2plab/pkg/logutil"
 	"github.com/Netflix/p2plab/pkg/stringutil"
 	"github.com/Netflix/p2plab/query"
+	"github.com/Netflix/p2plab/scenarios"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -42,6 +52,8 @@ func (s *router) Routes() []daemon.Route {
 	return []daemon.Route{
 		// GET
 		daemon.NewGetRoute("/clusters/{name}/nodes/json", s.getNodes),
+		daemon.NewGetRoute("/clusters/{name}/nodes/explain", s.getNodesExplain),
+		daemon.NewGetRoute("/clusters/{name}/nodes/logs", s.getNodesLogs),
 		daemon.NewGetRoute("/clusters/{name}/nodes/{id}/json", s.getNodeById),
 		// PUT
 		daemon.NewPutRoute("/clusters/{name}/nodes/label", s.putNodesLabel),
@@ -59,6 +71,119 @@ func (s *router) getNodes(ctx context.Context, w http.ResponseWriter, r *http.Re
 	return daemon.WriteJSON(w, &matchedNodes)
 }
 
+func (s *router) getNodesExplain(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	ns, err := s.db.ListNodes(ctx, vars["name"])
+	if err != nil {
+		return err
+	}
+
+	lset := query.NewLabeledSet()
+	for _, n := range ns {
+		lset.Add(query.NewAttributed(n.ID, n.Labels, n.Attributes()))
+	}
+
+	// Queries of a scenario may reference its selections and sample with its
+	// random seed.
+	sid := r.FormValue("scenario")
+	if sid != "" {
+		scenario, err := s.db.GetScenario(ctx, sid)
+		if err != nil {
+			return err
+		}
+
+		ctx = query.WithRandomSeed(ctx, scenario.Definition.RandomSeed)
+		ctx, err = scenarios.ResolveSelections(ctx, scenario.Definition.Selections, lset)
+		if err != nil {
+			return err
+		}
+	}
+
+	qry, err := query.Parse(ctx, r.FormValue("query"))
+	if err != nil {
+		return err
+	}
+
+	ctx, _, err = s.withClusters(ctx, r.FormValue("query"))
+	if err != nil {
+		return err
+	}
+
+	ctx = s.withTopology(ctx, ns, r.FormValue("query"))
+	explanation, err := query.Explain(ctx, qry, lset)
+	if err != nil {
+		return err
+	}
+
+	return daemon.WriteJSON(w, &explanation)
+}
+
+// getNodesLogs multiplexes the lines logged by the nodes matching a query,
+// written as JSON one per line with the node that logged them. Unless
+// following, the lines are sorted by the time they were logged.
+func (s *router) getNodesLogs(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	matchedNodes, err := s.matchNodes(ctx, vars["name"], r.FormValue("query"))
+	if err != nil {
+		return err
+	}
+
+	var opts []p2plab.LogsOption
+	if v := r.FormValue("since"); v != "" {
+		since, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "invalid since %q", v)
+		}
+		opts = append(opts, p2plab.WithLogsSince(since))
+	}
+	follow := r.FormValue("follow") == "true"
+	if follow {
+		opts = append(opts, p2plab.WithFollow())
+	}
+
+	var (
+		mu    sync.Mutex
+		lines []metadata.LogLine
+		wg    sync.WaitGroup
+	)
+	enc := json.NewEncoder(logutil.NewWriteFlusher(w))
+	for _, n := range matchedNodes {
+		wg.Add(1)
+		go func(n metadata.Node) {
+			defer wg.Done()
+
+			node := controlapi.NewNode(s.client, n)
+			err := node.Logs(ctx, func(line metadata.LogLine) error {
+				line.Node = n.ID
+
+				mu.Lock()
+				defer mu.Unlock()
+				if !follow {
+					lines = append(lines, line)
+					return nil
+				}
+				return enc.Encode(&line)
+			}, opts...)
+			if err != nil {
+				// A node that is unreachable shouldn't hide the logs of the
+				// others.
+				zerolog.Ctx(ctx).Warn().Err(err).Str("node", n.ID).Msg("Failed to read node logs")
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time.Before(lines[j].Time)
+	})
+	for _, line := range lines {
+		err := enc.Encode(&line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *router) getNodeById(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	clusterId, id := vars["name"], vars["id"]
 	node, err := s.db.GetNode(ctx, clusterId, id)
@@ -70,14 +195,33 @@ func (s *router) getNodeById(ctx context.Context, w http.ResponseWriter, r *http
 }
 
 func (s *router) putNodesLabel(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	ids := strings.Split(r.FormValue("ids"), ",")
+	clusterId := vars["name"]
+	ids := stringutil.Coalesce(strings.Split(r.FormValue("ids"), ","))
 	addLabels := stringutil.Coalesce(strings.Split(r.FormValue("adds"), ","))
 	removeLabels := stringutil.Coalesce(strings.Split(r.FormValue("removes"), ","))
 
+	// Nodes matching the query are labeled along with the given ids.
+	if r.FormValue("query") != "" {
+		matchedNodes, err := s.matchNodes(ctx, clusterId, r.FormValue("query"))
+		if err != nil {
+			return err
+		}
+
+		err = s.ownNodes(ctx, clusterId, matchedNodes)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range matchedNodes {
+			if !stringutil.Contains(ids, n.ID) {
+				ids = append(ids, n.ID)
+			}
+		}
+	}
+
 	var nodes []metadata.Node
-	if len(addLabels) > 0 || len(removeLabels) > 0 {
+	if len(ids) > 0 && (len(addLabels) > 0 || len(removeLabels) > 0) {
 		var err error
-		clusterId := vars["name"]
 		nodes, err = s.db.LabelNodes(ctx, clusterId, ids, addLabels, removeLabels)
 		if err != nil {
 			return err
@@ -100,6 +244,11 @@ func (s *router) putNodesUpdate(ctx context.Context, w http.ResponseWriter, r *h
 		return err
 	}
 
+	err = s.ownNodes(ctx, clusterId, matchedNodes)
+	if err != nil {
+		return err
+	}
+
 	var ns []metadata.Node
 	err = s.db.Update(ctx, func(tx *bolt.Tx) error {
 		tctx := metadata.WithTransactionContext(ctx, tx)
@@ -120,6 +269,9 @@ func (s *router) putNodesUpdate(ctx context.Context, w http.ResponseWriter, r *h
 			if pdef.Routing != "" {
 				n.Peer.Routing = pdef.Routing
 			}
+			if pdef.NetworkStack != "" {
+				n.Peer.NetworkStack = pdef.NetworkStack
+			}
 
 			var err error
 			n, err = s.db.UpdateNode(tctx, clusterId, n)
@@ -138,6 +290,8 @@ func (s *router) putNodesUpdate(ctx context.Context, w http.ResponseWriter, r *h
 	return daemon.WriteJSON(w, &ns)
 }
 
+// matchNodes returns the nodes of a cluster that match a query, along with
+// the nodes of other clusters that it selects with cluster queries.
 func (s *router) matchNodes(ctx context.Context, clusterId, q string) ([]metadata.Node, error) {
 	ns, err := s.db.ListNodes(ctx, clusterId)
 	if err != nil {
@@ -146,20 +300,86 @@ func (s *router) matchNodes(ctx context.Context, clusterId, q string) ([]metadat
 
 	var ls []p2plab.Labeled
 	for _, n := range ns {
-		ls = append(ls, query.NewLabeled(n.ID, n.Labels))
+		ls = append(ls, query.NewAttributed(n.ID, n.Labels, n.Attributes()))
 	}
 
+	ctx, others, err := s.withClusters(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = s.withTopology(ctx, ns, q)
 	mset, err := query.Execute(ctx, ls, q)
 	if err != nil {
 		return nil, err
 	}
 
 	var matchedNodes []metadata.Node
-	for _, n := range ns {
+	for _, n := range append(ns, others...) {
 		if mset.Contains(n.ID) {
 			matchedNodes = append(matchedNodes, n)
+			mset.Remove(n.ID)
 		}
 	}
 
 	return matchedNodes, nil
 }
+
+// withClusters returns a context with the nodes of the other clusters that
+// a query selects from, and those nodes.
+func (s *router) withClusters(ctx context.Context, q string) (context.Context, []metadata.Node, error) {
+	names := query.Clusters(q)
+	if len(names) == 0 {
+		return ctx, nil, nil
+	}
+
+	var (
+		others   []metadata.Node
+		clusters = make(map[string]p2plab.LabeledSet)
+	)
+	for _, name := range names {
+		if _, ok := clusters[name]; ok {
+			continue
+		}
+
+		_, err := s.db.GetCluster(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ns, err := s.db.ListNodes(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cset := query.NewLabeledSet()
+		for _, n := range ns {
+			cset.Add(query.NewAttributed(n.ID, n.Labels, n.Attributes()))
+		}
+		clusters[name] = cset
+		others = append(others, ns...)
+	}
+
+	return query.WithClusters(ctx, clusters), others, nil
+}
+
+// ownNodes returns an error if any of the nodes belong to another cluster,
+// since nodes can only be changed through their own cluster.
+func (s *router) ownNodes(ctx context.Context, clusterId string, ns []metadata.Node) error {
+	own, err := s.db.ListNodes(ctx, clusterId)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, n := range own {
+		ids = append(ids, n.ID)
+	}
+
+	for _, n := range ns {
+		if !stringutil.Contains(ids, n.ID) {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "node %q is not in cluster %q and must be changed through its own cluster", n.ID, clusterId)
+		}
+	}
+	return nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/stringutil"
 	"github.com/Netflix/p2plab/query"
+	"github.com/Netflix/p2plab/scenarios"
 	"github.com/rs/zerolog"
 )
 
@@ -43,6 +44,7 @@ func (s *router) Routes() []daemon.Route {
 		daemon.NewGetRoute("/scenarios/{name}/json", s.getScenarioByName),
 		// POST
 		daemon.NewPostRoute("/scenarios/create", s.postScenariosCreate),
+		daemon.NewPostRoute("/scenarios/validate", s.postScenariosValidate),
 		// PUT
 		daemon.NewPutRoute("/scenarios/label", s.putScenariosLabel),
 		// DELETE
@@ -94,6 +96,21 @@ func (s *router) postScenariosCreate(ctx context.Context, w http.ResponseWriter,
 	return daemon.WriteJSON(w, &scenario)
 }
 
+func (s *router) postScenariosValidate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var sdef metadata.ScenarioDefinition
+	err := json.NewDecoder(r.Body).Decode(&sdef)
+	if err != nil {
+		return err
+	}
+
+	diagnostics := scenarios.Validate(ctx, sdef)
+	if diagnostics == nil {
+		diagnostics = []metadata.Diagnostic{}
+	}
+
+	return daemon.WriteJSON(w, &diagnostics)
+}
+
 func (s *router) putScenariosLabel(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	names := strings.Split(r.FormValue("names"), ",")
 	addLabels := stringutil.Coalesce(strings.Split(r.FormValue("adds"), ","))
@@ -0,0 +1,123 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulerouter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Netflix/p2plab/daemon"
+	"github.com/Netflix/p2plab/labd/controlapi"
+	"github.com/Netflix/p2plab/labd/scheduler"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/logutil"
+	"github.com/rs/zerolog"
+)
+
+type router struct {
+	db        metadata.DB
+	scheduler *scheduler.Scheduler
+}
+
+func New(db metadata.DB, scheduler *scheduler.Scheduler) daemon.Router {
+	return &router{db, scheduler}
+}
+
+func (s *router) Routes() []daemon.Route {
+	return []daemon.Route{
+		// GET
+		daemon.NewGetRoute("/schedules/json", s.getSchedules),
+		daemon.NewGetRoute("/schedules/{name}/json", s.getScheduleByName),
+		// POST
+		daemon.NewPostRoute("/schedules/create", s.postSchedulesCreate),
+		// PUT
+		daemon.NewPutRoute("/schedules/{name}/run", s.putScheduleRun),
+		// DELETE
+		daemon.NewDeleteRoute("/schedules/delete", s.deleteSchedules),
+	}
+}
+
+func (s *router) getSchedules(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	schedules, err := s.db.ListSchedules(ctx)
+	if err != nil {
+		return err
+	}
+
+	return daemon.WriteJSON(w, &schedules)
+}
+
+func (s *router) getScheduleByName(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	schedule, err := s.db.GetSchedule(ctx, vars["name"])
+	if err != nil {
+		return err
+	}
+
+	return daemon.WriteJSON(w, &schedule)
+}
+
+func (s *router) postSchedulesCreate(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var sdef metadata.ScheduleDefinition
+	err := json.NewDecoder(r.Body).Decode(&sdef)
+	if err != nil {
+		return err
+	}
+
+	err = sdef.Validate()
+	if err != nil {
+		return err
+	}
+
+	schedule, err := s.db.CreateSchedule(ctx, metadata.Schedule{
+		ID:         r.FormValue("name"),
+		Definition: sdef,
+	})
+	if err != nil {
+		return err
+	}
+
+	return daemon.WriteJSON(w, &schedule)
+}
+
+func (s *router) putScheduleRun(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	name := vars["name"]
+	_, err := s.db.GetSchedule(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	// The benchmark ID is only known once the benchmark starts, so it is sent
+	// as a trailer after the logs are streamed.
+	w.Header().Set("Trailer", controlapi.ResourceID)
+
+	ctx, _ = logutil.WithResponseLogger(ctx, w)
+	bid, err := s.scheduler.RunSchedule(ctx, name)
+	if bid != "" {
+		w.Header().Set(controlapi.ResourceID, bid)
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to run schedule")
+	}
+
+	return nil
+}
+
+func (s *router) deleteSchedules(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	names := strings.Split(r.FormValue("names"), ",")
+
+	zerolog.Ctx(ctx).Info().Strs("schedules", names).Msg("Deleting schedules")
+	return s.db.DeleteSchedules(ctx, names...)
+}
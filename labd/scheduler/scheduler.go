@@ -0,0 +1,213 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/labd/notifier"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Runner runs benchmarks in labd's process.
+type Runner interface {
+	RunBenchmark(ctx context.Context, cluster, scenario string, opts ...p2plab.StartBenchmarkOption) (string, error)
+}
+
+// Scheduler runs the benchmarks of schedules when they are due, compares each
+// benchmark against the benchmarks of the schedule before it, and alerts when
+// a metric regresses.
+type Scheduler struct {
+	db       metadata.DB
+	runner   Runner
+	client   *http.Client
+	interval time.Duration
+
+	// mu serializes runs, so that scheduled benchmarks do not contend for
+	// the same cluster.
+	mu sync.Mutex
+}
+
+func New(db metadata.DB, runner Runner, client *http.Client) *Scheduler {
+	return &Scheduler{
+		db:       db,
+		runner:   runner,
+		client:   client,
+		interval: time.Minute,
+	}
+}
+
+// Run runs schedules that are due on every interval until the context is
+// cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			schedules, err := s.db.ListSchedules(ctx)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("failed to list schedules")
+				continue
+			}
+
+			for _, schedule := range schedules {
+				next := schedule.Next()
+				if next.IsZero() || next.After(now) {
+					continue
+				}
+
+				_, err = s.RunSchedule(ctx, schedule.ID)
+				if err != nil {
+					zerolog.Ctx(ctx).Error().Err(err).Str("schedule", schedule.ID).Msg("failed to run schedule")
+				}
+			}
+		}
+	}
+}
+
+// RunSchedule runs a benchmark of the schedule, alerts on any regressions
+// against its baseline, and returns the ID of the benchmark.
+func (s *Scheduler) RunSchedule(ctx context.Context, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, err := s.db.GetSchedule(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	logger := zerolog.Ctx(ctx).With().Str("schedule", id).Logger()
+	ctx = logger.WithContext(ctx)
+
+	// Record the run before the benchmark, so that a schedule that keeps
+	// failing is not retried on every interval.
+	schedule.LastRun = time.Now().UTC()
+	schedule, err = s.db.UpdateSchedule(ctx, schedule)
+	if err != nil {
+		return "", err
+	}
+
+	sdef := schedule.Definition
+	logger.Info().Str("cluster", sdef.Cluster).Str("scenario", sdef.Scenario).Msg("Running scheduled benchmark")
+	bid, err := s.runner.RunBenchmark(ctx, sdef.Cluster, sdef.Scenario,
+		p2plab.WithBenchmarkLabels(fmt.Sprintf("schedule=%s", schedule.ID)),
+	)
+	if bid != "" {
+		schedule.Benchmarks = append(schedule.Benchmarks, bid)
+		_, uerr := s.db.UpdateSchedule(ctx, schedule)
+		if uerr != nil {
+			return bid, uerr
+		}
+	}
+	if err != nil {
+		return bid, errors.Wrap(err, "failed to run scheduled benchmark")
+	}
+
+	err = s.checkRegressions(ctx, schedule, bid)
+	if err != nil {
+		return bid, err
+	}
+
+	return bid, nil
+}
+
+func (s *Scheduler) checkRegressions(ctx context.Context, schedule metadata.Schedule, bid string) error {
+	sdef := schedule.Definition
+	if len(sdef.Alerts) == 0 {
+		return nil
+	}
+
+	head, err := s.db.GetReport(ctx, bid)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get report of benchmark %q", bid)
+	}
+
+	size := sdef.Baseline
+	if size == 0 {
+		size = metadata.DefaultScheduleBaseline
+	}
+
+	// The baseline is the most recent benchmarks before this one that
+	// completed and met their expectations, so that a failed benchmark does
+	// not hide a regression.
+	var (
+		baseline []string
+		reports  []metadata.Report
+	)
+	for i := len(schedule.Benchmarks) - 2; i >= 0 && len(baseline) < size; i-- {
+		id := schedule.Benchmarks[i]
+		benchmark, err := s.db.GetBenchmark(ctx, id)
+		if err != nil {
+			continue
+		}
+		if benchmark.Status != metadata.BenchmarkDone {
+			continue
+		}
+
+		report, err := s.db.GetReport(ctx, id)
+		if err != nil {
+			continue
+		}
+		baseline = append(baseline, id)
+		reports = append(reports, report)
+	}
+
+	if len(reports) == 0 {
+		zerolog.Ctx(ctx).Info().Msg("No baseline to compare against yet")
+		return nil
+	}
+
+	significance := sdef.Significance
+	if significance == 0 {
+		significance = metadata.DefaultSignificance
+	}
+
+	comparison := metadata.CompareReports(metadata.BaselineReport(reports), head, significance)
+	comparison.Base, comparison.Head = strings.Join(baseline, ","), bid
+
+	var regressions []metadata.MetricComparison
+	for _, alert := range sdef.Alerts {
+		mc, ok := alert.Check(comparison)
+		if !ok {
+			continue
+		}
+		zerolog.Ctx(ctx).Warn().Str("metric", mc.Metric).Str("base", mc.Base).Str("head", mc.Head).Msg("Metric regressed")
+		regressions = append(regressions, mc)
+	}
+
+	if len(regressions) == 0 {
+		zerolog.Ctx(ctx).Info().Int("baseline", len(baseline)).Msg("No regressions against baseline")
+		return nil
+	}
+
+	return notifier.SendAlert(ctx, s.client, sdef.Webhook, sdef.Slack, notifier.Alert{
+		Schedule:    schedule.ID,
+		Benchmark:   bid,
+		Baseline:    baseline,
+		Regressions: regressions,
+	})
+}
@@ -15,8 +15,12 @@
 package labd
 
 import (
+	"time"
+
 	"github.com/Netflix/p2plab/downloaders"
+	"github.com/Netflix/p2plab/labd/notifier"
 	"github.com/Netflix/p2plab/providers"
+	"github.com/Netflix/p2plab/publishers"
 	"github.com/Netflix/p2plab/uploaders"
 )
 
@@ -29,6 +33,17 @@ type LabdSettings struct {
 	Uploader           string
 	UploaderSettings   uploaders.UploaderSettings
 	DownloaderSettings downloaders.DownloaderSettings
+	Publisher          string
+	PublisherSettings  publishers.PublisherSettings
+	NotifierSettings   notifier.Settings
+	ReaperInterval     time.Duration
+	ReaperDestroy      bool
+
+	// RetentionAge is the age after which the reports of benchmarks are
+	// downsampled to RetentionResolution, checked every RetentionInterval.
+	RetentionAge        time.Duration
+	RetentionResolution time.Duration
+	RetentionInterval   time.Duration
 }
 
 func WithLibp2pPort(port int) LabdOption {
@@ -66,9 +81,54 @@ func WithUploaderSettings(settings uploaders.UploaderSettings) LabdOption {
 	}
 }
 
+// WithPublisher sets the type of publisher that benchmark results are
+// published with, or disables publishing if empty.
+func WithPublisher(publisher string) LabdOption {
+	return func(s *LabdSettings) error {
+		s.Publisher = publisher
+		return nil
+	}
+}
+
+func WithPublisherSettings(settings publishers.PublisherSettings) LabdOption {
+	return func(s *LabdSettings) error {
+		s.PublisherSettings = settings
+		return nil
+	}
+}
+
+// WithNotifierSettings sets the webhooks that benchmarks are notified of when
+// they complete.
+func WithNotifierSettings(settings notifier.Settings) LabdOption {
+	return func(s *LabdSettings) error {
+		s.NotifierSettings = settings
+		return nil
+	}
+}
+
 func WithDownloaderSettings(settings downloaders.DownloaderSettings) LabdOption {
 	return func(s *LabdSettings) error {
 		s.DownloaderSettings = settings
 		return nil
 	}
 }
+
+func WithReaper(interval time.Duration, destroy bool) LabdOption {
+	return func(s *LabdSettings) error {
+		s.ReaperInterval = interval
+		s.ReaperDestroy = destroy
+		return nil
+	}
+}
+
+// WithRetention downsamples the series of benchmark reports older than age to
+// one sample per resolution, checking for old reports on every interval. A
+// zero age disables retention.
+func WithRetention(age, resolution, interval time.Duration) LabdOption {
+	return func(s *LabdSettings) error {
+		s.RetentionAge = age
+		s.RetentionResolution = resolution
+		s.RetentionInterval = interval
+		return nil
+	}
+}
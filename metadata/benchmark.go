@@ -16,6 +16,9 @@ package metadata
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Netflix/p2plab/errdefs"
@@ -35,6 +38,18 @@ type Benchmark struct {
 
 	Plan ScenarioPlan
 
+	// Baseline is the benchmark pinned as the baseline of the scenario when
+	// the benchmark completed, if any.
+	Baseline string
+
+	// Regressions are the metrics that significantly regressed from the
+	// baseline.
+	Regressions []string
+
+	// Published maps the files of the benchmark's results that have been
+	// published, such as "report.html", to the URLs they can be shared with.
+	Published map[string]string
+
 	Labels []string
 
 	CreatedAt, UpdatedAt time.Time
@@ -50,15 +65,133 @@ var (
 
 	BenchmarkDone BenchmarkStatus = "done"
 
+	// BenchmarkFailed is a benchmark that completed but did not meet the
+	// expectations of its scenario.
+	BenchmarkFailed BenchmarkStatus = "failed"
+
 	BenchmarkError BenchmarkStatus = "error"
+
+	// BenchmarkAborted is a benchmark that was cancelled while it ran. Its
+	// report has the partial metrics of the nodes when it was cancelled.
+	BenchmarkAborted BenchmarkStatus = "aborted"
 )
 
+// BenchmarkView is a saved filter of listed benchmarks, such as the benchmarks
+// of a nightly schedule.
+type BenchmarkView struct {
+	Name string `json:"name"`
+
+	// Query filters the benchmarks by their labels.
+	Query string `json:"query,omitempty"`
+
+	// Limit is how many of the most recently created benchmarks are listed,
+	// or all of them if zero.
+	Limit int `json:"limit,omitempty"`
+}
+
 type ScenarioPlan struct {
 	Objects map[string]cid.Cid
 
-	Seed ScenarioStage
+	Stages []StagePlan
+
+	// Network maps a node ID to the network rules applied to it.
+	Network map[string][]NetworkRule
+
+	// Traffic maps a node ID to the TaskTraffic it runs in the background of
+	// the benchmarking session. The subject of each task holds the comma
+	// separated IDs of the nodes traffic is sent to, which are resolved to
+	// peer addresses once the session starts.
+	Traffic map[string]Task
+
+	// Topology maps a node ID to the IDs of the nodes it dials when the
+	// benchmarking session starts. If nil, every node is connected to every
+	// other node.
+	Topology map[string][]string
+
+	// Peers maps a node ID to the peer definition it runs for the benchmark
+	// when it differs from its own.
+	Peers map[string]PeerDefinition
+
+	// Soak loops the measured stages if not nil.
+	Soak *SoakPlan
+
+	// SampleInterval is how often nodes are sampled while the measured
+	// stages run, or zero if they are not sampled.
+	SampleInterval time.Duration
+}
+
+// SoakPlan is a soak with its durations parsed.
+type SoakPlan struct {
+	// Duration is how long the measured stages loop for.
+	Duration time.Duration
 
-	Benchmark ScenarioStage
+	// Interval is how often an interim report snapshot is collected.
+	Interval time.Duration
+}
+
+// RampUpProfile is how the concurrency of a stage grows.
+type RampUpProfile string
+
+var (
+	// RampUpLinear grows the concurrency at a constant rate.
+	RampUpLinear RampUpProfile = "linear"
+
+	// RampUpStep grows the concurrency in equal steps at equal intervals.
+	RampUpStep RampUpProfile = "step"
+
+	// RampUpExponential grows the concurrency by a constant factor over
+	// equal intervals.
+	RampUpExponential RampUpProfile = "exponential"
+)
+
+// RampUpPlan is a ramp-up with its duration parsed.
+type RampUpPlan struct {
+	Profile  RampUpProfile
+	Duration time.Duration
+	Steps    int
+}
+
+// NetworkRule is a network impairment resolved for a node.
+type NetworkRule struct {
+	NetworkImpairment
+
+	// Destinations are the addresses of the peers whose traffic is impaired.
+	// If empty, traffic to all destinations is impaired.
+	Destinations []string
+}
+
+// StagePlan is a stage of a scenario with its actions resolved into tasks for
+// each node.
+type StagePlan struct {
+	Name string
+
+	DependsOn []string
+
+	Seed bool
+
+	Warmup bool
+
+	// Timeout is how long the stage may run before it fails. A zero timeout
+	// is unlimited.
+	Timeout time.Duration
+
+	// OnFailure is the FailurePolicy of the stage.
+	OnFailure string
+
+	// When are the conditions that must hold for the stage to run.
+	When []string
+
+	// Otherwise is what happens when a condition does not hold.
+	Otherwise string
+
+	// MaxConcurrency is the most nodes that run their task at once, or zero
+	// for no limit.
+	MaxConcurrency int
+
+	// RampUp grows the concurrency of the stage if not nil.
+	RampUp *RampUpPlan
+
+	Tasks ScenarioStage
 }
 
 type ScenarioStage map[string]Task
@@ -67,6 +200,109 @@ type Task struct {
 	Type TaskType
 
 	Subject string
+
+	// PinType is the type of pin for TaskPin.
+	PinType PinType
+
+	// Depth limits how many levels of links are fetched for a recursive
+	// TaskPin. A negative depth fetches the entire DAG.
+	Depth int
+
+	// Delay is how long a node waits before running the task, so that tasks
+	// can be scheduled over the course of a stage.
+	Delay time.Duration
+
+	// StartAt is the instant at which a node starts the task, before its
+	// delay, so that nodes start together regardless of when the task
+	// arrives. It is set as a stage runs rather than planned, and a zero
+	// instant starts the task on arrival.
+	StartAt time.Time
+
+	// Deadline is how long the task may run before it fails. A zero deadline
+	// is unlimited.
+	Deadline time.Duration
+
+	// OnFailure is the FailurePolicy of the task.
+	OnFailure string
+
+	// Downtime is how long a node stays offline for TaskChurn before it
+	// rejoins its peers. A zero downtime keeps the node offline.
+	Downtime time.Duration
+
+	// Count is the number of messages published for TaskPublish, expected
+	// for TaskSubscribe, providers to find for TaskFindProvs, files imported
+	// for TaskAdd, streams opened to each peer for TaskTraffic, or updates
+	// expected for TaskFollow.
+	Count int
+
+	// Publishers is the number of peers publishing Count messages each to the
+	// topic of TaskSubscribe.
+	Publishers int
+
+	// Size is the size in bytes of messages published for TaskPublish, of
+	// files imported for TaskAdd, or of the data written for TaskMutate.
+	Size int
+
+	// Interval is how long to wait between messages for TaskPublish.
+	Interval time.Duration
+
+	// Timeout is how long to wait for messages for TaskSubscribe or updates
+	// for TaskFollow, or for each DHT query for TaskFindProvs and
+	// TaskFindPeer.
+	Timeout time.Duration
+
+	// Rate is the number of retrievals issued per second for TaskLoad, or the
+	// bytes sent per second on each stream for TaskTraffic.
+	Rate float64
+
+	// Duration is how long retrievals are issued for TaskLoad.
+	Duration time.Duration
+
+	// Evict is whether TaskLoad removes the object from the blockstore after
+	// each retrieval, so that every retrieval fetches it from the network, or
+	// whether TaskRestart clears the blockstore so the peer restarts with a
+	// cold cache.
+	Evict bool
+
+	// Path is the path within the object retrieved for TaskGetRange or
+	// TaskGatewayGet, or of the file changed for TaskMutate.
+	Path string
+
+	// Mutation is how the file at Path is changed for TaskMutate.
+	Mutation MutationMode
+
+	// Topic is the pubsub topic the new root of TaskMutate is announced on.
+	Topic string
+
+	// Gateway is the URL of the HTTP gateway that content is retrieved
+	// through for TaskGatewayGet.
+	Gateway string
+
+	// Script is whether the subject of TaskExec is the cid of a script to
+	// run, rather than the name of a command the node allows.
+	Script bool
+
+	// Args are the arguments of the command or script run for TaskExec.
+	Args []string
+
+	// Offset is the byte offset of the range of the file retrieved for
+	// TaskGetRange.
+	Offset int64
+
+	// Length is the number of bytes retrieved for TaskGetRange. A zero length
+	// retrieves the whole DAG at Path, or the rest of the file from a
+	// non-zero Offset.
+	Length int64
+
+	// Layout, Chunker, RawLeaves, HashFunc and MaxLinks specify how files are
+	// converted into DAGs for TaskAdd and TaskMutate, as for an
+	// ObjectDefinition. Empty
+	// values use the peer's defaults.
+	Layout    string
+	Chunker   string
+	RawLeaves bool
+	HashFunc  string
+	MaxLinks  int
 }
 
 type TaskType string
@@ -74,9 +310,84 @@ type TaskType string
 var (
 	TaskUpdate     TaskType = "update"
 	TaskGet        TaskType = "get"
+	TaskPin        TaskType = "pin"
+	TaskChurn      TaskType = "churn"
+	TaskPublish    TaskType = "publish"
+	TaskSubscribe  TaskType = "subscribe"
+	TaskProvide    TaskType = "provide"
+	TaskFindProvs  TaskType = "findprovs"
+	TaskFindPeer   TaskType = "findpeer"
+	TaskLoad       TaskType = "load"
+	TaskGetRange   TaskType = "get-range"
+	TaskAdd        TaskType = "add"
 	TaskConnect    TaskType = "connect"
 	TaskConnectOne TaskType = "connect-one"
 	TaskDisconnect TaskType = "disconnect"
+
+	// TaskGatewayGet retrieves content through another node's HTTP gateway
+	// rather than through bitswap directly.
+	TaskGatewayGet TaskType = "gateway-get"
+
+	// TaskExec runs a command allowed by the node or a script, capturing its
+	// output into the node's report.
+	TaskExec TaskType = "exec"
+
+	// TaskMutate changes a file within a DAG, provides the new root and
+	// announces it on a pubsub topic.
+	TaskMutate TaskType = "mutate"
+
+	// TaskFollow retrieves each new root announced on the pubsub topic in the
+	// task's subject, measuring how quickly updates propagate.
+	TaskFollow TaskType = "follow"
+
+	// TaskResetReport excludes the operations so far from a node's report.
+	TaskResetReport TaskType = "reset-report"
+
+	// TaskTraffic streams background traffic to the comma separated peer
+	// addresses in the task's subject until it is cancelled.
+	TaskTraffic TaskType = "traffic"
+
+	// TaskRestart restarts a node's peer with the same identity.
+	TaskRestart TaskType = "restart-peers"
+
+	// TaskUpdateConfig restarts a node's peer with the space separated
+	// settings in the task's subject applied to its peer definition.
+	TaskUpdateConfig TaskType = "update-peer-config"
+)
+
+type PinType string
+
+var (
+	// PinDirect pins only the root block of a DAG.
+	PinDirect PinType = "direct"
+
+	// PinRecursive pins the root block and the blocks it links to.
+	PinRecursive PinType = "recursive"
+)
+
+// ChurnMode is how a node leaves its peers for TaskChurn, which is given as
+// the task's subject. The node stays part of the cluster and rejoins the same
+// peers after its downtime.
+type ChurnMode string
+
+var (
+	// ChurnDisconnect drops the node's connections, but peers may redial it.
+	ChurnDisconnect ChurnMode = "disconnect"
+
+	// ChurnKill drops the node's connections and blocks its peers, as if the
+	// node crashed.
+	ChurnKill ChurnMode = "kill"
+)
+
+// MutationMode is how a file is changed for TaskMutate.
+type MutationMode string
+
+var (
+	// MutationAppend appends random data to the file.
+	MutationAppend MutationMode = "append"
+
+	// MutationReplace replaces the file with random data.
+	MutationReplace MutationMode = "replace"
 )
 
 func (m *db) GetBenchmark(ctx context.Context, id string) (Benchmark, error) {
@@ -287,6 +598,11 @@ func readBenchmark(bkt *bolt.Bucket, benchmark *Benchmark) error {
 		return err
 	}
 
+	benchmark.Published, err = readMap(bkt, bucketKeyPublished)
+	if err != nil {
+		return err
+	}
+
 	return bkt.ForEach(func(k, v []byte) error {
 		if v == nil {
 			return nil
@@ -297,6 +613,12 @@ func readBenchmark(bkt *bolt.Bucket, benchmark *Benchmark) error {
 			benchmark.ID = string(v)
 		case string(bucketKeyStatus):
 			benchmark.Status = BenchmarkStatus(v)
+		case string(bucketKeyBaseline):
+			benchmark.Baseline = string(v)
+		case string(bucketKeyRegressions):
+			if len(v) > 0 {
+				benchmark.Regressions = strings.Split(string(v), ",")
+			}
 		}
 
 		return nil
@@ -320,14 +642,131 @@ func readPlan(bkt *bolt.Bucket, plan *ScenarioPlan) error {
 		plan.Objects = objects
 	}
 
-	plan.Seed, err = readTaskMap(bkt, bucketKeySeed)
+	content := bkt.Get(bucketKeyNetwork)
+	if content != nil {
+		err = json.Unmarshal(content, &plan.Network)
+		if err != nil {
+			return err
+		}
+	}
+
+	content = bkt.Get(bucketKeyTopology)
+	if content != nil {
+		err = json.Unmarshal(content, &plan.Topology)
+		if err != nil {
+			return err
+		}
+	}
+
+	content = bkt.Get(bucketKeyPeers)
+	if content != nil {
+		err = json.Unmarshal(content, &plan.Peers)
+		if err != nil {
+			return err
+		}
+	}
+
+	content = bkt.Get(bucketKeySoak)
+	if content != nil {
+		err = json.Unmarshal(content, &plan.Soak)
+		if err != nil {
+			return err
+		}
+	}
+
+	interval := bkt.Get(bucketKeySampleInterval)
+	if interval != nil {
+		plan.SampleInterval, err = time.ParseDuration(string(interval))
+		if err != nil {
+			return err
+		}
+	}
+
+	plan.Traffic, err = readTaskMap(bkt, bucketKeyTraffic)
 	if err != nil {
+		return err
+	}
+
+	sbkt := bkt.Bucket(bucketKeyStages)
+	if sbkt == nil {
+		return readLegacyPlan(bkt, plan)
+	}
+
+	return sbkt.ForEach(func(name, v []byte) error {
+		nbkt := sbkt.Bucket(name)
+		if nbkt == nil {
+			return nil
+		}
+
+		stage := StagePlan{Name: string(name)}
+		depends := nbkt.Get(bucketKeyDepends)
+		if len(depends) > 0 {
+			stage.DependsOn = strings.Split(string(depends), ",")
+		}
+		stage.Seed, _ = strconv.ParseBool(string(nbkt.Get(bucketKeySeed)))
+		stage.Warmup, _ = strconv.ParseBool(string(nbkt.Get(bucketKeyWarmup)))
+		stage.Timeout, _ = time.ParseDuration(string(nbkt.Get(bucketKeyTimeout)))
+		stage.OnFailure = string(nbkt.Get(bucketKeyFailure))
+		stage.Otherwise = string(nbkt.Get(bucketKeyOtherwise))
+		stage.MaxConcurrency, _ = strconv.Atoi(string(nbkt.Get(bucketKeyMaxConcurrency)))
+
+		var err error
+		content := nbkt.Get(bucketKeyWhen)
+		if len(content) > 0 {
+			err = json.Unmarshal(content, &stage.When)
+			if err != nil {
+				return err
+			}
+		}
+
+		content = nbkt.Get(bucketKeyRampUp)
+		if len(content) > 0 {
+			err = json.Unmarshal(content, &stage.RampUp)
+			if err != nil {
+				return err
+			}
+		}
+
+		stage.Tasks, err = readTaskMap(nbkt, bucketKeyTasks)
+		if err != nil {
+			return err
+		}
+
+		plan.Stages = append(plan.Stages, stage)
 		return nil
+	})
+}
+
+// readLegacyPlan reads plans written before scenarios had stages, which only
+// had a seed and a benchmark task map.
+func readLegacyPlan(bkt *bolt.Bucket, plan *ScenarioPlan) error {
+	seed, err := readTaskMap(bkt, bucketKeySeed)
+	if err != nil {
+		return err
 	}
 
-	plan.Benchmark, err = readTaskMap(bkt, bucketKeyBenchmark)
+	if seed != nil {
+		plan.Stages = append(plan.Stages, StagePlan{
+			Name:  StageSeed,
+			Seed:  true,
+			Tasks: seed,
+		})
+	}
+
+	benchmark, err := readTaskMap(bkt, bucketKeyBenchmark)
 	if err != nil {
-		return nil
+		return err
+	}
+
+	if benchmark != nil {
+		stage := StagePlan{
+			Name:  StageBenchmark,
+			Tasks: benchmark,
+		}
+		if seed != nil {
+			stage.DependsOn = []string{StageSeed}
+		}
+		plan.Stages = append(plan.Stages, stage)
 	}
 
 	return nil
@@ -353,6 +792,119 @@ func readTaskMap(bkt *bolt.Bucket, name []byte) (map[string]Task, error) {
 				task.Type = TaskType(v)
 			case string(bucketKeySubject):
 				task.Subject = string(v)
+			case string(bucketKeyPinType):
+				task.PinType = PinType(v)
+			case string(bucketKeyDepth):
+				var err error
+				task.Depth, err = strconv.Atoi(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyDelay):
+				var err error
+				task.Delay, err = time.ParseDuration(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyDeadline):
+				var err error
+				task.Deadline, err = time.ParseDuration(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyFailure):
+				task.OnFailure = string(v)
+			case string(bucketKeyDowntime):
+				var err error
+				task.Downtime, err = time.ParseDuration(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyCount):
+				var err error
+				task.Count, err = strconv.Atoi(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyPublishers):
+				var err error
+				task.Publishers, err = strconv.Atoi(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeySize):
+				var err error
+				task.Size, err = strconv.Atoi(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyInterval):
+				var err error
+				task.Interval, err = time.ParseDuration(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyTimeout):
+				var err error
+				task.Timeout, err = time.ParseDuration(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyRate):
+				var err error
+				task.Rate, err = strconv.ParseFloat(string(v), 64)
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyDuration):
+				var err error
+				task.Duration, err = time.ParseDuration(string(v))
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyEvict):
+				task.Evict, _ = strconv.ParseBool(string(v))
+			case string(bucketKeyPath):
+				task.Path = string(v)
+			case string(bucketKeyGateway):
+				task.Gateway = string(v)
+			case string(bucketKeyMutation):
+				task.Mutation = MutationMode(v)
+			case string(bucketKeyTopic):
+				task.Topic = string(v)
+			case string(bucketKeyScript):
+				task.Script, _ = strconv.ParseBool(string(v))
+			case string(bucketKeyArgs):
+				err := json.Unmarshal(v, &task.Args)
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyOffset):
+				var err error
+				task.Offset, err = strconv.ParseInt(string(v), 10, 64)
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyLength):
+				var err error
+				task.Length, err = strconv.ParseInt(string(v), 10, 64)
+				if err != nil {
+					return err
+				}
+			case string(bucketKeyLayout):
+				task.Layout = string(v)
+			case string(bucketKeyChunker):
+				task.Chunker = string(v)
+			case string(bucketKeyRawLeaves):
+				task.RawLeaves, _ = strconv.ParseBool(string(v))
+			case string(bucketKeyHashFunc):
+				task.HashFunc = string(v)
+			case string(bucketKeyMaxLinks):
+				var err error
+				task.MaxLinks, err = strconv.Atoi(string(v))
+				if err != nil {
+					return err
+				}
 			}
 			return nil
 		})
@@ -435,9 +987,16 @@ func writeBenchmark(bkt *bolt.Bucket, benchmark *Benchmark) error {
 		return err
 	}
 
+	err = writeMap(bkt, bucketKeyPublished, benchmark.Published)
+	if err != nil {
+		return err
+	}
+
 	for _, f := range []field{
 		{bucketKeyID, []byte(benchmark.ID)},
 		{bucketKeyStatus, []byte(benchmark.Status)},
+		{bucketKeyBaseline, []byte(benchmark.Baseline)},
+		{bucketKeyRegressions, []byte(strings.Join(benchmark.Regressions, ","))},
 	} {
 		err = bkt.Put(f.key, f.value)
 		if err != nil {
@@ -473,16 +1032,124 @@ func writePlan(bkt *bolt.Bucket, plan *ScenarioPlan) error {
 		return err
 	}
 
-	err = writeTaskMap(bkt, bucketKeySeed, plan.Seed)
+	if len(plan.Network) > 0 {
+		content, err := json.Marshal(plan.Network)
+		if err != nil {
+			return err
+		}
+
+		err = bkt.Put(bucketKeyNetwork, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if plan.Topology != nil {
+		content, err := json.Marshal(plan.Topology)
+		if err != nil {
+			return err
+		}
+
+		err = bkt.Put(bucketKeyTopology, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(plan.Peers) > 0 {
+		content, err := json.Marshal(plan.Peers)
+		if err != nil {
+			return err
+		}
+
+		err = bkt.Put(bucketKeyPeers, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if plan.Soak != nil {
+		content, err := json.Marshal(plan.Soak)
+		if err != nil {
+			return err
+		}
+
+		err = bkt.Put(bucketKeySoak, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = bkt.Put(bucketKeySampleInterval, []byte(plan.SampleInterval.String()))
+	if err != nil {
+		return err
+	}
+
+	err = writeTaskMap(bkt, bucketKeyTraffic, plan.Traffic)
 	if err != nil {
 		return err
 	}
 
-	err = writeTaskMap(bkt, bucketKeyBenchmark, plan.Benchmark)
+	if len(plan.Stages) == 0 {
+		return nil
+	}
+
+	sbkt, err := RecreateBucket(bkt, bucketKeyStages)
 	if err != nil {
 		return err
 	}
 
+	for _, stage := range plan.Stages {
+		nbkt, err := sbkt.CreateBucket([]byte(stage.Name))
+		if err != nil {
+			return err
+		}
+
+		for _, f := range []field{
+			{bucketKeyDepends, []byte(strings.Join(stage.DependsOn, ","))},
+			{bucketKeySeed, []byte(strconv.FormatBool(stage.Seed))},
+			{bucketKeyWarmup, []byte(strconv.FormatBool(stage.Warmup))},
+			{bucketKeyTimeout, []byte(stage.Timeout.String())},
+			{bucketKeyFailure, []byte(stage.OnFailure)},
+			{bucketKeyOtherwise, []byte(stage.Otherwise)},
+			{bucketKeyMaxConcurrency, []byte(strconv.Itoa(stage.MaxConcurrency))},
+		} {
+			err = nbkt.Put(f.key, f.value)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(stage.When) > 0 {
+			content, err := json.Marshal(stage.When)
+			if err != nil {
+				return err
+			}
+
+			err = nbkt.Put(bucketKeyWhen, content)
+			if err != nil {
+				return err
+			}
+		}
+
+		if stage.RampUp != nil {
+			content, err := json.Marshal(stage.RampUp)
+			if err != nil {
+				return err
+			}
+
+			err = nbkt.Put(bucketKeyRampUp, content)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = writeTaskMap(nbkt, bucketKeyTasks, stage.Tasks)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -505,12 +1172,50 @@ func writeTaskMap(bkt *bolt.Bucket, name []byte, stage map[string]Task) error {
 		for _, f := range []field{
 			{bucketKeyType, []byte(task.Type)},
 			{bucketKeySubject, []byte(task.Subject)},
+			{bucketKeyPinType, []byte(task.PinType)},
+			{bucketKeyDepth, []byte(strconv.Itoa(task.Depth))},
+			{bucketKeyDelay, []byte(task.Delay.String())},
+			{bucketKeyDeadline, []byte(task.Deadline.String())},
+			{bucketKeyFailure, []byte(task.OnFailure)},
+			{bucketKeyDowntime, []byte(task.Downtime.String())},
+			{bucketKeyCount, []byte(strconv.Itoa(task.Count))},
+			{bucketKeyPublishers, []byte(strconv.Itoa(task.Publishers))},
+			{bucketKeySize, []byte(strconv.Itoa(task.Size))},
+			{bucketKeyInterval, []byte(task.Interval.String())},
+			{bucketKeyTimeout, []byte(task.Timeout.String())},
+			{bucketKeyRate, []byte(strconv.FormatFloat(task.Rate, 'g', -1, 64))},
+			{bucketKeyDuration, []byte(task.Duration.String())},
+			{bucketKeyEvict, []byte(strconv.FormatBool(task.Evict))},
+			{bucketKeyPath, []byte(task.Path)},
+			{bucketKeyGateway, []byte(task.Gateway)},
+			{bucketKeyMutation, []byte(task.Mutation)},
+			{bucketKeyTopic, []byte(task.Topic)},
+			{bucketKeyScript, []byte(strconv.FormatBool(task.Script))},
+			{bucketKeyOffset, []byte(strconv.FormatInt(task.Offset, 10))},
+			{bucketKeyLength, []byte(strconv.FormatInt(task.Length, 10))},
+			{bucketKeyLayout, []byte(task.Layout)},
+			{bucketKeyChunker, []byte(task.Chunker)},
+			{bucketKeyRawLeaves, []byte(strconv.FormatBool(task.RawLeaves))},
+			{bucketKeyHashFunc, []byte(task.HashFunc)},
+			{bucketKeyMaxLinks, []byte(strconv.Itoa(task.MaxLinks))},
 		} {
 			err = tbkt.Put(f.key, f.value)
 			if err != nil {
 				return err
 			}
 		}
+
+		if len(task.Args) > 0 {
+			content, err := json.Marshal(task.Args)
+			if err != nil {
+				return err
+			}
+
+			err = tbkt.Put(bucketKeyArgs, content)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
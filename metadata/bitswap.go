@@ -0,0 +1,142 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type BitswapEventType string
+
+var (
+	BitswapWantBlock BitswapEventType = "want-block"
+	BitswapWantHave  BitswapEventType = "want-have"
+	BitswapCancel    BitswapEventType = "cancel"
+	BitswapBlock     BitswapEventType = "block"
+	BitswapHave      BitswapEventType = "have"
+	BitswapDontHave  BitswapEventType = "dont-have"
+)
+
+// MaxBitswapEvents is the number of bitswap events a node records between
+// reports, beyond which events are dropped.
+const MaxBitswapEvents = 1 << 20
+
+// BitswapEvent is an entry of a bitswap message sent or received by a node.
+type BitswapEvent struct {
+	Time time.Time
+
+	// Peer is the ID of the peer the message was sent to or received from.
+	Peer string
+
+	// Sent is whether the node sent the message rather than received it.
+	Sent bool
+
+	Type BitswapEventType
+
+	Cid string
+
+	// Size is the size in bytes of a block.
+	Size int `json:",omitempty"`
+
+	// Dup is whether a received block was already in the node's blockstore.
+	Dup bool `json:",omitempty"`
+
+	// Action is the ID of the action the node was running when the message
+	// was exchanged, or that a peer's message was exchanged on behalf of.
+	Action string `json:",omitempty"`
+}
+
+// EncodeBitswapEvents returns the events as gzipped JSON lines.
+func EncodeBitswapEvents(events []BitswapEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, event := range events {
+		err := enc.Encode(event)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeBitswapEvents returns the events encoded by EncodeBitswapEvents.
+func DecodeBitswapEvents(content []byte) ([]BitswapEvent, error) {
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var events []BitswapEvent
+	dec := json.NewDecoder(zr)
+	for {
+		var event BitswapEvent
+		err = dec.Decode(&event)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
+
+// CorrelateBitswapActions keys the events of nodes that were not running an
+// action by the action of the peer they exchanged a message with, so that a
+// retrieval can be followed to the providers that served it. Events maps a
+// node ID to its events, and peerIDs maps a node ID to its peer ID.
+func CorrelateBitswapActions(events map[string][]BitswapEvent, peerIDs map[string]string) {
+	nodeByPeer := make(map[string]string)
+	for id, peerID := range peerIDs {
+		nodeByPeer[peerID] = id
+	}
+
+	// actions maps the node, peer and cid of the message from the other end
+	// to the action of the peer.
+	actions := make(map[[3]string]string)
+	for id, nodeEvents := range events {
+		for _, event := range nodeEvents {
+			remote, ok := nodeByPeer[event.Peer]
+			if event.Action == "" || !ok {
+				continue
+			}
+			actions[[3]string{remote, peerIDs[id], event.Cid}] = event.Action
+		}
+	}
+
+	for id, nodeEvents := range events {
+		for i, event := range nodeEvents {
+			if event.Action != "" {
+				continue
+			}
+			nodeEvents[i].Action = actions[[3]string{id, event.Peer, event.Cid}]
+		}
+	}
+}
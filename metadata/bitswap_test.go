@@ -0,0 +1,46 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelateBitswapActions(t *testing.T) {
+	events := map[string][]BitswapEvent{
+		"requester": {
+			{Peer: "QmProvider", Sent: true, Type: BitswapWantBlock, Cid: "a", Action: "action"},
+			{Peer: "QmProvider", Type: BitswapBlock, Cid: "a", Action: "action"},
+		},
+		"provider": {
+			{Peer: "QmRequester", Type: BitswapWantBlock, Cid: "a"},
+			{Peer: "QmRequester", Sent: true, Type: BitswapBlock, Cid: "a"},
+			{Peer: "QmRequester", Type: BitswapWantBlock, Cid: "b"},
+			{Peer: "QmOther", Type: BitswapWantBlock, Cid: "a"},
+		},
+	}
+	CorrelateBitswapActions(events, map[string]string{
+		"requester": "QmRequester",
+		"provider":  "QmProvider",
+	})
+
+	var actions []string
+	for _, event := range events["provider"] {
+		actions = append(actions, event.Action)
+	}
+	require.Equal(t, []string{"action", "action", "", ""}, actions)
+}
@@ -25,11 +25,28 @@ var (
 	bucketKeyBuilds      = []byte("builds")
 	bucketKeyBenchmarks  = []byte("benchmarks")
 	bucketKeyExperiments = []byte("experiments")
+	bucketKeySchedules   = []byte("schedules")
 
 	// Cluster buckets.
+	bucketKeyName         = []byte("name")
+	bucketKeyConnectivity = []byte("connectivity")
+	bucketKeyRetries      = []byte("retries")
+	bucketKeyTolerance    = []byte("tolerance")
 	bucketKeySize         = []byte("size")
 	bucketKeyInstanceType = []byte("instanceType")
 	bucketKeyRegion       = []byte("region")
+	bucketKeyImage        = []byte("image")
+	bucketKeyBootstrap    = []byte("bootstrap")
+	bucketKeyPlacement    = []byte("placement")
+	bucketKeyDedicated    = []byte("dedicated")
+	bucketKeyStorageSize  = []byte("storageSize")
+	bucketKeyStorageType  = []byte("storageType")
+	bucketKeyStorageIOPS  = []byte("storageIops")
+	bucketKeyEphemeral    = []byte("instanceStores")
+	bucketKeyCost         = []byte("cost")
+	bucketKeyHourly       = []byte("hourly")
+	bucketKeyAccrued      = []byte("accrued")
+	bucketKeyAccruedAt    = []byte("accruedAt")
 
 	// Scenario buckets.
 	bucketKeyObjects   = []byte("objects")
@@ -42,16 +59,34 @@ var (
 	bucketKeyRawLeaves = []byte("rawLeaves")
 	bucketKeyHashFunc  = []byte("hashFunc")
 	bucketKeyMaxLinks  = []byte("maxLinks")
+	bucketKeyStages    = []byte("stages")
+	bucketKeyNetwork   = []byte("network")
+	bucketKeyTrials    = []byte("trials")
+	bucketKeyRandSeed  = []byte("randomSeed")
+	bucketKeyExpects   = []byte("expectations")
+	bucketKeyTraffic   = []byte("traffic")
+	bucketKeyTopology  = []byte("topology")
+	bucketKeyPeers     = []byte("peers")
+	bucketKeySoak      = []byte("soak")
+	bucketKeySelect    = []byte("selections")
+	bucketKeyQueryVer  = []byte("queryVersion")
 
 	// Node buckets.
 	bucketKeyAddress            = []byte("address")
 	bucketKeyAgentPort          = []byte("agentPort")
 	bucketKeyAppPort            = []byte("appPort")
+	bucketKeyArch               = []byte("arch")
 	bucketKeyPort               = []byte("port")
 	bucketKeyTransports         = []byte("transports")
 	bucketKeyMuxers             = []byte("muxers")
 	bucketKeySecurityTransports = []byte("securityTransports")
 	bucketKeyRouting            = []byte("routing")
+	bucketKeyNetworkStack       = []byte("networkStack")
+	bucketKeyBitswapNoProvide   = []byte("bitswapNoProvide")
+	bucketKeyBitswapSearchDelay = []byte("bitswapSearchDelay")
+	bucketKeyBitswapTaskWorkers = []byte("bitswapTaskWorkers")
+	bucketKeyBitswapTrace       = []byte("bitswapTrace")
+	bucketKeyCollectors         = []byte("collectors")
 
 	// Build buckets
 	bucketKeyLink = []byte("link")
@@ -61,7 +96,62 @@ var (
 	bucketKeyScenario = []byte("scenario")
 	bucketKeyPlan     = []byte("plan")
 	bucketKeySubject  = []byte("subject")
+	bucketKeyPinType  = []byte("pinType")
+	bucketKeyDepth    = []byte("depth")
+	bucketKeyTasks    = []byte("tasks")
+	bucketKeyDepends  = []byte("dependsOn")
+	bucketKeyWarmup   = []byte("warmup")
+	bucketKeyDeadline = []byte("deadline")
+	bucketKeyFailure  = []byte("onFailure")
+	bucketKeyDelay    = []byte("delay")
+	bucketKeyDowntime = []byte("downtime")
+	bucketKeyCount    = []byte("count")
+	bucketKeyInterval = []byte("interval")
+	bucketKeyTimeout  = []byte("timeout")
+	bucketKeyRate     = []byte("rate")
+	bucketKeyDuration = []byte("duration")
+	bucketKeyEvict    = []byte("evict")
+	bucketKeyPath     = []byte("path")
+	bucketKeyGateway  = []byte("gateway")
+	bucketKeyOffset   = []byte("offset")
+	bucketKeyLength   = []byte("length")
 	bucketKeyReport   = []byte("report")
+	bucketKeyTrace    = []byte("trace")
+
+	// Exec buckets.
+	bucketKeyScript = []byte("script")
+	bucketKeyArgs   = []byte("args")
+
+	// Mutation buckets.
+	bucketKeyMutation = []byte("mutation")
+	bucketKeyTopic    = []byte("topic")
+
+	// Pubsub buckets.
+	bucketKeyPublishers = []byte("publishers")
+
+	// Stage condition buckets.
+	bucketKeyWhen      = []byte("when")
+	bucketKeyOtherwise = []byte("otherwise")
+
+	// Stage concurrency buckets.
+	bucketKeyMaxConcurrency = []byte("maxConcurrency")
+	bucketKeyRampUp         = []byte("rampUp")
+
+	// Sampling buckets.
+	bucketKeySampleInterval = []byte("sampleInterval")
+
+	// Profile buckets.
+	bucketKeyProfiles = []byte("profiles")
+
+	// Schedule buckets.
+	bucketKeyLastRun = []byte("lastRun")
+
+	// Baseline buckets.
+	bucketKeyBaseline    = []byte("baseline")
+	bucketKeyRegressions = []byte("regressions")
+
+	// Publish buckets.
+	bucketKeyPublished = []byte("published")
 
 	// Common buckets.
 	bucketKeyID           = []byte("id")
@@ -173,3 +263,15 @@ func getExperimentBucket(tx *bolt.Tx, name string) *bolt.Bucket {
 func createExperimentsBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
 	return createBucketIfNotExists(tx, bucketKeyVersion, bucketKeyExperiments)
 }
+
+func getSchedulesBucket(tx *bolt.Tx) *bolt.Bucket {
+	return getBucket(tx, bucketKeyVersion, bucketKeySchedules)
+}
+
+func getScheduleBucket(tx *bolt.Tx, id string) *bolt.Bucket {
+	return getBucket(tx, bucketKeyVersion, bucketKeySchedules, []byte(id))
+}
+
+func createSchedulesBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
+	return createBucketIfNotExists(tx, bucketKeyVersion, bucketKeySchedules)
+}
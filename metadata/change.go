@@ -0,0 +1,168 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ChangeKind is how a field changed between two definitions.
+type ChangeKind string
+
+var (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// FieldChange is a field that differs between two definitions.
+type FieldChange struct {
+	Kind ChangeKind
+
+	// Path locates the field in the definitions as they are written in JSON,
+	// such as "groups.0.size".
+	Path string
+
+	// Old and New are the JSON values of the field in each definition, empty
+	// when the field was added or removed.
+	Old string `json:",omitempty"`
+	New string `json:",omitempty"`
+}
+
+// DiffDefinitions compares two definitions field by field as they are
+// written in JSON, and returns the fields that changed from old to new
+// ordered by path. Lists are compared by index.
+func DiffDefinitions(old, new interface{}) ([]FieldChange, error) {
+	o, err := jsonTree(old)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := jsonTree(new)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	diffValues(&changes, "", o, n)
+	return changes, nil
+}
+
+// jsonTree returns the generic JSON value of v.
+func jsonTree(v interface{}) (interface{}, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree interface{}
+	err = json.Unmarshal(content, &tree)
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func diffValues(changes *[]FieldChange, path string, old, new interface{}) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make(map[string]struct{})
+		for k := range o {
+			keys[k] = struct{}{}
+		}
+		for k := range n {
+			keys[k] = struct{}{}
+		}
+
+		var sorted []string
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		for _, k := range sorted {
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			diffField(changes, joinPath(path, k), ov, nv, inOld, inNew)
+		}
+		return
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(o) || i < len(n); i++ {
+			var ov, nv interface{}
+			if i < len(o) {
+				ov = o[i]
+			}
+			if i < len(n) {
+				nv = n[i]
+			}
+			diffField(changes, joinPath(path, strconv.Itoa(i)), ov, nv, i < len(o), i < len(n))
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, FieldChange{
+			Kind: ChangeModified,
+			Path: path,
+			Old:  jsonString(old),
+			New:  jsonString(new),
+		})
+	}
+}
+
+// diffField compares a field that may only be in one of the definitions.
+// Fields that are null are treated as missing, since they are omitted or
+// zero alike.
+func diffField(changes *[]FieldChange, path string, old, new interface{}, inOld, inNew bool) {
+	inOld = inOld && old != nil
+	inNew = inNew && new != nil
+	switch {
+	case inOld && inNew:
+		diffValues(changes, path, old, new)
+	case inOld:
+		*changes = append(*changes, FieldChange{Kind: ChangeRemoved, Path: path, Old: jsonString(old)})
+	case inNew:
+		*changes = append(*changes, FieldChange{Kind: ChangeAdded, Path: path, New: jsonString(new)})
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return fmt.Sprintf("%s.%s", path, key)
+}
+
+func jsonString(v interface{}) string {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(content)
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDefinitions(t *testing.T) {
+	type group struct {
+		Size   int
+		Region string
+		Labels []string `json:",omitempty"`
+	}
+	type definition struct {
+		Groups  []group
+		Retries int `json:",omitempty"`
+		Vars    map[string]string
+	}
+
+	old := definition{
+		Groups: []group{
+			{Size: 3, Region: "us-west-2"},
+			{Size: 2, Region: "us-east-1", Labels: []string{"seeder"}},
+		},
+		Vars: map[string]string{"a": "1", "b": "2"},
+	}
+	new := definition{
+		Groups: []group{
+			{Size: 5, Region: "us-west-2"},
+		},
+		Retries: 2,
+		Vars:    map[string]string{"a": "1", "c": "3"},
+	}
+
+	changes, err := DiffDefinitions(old, new)
+	require.NoError(t, err)
+	require.Equal(t, []FieldChange{
+		{Kind: ChangeModified, Path: "Groups.0.Size", Old: "3", New: "5"},
+		{Kind: ChangeRemoved, Path: "Groups.1", Old: `{"Labels":["seeder"],"Region":"us-east-1","Size":2}`},
+		{Kind: ChangeAdded, Path: "Retries", New: "2"},
+		{Kind: ChangeRemoved, Path: "Vars.b", Old: `"2"`},
+		{Kind: ChangeAdded, Path: "Vars.c", New: `"3"`},
+	}, changes)
+
+	changes, err = DiffDefinitions(old, old)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
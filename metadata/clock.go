@@ -0,0 +1,45 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import "time"
+
+// ClockReading is a labagent's wall-clock time when it received and when it
+// answered a clock request.
+type ClockReading struct {
+	Receive  time.Time
+	Transmit time.Time
+}
+
+// ClockOffset is an NTP-style estimate of how far a node's clock is ahead of
+// the local clock.
+type ClockOffset struct {
+	// Offset is added to a local instant to get the same instant in the
+	// node's clock.
+	Offset time.Duration
+
+	// RoundTrip is the network delay of the sample the offset was estimated
+	// from.
+	RoundTrip time.Duration
+}
+
+// NewClockOffset estimates a clock offset from a request sent at t0 and
+// answered at t3 in the local clock, with the reading taken by the node.
+func NewClockOffset(t0 time.Time, reading ClockReading, t3 time.Time) ClockOffset {
+	return ClockOffset{
+		Offset:    (reading.Receive.Sub(t0) + reading.Transmit.Sub(t3)) / 2,
+		RoundTrip: t3.Sub(t0) - reading.Transmit.Sub(reading.Receive),
+	}
+}
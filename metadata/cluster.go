@@ -16,7 +16,11 @@ package metadata
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Netflix/p2plab/errdefs"
@@ -35,17 +39,28 @@ type Cluster struct {
 
 	Definition ClusterDefinition
 
+	Cost ClusterCost
+
 	Labels []string
 
 	CreatedAt, UpdatedAt time.Time
 }
 
+// RunningStatus returns the status of the cluster once it runs a number of
+// nodes, which is degraded if that is fewer nodes than it was defined with.
+func (c Cluster) RunningStatus(nodes int) ClusterStatus {
+	if nodes < c.Definition.Size() {
+		return ClusterDegraded
+	}
+	return ClusterCreated
+}
+
 func (c Cluster) Validate() error {
 	err := ValidateClusterID(c.ID)
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.Definition.Validate()
 }
 
 type ClusterStatus string
@@ -54,6 +69,9 @@ var (
 	ClusterCreating   ClusterStatus = "creating"
 	ClusterConnecting ClusterStatus = "connecting"
 	ClusterCreated    ClusterStatus = "created"
+	ClusterPooled     ClusterStatus = "pooled"
+	ClusterPaused     ClusterStatus = "paused"
+	ClusterDegraded   ClusterStatus = "degraded"
 	ClusterDestroying ClusterStatus = "destroying"
 	ClusterDestroyed  ClusterStatus = "destroyed"
 	ClusterError      ClusterStatus = "error"
@@ -61,6 +79,76 @@ var (
 
 type ClusterDefinition struct {
 	Groups []ClusterGroup
+
+	// NetworkStack is one of the following: ["ipv4", "ipv6", "dual"]. Defaults
+	// to ipv4.
+	NetworkStack NetworkStack `json:"networkStack,omitempty"`
+
+	// Connectivity declares which groups may talk to each other. When empty,
+	// all groups can reach each other. Otherwise, traffic between groups is
+	// denied unless allowed by a rule.
+	Connectivity []ConnectivityRule `json:"connectivity,omitempty"`
+
+	// Retries is the number of times nodes that fail to launch or become
+	// healthy are replaced during provisioning.
+	Retries int `json:"retries,omitempty"`
+
+	// Tolerance is the fraction of nodes between 0 and 1 that may still be
+	// failed after retries. Clusters with failed nodes within tolerance are
+	// created as degraded, otherwise the creation fails.
+	Tolerance float64 `json:"tolerance,omitempty"`
+}
+
+// MaxFailures returns the number of nodes that may fail to provision.
+func (d ClusterDefinition) MaxFailures() int {
+	return int(d.Tolerance * float64(d.Size()))
+}
+
+// ConnectivityRule allows traffic from one named cluster group to another.
+type ConnectivityRule struct {
+	From string `json:"from"`
+
+	To string `json:"to"`
+
+	// Protocol is one of the following: ["tcp", "udp"]. When empty, both are
+	// allowed.
+	Protocol string `json:"protocol,omitempty"`
+
+	// Ports is a list of ports or port ranges, e.g. "4001" or "4001-4010". When
+	// empty, all ports are allowed.
+	Ports []string `json:"ports,omitempty"`
+}
+
+// PortRange parses a port or port range of a connectivity rule.
+func PortRange(ports string) (from, to int, err error) {
+	parts := strings.SplitN(ports, "-", 2)
+	from, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, errors.Wrapf(errdefs.ErrInvalidArgument, "invalid port %q", ports)
+	}
+
+	to = from
+	if len(parts) == 2 {
+		to, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, errors.Wrapf(errdefs.ErrInvalidArgument, "invalid port %q", ports)
+		}
+	}
+
+	if from <= 0 || to > 65535 || from > to {
+		return 0, 0, errors.Wrapf(errdefs.ErrInvalidArgument, "invalid port range %q", ports)
+	}
+
+	return from, to, nil
+}
+
+// GroupName returns the name of the i-th cluster group, which defaults to its
+// index.
+func (d ClusterDefinition) GroupName(i int) string {
+	if d.Groups[i].Name != "" {
+		return d.Groups[i].Name
+	}
+	return strconv.Itoa(i)
 }
 
 func (d ClusterDefinition) Size() int {
@@ -71,6 +159,113 @@ func (d ClusterDefinition) Size() int {
 	return sum
 }
 
+func (d ClusterDefinition) Validate() error {
+	if len(d.Groups) == 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "cluster definition must have at least one group")
+	}
+
+	for i, g := range d.Groups {
+		if g.Size <= 0 {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster group %d must have a positive size", i)
+		}
+
+		switch g.Placement.Strategy {
+		case "", PlacementSpread, PlacementPack:
+		default:
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster group %d has unrecognized placement strategy %q", i, g.Placement.Strategy)
+		}
+
+		if g.Storage.Size < 0 || g.Storage.IOPS < 0 || g.Storage.InstanceStores < 0 {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster group %d storage must not be negative", i)
+		}
+
+		if g.Storage.InstanceStores > MaxInstanceStores {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster group %d cannot attach more than %d instance stores", i, MaxInstanceStores)
+		}
+
+		switch g.Storage.Type {
+		case "", "gp2", "st1":
+			if g.Storage.IOPS > 0 {
+				return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster group %d storage type %q does not support provisioned iops", i, g.Storage.Type)
+			}
+		case "gp3", "io1", "io2":
+		default:
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster group %d has unrecognized storage type %q", i, g.Storage.Type)
+		}
+
+		if g.Peer != nil {
+			err := g.Peer.Validate()
+			if err != nil {
+				return errors.Wrapf(err, "cluster group %d peer", i)
+			}
+		}
+	}
+
+	if d.Retries < 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "cluster retries must not be negative")
+	}
+
+	if d.Tolerance < 0 || d.Tolerance > 1 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "cluster tolerance must be between 0 and 1")
+	}
+
+	switch d.NetworkStack {
+	case "", NetworkIPv4, NetworkIPv6, NetworkDual:
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized network stack %q", d.NetworkStack)
+	}
+
+	names := make(map[string]struct{})
+	for i := range d.Groups {
+		name := d.GroupName(i)
+		_, ok := names[name]
+		if ok {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster group name %q is not unique", name)
+		}
+		names[name] = struct{}{}
+	}
+
+	for _, rule := range d.Connectivity {
+		for _, name := range []string{rule.From, rule.To} {
+			_, ok := names[name]
+			if !ok {
+				return errors.Wrapf(errdefs.ErrInvalidArgument, "connectivity rule references unknown group %q", name)
+			}
+		}
+
+		switch rule.Protocol {
+		case "", "tcp", "udp":
+		default:
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "connectivity rule has unrecognized protocol %q", rule.Protocol)
+		}
+
+		for _, ports := range rule.Ports {
+			_, _, err := PortRange(ports)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.Size() > ClusterSizeMax {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "cluster size %d exceeds max %d", d.Size(), ClusterSizeMax)
+	}
+
+	return nil
+}
+
+// Hash returns a digest of the cluster definition. Clusters with the same hash
+// have identical topologies and can be used interchangeably.
+func (d ClusterDefinition) Hash() (string, error) {
+	content, err := json.Marshal(&d)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func (d ClusterDefinition) GenerateLabels() (labels []string) {
 	regionSet := make(map[string]struct{})
 	instanceTypeSet := make(map[string]struct{})
@@ -91,13 +286,84 @@ func (d ClusterDefinition) GenerateLabels() (labels []string) {
 }
 
 type ClusterGroup struct {
+	// Name identifies the group in connectivity rules.
+	Name string `json:"name,omitempty"`
+
 	Size         int
 	InstanceType string
 	Region       string
-	Peer         *PeerDefinition `json:"peer,omitempty"`
-	Labels       []string
+
+	// Image is the base image to launch nodes with, e.g. an AMI ID for the
+	// terraform provider. When empty, the provider's default labagent image is
+	// used.
+	Image string `json:"image,omitempty"`
+
+	// Bootstrap is a shell script that is run on each node before labagent
+	// starts, e.g. to tune sysctls or mount instance storage. If it fails,
+	// labagent still starts but fails its health check.
+	Bootstrap string `json:"bootstrap,omitempty"`
+
+	// Placement constrains where nodes are physically placed.
+	Placement Placement `json:"placement,omitempty"`
+
+	// Storage configures the root volume of each node, e.g. to fit large
+	// datasets.
+	Storage Storage `json:"storage,omitempty"`
+
+	Peer   *PeerDefinition `json:"peer,omitempty"`
+	Labels []string
 }
 
+// Placement describes the physical placement of a cluster group's nodes.
+type Placement struct {
+	// Strategy is one of the following: ["spread", "pack"]. Spread distributes
+	// nodes across availability zones and onto distinct racks, while pack
+	// places them close together in a single availability zone. Defaults to
+	// spread. Racks are only distinct for groups of up to 7 nodes per
+	// availability zone, beyond which nodes are only spread across zones.
+	Strategy PlacementStrategy `json:"strategy,omitempty"`
+
+	// DedicatedInstances launches nodes as dedicated instances, which run on
+	// hardware dedicated to the AWS account. Unlike dedicated hosts, the
+	// instances aren't pinned to specific physical servers.
+	DedicatedInstances bool `json:"dedicatedInstances,omitempty"`
+}
+
+// Storage describes the root volume attached to a node.
+type Storage struct {
+	// Size is the size of the volume in GiB. When zero, the image's default
+	// root volume is used.
+	Size int `json:"size,omitempty"`
+
+	// Type is one of the following: ["gp2", "gp3", "io1", "io2", "st1"].
+	// Defaults to gp2.
+	Type string `json:"type,omitempty"`
+
+	// IOPS is the provisioned IOPS for gp3, io1 and io2 volumes.
+	IOPS int `json:"iops,omitempty"`
+
+	// InstanceStores is the number of instance store volumes of the instance
+	// type to attach, such as the disks of d2 instances. Instance types with
+	// NVMe instance storage, such as i3, attach theirs regardless. Instance
+	// stores are attached unformatted, so the group's bootstrap script should
+	// format and mount them.
+	InstanceStores int `json:"instanceStores,omitempty"`
+}
+
+// BootstrapFailedPath is where a node records that its bootstrap script failed,
+// which fails labagent's health check.
+const BootstrapFailedPath = "/var/lib/p2plab/bootstrap.failed"
+
+// MaxInstanceStores is the most instance store volumes an instance type has.
+const MaxInstanceStores = 24
+
+type PlacementStrategy string
+
+var (
+	PlacementSpread PlacementStrategy = "spread"
+	PlacementPack   PlacementStrategy = "pack"
+)
+
 func (m *db) GetCluster(ctx context.Context, id string) (Cluster, error) {
 	var cluster Cluster
 
@@ -171,13 +437,28 @@ func (m *db) CreateCluster(ctx context.Context, cluster Cluster) (Cluster, error
 			return err
 		}
 
-		cbkt, err := bkt.CreateBucket([]byte(cluster.ID))
-		if err != nil {
-			if err != bolt.ErrBucketExists {
+		// A destroyed cluster is only kept for its accrued cost, so its name
+		// can be reused by a new cluster.
+		if ebkt := bkt.Bucket([]byte(cluster.ID)); ebkt != nil {
+			var existing Cluster
+			err = readCluster(ebkt, &existing)
+			if err != nil {
+				return err
+			}
+
+			if existing.Status != ClusterDestroyed {
+				return errors.Wrapf(errdefs.ErrAlreadyExists, "cluster %q", cluster.ID)
+			}
+
+			err = bkt.DeleteBucket([]byte(cluster.ID))
+			if err != nil {
 				return err
 			}
+		}
 
-			return errors.Wrapf(errdefs.ErrAlreadyExists, "cluster %q", cluster.ID)
+		cbkt, err := bkt.CreateBucket([]byte(cluster.ID))
+		if err != nil {
+			return err
 		}
 
 		cluster.CreatedAt = time.Now().UTC()
@@ -285,6 +566,11 @@ func readCluster(bkt *bolt.Bucket, cluster *Cluster) error {
 		return err
 	}
 
+	cluster.Cost, err = readClusterCost(bkt)
+	if err != nil {
+		return err
+	}
+
 	cluster.Labels, err = readLabels(bkt)
 	if err != nil {
 		return err
@@ -313,6 +599,33 @@ func readClusterDefinition(bkt *bolt.Bucket) (ClusterDefinition, error) {
 	if dbkt == nil {
 		return cdef, nil
 	}
+	cdef.NetworkStack = NetworkStack(dbkt.Get(bucketKeyNetworkStack))
+
+	retries := dbkt.Get(bucketKeyRetries)
+	if retries != nil {
+		var err error
+		cdef.Retries, err = strconv.Atoi(string(retries))
+		if err != nil {
+			return cdef, err
+		}
+	}
+
+	tolerance := dbkt.Get(bucketKeyTolerance)
+	if tolerance != nil {
+		var err error
+		cdef.Tolerance, err = strconv.ParseFloat(string(tolerance), 64)
+		if err != nil {
+			return cdef, err
+		}
+	}
+
+	content := dbkt.Get(bucketKeyConnectivity)
+	if content != nil {
+		err := json.Unmarshal(content, &cdef.Connectivity)
+		if err != nil {
+			return cdef, err
+		}
+	}
 
 	i := 0
 	gbkt := dbkt.Bucket([]byte(strconv.Itoa(i)))
@@ -337,6 +650,8 @@ func readClusterDefinition(bkt *bolt.Bucket) (ClusterDefinition, error) {
 
 		err = gbkt.ForEach(func(k, v []byte) error {
 			switch string(k) {
+			case string(bucketKeyName):
+				group.Name = string(v)
 			case string(bucketKeySize):
 				size, err := strconv.Atoi(string(v))
 				if err != nil {
@@ -347,6 +662,38 @@ func readClusterDefinition(bkt *bolt.Bucket) (ClusterDefinition, error) {
 				group.InstanceType = string(v)
 			case string(bucketKeyRegion):
 				group.Region = string(v)
+			case string(bucketKeyImage):
+				group.Image = string(v)
+			case string(bucketKeyBootstrap):
+				group.Bootstrap = string(v)
+			case string(bucketKeyPlacement):
+				group.Placement.Strategy = PlacementStrategy(v)
+			case string(bucketKeyStorageSize):
+				size, err := strconv.Atoi(string(v))
+				if err != nil {
+					return err
+				}
+				group.Storage.Size = size
+			case string(bucketKeyStorageType):
+				group.Storage.Type = string(v)
+			case string(bucketKeyStorageIOPS):
+				iops, err := strconv.Atoi(string(v))
+				if err != nil {
+					return err
+				}
+				group.Storage.IOPS = iops
+			case string(bucketKeyEphemeral):
+				stores, err := strconv.Atoi(string(v))
+				if err != nil {
+					return err
+				}
+				group.Storage.InstanceStores = stores
+			case string(bucketKeyDedicated):
+				dedicated, err := strconv.ParseBool(string(v))
+				if err != nil {
+					return err
+				}
+				group.Placement.DedicatedInstances = dedicated
 			}
 			return nil
 		})
@@ -374,6 +721,11 @@ func writeCluster(bkt *bolt.Bucket, cluster *Cluster) error {
 		return err
 	}
 
+	err = writeClusterCost(bkt, cluster.Cost)
+	if err != nil {
+		return err
+	}
+
 	err = writeLabels(bkt, cluster.Labels)
 	if err != nil {
 		return err
@@ -398,6 +750,29 @@ func writeClusterDefinition(bkt *bolt.Bucket, cdef ClusterDefinition) error {
 		return err
 	}
 
+	for _, f := range []field{
+		{bucketKeyNetworkStack, []byte(cdef.NetworkStack)},
+		{bucketKeyRetries, []byte(strconv.Itoa(cdef.Retries))},
+		{bucketKeyTolerance, []byte(strconv.FormatFloat(cdef.Tolerance, 'f', -1, 64))},
+	} {
+		err = dbkt.Put(f.key, f.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(cdef.Connectivity) > 0 {
+		content, err := json.Marshal(&cdef.Connectivity)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeyConnectivity, content)
+		if err != nil {
+			return err
+		}
+	}
+
 	for i, group := range cdef.Groups {
 		gbkt, err := dbkt.CreateBucket([]byte(strconv.Itoa(i)))
 		if err != nil {
@@ -417,9 +792,18 @@ func writeClusterDefinition(bkt *bolt.Bucket, cdef ClusterDefinition) error {
 		}
 
 		for _, f := range []field{
+			{bucketKeyName, []byte(group.Name)},
 			{bucketKeySize, []byte(strconv.Itoa(group.Size))},
 			{bucketKeyInstanceType, []byte(group.InstanceType)},
 			{bucketKeyRegion, []byte(group.Region)},
+			{bucketKeyImage, []byte(group.Image)},
+			{bucketKeyBootstrap, []byte(group.Bootstrap)},
+			{bucketKeyPlacement, []byte(group.Placement.Strategy)},
+			{bucketKeyDedicated, []byte(strconv.FormatBool(group.Placement.DedicatedInstances))},
+			{bucketKeyStorageSize, []byte(strconv.Itoa(group.Storage.Size))},
+			{bucketKeyStorageType, []byte(group.Storage.Type)},
+			{bucketKeyStorageIOPS, []byte(strconv.Itoa(group.Storage.IOPS))},
+			{bucketKeyEphemeral, []byte(strconv.Itoa(group.Storage.InstanceStores))},
 		} {
 			err = gbkt.Put(f.key, f.value)
 			if err != nil {
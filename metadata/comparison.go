@@ -0,0 +1,204 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"sort"
+
+	"github.com/Netflix/p2plab/pkg/stats"
+)
+
+// DefaultSignificance is the p-value below which a change between two
+// benchmarks is significant, unless otherwise specified.
+const DefaultSignificance = 0.05
+
+// ReportComparison compares the reports of two benchmarks of the same
+// scenario, from a base benchmark to a head benchmark.
+type ReportComparison struct {
+	Base string
+	Head string
+
+	// Significance is the p-value below which a change is significant.
+	Significance float64
+
+	// Metrics are the compared metrics ordered by name, excluding those
+	// that are zero in both reports.
+	Metrics []MetricComparison
+}
+
+// Regressions returns the metrics that significantly changed for the worse.
+func (c ReportComparison) Regressions() []MetricComparison {
+	var regressions []MetricComparison
+	for _, m := range c.Metrics {
+		if m.Regression {
+			regressions = append(regressions, m)
+		}
+	}
+	return regressions
+}
+
+// MetricComparison is the change of a metric between two reports. Values are
+// the mean across trials, formatted like expectation values.
+type MetricComparison struct {
+	Metric string
+	Base   string
+	Head   string
+	Delta  string
+
+	// Change is the relative change from base to head, or zero if the base
+	// is zero.
+	Change float64
+
+	// PValue is the two-sided p-value of Welch's t-test on the per-trial
+	// samples, which is 1 unless both benchmarks ran at least two trials.
+	PValue float64
+
+	// Tested is whether both reports had enough trials for the p-value to
+	// be meaningful.
+	Tested bool
+
+	Significant bool
+
+	// Regression is whether the change is significant and for the worse.
+	Regression bool
+}
+
+// higherIsBetter are the metrics for which an increase is an improvement.
+var higherIsBetter = map[string]bool{
+	"loadThroughput": true,
+}
+
+// neutralMetrics are the metrics that measure how much work was done rather
+// than how well, so that changes are never regressions.
+var neutralMetrics = map[string]bool{
+	"retrievals":     true,
+	"blocksReceived": true,
+	"dataReceived":   true,
+}
+
+// CompareReports compares every expectation metric and stage time of two
+// reports, using each trial as a sample. A report that ran a single trial
+// has itself as its only sample.
+func CompareReports(base, head Report, significance float64) ReportComparison {
+	comparison := ReportComparison{Significance: significance}
+
+	names := make(map[string]struct{})
+	for name := range expectationMetrics {
+		names[name] = struct{}{}
+	}
+	for _, report := range []Report{base, head} {
+		for stage := range report.Summary.Stages {
+			names["stage."+stage] = struct{}{}
+		}
+		for _, trial := range trialReports(report) {
+			for custom := range trial.Aggregates.Totals.Custom {
+				names["custom."+custom] = struct{}{}
+			}
+		}
+	}
+
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	baseTrials, headTrials := trialReports(base), trialReports(head)
+	for _, name := range sorted {
+		m, _ := lookupMetric(name)
+		a, b := sampleMetric(m, baseTrials), sampleMetric(m, headTrials)
+		meanA, meanB := stats.Mean(a), stats.Mean(b)
+		if meanA == 0 && meanB == 0 {
+			continue
+		}
+
+		e := Expectation{Metric: name, kind: m.kind}
+		mc := MetricComparison{
+			Metric: name,
+			Base:   e.format(meanA),
+			Head:   e.format(meanB),
+			Delta:  formatDelta(e, meanB-meanA),
+			PValue: stats.WelchTTest(a, b),
+			Tested: len(a) > 1 && len(b) > 1,
+		}
+		if meanA != 0 {
+			mc.Change = (meanB - meanA) / meanA
+		}
+		mc.Significant = mc.PValue < significance
+
+		worse := meanB > meanA
+		if higherIsBetter[name] {
+			worse = meanB < meanA
+		}
+		mc.Regression = mc.Significant && worse && !neutralMetrics[name]
+
+		comparison.Metrics = append(comparison.Metrics, mc)
+	}
+
+	return comparison
+}
+
+// BaselineReport returns a report whose trials are the trials of every given
+// report, so that a report can be compared against several benchmarks before
+// it.
+func BaselineReport(reports []Report) Report {
+	var baseline Report
+	for _, report := range reports {
+		for _, trial := range trialReports(report) {
+			baseline.Trials = append(baseline.Trials, ReportTrial{
+				Summary:    trial.Summary,
+				Aggregates: trial.Aggregates,
+			})
+		}
+	}
+	return baseline
+}
+
+// trialReports returns a report for each trial of a report, or the report
+// itself if it ran a single trial.
+func trialReports(report Report) []Report {
+	if len(report.Trials) == 0 {
+		return []Report{report}
+	}
+
+	var reports []Report
+	for _, trial := range report.Trials {
+		reports = append(reports, Report{
+			Summary:    trial.Summary,
+			Aggregates: trial.Aggregates,
+		})
+	}
+	return reports
+}
+
+func sampleMetric(m metric, reports []Report) []float64 {
+	var samples []float64
+	for _, r := range reports {
+		samples = append(samples, m.value(r))
+	}
+	return samples
+}
+
+// formatDelta formats a signed difference like the values of e.
+func formatDelta(e Expectation, delta float64) string {
+	switch {
+	case delta < 0:
+		return "-" + e.format(-delta)
+	case delta > 0:
+		return "+" + e.format(delta)
+	default:
+		return e.format(delta)
+	}
+}
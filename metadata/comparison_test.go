@@ -0,0 +1,50 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareReports(t *testing.T) {
+	trials := func(times ...time.Duration) Report {
+		var r Report
+		for _, d := range times {
+			r.Trials = append(r.Trials, ReportTrial{Summary: ReportSummary{TotalTime: d}})
+		}
+		return r
+	}
+
+	base := trials(10*time.Second, 11*time.Second, 9*time.Second)
+	head := trials(20*time.Second, 21*time.Second, 19*time.Second)
+	comparison := CompareReports(base, head, DefaultSignificance)
+	require.Len(t, comparison.Metrics, 1)
+
+	m := comparison.Metrics[0]
+	require.Equal(t, "totalTime", m.Metric)
+	require.Equal(t, "+10s", m.Delta)
+	require.InDelta(t, 1, m.Change, 1e-9)
+	require.True(t, m.Regression)
+
+	comparison = CompareReports(head, base, DefaultSignificance)
+	require.True(t, comparison.Metrics[0].Significant)
+	require.Empty(t, comparison.Regressions())
+
+	comparison = CompareReports(trials(10*time.Second), trials(20*time.Second), DefaultSignificance)
+	require.False(t, comparison.Metrics[0].Significant)
+}
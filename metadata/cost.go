@@ -0,0 +1,104 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ClusterCost tracks the cost in USD of running a cluster.
+type ClusterCost struct {
+	// Hourly is the estimated cost of running the cluster for an hour.
+	Hourly float64
+
+	// Accrued is the cost accumulated up until AccruedAt.
+	Accrued float64
+
+	AccruedAt time.Time
+}
+
+// Accrue returns the cost with the runtime since AccruedAt accumulated up
+// until now.
+func (c ClusterCost) Accrue(now time.Time) ClusterCost {
+	if !c.AccruedAt.IsZero() && now.After(c.AccruedAt) {
+		c.Accrued += c.Hourly * now.Sub(c.AccruedAt).Hours()
+	}
+	c.AccruedAt = now
+	return c
+}
+
+// CostReport is the lab-wide cost of all clusters.
+type CostReport struct {
+	Hourly float64
+
+	Accrued float64
+
+	Clusters map[string]ClusterCost
+}
+
+func readClusterCost(bkt *bolt.Bucket) (ClusterCost, error) {
+	var cost ClusterCost
+
+	cbkt := bkt.Bucket(bucketKeyCost)
+	if cbkt == nil {
+		return cost, nil
+	}
+
+	err := cbkt.ForEach(func(k, v []byte) error {
+		var err error
+		switch string(k) {
+		case string(bucketKeyHourly):
+			cost.Hourly, err = strconv.ParseFloat(string(v), 64)
+		case string(bucketKeyAccrued):
+			cost.Accrued, err = strconv.ParseFloat(string(v), 64)
+		case string(bucketKeyAccruedAt):
+			err = cost.AccruedAt.UnmarshalBinary(v)
+		}
+		return err
+	})
+	if err != nil {
+		return cost, err
+	}
+
+	return cost, nil
+}
+
+func writeClusterCost(bkt *bolt.Bucket, cost ClusterCost) error {
+	cbkt, err := RecreateBucket(bkt, bucketKeyCost)
+	if err != nil {
+		return err
+	}
+
+	accruedAt, err := cost.AccruedAt.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []field{
+		{bucketKeyHourly, []byte(strconv.FormatFloat(cost.Hourly, 'f', -1, 64))},
+		{bucketKeyAccrued, []byte(strconv.FormatFloat(cost.Accrued, 'f', -1, 64))},
+		{bucketKeyAccruedAt, accruedAt},
+	} {
+		err = cbkt.Put(f.key, f.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
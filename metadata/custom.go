@@ -0,0 +1,39 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// CustomMetricKind is how a custom metric behaves when a node's report is
+// reset.
+type CustomMetricKind string
+
+var (
+	// CustomCounter accumulates from when the peer started, and reports
+	// count from when they were last reset.
+	CustomCounter CustomMetricKind = "counter"
+
+	// CustomGauge is a value at the time the report is collected, such as
+	// the size of a datastore.
+	CustomGauge CustomMetricKind = "gauge"
+)
+
+// CustomMetric is a metric of a peer collected by a metrics collector, such as
+// the counters of a protocol or datastore that reports do not cover. It is
+// reported as "<collector>.<name>" among the custom metrics of the node, and
+// is summed across nodes in the aggregates.
+type CustomMetric struct {
+	Name  string           `json:"name"`
+	Kind  CustomMetricKind `json:"kind"`
+	Value float64          `json:"value"`
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// ClusterHealth is the result of a healthcheck of every node of a cluster.
+type ClusterHealth struct {
+	Cluster string
+
+	// Healthy and Unhealthy are the IDs of the nodes whose agents did and did
+	// not respond to a healthcheck.
+	Healthy   []string
+	Unhealthy []string
+}
+
+// Dashboard is a snapshot of the lab, with the health of each cluster, the
+// progress of running benchmarks and the most recent results.
+type Dashboard struct {
+	Clusters []Cluster
+
+	// Health maps the ID of each cluster to its health.
+	Health map[string]ClusterHealth
+
+	Running []BenchmarkProgress
+
+	Recent []Benchmark
+}
@@ -38,8 +38,11 @@ type DB interface {
 	ScenarioStore
 	BuildStore
 	ReportStore
+	TraceStore
+	ProfileStore
 	BenchmarkStore
 	ExperimentStore
+	ScheduleStore
 
 	View(ctx context.Context, fn func(*bolt.Tx) error) error
 
@@ -74,6 +77,8 @@ type NodeStore interface {
 	UpdateNode(ctx context.Context, cluster string, node Node) (Node, error)
 
 	LabelNodes(ctx context.Context, cluster string, ids, adds, removes []string) ([]Node, error)
+
+	DeleteNodes(ctx context.Context, cluster string, ids ...string) error
 }
 
 type ScenarioStore interface {
@@ -106,6 +111,18 @@ type ReportStore interface {
 	CreateReport(ctx context.Context, id string, report Report) error
 }
 
+type TraceStore interface {
+	GetTrace(ctx context.Context, id string) (Trace, error)
+
+	CreateTrace(ctx context.Context, id string, trace Trace) error
+}
+
+type ProfileStore interface {
+	GetProfile(ctx context.Context, id, node string, kind ProfileKind) ([]byte, error)
+
+	CreateProfile(ctx context.Context, id, node string, kind ProfileKind, profile []byte) error
+}
+
 type BenchmarkStore interface {
 	GetBenchmark(ctx context.Context, id string) (Benchmark, error)
 
@@ -134,6 +151,18 @@ type ExperimentStore interface {
 	DeleteExperiment(ctx context.Context, id string) error
 }
 
+type ScheduleStore interface {
+	GetSchedule(ctx context.Context, id string) (Schedule, error)
+
+	ListSchedules(ctx context.Context) ([]Schedule, error)
+
+	CreateSchedule(ctx context.Context, schedule Schedule) (Schedule, error)
+
+	UpdateSchedule(ctx context.Context, schedule Schedule) (Schedule, error)
+
+	DeleteSchedules(ctx context.Context, ids ...string) error
+}
+
 type db struct {
 	boltdb *bolt.DB
 }
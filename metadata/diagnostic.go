@@ -0,0 +1,52 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// Severity is how serious a diagnostic is.
+type Severity string
+
+var (
+	// SeverityError is a problem that prevents a definition from running.
+	SeverityError Severity = "error"
+
+	// SeverityWarning is a likely mistake that does not prevent a definition
+	// from running.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found when validating a definition.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+
+	// Path locates the problem in the definition, such as
+	// "stages.fetch.actions.neighbors".
+	Path string `json:"path"`
+
+	// Line is the line of the definition's file that Path is found at, or 0
+	// if it is unknown.
+	Line int `json:"line,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// HasErrors returns whether any of the diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultDiffThreshold is the relative change beyond which a metric of a node
+// changed between two reports, unless otherwise specified.
+const DefaultDiffThreshold = 0.1
+
+// DiffGrouping is how the nodes of two reports are matched and compared.
+type DiffGrouping string
+
+var (
+	// DiffByNode compares each node to the node of the other report that
+	// was matched by the same queries and has the same labels.
+	DiffByNode DiffGrouping = "node"
+
+	// DiffByQuery compares the nodes matched by the same queries as a whole.
+	DiffByQuery DiffGrouping = "query"
+
+	// DiffByLabel compares the nodes with each label, such as a region or an
+	// instance type, as a whole.
+	DiffByLabel DiffGrouping = "label"
+)
+
+// ReportDiff compares the nodes of two reports, from a base benchmark to a
+// head benchmark, so that a regression can be pinned to specific nodes or
+// regions rather than the whole cluster.
+type ReportDiff struct {
+	Base string
+	Head string
+
+	By DiffGrouping
+
+	// Threshold is the relative change beyond which a metric changed.
+	Threshold float64
+
+	// Entries are the matched nodes or groups of nodes with metrics that
+	// changed, in order.
+	Entries []DiffEntry
+
+	// UnmatchedBase and UnmatchedHead are the nodes or groups of each report
+	// without a counterpart in the other.
+	UnmatchedBase []string `json:",omitempty"`
+	UnmatchedHead []string `json:",omitempty"`
+}
+
+// DiffEntry is the change of a node or group of nodes between two reports.
+type DiffEntry struct {
+	// Key is what the nodes were matched by: the queries and labels of a
+	// node, the queries of a group, or a label.
+	Key string
+
+	// BaseNode and HeadNode are the IDs of the matched nodes when comparing
+	// by node.
+	BaseNode string `json:",omitempty"`
+	HeadNode string `json:",omitempty"`
+
+	// Metrics are the metrics that changed beyond the threshold, ordered by
+	// name.
+	Metrics []MetricDiff
+}
+
+// MetricDiff is the change of a metric of a node or group of nodes. Values
+// are formatted like expectation values.
+type MetricDiff struct {
+	Metric string
+	Base   string
+	Head   string
+	Delta  string
+
+	// Change is the relative change from base to head, or zero if the base
+	// is zero.
+	Change float64
+
+	// Regression is whether the change is for the worse.
+	Regression bool
+}
+
+// Regressions returns the entries with metrics that changed for the worse,
+// with only those metrics.
+func (d ReportDiff) Regressions() []DiffEntry {
+	var regressions []DiffEntry
+	for _, entry := range d.Entries {
+		var metrics []MetricDiff
+		for _, m := range entry.Metrics {
+			if m.Regression {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			entry.Metrics = metrics
+			regressions = append(regressions, entry)
+		}
+	}
+	return regressions
+}
+
+// DiffNodes returns the expectation metrics of two node reports, or of the
+// aggregates of two groups of nodes, that changed by more than a threshold
+// relative to the base. A metric that was zero in the base changed if it is
+// not zero in the head.
+func DiffNodes(base, head ReportNode, threshold float64) []MetricDiff {
+	a := Report{Aggregates: ReportAggregates{Totals: base}}
+	b := Report{Aggregates: ReportAggregates{Totals: head}}
+
+	var names []string
+	for name := range expectationMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diffs []MetricDiff
+	for _, name := range names {
+		m := expectationMetrics[name]
+		valueA, valueB := m.value(a), m.value(b)
+		if valueA == valueB {
+			continue
+		}
+
+		var change float64
+		if valueA != 0 {
+			change = (valueB - valueA) / valueA
+			if math.Abs(change) <= threshold {
+				continue
+			}
+		}
+
+		worse := valueB > valueA
+		if higherIsBetter[name] {
+			worse = valueB < valueA
+		}
+
+		e := Expectation{Metric: name, kind: m.kind}
+		diffs = append(diffs, MetricDiff{
+			Metric:     name,
+			Base:       e.format(valueA),
+			Head:       e.format(valueB),
+			Delta:      formatDelta(e, valueB-valueA),
+			Change:     change,
+			Regression: worse && !neutralMetrics[name],
+		})
+	}
+	return diffs
+}
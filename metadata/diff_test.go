@@ -0,0 +1,51 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffNodes(t *testing.T) {
+	var base, head ReportNode
+	base.Retrieval.Time.Observe(time.Second)
+	head.Retrieval.Time.Observe(2 * time.Second)
+	base.Bitswap.BlocksReceived = 100
+	head.Bitswap.BlocksReceived = 105
+	head.Retrieval.Failures = 1
+
+	diffs := DiffNodes(base, head, DefaultDiffThreshold)
+	changed := make(map[string]MetricDiff)
+	for _, d := range diffs {
+		changed[d.Metric] = d
+	}
+
+	require.NotContains(t, changed, "blocksReceived")
+	require.Contains(t, changed, "retrievalTime.mean")
+	require.True(t, changed["retrievalTime.mean"].Regression)
+	require.InDelta(t, 1, changed["retrievalTime.mean"].Change, 0.1)
+	require.True(t, changed["retrievalFailures"].Regression)
+	require.Equal(t, "+1", changed["retrievalFailures"].Delta)
+
+	diff := ReportDiff{Entries: []DiffEntry{{Key: "-", Metrics: diffs}}}
+	regressions := diff.Regressions()
+	require.Len(t, regressions, 1)
+	for _, m := range regressions[0].Metrics {
+		require.True(t, m.Regression)
+	}
+}
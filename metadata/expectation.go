@@ -0,0 +1,256 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/errdefs"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+// Expectation is a condition on a metric of a report.
+type Expectation struct {
+	Metric    string
+	Op        string
+	Threshold float64
+
+	kind metricKind
+}
+
+// ExpectationResult is the outcome of evaluating an expectation.
+type ExpectationResult struct {
+	Expectation string
+	Actual      string
+	Passed      bool
+}
+
+// ExpectationsPassed returns whether all the expectations were met.
+func ExpectationsPassed(results []ExpectationResult) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+type metricKind int
+
+const (
+	metricCount metricKind = iota
+	metricDuration
+	metricBytes
+)
+
+type metric struct {
+	kind  metricKind
+	value func(Report) float64
+}
+
+var expectationMetrics = map[string]metric{
+	"totalTime": {metricDuration, func(r Report) float64 {
+		return float64(r.Summary.TotalTime)
+	}},
+	"retrievals": {metricCount, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Retrieval.Time.Count())
+	}},
+	"retrievalFailures": {metricCount, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Retrieval.Failures)
+	}},
+	"loadFailures": {metricCount, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Load.Failures)
+	}},
+	"loadMissed": {metricCount, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Load.Missed)
+	}},
+	"loadThroughput": {metricCount, func(r Report) float64 {
+		return r.Aggregates.Totals.Load.Throughput()
+	}},
+	"blocksReceived": {metricCount, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Bitswap.BlocksReceived)
+	}},
+	"duplicateBlocks": {metricCount, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Bitswap.DupBlksReceived)
+	}},
+	"dataReceived": {metricBytes, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Bitswap.DataReceived)
+	}},
+	"duplicateData": {metricBytes, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Bitswap.DupDataReceived)
+	}},
+	"pubsubLost": {metricCount, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Pubsub.MessagesLost)
+	}},
+	"updatesLost": {metricCount, func(r Report) float64 {
+		return float64(r.Aggregates.Totals.Update.Lost)
+	}},
+	"dhtFailures": {metricCount, func(r Report) float64 {
+		dht := r.Aggregates.Totals.DHT
+		return float64(dht.Provide.Failures + dht.FindProviders.Failures + dht.FindPeer.Failures)
+	}},
+}
+
+func init() {
+	histograms := map[string]func(Report) ReportHistogram{
+		"retrievalTime":     func(r Report) ReportHistogram { return r.Aggregates.Totals.Retrieval.Time },
+		"loadLatency":       func(r Report) ReportHistogram { return r.Aggregates.Totals.Load.Latency },
+		"updatePropagation": func(r Report) ReportHistogram { return r.Aggregates.Totals.Update.Propagation },
+		"pubsubLatency":     func(r Report) ReportHistogram { return r.Aggregates.Totals.Pubsub.Latency },
+		"dhtProvideTime":    func(r Report) ReportHistogram { return r.Aggregates.Totals.DHT.Provide.Time },
+		"dhtFindProvsTime":  func(r Report) ReportHistogram { return r.Aggregates.Totals.DHT.FindProviders.Time },
+		"dhtFindPeerTime":   func(r Report) ReportHistogram { return r.Aggregates.Totals.DHT.FindPeer.Time },
+	}
+
+	for name, histogram := range histograms {
+		histogram := histogram
+		expectationMetrics[name+".mean"] = metric{metricDuration, func(r Report) float64 {
+			return float64(histogram(r).Mean())
+		}}
+		expectationMetrics[name+".max"] = metric{metricDuration, func(r Report) float64 {
+			return float64(histogram(r).Max)
+		}}
+		for _, p := range []float64{50, 90, 95, 99, 99.9} {
+			p := p
+			expectationMetrics[fmt.Sprintf("%s.p%g", name, p)] = metric{metricDuration, func(r Report) float64 {
+				return float64(histogram(r).Percentile(p))
+			}}
+		}
+	}
+}
+
+// lookupMetric returns the metric with a given name. Stage times are named
+// "stage.<name>", and the totals of custom metrics "custom.<name>".
+func lookupMetric(name string) (metric, bool) {
+	if strings.HasPrefix(name, "stage.") {
+		stage := strings.TrimPrefix(name, "stage.")
+		return metric{metricDuration, func(r Report) float64 {
+			return float64(r.Summary.Stages[stage])
+		}}, true
+	}
+	if strings.HasPrefix(name, "custom.") {
+		custom := strings.TrimPrefix(name, "custom.")
+		return metric{metricCount, func(r Report) float64 {
+			return r.Aggregates.Totals.Custom[custom]
+		}}, true
+	}
+
+	m, ok := expectationMetrics[name]
+	return m, ok
+}
+
+// ParseExpectation parses an expectation of the form "<metric> <op> <value>",
+// where op is one of <, <=, >, >=, == or !=. Values of time metrics are
+// durations and values of data metrics are sizes such as "10MB".
+func ParseExpectation(s string) (Expectation, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return Expectation{}, errors.Wrapf(errdefs.ErrInvalidArgument, "expectation %q must be of the form \"<metric> <op> <value>\"", s)
+	}
+
+	m, ok := lookupMetric(fields[0])
+	if !ok {
+		return Expectation{}, errors.Wrapf(errdefs.ErrInvalidArgument, "expectation %q has unrecognized metric %q", s, fields[0])
+	}
+
+	switch fields[1] {
+	case "<", "<=", ">", ">=", "==", "!=":
+	default:
+		return Expectation{}, errors.Wrapf(errdefs.ErrInvalidArgument, "expectation %q has unrecognized operator %q", s, fields[1])
+	}
+
+	var (
+		threshold float64
+		err       error
+	)
+	switch m.kind {
+	case metricDuration:
+		var d time.Duration
+		d, err = time.ParseDuration(fields[2])
+		threshold = float64(d)
+	case metricBytes:
+		var b uint64
+		b, err = humanize.ParseBytes(fields[2])
+		threshold = float64(b)
+	default:
+		threshold, err = strconv.ParseFloat(fields[2], 64)
+	}
+	if err != nil {
+		return Expectation{}, errors.Wrapf(errdefs.ErrInvalidArgument, "expectation %q has invalid value: %s", s, err)
+	}
+
+	return Expectation{
+		Metric:    fields[0],
+		Op:        fields[1],
+		Threshold: threshold,
+		kind:      m.kind,
+	}, nil
+}
+
+// Evaluate evaluates the expectation against a report.
+func (e Expectation) Evaluate(report Report) ExpectationResult {
+	m, _ := lookupMetric(e.Metric)
+	actual := m.value(report)
+
+	var passed bool
+	switch e.Op {
+	case "<":
+		passed = actual < e.Threshold
+	case "<=":
+		passed = actual <= e.Threshold
+	case ">":
+		passed = actual > e.Threshold
+	case ">=":
+		passed = actual >= e.Threshold
+	case "==":
+		passed = actual == e.Threshold
+	case "!=":
+		passed = actual != e.Threshold
+	}
+
+	return ExpectationResult{
+		Expectation: fmt.Sprintf("%s %s %s", e.Metric, e.Op, e.format(e.Threshold)),
+		Actual:      e.format(actual),
+		Passed:      passed,
+	}
+}
+
+func (e Expectation) format(v float64) string {
+	switch e.kind {
+	case metricDuration:
+		return time.Duration(v).String()
+	case metricBytes:
+		return humanize.Bytes(uint64(v))
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+// EvaluateExpectations evaluates expectations against a report.
+func EvaluateExpectations(report Report, expectations []string) ([]ExpectationResult, error) {
+	var results []ExpectationResult
+	for _, s := range expectations {
+		e, err := ParseExpectation(s)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, e.Evaluate(report))
+	}
+	return results, nil
+}
@@ -16,6 +16,9 @@ package metadata
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/Netflix/p2plab/errdefs"
@@ -30,6 +33,10 @@ type Experiment struct {
 
 	Definition ExperimentDefinition
 
+	// Trials are the combinations of variables run by the experiment, in the
+	// order of ExperimentDefinition.Variables.
+	Trials []ExperimentTrial
+
 	Labels []string
 
 	CreatedAt, UpdatedAt time.Time
@@ -38,6 +45,7 @@ type Experiment struct {
 type ExperimentStatus string
 
 var (
+	ExperimentPending ExperimentStatus = "pending"
 	ExperimentRunning ExperimentStatus = "running"
 	ExperimentDone    ExperimentStatus = "done"
 	ExperimentError   ExperimentStatus = "error"
@@ -45,15 +53,262 @@ var (
 
 // ExperimentDefinition defines an experiment.
 type ExperimentDefinition struct {
+	// IndependentVariable are sets of variables that are each run as a trial.
 	IndependentVariable []IndependentVariable
 
+	// Matrix is the values of each variable to sweep, where every combination
+	// of values is run as a trial in addition to IndependentVariable.
+	Matrix map[string][]interface{} `json:",omitempty"`
+
+	// Concurrency is the maximum number of trials that run at once, each on
+	// its own cluster. Defaults to 1.
+	Concurrency int `json:",omitempty"`
+
 	ClusterDefinition ClusterDefinition
 
 	ScenarioDefinition ScenarioDefinition
+
+	// ScenarioTemplate is a scenario definition that is executed as a
+	// template with the variables of each trial. When set, it is used instead
+	// of ScenarioDefinition.
+	ScenarioTemplate string `json:",omitempty"`
 }
 
+// Variables that configure the cluster of a trial, which are also available
+// to the scenario template.
+const (
+	// VariableClusterSize sets the size of cluster groups that do not have
+	// one, so that groups such as seeders can keep a fixed size.
+	VariableClusterSize = "clusterSize"
+
+	// VariableCommit sets the git reference of every peer.
+	VariableCommit = "commit"
+)
+
 type IndependentVariable map[string]interface{}
 
+// Variables returns the variables of every trial, the independent variables
+// followed by the combinations of the matrix. Combinations vary the last
+// variable in alphabetical order fastest.
+func (d ExperimentDefinition) Variables() []IndependentVariable {
+	ivs := append([]IndependentVariable{}, d.IndependentVariable...)
+	if len(d.Matrix) == 0 {
+		return ivs
+	}
+
+	var names []string
+	for name := range d.Matrix {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	combinations := []IndependentVariable{{}}
+	for _, name := range names {
+		var next []IndependentVariable
+		for _, combination := range combinations {
+			for _, value := range d.Matrix[name] {
+				iv := make(IndependentVariable)
+				for k, v := range combination {
+					iv[k] = v
+				}
+				iv[name] = value
+				next = append(next, iv)
+			}
+		}
+		combinations = next
+	}
+
+	return append(ivs, combinations...)
+}
+
+// Validate returns an error if the experiment definition is malformed.
+func (d ExperimentDefinition) Validate() error {
+	if len(d.Variables()) == 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "experiment must have at least one trial")
+	}
+	if d.Concurrency < 0 {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "experiment concurrency %d must not be negative", d.Concurrency)
+	}
+	for name, values := range d.Matrix {
+		if len(values) == 0 {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "experiment matrix variable %q has no values", name)
+		}
+	}
+	return nil
+}
+
+// ExperimentTrial is a run of an experiment's scenario with a set of
+// variables.
+type ExperimentTrial struct {
+	Variables IndependentVariable
+
+	Status ExperimentStatus
+
+	// Benchmark is the ID of the benchmark of the trial, once it has started.
+	Benchmark string `json:",omitempty"`
+
+	// Error is why the trial failed, if its status is error.
+	Error string `json:",omitempty"`
+}
+
+// ExperimentReport summarizes the benchmarks of an experiment's trials.
+type ExperimentReport struct {
+	ID string
+
+	// Variables are the names of every variable of the trials, sorted.
+	Variables []string
+
+	Trials []ExperimentTrialReport
+}
+
+// ExperimentTrialReport is the metrics of a trial's benchmark, keyed by the
+// names used by expectations.
+type ExperimentTrialReport struct {
+	Variables IndependentVariable
+	Benchmark string
+	Metrics   map[string]float64
+}
+
+// NewExperimentReport returns the report of an experiment from the reports of
+// its benchmarks. Trials without a report are omitted.
+func NewExperimentReport(experiment Experiment, reportByBenchmark map[string]Report) ExperimentReport {
+	report := ExperimentReport{ID: experiment.ID}
+
+	names := make(map[string]struct{})
+	for _, trial := range experiment.Trials {
+		for name := range trial.Variables {
+			names[name] = struct{}{}
+		}
+
+		r, ok := reportByBenchmark[trial.Benchmark]
+		if !ok {
+			continue
+		}
+
+		metrics := make(map[string]float64)
+		for name, m := range expectationMetrics {
+			v := m.value(r)
+			if v != 0 {
+				metrics[name] = v
+			}
+		}
+		for stage, d := range r.Summary.Stages {
+			metrics["stage."+stage] = float64(d)
+		}
+
+		report.Trials = append(report.Trials, ExperimentTrialReport{
+			Variables: trial.Variables,
+			Benchmark: trial.Benchmark,
+			Metrics:   metrics,
+		})
+	}
+
+	for name := range names {
+		report.Variables = append(report.Variables, name)
+	}
+	sort.Strings(report.Variables)
+
+	return report
+}
+
+// ExperimentPivot is the mean of a metric across trials, by the values of a
+// row variable and a column variable.
+type ExperimentPivot struct {
+	Metric  string
+	Row     string
+	Column  string
+	Rows    []string
+	Columns []string
+
+	// Cells are indexed by row and then column, and are empty where no trial
+	// had the values.
+	Cells [][]string
+}
+
+// Pivot returns the mean of a metric by the values of the row and column
+// variables, averaging over the other variables. The column variable may be
+// empty to pivot by rows alone. Values are ordered as the trials first have
+// them.
+func (r ExperimentReport) Pivot(metric, row, column string) (ExperimentPivot, error) {
+	m, ok := lookupMetric(metric)
+	if !ok {
+		return ExperimentPivot{}, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized metric %q", metric)
+	}
+	for _, name := range []string{row, column} {
+		if name == "" {
+			continue
+		}
+		i := sort.SearchStrings(r.Variables, name)
+		if i == len(r.Variables) || r.Variables[i] != name {
+			return ExperimentPivot{}, errors.Wrapf(errdefs.ErrInvalidArgument, "experiment has no variable %q", name)
+		}
+	}
+
+	pivot := ExperimentPivot{
+		Metric: metric,
+		Row:    row,
+		Column: column,
+	}
+
+	type cell struct {
+		sum   float64
+		count int
+	}
+	var (
+		rowIndex = make(map[string]int)
+		colIndex = make(map[string]int)
+		cells    = make(map[[2]int]*cell)
+	)
+	index := func(values *[]string, indices map[string]int, iv IndependentVariable, name string) int {
+		value := ""
+		if name != "" {
+			value = formatVariable(iv[name])
+		}
+		i, ok := indices[value]
+		if !ok {
+			i = len(*values)
+			indices[value] = i
+			*values = append(*values, value)
+		}
+		return i
+	}
+
+	for _, trial := range r.Trials {
+		key := [2]int{
+			index(&pivot.Rows, rowIndex, trial.Variables, row),
+			index(&pivot.Columns, colIndex, trial.Variables, column),
+		}
+		c, ok := cells[key]
+		if !ok {
+			c = &cell{}
+			cells[key] = c
+		}
+		c.sum += trial.Metrics[metric]
+		c.count++
+	}
+
+	e := Expectation{Metric: metric, kind: m.kind}
+	for i := range pivot.Rows {
+		values := make([]string, len(pivot.Columns))
+		for j := range pivot.Columns {
+			c, ok := cells[[2]int{i, j}]
+			if ok {
+				values[j] = e.format(c.sum / float64(c.count))
+			}
+		}
+		pivot.Cells = append(pivot.Cells, values)
+	}
+
+	return pivot, nil
+}
+
+func formatVariable(v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
 func (m *db) GetExperiment(ctx context.Context, id string) (Experiment, error) {
 	var experiment Experiment
 
@@ -231,9 +486,20 @@ func readExperiment(bkt *bolt.Bucket, experiment *Experiment) error {
 		return err
 	}
 
-	experiment.Definition, err = readExperimentDefinition(bkt)
-	if err != nil {
-		return err
+	content := bkt.Get(bucketKeyDefinition)
+	if content != nil {
+		err = json.Unmarshal(content, &experiment.Definition)
+		if err != nil {
+			return err
+		}
+	}
+
+	content = bkt.Get(bucketKeyTrials)
+	if content != nil {
+		err = json.Unmarshal(content, &experiment.Trials)
+		if err != nil {
+			return err
+		}
 	}
 
 	experiment.Labels, err = readLabels(bkt)
@@ -257,42 +523,18 @@ func readExperiment(bkt *bolt.Bucket, experiment *Experiment) error {
 	})
 }
 
-func readExperimentDefinition(bkt *bolt.Bucket) (ExperimentDefinition, error) {
-	var edef ExperimentDefinition
-
-	dbkt := bkt.Bucket(bucketKeyDefinition)
-	if dbkt == nil {
-		return edef, nil
-	}
-
-	cbkt := dbkt.Bucket(bucketKeyCluster)
-	if cbkt != nil {
-		var err error
-		edef.ClusterDefinition, err = readClusterDefinition(cbkt)
-		if err != nil {
-			return edef, nil
-		}
-	}
-
-	sbkt := dbkt.Bucket(bucketKeyScenario)
-	if sbkt != nil {
-		var err error
-		edef.ScenarioDefinition, err = readScenarioDefinition(sbkt)
-		if err != nil {
-			return edef, nil
-		}
-	}
-
-	return edef, nil
-}
-
 func writeExperiment(bkt *bolt.Bucket, experiment *Experiment) error {
 	err := WriteTimestamps(bkt, experiment.CreatedAt, experiment.UpdatedAt)
 	if err != nil {
 		return err
 	}
 
-	err = writeExperimentDefinition(bkt, experiment.Definition)
+	definition, err := json.Marshal(experiment.Definition)
+	if err != nil {
+		return err
+	}
+
+	trials, err := json.Marshal(experiment.Trials)
 	if err != nil {
 		return err
 	}
@@ -305,6 +547,8 @@ func writeExperiment(bkt *bolt.Bucket, experiment *Experiment) error {
 	for _, f := range []field{
 		{bucketKeyID, []byte(experiment.ID)},
 		{bucketKeyStatus, []byte(experiment.Status)},
+		{bucketKeyDefinition, definition},
+		{bucketKeyTrials, trials},
 	} {
 		err = bkt.Put(f.key, f.value)
 		if err != nil {
@@ -314,12 +558,3 @@ func writeExperiment(bkt *bolt.Bucket, experiment *Experiment) error {
 
 	return nil
 }
-
-func writeExperimentDefinition(bkt *bolt.Bucket, edef ExperimentDefinition) error {
-	// dbkt, err := RecreateBucket(bkt, bucketKeyDefinition)
-	// if err != nil {
-	// 	return err
-	// }
-
-	return nil
-}
@@ -0,0 +1,77 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExperimentVariables(t *testing.T) {
+	edef := ExperimentDefinition{
+		IndependentVariable: []IndependentVariable{
+			{"objectSize": "1MB"},
+		},
+		Matrix: map[string][]interface{}{
+			"objectSize":        {"1MB", "10MB"},
+			VariableClusterSize: {3, 5, 10},
+		},
+	}
+
+	ivs := edef.Variables()
+	require.Len(t, ivs, 7)
+	require.Equal(t, IndependentVariable{"objectSize": "1MB"}, ivs[0])
+	require.Equal(t, IndependentVariable{VariableClusterSize: 3, "objectSize": "1MB"}, ivs[1])
+	require.Equal(t, IndependentVariable{VariableClusterSize: 3, "objectSize": "10MB"}, ivs[2])
+	require.Equal(t, IndependentVariable{VariableClusterSize: 10, "objectSize": "10MB"}, ivs[6])
+	require.NoError(t, edef.Validate())
+
+	require.Error(t, ExperimentDefinition{}.Validate())
+}
+
+func TestExperimentReportPivot(t *testing.T) {
+	trial := func(size string, nodes int, total time.Duration) ExperimentTrialReport {
+		return ExperimentTrialReport{
+			Variables: IndependentVariable{"objectSize": size, VariableClusterSize: nodes, "commit": "HEAD"},
+			Metrics:   map[string]float64{"totalTime": float64(total)},
+		}
+	}
+	report := ExperimentReport{
+		Variables: []string{VariableClusterSize, "commit", "objectSize"},
+		Trials: []ExperimentTrialReport{
+			trial("1MB", 3, time.Second),
+			trial("1MB", 3, 3*time.Second),
+			trial("1MB", 5, 4*time.Second),
+			trial("10MB", 3, 10*time.Second),
+		},
+	}
+
+	pivot, err := report.Pivot("totalTime", "objectSize", VariableClusterSize)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1MB", "10MB"}, pivot.Rows)
+	require.Equal(t, []string{"3", "5"}, pivot.Columns)
+	require.Equal(t, [][]string{{"2s", "4s"}, {"10s", ""}}, pivot.Cells)
+
+	pivot, err = report.Pivot("totalTime", "objectSize", "")
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"2.666666666s"}, {"10s"}}, pivot.Cells)
+
+	_, err = report.Pivot("totalTime", "regions", "")
+	require.Error(t, err)
+	_, err = report.Pivot("unknown", "objectSize", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+)
+
+// DefaultHeadlineMetrics are the metrics that summarize a report when no
+// others are chosen.
+var DefaultHeadlineMetrics = []string{
+	"totalTime",
+	"retrievalTime.p50",
+	"retrievalTime.p95",
+	"retrievalFailures",
+	"dataReceived",
+	"duplicateData",
+}
+
+// MetricValue is the formatted value of a metric of a report.
+type MetricValue struct {
+	Metric string
+	Value  string
+}
+
+// ValidateMetric returns an error if a metric is not recognized, where metrics
+// are named as in expectations.
+func ValidateMetric(name string) error {
+	if _, ok := lookupMetric(name); !ok {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized metric %q", name)
+	}
+	return nil
+}
+
+// HeadlineMetrics returns the formatted values of metrics of a report, leaving
+// out metrics that are not recognized.
+func HeadlineMetrics(report Report, names []string) []MetricValue {
+	var values []MetricValue
+	for _, name := range names {
+		m, ok := lookupMetric(name)
+		if !ok {
+			continue
+		}
+
+		e := Expectation{kind: m.kind}
+		values = append(values, MetricValue{
+			Metric: name,
+			Value:  e.format(m.value(report)),
+		})
+	}
+	return values
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadlineMetrics(t *testing.T) {
+	var report Report
+	report.Summary.TotalTime = 90 * time.Second
+	report.Summary.Stages = map[string]time.Duration{"seed": time.Second}
+	report.Aggregates.Totals.Retrieval.Failures = 3
+	report.Aggregates.Totals.Bitswap.DataReceived = 2000000
+
+	values := HeadlineMetrics(report, []string{"totalTime", "retrievalFailures", "dataReceived", "stage.seed", "unknown"})
+	require.Equal(t, []MetricValue{
+		{Metric: "totalTime", Value: "1m30s"},
+		{Metric: "retrievalFailures", Value: "3"},
+		{Metric: "dataReceived", Value: "2.0 MB"},
+		{Metric: "stage.seed", Value: "1s"},
+	}, values)
+
+	require.NoError(t, ValidateMetric("retrievalTime.p95"))
+	require.Error(t, ValidateMetric("unknown"))
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"math"
+	"time"
+)
+
+// HostUsage is the resource utilization of a node's host.
+type HostUsage struct {
+	// CPU is the fraction of CPU time spent busy across all cores, from 0 to
+	// 1.
+	CPU float64
+
+	// Memory is the bytes of memory in use, excluding buffers and caches.
+	Memory uint64
+
+	// DiskRead and DiskWrite are the bytes per second read from and written
+	// to block devices.
+	DiskRead  float64
+	DiskWrite float64
+
+	// NetworkReceive and NetworkTransmit are the bytes per second received
+	// and transmitted by network interfaces other than loopback.
+	NetworkReceive  float64
+	NetworkTransmit float64
+}
+
+// HostSample is the utilization of a node's host over the interval that
+// ended at Time, in the clock of the node's labagent.
+type HostSample struct {
+	Time time.Time
+
+	HostUsage
+}
+
+// ReportHost is the resource utilization of a node's host while the measured
+// stages ran, sampled by its labagent.
+type ReportHost struct {
+	// Series are the samples taken while the measured stages ran, in the
+	// order they were taken.
+	Series []ReportHostSample `json:",omitempty"`
+
+	// Peak is the highest utilization of each resource in the series.
+	Peak HostUsage
+
+	// Mean is the mean utilization of each resource in the series.
+	Mean HostUsage
+}
+
+// ReportHostSample is the utilization of a node's host over the interval
+// that ended at Elapsed.
+type ReportHostSample struct {
+	// Elapsed is the time since the measured stages started.
+	Elapsed time.Duration
+
+	HostUsage
+}
+
+// NewReportHost returns the utilization of a host from the samples that fall
+// between start and end in the local clock, where offset is how far the
+// node's clock is ahead of the local clock.
+func NewReportHost(samples []HostSample, start, end time.Time, offset time.Duration) ReportHost {
+	var host ReportHost
+	for _, sample := range samples {
+		t := sample.Time.Add(-offset)
+		if t.Before(start) || t.After(end) {
+			continue
+		}
+
+		usage := sample.HostUsage
+		host.Series = append(host.Series, ReportHostSample{
+			Elapsed:   t.Sub(start),
+			HostUsage: usage,
+		})
+
+		peak := &host.Peak
+		peak.CPU = math.Max(peak.CPU, usage.CPU)
+		if usage.Memory > peak.Memory {
+			peak.Memory = usage.Memory
+		}
+		peak.DiskRead = math.Max(peak.DiskRead, usage.DiskRead)
+		peak.DiskWrite = math.Max(peak.DiskWrite, usage.DiskWrite)
+		peak.NetworkReceive = math.Max(peak.NetworkReceive, usage.NetworkReceive)
+		peak.NetworkTransmit = math.Max(peak.NetworkTransmit, usage.NetworkTransmit)
+
+		mean := &host.Mean
+		mean.CPU += usage.CPU
+		mean.Memory += usage.Memory
+		mean.DiskRead += usage.DiskRead
+		mean.DiskWrite += usage.DiskWrite
+		mean.NetworkReceive += usage.NetworkReceive
+		mean.NetworkTransmit += usage.NetworkTransmit
+	}
+
+	n := len(host.Series)
+	if n == 0 {
+		return host
+	}
+
+	mean := &host.Mean
+	mean.CPU /= float64(n)
+	mean.Memory /= uint64(n)
+	mean.DiskRead /= float64(n)
+	mean.DiskWrite /= float64(n)
+	mean.NetworkReceive /= float64(n)
+	mean.NetworkTransmit /= float64(n)
+	return host
+}
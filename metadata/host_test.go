@@ -0,0 +1,61 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReportHost(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := start.Add(3 * time.Second)
+
+	// The node's clock is a minute ahead of the local clock.
+	offset := time.Minute
+	sample := func(elapsed time.Duration, cpu float64, memory uint64) HostSample {
+		return HostSample{
+			Time: start.Add(offset + elapsed),
+			HostUsage: HostUsage{
+				CPU:            cpu,
+				Memory:         memory,
+				NetworkReceive: cpu * 100,
+			},
+		}
+	}
+
+	host := NewReportHost([]HostSample{
+		sample(-time.Second, 1, 4096),
+		sample(time.Second, 0.2, 1024),
+		sample(2*time.Second, 0.8, 3072),
+		sample(3*time.Second, 0.5, 2048),
+		sample(4*time.Second, 1, 4096),
+	}, start, end, offset)
+
+	require.Len(t, host.Series, 3)
+	require.Equal(t, time.Second, host.Series[0].Elapsed)
+	require.Equal(t, 3*time.Second, host.Series[2].Elapsed)
+
+	require.Equal(t, 0.8, host.Peak.CPU)
+	require.Equal(t, uint64(3072), host.Peak.Memory)
+	require.Equal(t, 80.0, host.Peak.NetworkReceive)
+
+	require.InDelta(t, 0.5, host.Mean.CPU, 1e-9)
+	require.Equal(t, uint64(2048), host.Mean.Memory)
+
+	require.Equal(t, ReportHost{}, NewReportHost(nil, start, end, offset))
+}
@@ -0,0 +1,31 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// LabContext is a named labd endpoint that labctl sends commands to, such as
+// a staging or production lab.
+type LabContext struct {
+	Name string `json:"name"`
+
+	Address string `json:"address"`
+
+	// Token is sent as a bearer token to labd, for labs behind an
+	// authenticating proxy.
+	Token string `json:"token,omitempty"`
+
+	// Current is whether labctl uses the context when neither --context nor
+	// --address is given.
+	Current bool `json:"current,omitempty"`
+}
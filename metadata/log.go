@@ -0,0 +1,37 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import "time"
+
+// LogSource is the daemon on a node that logged a line.
+type LogSource string
+
+var (
+	LogSourceLabagent LogSource = "labagent"
+	LogSourceLabapp   LogSource = "labapp"
+)
+
+// LogLine is a line logged by a node's labagent or labapp, at Time in the
+// node's clock.
+type LogLine struct {
+	// Node is the ID of the node that logged the line. It is only set when the
+	// lines of many nodes are multiplexed.
+	Node string `json:",omitempty"`
+
+	Source LogSource
+	Time   time.Time
+	Text   string
+}
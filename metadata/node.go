@@ -44,6 +44,13 @@ type Node struct {
 
 	AppPort int
 
+	// Region and InstanceType are those of the node's cluster group or host,
+	// and Arch is the CPU architecture of its instance type, such as "amd64"
+	// or "arm64".
+	Region       string
+	InstanceType string
+	Arch         string
+
 	Peer PeerDefinition
 
 	Labels []string
@@ -51,6 +58,39 @@ type Node struct {
 	CreatedAt, UpdatedAt time.Time
 }
 
+// Attributes returns the structured fields of the node that can be queried,
+// unlike labels which may drift from the cluster definition.
+func (n Node) Attributes() map[string]string {
+	return map[string]string{
+		"id":           n.ID,
+		"address":      n.Address,
+		"region":       n.Region,
+		"instanceType": n.InstanceType,
+		"arch":         n.Arch,
+		"gitReference": n.Peer.GitReference,
+	}
+}
+
+// InstanceArch returns the CPU architecture of an EC2 instance type. Graviton
+// instance types, whose family has a "g" attribute after its generation such as
+// "m6g" or "c7gn", are arm64 and all others are amd64.
+func InstanceArch(instanceType string) string {
+	if instanceType == "" {
+		return ""
+	}
+
+	family := strings.SplitN(instanceType, ".", 2)[0]
+	if family == "a1" {
+		return "arm64"
+	}
+
+	i := strings.IndexAny(family, "0123456789")
+	if i >= 0 && strings.Contains(strings.TrimLeft(family[i:], "0123456789"), "g") {
+		return "arm64"
+	}
+	return "amd64"
+}
+
 type PeerDefinition struct {
 	GitReference string
 
@@ -61,8 +101,152 @@ type PeerDefinition struct {
 	SecurityTransports []string
 
 	Routing string
+
+	// NetworkStack is the IP stack libp2p listens on. Defaults to ipv4.
+	NetworkStack NetworkStack
+
+	// BitswapNoProvide stops bitswap from announcing the blocks it receives
+	// to content routing.
+	BitswapNoProvide bool
+
+	// BitswapSearchDelay is how long bitswap waits for blocks from connected
+	// peers before searching for providers. Defaults to bitswap's default.
+	BitswapSearchDelay time.Duration
+
+	// BitswapTaskWorkers is how many workers send blocks to the peers that
+	// want them concurrently. Defaults to bitswap's default.
+	BitswapTaskWorkers int
+
+	// BitswapMaxOutstandingWants and BitswapEngineBlockstoreWorkers are not
+	// configurable in the version of bitswap that labapp is built with, so
+	// they are rejected by Validate unless zero.
+	BitswapMaxOutstandingWants     int
+	BitswapEngineBlockstoreWorkers int
+
+	// BitswapTrace records every bitswap message sent and received in the
+	// peer's report.
+	BitswapTrace bool
+
+	// Collectors are the metrics collectors whose metrics are merged into
+	// the peer's report, such as "datastore" or "exec:<command>".
+	Collectors []string
+}
+
+// UpdatePeerDefinition returns a copy of the peer definition with settings of
+// the form key=value applied.
+func UpdatePeerDefinition(pdef PeerDefinition, settings []string) (PeerDefinition, error) {
+	for _, setting := range settings {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return pdef, errors.Wrapf(errdefs.ErrInvalidArgument, "peer setting %q must be of the form key=value", setting)
+		}
+
+		key, value := parts[0], parts[1]
+		var err error
+		switch key {
+		case "transports":
+			pdef.Transports = strings.Split(value, ",")
+		case "muxers":
+			pdef.Muxers = strings.Split(value, ",")
+		case "security":
+			pdef.SecurityTransports = strings.Split(value, ",")
+		case "routing":
+			pdef.Routing = value
+		case "bitswap-provide":
+			var provide bool
+			provide, err = strconv.ParseBool(value)
+			pdef.BitswapNoProvide = !provide
+		case "bitswap-search-delay":
+			pdef.BitswapSearchDelay, err = time.ParseDuration(value)
+		case "bitswap-task-workers":
+			pdef.BitswapTaskWorkers, err = strconv.Atoi(value)
+			if err == nil && pdef.BitswapTaskWorkers < 1 {
+				err = errors.New("must be at least 1")
+			}
+		case "bitswap-max-outstanding-wants", "bitswap-engine-blockstore-workers":
+			err = errors.New("not supported by this version of bitswap")
+		case "bitswap-trace":
+			pdef.BitswapTrace, err = strconv.ParseBool(value)
+		case "collectors":
+			pdef.Collectors = strings.Split(value, ",")
+		default:
+			return pdef, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized peer setting %q", key)
+		}
+		if err != nil {
+			return pdef, errors.Wrapf(errdefs.ErrInvalidArgument, "peer setting %s=%q: %s", key, value, err)
+		}
+	}
+	return pdef, nil
 }
 
+// Validate returns an error if the peer definition sets bitswap parameters
+// that labapp can't apply.
+func (d PeerDefinition) Validate() error {
+	if d.BitswapTaskWorkers < 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "bitswap task workers must not be negative")
+	}
+	if d.BitswapMaxOutstandingWants != 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "bitswap max outstanding wants is not supported by this version of bitswap")
+	}
+	if d.BitswapEngineBlockstoreWorkers != 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "bitswap engine blockstore workers is not supported by this version of bitswap")
+	}
+	return nil
+}
+
+// Override returns a copy of the peer definition with the fields that are set
+// in o replacing its own. Boolean fields can only be overridden to true.
+func (d PeerDefinition) Override(o PeerDefinition) PeerDefinition {
+	if o.GitReference != "" {
+		d.GitReference = o.GitReference
+	}
+	if len(o.Transports) > 0 {
+		d.Transports = o.Transports
+	}
+	if len(o.Muxers) > 0 {
+		d.Muxers = o.Muxers
+	}
+	if len(o.SecurityTransports) > 0 {
+		d.SecurityTransports = o.SecurityTransports
+	}
+	if o.Routing != "" {
+		d.Routing = o.Routing
+	}
+	if o.NetworkStack != "" {
+		d.NetworkStack = o.NetworkStack
+	}
+	if o.BitswapNoProvide {
+		d.BitswapNoProvide = true
+	}
+	if o.BitswapSearchDelay != 0 {
+		d.BitswapSearchDelay = o.BitswapSearchDelay
+	}
+	if o.BitswapTaskWorkers != 0 {
+		d.BitswapTaskWorkers = o.BitswapTaskWorkers
+	}
+	if o.BitswapMaxOutstandingWants != 0 {
+		d.BitswapMaxOutstandingWants = o.BitswapMaxOutstandingWants
+	}
+	if o.BitswapEngineBlockstoreWorkers != 0 {
+		d.BitswapEngineBlockstoreWorkers = o.BitswapEngineBlockstoreWorkers
+	}
+	if o.BitswapTrace {
+		d.BitswapTrace = true
+	}
+	if len(o.Collectors) > 0 {
+		d.Collectors = o.Collectors
+	}
+	return d
+}
+
+type NetworkStack string
+
+var (
+	NetworkIPv4 NetworkStack = "ipv4"
+	NetworkIPv6 NetworkStack = "ipv6"
+	NetworkDual NetworkStack = "dual"
+)
+
 func (m *db) GetNode(ctx context.Context, cluster, id string) (Node, error) {
 	var node Node
 
@@ -290,6 +474,12 @@ func readNode(bkt *bolt.Bucket, node *Node) error {
 			node.AgentPort, _ = strconv.Atoi(string(v))
 		case string(bucketKeyAppPort):
 			node.AppPort, _ = strconv.Atoi(string(v))
+		case string(bucketKeyRegion):
+			node.Region = string(v)
+		case string(bucketKeyInstanceType):
+			node.InstanceType = string(v)
+		case string(bucketKeyArch):
+			node.Arch = string(v)
 		}
 
 		return nil
@@ -326,6 +516,20 @@ func readPeerDefinition(bkt *bolt.Bucket) (PeerDefinition, error) {
 			}
 		case string(bucketKeyRouting):
 			pdef.Routing = string(v)
+		case string(bucketKeyNetworkStack):
+			pdef.NetworkStack = NetworkStack(v)
+		case string(bucketKeyBitswapNoProvide):
+			pdef.BitswapNoProvide, _ = strconv.ParseBool(string(v))
+		case string(bucketKeyBitswapSearchDelay):
+			pdef.BitswapSearchDelay, _ = time.ParseDuration(string(v))
+		case string(bucketKeyBitswapTaskWorkers):
+			pdef.BitswapTaskWorkers, _ = strconv.Atoi(string(v))
+		case string(bucketKeyBitswapTrace):
+			pdef.BitswapTrace, _ = strconv.ParseBool(string(v))
+		case string(bucketKeyCollectors):
+			if len(v) > 0 {
+				pdef.Collectors = strings.Split(string(v), ",")
+			}
 		}
 
 		return nil
@@ -354,6 +558,9 @@ func writeNode(bkt *bolt.Bucket, node *Node) error {
 		{bucketKeyAddress, []byte(node.Address)},
 		{bucketKeyAgentPort, []byte(strconv.Itoa(node.AgentPort))},
 		{bucketKeyAppPort, []byte(strconv.Itoa(node.AppPort))},
+		{bucketKeyRegion, []byte(node.Region)},
+		{bucketKeyInstanceType, []byte(node.InstanceType)},
+		{bucketKeyArch, []byte(node.Arch)},
 	} {
 		err = bkt.Put(f.key, f.value)
 		if err != nil {
@@ -384,6 +591,12 @@ func writePeerDefinition(bkt *bolt.Bucket, pdef PeerDefinition) error {
 		{bucketKeyMuxers, []byte(strings.Join(pdef.Muxers, ","))},
 		{bucketKeySecurityTransports, []byte(strings.Join(pdef.SecurityTransports, ","))},
 		{bucketKeyRouting, []byte(pdef.Routing)},
+		{bucketKeyNetworkStack, []byte(pdef.NetworkStack)},
+		{bucketKeyBitswapNoProvide, []byte(strconv.FormatBool(pdef.BitswapNoProvide))},
+		{bucketKeyBitswapSearchDelay, []byte(pdef.BitswapSearchDelay.String())},
+		{bucketKeyBitswapTaskWorkers, []byte(strconv.Itoa(pdef.BitswapTaskWorkers))},
+		{bucketKeyBitswapTrace, []byte(strconv.FormatBool(pdef.BitswapTrace))},
+		{bucketKeyCollectors, []byte(strings.Join(pdef.Collectors, ","))},
 	} {
 		err = dbkt.Put(f.key, f.value)
 		if err != nil {
@@ -0,0 +1,61 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceArch(t *testing.T) {
+	for instanceType, arch := range map[string]string{
+		"":             "",
+		"t2.micro":     "amd64",
+		"m5dn.large":   "amd64",
+		"g5.xlarge":    "amd64",
+		"a1.large":     "arm64",
+		"m6g.large":    "arm64",
+		"c6gn.xlarge":  "arm64",
+		"r6gd.large":   "arm64",
+		"g5g.xlarge":   "arm64",
+		"im4gn.large":  "arm64",
+		"u-6tb1.metal": "amd64",
+	} {
+		require.Equal(t, arch, InstanceArch(instanceType), instanceType)
+	}
+}
+
+func TestPeerDefinitionValidate(t *testing.T) {
+	require.NoError(t, PeerDefinition{BitswapTaskWorkers: 4}.Validate())
+
+	for _, pdef := range []PeerDefinition{
+		{BitswapTaskWorkers: -1},
+		{BitswapMaxOutstandingWants: 16},
+		{BitswapEngineBlockstoreWorkers: 64},
+	} {
+		err := pdef.Validate()
+		require.True(t, errdefs.IsInvalidArgument(err), "%+v", pdef)
+	}
+
+	for _, setting := range []string{
+		"bitswap-max-outstanding-wants=16",
+		"bitswap-engine-blockstore-workers=64",
+	} {
+		_, err := UpdatePeerDefinition(PeerDefinition{}, []string{setting})
+		require.True(t, errdefs.IsInvalidArgument(err), setting)
+	}
+}
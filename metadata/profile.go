@@ -0,0 +1,123 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ProfileKind is a kind of pprof profile captured from a labapp.
+type ProfileKind string
+
+var (
+	// ProfileCPU is the CPU profile of a labapp while the measured stages
+	// ran.
+	ProfileCPU ProfileKind = "cpu"
+
+	// ProfileHeap is the heap profile of a labapp when the measured stages
+	// ended.
+	ProfileHeap ProfileKind = "heap"
+)
+
+// ReportProfile is the digest of a profile captured from a node, whose
+// content is stored with the benchmark.
+type ReportProfile struct {
+	Kind ProfileKind
+
+	// Digest is the hex encoded SHA-256 digest of the profile.
+	Digest string
+
+	// Size is the size of the profile in bytes.
+	Size int64
+}
+
+// NewReportProfile returns the digest of a profile.
+func NewReportProfile(kind ProfileKind, profile []byte) ReportProfile {
+	digest := sha256.Sum256(profile)
+	return ReportProfile{
+		Kind:   kind,
+		Digest: hex.EncodeToString(digest[:]),
+		Size:   int64(len(profile)),
+	}
+}
+
+func (m *db) GetProfile(ctx context.Context, id, node string, kind ProfileKind) ([]byte, error) {
+	var profile []byte
+
+	err := m.View(ctx, func(tx *bolt.Tx) error {
+		bkt := getBenchmarksBucket(tx)
+		if bkt == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "benchmark %q", id)
+		}
+
+		bbkt := bkt.Bucket([]byte(id))
+		if bbkt == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "benchmark %q", id)
+		}
+
+		var content []byte
+		pbkt := bbkt.Bucket(bucketKeyProfiles)
+		if pbkt != nil {
+			nbkt := pbkt.Bucket([]byte(node))
+			if nbkt != nil {
+				content = nbkt.Get([]byte(kind))
+			}
+		}
+		if content == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "no %s profile of node %q available", kind, node)
+		}
+
+		// Values are only valid for the life of the transaction.
+		profile = append([]byte(nil), content...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func (m *db) CreateProfile(ctx context.Context, id, node string, kind ProfileKind, profile []byte) error {
+	return m.Update(ctx, func(tx *bolt.Tx) error {
+		bkt, err := createBenchmarksBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		bbkt := bkt.Bucket([]byte(id))
+		if bbkt == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "benchmark %q", id)
+		}
+
+		pbkt, err := bbkt.CreateBucketIfNotExists(bucketKeyProfiles)
+		if err != nil {
+			return err
+		}
+
+		nbkt, err := pbkt.CreateBucketIfNotExists([]byte(node))
+		if err != nil {
+			return err
+		}
+
+		return nbkt.Put([]byte(kind), profile)
+	})
+}
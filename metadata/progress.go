@@ -0,0 +1,126 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import "time"
+
+// BenchmarkProgress is how far a benchmark has progressed while it runs.
+type BenchmarkProgress struct {
+	ID string
+
+	Status BenchmarkStatus
+
+	// Trial is the trial that is running, counting from one, of Trials.
+	Trial  int
+	Trials int
+
+	// Stages are the stages of the trial that are running.
+	Stages []string
+
+	// StagesCompleted is the number of stages of the trial that completed or
+	// were skipped, of StagesTotal.
+	StagesCompleted int
+	StagesTotal     int
+
+	// ActionsCompleted is the number of tasks of the trial that nodes
+	// completed, of ActionsTotal. Soaks loop their stages, so they complete
+	// more actions than planned.
+	ActionsCompleted int
+	ActionsTotal     int
+
+	StartedAt time.Time
+
+	// Elapsed is the time since the benchmark started.
+	Elapsed time.Duration
+
+	// ETA is when the benchmark is estimated to complete, or zero if it
+	// cannot be estimated yet.
+	ETA time.Time
+}
+
+// Done returns whether the benchmark is no longer running.
+func (p BenchmarkProgress) Done() bool {
+	return p.Status != BenchmarkPlanning && p.Status != BenchmarkRunning
+}
+
+// Percent returns how far the benchmark has progressed from 0 to 100, as
+// estimated from its elapsed time and ETA.
+func (p BenchmarkProgress) Percent() float64 {
+	if p.Done() {
+		return 100
+	}
+
+	total := p.ETA.Sub(p.StartedAt)
+	if p.ETA.IsZero() || total <= 0 {
+		return 0
+	}
+
+	percent := 100 * float64(p.Elapsed) / float64(total)
+	if percent > 100 {
+		percent = 100
+	}
+	return percent
+}
+
+// Estimate sets the ETA of the benchmark at now by extrapolating the time it
+// took to make its progress so far, where the progress of the current trial
+// is its fraction from 0 to 1.
+func (p *BenchmarkProgress) Estimate(now time.Time, fraction float64) {
+	p.Elapsed = now.Sub(p.StartedAt)
+	p.ETA = time.Time{}
+	if p.Trials == 0 || fraction <= 0 {
+		return
+	}
+
+	done := (float64(p.Trial-1) + fraction) / float64(p.Trials)
+	if done > 1 {
+		done = 1
+	}
+	p.ETA = p.StartedAt.Add(time.Duration(float64(p.Elapsed) / done))
+}
+
+// ProgressEvent is a machine-readable update on the progress of an operation
+// that labctl is waiting for, such as creating a cluster or running a
+// benchmark.
+type ProgressEvent struct {
+	Time time.Time
+
+	// Kind is the kind of resource being operated on, either cluster or
+	// benchmark.
+	Kind string
+	ID   string
+
+	// Status is the status of the resource, and Stage is what it is doing,
+	// such as the stages of a benchmark's scenario that are running.
+	Status string
+	Stage  string `json:",omitempty"`
+
+	// Percent is how far the operation has progressed from 0 to 100, or 0 if
+	// it cannot be estimated yet.
+	Percent float64
+
+	// Nodes is the number of nodes of a cluster that are provisioned, of
+	// NodesTotal.
+	Nodes      int `json:",omitempty"`
+	NodesTotal int `json:",omitempty"`
+
+	// Trial is the trial of a benchmark that is running, of Trials, and
+	// Actions is the number of its tasks that nodes completed, of
+	// ActionsTotal.
+	Trial        int `json:",omitempty"`
+	Trials       int `json:",omitempty"`
+	Actions      int `json:",omitempty"`
+	ActionsTotal int `json:",omitempty"`
+}
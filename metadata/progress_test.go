@@ -0,0 +1,61 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarkProgressEstimate(t *testing.T) {
+	start := time.Unix(1000, 0)
+	p := BenchmarkProgress{
+		Status:    BenchmarkRunning,
+		Trial:     2,
+		Trials:    4,
+		StartedAt: start,
+	}
+
+	// Half of the second trial is three eighths of the benchmark.
+	p.Estimate(start.Add(3*time.Minute), 0.5)
+	require.Equal(t, 3*time.Minute, p.Elapsed)
+	require.Equal(t, start.Add(8*time.Minute), p.ETA)
+
+	p.Estimate(start.Add(time.Minute), 0)
+	require.True(t, p.ETA.IsZero())
+	require.False(t, p.Done())
+
+	p.Status = BenchmarkDone
+	require.True(t, p.Done())
+}
+
+func TestBenchmarkProgressPercent(t *testing.T) {
+	start := time.Unix(1000, 0)
+	p := BenchmarkProgress{
+		Status:    BenchmarkRunning,
+		Trial:     1,
+		Trials:    2,
+		StartedAt: start,
+	}
+	require.Equal(t, float64(0), p.Percent())
+
+	p.Estimate(start.Add(time.Minute), 0.5)
+	require.Equal(t, float64(25), p.Percent())
+
+	p.Status = BenchmarkDone
+	require.Equal(t, float64(100), p.Percent())
+}
@@ -17,6 +17,9 @@ package metadata
 import (
 	"context"
 	"encoding/json"
+	"math"
+	"math/bits"
+	"sort"
 	"time"
 
 	"github.com/Netflix/p2plab/errdefs"
@@ -34,11 +37,102 @@ type Report struct {
 	Nodes map[string]ReportNode
 
 	Queries map[string][]string
+
+	// Trials summarizes each trial when the scenario ran more than once, in
+	// which case the summary is the mean of the trials, and the aggregates,
+	// nodes and queries are of the last trial.
+	Trials []ReportTrial
+
+	// Statistics are the means of metrics across trials with their
+	// confidence intervals and outlying trials, when the scenario ran more
+	// than once.
+	Statistics ReportStatistics
+
+	// Snapshots are the interim reports collected during a soak, in the order
+	// they were collected.
+	Snapshots []ReportSnapshot
+	// Series maps a node ID to the samples of its counters taken while the
+	// measured stages ran, in the order they were taken, if the scenario
+	// samples nodes.
+	Series map[string][]ReportSample
+
+	// Bandwidth maps a node ID to the bytes it exchanged with each other
+	// node of the cluster.
+	Bandwidth ReportBandwidthMatrix `json:",omitempty"`
+
+	// Topology is the connection graph of the nodes when the measured stages
+	// started and ended.
+	Topology ReportTopology
+
+	// Downsampled is set once the raw samples of the report have been
+	// downsampled by labd's retention policy.
+	Downsampled *ReportDownsampling `json:",omitempty"`
+}
+
+// ReportBandwidthMatrix maps a node ID to the bytes it exchanged with other
+// nodes by their IDs, as counted by the node.
+type ReportBandwidthMatrix map[string]map[string]ReportPairBandwidth
+
+// ReportPairBandwidth is the bytes a node sent to and received from another
+// node.
+type ReportPairBandwidth struct {
+	Sent     int64
+	Received int64
+}
+
+// ReportSample is a node's counters sampled while the measured stages ran,
+// which accumulate like those of the node's report.
+type ReportSample struct {
+	// Elapsed is the time since the measured stages started.
+	Elapsed time.Duration
+
+	Bitswap ReportBitswap
+
+	Bandwidth metrics.Stats
+
+	Connections ReportConnections
+}
+
+// ReportSnapshot is an interim report collected during a soak. Its metrics
+// accumulate from the start of the soak.
+type ReportSnapshot struct {
+	// Elapsed is the time since the soak started.
+	Elapsed time.Duration
+
+	// Iterations is the number of completed iterations of the measured
+	// stages.
+	Iterations int
+
+	Aggregates ReportAggregates
+}
+
+type ReportTrial struct {
+	Summary ReportSummary
+
+	Aggregates ReportAggregates
 }
 
 type ReportSummary struct {
 	TotalTime time.Duration
 
+	// TotalTimeStdDev is the standard deviation of the total time across
+	// trials.
+	TotalTimeStdDev time.Duration
+
+	// Seed is the random seed the scenario was planned with.
+	Seed int64
+
+	// Stages is the time taken by each measured stage of the scenario.
+	Stages map[string]time.Duration
+
+	// Skipped are the measured stages that were skipped because their
+	// conditions did not hold.
+	Skipped []string
+
+	// Expectations are the results of evaluating the scenario's
+	// expectations against the report.
+	Expectations []ExpectationResult
+
 	Trace string
 
 	Metrics string
@@ -52,6 +146,151 @@ type ReportNode struct {
 	Bitswap ReportBitswap
 
 	Bandwidth ReportBandwidth
+
+	Pubsub ReportPubsub
+
+	DHT ReportDHT
+
+	Load ReportLoad
+
+	Retrieval ReportRetrieval
+
+	Add ReportAdd
+
+	Gateway ReportGateway
+
+	Update ReportUpdate
+
+	Resources ReportResources
+
+	Connections ReportConnections
+
+	// Host is the resource utilization of the node's host while the
+	// measured stages ran. It is not aggregated.
+	Host ReportHost
+
+	// Exec are the commands and scripts run by the node, in the order they
+	// completed. They are not aggregated.
+	Exec []ReportExec
+
+	// BitswapTrace are the bitswap messages of the node, if its peer traces
+	// bitswap. They are not aggregated.
+	BitswapTrace ReportBitswapTrace
+
+	// PeerID is the libp2p peer ID of the node, by which other nodes count
+	// the bandwidth they exchanged with it.
+	PeerID string `json:",omitempty"`
+
+	// Custom are the metrics of the peer's metrics collectors by
+	// "<collector>.<name>".
+	Custom map[string]float64 `json:",omitempty"`
+
+	// Profiles are the digests of the profiles captured from the node's
+	// labapp while the measured stages ran, which are stored with the
+	// benchmark. They are not aggregated.
+	Profiles []ReportProfile `json:",omitempty"`
+
+	// Labels are the labels of the node other than its ID, such as its
+	// region and instance type, by which it is matched to the nodes of other
+	// reports. They are not aggregated.
+	Labels []string `json:",omitempty"`
+}
+
+// ReportBitswapTrace is every entry of the bitswap messages sent and received
+// by a node.
+type ReportBitswapTrace struct {
+	// Events are the BitswapEvents in the order they occurred, compressed
+	// by EncodeBitswapEvents.
+	Events []byte `json:",omitempty"`
+
+	// Dropped is the number of events beyond MaxBitswapEvents that were not
+	// recorded.
+	Dropped int64 `json:",omitempty"`
+}
+
+// ReportUpdate measures how quickly updated DAGs propagate, from their new
+// roots being announced to the followers that retrieve them.
+type ReportUpdate struct {
+	// Published is the number of updates announced.
+	Published int64
+
+	// Received is the number of updates retrieved by followers.
+	Received int64
+
+	// Lost is the number of updates followers expected but did not retrieve
+	// in time.
+	Lost int64
+
+	// Failures is the number of updates that could not be made or retrieved.
+	Failures int64
+
+	// Propagation is the time from each update being announced to a follower
+	// having retrieved it.
+	Propagation ReportHistogram
+}
+
+// ReportExec is the outcome of a command or script run by a node.
+type ReportExec struct {
+	// Command is the name of the command, or the cid of the script.
+	Command string
+	Args    []string
+
+	// ExitCode is the exit code of the command, or -1 if it did not exit.
+	ExitCode int
+
+	// Stdout is the standard output of the command, truncated to
+	// MaxExecOutput bytes.
+	Stdout string
+
+	Time time.Duration
+
+	// Action is the ID of the action that ran the command.
+	Action string `json:",omitempty"`
+}
+
+// MaxExecOutput is the number of bytes of a command's standard output that
+// are kept in the report.
+const MaxExecOutput = 64 * 1024
+
+// ReportGateway measures retrievals through HTTP gateways, where each
+// retrieval is a request for a file or directory listing.
+type ReportGateway struct {
+	Requests int64
+	Failures int64
+
+	// Bytes is the number of bytes of files received.
+	Bytes int64
+
+	// FirstByte is the time from each request being sent to the first byte
+	// of its response being received.
+	FirstByte ReportHistogram
+
+	// Time is the time taken to retrieve each object, including every file
+	// and directory listing within it.
+	Time ReportHistogram
+}
+
+// ReportConnections are the connections a node had open when its report was
+// collected.
+type ReportConnections struct {
+	// Peers is the number of peers with open connections.
+	Peers int64
+
+	// Conns is the number of open connections, which may be more than one
+	// per peer.
+	Conns int64
+}
+
+// ReportResources is the resource usage of a node's peer when its report was
+// collected, which is never reset.
+type ReportResources struct {
+	// HeapAlloc is the bytes of allocated heap objects.
+	HeapAlloc uint64
+
+	// HeapObjects is the number of allocated heap objects.
+	HeapObjects uint64
+
+	Goroutines int64
 }
 
 type ReportBitswap struct {
@@ -64,6 +303,284 @@ type ReportBitswap struct {
 	MessagesReceived uint64
 }
 
+// ReportPubsub measures the propagation of pubsub messages.
+type ReportPubsub struct {
+	MessagesPublished int64
+	MessagesReceived  int64
+
+	// MessagesLost is the number of messages subscribers expected but did not
+	// receive before timing out.
+	MessagesLost int64
+
+	// Latency is the time between each message being published and received,
+	// by the clocks of its publisher and subscriber, which are assumed to be
+	// synchronized.
+	Latency ReportHistogram
+}
+
+// ReportDHT measures the operations made against the DHT.
+type ReportDHT struct {
+	Provide       ReportOperation
+	FindProviders ReportOperation
+	FindPeer      ReportOperation
+}
+
+// ReportOperation measures repeated operations of the same kind.
+type ReportOperation struct {
+	Count    int64
+	Failures int64
+
+	// Time is the time taken by each operation, including those that failed.
+	Time ReportHistogram
+}
+
+// Record adds an operation that took d to the report.
+func (r *ReportOperation) Record(d time.Duration, err error) {
+	r.Count++
+	if err != nil {
+		r.Failures++
+	}
+	r.Time.Observe(d)
+}
+
+// Add adds the operations of another report to the report.
+func (r *ReportOperation) Add(o ReportOperation) {
+	r.Count += o.Count
+	r.Failures += o.Failures
+	r.Time.Merge(o.Time)
+}
+
+// ReportRetrieval measures the time taken to retrieve objects.
+type ReportRetrieval struct {
+	Failures int64
+	Time     ReportHistogram
+}
+
+// ReportLoad measures retrievals issued at a sustained rate.
+type ReportLoad struct {
+	Requests int64
+	Failures int64
+
+	// Missed is the number of retrievals that were not issued because too
+	// many were already in flight.
+	Missed int64
+
+	// Elapsed is the time from the first retrieval being issued to the last
+	// one completing.
+	Elapsed time.Duration
+
+	// Latency is the time from each retrieval being scheduled to it
+	// completing, so that retrievals delayed by earlier ones are accounted
+	// for.
+	Latency ReportHistogram
+}
+
+// Throughput returns the mean number of retrievals completed per second.
+func (r ReportLoad) Throughput() float64 {
+	if r.Elapsed == 0 {
+		return 0
+	}
+	return float64(r.Requests-r.Failures) / r.Elapsed.Seconds()
+}
+
+// ReportAdd measures importing data into a peer's blockstore.
+type ReportAdd struct {
+	Files    int64
+	Failures int64
+
+	// Bytes is the size of the data imported.
+	Bytes int64
+
+	// Time is how long each file took to be chunked into a DAG and stored.
+	Time ReportHistogram
+}
+
+// Throughput returns the number of bytes imported per second spent adding.
+func (r ReportAdd) Throughput() float64 {
+	if r.Time.Total == 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Time.Total.Seconds()
+}
+
+const (
+	// histogramUnit is the resolution of a ReportHistogram.
+	histogramUnit = time.Microsecond
+
+	// histogramSubBucketBits is the log2 of the number of buckets below the
+	// first power of two that is split, after which each power of two is
+	// split into half as many buckets. Durations are therefore recorded to
+	// within 1/128 of their magnitude.
+	histogramSubBucketBits = 8
+
+	histogramSubBucketHalf = 1 << (histogramSubBucketBits - 1)
+)
+
+// ReportHistogram is a high dynamic range distribution of durations that can
+// be merged across nodes and trials. Each power of two is split into
+// logarithmically equal buckets, so that percentiles are accurate to within
+// 1% at any magnitude.
+type ReportHistogram struct {
+	// Buckets maps the index of each bucket to the number of durations
+	// observed in it. Empty buckets are omitted.
+	Buckets map[int]int64 `json:",omitempty"`
+
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// HistogramBucket is a bucket of a ReportHistogram.
+type HistogramBucket struct {
+	// Upper is the largest duration recorded in the bucket.
+	Upper time.Duration
+	Count int64
+}
+
+// histogramIndex returns the index of the bucket that records d.
+func histogramIndex(d time.Duration) int {
+	v := uint64(d / histogramUnit)
+	exp := bits.Len64(v) - histogramSubBucketBits
+	if exp <= 0 {
+		return int(v)
+	}
+	return exp*histogramSubBucketHalf + int(v>>uint(exp))
+}
+
+// histogramUpper returns the largest duration recorded in a bucket.
+func histogramUpper(index int) time.Duration {
+	exp := index/histogramSubBucketHalf - 1
+	if exp < 0 {
+		exp = 0
+	}
+	sub := int64(index - exp*histogramSubBucketHalf)
+	return time.Duration((sub+1)<<uint(exp))*histogramUnit - 1
+}
+
+// Observe adds a duration to the histogram.
+func (h *ReportHistogram) Observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	if h.Buckets == nil {
+		h.Buckets = make(map[int]int64)
+	}
+
+	if len(h.Buckets) == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	h.Buckets[histogramIndex(d)]++
+	h.Total += d
+}
+
+// Merge adds the durations of another histogram to the histogram.
+func (h *ReportHistogram) Merge(o ReportHistogram) {
+	if len(o.Buckets) == 0 {
+		return
+	}
+	if h.Buckets == nil {
+		h.Buckets = make(map[int]int64)
+	}
+
+	if len(h.Buckets) == 0 || o.Min < h.Min {
+		h.Min = o.Min
+	}
+	if o.Max > h.Max {
+		h.Max = o.Max
+	}
+	for i, count := range o.Buckets {
+		h.Buckets[i] += count
+	}
+	h.Total += o.Total
+}
+
+// Copy returns a histogram that does not share buckets with h.
+func (h ReportHistogram) Copy() ReportHistogram {
+	if h.Buckets == nil {
+		return h
+	}
+
+	buckets := make(map[int]int64, len(h.Buckets))
+	for i, count := range h.Buckets {
+		buckets[i] = count
+	}
+	h.Buckets = buckets
+	return h
+}
+
+// Count returns the number of durations observed.
+func (h ReportHistogram) Count() int64 {
+	var count int64
+	for _, c := range h.Buckets {
+		count += c
+	}
+	return count
+}
+
+// Mean returns the mean of the durations observed.
+func (h ReportHistogram) Mean() time.Duration {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(count)
+}
+
+// Distribution returns the non-empty buckets of the histogram in increasing
+// order. The upper bound of the last bucket is the largest duration observed.
+func (h ReportHistogram) Distribution() []HistogramBucket {
+	indices := make([]int, 0, len(h.Buckets))
+	for i, count := range h.Buckets {
+		if count > 0 {
+			indices = append(indices, i)
+		}
+	}
+	sort.Ints(indices)
+
+	distribution := make([]HistogramBucket, len(indices))
+	for j, i := range indices {
+		upper := histogramUpper(i)
+		if upper > h.Max {
+			upper = h.Max
+		}
+		distribution[j] = HistogramBucket{Upper: upper, Count: h.Buckets[i]}
+	}
+	return distribution
+}
+
+// Percentile returns an upper bound for the p-th percentile of the durations
+// observed, where p is between 0 and 100.
+func (h ReportHistogram) Percentile(p float64) time.Duration {
+	distribution := h.Distribution()
+	var count int64
+	for _, bucket := range distribution {
+		count += bucket.Count
+	}
+	if count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for _, bucket := range distribution {
+		seen += bucket.Count
+		if seen >= rank {
+			if bucket.Upper < h.Min {
+				return h.Min
+			}
+			return bucket.Upper
+		}
+	}
+	return h.Max
+}
+
 type ReportBandwidth struct {
 	Totals metrics.Stats
 
@@ -0,0 +1,75 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportHistogramPercentile(t *testing.T) {
+	var h ReportHistogram
+	require.Equal(t, time.Duration(0), h.Percentile(50))
+
+	for i := 0; i < 90; i++ {
+		h.Observe(3 * time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		h.Observe(100 * time.Millisecond)
+	}
+
+	require.Equal(t, int64(100), h.Count())
+	require.InEpsilon(t, float64(3*time.Millisecond), float64(h.Percentile(50)), 0.01)
+	require.InEpsilon(t, float64(3*time.Millisecond), float64(h.Percentile(90)), 0.01)
+	require.Equal(t, 100*time.Millisecond, h.Percentile(99))
+	require.Equal(t, 100*time.Millisecond, h.Percentile(99.9))
+}
+
+func TestReportHistogramPrecision(t *testing.T) {
+	for _, d := range []time.Duration{
+		257 * time.Microsecond,
+		3 * time.Millisecond,
+		1234 * time.Millisecond,
+		time.Hour,
+	} {
+		var h ReportHistogram
+		h.Observe(d)
+		h.Observe(2 * d)
+
+		require.Equal(t, d, h.Min)
+		p := h.Percentile(50)
+		require.True(t, p >= d, "%s is below %s", p, d)
+		require.InEpsilon(t, float64(d), float64(p), 0.01)
+	}
+}
+
+func TestReportHistogramMerge(t *testing.T) {
+	var a, b ReportHistogram
+	a.Observe(time.Millisecond)
+	b.Observe(time.Second)
+
+	a.Merge(b)
+	require.Equal(t, int64(2), a.Count())
+	require.Equal(t, time.Millisecond, a.Min)
+	require.Equal(t, time.Second, a.Max)
+	require.InEpsilon(t, float64(time.Millisecond), float64(a.Percentile(50)), 0.01)
+
+	c := a.Copy()
+	c.Observe(time.Minute)
+	require.Equal(t, int64(2), a.Count())
+	require.Equal(t, int64(3), c.Count())
+}
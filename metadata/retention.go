@@ -0,0 +1,120 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import "time"
+
+// ReportDownsampling records that the raw samples of a report were
+// downsampled by labd's retention policy.
+type ReportDownsampling struct {
+	// Time is when the report was downsampled.
+	Time time.Time
+
+	// Resolution is the interval the series of the report were downsampled
+	// to.
+	Resolution time.Duration
+}
+
+// DownsampleReport returns a report whose series have at most one sample per
+// resolution, and without the raw bitswap messages of its nodes. The summary,
+// aggregates, node metrics and statistics are kept so that trends across
+// benchmarks can still be compared.
+func DownsampleReport(report Report, resolution time.Duration, now time.Time) Report {
+	if report.Series != nil {
+		series := make(map[string][]ReportSample, len(report.Series))
+		for id, samples := range report.Series {
+			series[id] = downsampleSamples(samples, resolution)
+		}
+		report.Series = series
+	}
+
+	if report.Nodes != nil {
+		nodes := make(map[string]ReportNode, len(report.Nodes))
+		for id, node := range report.Nodes {
+			node.Host.Series = downsampleHostSamples(node.Host.Series, resolution)
+			node.BitswapTrace.Events = nil
+			nodes[id] = node
+		}
+		report.Nodes = nodes
+	}
+
+	report.Snapshots = downsampleSnapshots(report.Snapshots, resolution)
+	report.Downsampled = &ReportDownsampling{
+		Time:       now,
+		Resolution: resolution,
+	}
+	return report
+}
+
+// downsampleSamples keeps the last sample of each resolution, as the counters
+// of a sample accumulate.
+func downsampleSamples(samples []ReportSample, resolution time.Duration) []ReportSample {
+	var downsampled []ReportSample
+	for i, sample := range samples {
+		if i+1 < len(samples) && samples[i+1].Elapsed/resolution == sample.Elapsed/resolution {
+			continue
+		}
+		downsampled = append(downsampled, sample)
+	}
+	return downsampled
+}
+
+// downsampleSnapshots keeps the last snapshot of each resolution, as the
+// metrics of a snapshot accumulate.
+func downsampleSnapshots(snapshots []ReportSnapshot, resolution time.Duration) []ReportSnapshot {
+	var downsampled []ReportSnapshot
+	for i, snapshot := range snapshots {
+		if i+1 < len(snapshots) && snapshots[i+1].Elapsed/resolution == snapshot.Elapsed/resolution {
+			continue
+		}
+		downsampled = append(downsampled, snapshot)
+	}
+	return downsampled
+}
+
+// downsampleHostSamples averages the utilization of the samples in each
+// resolution, as each sample is the utilization over its own interval.
+func downsampleHostSamples(samples []ReportHostSample, resolution time.Duration) []ReportHostSample {
+	var (
+		downsampled []ReportHostSample
+		sum         ReportHostSample
+		n           int
+	)
+	for i, sample := range samples {
+		sum.CPU += sample.CPU
+		sum.Memory += sample.Memory
+		sum.DiskRead += sample.DiskRead
+		sum.DiskWrite += sample.DiskWrite
+		sum.NetworkReceive += sample.NetworkReceive
+		sum.NetworkTransmit += sample.NetworkTransmit
+		n++
+
+		if i+1 < len(samples) && samples[i+1].Elapsed/resolution == sample.Elapsed/resolution {
+			continue
+		}
+
+		sum.Elapsed = sample.Elapsed
+		sum.CPU /= float64(n)
+		sum.Memory /= uint64(n)
+		sum.DiskRead /= float64(n)
+		sum.DiskWrite /= float64(n)
+		sum.NetworkReceive /= float64(n)
+		sum.NetworkTransmit /= float64(n)
+		downsampled = append(downsampled, sum)
+
+		sum, n = ReportHostSample{}, 0
+	}
+	return downsampled
+}
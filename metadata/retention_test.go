@@ -0,0 +1,80 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownsampleReport(t *testing.T) {
+	now := time.Unix(1000, 0)
+	report := Report{
+		Series: map[string][]ReportSample{
+			"a": {
+				{Elapsed: time.Second, Connections: ReportConnections{Conns: 1}},
+				{Elapsed: 2 * time.Second, Connections: ReportConnections{Conns: 2}},
+				{Elapsed: 11 * time.Second, Connections: ReportConnections{Conns: 3}},
+				{Elapsed: 25 * time.Second, Connections: ReportConnections{Conns: 4}},
+			},
+		},
+		Nodes: map[string]ReportNode{
+			"a": {
+				Host: ReportHost{
+					Series: []ReportHostSample{
+						{Elapsed: time.Second, HostUsage: HostUsage{CPU: 0.2, Memory: 1024}},
+						{Elapsed: 2 * time.Second, HostUsage: HostUsage{CPU: 0.4, Memory: 3072}},
+						{Elapsed: 12 * time.Second, HostUsage: HostUsage{CPU: 1, Memory: 4096}},
+					},
+					Peak: HostUsage{CPU: 1},
+				},
+				BitswapTrace: ReportBitswapTrace{Events: []byte("events"), Dropped: 2},
+			},
+		},
+		Snapshots: []ReportSnapshot{
+			{Elapsed: 5 * time.Second, Iterations: 1},
+			{Elapsed: 8 * time.Second, Iterations: 2},
+		},
+	}
+
+	downsampled := DownsampleReport(report, 10*time.Second, now)
+
+	series := downsampled.Series["a"]
+	require.Len(t, series, 3)
+	require.Equal(t, 2*time.Second, series[0].Elapsed)
+	require.Equal(t, int64(2), series[0].Connections.Conns)
+	require.Equal(t, 25*time.Second, series[2].Elapsed)
+
+	node := downsampled.Nodes["a"]
+	require.Len(t, node.Host.Series, 2)
+	require.Equal(t, 2*time.Second, node.Host.Series[0].Elapsed)
+	require.InDelta(t, 0.3, node.Host.Series[0].CPU, 1e-9)
+	require.Equal(t, uint64(2048), node.Host.Series[0].Memory)
+	require.Equal(t, 1.0, node.Host.Series[1].CPU)
+	require.Equal(t, 1.0, node.Host.Peak.CPU)
+	require.Nil(t, node.BitswapTrace.Events)
+	require.Equal(t, int64(2), node.BitswapTrace.Dropped)
+
+	require.Len(t, downsampled.Snapshots, 1)
+	require.Equal(t, 2, downsampled.Snapshots[0].Iterations)
+
+	require.Equal(t, &ReportDownsampling{Time: now, Resolution: 10 * time.Second}, downsampled.Downsampled)
+
+	// The original report is left untouched.
+	require.Len(t, report.Series["a"], 4)
+	require.NotNil(t, report.Nodes["a"].BitswapTrace.Events)
+}
@@ -16,10 +16,14 @@ package metadata
 
 import (
 	"context"
+	"encoding/json"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/Netflix/p2plab/errdefs"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/pkg/errors"
 	bolt "go.etcd.io/bbolt"
 )
@@ -29,6 +33,10 @@ type Scenario struct {
 
 	Definition ScenarioDefinition
 
+	// Baseline is the ID of the benchmark that later benchmarks of the
+	// scenario are compared against, if one is pinned.
+	Baseline string
+
 	Labels []string
 
 	CreatedAt, UpdatedAt time.Time
@@ -38,6 +46,12 @@ type Scenario struct {
 type ScenarioDefinition struct {
 	Objects map[string]ObjectDefinition `json:"objects,omitempty"`
 
+	// Selections name queries that the scenario's other queries reference as
+	// "@<name>", such as "@leechers". Selections are resolved once per
+	// benchmark, so every stage and trial selects the same nodes, and may not
+	// reference other selections.
+	Selections map[string]string `json:"selections,omitempty"`
+
 	// Seed map a query to an action. Queries are executed in parallel to seed
 	// a cluster with initial data before running the benchmark.
 	Seed map[string]string `json:"seed,omitempty"`
@@ -45,6 +59,633 @@ type ScenarioDefinition struct {
 	// Benchmark maps a query to an action. Queries are executed in parallel
 	// during the benchmark and metrics are collected during this stage.
 	Benchmark map[string]string `json:"benchmark,omitempty"`
+
+	// Stages are named phases of the scenario that execute in the order of
+	// their dependencies. Seed and Benchmark are shorthand for a "seed" stage
+	// and a "benchmark" stage that depends on it, and are ignored when stages
+	// are defined.
+	Stages []StageDefinition `json:"stages,omitempty"`
+
+	// Network maps a query to impairments applied to the traffic of the
+	// matched nodes. Impairments are applied after seeding and removed once
+	// the benchmark completes.
+	Network map[string]NetworkImpairment `json:"network,omitempty"`
+
+	// Traffic maps a query to background traffic sent by the matched nodes
+	// for the duration of the benchmarking session, so that the benchmark
+	// runs on a contended rather than an idle network.
+	Traffic map[string]TrafficDefinition `json:"traffic,omitempty"`
+
+	// Topology connects the nodes in a shape when the benchmarking session
+	// starts. If nil, every node is connected to every other node.
+	Topology *TopologyDefinition `json:"topology,omitempty"`
+
+	// Peers maps a query to a peer definition that overrides the peer
+	// definition of the matched nodes for the benchmark, so that nodes can
+	// run different builds or configurations, such as a patched bitswap on
+	// half of the cluster. Only the fields that are set are overridden.
+	Peers map[string]PeerDefinition `json:"peers,omitempty"`
+
+	// Soak loops the measured stages for a wall-clock duration instead of
+	// running them once, collecting interim report snapshots to catch memory
+	// growth and throughput degradation over time.
+	Soak *SoakDefinition `json:"soak,omitempty"`
+
+	// SampleInterval is how often the bitswap, bandwidth and connection
+	// counters of each node are sampled while the measured stages run, such
+	// as "5s", so that throughput can be charted over time. If empty, nodes
+	// are not sampled.
+	SampleInterval string `json:"sampleInterval,omitempty"`
+
+	// Profiles are the kinds of profiles captured from the labapp of each
+	// node while the measured stages run, "cpu" or "heap", and attached to
+	// the report.
+	Profiles []ProfileKind `json:"profiles,omitempty"`
+
+	// Trials is the number of times the scenario runs in a benchmark, which
+	// defaults to once.
+	Trials int `json:"trials,omitempty"`
+
+	// RandomSeed seeds the random choices made when planning the scenario,
+	// such as which nodes churn, so that benchmarks are repeatable. Each trial
+	// derives its own seed from it, except sample queries, which choose the
+	// same nodes in every trial. If zero, a seed is generated and recorded in
+	// the report.
+	RandomSeed int64 `json:"randomSeed,omitempty"`
+
+	// QueryVersion is the version of the query language that the scenario's
+	// queries are written in. Operators introduced after that version are
+	// rejected. If zero, the latest version is used.
+	QueryVersion int `json:"queryVersion,omitempty"`
+
+	// Expectations are conditions on the report of the form
+	// "<metric> <op> <value>", such as "retrievalTime.p95 < 10s", that are
+	// evaluated after the benchmark. The benchmark fails if any are not met.
+	Expectations []string `json:"expectations,omitempty"`
+}
+
+// SoakDefinition defines how long a scenario soaks.
+type SoakDefinition struct {
+	// Duration is how long the measured stages loop for, such as "24h". The
+	// iteration in progress when it elapses runs to completion.
+	Duration string `json:"duration"`
+
+	// Interval is how often an interim report snapshot is collected, which
+	// defaults to 10m.
+	Interval string `json:"interval,omitempty"`
+}
+
+// ParseSampleInterval parses a scenario's sample interval, which is zero if
+// the interval is empty.
+func ParseSampleInterval(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	interval, err := time.ParseDuration(s)
+	if err != nil || interval <= 0 {
+		return 0, errors.Wrapf(errdefs.ErrInvalidArgument, "sample interval %q must be a positive duration", s)
+	}
+	return interval, nil
+}
+
+// DefaultSoakInterval is how often interim report snapshots are collected if
+// no interval is defined.
+var DefaultSoakInterval = 10 * time.Minute
+
+// Plan returns the soak with its durations parsed.
+func (s SoakDefinition) Plan() (SoakPlan, error) {
+	var plan SoakPlan
+	duration, err := time.ParseDuration(s.Duration)
+	if err != nil || duration <= 0 {
+		return plan, errors.Wrapf(errdefs.ErrInvalidArgument, "soak duration %q must be a positive duration", s.Duration)
+	}
+	plan.Duration = duration
+
+	plan.Interval = DefaultSoakInterval
+	if s.Interval != "" {
+		interval, err := time.ParseDuration(s.Interval)
+		if err != nil || interval <= 0 {
+			return plan, errors.Wrapf(errdefs.ErrInvalidArgument, "soak interval %q must be a positive duration", s.Interval)
+		}
+		plan.Interval = interval
+	}
+
+	return plan, nil
+}
+
+// NetworkImpairment degrades the egress traffic of a node with netem.
+type NetworkImpairment struct {
+	// Latency is the delay added to each packet, such as "50ms". Since it is
+	// applied to egress, the round trip time between two impaired nodes
+	// increases by twice the latency.
+	Latency string `json:"latency,omitempty"`
+
+	// Jitter is the random variation of the latency, such as "10ms".
+	Jitter string `json:"jitter,omitempty"`
+
+	// Loss is the percentage of packets dropped.
+	Loss float64 `json:"loss,omitempty"`
+
+	// Bandwidth caps the rate of traffic in tc units, such as "10mbit".
+	Bandwidth string `json:"bandwidth,omitempty"`
+
+	// Peers is a query selecting the nodes whose traffic is impaired. If
+	// empty, traffic to all destinations is impaired.
+	Peers string `json:"peers,omitempty"`
+}
+
+// TrafficDefinition defines background traffic streamed from a node to its
+// peers. The traffic is discarded by the peers, and is reported in the
+// bandwidth of the "/p2plab/traffic/1.0.0" protocol.
+type TrafficDefinition struct {
+	// Peers is a query selecting the nodes traffic is sent to.
+	Peers string `json:"peers"`
+
+	// Streams is the number of streams opened to each peer, which defaults
+	// to one.
+	Streams int `json:"streams,omitempty"`
+
+	// Rate caps the bytes sent per second on each stream, such as "1MB". If
+	// empty, streams send as fast as the network allows.
+	Rate string `json:"rate,omitempty"`
+}
+
+// Validate returns an error if the traffic definition is malformed.
+func (t TrafficDefinition) Validate() error {
+	if t.Peers == "" {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "peers must be provided")
+	}
+
+	if t.Streams < 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "streams must not be negative")
+	}
+
+	_, err := t.BytesPerSecond()
+	return err
+}
+
+// BytesPerSecond returns the rate of each stream, or zero if unlimited.
+func (t TrafficDefinition) BytesPerSecond() (float64, error) {
+	if t.Rate == "" {
+		return 0, nil
+	}
+
+	rate, err := humanize.ParseBytes(t.Rate)
+	if err != nil {
+		return 0, errors.Wrapf(errdefs.ErrInvalidArgument, "invalid rate %q", t.Rate)
+	}
+	return float64(rate), nil
+}
+
+// TopologyShape is the shape of the connections between nodes.
+type TopologyShape string
+
+var (
+	// TopologyRing connects each node to the next, and the last to the first.
+	TopologyRing TopologyShape = "ring"
+
+	// TopologyStar connects the center nodes to every other node.
+	TopologyStar TopologyShape = "star"
+
+	// TopologyRegular connects each node to Degree other nodes at random.
+	TopologyRegular TopologyShape = "k-regular"
+
+	// TopologyCustom connects nodes by an adjacency list of queries.
+	TopologyCustom TopologyShape = "custom"
+)
+
+// TopologyDefinition defines how nodes are connected in a benchmarking
+// session, so that experiments on the shape of the network are reproducible.
+type TopologyDefinition struct {
+	Shape TopologyShape `json:"shape"`
+
+	// Nodes is a query selecting the nodes in a ring, star or k-regular
+	// topology. If empty, all nodes are selected.
+	Nodes string `json:"nodes,omitempty"`
+
+	// Center is a query selecting the center nodes of a star topology.
+	Center string `json:"center,omitempty"`
+
+	// Degree is the number of peers of each node in a k-regular topology.
+	Degree int `json:"degree,omitempty"`
+
+	// Adjacency maps a query to a query selecting the nodes that the matched
+	// nodes are connected to in a custom topology.
+	Adjacency map[string]string `json:"adjacency,omitempty"`
+}
+
+// Validate returns an error if the topology definition is malformed.
+func (t TopologyDefinition) Validate() error {
+	switch t.Shape {
+	case TopologyRing:
+	case TopologyStar:
+		if t.Center == "" {
+			return errors.Wrap(errdefs.ErrInvalidArgument, "star topology requires a center")
+		}
+	case TopologyRegular:
+		if t.Degree <= 0 {
+			return errors.Wrap(errdefs.ErrInvalidArgument, "k-regular topology requires a positive degree")
+		}
+	case TopologyCustom:
+		if len(t.Adjacency) == 0 {
+			return errors.Wrap(errdefs.ErrInvalidArgument, "custom topology requires an adjacency list")
+		}
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized topology shape %q", t.Shape)
+	}
+	return nil
+}
+
+// Validate returns an error if the impairment is malformed.
+func (i NetworkImpairment) Validate() error {
+	for _, d := range []string{i.Latency, i.Jitter} {
+		if d == "" {
+			continue
+		}
+
+		_, err := time.ParseDuration(d)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "invalid duration %q", d)
+		}
+	}
+
+	if i.Jitter != "" && i.Latency == "" {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "jitter requires a latency")
+	}
+
+	if i.Loss < 0 || i.Loss > 100 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "loss must be a percentage between 0 and 100")
+	}
+
+	return nil
+}
+
+// StageDefinition defines a named phase of a scenario.
+type StageDefinition struct {
+	Name string `json:"name"`
+
+	// DependsOn names the stages that must complete before this stage starts.
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Actions maps a query to an action. Queries are executed in parallel.
+	Actions map[string]string `json:"actions"`
+
+	// Seed marks a stage that distributes initial data from labd's seeding
+	// peer. Seed stages run before the benchmark session and are not measured,
+	// so they may only depend on other seed stages.
+	Seed bool `json:"seed,omitempty"`
+
+	// Warmup marks a stage that runs in the benchmark session before the
+	// measured stages, and whose operations are excluded from the report. Warmup
+	// stages may only depend on seed and other warmup stages.
+	Warmup bool `json:"warmup,omitempty"`
+
+	// Timeout is how long the stage may run before it fails, such as "10m".
+	// If empty, the stage may run indefinitely.
+	Timeout string `json:"timeout,omitempty"`
+
+	// OnFailure is the FailurePolicy of the stage when it fails or times out.
+	OnFailure string `json:"onFailure,omitempty"`
+
+	// When are conditions on the metrics measured so far, of the same form as
+	// expectations, that must all hold for the stage to run. Stage times are
+	// of the stages that have completed.
+	When []string `json:"when,omitempty"`
+
+	// MaxConcurrency is the most nodes that run their task of the stage at
+	// once. If zero, every node starts its task at once.
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+
+	// RampUp grows the number of nodes running their task at once from one
+	// to MaxConcurrency, or every node if it is zero.
+	RampUp *RampUpDefinition `json:"rampUp,omitempty"`
+
+	// Otherwise is what happens when a condition does not hold, either
+	// "skip" to skip the stage, or "abort" to fail the benchmark. Stages
+	// that depend on a skipped stage still run. If empty, the stage is
+	// skipped.
+	Otherwise string `json:"otherwise,omitempty"`
+}
+
+// RampUpDefinition defines how the concurrency of a stage grows.
+type RampUpDefinition struct {
+	// Profile is how the concurrency grows, either "linear", "step" or
+	// "exponential".
+	Profile string `json:"profile"`
+
+	// Duration is how long the concurrency takes to reach its limit, such as
+	// "5m".
+	Duration string `json:"duration"`
+
+	// Steps is the number of equal steps the concurrency grows in for the
+	// "step" profile, which defaults to 4.
+	Steps int `json:"steps,omitempty"`
+}
+
+// DefaultRampUpSteps is the number of steps of a step ramp-up if none are
+// defined.
+var DefaultRampUpSteps = 4
+
+// Plan returns the ramp-up with its duration parsed.
+func (r RampUpDefinition) Plan() (RampUpPlan, error) {
+	plan := RampUpPlan{
+		Profile: RampUpProfile(r.Profile),
+		Steps:   r.Steps,
+	}
+	switch plan.Profile {
+	case RampUpLinear, RampUpExponential:
+	case RampUpStep:
+		if plan.Steps == 0 {
+			plan.Steps = DefaultRampUpSteps
+		}
+		if plan.Steps < 0 {
+			return plan, errors.Wrapf(errdefs.ErrInvalidArgument, "ramp-up steps %d must be positive", r.Steps)
+		}
+	default:
+		return plan, errors.Wrapf(errdefs.ErrInvalidArgument, "ramp-up profile %q must be %q, %q or %q", r.Profile, RampUpLinear, RampUpStep, RampUpExponential)
+	}
+
+	duration, err := time.ParseDuration(r.Duration)
+	if err != nil || duration <= 0 {
+		return plan, errors.Wrapf(errdefs.ErrInvalidArgument, "ramp-up duration %q must be a positive duration", r.Duration)
+	}
+	plan.Duration = duration
+
+	return plan, nil
+}
+
+var (
+	// OtherwiseSkip skips a stage whose conditions do not hold.
+	OtherwiseSkip = "skip"
+
+	// OtherwiseAbort fails the benchmark when a stage's conditions do not
+	// hold.
+	OtherwiseAbort = "abort"
+)
+
+// ValidateConditions returns an error if the stage's conditions or what
+// happens otherwise are invalid. Seed stages run before any metrics are
+// measured, so they may not have conditions.
+func (d StageDefinition) ValidateConditions() error {
+	if len(d.When) > 0 && d.Seed {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "seed stages may not have conditions")
+	}
+
+	for _, c := range d.When {
+		_, err := ParseExpectation(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch d.Otherwise {
+	case "", OtherwiseSkip, OtherwiseAbort:
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "otherwise %q must be %q or %q", d.Otherwise, OtherwiseSkip, OtherwiseAbort)
+	}
+
+	return nil
+}
+
+// FailurePolicy is what happens when a stage or a task fails.
+type FailurePolicy struct {
+	// Retries is the number of times a failure is retried.
+	Retries int
+
+	// Continue ignores a failure once it is out of retries, instead of
+	// aborting the benchmark.
+	Continue bool
+}
+
+// ParseFailurePolicy parses a failure policy of "abort", "continue" or
+// "retry <n>", which may also be written "retry:<n>" where spaces are not
+// allowed. An empty policy aborts.
+func ParseFailurePolicy(policy string) (FailurePolicy, error) {
+	fields := strings.FieldsFunc(policy, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ':'
+	})
+	if len(fields) == 0 {
+		return FailurePolicy{}, nil
+	}
+
+	switch {
+	case len(fields) == 1 && fields[0] == "abort":
+		return FailurePolicy{}, nil
+	case len(fields) == 1 && fields[0] == "continue":
+		return FailurePolicy{Continue: true}, nil
+	case len(fields) == 2 && fields[0] == "retry":
+		retries, err := strconv.Atoi(fields[1])
+		if err != nil || retries < 0 {
+			return FailurePolicy{}, errors.Wrapf(errdefs.ErrInvalidArgument, "retries %q must be a non-negative integer", fields[1])
+		}
+		return FailurePolicy{Retries: retries}, nil
+	default:
+		return FailurePolicy{}, errors.Wrapf(errdefs.ErrInvalidArgument, "failure policy %q must be \"abort\", \"continue\" or \"retry <n>\"", policy)
+	}
+}
+
+var (
+	// StageSeed is the name of the stage converted from Seed.
+	StageSeed = "seed"
+
+	// StageBenchmark is the name of the stage converted from Benchmark.
+	StageBenchmark = "benchmark"
+)
+
+// StageDefinitions returns the stages of the scenario, converting Seed and
+// Benchmark into stages if no stages are defined.
+func (d ScenarioDefinition) StageDefinitions() []StageDefinition {
+	if len(d.Stages) > 0 {
+		return d.Stages
+	}
+
+	var stages []StageDefinition
+	if len(d.Seed) > 0 {
+		stages = append(stages, StageDefinition{
+			Name:    StageSeed,
+			Actions: d.Seed,
+			Seed:    true,
+		})
+	}
+
+	if len(d.Benchmark) > 0 {
+		stage := StageDefinition{
+			Name:    StageBenchmark,
+			Actions: d.Benchmark,
+		}
+		if len(d.Seed) > 0 {
+			stage.DependsOn = []string{StageSeed}
+		}
+		stages = append(stages, stage)
+	}
+
+	return stages
+}
+
+// Validate returns an error if the stages of the scenario do not form a
+// valid dependency DAG.
+func (d ScenarioDefinition) Validate() error {
+	stages, err := SortStages(d.StageDefinitions())
+	if err != nil {
+		return err
+	}
+
+	for _, stage := range stages {
+		if stage.Timeout != "" {
+			_, err = time.ParseDuration(stage.Timeout)
+			if err != nil {
+				return errors.Wrapf(errdefs.ErrInvalidArgument, "stage %q has invalid timeout %q", stage.Name, stage.Timeout)
+			}
+		}
+
+		_, err = ParseFailurePolicy(stage.OnFailure)
+		if err != nil {
+			return errors.Wrapf(err, "stage %q", stage.Name)
+		}
+
+		err = stage.ValidateConditions()
+		if err != nil {
+			return errors.Wrapf(err, "stage %q", stage.Name)
+		}
+
+		if stage.MaxConcurrency < 0 {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "stage %q max concurrency must not be negative", stage.Name)
+		}
+
+		if stage.RampUp != nil {
+			_, err = stage.RampUp.Plan()
+			if err != nil {
+				return errors.Wrapf(err, "stage %q", stage.Name)
+			}
+		}
+	}
+
+	if d.Trials < 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "trials must not be negative")
+	}
+
+	if d.QueryVersion < 0 {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "query version must not be negative")
+	}
+
+	for q, impairment := range d.Network {
+		err = impairment.Validate()
+		if err != nil {
+			return errors.Wrapf(err, "network impairment for %q", q)
+		}
+	}
+
+	for q, traffic := range d.Traffic {
+		err = traffic.Validate()
+		if err != nil {
+			return errors.Wrapf(err, "traffic for %q", q)
+		}
+	}
+
+	if d.Topology != nil {
+		err = d.Topology.Validate()
+		if err != nil {
+			return errors.Wrap(err, "topology")
+		}
+	}
+
+	if d.Soak != nil {
+		_, err = d.Soak.Plan()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = ParseSampleInterval(d.SampleInterval)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range d.Expectations {
+		_, err = ParseExpectation(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SortStages returns the stages in an order where every stage comes after its
+// dependencies, or an error if a dependency is unknown or cyclic.
+func SortStages(stages []StageDefinition) ([]StageDefinition, error) {
+	stageByName := make(map[string]StageDefinition)
+	for _, stage := range stages {
+		if stage.Name == "" {
+			return nil, errors.Wrap(errdefs.ErrInvalidArgument, "stage must have a name")
+		}
+
+		_, ok := stageByName[stage.Name]
+		if ok {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "stage %q is defined more than once", stage.Name)
+		}
+		stageByName[stage.Name] = stage
+	}
+
+	for _, stage := range stages {
+		if stage.Seed && stage.Warmup {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "stage %q cannot be both a seed and warmup stage", stage.Name)
+		}
+
+		for _, dep := range stage.DependsOn {
+			dstage, ok := stageByName[dep]
+			if !ok {
+				return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "stage %q depends on unknown stage %q", stage.Name, dep)
+			}
+
+			if stage.Seed && !dstage.Seed {
+				return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "seed stage %q cannot depend on non-seed stage %q", stage.Name, dep)
+			}
+
+			if stage.Warmup && !dstage.Seed && !dstage.Warmup {
+				return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "warmup stage %q cannot depend on measured stage %q", stage.Name, dep)
+			}
+		}
+	}
+
+	var (
+		sorted   []StageDefinition
+		visited  = make(map[string]bool)
+		visiting = make(map[string]bool)
+		visit    func(stage StageDefinition) error
+	)
+	visit = func(stage StageDefinition) error {
+		if visited[stage.Name] {
+			return nil
+		}
+
+		if visiting[stage.Name] {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "stage %q has a cyclic dependency", stage.Name)
+		}
+		visiting[stage.Name] = true
+
+		for _, dep := range stage.DependsOn {
+			err := visit(stageByName[dep])
+			if err != nil {
+				return err
+			}
+		}
+
+		visiting[stage.Name] = false
+		visited[stage.Name] = true
+		sorted = append(sorted, stage)
+		return nil
+	}
+
+	for _, stage := range stages {
+		err := visit(stage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
 }
 
 // ObjectDefinition define a type of data that will be distributed during the
@@ -52,9 +693,13 @@ type ScenarioDefinition struct {
 // into IPFS datastructures.
 type ObjectDefinition struct {
 	// Type specifies what type is the source of the data and how the data is
-	// retrieved. Types must be one of the following: ["oci-image"].
+	// retrieved. Types must be one of the following: ["oci", "tree",
+	// "inline"].
 	Type string `json:"type"`
 
+	// Source is a reference to an image for "oci", the spec of a synthetic
+	// directory tree for "tree", such as "files=1000 size=4KiB-64KiB depth=2
+	// fanout=8", or the content of a file for "inline", such as a script.
 	Source string `json:"source"`
 
 	// Layout specify how the DAG is shaped and constructed over the IPLD blocks.
@@ -141,7 +786,12 @@ func (m *db) ListScenarios(ctx context.Context) ([]Scenario, error) {
 }
 
 func (m *db) CreateScenario(ctx context.Context, scenario Scenario) (Scenario, error) {
-	err := m.Update(ctx, func(tx *bolt.Tx) error {
+	err := scenario.Definition.Validate()
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	err = m.Update(ctx, func(tx *bolt.Tx) error {
 		bkt, err := createScenariosBucket(tx)
 		if err != nil {
 			return err
@@ -276,6 +926,8 @@ func readScenario(bkt *bolt.Bucket, scenario *Scenario) error {
 		switch string(k) {
 		case string(bucketKeyID):
 			scenario.ID = string(v)
+		case string(bucketKeyBaseline):
+			scenario.Baseline = string(v)
 		}
 
 		return nil
@@ -296,6 +948,11 @@ func readScenarioDefinition(bkt *bolt.Bucket) (ScenarioDefinition, error) {
 		return sdef, err
 	}
 
+	sdef.Selections, err = readMap(dbkt, bucketKeySelect)
+	if err != nil {
+		return sdef, err
+	}
+
 	sdef.Seed, err = readMap(dbkt, bucketKeySeed)
 	if err != nil {
 		return sdef, err
@@ -306,6 +963,99 @@ func readScenarioDefinition(bkt *bolt.Bucket) (ScenarioDefinition, error) {
 		return sdef, err
 	}
 
+	content := dbkt.Get(bucketKeyStages)
+	if content != nil {
+		err = json.Unmarshal(content, &sdef.Stages)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	trials := dbkt.Get(bucketKeyTrials)
+	if trials != nil {
+		sdef.Trials, err = strconv.Atoi(string(trials))
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	seed := dbkt.Get(bucketKeyRandSeed)
+	if seed != nil {
+		sdef.RandomSeed, err = strconv.ParseInt(string(seed), 10, 64)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	version := dbkt.Get(bucketKeyQueryVer)
+	if version != nil {
+		sdef.QueryVersion, err = strconv.Atoi(string(version))
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	content = dbkt.Get(bucketKeyNetwork)
+	if content != nil {
+		err = json.Unmarshal(content, &sdef.Network)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	content = dbkt.Get(bucketKeyTraffic)
+	if content != nil {
+		err = json.Unmarshal(content, &sdef.Traffic)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	content = dbkt.Get(bucketKeyTopology)
+	if content != nil {
+		err = json.Unmarshal(content, &sdef.Topology)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	content = dbkt.Get(bucketKeyPeers)
+	if content != nil {
+		err = json.Unmarshal(content, &sdef.Peers)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	content = dbkt.Get(bucketKeySoak)
+	if content != nil {
+		err = json.Unmarshal(content, &sdef.Soak)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	interval := dbkt.Get(bucketKeySampleInterval)
+	if interval != nil {
+		sdef.SampleInterval = string(interval)
+	}
+
+	content = dbkt.Get(bucketKeyExpects)
+	if content != nil {
+		err = json.Unmarshal(content, &sdef.Expectations)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
+	content = dbkt.Get(bucketKeyProfiles)
+	if content != nil {
+		err = json.Unmarshal(content, &sdef.Profiles)
+		if err != nil {
+			return sdef, err
+		}
+	}
+
 	return sdef, nil
 }
 
@@ -327,6 +1077,7 @@ func writeScenario(bkt *bolt.Bucket, scenario *Scenario) error {
 
 	for _, f := range []field{
 		{bucketKeyID, []byte(scenario.ID)},
+		{bucketKeyBaseline, []byte(scenario.Baseline)},
 	} {
 		err = bkt.Put(f.key, f.value)
 		if err != nil {
@@ -356,6 +1107,11 @@ func writeScenarioDefinition(bkt *bolt.Bucket, sdef ScenarioDefinition) error {
 		return err
 	}
 
+	err = writeMap(dbkt, bucketKeySelect, sdef.Selections)
+	if err != nil {
+		return err
+	}
+
 	err = writeMap(dbkt, bucketKeySeed, sdef.Seed)
 	if err != nil {
 		return err
@@ -366,6 +1122,114 @@ func writeScenarioDefinition(bkt *bolt.Bucket, sdef ScenarioDefinition) error {
 		return err
 	}
 
+	if len(sdef.Stages) > 0 {
+		content, err := json.Marshal(sdef.Stages)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeyStages, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range []field{
+		{bucketKeyTrials, []byte(strconv.Itoa(sdef.Trials))},
+		{bucketKeyRandSeed, []byte(strconv.FormatInt(sdef.RandomSeed, 10))},
+		{bucketKeyQueryVer, []byte(strconv.Itoa(sdef.QueryVersion))},
+		{bucketKeySampleInterval, []byte(sdef.SampleInterval)},
+	} {
+		err = dbkt.Put(f.key, f.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(sdef.Network) > 0 {
+		content, err := json.Marshal(sdef.Network)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeyNetwork, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(sdef.Traffic) > 0 {
+		content, err := json.Marshal(sdef.Traffic)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeyTraffic, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if sdef.Topology != nil {
+		content, err := json.Marshal(sdef.Topology)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeyTopology, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(sdef.Peers) > 0 {
+		content, err := json.Marshal(sdef.Peers)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeyPeers, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if sdef.Soak != nil {
+		content, err := json.Marshal(sdef.Soak)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeySoak, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(sdef.Expectations) > 0 {
+		content, err := json.Marshal(sdef.Expectations)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeyExpects, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(sdef.Profiles) > 0 {
+		content, err := json.Marshal(sdef.Profiles)
+		if err != nil {
+			return err
+		}
+
+		err = dbkt.Put(bucketKeyProfiles, content)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
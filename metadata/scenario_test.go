@@ -0,0 +1,83 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/stretchr/testify/require"
+)
+
+func stageNames(stages []StageDefinition) []string {
+	var names []string
+	for _, stage := range stages {
+		names = append(names, stage.Name)
+	}
+	return names
+}
+
+func TestSortStages(t *testing.T) {
+	sorted, err := SortStages([]StageDefinition{
+		{Name: "fetch", DependsOn: []string{"warm", "seed"}},
+		{Name: "warm", DependsOn: []string{"seed"}, Warmup: true},
+		{Name: "seed", Seed: true},
+		{Name: "churn"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"seed", "warm", "fetch", "churn"}, stageNames(sorted))
+
+	// Stages without dependencies keep their order.
+	sorted, err = SortStages([]StageDefinition{{Name: "b"}, {Name: "a"}, {Name: "c"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "a", "c"}, stageNames(sorted))
+}
+
+func TestSortStagesCycle(t *testing.T) {
+	for _, stages := range [][]StageDefinition{
+		{
+			{Name: "a", DependsOn: []string{"a"}},
+		},
+		{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"a"}},
+		},
+		{
+			{Name: "root"},
+			{Name: "a", DependsOn: []string{"root", "c"}},
+			{Name: "b", DependsOn: []string{"a"}},
+			{Name: "c", DependsOn: []string{"b"}},
+		},
+	} {
+		_, err := SortStages(stages)
+		require.Error(t, err, stageNames(stages))
+		require.True(t, errdefs.IsInvalidArgument(err))
+		require.Contains(t, err.Error(), "cyclic")
+	}
+}
+
+func TestSortStagesInvalid(t *testing.T) {
+	for _, stages := range [][]StageDefinition{
+		{{Name: ""}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", DependsOn: []string{"missing"}}},
+		{{Name: "a", Seed: true, Warmup: true}},
+		{{Name: "a"}, {Name: "b", Seed: true, DependsOn: []string{"a"}}},
+		{{Name: "a"}, {Name: "b", Warmup: true, DependsOn: []string{"a"}}},
+	} {
+		_, err := SortStages(stages)
+		require.True(t, errdefs.IsInvalidArgument(err), stageNames(stages))
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/pkg/cron"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultScheduleBaseline is the number of previous benchmarks of a schedule
+// that a benchmark is compared against, unless otherwise specified.
+const DefaultScheduleBaseline = 5
+
+// Schedule runs benchmarks of a scenario on a cluster periodically, and
+// alerts when a benchmark regresses from those before it.
+type Schedule struct {
+	ID string
+
+	Definition ScheduleDefinition
+
+	// Benchmarks are the IDs of the benchmarks run by the schedule, in the
+	// order they ran.
+	Benchmarks []string
+
+	// LastRun is when the schedule last started a benchmark.
+	LastRun time.Time
+
+	CreatedAt, UpdatedAt time.Time
+}
+
+// ScheduleDefinition defines a schedule.
+type ScheduleDefinition struct {
+	Cluster  string `json:"cluster"`
+	Scenario string `json:"scenario"`
+
+	// Cron is when benchmarks run, as a cron expression such as "0 3 * * *".
+	Cron string `json:"cron"`
+
+	// Baseline is the number of previous benchmarks that each benchmark is
+	// compared against. Defaults to DefaultScheduleBaseline.
+	Baseline int `json:"baseline,omitempty"`
+
+	// Significance is the p-value below which a change is significant.
+	// Defaults to DefaultSignificance.
+	Significance float64 `json:"significance,omitempty"`
+
+	// Alerts are the metrics whose regressions are alerted.
+	Alerts []ScheduleAlert `json:"alerts"`
+
+	// Webhook is a URL that alerts are posted to as JSON.
+	Webhook string `json:"webhook,omitempty"`
+
+	// Slack is the URL of a Slack incoming webhook that alerts are posted to
+	// as messages.
+	Slack string `json:"slack,omitempty"`
+}
+
+// ScheduleAlert alerts when a metric gets worse than the baseline by more
+// than a threshold.
+type ScheduleAlert struct {
+	// Metric is the name of a metric as used by expectations, such as
+	// "retrievalTime.p95" or "stage.<name>".
+	Metric string `json:"metric"`
+
+	// Threshold is the relative change beyond which the metric regressed,
+	// such as 0.1 for 10%.
+	Threshold float64 `json:"threshold"`
+}
+
+// Validate returns an error if the schedule definition is malformed.
+func (d ScheduleDefinition) Validate() error {
+	if d.Cluster == "" || d.Scenario == "" {
+		return errors.Wrap(errdefs.ErrInvalidArgument, "schedule must have a cluster and scenario")
+	}
+
+	_, err := cron.Parse(d.Cron)
+	if err != nil {
+		return err
+	}
+
+	if d.Baseline < 0 {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "schedule baseline %d must not be negative", d.Baseline)
+	}
+	if d.Significance < 0 || d.Significance >= 1 {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "schedule significance %g must be between 0 and 1", d.Significance)
+	}
+
+	for _, alert := range d.Alerts {
+		if _, ok := lookupMetric(alert.Metric); !ok {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "schedule alert has unrecognized metric %q", alert.Metric)
+		}
+		if alert.Threshold < 0 {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "schedule alert on %q has negative threshold", alert.Metric)
+		}
+	}
+
+	return nil
+}
+
+// Next returns when the schedule next runs after its last run, or after it
+// was created if it has not run yet.
+func (s Schedule) Next() time.Time {
+	c, err := cron.Parse(s.Definition.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+
+	last := s.LastRun
+	if last.IsZero() {
+		last = s.CreatedAt
+	}
+	return c.Next(last.Local())
+}
+
+// Check returns the comparison of the alert's metric and whether it
+// regressed beyond the threshold. Changes must also be significant, unless
+// the comparison has too few trials to test them.
+func (a ScheduleAlert) Check(comparison ReportComparison) (MetricComparison, bool) {
+	for _, mc := range comparison.Metrics {
+		if mc.Metric != a.Metric {
+			continue
+		}
+		if neutralMetrics[mc.Metric] {
+			return mc, false
+		}
+
+		change := mc.Change
+		if higherIsBetter[mc.Metric] {
+			change = -change
+		}
+		if change <= a.Threshold {
+			return mc, false
+		}
+		return mc, mc.Significant || !mc.Tested
+	}
+	return MetricComparison{Metric: a.Metric}, false
+}
+
+func (m *db) GetSchedule(ctx context.Context, id string) (Schedule, error) {
+	var schedule Schedule
+
+	err := m.View(ctx, func(tx *bolt.Tx) error {
+		bkt := getScheduleBucket(tx, id)
+		if bkt == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "schedule %q", id)
+		}
+
+		schedule.ID = id
+		return readSchedule(bkt, &schedule)
+	})
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	return schedule, nil
+}
+
+func (m *db) ListSchedules(ctx context.Context) ([]Schedule, error) {
+	var schedules []Schedule
+	err := m.View(ctx, func(tx *bolt.Tx) error {
+		bkt := getSchedulesBucket(tx)
+		if bkt == nil {
+			return nil
+		}
+
+		return bkt.ForEach(func(k, v []byte) error {
+			schedule := Schedule{
+				ID: string(k),
+			}
+
+			err := readSchedule(bkt.Bucket(k), &schedule)
+			if err != nil {
+				return err
+			}
+
+			schedules = append(schedules, schedule)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (m *db) CreateSchedule(ctx context.Context, schedule Schedule) (Schedule, error) {
+	err := m.Update(ctx, func(tx *bolt.Tx) error {
+		bkt, err := createSchedulesBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		sbkt, err := bkt.CreateBucket([]byte(schedule.ID))
+		if err != nil {
+			if err != bolt.ErrBucketExists {
+				return err
+			}
+
+			return errors.Wrapf(errdefs.ErrAlreadyExists, "schedule %q", schedule.ID)
+		}
+
+		schedule.CreatedAt = time.Now().UTC()
+		schedule.UpdatedAt = schedule.CreatedAt
+		return writeSchedule(sbkt, &schedule)
+	})
+	if err != nil {
+		return Schedule{}, err
+	}
+	return schedule, err
+}
+
+func (m *db) UpdateSchedule(ctx context.Context, schedule Schedule) (Schedule, error) {
+	if schedule.ID == "" {
+		return Schedule{}, errors.Wrapf(errdefs.ErrInvalidArgument, "schedule id required for update")
+	}
+
+	err := m.Update(ctx, func(tx *bolt.Tx) error {
+		bkt, err := createSchedulesBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		sbkt := bkt.Bucket([]byte(schedule.ID))
+		if sbkt == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "schedule %q", schedule.ID)
+		}
+
+		schedule.UpdatedAt = time.Now().UTC()
+		return writeSchedule(sbkt, &schedule)
+	})
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	return schedule, nil
+}
+
+func (m *db) DeleteSchedules(ctx context.Context, ids ...string) error {
+	return m.Update(ctx, func(tx *bolt.Tx) error {
+		bkt := getSchedulesBucket(tx)
+		if bkt == nil {
+			return nil
+		}
+
+		for _, id := range ids {
+			err := bkt.DeleteBucket([]byte(id))
+			if err != nil {
+				if err == bolt.ErrBucketNotFound {
+					return errors.Wrapf(errdefs.ErrNotFound, "schedule %q", id)
+				}
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func readSchedule(bkt *bolt.Bucket, schedule *Schedule) error {
+	err := ReadTimestamps(bkt, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	content := bkt.Get(bucketKeyDefinition)
+	if content != nil {
+		err = json.Unmarshal(content, &schedule.Definition)
+		if err != nil {
+			return err
+		}
+	}
+
+	benchmarks := bkt.Get(bucketKeyBenchmarks)
+	if len(benchmarks) > 0 {
+		schedule.Benchmarks = strings.Split(string(benchmarks), ",")
+	}
+
+	lastRun := bkt.Get(bucketKeyLastRun)
+	if lastRun != nil {
+		err = schedule.LastRun.UnmarshalBinary(lastRun)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeSchedule(bkt *bolt.Bucket, schedule *Schedule) error {
+	err := WriteTimestamps(bkt, schedule.CreatedAt, schedule.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(schedule.Definition)
+	if err != nil {
+		return err
+	}
+
+	lastRun, err := schedule.LastRun.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []field{
+		{bucketKeyDefinition, content},
+		{bucketKeyBenchmarks, []byte(strings.Join(schedule.Benchmarks, ","))},
+		{bucketKeyLastRun, lastRun},
+	} {
+		err = bkt.Put(f.key, f.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
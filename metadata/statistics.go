@@ -0,0 +1,117 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Netflix/p2plab/pkg/stats"
+)
+
+// DefaultConfidence is the confidence level of the intervals of metrics
+// across the trials of a report.
+const DefaultConfidence = 0.95
+
+// ReportStatistics are the distributions of metrics across the trials of a
+// report.
+type ReportStatistics struct {
+	// Confidence is the confidence level of the intervals.
+	Confidence float64
+
+	// Metrics are ordered by name, excluding those that are zero in every
+	// trial.
+	Metrics []MetricStatistics
+}
+
+// MetricStatistics is the distribution of a metric across trials. Values are
+// formatted like expectation values.
+type MetricStatistics struct {
+	Metric string
+	Mean   string
+
+	// Interval is the half-width of the confidence interval of the mean.
+	Interval string
+
+	// RelativeInterval is the interval relative to the mean, or zero if the
+	// mean is zero.
+	RelativeInterval float64
+
+	// Outliers are the trials, counting from one, whose values are outliers
+	// among the trials.
+	Outliers []int `json:",omitempty"`
+}
+
+// String returns the mean and its interval, such as "1.2s ± 0.1s".
+func (m MetricStatistics) String() string {
+	return fmt.Sprintf("%s ± %s", m.Mean, m.Interval)
+}
+
+// TrialStatistics returns the mean of every expectation metric and stage time
+// across the trials of a report, with its confidence interval and the trials
+// that are outliers. A report that ran a single trial has no statistics.
+func TrialStatistics(report Report, confidence float64) ReportStatistics {
+	statistics := ReportStatistics{Confidence: confidence}
+	if len(report.Trials) < 2 {
+		return statistics
+	}
+
+	names := make(map[string]struct{})
+	for name := range expectationMetrics {
+		names[name] = struct{}{}
+	}
+	for _, trial := range report.Trials {
+		for stage := range trial.Summary.Stages {
+			names["stage."+stage] = struct{}{}
+		}
+		for custom := range trial.Aggregates.Totals.Custom {
+			names["custom."+custom] = struct{}{}
+		}
+	}
+
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	trials := trialReports(report)
+	for _, name := range sorted {
+		m, _ := lookupMetric(name)
+		samples := sampleMetric(m, trials)
+		mean := stats.Mean(samples)
+		if mean == 0 && stats.Variance(samples) == 0 {
+			continue
+		}
+
+		e := Expectation{Metric: name, kind: m.kind}
+		interval := stats.ConfidenceInterval(samples, confidence)
+		ms := MetricStatistics{
+			Metric:   name,
+			Mean:     e.format(mean),
+			Interval: e.format(interval),
+		}
+		if mean != 0 {
+			ms.RelativeInterval = interval / mean
+		}
+		for _, i := range stats.Outliers(samples) {
+			ms.Outliers = append(ms.Outliers, i+1)
+		}
+
+		statistics.Metrics = append(statistics.Metrics, ms)
+	}
+
+	return statistics
+}
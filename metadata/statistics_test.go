@@ -0,0 +1,42 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrialStatistics(t *testing.T) {
+	var report Report
+	for _, d := range []time.Duration{10, 11, 10, 12, 11, 40} {
+		report.Trials = append(report.Trials, ReportTrial{Summary: ReportSummary{TotalTime: d * time.Second}})
+	}
+
+	statistics := TrialStatistics(report, DefaultConfidence)
+	require.Equal(t, DefaultConfidence, statistics.Confidence)
+	require.Len(t, statistics.Metrics, 1)
+
+	m := statistics.Metrics[0]
+	require.Equal(t, "totalTime", m.Metric)
+	require.Equal(t, "15.666666666s", m.Mean)
+	require.Equal(t, []int{6}, m.Outliers)
+	require.True(t, m.RelativeInterval > 0.5)
+
+	report.Trials = report.Trials[:1]
+	require.Empty(t, TrialStatistics(report, DefaultConfidence).Metrics)
+}
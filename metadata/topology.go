@@ -0,0 +1,101 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import "sort"
+
+// ReportTopology is the connection graph of the nodes when the measured
+// stages started and ended.
+type ReportTopology struct {
+	Start ReportTopologySnapshot `json:",omitempty"`
+
+	End ReportTopologySnapshot `json:",omitempty"`
+}
+
+// ReportTopologySnapshot maps a node ID to the connections its peer had open.
+type ReportTopologySnapshot map[string][]ReportPeerConn
+
+// ReportPeerConn is a connection from a node's peer to a remote peer.
+type ReportPeerConn struct {
+	// Peer is the ID of the remote peer.
+	Peer string
+
+	// Node is the ID of the remote peer's node, if it is one of the nodes.
+	Node string `json:",omitempty"`
+
+	// Addr is the multiaddr of the remote peer.
+	Addr string
+
+	// Transport is the transport of the connection, such as tcp or quic.
+	Transport string
+
+	// Direction is either inbound or outbound.
+	Direction string
+
+	// Protocols are the protocols the remote peer supports.
+	Protocols []string `json:",omitempty"`
+}
+
+// TopologyEdge is a pair of nodes with at least one connection between them,
+// ordered so that A < B.
+type TopologyEdge struct {
+	A string
+	B string
+
+	// Transports are the transports of the connections between the nodes.
+	Transports []string
+}
+
+// Edges returns the pairs of nodes that are connected, in order. Connections
+// to peers outside of the nodes are left out, and connections reported by
+// both nodes of a pair are counted once.
+func (s ReportTopologySnapshot) Edges() []TopologyEdge {
+	transports := make(map[[2]string]map[string]struct{})
+	for id, conns := range s {
+		for _, conn := range conns {
+			if conn.Node == "" || conn.Node == id {
+				continue
+			}
+
+			key := [2]string{id, conn.Node}
+			if key[1] < key[0] {
+				key[0], key[1] = key[1], key[0]
+			}
+			if transports[key] == nil {
+				transports[key] = make(map[string]struct{})
+			}
+			if conn.Transport != "" {
+				transports[key][conn.Transport] = struct{}{}
+			}
+		}
+	}
+
+	edges := make([]TopologyEdge, 0, len(transports))
+	for key, set := range transports {
+		edge := TopologyEdge{A: key[0], B: key[1]}
+		for transport := range set {
+			edge.Transports = append(edge.Transports, transport)
+		}
+		sort.Strings(edge.Transports)
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].A != edges[j].A {
+			return edges[i].A < edges[j].A
+		}
+		return edges[i].B < edges[j].B
+	})
+	return edges
+}
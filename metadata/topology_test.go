@@ -0,0 +1,41 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopologyEdges(t *testing.T) {
+	snapshot := ReportTopologySnapshot{
+		"a": {
+			{Peer: "Qmb", Node: "b", Transport: "tcp"},
+			{Peer: "Qmc", Node: "c", Transport: "quic"},
+			{Peer: "Qmx", Transport: "tcp"},
+		},
+		"b": {
+			{Peer: "Qma", Node: "a", Transport: "tcp"},
+			{Peer: "Qma", Node: "a", Transport: "quic"},
+		},
+		"c": nil,
+	}
+
+	require.Equal(t, []TopologyEdge{
+		{A: "a", B: "b", Transports: []string{"quic", "tcp"}},
+		{A: "a", B: "c", Transports: []string{"quic"}},
+	}, snapshot.Edges())
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Trace is the sequence of tasks executed by a benchmark, recorded so that it
+// can be replayed on another cluster or commit.
+type Trace struct {
+	// Seed is the random seed the traced trial was planned with.
+	Seed int64
+
+	// Nodes are the IDs of the nodes of the traced cluster in sorted order.
+	// Nodes are mapped by their position when a trace is replayed.
+	Nodes []string
+
+	// Events are the executed tasks in the order they started.
+	Events []TraceEvent
+}
+
+// TraceEvent is a task executed by a node during a benchmark.
+type TraceEvent struct {
+	// Node is the ID of the node that executed the task.
+	Node string
+
+	// Stage is the name of the stage the task belongs to.
+	Stage string
+
+	Task Task
+
+	// Offset is when the task started relative to the start of the
+	// benchmark.
+	Offset time.Duration
+
+	// Duration is how long the task took, including any retries.
+	Duration time.Duration
+
+	// Action is the ID carried by the requests of the task, by which the
+	// nodes key the samples they record while it runs.
+	Action string `json:",omitempty"`
+}
+
+func (m *db) GetTrace(ctx context.Context, id string) (Trace, error) {
+	var trace Trace
+
+	err := m.View(ctx, func(tx *bolt.Tx) error {
+		bkt := getBenchmarksBucket(tx)
+		if bkt == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "benchmark %q", id)
+		}
+
+		bbkt := bkt.Bucket([]byte(id))
+		if bbkt == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "benchmark %q", id)
+		}
+
+		content := bbkt.Get(bucketKeyTrace)
+		if content == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "no trace available")
+		}
+
+		return json.Unmarshal(content, &trace)
+	})
+	if err != nil {
+		return trace, err
+	}
+
+	return trace, nil
+}
+
+func (m *db) CreateTrace(ctx context.Context, id string, trace Trace) error {
+	return m.Update(ctx, func(tx *bolt.Tx) error {
+		bkt, err := createBenchmarksBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		bbkt := bkt.Bucket([]byte(id))
+		if bbkt == nil {
+			return errors.Wrapf(errdefs.ErrNotFound, "benchmark %q", id)
+		}
+
+		content, err := json.Marshal(&trace)
+		if err != nil {
+			return err
+		}
+
+		return bbkt.Put(bucketKeyTrace, content)
+	})
+}
@@ -16,6 +16,7 @@ package p2plab
 
 import (
 	"context"
+	"time"
 
 	"github.com/Netflix/p2plab/metadata"
 )
@@ -25,9 +26,38 @@ type NodeAPI interface {
 	// Get returns a node.
 	Get(ctx context.Context, cluster, id string) (Node, error)
 
-	Label(ctx context.Context, cluster string, ids, adds, removes []string) ([]Node, error)
+	// Label adds/removes labels to/from nodes, both the nodes with the given
+	// ids and the nodes matching the list options' query.
+	Label(ctx context.Context, cluster string, ids, adds, removes []string, opts ...ListOption) ([]Node, error)
 
 	List(ctx context.Context, cluster string, opts ...ListOption) ([]Node, error)
+
+	// Explain parses a query and returns its syntax tree with the nodes of the
+	// cluster that each of its subqueries matches.
+	Explain(ctx context.Context, cluster, q string, opts ...ExplainOption) (metadata.QueryExplanation, error)
+
+	// Logs calls fn with the lines logged by the labagents and labapps of a
+	// cluster's nodes, with the node that logged each line.
+	Logs(ctx context.Context, cluster string, fn func(metadata.LogLine) error, opts ...LogsOption) error
+}
+
+// ExplainOption is an option to modify explain settings.
+type ExplainOption func(*ExplainSettings) error
+
+// ExplainSettings specify how a query is explained.
+type ExplainSettings struct {
+	// Scenario is the ID of a scenario whose selections and random seed the
+	// query is explained with.
+	Scenario string
+}
+
+// WithExplainScenario explains a query in the context of a scenario, so that
+// it may reference the scenario's selections.
+func WithExplainScenario(scenario string) ExplainOption {
+	return func(s *ExplainSettings) error {
+		s.Scenario = scenario
+		return nil
+	}
 }
 
 // Node is an instance running the P2P application to be benchmarked.
@@ -46,8 +76,28 @@ type NodeProvider interface {
 	// CreateNodeGroup returns a healthy cluster of nodes.
 	CreateNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*NodeGroup, error)
 
+	// ReplaceNodes provisions a replacement for each failed node in a cluster
+	// and releases the failed nodes. The replacements may be fewer than the
+	// failed nodes if the provider runs out of capacity.
+	ReplaceNodes(ctx context.Context, ng *NodeGroup, cdef metadata.ClusterDefinition, failed []metadata.Node) ([]metadata.Node, error)
+
 	// DestroyNodeGroup destroys a cluster of nodes.
 	DestroyNodeGroup(ctx context.Context, ng *NodeGroup) error
+
+	// StopNodeGroup shuts down a cluster of nodes while retaining their
+	// volumes so that they can be started again later.
+	StopNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) error
+
+	// StartNodeGroup starts a cluster of nodes previously stopped.
+	StartNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) error
+
+	// ListNodeGroups returns the IDs of node groups that currently have
+	// infrastructure provisioned by the provider.
+	ListNodeGroups(ctx context.Context) ([]string, error)
+
+	// PlanNodeGroup returns the resources that would be created for a cluster
+	// of nodes without provisioning anything.
+	PlanNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*NodeGroupPlan, error)
 }
 
 // NodeGroup is a cluster of nodes.
@@ -56,9 +106,72 @@ type NodeGroup struct {
 	Nodes []metadata.Node
 }
 
+// NodeGroupPlan describes what a provider would create for a cluster of
+// nodes.
+type NodeGroupPlan struct {
+	ID         string
+	Resources  []string
+	HourlyCost float64
+}
+
 // SSHOption is an option to modify SSH settings.
-type SSHOption func(SSHSettings) error
+type SSHOption func(*SSHSettings) error
 
 // SSHSetttings specify ssh settings when connecting to a node.
 type SSHSettings struct {
+	// LocalPort and RemotePort forward a local port to a port on the node
+	// instead of opening an interactive shell, when RemotePort is set.
+	LocalPort  int
+	RemotePort int
+}
+
+// WithPortForward forwards a local port to a port on the node until the
+// connection is closed.
+func WithPortForward(local, remote int) SSHOption {
+	return func(s *SSHSettings) error {
+		s.LocalPort = local
+		s.RemotePort = remote
+		return nil
+	}
+}
+
+// LogsOption is an option to modify logs settings.
+type LogsOption func(*LogsSettings) error
+
+// LogsSettings specify which lines logged by nodes are read.
+type LogsSettings struct {
+	// Query selects the nodes whose lines are read. All nodes of the cluster
+	// are selected if it is empty.
+	Query string
+
+	// Since skips the lines logged before a time in the nodes' clock.
+	Since time.Time
+
+	// Follow keeps reading lines as they are logged.
+	Follow bool
+}
+
+// WithLogsQuery reads the lines of the nodes matching a query.
+func WithLogsQuery(q string) LogsOption {
+	return func(s *LogsSettings) error {
+		s.Query = q
+		return nil
+	}
+}
+
+// WithLogsSince reads the lines logged after a time.
+func WithLogsSince(since time.Time) LogsOption {
+	return func(s *LogsSettings) error {
+		s.Since = since
+		return nil
+	}
+}
+
+// WithFollow keeps reading lines as they are logged until the context is
+// cancelled.
+func WithFollow() LogsOption {
+	return func(s *LogsSettings) error {
+		s.Follow = true
+		return nil
+	}
 }
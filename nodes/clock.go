@@ -0,0 +1,62 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	"github.com/rs/zerolog"
+)
+
+// MeasureClocks estimates the clock offset of each node by its ID. Nodes whose
+// clocks cannot be measured are left out with a warning.
+func MeasureClocks(ctx context.Context, ns []p2plab.Node) map[string]metadata.ClockOffset {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.MeasureClocks")
+	defer span.Finish()
+	span.SetTag("nodes", len(ns))
+
+	zerolog.Ctx(ctx).Info().Msg("Measuring clock offsets")
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	offsets := make(map[string]metadata.ClockOffset)
+	for _, n := range ns {
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			offset, err := n.ClockOffset(ctx)
+			if err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Str("node", n.ID()).Msg("Failed to measure clock offset")
+				return
+			}
+			zerolog.Ctx(ctx).Debug().Str("node", n.ID()).Dur("offset", offset.Offset).Dur("rtt", offset.RoundTrip).Msg("Measured clock offset")
+
+			mu.Lock()
+			offsets[n.ID()] = offset
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return offsets
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/logutil"
 	"github.com/Netflix/p2plab/pkg/traceutil"
@@ -36,38 +37,7 @@ func Connect(ctx context.Context, ns []p2plab.Node) error {
 	defer span.Finish()
 	span.SetTag("nodes", len(ns))
 
-	collectPeerAddrs, gctx := errgroup.WithContext(ctx)
-
-	zerolog.Ctx(ctx).Info().Msg("Retrieving peer infos")
-	go logutil.Elapsed(gctx, 20*time.Second, "Retrieving peer infos")
-
-	var lk sync.Mutex
-	peerInfoByNodeID := make(map[string]peer.AddrInfo)
-	for _, n := range ns {
-		n := n
-		collectPeerAddrs.Go(func() error {
-			peerInfo, err := n.PeerInfo(gctx)
-			if err != nil {
-				return err
-			}
-
-			if len(peerInfo.Addrs) == 0 {
-				return errors.Errorf("peer %q has zero addresses", n.Metadata().Address)
-			}
-
-			lk.Lock()
-			peerInfoByNodeID[n.ID()] = peerInfo
-			lk.Unlock()
-
-			for _, ma := range peerInfo.Addrs {
-				zerolog.Ctx(gctx).Debug().Str("addr", ma.String()).Msg("Retrieved peer address")
-			}
-
-			return nil
-		})
-	}
-
-	err := collectPeerAddrs.Wait()
+	peerInfoByNodeID, err := collectPeerInfos(ctx, ns)
 	if err != nil {
 		return err
 	}
@@ -90,10 +60,7 @@ func Connect(ctx context.Context, ns []p2plab.Node) error {
 				continue
 			}
 
-			var peerAddrs []string
-			for _, ma := range pi.Addrs {
-				peerAddrs = append(peerAddrs, fmt.Sprintf("%s/p2p/%s", ma, pi.ID))
-			}
+			peerAddrs := p2pAddrs(pi)
 			if len(peerAddrs) > 0 {
 				conns[npi.ID][pi.ID] = peerAddrs
 			}
@@ -134,3 +101,91 @@ func Connect(ctx context.Context, ns []p2plab.Node) error {
 
 	return connectPeers.Wait()
 }
+
+// ConnectTopology connects nodes along the edges of a topology, where dials
+// maps the ID of a node to the IDs of the nodes it dials.
+func ConnectTopology(ctx context.Context, ns []p2plab.Node, dials map[string][]string) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.ConnectTopology")
+	defer span.Finish()
+	span.SetTag("nodes", len(ns))
+
+	peerInfoByNodeID, err := collectPeerInfos(ctx, ns)
+	if err != nil {
+		return err
+	}
+
+	connectPeers, gctx := errgroup.WithContext(ctx)
+
+	zerolog.Ctx(ctx).Info().Msg("Connecting cluster topology")
+	go logutil.Elapsed(gctx, 20*time.Second, "Connecting cluster topology")
+
+	for _, n := range ns {
+		for _, id := range dials[n.ID()] {
+			pi, ok := peerInfoByNodeID[id]
+			if !ok {
+				return errors.Wrapf(errdefs.ErrNotFound, "node %q in topology", id)
+			}
+
+			n := n
+			peerAddrs := p2pAddrs(pi)
+			connectPeers.Go(func() error {
+				return n.Run(gctx, metadata.Task{
+					Type:    metadata.TaskConnectOne,
+					Subject: strings.Join(peerAddrs, ","),
+				})
+			})
+		}
+	}
+
+	return connectPeers.Wait()
+}
+
+// collectPeerInfos retrieves the peer info of each node by its ID.
+func collectPeerInfos(ctx context.Context, ns []p2plab.Node) (map[string]peer.AddrInfo, error) {
+	collectPeerAddrs, gctx := errgroup.WithContext(ctx)
+
+	zerolog.Ctx(ctx).Info().Msg("Retrieving peer infos")
+	go logutil.Elapsed(gctx, 20*time.Second, "Retrieving peer infos")
+
+	var lk sync.Mutex
+	peerInfoByNodeID := make(map[string]peer.AddrInfo)
+	for _, n := range ns {
+		n := n
+		collectPeerAddrs.Go(func() error {
+			peerInfo, err := n.PeerInfo(gctx)
+			if err != nil {
+				return err
+			}
+
+			if len(peerInfo.Addrs) == 0 {
+				return errors.Errorf("peer %q has zero addresses", n.Metadata().Address)
+			}
+
+			lk.Lock()
+			peerInfoByNodeID[n.ID()] = peerInfo
+			lk.Unlock()
+
+			for _, ma := range peerInfo.Addrs {
+				zerolog.Ctx(gctx).Debug().Str("addr", ma.String()).Msg("Retrieved peer address")
+			}
+
+			return nil
+		})
+	}
+
+	err := collectPeerAddrs.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	return peerInfoByNodeID, nil
+}
+
+// p2pAddrs returns the addresses of a peer with its peer ID.
+func p2pAddrs(pi peer.AddrInfo) []string {
+	var addrs []string
+	for _, ma := range pi.Addrs {
+		addrs = append(addrs, fmt.Sprintf("%s/p2p/%s", ma, pi.ID))
+	}
+	return addrs
+}
@@ -16,6 +16,7 @@ package nodes
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/Netflix/p2plab"
@@ -55,3 +56,42 @@ func WaitHealthy(ctx context.Context, ns []p2plab.Node) error {
 	return nil
 
 }
+
+// FilterHealthy waits for nodes to become healthy and partitions them into
+// healthy and unhealthy nodes, rather than failing on the first unhealthy one.
+func FilterHealthy(ctx context.Context, ns []p2plab.Node) (healthy, unhealthy []p2plab.Node) {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.FilterHealthy")
+	defer span.Finish()
+	span.SetTag("nodes", len(ns))
+
+	ectx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	zerolog.Ctx(ctx).Info().Msg("Waiting for healthy nodes")
+	go logutil.Elapsed(ectx, 20*time.Second, "Waiting for healthy nodes")
+
+	var (
+		wg sync.WaitGroup
+		lk sync.Mutex
+	)
+	for _, n := range ns {
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok := n.Healthcheck(ctx)
+
+			lk.Lock()
+			defer lk.Unlock()
+			if ok {
+				healthy = append(healthy, n)
+			} else {
+				zerolog.Ctx(ctx).Warn().Str("node", n.ID()).Msg("Node is unhealthy")
+				unhealthy = append(unhealthy, n)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return healthy, unhealthy
+}
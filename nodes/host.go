@@ -0,0 +1,63 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	"github.com/rs/zerolog"
+)
+
+// CollectHosts returns the resource utilization of each node's host between
+// start and end by its ID, using the clock offsets of the nodes to align
+// their samples with the local clock. Nodes whose hosts cannot be collected
+// are left out with a warning.
+func CollectHosts(ctx context.Context, ns []p2plab.Node, offsets map[string]metadata.ClockOffset, start, end time.Time) map[string]metadata.ReportHost {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.CollectHosts")
+	defer span.Finish()
+	span.SetTag("nodes", len(ns))
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	hosts := make(map[string]metadata.ReportHost)
+	for _, n := range ns {
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			offset := offsets[n.ID()].Offset
+			samples, err := n.HostSamples(ctx, start.Add(offset))
+			if err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Str("node", n.ID()).Msg("Failed to collect host resources")
+				return
+			}
+
+			mu.Lock()
+			hosts[n.ID()] = metadata.NewReportHost(samples, start, end, offset)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return hosts
+}
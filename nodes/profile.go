@@ -0,0 +1,90 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	"github.com/rs/zerolog"
+)
+
+// StartProfiles starts capturing profiles of the labapp of each node. Nodes
+// whose profiles cannot be started are left out with a warning.
+func StartProfiles(ctx context.Context, ns []p2plab.Node, kinds []metadata.ProfileKind) {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.StartProfiles")
+	defer span.Finish()
+	span.SetTag("nodes", len(ns))
+
+	var wg sync.WaitGroup
+	for _, n := range ns {
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for _, kind := range kinds {
+				err := n.StartProfile(ctx, kind)
+				if err != nil {
+					zerolog.Ctx(ctx).Warn().Err(err).Str("node", n.ID()).Str("profile", string(kind)).Msg("Failed to start profile")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// CollectProfiles stops capturing profiles of the labapp of each node, and
+// returns them by the node's ID. Profiles that cannot be collected are left
+// out with a warning.
+func CollectProfiles(ctx context.Context, ns []p2plab.Node, kinds []metadata.ProfileKind) map[string]map[metadata.ProfileKind][]byte {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.CollectProfiles")
+	defer span.Finish()
+	span.SetTag("nodes", len(ns))
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	profiles := make(map[string]map[metadata.ProfileKind][]byte)
+	for _, n := range ns {
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for _, kind := range kinds {
+				profile, err := n.StopProfile(ctx, kind)
+				if err != nil {
+					zerolog.Ctx(ctx).Warn().Err(err).Str("node", n.ID()).Str("profile", string(kind)).Msg("Failed to collect profile")
+					continue
+				}
+
+				mu.Lock()
+				if profiles[n.ID()] == nil {
+					profiles[n.ID()] = make(map[metadata.ProfileKind][]byte)
+				}
+				profiles[n.ID()][kind] = profile
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return profiles
+}
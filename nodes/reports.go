@@ -62,3 +62,21 @@ func CollectReports(ctx context.Context, ns []p2plab.Node) (map[string]metadata.
 
 	return reportByNodeID, nil
 }
+
+// ResetReports excludes the operations so far from the reports of the nodes.
+func ResetReports(ctx context.Context, ns []p2plab.Node) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.ResetReports")
+	defer span.Finish()
+	span.SetTag("nodes", len(ns))
+
+	zerolog.Ctx(ctx).Info().Msg("Resetting reports")
+	eg, gctx := errgroup.WithContext(ctx)
+	for _, n := range ns {
+		n := n
+		eg.Go(func() error {
+			return n.Run(gctx, metadata.Task{Type: metadata.TaskResetReport})
+		})
+	}
+
+	return eg.Wait()
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	"github.com/rs/zerolog"
+)
+
+// CollectTopology returns the connections each node's peer has open by its
+// ID, resolving remote peers to the nodes they belong to. Nodes whose
+// connections cannot be collected are left out with a warning.
+func CollectTopology(ctx context.Context, ns []p2plab.Node) metadata.ReportTopologySnapshot {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.CollectTopology")
+	defer span.Finish()
+	span.SetTag("nodes", len(ns))
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	snapshot := make(metadata.ReportTopologySnapshot)
+	nodeIdByPeerId := make(map[string]string)
+	for _, n := range ns {
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			peerInfo, err := n.PeerInfo(ctx)
+			if err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Str("node", n.ID()).Msg("Failed to get peer info")
+				return
+			}
+
+			conns, err := n.Conns(ctx)
+			if err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Str("node", n.ID()).Msg("Failed to collect connections")
+				return
+			}
+
+			mu.Lock()
+			nodeIdByPeerId[peerInfo.ID.Pretty()] = n.ID()
+			snapshot[n.ID()] = conns
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, conns := range snapshot {
+		for i, conn := range conns {
+			conns[i].Node = nodeIdByPeerId[conn.Peer]
+		}
+	}
+
+	return snapshot
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/pkg/logutil"
 	"github.com/Netflix/p2plab/pkg/traceutil"
 	"github.com/rs/zerolog"
@@ -28,11 +29,26 @@ import (
 )
 
 func Update(ctx context.Context, builder p2plab.Builder, ns []p2plab.Node) error {
+	return UpdatePeers(ctx, builder, ns, nil)
+}
+
+// UpdatePeers updates nodes to the peer definitions in pdefs by node ID, and
+// nodes without one to their own peer definition.
+func UpdatePeers(ctx context.Context, builder p2plab.Builder, ns []p2plab.Node, pdefs map[string]metadata.PeerDefinition) error {
 	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.Update")
 	defer span.Finish()
 	span.SetTag("nodes", len(ns))
 
-	commitByRef, err := ResolveUniqueCommits(ctx, builder, ns)
+	pdefByID := make(map[string]metadata.PeerDefinition)
+	for _, n := range ns {
+		pdef, ok := pdefs[n.ID()]
+		if !ok {
+			pdef = n.Metadata().Peer
+		}
+		pdefByID[n.ID()] = pdef
+	}
+
+	commitByRef, err := ResolveUniqueCommits(ctx, builder, pdefByID)
 	if err != nil {
 		return err
 	}
@@ -61,7 +77,7 @@ func Update(ctx context.Context, builder p2plab.Builder, ns []p2plab.Node) error
 	for _, n := range ns {
 		n := n
 		updatePeers.Go(func() error {
-			pdef := n.Metadata().Peer
+			pdef := pdefByID[n.ID()]
 			link := linkByCommit[commitByRef[pdef.GitReference]]
 			return n.Update(gctx, n.ID(), link, pdef)
 		})
@@ -75,10 +91,10 @@ func Update(ctx context.Context, builder p2plab.Builder, ns []p2plab.Node) error
 	return nil
 }
 
-func ResolveUniqueCommits(ctx context.Context, builder p2plab.Builder, ns []p2plab.Node) (commitByRef map[string]string, err error) {
+func ResolveUniqueCommits(ctx context.Context, builder p2plab.Builder, pdefByID map[string]metadata.PeerDefinition) (commitByRef map[string]string, err error) {
 	span, ctx := traceutil.StartSpanFromContext(ctx, "nodes.ResolveUniqueCommits")
 	defer span.Finish()
-	span.SetTag("nodes", len(ns))
+	span.SetTag("nodes", len(pdefByID))
 
 	resolving, gctx := errgroup.WithContext(ctx)
 
@@ -87,16 +103,16 @@ func ResolveUniqueCommits(ctx context.Context, builder p2plab.Builder, ns []p2pl
 
 	var mu sync.Mutex
 	commitByRef = make(map[string]string)
-	for _, n := range ns {
-		n := n
+	for _, pdef := range pdefByID {
+		pdef := pdef
 		resolving.Go(func() error {
-			commit, err := builder.Resolve(ctx, n.Metadata().Peer.GitReference)
+			commit, err := builder.Resolve(ctx, pdef.GitReference)
 			if err != nil {
 				return err
 			}
 
 			mu.Lock()
-			commitByRef[n.Metadata().Peer.GitReference] = commit
+			commitByRef[pdef.GitReference] = commit
 			mu.Unlock()
 			return nil
 		})
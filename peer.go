@@ -17,6 +17,7 @@ package p2plab
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/Netflix/p2plab/metadata"
 	cid "github.com/ipfs/go-cid"
@@ -49,11 +50,88 @@ type Peer interface {
 	// FetchGraph fetches the full DAG rooted at a given cid.
 	FetchGraph(ctx context.Context, c cid.Cid) error
 
+	// GetRange fetches part of the DAG rooted at a given cid: the DAG at a
+	// slash separated path of link names, or a byte range of the file there.
+	// A zero length reads to the end of the file.
+	GetRange(ctx context.Context, c cid.Cid, path string, offset, length int64) error
+
+	// Pin fetches the DAG rooted at a given cid down to a depth and pins it
+	// so that its blocks are not evicted by Load. A negative depth is
+	// unlimited.
+	Pin(ctx context.Context, c cid.Cid, pinType metadata.PinType, depth int) error
+
+	// Publish publishes count messages of a given size to a pubsub topic, one
+	// every interval.
+	Publish(ctx context.Context, topic string, count, size int, interval time.Duration) error
+
+	// Subscribe subscribes to a pubsub topic until count messages are received
+	// from each of a number of publishers or the timeout elapses, recording
+	// their latency and any messages lost.
+	Subscribe(ctx context.Context, topic string, count, publishers int, timeout time.Duration) error
+
+	// Provide announces to the DHT that the peer can provide a given cid.
+	Provide(ctx context.Context, c cid.Cid) error
+
+	// FindProviders queries the DHT for up to count providers of a given cid.
+	FindProviders(ctx context.Context, c cid.Cid, count int, timeout time.Duration) ([]peer.AddrInfo, error)
+
+	// FindPeer queries the DHT for the addresses of a given peer.
+	FindPeer(ctx context.Context, id peer.ID, timeout time.Duration) (peer.AddrInfo, error)
+
+	// Load retrieves the DAG rooted at a given cid at a sustained rate per
+	// second for a duration, optionally evicting it after each retrieval.
+	// Retrievals that cannot be issued while too many are in flight are
+	// counted as missed.
+	Load(ctx context.Context, c cid.Cid, rate float64, duration time.Duration, evict bool) error
+
+	// Traffic sends background traffic on a number of streams to each peer,
+	// capped at a rate of bytes per second on each stream if positive, until
+	// the context is cancelled.
+	Traffic(ctx context.Context, infos []peer.AddrInfo, streams int, rate float64) error
+
+	// GatewayGet retrieves the content at a path within a given cid through
+	// the HTTP gateway of another peer, requesting every file and directory
+	// listing within it.
+	GatewayGet(ctx context.Context, gateway string, c cid.Cid, path string) error
+
+	// Mutate changes the file at a slash separated path of link names within
+	// the DAG rooted at a given cid, by appending or replacing it with size
+	// bytes of random data, then provides the new root and announces it on a
+	// pubsub topic.
+	Mutate(ctx context.Context, c cid.Cid, path string, mode metadata.MutationMode, size int64, topic string, opts ...AddOption) error
+
+	// Follow retrieves the roots announced on a pubsub topic until count
+	// updates have been retrieved or the timeout elapses, recording how long
+	// each took to propagate.
+	Follow(ctx context.Context, topic string, count int, timeout time.Duration) error
+
+	// Import adds count files of random data of a given size into the Peer's
+	// storage, recording how long each takes to be chunked and stored.
+	Import(ctx context.Context, count int, size int64, opts ...AddOption) error
+
 	// Report returns all the metrics collected from the peer.
 	Report(ctx context.Context) (metadata.ReportNode, error)
+
+	// ResetReport excludes the operations so far from the peer's report.
+	ResetReport(ctx context.Context) error
+
+	// Restart restarts the peer with a peer definition, keeping its identity
+	// and metrics, and optionally clearing its blocks for a cold cache.
+	Restart(ctx context.Context, pdef metadata.PeerDefinition, clear bool) error
 }
 
 // AddOption is an option for AddSettings.
+// MetricsCollector collects metrics of a peer that reports do not cover, such
+// as the counters of another protocol or of a custom datastore, so that they
+// are reported alongside the peer's standard metrics.
+type MetricsCollector interface {
+	// Name prefixes the names of the collector's metrics in reports.
+	Name() string
+
+	// Collect returns the current value of each of the collector's metrics.
+	Collect(ctx context.Context) ([]metadata.CustomMetric, error)
+}
+
 type AddOption func(*AddSettings) error
 
 // AddSettings describe the settings for adding content to the peer.
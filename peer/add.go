@@ -0,0 +1,81 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// addStats accumulates the import metrics for a peer's report.
+type addStats struct {
+	mu     sync.Mutex
+	report metadata.ReportAdd
+}
+
+func (p *Peer) Import(ctx context.Context, count int, size int64, opts ...p2plab.AddOption) error {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; i < count; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		// Generate the data up front so that only chunking and storing it is
+		// measured.
+		data := make([]byte, size)
+		rng.Read(data)
+
+		start := time.Now()
+		_, err := p.Add(ctx, bytes.NewReader(data), opts...)
+		p.recordAdd(start, size, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordAdd adds a file of a given size that started importing at start to
+// the report.
+func (p *Peer) recordAdd(start time.Time, size int64, err error) {
+	p.addStats.mu.Lock()
+	defer p.addStats.mu.Unlock()
+
+	p.addStats.report.Files++
+	if err != nil {
+		p.addStats.report.Failures++
+		return
+	}
+	p.addStats.report.Bytes += size
+	p.addStats.report.Time.Observe(time.Since(start))
+}
+
+// addReport returns a snapshot of the import metrics.
+func (p *Peer) addReport() metadata.ReportAdd {
+	p.addStats.mu.Lock()
+	defer p.addStats.mu.Unlock()
+
+	report := p.addStats.report
+	report.Time = report.Time.Copy()
+	return report
+}
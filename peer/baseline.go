@@ -0,0 +1,132 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Netflix/p2plab/metadata"
+	metrics "github.com/libp2p/go-libp2p-core/metrics"
+)
+
+// baseline is a snapshot of the counters of a peer when its report was last
+// reset.
+type baseline struct {
+	mu     sync.Mutex
+	report metadata.ReportNode
+}
+
+// ResetReport starts collecting metrics afresh, so that operations before the
+// reset are excluded from the peer's report.
+func (p *Peer) ResetReport(ctx context.Context) error {
+	p.mu.RLock()
+	report, err := p.counters()
+	p.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	p.baseline.mu.Lock()
+	p.baseline.report = report
+	p.baseline.mu.Unlock()
+
+	p.retrievalStats.mu.Lock()
+	p.retrievalStats.report = metadata.ReportRetrieval{}
+	p.retrievalStats.mu.Unlock()
+
+	p.pubsubStats.mu.Lock()
+	p.pubsubStats.report = metadata.ReportPubsub{}
+	p.pubsubStats.mu.Unlock()
+
+	p.dhtStats.mu.Lock()
+	p.dhtStats.report = metadata.ReportDHT{}
+	p.dhtStats.mu.Unlock()
+
+	p.loadStats.mu.Lock()
+	p.loadStats.report = metadata.ReportLoad{}
+	p.loadStats.mu.Unlock()
+
+	p.addStats.mu.Lock()
+	p.addStats.report = metadata.ReportAdd{}
+	p.addStats.mu.Unlock()
+
+	p.gatewayStats.mu.Lock()
+	p.gatewayStats.report = metadata.ReportGateway{}
+	p.gatewayStats.mu.Unlock()
+
+	p.updateStats.mu.Lock()
+	p.updateStats.report = metadata.ReportUpdate{}
+	p.updateStats.mu.Unlock()
+
+	p.execStats.mu.Lock()
+	p.execStats.report = nil
+	p.execStats.mu.Unlock()
+
+	p.bitswapStats.reset()
+	p.resetCustom(ctx)
+
+	return nil
+}
+
+// subtract removes the baseline from the counters of a report.
+func (b *baseline) subtract(report *metadata.ReportNode) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bswap, base := &report.Bitswap, b.report.Bitswap
+	bswap.BlocksReceived -= base.BlocksReceived
+	bswap.DataReceived -= base.DataReceived
+	bswap.BlocksSent -= base.BlocksSent
+	bswap.DataSent -= base.DataSent
+	bswap.DupBlksReceived -= base.DupBlksReceived
+	bswap.DupDataReceived -= base.DupDataReceived
+	bswap.MessagesReceived -= base.MessagesReceived
+
+	bw := &report.Bandwidth
+	bw.Totals = subtractStats(bw.Totals, b.report.Bandwidth.Totals)
+	for id, stats := range bw.Peers {
+		bw.Peers[id] = subtractStats(stats, b.report.Bandwidth.Peers[id])
+	}
+	for proto, stats := range bw.Protocols {
+		bw.Protocols[proto] = subtractStats(stats, b.report.Bandwidth.Protocols[proto])
+	}
+}
+
+// carry folds bitswap counters into the baseline, so that they keep
+// accumulating once bitswap is restarted and counts from zero again. The
+// counters are unsigned, so the baseline may wrap around, and subtracting it
+// adds the carried counters back.
+func (b *baseline) carry(bswap metadata.ReportBitswap) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	base := &b.report.Bitswap
+	base.BlocksReceived -= bswap.BlocksReceived
+	base.DataReceived -= bswap.DataReceived
+	base.BlocksSent -= bswap.BlocksSent
+	base.DataSent -= bswap.DataSent
+	base.DupBlksReceived -= bswap.DupBlksReceived
+	base.DupDataReceived -= bswap.DupDataReceived
+	base.MessagesReceived -= bswap.MessagesReceived
+}
+
+// subtractStats subtracts the totals of bandwidth stats. Rates are
+// instantaneous, so they are kept as is.
+func subtractStats(stats, base metrics.Stats) metrics.Stats {
+	stats.TotalIn -= base.TotalIn
+	stats.TotalOut -= base.TotalOut
+	return stats
+}
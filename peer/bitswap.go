@@ -0,0 +1,192 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	pb "github.com/ipfs/go-bitswap/message/pb"
+	"github.com/ipfs/go-bitswap/network"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	"github.com/rs/zerolog/log"
+)
+
+// bitswapStats accumulates the bitswap messages of a peer for its report,
+// when the peer traces bitswap.
+type bitswapStats struct {
+	mu      sync.Mutex
+	events  []metadata.BitswapEvent
+	dropped int64
+
+	// actions are the IDs of the actions running on the peer in the order
+	// they started. Messages are keyed by the latest.
+	actions []string
+}
+
+// record adds an event for each entry of a bitswap message. Received blocks
+// are duplicates if they are already in the blockstore.
+func (s *bitswapStats) record(bs blockstore.Blockstore, sent bool, p libp2ppeer.ID, msg bsmsg.BitSwapMessage) {
+	now := time.Now()
+	var events []metadata.BitswapEvent
+	add := func(typ metadata.BitswapEventType, c string) *metadata.BitswapEvent {
+		events = append(events, metadata.BitswapEvent{
+			Time: now,
+			Peer: p.Pretty(),
+			Sent: sent,
+			Type: typ,
+			Cid:  c,
+		})
+		return &events[len(events)-1]
+	}
+
+	for _, entry := range msg.Wantlist() {
+		switch {
+		case entry.Cancel:
+			add(metadata.BitswapCancel, entry.Cid.String())
+		case entry.WantType == pb.Message_Wantlist_Have:
+			add(metadata.BitswapWantHave, entry.Cid.String())
+		default:
+			add(metadata.BitswapWantBlock, entry.Cid.String())
+		}
+	}
+	for _, c := range msg.Haves() {
+		add(metadata.BitswapHave, c.String())
+	}
+	for _, c := range msg.DontHaves() {
+		add(metadata.BitswapDontHave, c.String())
+	}
+	for _, blk := range msg.Blocks() {
+		event := add(metadata.BitswapBlock, blk.Cid().String())
+		event.Size = len(blk.RawData())
+		if !sent {
+			event.Dup, _ = bs.Has(blk.Cid())
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var action string
+	if len(s.actions) > 0 {
+		action = s.actions[len(s.actions)-1]
+	}
+	for _, event := range events {
+		if len(s.events) >= metadata.MaxBitswapEvents {
+			s.dropped++
+			continue
+		}
+		event.Action = action
+		s.events = append(s.events, event)
+	}
+}
+
+// StartAction keys the bitswap messages the peer exchanges by an action until
+// the returned function is called.
+func (p *Peer) StartAction(id string) func() {
+	s := &p.bitswapStats
+	s.mu.Lock()
+	s.actions = append(s.actions, id)
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, action := range s.actions {
+			if action == id {
+				s.actions = append(s.actions[:i], s.actions[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// reset discards the events recorded so far.
+func (s *bitswapStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+	s.dropped = 0
+}
+
+// bitswapTrace returns the events recorded so far.
+func (p *Peer) bitswapTrace() metadata.ReportBitswapTrace {
+	p.bitswapStats.mu.Lock()
+	defer p.bitswapStats.mu.Unlock()
+
+	if len(p.bitswapStats.events) == 0 && p.bitswapStats.dropped == 0 {
+		return metadata.ReportBitswapTrace{}
+	}
+
+	events, err := metadata.EncodeBitswapEvents(p.bitswapStats.events)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to encode bitswap events")
+	}
+	return metadata.ReportBitswapTrace{
+		Events:  events,
+		Dropped: p.bitswapStats.dropped,
+	}
+}
+
+// tracingNetwork records the messages that bitswap sends and receives over
+// a network.
+type tracingNetwork struct {
+	network.BitSwapNetwork
+	bs    blockstore.Blockstore
+	stats *bitswapStats
+}
+
+func (n *tracingNetwork) SetDelegate(r network.Receiver) {
+	n.BitSwapNetwork.SetDelegate(&tracingReceiver{r, n})
+}
+
+func (n *tracingNetwork) SendMessage(ctx context.Context, p libp2ppeer.ID, msg bsmsg.BitSwapMessage) error {
+	n.stats.record(n.bs, true, p, msg)
+	return n.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+func (n *tracingNetwork) NewMessageSender(ctx context.Context, p libp2ppeer.ID) (network.MessageSender, error) {
+	sender, err := n.BitSwapNetwork.NewMessageSender(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &tracingSender{sender, p, n}, nil
+}
+
+type tracingReceiver struct {
+	network.Receiver
+	n *tracingNetwork
+}
+
+func (r *tracingReceiver) ReceiveMessage(ctx context.Context, p libp2ppeer.ID, msg bsmsg.BitSwapMessage) {
+	// Recorded before the delegate stores the blocks, so that duplicates can
+	// be told apart.
+	r.n.stats.record(r.n.bs, false, p, msg)
+	r.Receiver.ReceiveMessage(ctx, p, msg)
+}
+
+type tracingSender struct {
+	network.MessageSender
+	p libp2ppeer.ID
+	n *tracingNetwork
+}
+
+func (s *tracingSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	s.n.stats.record(s.n.bs, true, s.p, msg)
+	return s.MessageSender.SendMsg(ctx, msg)
+}
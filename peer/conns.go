@@ -0,0 +1,74 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"sort"
+
+	"github.com/Netflix/p2plab/metadata"
+	network "github.com/libp2p/go-libp2p-core/network"
+)
+
+// Conns returns the connections the peer has open, ordered by remote peer.
+func (p *Peer) Conns() []metadata.ReportPeerConn {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	conns := []metadata.ReportPeerConn{}
+	for _, c := range p.host.Network().Conns() {
+		remote := c.RemotePeer()
+		conn := metadata.ReportPeerConn{
+			Peer:      remote.Pretty(),
+			Addr:      c.RemoteMultiaddr().String(),
+			Transport: connTransport(c),
+			Direction: connDirection(c.Stat().Direction),
+		}
+
+		protocols, err := p.host.Peerstore().GetProtocols(remote)
+		if err == nil {
+			sort.Strings(protocols)
+			conn.Protocols = protocols
+		}
+
+		conns = append(conns, conn)
+	}
+	sort.SliceStable(conns, func(i, j int) bool {
+		return conns[i].Peer < conns[j].Peer
+	})
+	return conns
+}
+
+// connTransport returns the outermost protocol of a connection's remote
+// multiaddr, such as tcp, quic or ws.
+func connTransport(c network.Conn) string {
+	var transport string
+	for _, p := range c.RemoteMultiaddr().Protocols() {
+		if p.Name != "p2p" && p.Name != "ipfs" {
+			transport = p.Name
+		}
+	}
+	return transport
+}
+
+func connDirection(dir network.Direction) string {
+	switch dir {
+	case network.DirInbound:
+		return "inbound"
+	case network.DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/rs/zerolog"
+)
+
+// customStats holds the metrics collectors of a peer.
+type customStats struct {
+	mu         sync.Mutex
+	collectors []p2plab.MetricsCollector
+
+	// baseline is the value of each counter when the report was last reset.
+	baseline map[string]float64
+}
+
+// RegisterCollector reports the metrics of a collector with the peer's
+// report.
+func (p *Peer) RegisterCollector(c p2plab.MetricsCollector) {
+	p.customStats.mu.Lock()
+	defer p.customStats.mu.Unlock()
+
+	p.customStats.collectors = append(p.customStats.collectors, c)
+}
+
+// collectCustom returns the metrics of every collector by
+// "<collector>.<name>", and the names of those that are counters. Collectors
+// that fail are left out with a warning.
+func (p *Peer) collectCustom(ctx context.Context) (map[string]float64, map[string]bool) {
+	p.customStats.mu.Lock()
+	collectors := append([]p2plab.MetricsCollector(nil), p.customStats.collectors...)
+	p.customStats.mu.Unlock()
+
+	values := make(map[string]float64)
+	counters := make(map[string]bool)
+	for _, c := range collectors {
+		metrics, err := c.Collect(ctx)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str("collector", c.Name()).Msg("Failed to collect custom metrics")
+			continue
+		}
+
+		for _, m := range metrics {
+			name := fmt.Sprintf("%s.%s", c.Name(), m.Name)
+			values[name] = m.Value
+			if m.Kind == metadata.CustomCounter {
+				counters[name] = true
+			}
+		}
+	}
+	return values, counters
+}
+
+// customReport returns the metrics of the collectors, with counters from when
+// the report was last reset.
+func (p *Peer) customReport(ctx context.Context) map[string]float64 {
+	values, counters := p.collectCustom(ctx)
+	if len(values) == 0 {
+		return nil
+	}
+
+	p.customStats.mu.Lock()
+	defer p.customStats.mu.Unlock()
+	for name := range counters {
+		values[name] -= p.customStats.baseline[name]
+	}
+	return values
+}
+
+// resetCustom takes the current value of the counters of the collectors as
+// their baseline.
+func (p *Peer) resetCustom(ctx context.Context) {
+	values, counters := p.collectCustom(ctx)
+
+	baseline := make(map[string]float64)
+	for name := range counters {
+		baseline[name] = values[name]
+	}
+
+	p.customStats.mu.Lock()
+	p.customStats.baseline = baseline
+	p.customStats.mu.Unlock()
+}
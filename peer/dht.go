@@ -0,0 +1,92 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+)
+
+// dhtStats accumulates the DHT metrics for a peer's report.
+type dhtStats struct {
+	mu     sync.Mutex
+	report metadata.ReportDHT
+}
+
+// record adds an operation that started at start to op.
+func (s *dhtStats) record(op *metadata.ReportOperation, start time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op.Record(time.Since(start), err)
+}
+
+func (p *Peer) Provide(ctx context.Context, c cid.Cid) error {
+	start := time.Now()
+	err := p.router().Provide(ctx, c, true)
+	p.dhtStats.record(&p.dhtStats.report.Provide, start, err)
+	if err != nil {
+		return errors.Wrapf(err, "failed to provide %q", c)
+	}
+	return nil
+}
+
+func (p *Peer) FindProviders(ctx context.Context, c cid.Cid, count int, timeout time.Duration) ([]libp2ppeer.AddrInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var infos []libp2ppeer.AddrInfo
+	for info := range p.router().FindProvidersAsync(ctx, c, count) {
+		infos = append(infos, info)
+	}
+
+	var err error
+	if len(infos) == 0 {
+		err = errors.Errorf("no providers found for %q", c)
+	}
+	p.dhtStats.record(&p.dhtStats.report.FindProviders, start, err)
+	return infos, err
+}
+
+func (p *Peer) FindPeer(ctx context.Context, id libp2ppeer.ID, timeout time.Duration) (libp2ppeer.AddrInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	info, err := p.router().FindPeer(ctx, id)
+	p.dhtStats.record(&p.dhtStats.report.FindPeer, start, err)
+	if err != nil {
+		return libp2ppeer.AddrInfo{}, errors.Wrapf(err, "failed to find peer %q", id)
+	}
+	return info, nil
+}
+
+// dhtReport returns a snapshot of the DHT metrics.
+func (p *Peer) dhtReport() metadata.ReportDHT {
+	p.dhtStats.mu.Lock()
+	defer p.dhtStats.mu.Unlock()
+
+	report := p.dhtStats.report
+	for _, op := range []*metadata.ReportOperation{&report.Provide, &report.FindProviders, &report.FindPeer} {
+		op.Time = op.Time.Copy()
+	}
+	return report
+}
@@ -0,0 +1,44 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"sync"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// execStats accumulates the commands run on a peer's node for its report.
+type execStats struct {
+	mu     sync.Mutex
+	report []metadata.ReportExec
+}
+
+// RecordExec adds the outcome of a command run on the peer's node to its
+// report.
+func (p *Peer) RecordExec(exec metadata.ReportExec) {
+	p.execStats.mu.Lock()
+	defer p.execStats.mu.Unlock()
+
+	p.execStats.report = append(p.execStats.report, exec)
+}
+
+// execReport returns a snapshot of the commands run so far.
+func (p *Peer) execReport() []metadata.ReportExec {
+	p.execStats.mu.Lock()
+	defer p.execStats.mu.Unlock()
+
+	return append([]metadata.ReportExec(nil), p.execStats.report...)
+}
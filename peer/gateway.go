@@ -0,0 +1,181 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	merkledag "github.com/ipfs/go-merkledag"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	// GatewayDirectoryContentType is the content type of a directory served
+	// by a gateway, which is listed as a JSON array of its entry names.
+	GatewayDirectoryContentType = "application/vnd.p2plab.directory+json"
+
+	// gatewayConcurrency is how many requests are in flight at once when
+	// retrieving a directory through a gateway, as a browser would.
+	gatewayConcurrency = 6
+)
+
+// gatewayStats accumulates the gateway retrieval metrics for a peer's report.
+type gatewayStats struct {
+	mu     sync.Mutex
+	report metadata.ReportGateway
+}
+
+// Open returns the UnixFS file or directory at a slash separated path of link
+// names from the DAG rooted at c, retrieving it through the peer as needed.
+func (p *Peer) Open(ctx context.Context, c cid.Cid, path string) (files.Node, error) {
+	dserv := p.DAGService()
+	ng := merkledag.NewSession(ctx, dserv)
+	nd, err := resolvePath(ctx, ng, c, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return unixfile.NewUnixfsFile(ctx, dserv, nd)
+}
+
+func (p *Peer) GatewayGet(ctx context.Context, gateway string, c cid.Cid, path string) error {
+	target := c.String()
+	path = strings.Trim(path, "/")
+	if path != "" {
+		target = fmt.Sprintf("%s/%s", target, path)
+	}
+
+	start := time.Now()
+	sem := make(chan struct{}, gatewayConcurrency)
+	err := p.gatewayGet(ctx, strings.TrimSuffix(gateway, "/"), target, sem)
+
+	p.gatewayStats.mu.Lock()
+	defer p.gatewayStats.mu.Unlock()
+	if err == nil {
+		p.gatewayStats.report.Time.Observe(time.Since(start))
+	}
+	return err
+}
+
+// gatewayGet requests a path from a gateway, and then every entry within it
+// if it is a directory. Requests wait on sem so that only so many are in
+// flight at once.
+func (p *Peer) gatewayGet(ctx context.Context, gateway, target string, sem chan struct{}) error {
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	entries, err := p.gatewayRequest(ctx, gateway, target)
+	<-sem
+	if err != nil {
+		return err
+	}
+
+	eg, gctx := errgroup.WithContext(ctx)
+	for _, entry := range entries {
+		entry := entry
+		eg.Go(func() error {
+			return p.gatewayGet(gctx, gateway, fmt.Sprintf("%s/%s", target, url.PathEscape(entry)), sem)
+		})
+	}
+	return eg.Wait()
+}
+
+// gatewayRequest requests a path from a gateway and records the request,
+// returning the entries of a directory.
+func (p *Peer) gatewayRequest(ctx context.Context, gateway, target string) (entries []string, err error) {
+	var (
+		firstByte time.Duration
+		n         int64
+	)
+	defer func() {
+		p.recordGatewayRequest(firstByte, n, err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/ipfs/%s", gateway, target), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to request %q from gateway", target)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("gateway responded to %q with %s: %s", target, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	br := bufio.NewReader(resp.Body)
+	_, err = br.Peek(1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	firstByte = time.Since(start)
+
+	if resp.Header.Get("Content-Type") == GatewayDirectoryContentType {
+		err = json.NewDecoder(br).Decode(&entries)
+		return entries, err
+	}
+
+	n, err = io.Copy(ioutil.Discard, br)
+	return nil, err
+}
+
+// recordGatewayRequest adds a request whose first byte was received after
+// firstByte and that received n bytes of a file to the report.
+func (p *Peer) recordGatewayRequest(firstByte time.Duration, n int64, err error) {
+	p.gatewayStats.mu.Lock()
+	defer p.gatewayStats.mu.Unlock()
+
+	p.gatewayStats.report.Requests++
+	if err != nil {
+		p.gatewayStats.report.Failures++
+		return
+	}
+	p.gatewayStats.report.Bytes += n
+	p.gatewayStats.report.FirstByte.Observe(firstByte)
+}
+
+// gatewayReport returns a snapshot of the gateway retrieval metrics.
+func (p *Peer) gatewayReport() metadata.ReportGateway {
+	p.gatewayStats.mu.Lock()
+	defer p.gatewayStats.mu.Unlock()
+
+	report := p.gatewayStats.report
+	report.FirstByte = report.FirstByte.Copy()
+	report.Time = report.Time.Copy()
+	return report
+}
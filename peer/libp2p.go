@@ -36,17 +36,17 @@ import (
 	"github.com/pkg/errors"
 )
 
-func NewLibp2pPeer(ctx context.Context, port int, pdef metadata.PeerDefinition, reporter metrics.Reporter) (host.Host, routing.ContentRouting, error) {
+func NewLibp2pPeer(ctx context.Context, port int, pdef metadata.PeerDefinition, reporter metrics.Reporter, opts ...libp2p.Option) (host.Host, routing.Routing, error) {
 	var (
 		addresses        []string
 		transportOptions []libp2p.Option
 	)
 	for _, transportType := range pdef.Transports {
-		option, address, err := NewTransportOption(transportType, port)
+		option, addrs, err := NewTransportOption(transportType, port, pdef.NetworkStack)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "failed to create transport option")
 		}
-		addresses = append(addresses, address)
+		addresses = append(addresses, addrs...)
 		transportOptions = append(transportOptions, option)
 	}
 
@@ -68,7 +68,7 @@ func NewLibp2pPeer(ctx context.Context, port int, pdef metadata.PeerDefinition,
 		securityOptions = append(securityOptions, option)
 	}
 
-	routingOption, r, err := NewRoutingOption(ctx, pdef.Routing)
+	routingOption, newRouting, err := NewRoutingOption(ctx, pdef.Routing)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed to create routing option")
 	}
@@ -81,25 +81,49 @@ func NewLibp2pPeer(ctx context.Context, port int, pdef metadata.PeerDefinition,
 		libp2p.ChainOptions(securityOptions...),
 		libp2p.BandwidthReporter(reporter),
 		routingOption,
+		libp2p.ChainOptions(opts...),
 	)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed to create libp2p host")
 	}
 
-	return host, r, nil
+	return host, newRouting(), nil
 }
 
-func NewTransportOption(transportType string, port int) (libp2p.Option, string, error) {
+func NewTransportOption(transportType string, port int, stack metadata.NetworkStack) (libp2p.Option, []string, error) {
+	var ips []string
+	switch stack {
+	case "", metadata.NetworkIPv4:
+		ips = []string{"/ip4/0.0.0.0"}
+	case metadata.NetworkIPv6:
+		ips = []string{"/ip6/::"}
+	case metadata.NetworkDual:
+		ips = []string{"/ip4/0.0.0.0", "/ip6/::"}
+	default:
+		return nil, nil, errors.Wrapf(errdefs.ErrInvalidArgument, "network stack %q", stack)
+	}
+
+	var (
+		option libp2p.Option
+		suffix string
+	)
 	switch transportType {
 	case "tcp":
-		return libp2p.Transport(tcp.NewTCPTransport), fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port), nil
+		option, suffix = libp2p.Transport(tcp.NewTCPTransport), fmt.Sprintf("/tcp/%d", port)
 	case "ws":
-		return libp2p.Transport(ws.New), fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/ws", port), nil
+		option, suffix = libp2p.Transport(ws.New), fmt.Sprintf("/tcp/%d/ws", port)
 	case "quic":
-		return libp2p.Transport(quic.NewTransport), fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic", port), nil
+		option, suffix = libp2p.Transport(quic.NewTransport), fmt.Sprintf("/udp/%d/quic", port)
 	default:
-		return nil, "", errors.Wrapf(errdefs.ErrInvalidArgument, "transport %q", transportType)
+		return nil, nil, errors.Wrapf(errdefs.ErrInvalidArgument, "transport %q", transportType)
+	}
+
+	var addresses []string
+	for _, ip := range ips {
+		addresses = append(addresses, ip+suffix)
 	}
+
+	return option, addresses, nil
 }
 
 func NewMuxerOption(muxerType string) (libp2p.Option, error) {
@@ -124,14 +148,16 @@ func NewSecurityOption(securityType string) (libp2p.Option, error) {
 	}
 }
 
-func NewRoutingOption(ctx context.Context, routingType string) (libp2p.Option, routing.ContentRouting, error) {
+// NewRoutingOption returns a libp2p option for the routing type, and a
+// function returning the routing once the libp2p host has been constructed.
+func NewRoutingOption(ctx context.Context, routingType string) (libp2p.Option, func() routing.Routing, error) {
 	switch routingType {
 	case "nil":
-		routing, err := nilrouting.ConstructNilRouting(nil, nil, nil, nil)
+		r, err := nilrouting.ConstructNilRouting(nil, nil, nil, nil)
 		if err != nil {
 			return nil, nil, err
 		}
-		return libp2p.Routing(nil), routing, nil
+		return libp2p.Routing(nil), func() routing.Routing { return r }, nil
 	case "kaddht":
 		var dht *kaddht.IpfsDHT
 		newDHT := func(h host.Host) (routing.PeerRouting, error) {
@@ -139,7 +165,7 @@ func NewRoutingOption(ctx context.Context, routingType string) (libp2p.Option, r
 			dht, err = kaddht.New(ctx, h)
 			return dht, err
 		}
-		return libp2p.Routing(newDHT), dht, nil
+		return libp2p.Routing(newDHT), func() routing.Routing { return dht }, nil
 	default:
 		return nil, nil, errors.Wrapf(errdefs.ErrInvalidArgument, "routing %q", routingType)
 	}
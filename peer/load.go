@@ -0,0 +1,119 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// loadStats accumulates the load metrics for a peer's report.
+type loadStats struct {
+	mu     sync.Mutex
+	report metadata.ReportLoad
+}
+
+// MaxLoadInFlight is the most retrievals Load has in flight at once. When
+// evicting, retrievals are serialized so only one is in flight.
+var MaxLoadInFlight = 64
+
+func (p *Peer) Load(ctx context.Context, c cid.Cid, rate float64, duration time.Duration, evict bool) error {
+	if rate <= 0 {
+		return errors.Errorf("rate must be positive")
+	}
+
+	interval := time.Duration(float64(time.Second) / rate)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Retrievals in flight are bounded so that a peer that cannot keep up
+	// with the rate does not pile them up. Retrievals are serialized when
+	// evicting, so that a retrieval does not evict blocks from under another.
+	inFlight := MaxLoadInFlight
+	if evict {
+		inFlight = 1
+	}
+	sem := make(chan struct{}, inFlight)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	scheduled := start
+	for {
+		select {
+		case sem <- struct{}{}:
+			wg.Add(1)
+			go func(scheduled time.Time) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				err := p.fetchGraph(ctx, c)
+				if err == nil && evict {
+					err = p.evict(ctx, c)
+				}
+				if err != nil {
+					zerolog.Ctx(ctx).Debug().Str("cid", c.String()).Err(err).Msg("Failed retrieval under load")
+				}
+
+				p.loadStats.mu.Lock()
+				defer p.loadStats.mu.Unlock()
+				p.loadStats.report.Requests++
+				if err != nil {
+					p.loadStats.report.Failures++
+				}
+				p.loadStats.report.Latency.Observe(time.Since(scheduled))
+			}(scheduled)
+		default:
+			// A retrieval that cannot be issued on schedule is missed rather
+			// than queued, so that the rate is never exceeded to catch up.
+			p.loadStats.mu.Lock()
+			p.loadStats.report.Missed++
+			p.loadStats.mu.Unlock()
+		}
+
+		scheduled = scheduled.Add(interval)
+		if scheduled.Sub(start) >= duration {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	wg.Wait()
+
+	p.loadStats.mu.Lock()
+	p.loadStats.report.Elapsed += time.Since(start)
+	p.loadStats.mu.Unlock()
+	return nil
+}
+
+// loadReport returns a snapshot of the load metrics.
+func (p *Peer) loadReport() metadata.ReportLoad {
+	p.loadStats.mu.Lock()
+	defer p.loadStats.mu.Unlock()
+
+	report := p.loadStats.report
+	report.Latency = report.Latency.Copy()
+	return report
+}
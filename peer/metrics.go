@@ -0,0 +1,64 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"github.com/Netflix/p2plab/pkg/metrics"
+	bitswap "github.com/ipfs/go-bitswap"
+)
+
+// RegisterMetrics registers the peer's bitswap counters, bandwidth and
+// connection counts, named with a namespace prefix. They are read from the
+// peer each time the registry is written, and are never reset.
+func (p *Peer) RegisterMetrics(reg *metrics.Registry, namespace string) {
+	stat := func(field func(*bitswap.Stat) uint64) func() float64 {
+		return func() float64 {
+			st, err := p.exchange().Stat()
+			if err != nil {
+				return 0
+			}
+			return float64(field(st))
+		}
+	}
+
+	for _, c := range []struct {
+		name, help string
+		field      func(*bitswap.Stat) uint64
+	}{
+		{"blocks_received_total", "Blocks received by bitswap.", func(s *bitswap.Stat) uint64 { return s.BlocksReceived }},
+		{"data_received_bytes_total", "Bytes of blocks received by bitswap.", func(s *bitswap.Stat) uint64 { return s.DataReceived }},
+		{"blocks_sent_total", "Blocks sent by bitswap.", func(s *bitswap.Stat) uint64 { return s.BlocksSent }},
+		{"data_sent_bytes_total", "Bytes of blocks sent by bitswap.", func(s *bitswap.Stat) uint64 { return s.DataSent }},
+		{"duplicate_blocks_received_total", "Blocks received by bitswap that were already received.", func(s *bitswap.Stat) uint64 { return s.DupBlksReceived }},
+		{"duplicate_data_received_bytes_total", "Bytes of blocks received by bitswap that were already received.", func(s *bitswap.Stat) uint64 { return s.DupDataReceived }},
+		{"messages_received_total", "Messages received by bitswap.", func(s *bitswap.Stat) uint64 { return s.MessagesReceived }},
+	} {
+		reg.NewCounterFunc(namespace+"_bitswap_"+c.name, c.help, stat(c.field))
+	}
+
+	reg.NewCounterFunc(namespace+"_bandwidth_in_bytes_total", "Bytes received from all peers.", func() float64 {
+		return float64(p.reporter.GetBandwidthTotals().TotalIn)
+	})
+	reg.NewCounterFunc(namespace+"_bandwidth_out_bytes_total", "Bytes sent to all peers.", func() float64 {
+		return float64(p.reporter.GetBandwidthTotals().TotalOut)
+	})
+
+	reg.NewGaugeFunc(namespace+"_peers", "Peers with open connections.", func() float64 {
+		return float64(len(p.Host().Network().Peers()))
+	})
+	reg.NewGaugeFunc(namespace+"_connections", "Open connections to peers.", func() float64 {
+		return float64(len(p.Host().Network().Conns()))
+	})
+}
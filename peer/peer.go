@@ -20,6 +20,7 @@ import (
 	"io"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Netflix/p2plab"
@@ -44,10 +45,12 @@ import (
 	"github.com/ipfs/go-unixfs/importer/balanced"
 	"github.com/ipfs/go-unixfs/importer/helpers"
 	"github.com/ipfs/go-unixfs/importer/trickle"
+	libp2p "github.com/libp2p/go-libp2p"
 	host "github.com/libp2p/go-libp2p-core/host"
 	metrics "github.com/libp2p/go-libp2p-core/metrics"
 	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/routing"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	swarm "github.com/libp2p/go-libp2p-swarm"
 	filter "github.com/libp2p/go-maddr-filter"
 	multiaddr "github.com/multiformats/go-multiaddr"
@@ -67,17 +70,64 @@ var (
 	ReprovideInterval = 12 * time.Hour
 )
 
+var (
+	// bitswapTaskWorkersMu guards bitswap.TaskWorkerCount, which this version
+	// of bitswap reads when it starts rather than taking as an option, from
+	// peers starting concurrently in the same process.
+	bitswapTaskWorkersMu sync.Mutex
+
+	// defaultBitswapTaskWorkers is the task worker count of peers that don't
+	// set one.
+	defaultBitswapTaskWorkers = bitswap.TaskWorkerCount
+)
+
 type Peer struct {
-	host     host.Host
-	dserv    ipld.DAGService
-	system   provider.System
-	r        routing.ContentRouting
-	bswap    *bitswap.Bitswap
-	bserv    blockservice.BlockService
+	root string
+	port int
+	pdef metadata.PeerDefinition
+
+	// ctx is the context the peer was created with, which the peer's services
+	// are started from whenever the peer is restarted.
+	ctx context.Context
+
+	// mu guards the peer definition and the services started from it, which
+	// Restart replaces. Short operations hold it for reading throughout so
+	// that Restart waits for them, while long ones only hold it to get the
+	// services they use, and fail if the peer restarts under them.
+	mu     sync.RWMutex
+	cancel context.CancelFunc
+	closed chan struct{}
+	host   host.Host
+	dserv  ipld.DAGService
+	system provider.System
+	r      routing.Routing
+	bswap  *bitswap.Bitswap
+	bserv  blockservice.BlockService
+	swarm  *swarm.Swarm
+	pubsub *pubsub.PubSub
+
 	bs       blockstore.Blockstore
 	ds       datastore.Batching
-	swarm    *swarm.Swarm
 	reporter metrics.Reporter
+
+	// pinMu is held for writing while a DAG is pinned and for reading while
+	// blocks are evicted, so that evictions never remove the blocks of a pin
+	// in progress.
+	pinMu sync.RWMutex
+
+	retrievalStats retrievalStats
+	pubsubStats    pubsubStats
+	dhtStats       dhtStats
+	loadStats      loadStats
+	addStats       addStats
+	gatewayStats   gatewayStats
+	execStats      execStats
+	updateStats    updateStats
+	bitswapStats   bitswapStats
+	customStats    customStats
+
+	// baseline is subtracted from counters that cannot be reset.
+	baseline baseline
 }
 
 func New(ctx context.Context, root string, port int, pdef metadata.PeerDefinition) (*Peer, error) {
@@ -86,39 +136,85 @@ func New(ctx context.Context, root string, port int, pdef metadata.PeerDefinitio
 		return nil, errors.Wrap(err, "failed to create datastore")
 	}
 
-	reporter := metrics.NewBandwidthCounter()
-	h, r, err := NewLibp2pPeer(ctx, port, pdef, reporter)
+	bs, err := NewBlockstore(ctx, ds)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create libp2p peer")
+		return nil, errors.Wrap(err, "failed to create blockstore")
 	}
 
-	swarm, ok := h.Network().(*swarm.Swarm)
-	if !ok {
-		return nil, errors.New("expected to be able to cast host network to swarm")
+	p := &Peer{
+		root:     root,
+		port:     port,
+		ctx:      ctx,
+		bs:       bs,
+		ds:       ds,
+		reporter: metrics.NewBandwidthCounter(),
 	}
 
-	bs, err := NewBlockstore(ctx, ds)
+	err = p.start(pdef)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create blockstore")
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// start creates the libp2p host and the services of the peer on top of its
+// blockstore. The services are stopped when the peer's context is cancelled.
+func (p *Peer) start(pdef metadata.PeerDefinition, opts ...libp2p.Option) error {
+	ctx, cancel := context.WithCancel(p.ctx)
+	h, r, err := NewLibp2pPeer(ctx, p.port, pdef, p.reporter, opts...)
+	if err != nil {
+		cancel()
+		return errors.Wrap(err, "failed to create libp2p peer")
+	}
+
+	swarm, ok := h.Network().(*swarm.Swarm)
+	if !ok {
+		cancel()
+		return errors.New("expected to be able to cast host network to swarm")
 	}
 
 	bswapnet := network.NewFromIpfsHost(h, r)
-	rem := bitswap.New(ctx, bswapnet, bs)
+	if pdef.BitswapTrace {
+		bswapnet = &tracingNetwork{bswapnet, p.bs, &p.bitswapStats}
+	}
+	// The task worker count is reset on every start so that a peer without
+	// one doesn't inherit that of a peer started before it.
+	bitswapTaskWorkersMu.Lock()
+	bitswap.TaskWorkerCount = defaultBitswapTaskWorkers
+	if pdef.BitswapTaskWorkers > 0 {
+		bitswap.TaskWorkerCount = pdef.BitswapTaskWorkers
+	}
+	rem := bitswap.New(ctx, bswapnet, p.bs, NewBitswapOptions(pdef)...)
+	bitswapTaskWorkersMu.Unlock()
 
 	bswap, ok := rem.(*bitswap.Bitswap)
 	if !ok {
-		return nil, errors.New("expected to be able to cast exchange interface to bitswap")
+		cancel()
+		return errors.New("expected to be able to cast exchange interface to bitswap")
 	}
 
-	bserv := blockservice.New(bs, rem)
+	bserv := blockservice.New(p.bs, rem)
+
+	ps, err := newPubsub(ctx, h)
+	if err != nil {
+		cancel()
+		bserv.Close()
+		return errors.Wrap(err, "failed to create pubsub")
+	}
 
-	system, err := NewProviderSystem(ctx, ds, bs, r)
+	system, err := NewProviderSystem(ctx, p.ds, p.bs, r)
 	if err != nil {
+		cancel()
 		bserv.Close()
-		return nil, errors.Wrap(err, "failed to create provider system")
+		return errors.Wrap(err, "failed to create provider system")
 	}
 
+	handleTraffic(h)
+
+	closed := make(chan struct{})
 	go func() {
+		defer close(closed)
 		system.Run()
 
 		select {
@@ -135,30 +231,57 @@ func New(ctx context.Context, root string, port int, pdef metadata.PeerDefinitio
 		}
 	}()
 
-	dserv := merkledag.NewDAGService(bserv)
-	return &Peer{
-		host:     h,
-		dserv:    dserv,
-		system:   system,
-		r:        r,
-		bswap:    bswap,
-		bserv:    bserv,
-		bs:       bs,
-		ds:       ds,
-		swarm:    swarm,
-		reporter: reporter,
-	}, nil
+	p.pdef = pdef
+	p.cancel = cancel
+	p.closed = closed
+	p.host = h
+	p.dserv = merkledag.NewDAGService(bserv)
+	p.system = system
+	p.r = r
+	p.bswap = bswap
+	p.bserv = bserv
+	p.swarm = swarm
+	p.pubsub = ps
+	return nil
 }
 
 func (p *Peer) Host() host.Host {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return p.host
 }
 
 func (p *Peer) DAGService() ipld.DAGService {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return p.dserv
 }
 
+// router returns the content routing the peer is running.
+func (p *Peer) router() routing.Routing {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.r
+}
+
+// exchange returns the bitswap the peer is running.
+func (p *Peer) exchange() *bitswap.Bitswap {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.bswap
+}
+
+// pubsubRouter returns the pubsub router the peer is running.
+func (p *Peer) pubsubRouter() *pubsub.PubSub {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pubsub
+}
+
 func (p *Peer) Connect(ctx context.Context, infos []libp2ppeer.AddrInfo) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	g, ctx := errgroup.WithContext(ctx)
 	for _, info := range infos {
 		info := info
@@ -181,6 +304,9 @@ func (p *Peer) Connect(ctx context.Context, infos []libp2ppeer.AddrInfo) error {
 }
 
 func (p *Peer) Disconnect(ctx context.Context, infos []libp2ppeer.AddrInfo) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	g, ctx := errgroup.WithContext(ctx)
 	for _, info := range infos {
 		info := info
@@ -236,7 +362,7 @@ func (p *Peer) Add(ctx context.Context, r io.Reader, opts ...p2plab.AddOption) (
 	prefix.MhType = hashFuncCode
 
 	dbp := helpers.DagBuilderParams{
-		Dagserv:    p.dserv,
+		Dagserv:    p.DAGService(),
 		RawLeaves:  settings.RawLeaves,
 		Maxlinks:   settings.MaxLinks,
 		NoCopy:     settings.NoCopy,
@@ -267,11 +393,21 @@ func (p *Peer) Add(ctx context.Context, r io.Reader, opts ...p2plab.AddOption) (
 }
 
 func (p *Peer) FetchGraph(ctx context.Context, c cid.Cid) error {
-	ng := merkledag.NewSession(ctx, p.dserv)
+	start := time.Now()
+	err := p.fetchGraph(ctx, c)
+	p.recordRetrieval(start, err)
+	return err
+}
+
+func (p *Peer) fetchGraph(ctx context.Context, c cid.Cid) error {
+	ng := merkledag.NewSession(ctx, p.DAGService())
 	return dag.Walk(ctx, c, ng)
 }
 
 func (p *Peer) Get(ctx context.Context, c cid.Cid) (files.Node, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	nd, err := p.dserv.Get(ctx, c)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get file %q", c)
@@ -281,6 +417,37 @@ func (p *Peer) Get(ctx context.Context, c cid.Cid) (files.Node, error) {
 }
 
 func (p *Peer) Report(ctx context.Context) (metadata.ReportNode, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	report, err := p.counters()
+	if err != nil {
+		return metadata.ReportNode{}, err
+	}
+	p.baseline.subtract(&report)
+
+	report.Pubsub = p.pubsubReport()
+	report.DHT = p.dhtReport()
+	report.Load = p.loadReport()
+	report.Retrieval = p.retrievalReport()
+	report.Add = p.addReport()
+	report.Gateway = p.gatewayReport()
+	report.Exec = p.execReport()
+	report.Update = p.updateReport()
+	report.BitswapTrace = p.bitswapTrace()
+	report.Custom = p.customReport(ctx)
+	report.Resources = resourcesReport()
+	report.PeerID = p.host.ID().Pretty()
+	report.Connections = metadata.ReportConnections{
+		Peers: int64(len(p.host.Network().Peers())),
+		Conns: int64(len(p.host.Network().Conns())),
+	}
+	return report, nil
+}
+
+// counters returns the bitswap and bandwidth metrics, which accumulate from
+// when the peer started. The caller must hold mu.
+func (p *Peer) counters() (metadata.ReportNode, error) {
 	stat, err := p.bswap.Stat()
 	if err != nil {
 		return metadata.ReportNode{}, err
@@ -310,6 +477,32 @@ func (p *Peer) Report(ctx context.Context) (metadata.ReportNode, error) {
 	}, nil
 }
 
+// retrievalStats accumulates the retrieval metrics for a peer's report.
+type retrievalStats struct {
+	mu     sync.Mutex
+	report metadata.ReportRetrieval
+}
+
+// recordRetrieval adds a retrieval that started at start to the report.
+func (p *Peer) recordRetrieval(start time.Time, err error) {
+	p.retrievalStats.mu.Lock()
+	defer p.retrievalStats.mu.Unlock()
+	if err != nil {
+		p.retrievalStats.report.Failures++
+	}
+	p.retrievalStats.report.Time.Observe(time.Since(start))
+}
+
+// retrievalReport returns a snapshot of the retrieval metrics.
+func (p *Peer) retrievalReport() metadata.ReportRetrieval {
+	p.retrievalStats.mu.Lock()
+	defer p.retrievalStats.mu.Unlock()
+
+	report := p.retrievalStats.report
+	report.Time = report.Time.Copy()
+	return report
+}
+
 func NewDatastore(path string) (datastore.Batching, error) {
 	return badger.NewDatastore(path, &badger.DefaultOptions)
 }
@@ -327,6 +520,16 @@ func NewBlockstore(ctx context.Context, ds datastore.Batching) (blockstore.Block
 	return bs, nil
 }
 
+func NewBitswapOptions(pdef metadata.PeerDefinition) []bitswap.Option {
+	opts := []bitswap.Option{
+		bitswap.ProvideEnabled(!pdef.BitswapNoProvide),
+	}
+	if pdef.BitswapSearchDelay > 0 {
+		opts = append(opts, bitswap.ProviderSearchDelay(pdef.BitswapSearchDelay))
+	}
+	return opts
+}
+
 func NewProviderSystem(ctx context.Context, ds datastore.Batching, bs blockstore.Blockstore, r routing.ContentRouting) (provider.System, error) {
 	queue, err := queue.NewQueue(ctx, "repro", ds)
 	if err != nil {
@@ -345,12 +548,17 @@ func cidrFromAddrInfo(info libp2ppeer.AddrInfo) (*net.IPNet, error) {
 	}
 
 	ma := info.Addrs[0]
-	ip4Addr, err := ma.ValueForProtocol(multiaddr.ProtocolWithName("ip4").Code)
+	cidr := "%s/32"
+	ipAddr, err := ma.ValueForProtocol(multiaddr.P_IP4)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get ip4 value")
+		cidr = "%s/128"
+		ipAddr, err = ma.ValueForProtocol(multiaddr.P_IP6)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get ip4 or ip6 value")
+		}
 	}
 
-	_, ipnet, err := net.ParseCIDR(fmt.Sprintf("%s/32", ip4Addr))
+	_, ipnet, err := net.ParseCIDR(fmt.Sprintf(cidr, ipAddr))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse peer cidr")
 	}
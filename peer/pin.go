@@ -0,0 +1,159 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Netflix/p2plab/dag"
+	"github.com/Netflix/p2plab/metadata"
+	blockservice "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	datastore "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
+	"github.com/pkg/errors"
+)
+
+// pinsKey is the datastore key under which pins are recorded.
+var pinsKey = datastore.NewKey("/pins")
+
+// pinKey returns the datastore key recording a pin on a cid.
+func pinKey(c cid.Cid) datastore.Key {
+	return pinsKey.ChildString(c.String())
+}
+
+// pin is the record of a pinned DAG.
+type pin struct {
+	Type  metadata.PinType
+	Depth int
+}
+
+// Pin fetches the DAG rooted at c down to depth and records a pin on it. A
+// direct pin retains only the root block, and a recursive pin retains every
+// block down to depth, or the entire DAG if depth is negative.
+//
+// The peer has no garbage collector, so pins only guard blocks against the
+// evictions of Load: a block reachable from a pin within its depth is never
+// evicted, even when it is shared with an evicted DAG. Restarting the peer
+// with clear removes every block and pin.
+func (p *Peer) Pin(ctx context.Context, c cid.Cid, pinType metadata.PinType, depth int) error {
+	switch pinType {
+	case metadata.PinDirect:
+		depth = 0
+	case metadata.PinRecursive:
+	default:
+		return errors.Errorf("unrecognized pin type %q", pinType)
+	}
+
+	p.pinMu.Lock()
+	defer p.pinMu.Unlock()
+
+	ng := merkledag.NewSession(ctx, p.DAGService())
+	err := dag.WalkDepth(ctx, c, ng, depth)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(pin{Type: pinType, Depth: depth})
+	if err != nil {
+		return err
+	}
+
+	return p.ds.Put(pinKey(c), value)
+}
+
+// evict removes the blocks of the DAG rooted at c from the blockstore, except
+// for the blocks retained by pins.
+func (p *Peer) evict(ctx context.Context, c cid.Cid) error {
+	p.pinMu.RLock()
+	defer p.pinMu.RUnlock()
+
+	pinned, err := p.pinnedBlocks(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list pinned blocks")
+	}
+
+	set := cid.NewSet()
+	err = merkledag.Walk(ctx, merkledag.GetLinksWithDAG(p.offlineDAG()), c, set.Visit)
+	if err != nil {
+		return errors.Wrapf(err, "failed to walk %q", c)
+	}
+
+	return set.ForEach(func(c cid.Cid) error {
+		if pinned.Has(c) {
+			return nil
+		}
+		return p.bs.DeleteBlock(c)
+	})
+}
+
+// pinnedBlocks returns the cids of the blocks retained by pins, which are the
+// blocks reachable from a pinned root within the depth it was pinned with.
+// The caller must hold pinMu.
+func (p *Peer) pinnedBlocks(ctx context.Context) (*cid.Set, error) {
+	results, err := p.ds.Query(query.Query{Prefix: pinsKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	getLinks := merkledag.GetLinksWithDAG(p.offlineDAG())
+	set := cid.NewSet()
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		root, err := cid.Decode(datastore.NewKey(result.Key).BaseNamespace())
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pin %q", result.Key)
+		}
+
+		var pn pin
+		err = json.Unmarshal(result.Value, &pn)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pin on %q", root)
+		}
+
+		// A block may be reached through a deeper path first, so it is
+		// visited again whenever it is reached at a shallower depth.
+		depths := make(map[cid.Cid]int)
+		err = merkledag.WalkDepth(ctx, getLinks, root, func(c cid.Cid, depth int) bool {
+			if pn.Depth >= 0 && depth > pn.Depth {
+				return false
+			}
+			if d, ok := depths[c]; ok && d <= depth {
+				return false
+			}
+			depths[c] = depth
+			set.Add(c)
+			return true
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to walk pin on %q", root)
+		}
+	}
+
+	return set, nil
+}
+
+// offlineDAG returns a DAG service without an exchange, so that only local
+// blocks are visited.
+func (p *Peer) offlineDAG() ipld.DAGService {
+	return merkledag.NewDAGService(blockservice.New(p.bs, nil))
+}
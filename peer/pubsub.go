@@ -0,0 +1,173 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	host "github.com/libp2p/go-libp2p-core/host"
+	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/pkg/errors"
+)
+
+// pubsubHeaderSize is the size of the header prefixed to every published
+// message, holding the publish time and sequence number.
+const pubsubHeaderSize = 16
+
+// pubsubStats accumulates the pubsub metrics for a peer's report.
+type pubsubStats struct {
+	mu     sync.Mutex
+	report metadata.ReportPubsub
+}
+
+func newPubsub(ctx context.Context, h host.Host) (*pubsub.PubSub, error) {
+	return pubsub.NewGossipSub(ctx, h)
+}
+
+func (p *Peer) Publish(ctx context.Context, topic string, count, size int, interval time.Duration) error {
+	if size < pubsubHeaderSize {
+		size = pubsubHeaderSize
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+
+		msg := make([]byte, size)
+		binary.BigEndian.PutUint64(msg, uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(msg[8:], uint64(i))
+
+		err := p.pubsubRouter().Publish(topic, msg)
+		if err != nil {
+			return errors.Wrapf(err, "failed to publish to topic %q", topic)
+		}
+
+		p.pubsubStats.mu.Lock()
+		p.pubsubStats.report.MessagesPublished++
+		p.pubsubStats.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (p *Peer) Subscribe(ctx context.Context, topic string, count, publishers int, timeout time.Duration) error {
+	sub, err := p.pubsubRouter().Subscribe(topic)
+	if err != nil {
+		return errors.Wrapf(err, "failed to subscribe to topic %q", topic)
+	}
+	defer sub.Cancel()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	seqnos, err := p.receive(ctx, sub, count, publishers)
+
+	// Every publisher sends the sequence numbers below count, so any missing
+	// from a publisher are lost, and so is every message of publishers that
+	// were never heard from.
+	var lost int64
+	for _, seen := range seqnos {
+		lost += int64(count - len(seen))
+	}
+	if len(seqnos) < publishers {
+		lost += int64((publishers - len(seqnos)) * count)
+	}
+
+	p.pubsubStats.mu.Lock()
+	p.pubsubStats.report.MessagesLost += lost
+	p.pubsubStats.mu.Unlock()
+
+	// Timing out is expected when messages are lost, so it is recorded rather
+	// than returned.
+	if err != nil && ctx.Err() != context.DeadlineExceeded {
+		return errors.Wrapf(err, "failed to receive from topic %q", topic)
+	}
+	return nil
+}
+
+// receive records the latency of messages from a subscription until count
+// messages have been received from each of the publishers or the context is
+// done. It returns the sequence numbers received from each publisher.
+//
+// Latency is measured from the publish time set by the publisher, so it
+// assumes that the clocks of nodes are synchronized, such as by the Amazon
+// Time Sync Service, and is skewed by any offset between them.
+func (p *Peer) receive(ctx context.Context, sub *pubsub.Subscription, count, publishers int) (map[libp2ppeer.ID]map[uint64]struct{}, error) {
+	var (
+		seqnos = make(map[libp2ppeer.ID]map[uint64]struct{})
+		done   int
+	)
+	for done < publishers {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return seqnos, err
+		}
+
+		if len(msg.Data) < pubsubHeaderSize {
+			continue
+		}
+
+		published := time.Unix(0, int64(binary.BigEndian.Uint64(msg.Data)))
+		latency := time.Since(published)
+
+		seqno := binary.BigEndian.Uint64(msg.Data[8:])
+		if seqno >= uint64(count) {
+			continue
+		}
+
+		from := msg.GetFrom()
+		seen, ok := seqnos[from]
+		if !ok {
+			seen = make(map[uint64]struct{})
+			seqnos[from] = seen
+		}
+		if _, ok := seen[seqno]; ok {
+			continue
+		}
+		seen[seqno] = struct{}{}
+		if len(seen) == count {
+			done++
+		}
+
+		p.pubsubStats.mu.Lock()
+		p.pubsubStats.report.MessagesReceived++
+		p.pubsubStats.report.Latency.Observe(latency)
+		p.pubsubStats.mu.Unlock()
+	}
+	return seqnos, nil
+}
+
+// pubsubReport returns a snapshot of the pubsub metrics.
+func (p *Peer) pubsubReport() metadata.ReportPubsub {
+	p.pubsubStats.mu.Lock()
+	defer p.pubsubStats.mu.Unlock()
+
+	report := p.pubsubStats.report
+	report.Latency = report.Latency.Copy()
+	return report
+}
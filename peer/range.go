@@ -0,0 +1,106 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/dag"
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	"github.com/pkg/errors"
+)
+
+func (p *Peer) GetRange(ctx context.Context, c cid.Cid, path string, offset, length int64) error {
+	start := time.Now()
+	err := p.getRange(ctx, c, path, offset, length)
+	p.recordRetrieval(start, err)
+	return err
+}
+
+func (p *Peer) getRange(ctx context.Context, c cid.Cid, path string, offset, length int64) error {
+	dserv := p.DAGService()
+	ng := merkledag.NewSession(ctx, dserv)
+	nd, err := resolvePath(ctx, ng, c, path)
+	if err != nil {
+		return err
+	}
+
+	if offset == 0 && length == 0 {
+		return dag.Walk(ctx, nd.Cid(), ng)
+	}
+
+	f, err := unixfile.NewUnixfsFile(ctx, dserv, nd)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q", nd.Cid())
+	}
+	defer f.Close()
+
+	file, ok := f.(files.File)
+	if !ok {
+		return errors.Errorf("%s%s is not a file", c, path)
+	}
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return errors.Wrapf(err, "failed to seek to offset %d", offset)
+	}
+
+	var r io.Reader = file
+	if length > 0 {
+		r = io.LimitReader(file, length)
+	}
+
+	_, err = io.Copy(ioutil.Discard, r)
+	return err
+}
+
+// resolvePath returns the node at a slash separated path of link names from
+// the DAG rooted at c.
+func resolvePath(ctx context.Context, ng ipld.NodeGetter, c cid.Cid, path string) (ipld.Node, error) {
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	for len(segments) > 0 {
+		lnk, rest, err := nd.ResolveLink(segments)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve %q in %q", path, c)
+		}
+
+		nd, err = lnk.GetNode(ctx, ng)
+		if err != nil {
+			return nil, err
+		}
+		segments = rest
+	}
+
+	return nd, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"runtime"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// resourcesReport returns the current resource usage of the process.
+func resourcesReport() metadata.ReportResources {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return metadata.ReportResources{
+		HeapAlloc:   stats.HeapAlloc,
+		HeapObjects: stats.HeapObjects,
+		Goroutines:  int64(runtime.NumGoroutine()),
+	}
+}
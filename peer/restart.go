@@ -0,0 +1,132 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+
+	"github.com/Netflix/p2plab/metadata"
+	datastore "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	libp2p "github.com/libp2p/go-libp2p"
+	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+)
+
+// Restart stops the services of the peer and starts them again with the peer
+// definition, keeping the identity and the metrics of the peer. If clear is
+// true, the blocks and pins of the peer are removed so that it restarts with
+// a cold cache. The peer reconnects to the peers it was connected to.
+//
+// Restart waits for the peer's short operations such as Report and Get, and
+// long operations in flight fail once the services they use are closed.
+func (p *Peer) Restart(ctx context.Context, pdef metadata.PeerDefinition, clear bool) error {
+	infos, err := p.restart(ctx, pdef, clear)
+	if err != nil {
+		return err
+	}
+
+	return p.Connect(ctx, infos)
+}
+
+// restart replaces the services of the peer, and returns the peers it was
+// connected to.
+func (p *Peer) restart(ctx context.Context, pdef metadata.PeerDefinition, clear bool) ([]libp2ppeer.AddrInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var infos []libp2ppeer.AddrInfo
+	for _, id := range p.host.Network().Peers() {
+		infos = append(infos, p.host.Peerstore().PeerInfo(id))
+	}
+	priv := p.host.Peerstore().PrivKey(p.host.ID())
+
+	// Bitswap counts from zero once it is restarted, so its counters so far
+	// are carried over in the baseline.
+	report, err := p.counters()
+	if err != nil {
+		return nil, err
+	}
+	p.baseline.carry(report.Bitswap)
+
+	p.cancel()
+	<-p.closed
+
+	err = p.host.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to close libp2p host")
+	}
+
+	if clear {
+		err = p.clear(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to clear blockstore")
+		}
+	}
+
+	err = p.start(pdef, libp2p.Identity(priv))
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// PeerDefinition returns the definition the peer was last started with.
+func (p *Peer) PeerDefinition() metadata.PeerDefinition {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pdef
+}
+
+// clear deletes every block and pin of the peer.
+func (p *Peer) clear(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys, err := p.bs.AllKeysChan(ctx)
+	if err != nil {
+		return err
+	}
+
+	for c := range keys {
+		err = p.bs.DeleteBlock(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	results, err := p.ds.Query(query.Query{
+		Prefix:   pinsKey.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for result := range results.Next() {
+		if result.Error != nil {
+			return result.Error
+		}
+
+		err = p.ds.Delete(datastore.NewKey(result.Key))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
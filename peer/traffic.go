@@ -0,0 +1,109 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"time"
+
+	host "github.com/libp2p/go-libp2p-core/host"
+	network "github.com/libp2p/go-libp2p-core/network"
+	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// TrafficProtocol is the protocol background traffic is streamed over, so
+// that its bandwidth is reported separately from other protocols.
+const TrafficProtocol protocol.ID = "/p2plab/traffic/1.0.0"
+
+// trafficChunkSize is the size of the writes on a traffic stream.
+const trafficChunkSize = 32 << 10
+
+// handleTraffic sets a stream handler discarding background traffic.
+func handleTraffic(h host.Host) {
+	h.SetStreamHandler(TrafficProtocol, func(s network.Stream) {
+		_, err := io.Copy(ioutil.Discard, s)
+		if err != nil {
+			s.Reset()
+			return
+		}
+		s.Close()
+	})
+}
+
+// Traffic opens a number of streams to each peer and sends random data on
+// them, capped at a rate of bytes per second on each stream if the rate is
+// positive, until the context is cancelled.
+func (p *Peer) Traffic(ctx context.Context, infos []libp2ppeer.AddrInfo, streams int, rate float64) error {
+	chunk := make([]byte, trafficChunkSize)
+	rand.Read(chunk)
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, info := range infos {
+		info := info
+		for i := 0; i < streams; i++ {
+			g.Go(func() error {
+				return p.sendTraffic(gctx, info, chunk, rate)
+			})
+		}
+	}
+
+	err := g.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (p *Peer) sendTraffic(ctx context.Context, info libp2ppeer.AddrInfo, chunk []byte, rate float64) error {
+	h := p.Host()
+	h.Peerstore().AddAddrs(info.ID, info.Addrs, time.Hour)
+	s, err := h.NewStream(ctx, info.ID, TrafficProtocol)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open traffic stream to %q", info.ID)
+	}
+	defer s.Reset()
+
+	start := time.Now()
+	var sent int64
+	for {
+		n, err := s.Write(chunk)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to send traffic to %q", info.ID)
+		}
+		sent += int64(n)
+
+		// Wait until the bytes sent so far are due at the rate.
+		var wait time.Duration
+		if rate > 0 {
+			due := start.Add(time.Duration(float64(sent) / rate * float64(time.Second)))
+			wait = time.Until(due)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peer
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	merkledag "github.com/ipfs/go-merkledag"
+	unixfs "github.com/ipfs/go-unixfs"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	"github.com/pkg/errors"
+)
+
+// updateHeaderSize is the size of the header prefixed to every announced
+// root, holding the announcement time.
+const updateHeaderSize = 8
+
+// updateStats accumulates the update propagation metrics for a peer's report.
+type updateStats struct {
+	mu     sync.Mutex
+	report metadata.ReportUpdate
+}
+
+func (p *Peer) Mutate(ctx context.Context, c cid.Cid, path string, mode metadata.MutationMode, size int64, topic string, opts ...p2plab.AddOption) error {
+	root, err := p.mutate(ctx, c, path, mode, size, opts...)
+	if err == nil {
+		err = p.Provide(ctx, root)
+	}
+	if err == nil {
+		msg := make([]byte, updateHeaderSize)
+		binary.BigEndian.PutUint64(msg, uint64(time.Now().UnixNano()))
+		err = p.pubsubRouter().Publish(topic, append(msg, root.Bytes()...))
+		if err != nil {
+			err = errors.Wrapf(err, "failed to announce %q on topic %q", root, topic)
+		}
+	}
+
+	p.updateStats.mu.Lock()
+	defer p.updateStats.mu.Unlock()
+	if err != nil {
+		p.updateStats.report.Failures++
+		return err
+	}
+	p.updateStats.report.Published++
+	return nil
+}
+
+// mutate changes the file at a slash separated path of link names from the
+// DAG rooted at c, and returns the root of the new DAG. The directories along
+// the path are copied with their link to the changed file or directory
+// replaced, so the new DAG shares every other block with the old one.
+func (p *Peer) mutate(ctx context.Context, c cid.Cid, path string, mode metadata.MutationMode, size int64, opts ...p2plab.AddOption) (cid.Cid, error) {
+	dserv := p.DAGService()
+	nd, err := dserv.Get(ctx, c)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	var (
+		names   []string
+		parents []*merkledag.ProtoNode
+	)
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+
+		pn, ok := nd.(*merkledag.ProtoNode)
+		if !ok {
+			return cid.Undef, errors.Wrapf(errdefs.ErrInvalidArgument, "%q in %q is not a directory", strings.Join(names, "/"), c)
+		}
+
+		fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+		if err != nil {
+			return cid.Undef, err
+		}
+		if fsn.Type() != unixfs.TDirectory {
+			return cid.Undef, errors.Wrapf(errdefs.ErrInvalidArgument, "%q in %q is not a plain directory", strings.Join(names, "/"), c)
+		}
+
+		lnk, err := pn.GetNodeLink(name)
+		if err != nil {
+			return cid.Undef, errors.Wrapf(err, "failed to resolve %q in %q", path, c)
+		}
+
+		nd, err = lnk.GetNode(ctx, dserv)
+		if err != nil {
+			return cid.Undef, err
+		}
+		names = append(names, name)
+		parents = append(parents, pn)
+	}
+
+	data := io.LimitReader(rand.New(rand.NewSource(time.Now().UnixNano())), size)
+	var r io.Reader
+	switch mode {
+	case metadata.MutationAppend:
+		f, err := unixfile.NewUnixfsFile(ctx, dserv, nd)
+		if err != nil {
+			return cid.Undef, errors.Wrapf(err, "failed to read %q", nd.Cid())
+		}
+		defer f.Close()
+
+		file, ok := f.(files.File)
+		if !ok {
+			return cid.Undef, errors.Wrapf(errdefs.ErrInvalidArgument, "%s/%s is not a file", c, path)
+		}
+		r = io.MultiReader(file, data)
+	case metadata.MutationReplace:
+		r = data
+	default:
+		return cid.Undef, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized mutation %q", mode)
+	}
+
+	child, err := p.Add(ctx, r, opts...)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	for i := len(parents) - 1; i >= 0; i-- {
+		pn := parents[i].Copy().(*merkledag.ProtoNode)
+		err = pn.RemoveNodeLink(names[i])
+		if err != nil {
+			return cid.Undef, err
+		}
+
+		err = pn.AddNodeLink(names[i], child)
+		if err != nil {
+			return cid.Undef, err
+		}
+
+		err = dserv.Add(ctx, pn)
+		if err != nil {
+			return cid.Undef, err
+		}
+		child = pn
+	}
+
+	return child.Cid(), nil
+}
+
+func (p *Peer) Follow(ctx context.Context, topic string, count int, timeout time.Duration) error {
+	sub, err := p.pubsubRouter().Subscribe(topic)
+	if err != nil {
+		return errors.Wrapf(err, "failed to subscribe to topic %q", topic)
+	}
+	defer sub.Cancel()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	received := 0
+	for received < count {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			break
+		}
+
+		if len(msg.Data) <= updateHeaderSize {
+			continue
+		}
+
+		announced := time.Unix(0, int64(binary.BigEndian.Uint64(msg.Data)))
+		_, root, err := cid.CidFromBytes(msg.Data[updateHeaderSize:])
+		if err != nil {
+			continue
+		}
+		received++
+
+		err = p.fetchGraph(ctx, root)
+		p.recordUpdate(announced, err)
+	}
+
+	p.updateStats.mu.Lock()
+	p.updateStats.report.Lost += int64(count - received)
+	p.updateStats.mu.Unlock()
+
+	// Timing out is expected when updates are lost, so it is recorded rather
+	// than returned.
+	if ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+		return errors.Wrapf(ctx.Err(), "failed to follow topic %q", topic)
+	}
+	return nil
+}
+
+// recordUpdate adds an update announced at announced that has been retrieved
+// to the report.
+func (p *Peer) recordUpdate(announced time.Time, err error) {
+	p.updateStats.mu.Lock()
+	defer p.updateStats.mu.Unlock()
+
+	if err != nil {
+		p.updateStats.report.Failures++
+		return
+	}
+	p.updateStats.report.Received++
+	p.updateStats.report.Propagation.Observe(time.Since(announced))
+}
+
+// updateReport returns a snapshot of the update propagation metrics.
+func (p *Peer) updateReport() metadata.ReportUpdate {
+	p.updateStats.mu.Lock()
+	defer p.updateStats.mu.Unlock()
+
+	report := p.updateStats.report
+	report.Propagation = report.Propagation.Copy()
+	return report
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+)
+
+// descriptors are shorthands for common schedules.
+var descriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// field is the range of values of a field of a cron expression.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// Schedule is a parsed cron expression of the form
+// "<minute> <hour> <day of month> <month> <day of week>".
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// anyDom and anyDow are whether the day fields are unrestricted. When
+	// both are restricted, a day matches if either field matches.
+	anyDom, anyDow bool
+}
+
+// Parse parses a cron expression. Each field is "*", a value, a range "a-b",
+// or a list of them separated by commas, and "*" and ranges may have a step
+// "/n". The descriptors @hourly, @daily, @weekly and @monthly are also
+// accepted.
+func Parse(spec string) (*Schedule, error) {
+	if expanded, ok := descriptors[spec]; ok {
+		spec = expanded
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "cron expression %q must have %d fields", spec, len(fields))
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cron expression %q", spec)
+		}
+		sets[i] = set
+	}
+
+	return &Schedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		anyDom: parts[2] == "*",
+		anyDow: parts[4] == "*",
+	}, nil
+}
+
+// parseField returns the set of values matched by a field as a bitmask.
+func parseField(s string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(s, ",") {
+		expr, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			expr = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step < 1 {
+				return 0, errors.Wrapf(errdefs.ErrInvalidArgument, "%s %q has invalid step", f.name, item)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		if expr != "*" {
+			bounds := strings.SplitN(expr, "-", 2)
+			var err error
+			lo, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, errors.Wrapf(errdefs.ErrInvalidArgument, "%s %q is not a number", f.name, item)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				hi, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, errors.Wrapf(errdefs.ErrInvalidArgument, "%s %q is not a number", f.name, item)
+				}
+			} else if step > 1 {
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, errors.Wrapf(errdefs.ErrInvalidArgument, "%s %q must be within %d-%d", f.name, item, f.min, f.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// maxSearch bounds how far ahead Next looks for a matching time, which is
+// only reached by expressions that never match, such as the 31st of
+// February.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Next returns the first minute after t that matches the schedule, in t's
+// location, or the zero time if there is none.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.Add(maxSearch)
+	for t.Before(end) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.anyDom && s.anyDow:
+		return true
+	case s.anyDom:
+		return dow
+	case s.anyDow:
+		return dom
+	default:
+		return dom || dow
+	}
+}
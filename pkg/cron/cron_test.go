@@ -0,0 +1,61 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2020, time.January, 15, 10, 30, 20, 0, time.UTC)
+
+	for _, tc := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2020, time.January, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, time.January, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2020, time.January, 16, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2020, time.January, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * 1-5", time.Date(2020, time.January, 15, 13, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2020, time.January, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * 0", time.Date(2020, time.January, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	} {
+		s, err := Parse(tc.spec)
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.next, s.Next(now), tc.spec)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 5-2 * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"* * 0 * *",
+	} {
+		_, err := Parse(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package defutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// IsYAML returns whether a definition file is written in YAML rather than
+// JSON, by its extension.
+func IsYAML(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// Decode decodes a definition written in JSON or YAML into v. YAML is
+// converted to JSON first, so that definitions decode the same way in either
+// format. Errors are prefixed by the line of the content they were found at
+// when it is known.
+func Decode(filename string, content []byte, v interface{}) error {
+	data := content
+	if IsYAML(filename) {
+		var doc interface{}
+		err := yaml.Unmarshal(content, &doc)
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "%s: %s", filename, strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+
+		data, err = json.Marshal(jsonValue(doc))
+		if err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "%s: %s", filename, err)
+		}
+	}
+
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		switch t := err.(type) {
+		case *json.SyntaxError:
+			line, col := position(content, t.Offset)
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "%s:%d:%d: %s", filename, line, col, t)
+		case *json.UnmarshalTypeError:
+			msg := fmt.Sprintf("cannot use %s as %s", t.Value, t.Type)
+			if t.Field != "" {
+				msg = fmt.Sprintf("%s: %s", t.Field, msg)
+			}
+
+			line := Line(content, t.Field)
+			if line == 0 && !IsYAML(filename) {
+				line, _ = position(content, t.Offset)
+			}
+			if line > 0 {
+				return errors.Wrapf(errdefs.ErrInvalidArgument, "%s:%d: %s", filename, line, msg)
+			}
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "%s: %s", filename, msg)
+		default:
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "%s: %s", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// Line returns the line of a definition written in JSON or YAML that a
+// dotted path of keys is defined at, such as "stages.fetch.timeout". Keys are
+// matched case insensitively like JSON fields, and numeric path elements are
+// skipped. If the path isn't fully found, the line of its deepest key found
+// is returned, or 0 if none are.
+func Line(content []byte, path string) int {
+	if path == "" {
+		return 0
+	}
+
+	lines := strings.Split(string(content), "\n")
+	line, indent := -1, -1
+	for _, key := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(key); err == nil {
+			continue
+		}
+
+		found := false
+		for i := line + 1; i < len(lines); i++ {
+			trimmed := strings.TrimLeft(lines[i], " \t")
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+
+			// Keys of the parent end where a line is indented as much as it.
+			n := len(lines[i]) - len(trimmed)
+			if line >= 0 && n <= indent {
+				break
+			}
+
+			if isKey(trimmed, key) {
+				line, indent, found = i, n, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return line + 1
+}
+
+// isKey returns whether a trimmed line of JSON or YAML defines a key.
+func isKey(trimmed, key string) bool {
+	trimmed = strings.TrimLeft(strings.TrimPrefix(trimmed, "-"), " ")
+	trimmed = strings.TrimLeft(trimmed, "\"'")
+	if len(trimmed) < len(key) || !strings.EqualFold(trimmed[:len(key)], key) {
+		return false
+	}
+
+	rest := strings.TrimLeft(trimmed[len(key):], "\"' ")
+	return strings.HasPrefix(rest, ":")
+}
+
+// position returns the line and column of a byte offset in content.
+func position(content []byte, offset int64) (line, col int) {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+
+	line, col = 1, 1
+	for _, b := range content[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// jsonValue converts a value decoded from YAML into one that can be encoded
+// as JSON, whose objects must have string keys.
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprint(k)] = jsonValue(v)
+		}
+		return m
+	case []interface{}:
+		for i, v := range t {
+			t[i] = jsonValue(v)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package defutil
+
+import (
+	"testing"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/stretchr/testify/require"
+)
+
+type definition struct {
+	Trials int
+	Stages map[string]struct {
+		Timeout string
+		Actions []string
+	}
+}
+
+func TestDecodeYAML(t *testing.T) {
+	content := []byte(`# Fetch an object from every node.
+trials: 2
+stages:
+  fetch:
+    timeout: 5m
+    actions:
+      - "(get 'image')"
+`)
+
+	var def definition
+	err := Decode("scenario.yaml", content, &def)
+	require.NoError(t, err)
+	require.Equal(t, 2, def.Trials)
+	require.Equal(t, "5m", def.Stages["fetch"].Timeout)
+	require.Equal(t, []string{"(get 'image')"}, def.Stages["fetch"].Actions)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		content  string
+		expected string
+	}{
+		{"scenario.json", "{\n  \"trials\": 2,\n  \"stages\": {\n}", "scenario.json:4:2:"},
+		{"scenario.json", "{\n  \"trials\": \"two\"\n}", "scenario.json:2: trials: cannot use string as int"},
+		{"scenario.yaml", "trials: 2\nstages:\n  fetch:\n    timeout: [5m]\n", "cannot use array as string"},
+		{"scenario.yml", "trials: 2\n  stages: {\n", "scenario.yml: line 2:"},
+	} {
+		t.Run(test.filename, func(t *testing.T) {
+			var def definition
+			err := Decode(test.filename, []byte(test.content), &def)
+			require.Error(t, err)
+			require.True(t, errdefs.IsInvalidArgument(err))
+			require.Contains(t, err.Error(), test.expected)
+		})
+	}
+}
+
+func TestLine(t *testing.T) {
+	json := []byte(`{
+    "objects": {
+        "image": {
+            "type": "oci"
+        }
+    },
+    "stages": {
+        "fetch": {
+            "timeout": "5m"
+        }
+    }
+}`)
+	require.Equal(t, 9, Line(json, "stages.fetch.timeout"))
+	require.Equal(t, 4, Line(json, "objects.image.type"))
+	require.Equal(t, 8, Line(json, "stages.fetch.actions"))
+	require.Equal(t, 0, Line(json, "benchmark"))
+
+	yaml := []byte("objects:\n  image:\n    type: oci\nstages:\n  fetch:\n    timeout: 5m\n")
+	require.Equal(t, 6, Line(yaml, "stages.fetch.timeout"))
+	require.Equal(t, 3, Line(yaml, "objects.image.type"))
+}
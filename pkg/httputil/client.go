@@ -34,7 +34,8 @@ func NewHTTPClient() *http.Client {
 
 type Client struct {
 	HTTPClient *http.Client
-	logger *zerolog.Logger
+	logger     *zerolog.Logger
+	headers    map[string]string
 }
 
 func NewClient(hclient *http.Client, opts ...ClientOption) (*Client, error) {
@@ -78,10 +79,12 @@ func (c *Client) NewRequest(method, url string, opts ...RequestOption) *Request
 	}
 
 	return &Request{
-		Method:  method,
-		Url:     url,
-		Options: make(map[string]string),
-		client:  client,
+		Method:         method,
+		Url:            url,
+		Options:        make(map[string]string),
+		headers:        c.headers,
+		idempotencyKey: settings.IdempotencyKey,
+		client:         client,
 	}
 }
 
@@ -94,6 +97,18 @@ func WithLogger(logger *zerolog.Logger) ClientOption {
 	}
 }
 
+// WithHeader sets a header on every request of the client, such as the
+// credentials of a proxy in front of labd.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) error {
+		if c.headers == nil {
+			c.headers = make(map[string]string)
+		}
+		c.headers[key] = value
+		return nil
+	}
+}
+
 type RequestOption func(*RequestSettings)
 
 type RequestSettings struct {
@@ -102,6 +117,10 @@ type RequestSettings struct {
 	RetryMax     int
 	CheckRetry   retryablehttp.CheckRetry
 	Backoff      retryablehttp.Backoff
+
+	// IdempotencyKey is sent with every attempt of the request, so that the
+	// daemon serving it only acts on it once.
+	IdempotencyKey string
 }
 
 func WithRetryWaitMin(d time.Duration) RequestOption {
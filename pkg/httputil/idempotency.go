@@ -0,0 +1,57 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httputil
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rs/xid"
+)
+
+// IdempotencyHeader is the HTTP header that carries the key of a request
+// that must only be acted on once, however many times it is retried.
+const IdempotencyHeader = "Idempotency-Key"
+
+// WithIdempotency retries a request that is not otherwise safe to retry,
+// such as one that creates a resource. Every attempt carries the same
+// idempotency key, and only transient failures are retried, where the
+// request may not have reached the daemon.
+func WithIdempotency() RequestOption {
+	return func(s *RequestSettings) {
+		s.IdempotencyKey = xid.New().String()
+		s.CheckRetry = RetryTransient
+	}
+}
+
+// RetryTransient retries requests that failed to connect, or were rejected
+// by a proxy or an unavailable daemon. Unlike the default policy, internal
+// server errors are not retried, because they are returned by a daemon that
+// has already acted on the request.
+func RetryTransient(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if err != nil {
+		return true, nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, nil
+	}
+	return false, nil
+}
@@ -25,6 +25,7 @@ import (
 	"strconv"
 
 	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/pkg/traceutil"
 	retryablehttp "github.com/hashicorp/go-retryablehttp"
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	opentracing "github.com/opentracing/opentracing-go"
@@ -37,6 +38,9 @@ type Request struct {
 	Url     string
 	Options map[string]string
 	body    io.Reader
+	headers map[string]string
+
+	idempotencyKey string
 
 	client    *retryablehttp.Client
 	rawClient *http.Client
@@ -82,6 +86,18 @@ func (r *Request) Send(ctx context.Context) (*http.Response, error) {
 	}
 	req = req.WithContext(ctx)
 
+	for key, value := range r.headers {
+		req.Header.Set(key, value)
+	}
+
+	if r.idempotencyKey != "" {
+		req.Header.Set(IdempotencyHeader, r.idempotencyKey)
+	}
+
+	if id := traceutil.ActionID(ctx); id != "" {
+		req.Header.Set(traceutil.ActionHeader, id)
+	}
+
 	span := opentracing.SpanFromContext(ctx)
 	if span != nil {
 		var ht *nethttp.Tracer
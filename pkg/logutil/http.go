@@ -24,8 +24,13 @@ import (
 	"github.com/rs/zerolog"
 )
 
-func WithResponseLogger(ctx context.Context, w http.ResponseWriter) (context.Context, *zerolog.Logger) {
-	multiwriter := io.MultiWriter(os.Stderr, NewWriteFlusher(w))
+// WithResponseLogger returns a context with a logger that writes to stderr,
+// the response and any other writers.
+func WithResponseLogger(ctx context.Context, w http.ResponseWriter, writers ...io.Writer) (context.Context, *zerolog.Logger) {
+	// The response is written to last, because it fails once the client has
+	// disconnected.
+	writers = append(append([]io.Writer{os.Stderr}, writers...), NewWriteFlusher(w))
+	multiwriter := io.MultiWriter(writers...)
 	logger := zerolog.Ctx(ctx).Output(multiwriter)
 	ctx = logger.WithContext(WithLogWriter(ctx, multiwriter))
 	return ctx, &logger
@@ -0,0 +1,54 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	reg := NewRegistry()
+	tasks := reg.NewCounter("tasks_total", "Tasks run.", "task")
+	tasks.Inc("get")
+	tasks.Add(2, "add")
+
+	reg.NewGaugeFunc("peers", "Connected peers.", func() float64 { return 3 })
+
+	latency := reg.NewHistogram("latency_seconds", "Task latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(5)
+
+	var buf bytes.Buffer
+	require.NoError(t, reg.Write(&buf))
+	require.Equal(t, `# HELP tasks_total Tasks run.
+# TYPE tasks_total counter
+tasks_total{task="add"} 2
+tasks_total{task="get"} 1
+# HELP peers Connected peers.
+# TYPE peers gauge
+peers 3
+# HELP latency_seconds Task latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+`, buf.String())
+}
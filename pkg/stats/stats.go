@@ -0,0 +1,220 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Mean returns the mean of samples.
+func Mean(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, x := range samples {
+		sum += x
+	}
+	return sum / float64(len(samples))
+}
+
+// Variance returns the unbiased sample variance of samples.
+func Variance(samples []float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	mean := Mean(samples)
+	var sum float64
+	for _, x := range samples {
+		sum += (x - mean) * (x - mean)
+	}
+	return sum / float64(len(samples)-1)
+}
+
+// WelchTTest returns the two-sided p-value of Welch's t-test for the means of
+// a and b being equal, which does not assume they have equal variances. Both
+// must have at least two samples, otherwise the p-value is 1. Samples without
+// variance have a p-value of 0 if their means differ.
+func WelchTTest(a, b []float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 1
+	}
+
+	na, nb := float64(len(a)), float64(len(b))
+	va, vb := Variance(a)/na, Variance(b)/nb
+	diff := Mean(a) - Mean(b)
+	if va+vb == 0 {
+		if diff == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	t := diff / math.Sqrt(va+vb)
+	df := (va + vb) * (va + vb) / (va*va/(na-1) + vb*vb/(nb-1))
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// ConfidenceInterval returns the half-width of the confidence interval of the
+// mean of samples at a confidence level between 0 and 1, using Student's
+// t-distribution. It is 0 for fewer than two samples.
+func ConfidenceInterval(samples []float64, confidence float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	n := float64(len(samples))
+	return studentTQuantile(1-confidence, n-1) * math.Sqrt(Variance(samples)/n)
+}
+
+// studentTQuantile returns the t whose two-sided tail probability is alpha
+// for the Student's t-distribution with df degrees of freedom, found by
+// bisection since the tail probability decreases as t grows.
+func studentTQuantile(alpha, df float64) float64 {
+	tail := func(t float64) float64 {
+		return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+	}
+
+	lo, hi := 0.0, 1.0
+	for tail(hi) > alpha && hi < 1e9 {
+		lo, hi = hi, hi*2
+	}
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if tail(mid) > alpha {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// outlierThreshold is the modified z-score beyond which a sample is an
+// outlier, as recommended by Iglewicz and Hoaglin.
+const outlierThreshold = 3.5
+
+// Outliers returns the indices of the samples that are outliers by their
+// modified z-score, which measures the distance from the median in median
+// absolute deviations so that the outliers themselves do not mask each other.
+// When most samples are equal, the mean absolute deviation is used instead.
+// At least three samples are needed to tell outliers apart.
+func Outliers(samples []float64) []int {
+	if len(samples) < 3 {
+		return nil
+	}
+
+	m := median(samples)
+	deviations := make([]float64, len(samples))
+	for i, x := range samples {
+		deviations[i] = math.Abs(x - m)
+	}
+
+	// The constants scale each deviation to the standard deviation of a
+	// normal distribution.
+	scale := 1.4826 * median(deviations)
+	if scale == 0 {
+		scale = 1.2533 * Mean(deviations)
+	}
+	if scale == 0 {
+		return nil
+	}
+
+	var outliers []int
+	for i, d := range deviations {
+		if d/scale > outlierThreshold {
+			outliers = append(outliers, i)
+		}
+	}
+	return outliers
+}
+
+func median(samples []float64) float64 {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// regularizedIncompleteBeta returns I_x(a, b), which for the Student's
+// t-distribution with df degrees of freedom gives the two-sided tail
+// probability of t when a is df/2, b is 1/2 and x is df/(df+t²).
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// The continued fraction converges quickly only on one side of the mean
+	// of the distribution, so the other side uses the symmetry relation.
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction of the incomplete
+// beta function by the modified Lentz's method.
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 3e-14
+		tiny          = 1e-300
+	)
+
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+		return v
+	}
+
+	c := 1.0
+	d := 1 / clamp(1-(a+b)*x/(a+1))
+	h := d
+	for i := 1; i <= maxIterations; i++ {
+		m := float64(i)
+
+		num := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 / clamp(1+num*d)
+		c = clamp(1 + num/c)
+		h *= d * c
+
+		num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 / clamp(1+num*d)
+		c = clamp(1 + num/c)
+		delta := d * c
+		h *= delta
+
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeanVariance(t *testing.T) {
+	samples := []float64{1, 2, 3, 4, 5}
+	require.Equal(t, 3.0, Mean(samples))
+	require.Equal(t, 2.5, Variance(samples))
+	require.Equal(t, 0.0, Variance(samples[:1]))
+}
+
+func TestWelchTTest(t *testing.T) {
+	// t = -5 with 8 degrees of freedom.
+	p := WelchTTest([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10})
+	require.InDelta(t, 0.001053, p, 1e-6)
+
+	p = WelchTTest([]float64{1, 2, 3}, []float64{1, 2, 3})
+	require.InDelta(t, 1, p, 1e-9)
+
+	require.Equal(t, 1.0, WelchTTest([]float64{1}, []float64{2, 3}))
+	require.Equal(t, 0.0, WelchTTest([]float64{2, 2}, []float64{3, 3}))
+	require.Equal(t, 1.0, WelchTTest([]float64{2, 2}, []float64{2, 2}))
+}
+
+func TestConfidenceInterval(t *testing.T) {
+	// The standard error is 1/sqrt(2) and t is 2.776 with 4 degrees of
+	// freedom.
+	ci := ConfidenceInterval([]float64{1, 2, 3, 4, 5}, 0.95)
+	require.InDelta(t, 1.963, ci, 1e-3)
+
+	// t is 12.706 with a single degree of freedom.
+	ci = ConfidenceInterval([]float64{1, 3}, 0.95)
+	require.InDelta(t, 12.706, ci, 1e-3)
+
+	require.Equal(t, 0.0, ConfidenceInterval([]float64{1}, 0.95))
+	require.Equal(t, 0.0, ConfidenceInterval([]float64{2, 2, 2}, 0.95))
+}
+
+func TestOutliers(t *testing.T) {
+	require.Equal(t, []int{5}, Outliers([]float64{10, 11, 10, 12, 11, 40}))
+	require.Equal(t, []int{0}, Outliers([]float64{1, 5, 5, 5, 5, 5}))
+	require.Empty(t, Outliers([]float64{10, 11, 12, 13}))
+	require.Empty(t, Outliers([]float64{1, 100}))
+	require.Empty(t, Outliers([]float64{3, 3, 3}))
+}
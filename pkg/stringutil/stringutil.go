@@ -14,6 +14,16 @@
 
 package stringutil
 
+// Contains returns whether the slice contains the string.
+func Contains(slice []string, s string) bool {
+	for _, e := range slice {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
 func Coalesce(slice []string) []string {
 	var r []string
 	for _, e := range slice {
@@ -0,0 +1,45 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traceutil
+
+import (
+	"context"
+
+	"github.com/rs/xid"
+)
+
+// ActionHeader is the HTTP header that carries the ID of the benchmark action
+// a request belongs to.
+const ActionHeader = "X-P2plab-Action"
+
+type actionKey struct{}
+
+// NewActionID returns a unique ID for a benchmark action.
+func NewActionID() string {
+	return xid.New().String()
+}
+
+// WithActionID returns a context for requests made on behalf of an action,
+// which carry its ID to the daemons that serve them.
+func WithActionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, actionKey{}, id)
+}
+
+// ActionID returns the ID of the action in ctx, or an empty string if there
+// is none.
+func ActionID(ctx context.Context) string {
+	id, _ := ctx.Value(actionKey{}).(string)
+	return id
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package printer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/olekukonko/tablewriter"
+)
+
+// progressBarWidth is the number of cells in a progress bar.
+const progressBarWidth = 20
+
+func printDashboard(d metadata.Dashboard) error {
+	fmt.Println("CLUSTERS")
+	table := newDashboardTable([]string{"ID", "STATUS", "SIZE", "HEALTHY", "UNHEALTHY"})
+	for _, c := range d.Clusters {
+		healthy, unhealthy := "-", ""
+		if health, ok := d.Health[c.ID]; ok {
+			total := len(health.Healthy) + len(health.Unhealthy)
+			healthy = fmt.Sprintf("%d/%d", len(health.Healthy), total)
+			unhealthy = strings.Join(health.Unhealthy, ",")
+		}
+		table.Append([]string{
+			c.ID,
+			string(c.Status),
+			strconv.Itoa(c.Definition.Size()),
+			healthy,
+			unhealthy,
+		})
+	}
+	renderDashboardTable(table, len(d.Clusters))
+
+	fmt.Println("\nRUNNING")
+	table = newDashboardTable([]string{"ID", "STATUS", "TRIAL", "PROGRESS", "ELAPSED", "ETA"})
+	for _, p := range d.Running {
+		trial := "-"
+		if p.Trials > 0 && p.Trial > 0 {
+			trial = fmt.Sprintf("%d/%d", p.Trial, p.Trials)
+		}
+		eta := "unknown"
+		if !p.ETA.IsZero() {
+			eta = humanize.Time(p.ETA)
+		}
+		table.Append([]string{
+			p.ID,
+			string(p.Status),
+			trial,
+			progressBar(p.ActionsCompleted, p.ActionsTotal),
+			p.Elapsed.Round(time.Second).String(),
+			eta,
+		})
+	}
+	renderDashboardTable(table, len(d.Running))
+
+	fmt.Println("\nRECENT")
+	table = newDashboardTable([]string{"ID", "STATUS", "CLUSTER", "SCENARIO", "REGRESSIONS", "UPDATEDAT"})
+	for _, b := range d.Recent {
+		regressions := "-"
+		if b.Baseline != "" {
+			regressions = strconv.Itoa(len(b.Regressions))
+		}
+		table.Append([]string{
+			b.ID,
+			string(b.Status),
+			b.Cluster.ID,
+			b.Scenario.ID,
+			regressions,
+			humanize.Time(b.UpdatedAt),
+		})
+	}
+	renderDashboardTable(table, len(d.Recent))
+
+	return nil
+}
+
+func newDashboardTable(header []string) *tablewriter.Table {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader(header)
+	return table
+}
+
+func renderDashboardTable(table *tablewriter.Table, rows int) {
+	if rows == 0 {
+		fmt.Println("None")
+		return
+	}
+	table.Render()
+}
+
+// progressBar draws the fraction of completed actions, such as
+// "[#####---------------] 25%".
+func progressBar(completed, total int) string {
+	if total == 0 {
+		return "-"
+	}
+
+	filled := progressBarWidth * completed / total
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	return fmt.Sprintf("[%s%s] %.0f%%",
+		strings.Repeat("#", filled),
+		strings.Repeat("-", progressBarWidth-filled),
+		100*float64(completed)/float64(total),
+	)
+}
@@ -0,0 +1,350 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package printer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+type ExportFormat string
+
+var (
+	ExportCSV   ExportFormat = "csv"
+	ExportJSONL ExportFormat = "jsonl"
+)
+
+// ExportTable is a flat table of results to be loaded into external analysis
+// tools. Durations are in seconds, and sizes are in bytes.
+type ExportTable struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Write writes the table as CSV with a header row, or as a JSON object per
+// row keyed by column.
+func (t ExportTable) Write(w io.Writer, format ExportFormat) error {
+	switch format {
+	case ExportCSV:
+		return t.writeCSV(w)
+	case ExportJSONL:
+		return t.writeJSONL(w)
+	default:
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "export format %q is not valid", format)
+	}
+}
+
+func (t ExportTable) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(t.Columns)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			switch v := v.(type) {
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+
+		err = cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (t ExportTable) writeJSONL(w io.Writer) error {
+	for _, row := range t.Rows {
+		// Objects are written field by field to keep the order of columns.
+		line := []byte{'{'}
+		for i, v := range row {
+			if i > 0 {
+				line = append(line, ',')
+			}
+
+			key, err := json.Marshal(t.Columns[i])
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+
+			line = append(line, key...)
+			line = append(line, ':')
+			line = append(line, value...)
+		}
+		line = append(line, '}', '\n')
+
+		_, err := w.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportRow builds a row of an ExportTable along with its columns.
+type exportRow struct {
+	columns []string
+	values  []interface{}
+}
+
+func (r *exportRow) add(column string, value interface{}) {
+	if d, ok := value.(time.Duration); ok {
+		value = d.Seconds()
+	}
+	r.columns = append(r.columns, column)
+	r.values = append(r.values, value)
+}
+
+func (r *exportRow) addHistogram(prefix string, h metadata.ReportHistogram) {
+	r.add(prefix+"_count", h.Count())
+	r.add(prefix+"_min", h.Min)
+	r.add(prefix+"_mean", h.Mean())
+	r.add(prefix+"_p50", h.Percentile(50))
+	r.add(prefix+"_p90", h.Percentile(90))
+	r.add(prefix+"_p95", h.Percentile(95))
+	r.add(prefix+"_p99", h.Percentile(99))
+	r.add(prefix+"_p99.9", h.Percentile(99.9))
+	r.add(prefix+"_max", h.Max)
+}
+
+func (r *exportRow) addOperation(prefix string, op metadata.ReportOperation) {
+	r.add(prefix+"_count", op.Count)
+	r.add(prefix+"_failures", op.Failures)
+	r.addHistogram(prefix+"_time", op.Time)
+}
+
+func (r *exportRow) addBitswap(bs metadata.ReportBitswap) {
+	r.add("bitswap_blocks_received", bs.BlocksReceived)
+	r.add("bitswap_data_received", bs.DataReceived)
+	r.add("bitswap_blocks_sent", bs.BlocksSent)
+	r.add("bitswap_data_sent", bs.DataSent)
+	r.add("bitswap_dup_blocks_received", bs.DupBlksReceived)
+	r.add("bitswap_dup_data_received", bs.DupDataReceived)
+	r.add("bitswap_messages_received", bs.MessagesReceived)
+}
+
+func (r *exportRow) addConnections(conns metadata.ReportConnections) {
+	r.add("connections_peers", conns.Peers)
+	r.add("connections_conns", conns.Conns)
+}
+
+func (r *exportRow) addHostUsage(prefix string, usage metadata.HostUsage) {
+	r.add(prefix+"_cpu", usage.CPU)
+	r.add(prefix+"_memory", usage.Memory)
+	r.add(prefix+"_disk_read", usage.DiskRead)
+	r.add(prefix+"_disk_write", usage.DiskWrite)
+	r.add(prefix+"_network_receive", usage.NetworkReceive)
+	r.add(prefix+"_network_transmit", usage.NetworkTransmit)
+}
+
+// ExportNodes returns a table with a row of metrics for each node of a
+// report.
+func ExportNodes(report metadata.Report) ExportTable {
+	var table ExportTable
+	for _, id := range sortedNodes(report) {
+		node := report.Nodes[id]
+
+		var r exportRow
+		r.add("node", id)
+		r.addBitswap(node.Bitswap)
+		r.add("bandwidth_total_in", node.Bandwidth.Totals.TotalIn)
+		r.add("bandwidth_total_out", node.Bandwidth.Totals.TotalOut)
+		r.add("bandwidth_rate_in", node.Bandwidth.Totals.RateIn)
+		r.add("bandwidth_rate_out", node.Bandwidth.Totals.RateOut)
+		r.add("pubsub_published", node.Pubsub.MessagesPublished)
+		r.add("pubsub_received", node.Pubsub.MessagesReceived)
+		r.add("pubsub_lost", node.Pubsub.MessagesLost)
+		r.addHistogram("pubsub_latency", node.Pubsub.Latency)
+		r.addOperation("dht_provide", node.DHT.Provide)
+		r.addOperation("dht_find_providers", node.DHT.FindProviders)
+		r.addOperation("dht_find_peer", node.DHT.FindPeer)
+		r.add("load_requests", node.Load.Requests)
+		r.add("load_failures", node.Load.Failures)
+		r.add("load_missed", node.Load.Missed)
+		r.add("load_throughput", node.Load.Throughput())
+		r.addHistogram("load_latency", node.Load.Latency)
+		r.add("retrieval_failures", node.Retrieval.Failures)
+		r.addHistogram("retrieval_time", node.Retrieval.Time)
+		r.add("add_files", node.Add.Files)
+		r.add("add_failures", node.Add.Failures)
+		r.add("add_bytes", node.Add.Bytes)
+		r.addHistogram("add_time", node.Add.Time)
+		r.add("gateway_requests", node.Gateway.Requests)
+		r.add("gateway_failures", node.Gateway.Failures)
+		r.add("gateway_bytes", node.Gateway.Bytes)
+		r.addHistogram("gateway_first_byte", node.Gateway.FirstByte)
+		r.addHistogram("gateway_time", node.Gateway.Time)
+		r.add("update_published", node.Update.Published)
+		r.add("update_received", node.Update.Received)
+		r.add("update_lost", node.Update.Lost)
+		r.add("update_failures", node.Update.Failures)
+		r.addHistogram("update_propagation", node.Update.Propagation)
+		r.add("resources_heap_alloc", node.Resources.HeapAlloc)
+		r.add("resources_heap_objects", node.Resources.HeapObjects)
+		r.add("resources_goroutines", node.Resources.Goroutines)
+		r.addHostUsage("host_peak", node.Host.Peak)
+		r.addHostUsage("host_mean", node.Host.Mean)
+		r.addConnections(node.Connections)
+
+		table.Columns = r.columns
+		table.Rows = append(table.Rows, r.values)
+	}
+	return table
+}
+
+// ExportSeries returns a table with a row for each sample of each node's
+// counters taken while the measured stages ran.
+func ExportSeries(report metadata.Report) ExportTable {
+	var ids []string
+	for id := range report.Series {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var table ExportTable
+	for _, id := range ids {
+		for _, sample := range report.Series[id] {
+			var r exportRow
+			r.add("node", id)
+			r.add("elapsed", sample.Elapsed)
+			r.addBitswap(sample.Bitswap)
+			r.add("bandwidth_total_in", sample.Bandwidth.TotalIn)
+			r.add("bandwidth_total_out", sample.Bandwidth.TotalOut)
+			r.add("bandwidth_rate_in", sample.Bandwidth.RateIn)
+			r.add("bandwidth_rate_out", sample.Bandwidth.RateOut)
+			r.addConnections(sample.Connections)
+
+			table.Columns = r.columns
+			table.Rows = append(table.Rows, r.values)
+		}
+	}
+	return table
+}
+
+// ExportHosts returns a table with a row for each sample of each node's host
+// utilization taken while the measured stages ran.
+func ExportHosts(report metadata.Report) ExportTable {
+	var table ExportTable
+	for _, id := range sortedNodes(report) {
+		for _, sample := range report.Nodes[id].Host.Series {
+			var r exportRow
+			r.add("node", id)
+			r.add("elapsed", sample.Elapsed)
+			r.addHostUsage("host", sample.HostUsage)
+
+			table.Columns = r.columns
+			table.Rows = append(table.Rows, r.values)
+		}
+	}
+	return table
+}
+
+// ExportBandwidth returns a table with a row for each pair of nodes that
+// exchanged bytes, from the most bytes sent to the least.
+func ExportBandwidth(report metadata.Report) ExportTable {
+	table := ExportTable{
+		Columns: []string{"node", "peer", "sent", "received"},
+	}
+	for _, pair := range sortedBandwidthPairs(report.Bandwidth) {
+		table.Rows = append(table.Rows, []interface{}{pair.From, pair.To, pair.Sent, pair.Received})
+	}
+	return table
+}
+
+// ExportActions returns a table with a row for each task executed by a node
+// during a benchmark, in the order they started.
+func ExportActions(trace metadata.Trace) ExportTable {
+	table := ExportTable{
+		Columns: []string{"node", "stage", "task", "subject", "offset", "duration", "action"},
+	}
+	for _, event := range trace.Events {
+		table.Rows = append(table.Rows, []interface{}{
+			event.Node,
+			event.Stage,
+			string(event.Task.Type),
+			event.Task.Subject,
+			event.Offset.Seconds(),
+			event.Duration.Seconds(),
+			event.Action,
+		})
+	}
+	return table
+}
+
+// ExportBitswap returns a table with a row for each bitswap message entry
+// sent or received by the nodes of a report that traced bitswap, in the order
+// they occurred on each node. Entries are keyed by the action they were
+// exchanged for, including on the peers that served it.
+func ExportBitswap(report metadata.Report) (ExportTable, error) {
+	table := ExportTable{
+		Columns: []string{"node", "time", "peer", "direction", "type", "cid", "size", "dup", "action"},
+	}
+
+	eventsByNodeId := make(map[string][]metadata.BitswapEvent)
+	peerIdByNodeId := make(map[string]string)
+	for id, reportNode := range report.Nodes {
+		events, err := metadata.DecodeBitswapEvents(reportNode.BitswapTrace.Events)
+		if err != nil {
+			return table, errors.Wrapf(err, "failed to decode bitswap events of node %q", id)
+		}
+		eventsByNodeId[id] = events
+		peerIdByNodeId[id] = reportNode.PeerID
+	}
+	metadata.CorrelateBitswapActions(eventsByNodeId, peerIdByNodeId)
+
+	for _, id := range sortedNodes(report) {
+		for _, event := range eventsByNodeId[id] {
+			direction := "received"
+			if event.Sent {
+				direction = "sent"
+			}
+
+			table.Rows = append(table.Rows, []interface{}{
+				id,
+				event.Time.Format(time.RFC3339Nano),
+				event.Peer,
+				direction,
+				string(event.Type),
+				event.Cid,
+				event.Size,
+				event.Dup,
+				event.Action,
+			})
+		}
+	}
+	return table, nil
+}
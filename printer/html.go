@@ -0,0 +1,570 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/hako/durafmt"
+)
+
+var (
+	HTMLReportTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Benchmark {{.ID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 960px; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ddd; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ddd; padding: 4px 10px; text-align: left; }
+th { background: #f4f4f4; }
+.passed { color: #2a7d2a; }
+.failed { color: #b22222; }
+svg { display: block; margin: 1em 0; }
+svg text { font-size: 11px; fill: #333; }
+</style>
+</head>
+<body>
+<h1>Benchmark {{.ID}}</h1>
+<table>
+<tr><th>Scenario</th><td>{{.Scenario}}</td></tr>
+<tr><th>Cluster</th><td>{{.Cluster}}</td></tr>
+<tr><th>Status</th><td>{{.Status}}</td></tr>
+<tr><th>Created</th><td>{{.CreatedAt}}</td></tr>
+<tr><th>Total time</th><td>{{.TotalTime}}</td></tr>
+{{if .Trials}}<tr><th>Trials</th><td>{{.Trials}} (stddev {{.TotalTimeStdDev}})</td></tr>
+{{end}}<tr><th>Seed</th><td>{{.Seed}}</td></tr>
+{{if .Skipped}}<tr><th>Skipped</th><td>{{.Skipped}}</td></tr>
+{{end}}</table>
+{{if .Stages}}
+<h2>Stages</h2>
+<table>
+<tr><th>Stage</th><th>Time</th></tr>
+{{range .Stages}}<tr><td>{{.Name}}</td><td>{{.Time}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Statistics}}
+<h2>Trials</h2>
+<table>
+<tr><th>Metric</th><th>Mean</th><th>{{.Confidence}} confidence interval</th><th>Outlying trials</th></tr>
+{{range .Statistics}}<tr><td>{{.Metric}}</td><td>{{.Mean}}</td><td>± {{.Interval}}</td><td>{{range $i, $t := .Outliers}}{{if $i}}, {{end}}{{$t}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Expectations}}
+<h2>Expectations</h2>
+<table>
+<tr><th>Expectation</th><th>Actual</th><th>Result</th></tr>
+{{range .Expectations}}<tr><td>{{.Expectation}}</td><td>{{.Actual}}</td>{{if .Passed}}<td class="passed">passed</td>{{else}}<td class="failed">failed</td>{{end}}</tr>
+{{end}}</table>
+{{end}}{{if .LatencyChart}}
+<h2>Latency</h2>
+{{.LatencyChart}}
+{{end}}{{if .SeriesChart}}
+<h2>Throughput</h2>
+{{.SeriesChart}}
+{{end}}{{if .HostCharts}}
+<h2>Hosts</h2>
+{{range .HostCharts}}{{.}}
+{{end}}{{end}}
+<h2>Nodes</h2>
+{{range .NodeCharts}}{{.}}
+{{end}}
+<h2>Topology</h2>
+{{.TopologyChart}}{{if .BandwidthChart}}
+<h2>Peer bandwidth</h2>
+{{.BandwidthChart}}{{end}}
+</body>
+</html>
+`))
+)
+
+type HTMLReportData struct {
+	ID              string
+	Scenario        string
+	Cluster         string
+	Status          string
+	CreatedAt       string
+	TotalTime       string
+	Trials          int
+	TotalTimeStdDev string
+	Seed            int64
+	Skipped         string
+	Stages          []HTMLReportStage
+	Statistics      []metadata.MetricStatistics
+	Confidence      string
+	Expectations    []metadata.ExpectationResult
+	LatencyChart    template.HTML
+	SeriesChart     template.HTML
+	HostCharts      []template.HTML
+	NodeCharts      []template.HTML
+	TopologyChart   template.HTML
+	BandwidthChart  template.HTML
+}
+
+type HTMLReportStage struct {
+	Name string
+	Time string
+}
+
+// WriteHTMLReport renders the report of a benchmark as a standalone HTML page.
+// Its charts are inline SVG, so the page can be viewed without network
+// access.
+func WriteHTMLReport(w io.Writer, benchmark metadata.Benchmark, report metadata.Report) error {
+	data := HTMLReportData{
+		ID:              benchmark.ID,
+		Scenario:        benchmark.Scenario.ID,
+		Cluster:         benchmark.Cluster.ID,
+		Status:          string(benchmark.Status),
+		CreatedAt:       benchmark.CreatedAt.Format(time.RFC1123),
+		TotalTime:       durafmt.Parse(report.Summary.TotalTime).String(),
+		Trials:          len(report.Trials),
+		TotalTimeStdDev: durafmt.Parse(report.Summary.TotalTimeStdDev).String(),
+		Seed:            report.Summary.Seed,
+		Skipped:         strings.Join(report.Summary.Skipped, ", "),
+		Statistics:      report.Statistics.Metrics,
+		Confidence:      fmt.Sprintf("%.0f%%", report.Statistics.Confidence*100),
+		Expectations:    report.Summary.Expectations,
+	}
+
+	for name, d := range report.Summary.Stages {
+		data.Stages = append(data.Stages, HTMLReportStage{name, durafmt.Parse(d).String()})
+	}
+	sort.Slice(data.Stages, func(i, j int) bool {
+		return data.Stages[i].Name < data.Stages[j].Name
+	})
+
+	data.LatencyChart = latencyChart(report)
+	data.SeriesChart = seriesChart(report)
+	data.HostCharts = hostCharts(report)
+	data.NodeCharts = nodeCharts(report)
+	// The connections captured at the end of the benchmark are drawn in
+	// place of the planned topology when the report has them.
+	topology := benchmark.Plan.Topology
+	if len(report.Topology.End) > 0 {
+		topology = make(map[string][]string)
+		for _, edge := range report.Topology.End.Edges() {
+			topology[edge.A] = append(topology[edge.A], edge.B)
+		}
+	}
+	data.TopologyChart = topologyChart(sortedNodes(report), topology)
+	data.BandwidthChart = bandwidthChart(sortedNodes(report), report.Bandwidth)
+
+	return HTMLReportTemplate.Execute(w, &data)
+}
+
+const (
+	chartWidth  = 900
+	chartHeight = 320
+
+	// chartMargin is the space around the plot of a chart for its title,
+	// axes and legend.
+	chartMarginLeft   = 90
+	chartMarginRight  = 180
+	chartMarginTop    = 30
+	chartMarginBottom = 40
+)
+
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+type chartPoint struct {
+	X, Y float64
+}
+
+type chartSeries struct {
+	Name   string
+	Points []chartPoint
+}
+
+// latencyChart plots the cumulative distribution of each latency histogram
+// that has observations, on a logarithmic time axis.
+func latencyChart(report metadata.Report) template.HTML {
+	var series []chartSeries
+	for _, hist := range latencyHistograms(latencyTotals(report)) {
+		count := hist.Histogram.Count()
+		if count == 0 {
+			continue
+		}
+
+		s := chartSeries{Name: hist.Name}
+		var cumulative int64
+		for _, bucket := range hist.Histogram.Distribution() {
+			cumulative += bucket.Count
+			s.Points = append(s.Points, chartPoint{float64(bucket.Upper), float64(cumulative) / float64(count)})
+		}
+		series = append(series, s)
+	}
+	if len(series) == 0 {
+		return ""
+	}
+
+	return lineChart("Latency CDF", series, true, 1, func(x float64) string {
+		return time.Duration(x).Round(time.Millisecond).String()
+	}, func(y float64) string {
+		return fmt.Sprintf("%.0f%%", y*100)
+	})
+}
+
+// seriesChart plots the bitswap throughput of the cluster over time, if the
+// nodes were sampled.
+func seriesChart(report metadata.Report) template.HTML {
+	totals := sumSeries(report)
+	if len(totals) == 0 {
+		return ""
+	}
+
+	s := chartSeries{Name: "data received"}
+	var max float64
+	for _, t := range totals {
+		s.Points = append(s.Points, chartPoint{t.Elapsed.Seconds(), t.Throughput})
+		max = math.Max(max, t.Throughput)
+	}
+
+	return lineChart("Throughput over time", []chartSeries{s}, false, max, func(x float64) string {
+		return (time.Duration(x) * time.Second).String()
+	}, func(y float64) string {
+		return fmt.Sprintf("%s/s", humanize.Bytes(uint64(y)))
+	})
+}
+
+// hostCharts plots the utilization of each node's host over time, if their
+// labagents sampled them.
+func hostCharts(report metadata.Report) []template.HTML {
+	ids := sortedNodes(report)
+	rateFormat := func(y float64) string {
+		return fmt.Sprintf("%s/s", humanize.Bytes(uint64(y)))
+	}
+
+	charts := []struct {
+		title  string
+		value  func(metadata.HostUsage) float64
+		max    float64
+		format func(float64) string
+	}{
+		{"CPU over time", func(u metadata.HostUsage) float64 { return u.CPU }, 1, func(y float64) string {
+			return fmt.Sprintf("%.0f%%", y*100)
+		}},
+		{"Memory over time", func(u metadata.HostUsage) float64 { return float64(u.Memory) }, 0, func(y float64) string {
+			return humanize.Bytes(uint64(y))
+		}},
+		{"Disk IO over time", func(u metadata.HostUsage) float64 { return u.DiskRead + u.DiskWrite }, 0, rateFormat},
+		{"Network over time", func(u metadata.HostUsage) float64 { return u.NetworkReceive + u.NetworkTransmit }, 0, rateFormat},
+	}
+
+	var svgs []template.HTML
+	for _, chart := range charts {
+		var series []chartSeries
+		for _, id := range ids {
+			samples := report.Nodes[id].Host.Series
+			if len(samples) == 0 {
+				continue
+			}
+
+			s := chartSeries{Name: id}
+			for _, sample := range samples {
+				s.Points = append(s.Points, chartPoint{sample.Elapsed.Seconds(), chart.value(sample.HostUsage)})
+			}
+			series = append(series, s)
+		}
+		if len(series) == 0 {
+			return nil
+		}
+
+		svgs = append(svgs, lineChart(chart.title, series, false, chart.max, func(x float64) string {
+			return (time.Duration(x) * time.Second).String()
+		}, chart.format))
+	}
+	return svgs
+}
+
+// nodeCharts returns bar charts comparing the nodes of a report.
+func nodeCharts(report metadata.Report) []template.HTML {
+	ids := sortedNodes(report)
+	bytesFormat := func(v float64) string {
+		return humanize.Bytes(uint64(v))
+	}
+
+	charts := []struct {
+		title  string
+		value  func(metadata.ReportNode) float64
+		format func(float64) string
+	}{
+		{"Data received", func(n metadata.ReportNode) float64 { return float64(n.Bitswap.DataReceived) }, bytesFormat},
+		{"Data sent", func(n metadata.ReportNode) float64 { return float64(n.Bitswap.DataSent) }, bytesFormat},
+		{"Duplicate data received", func(n metadata.ReportNode) float64 { return float64(n.Bitswap.DupDataReceived) }, bytesFormat},
+		{"Retrieval p95", func(n metadata.ReportNode) float64 { return float64(n.Retrieval.Time.Percentile(95)) }, func(v float64) string {
+			return time.Duration(v).Round(time.Millisecond).String()
+		}},
+		{"Peak CPU", func(n metadata.ReportNode) float64 { return n.Host.Peak.CPU }, func(v float64) string {
+			return fmt.Sprintf("%.0f%%", v*100)
+		}},
+		{"Peak memory", func(n metadata.ReportNode) float64 { return float64(n.Host.Peak.Memory) }, bytesFormat},
+	}
+
+	var svgs []template.HTML
+	for _, chart := range charts {
+		values := make([]float64, len(ids))
+		var nonzero bool
+		for i, id := range ids {
+			values[i] = chart.value(report.Nodes[id])
+			nonzero = nonzero || values[i] != 0
+		}
+		if nonzero {
+			svgs = append(svgs, barChart(chart.title, ids, values, chart.format))
+		}
+	}
+	return svgs
+}
+
+// lineChart plots series of points, with x optionally on a logarithmic scale
+// and y from zero to max, or to the largest y if max is zero.
+func lineChart(title string, series []chartSeries, logX bool, max float64, formatX, formatY func(float64) string) template.HTML {
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, p := range s.Points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			if max == 0 {
+				max = p.Y
+			}
+		}
+	}
+	if max <= 0 {
+		max = 1
+	}
+
+	transform := func(x float64) float64 { return x }
+	if logX {
+		transform = math.Log2
+		minX = math.Max(minX/2, 1)
+	}
+	lo, hi := transform(minX), transform(maxX)
+	if hi <= lo {
+		hi = lo + 1
+	}
+
+	plotWidth := float64(chartWidth - chartMarginLeft - chartMarginRight)
+	plotHeight := float64(chartHeight - chartMarginTop - chartMarginBottom)
+	scaleX := func(x float64) float64 {
+		return chartMarginLeft + (transform(x)-lo)/(hi-lo)*plotWidth
+	}
+	scaleY := func(y float64) float64 {
+		return chartMarginTop + plotHeight - y/max*plotHeight
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, chartWidth, chartHeight)
+	fmt.Fprintf(buf, `<text x="%d" y="18" font-weight="bold">%s</text>`, chartMarginLeft, html.EscapeString(title))
+	writeAxes(buf, chartHeight-chartMarginBottom)
+
+	const ticks = 5
+	for i := 0; i <= ticks; i++ {
+		frac := float64(i) / ticks
+		x := chartMarginLeft + frac*plotWidth
+		value := lo + frac*(hi-lo)
+		if logX {
+			value = math.Exp2(value)
+		}
+		fmt.Fprintf(buf, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x, chartHeight-chartMarginBottom+16, html.EscapeString(formatX(value)))
+
+		y := chartMarginTop + plotHeight - frac*plotHeight
+		fmt.Fprintf(buf, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, chartMarginLeft-6, y+4, html.EscapeString(formatY(frac*max)))
+		fmt.Fprintf(buf, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eee"/>`, chartMarginLeft, y, chartWidth-chartMarginRight, y)
+	}
+
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+
+		var points []string
+		for _, p := range s.Points {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", scaleX(p.X), scaleY(p.Y)))
+		}
+		fmt.Fprintf(buf, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(points, " "))
+
+		legendY := chartMarginTop + 16*i
+		fmt.Fprintf(buf, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`, chartWidth-chartMarginRight+16, legendY, color)
+		fmt.Fprintf(buf, `<text x="%d" y="%d">%s</text>`, chartWidth-chartMarginRight+32, legendY+9, html.EscapeString(s.Name))
+	}
+
+	buf.WriteString(`</svg>`)
+	return template.HTML(buf.String())
+}
+
+// barChart plots a horizontal bar for each label.
+func barChart(title string, labels []string, values []float64, format func(float64) string) template.HTML {
+	const barHeight = 18
+
+	var max float64
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	if max <= 0 {
+		max = 1
+	}
+
+	height := chartMarginTop + barHeight*len(labels) + chartMarginBottom
+	plotWidth := float64(chartWidth - chartMarginLeft - chartMarginRight)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, chartWidth, height)
+	fmt.Fprintf(buf, `<text x="%d" y="18" font-weight="bold">%s</text>`, chartMarginLeft, html.EscapeString(title))
+	writeAxes(buf, height-chartMarginBottom)
+
+	for i, label := range labels {
+		y := chartMarginTop + barHeight*i
+		width := values[i] / max * plotWidth
+		fmt.Fprintf(buf, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartMarginLeft-6, y+barHeight-5, html.EscapeString(label))
+		fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`, chartMarginLeft, y+2, width, barHeight-4, chartColors[0])
+		fmt.Fprintf(buf, `<text x="%.1f" y="%d">%s</text>`, chartMarginLeft+width+6, y+barHeight-5, html.EscapeString(format(values[i])))
+	}
+
+	buf.WriteString(`</svg>`)
+	return template.HTML(buf.String())
+}
+
+// writeAxes draws the axes of a chart whose x axis is at y.
+func writeAxes(buf *bytes.Buffer, y int) {
+	fmt.Fprintf(buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, chartMarginLeft, chartMarginTop, chartMarginLeft, y)
+	fmt.Fprintf(buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`, chartMarginLeft, y, chartWidth-chartMarginRight, y)
+}
+
+// topologyChart draws the nodes on a circle connected along the topology of
+// the benchmark, where a nil topology connects every node to every other.
+func topologyChart(ids []string, topology map[string][]string) template.HTML {
+	const (
+		size   = 480
+		radius = 180
+
+		// maxLabels is the most nodes that are labelled, beyond which labels
+		// overlap and are only shown on hover.
+		maxLabels = 32
+	)
+
+	positions := make(map[string]chartPoint)
+	for i, id := range ids {
+		angle := 2*math.Pi*float64(i)/float64(len(ids)) - math.Pi/2
+		positions[id] = chartPoint{size/2 + radius*math.Cos(angle), size/2 + radius*math.Sin(angle)}
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, size, size)
+
+	drawn := make(map[[2]string]bool)
+	edge := func(a, b string) {
+		if b < a {
+			a, b = b, a
+		}
+		if drawn[[2]string{a, b}] {
+			return
+		}
+		drawn[[2]string{a, b}] = true
+
+		pa, okA := positions[a]
+		pb, okB := positions[b]
+		if okA && okB {
+			fmt.Fprintf(buf, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#bbb"/>`, pa.X, pa.Y, pb.X, pb.Y)
+		}
+	}
+	if topology == nil {
+		for i, a := range ids {
+			for _, b := range ids[i+1:] {
+				edge(a, b)
+			}
+		}
+	} else {
+		for _, a := range ids {
+			for _, b := range topology[a] {
+				edge(a, b)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		p := positions[id]
+		fmt.Fprintf(buf, `<circle cx="%.1f" cy="%.1f" r="6" fill="%s"><title>%s</title></circle>`, p.X, p.Y, chartColors[0], html.EscapeString(id))
+		if len(ids) <= maxLabels {
+			fmt.Fprintf(buf, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, p.X, p.Y-10, html.EscapeString(id))
+		}
+	}
+
+	buf.WriteString(`</svg>`)
+	return template.HTML(buf.String())
+}
+
+// bandwidthChart draws the bytes each node sent to each other node as a heat
+// map, with senders as rows and receivers as columns.
+func bandwidthChart(ids []string, matrix metadata.ReportBandwidthMatrix) template.HTML {
+	if len(matrix) == 0 {
+		return ""
+	}
+
+	const (
+		plotSize = 480
+
+		// maxLabels is the most nodes that are labelled, beyond which labels
+		// overlap and are only shown on hover.
+		maxLabels = 32
+	)
+
+	var max int64
+	for _, row := range matrix {
+		for _, bw := range row {
+			if bw.Sent > max {
+				max = bw.Sent
+			}
+		}
+	}
+	if max <= 0 {
+		return ""
+	}
+
+	cell := float64(plotSize) / float64(len(ids))
+	size := chartMarginLeft + plotSize + chartMarginTop
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, size, size)
+	for i, from := range ids {
+		y := float64(chartMarginTop) + cell*float64(i)
+		if len(ids) <= maxLabels {
+			fmt.Fprintf(buf, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, chartMarginLeft-6, y+cell/2+4, html.EscapeString(from))
+		}
+
+		for j, to := range ids {
+			x := float64(chartMarginLeft) + cell*float64(j)
+			bw := matrix[from][to]
+			opacity := float64(bw.Sent) / float64(max)
+			fmt.Fprintf(buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" fill-opacity="%.2f" stroke="#eee"><title>%s to %s: %s</title></rect>`,
+				x, y, cell, cell, chartColors[3], opacity, html.EscapeString(from), html.EscapeString(to), humanize.Bytes(uint64(bw.Sent)))
+		}
+	}
+	if len(ids) <= maxLabels {
+		for j, to := range ids {
+			x := float64(chartMarginLeft) + cell*float64(j) + cell/2
+			fmt.Fprintf(buf, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x, chartMarginTop-6, html.EscapeString(to))
+		}
+	}
+
+	buf.WriteString(`</svg>`)
+	return template.HTML(buf.String())
+}
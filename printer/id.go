@@ -43,8 +43,16 @@ func (p *idPrinter) Print(v interface{}) error {
 		fmt.Printf("%s\n", t.ID)
 	case metadata.Benchmark:
 		fmt.Printf("%s\n", t.ID)
+	case metadata.BenchmarkProgress:
+		fmt.Printf("%s\n", t.ID)
 	case metadata.Experiment:
 		fmt.Printf("%s\n", t.ID)
+	case metadata.LabContext:
+		fmt.Printf("%s\n", t.Name)
+	case metadata.FieldChange:
+		fmt.Printf("%s\n", t.Path)
+	case metadata.Schedule:
+		fmt.Printf("%s\n", t.ID)
 	}
 
 	return nil
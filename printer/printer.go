@@ -31,6 +31,8 @@ var (
 	OutputID    OutputType = "id"
 	OutputUnix  OutputType = "unix"
 	OutputJSON  OutputType = "json"
+	OutputYAML  OutputType = "yaml"
+	OutputWide  OutputType = "wide"
 )
 
 func GetPrinter(output, auto OutputType) (Printer, error) {
@@ -49,6 +51,10 @@ func GetPrinter(output, auto OutputType) (Printer, error) {
 		p = NewUnixPrinter()
 	case OutputJSON:
 		p = NewJSONPrinter()
+	case OutputYAML:
+		p = NewYAMLPrinter()
+	case OutputWide:
+		p = NewWideTablePrinter()
 	default:
 		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "output %q is not valid", output)
 	}
@@ -19,9 +19,12 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/reports"
 	"github.com/alecthomas/template"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/hako/durafmt"
@@ -31,30 +34,180 @@ import (
 var (
 	ReportTemplate = template.Must(template.New("report").Parse(`# Summary
 Total time: {{.TotalTime}}
-Trace: {{.Trace}}
-
+{{if .Trials}}Trials: {{.Trials}} (stddev {{.TotalTimeStdDev}})
+{{end}}Seed: {{.Seed}}
+{{range .Stages}}Stage {{.}}
+{{end}}{{if .Skipped}}Skipped: {{.Skipped}}
+{{end}}Trace: {{.Trace}}
+{{if .TrialsTable}}
+# Trials
+{{.TrialsTable}}{{end}}{{if .Expectations}}
+# Expectations
+{{.Expectations}}{{end}}{{if .LatencyTable}}
+# Latency
+{{.LatencyTable}}{{end}}
 # Bandwidth
-{{.BandwidthTable}}
+{{.BandwidthTable}}{{if .PeerBandwidthTable}}
+# Peer bandwidth
+{{.PeerBandwidthTable}}{{end}}
 # Bitswap
-{{.BitswapTable}}`))
+{{.BitswapTable}}{{if .PubsubTable}}
+# Pubsub
+{{.PubsubTable}}{{end}}{{if .DHTTable}}
+# DHT
+{{.DHTTable}}{{end}}{{if .LoadTable}}
+# Load
+{{.LoadTable}}{{end}}{{if .AddTable}}
+# Add
+{{.AddTable}}{{end}}{{if .GatewayTable}}
+# Gateway
+{{.GatewayTable}}{{end}}{{if .UpdateTable}}
+# Updates
+{{.UpdateTable}}{{end}}{{if .ExecTable}}
+# Exec
+{{.ExecTable}}{{end}}{{if .SoakTable}}
+# Soak
+{{.SoakTable}}{{end}}{{if .HostTable}}
+# Hosts
+{{.HostTable}}{{end}}{{if .SeriesTable}}
+# Series
+{{.SeriesTable}}{{end}}`))
 )
 
 type ReportData struct {
-	TotalTime      string
-	Trace          string
-	BandwidthTable string
-	BitswapTable   string
+	TotalTime          string
+	Stages             []string
+	Skipped            string
+	Trials             int
+	TotalTimeStdDev    string
+	Seed               int64
+	TrialsTable        string
+	Trace              string
+	BandwidthTable     string
+	BitswapTable       string
+	PeerBandwidthTable string
+	Expectations       string
+	LatencyTable       string
+	PubsubTable        string
+	DHTTable           string
+	LoadTable          string
+	AddTable           string
+	GatewayTable       string
+	UpdateTable        string
+	ExecTable          string
+	SoakTable          string
+	HostTable          string
+	SeriesTable        string
 }
 
 func printReport(report metadata.Report) error {
 	bwTable := printReportBandwidth(report)
 	bswapTable := printReportBitswap(report)
 
+	var peerBwTable string
+	if len(report.Bandwidth) > 0 {
+		peerBwTable = printReportPeerBandwidth(report)
+	}
+
+	var trialsTable string
+	if len(report.Statistics.Metrics) > 0 {
+		trialsTable = printReportStatistics(report)
+	}
+
+	var expectations string
+	if len(report.Summary.Expectations) > 0 {
+		expectations = printReportExpectations(report)
+	}
+
+	var psTable string
+	ps := report.Aggregates.Totals.Pubsub
+	if ps.MessagesPublished > 0 || ps.MessagesReceived > 0 || ps.MessagesLost > 0 {
+		psTable = printReportPubsub(report)
+	}
+
+	var dhtTable string
+	dht := report.Aggregates.Totals.DHT
+	if dht.Provide.Count > 0 || dht.FindProviders.Count > 0 || dht.FindPeer.Count > 0 {
+		dhtTable = printReportDHT(report)
+	}
+
+	var loadTable string
+	if report.Aggregates.Totals.Load.Requests > 0 {
+		loadTable = printReportLoad(report)
+	}
+
+	var addTable string
+	if report.Aggregates.Totals.Add.Files > 0 {
+		addTable = printReportAdd(report)
+	}
+
+	var gatewayTable string
+	if report.Aggregates.Totals.Gateway.Requests > 0 {
+		gatewayTable = printReportGateway(report)
+	}
+
+	var updateTable string
+	update := report.Aggregates.Totals.Update
+	if update.Published > 0 || update.Received > 0 || update.Lost > 0 {
+		updateTable = printReportUpdate(report)
+	}
+
+	var execTable string
+	for _, reportNode := range report.Nodes {
+		if len(reportNode.Exec) > 0 {
+			execTable = printReportExec(report)
+			break
+		}
+	}
+
+	var soakTable string
+	if len(report.Snapshots) > 0 {
+		soakTable = printReportSoak(report)
+	}
+
+	var hostTable string
+	for _, reportNode := range report.Nodes {
+		if len(reportNode.Host.Series) > 0 {
+			hostTable = printReportHosts(report)
+			break
+		}
+	}
+
+	var seriesTable string
+	if len(report.Series) > 0 {
+		seriesTable = printReportSeries(report)
+	}
+
+	var stages []string
+	for name, d := range report.Summary.Stages {
+		stages = append(stages, fmt.Sprintf("%s: %s", name, durafmt.Parse(d)))
+	}
+	sort.Strings(stages)
+
 	data := ReportData{
-		TotalTime:      durafmt.Parse(report.Summary.TotalTime).String(),
-		Trace:          report.Summary.Trace,
-		BandwidthTable: bwTable,
-		BitswapTable:   bswapTable,
+		TotalTime:          durafmt.Parse(report.Summary.TotalTime).String(),
+		Stages:             stages,
+		Skipped:            strings.Join(report.Summary.Skipped, ", "),
+		Trials:             len(report.Trials),
+		TotalTimeStdDev:    durafmt.Parse(report.Summary.TotalTimeStdDev).String(),
+		Seed:               report.Summary.Seed,
+		TrialsTable:        trialsTable,
+		Trace:              report.Summary.Trace,
+		BandwidthTable:     bwTable,
+		BitswapTable:       bswapTable,
+		PeerBandwidthTable: peerBwTable,
+		Expectations:       expectations,
+		LatencyTable:       printReportLatency(report),
+		PubsubTable:        psTable,
+		DHTTable:           dhtTable,
+		LoadTable:          loadTable,
+		AddTable:           addTable,
+		GatewayTable:       gatewayTable,
+		UpdateTable:        updateTable,
+		ExecTable:          execTable,
+		SoakTable:          soakTable,
+		HostTable:          hostTable,
+		SeriesTable:        seriesTable,
 	}
 
 	err := ReportTemplate.Execute(os.Stdout, &data)
@@ -65,6 +218,35 @@ func printReport(report metadata.Report) error {
 	return nil
 }
 
+// printReportStatistics prints the mean of each metric across trials with its
+// confidence interval, and the trials that are outliers.
+func printReportStatistics(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"METRIC", "MEAN", "INTERVAL", "RELATIVE", "OUTLIERS"})
+	for _, m := range report.Statistics.Metrics {
+		var outliers []string
+		for _, trial := range m.Outliers {
+			outliers = append(outliers, strconv.Itoa(trial))
+		}
+		table.Append([]string{
+			m.Metric,
+			m.Mean,
+			fmt.Sprintf("± %s", m.Interval),
+			fmt.Sprintf("± %.1f%%", m.RelativeInterval*100),
+			strings.Join(outliers, ","),
+		})
+	}
+	table.SetCaption(true, fmt.Sprintf("%.0f%% confidence intervals across %d trials", report.Statistics.Confidence*100, len(report.Trials)))
+
+	table.Render()
+	return buf.String()
+}
+
 func printReportBandwidth(report metadata.Report) string {
 	buf := new(bytes.Buffer)
 	table := tablewriter.NewWriter(buf)
@@ -103,6 +285,80 @@ func printReportBandwidth(report metadata.Report) string {
 	return buf.String()
 }
 
+// maxBandwidthPairs is the most pairs of nodes printed in the peer bandwidth
+// table.
+const maxBandwidthPairs = 20
+
+// bandwidthPair is the bytes a node sent to another node.
+type bandwidthPair struct {
+	From, To string
+	metadata.ReportPairBandwidth
+}
+
+// sortedBandwidthPairs returns every pair of nodes in a bandwidth matrix,
+// from the most bytes sent to the least.
+func sortedBandwidthPairs(matrix metadata.ReportBandwidthMatrix) []bandwidthPair {
+	var pairs []bandwidthPair
+	for from, row := range matrix {
+		for to, bw := range row {
+			pairs = append(pairs, bandwidthPair{from, to, bw})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Sent != pairs[j].Sent {
+			return pairs[i].Sent > pairs[j].Sent
+		}
+		if pairs[i].From != pairs[j].From {
+			return pairs[i].From < pairs[j].From
+		}
+		return pairs[i].To < pairs[j].To
+	})
+	return pairs
+}
+
+// printReportPeerBandwidth prints the pairs of nodes that exchanged the most
+// bytes, with their share of the bytes sent between all nodes, so that hot
+// spots stand out.
+func printReportPeerBandwidth(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"FROM", "TO", "SENT", "RECEIVED", "SHARE"})
+
+	pairs := sortedBandwidthPairs(report.Bandwidth)
+	var total int64
+	for _, pair := range pairs {
+		total += pair.Sent
+	}
+
+	for i, pair := range pairs {
+		if i == maxBandwidthPairs {
+			break
+		}
+
+		var share float64
+		if total > 0 {
+			share = float64(pair.Sent) / float64(total)
+		}
+		table.Append([]string{
+			pair.From,
+			pair.To,
+			humanize.Bytes(uint64(pair.Sent)),
+			humanize.Bytes(uint64(pair.Received)),
+			fmt.Sprintf("%.1f%%", share*100),
+		})
+	}
+	if len(pairs) > maxBandwidthPairs {
+		table.SetCaption(true, fmt.Sprintf("%d of %d pairs, export all with --data bandwidth", maxBandwidthPairs, len(pairs)))
+	}
+
+	table.Render()
+	return buf.String()
+}
+
 func printReportBitswap(report metadata.Report) string {
 	buf := new(bytes.Buffer)
 	table := tablewriter.NewWriter(buf)
@@ -146,6 +402,516 @@ func printReportBitswap(report metadata.Report) string {
 	return buf.String()
 }
 
+// latencyHistogram is a latency distribution of one kind of operation.
+type latencyHistogram struct {
+	Name      string
+	Histogram metadata.ReportHistogram
+}
+
+// latencyHistograms returns the latency distribution of each kind of
+// operation.
+func latencyHistograms(totals metadata.ReportNode) []latencyHistogram {
+	return []latencyHistogram{
+		{"retrieval", totals.Retrieval.Time},
+		{"load", totals.Load.Latency},
+		{"add", totals.Add.Time},
+		{"gateway first byte", totals.Gateway.FirstByte},
+		{"gateway", totals.Gateway.Time},
+		{"update propagation", totals.Update.Propagation},
+		{"pubsub", totals.Pubsub.Latency},
+		{"dht provide", totals.DHT.Provide.Time},
+		{"dht find providers", totals.DHT.FindProviders.Time},
+		{"dht find peer", totals.DHT.FindPeer.Time},
+	}
+}
+
+// latencyTotals returns the totals of every trial merged, so that latency
+// percentiles cover all the trials rather than only the last one.
+func latencyTotals(report metadata.Report) metadata.ReportNode {
+	if len(report.Trials) > 0 {
+		return reports.MergeTrials(report.Trials).Totals
+	}
+	return report.Aggregates.Totals
+}
+
+// printReportLatency prints the percentiles of each kind of operation that
+// was measured, or nothing if none were.
+func printReportLatency(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"OPERATION", "COUNT", "MIN", "MEAN", "P50", "P90", "P99", "P99.9", "MAX"})
+
+	var rows int
+	for _, hist := range latencyHistograms(latencyTotals(report)) {
+		h := hist.Histogram
+		count := h.Count()
+		if count == 0 {
+			continue
+		}
+
+		table.Append([]string{
+			hist.Name,
+			humanize.Comma(count),
+			h.Min.String(),
+			h.Mean().String(),
+			h.Percentile(50).String(),
+			h.Percentile(90).String(),
+			h.Percentile(99).String(),
+			h.Percentile(99.9).String(),
+			h.Max.String(),
+		})
+		rows++
+	}
+	if rows == 0 {
+		return ""
+	}
+
+	table.Render()
+	return buf.String()
+}
+
+func printReportExpectations(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"EXPECTATION", "ACTUAL", "RESULT"})
+
+	for _, result := range report.Summary.Expectations {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		table.Append([]string{result.Expectation, result.Actual, status})
+	}
+
+	table.Render()
+	return buf.String()
+}
+
+func printReportPubsub(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"QUERY", "NODE", "PUBLISHED", "RECEIVED", "LOST", "MEANLATENCY", "P99LATENCY", "MAXLATENCY"})
+
+	qryBuckets, nodeIdsByQryBucket := sortQueryBuckets(report)
+	for _, qryBucket := range qryBuckets {
+		for _, nodeId := range nodeIdsByQryBucket[qryBucket] {
+			ps := report.Nodes[nodeId].Pubsub
+			table.Append([]string{
+				qryBucket,
+				nodeId,
+				humanize.Comma(ps.MessagesPublished),
+				humanize.Comma(ps.MessagesReceived),
+				humanize.Comma(ps.MessagesLost),
+				ps.Latency.Mean().String(),
+				ps.Latency.Percentile(99).String(),
+				ps.Latency.Max.String(),
+			})
+		}
+	}
+
+	ps := report.Aggregates.Totals.Pubsub
+	table.SetFooter([]string{
+		"",
+		"TOTAL",
+		humanize.Comma(ps.MessagesPublished),
+		humanize.Comma(ps.MessagesReceived),
+		humanize.Comma(ps.MessagesLost),
+		ps.Latency.Mean().String(),
+		ps.Latency.Percentile(99).String(),
+		ps.Latency.Max.String(),
+	})
+
+	table.Render()
+	return buf.String()
+}
+
+func printReportDHT(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"QUERY", "NODE", "PROVIDE", "FINDPROVS", "FINDPEER", "FAILURES"})
+
+	qryBuckets, nodeIdsByQryBucket := sortQueryBuckets(report)
+	for _, qryBucket := range qryBuckets {
+		for _, nodeId := range nodeIdsByQryBucket[qryBucket] {
+			table.Append(append([]string{qryBucket, nodeId}, dhtColumns(report.Nodes[nodeId].DHT)...))
+		}
+	}
+
+	table.SetFooter(append([]string{"", "TOTAL"}, dhtColumns(report.Aggregates.Totals.DHT)...))
+
+	table.Render()
+	return buf.String()
+}
+
+// dhtColumns returns the count and mean time of each DHT operation, followed
+// by the total failures.
+func dhtColumns(dht metadata.ReportDHT) []string {
+	var (
+		columns  []string
+		failures int64
+	)
+	for _, op := range []metadata.ReportOperation{dht.Provide, dht.FindProviders, dht.FindPeer} {
+		columns = append(columns, fmt.Sprintf("%s (%s)", humanize.Comma(op.Count), op.Time.Mean()))
+		failures += op.Failures
+	}
+	return append(columns, humanize.Comma(failures))
+}
+
+func printReportLoad(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"QUERY", "NODE", "REQUESTS", "FAILURES", "MISSED", "THROUGHPUT", "MEAN", "P50", "P95", "P99", "MAX"})
+
+	qryBuckets, nodeIdsByQryBucket := sortQueryBuckets(report)
+	for _, qryBucket := range qryBuckets {
+		for _, nodeId := range nodeIdsByQryBucket[qryBucket] {
+			table.Append(append([]string{qryBucket, nodeId}, loadColumns(report.Nodes[nodeId].Load)...))
+		}
+	}
+
+	table.SetFooter(append([]string{"", "TOTAL"}, loadColumns(report.Aggregates.Totals.Load)...))
+
+	table.Render()
+	return buf.String()
+}
+
+func loadColumns(load metadata.ReportLoad) []string {
+	return []string{
+		humanize.Comma(load.Requests),
+		humanize.Comma(load.Failures),
+		humanize.Comma(load.Missed),
+		fmt.Sprintf("%.1f/s", load.Throughput()),
+		load.Latency.Mean().String(),
+		load.Latency.Percentile(50).String(),
+		load.Latency.Percentile(95).String(),
+		load.Latency.Percentile(99).String(),
+		load.Latency.Max.String(),
+	}
+}
+
+func printReportAdd(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"QUERY", "NODE", "FILES", "FAILURES", "TOTAL SIZE", "THROUGHPUT", "MEAN", "P95", "MAX"})
+
+	qryBuckets, nodeIdsByQryBucket := sortQueryBuckets(report)
+	for _, qryBucket := range qryBuckets {
+		for _, nodeId := range nodeIdsByQryBucket[qryBucket] {
+			table.Append(append([]string{qryBucket, nodeId}, addColumns(report.Nodes[nodeId].Add)...))
+		}
+	}
+
+	table.SetFooter(append([]string{"", "TOTAL"}, addColumns(report.Aggregates.Totals.Add)...))
+
+	table.Render()
+	return buf.String()
+}
+
+func addColumns(add metadata.ReportAdd) []string {
+	return []string{
+		humanize.Comma(add.Files),
+		humanize.Comma(add.Failures),
+		humanize.Bytes(uint64(add.Bytes)),
+		fmt.Sprintf("%s/s", humanize.Bytes(uint64(add.Throughput()))),
+		add.Time.Mean().String(),
+		add.Time.Percentile(95).String(),
+		add.Time.Max.String(),
+	}
+}
+
+func printReportGateway(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"QUERY", "NODE", "REQUESTS", "FAILURES", "DATA RECEIVED", "FIRST BYTE P50", "FIRST BYTE P95", "TIME MEAN", "TIME MAX"})
+
+	qryBuckets, nodeIdsByQryBucket := sortQueryBuckets(report)
+	for _, qryBucket := range qryBuckets {
+		for _, nodeId := range nodeIdsByQryBucket[qryBucket] {
+			table.Append(append([]string{qryBucket, nodeId}, gatewayColumns(report.Nodes[nodeId].Gateway)...))
+		}
+	}
+
+	table.SetFooter(append([]string{"", "TOTAL"}, gatewayColumns(report.Aggregates.Totals.Gateway)...))
+
+	table.Render()
+	return buf.String()
+}
+
+func gatewayColumns(gateway metadata.ReportGateway) []string {
+	return []string{
+		humanize.Comma(gateway.Requests),
+		humanize.Comma(gateway.Failures),
+		humanize.Bytes(uint64(gateway.Bytes)),
+		gateway.FirstByte.Percentile(50).String(),
+		gateway.FirstByte.Percentile(95).String(),
+		gateway.Time.Mean().String(),
+		gateway.Time.Max.String(),
+	}
+}
+
+func printReportUpdate(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"QUERY", "NODE", "PUBLISHED", "RECEIVED", "LOST", "FAILURES", "MEAN", "P50", "P95", "MAX"})
+
+	qryBuckets, nodeIdsByQryBucket := sortQueryBuckets(report)
+	for _, qryBucket := range qryBuckets {
+		for _, nodeId := range nodeIdsByQryBucket[qryBucket] {
+			table.Append(append([]string{qryBucket, nodeId}, updateColumns(report.Nodes[nodeId].Update)...))
+		}
+	}
+
+	table.SetFooter(append([]string{"", "TOTAL"}, updateColumns(report.Aggregates.Totals.Update)...))
+
+	table.Render()
+	return buf.String()
+}
+
+// updateColumns returns the update counts followed by the distribution of
+// their propagation time.
+func updateColumns(update metadata.ReportUpdate) []string {
+	return []string{
+		humanize.Comma(update.Published),
+		humanize.Comma(update.Received),
+		humanize.Comma(update.Lost),
+		humanize.Comma(update.Failures),
+		update.Propagation.Mean().String(),
+		update.Propagation.Percentile(50).String(),
+		update.Propagation.Percentile(95).String(),
+		update.Propagation.Max.String(),
+	}
+}
+
+// printReportExec prints a row per command run by each node, with the last
+// line of its output since the full output is kept in the report.
+func printReportExec(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"QUERY", "NODE", "COMMAND", "EXIT CODE", "TIME", "OUTPUT"})
+
+	qryBuckets, nodeIdsByQryBucket := sortQueryBuckets(report)
+	for _, qryBucket := range qryBuckets {
+		for _, nodeId := range nodeIdsByQryBucket[qryBucket] {
+			for _, exec := range report.Nodes[nodeId].Exec {
+				lines := strings.Split(strings.TrimSpace(exec.Stdout), "\n")
+				table.Append([]string{
+					qryBucket,
+					nodeId,
+					strings.Join(append([]string{exec.Command}, exec.Args...), " "),
+					strconv.Itoa(exec.ExitCode),
+					exec.Time.String(),
+					lines[len(lines)-1],
+				})
+			}
+		}
+	}
+
+	table.Render()
+	return buf.String()
+}
+
+// printReportSoak prints a row per snapshot, where the throughput is of the
+// data received since the previous snapshot so that degradation over time
+// stands out.
+func printReportSoak(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"ELAPSED", "ITERATIONS", "DATA RECEIVED", "THROUGHPUT", "RETRIEVAL P95", "HEAP", "HEAP OBJECTS", "GOROUTINES"})
+
+	var prev metadata.ReportSnapshot
+	for _, snapshot := range report.Snapshots {
+		totals := snapshot.Aggregates.Totals
+
+		var throughput float64
+		elapsed := snapshot.Elapsed - prev.Elapsed
+		if elapsed > 0 {
+			received := totals.Bitswap.DataReceived - prev.Aggregates.Totals.Bitswap.DataReceived
+			throughput = float64(received) / elapsed.Seconds()
+		}
+
+		table.Append([]string{
+			durafmt.Parse(snapshot.Elapsed.Round(time.Second)).String(),
+			strconv.Itoa(snapshot.Iterations),
+			humanize.Bytes(totals.Bitswap.DataReceived),
+			fmt.Sprintf("%s/s", humanize.Bytes(uint64(throughput))),
+			totals.Retrieval.Time.Percentile(95).String(),
+			humanize.Bytes(totals.Resources.HeapAlloc),
+			humanize.Comma(int64(totals.Resources.HeapObjects)),
+			humanize.Comma(totals.Resources.Goroutines),
+		})
+		prev = snapshot
+	}
+
+	table.Render()
+	return buf.String()
+}
+
+// printReportHosts prints a row per node with the peak and mean utilization
+// of its host while the measured stages ran.
+func printReportHosts(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(true)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"QUERY", "NODE", "", "CPU", "MEMORY", "DISK READ", "DISK WRITE", "NETWORK IN", "NETWORK OUT"})
+
+	qryBuckets, nodeIdsByQryBucket := sortQueryBuckets(report)
+	for _, qryBucket := range qryBuckets {
+		for _, nodeId := range nodeIdsByQryBucket[qryBucket] {
+			host := report.Nodes[nodeId].Host
+			table.Append(append([]string{qryBucket, nodeId, "peak"}, hostColumns(host.Peak)...))
+			table.Append(append([]string{qryBucket, nodeId, "mean"}, hostColumns(host.Mean)...))
+		}
+	}
+
+	table.Render()
+	return buf.String()
+}
+
+func hostColumns(usage metadata.HostUsage) []string {
+	rate := func(v float64) string {
+		return fmt.Sprintf("%s/s", humanize.Bytes(uint64(v)))
+	}
+	return []string{
+		fmt.Sprintf("%.0f%%", usage.CPU*100),
+		humanize.Bytes(usage.Memory),
+		rate(usage.DiskRead),
+		rate(usage.DiskWrite),
+		rate(usage.NetworkReceive),
+		rate(usage.NetworkTransmit),
+	}
+}
+
+// seriesTotal is the sum of the samples of every node taken at the same time.
+type seriesTotal struct {
+	Elapsed time.Duration
+	Nodes   int
+	Sample  metadata.ReportSample
+
+	// Throughput is the bytes received by bitswap per second since the
+	// previous total.
+	Throughput float64
+}
+
+// sumSeries sums the samples of every node by when they were taken, in the
+// order they were taken.
+func sumSeries(report metadata.Report) []seriesTotal {
+	totalsByElapsed := make(map[time.Duration]*seriesTotal)
+	for _, samples := range report.Series {
+		for _, sample := range samples {
+			t, ok := totalsByElapsed[sample.Elapsed]
+			if !ok {
+				t = &seriesTotal{Elapsed: sample.Elapsed}
+				totalsByElapsed[sample.Elapsed] = t
+			}
+			t.Nodes++
+			t.Sample.Bitswap.BlocksReceived += sample.Bitswap.BlocksReceived
+			t.Sample.Bitswap.DataReceived += sample.Bitswap.DataReceived
+			t.Sample.Bandwidth.TotalIn += sample.Bandwidth.TotalIn
+			t.Sample.Bandwidth.TotalOut += sample.Bandwidth.TotalOut
+			t.Sample.Connections.Peers += sample.Connections.Peers
+			t.Sample.Connections.Conns += sample.Connections.Conns
+		}
+	}
+
+	var totals []seriesTotal
+	for _, t := range totalsByElapsed {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Elapsed < totals[j].Elapsed
+	})
+
+	var prev seriesTotal
+	for i, t := range totals {
+		received := t.Sample.Bitswap.DataReceived
+		if t.Elapsed > prev.Elapsed && received >= prev.Sample.Bitswap.DataReceived {
+			totals[i].Throughput = float64(received-prev.Sample.Bitswap.DataReceived) / (t.Elapsed - prev.Elapsed).Seconds()
+		}
+		prev = t
+	}
+	return totals
+}
+
+// printReportSeries prints the samples of every node summed by when they were
+// taken, with the throughput since the previous sample.
+func printReportSeries(report metadata.Report) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAutoFormatHeaders(false)
+	table.SetRowLine(true)
+
+	table.SetHeader([]string{"ELAPSED", "NODES", "BLOCKS RECEIVED", "DATA RECEIVED", "THROUGHPUT", "TOTALIN", "TOTALOUT", "PEERS", "CONNECTIONS"})
+
+	for _, t := range sumSeries(report) {
+		table.Append([]string{
+			durafmt.Parse(t.Elapsed.Round(time.Second)).String(),
+			strconv.Itoa(t.Nodes),
+			humanize.Comma(int64(t.Sample.Bitswap.BlocksReceived)),
+			humanize.Bytes(t.Sample.Bitswap.DataReceived),
+			fmt.Sprintf("%s/s", humanize.Bytes(uint64(t.Throughput))),
+			humanize.Bytes(uint64(t.Sample.Bandwidth.TotalIn)),
+			humanize.Bytes(uint64(t.Sample.Bandwidth.TotalOut)),
+			humanize.Comma(t.Sample.Connections.Peers),
+			humanize.Comma(t.Sample.Connections.Conns),
+		})
+	}
+
+	table.Render()
+	return buf.String()
+}
+
 func sortQueryBuckets(report metadata.Report) (qryBuckets []string, nodeIdsByQryBucket map[string][]string) {
 	queriesByNodeId := make(map[string][]string)
 	for qry, nodeIds := range report.Queries {
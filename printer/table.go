@@ -17,20 +17,30 @@ package printer
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Netflix/p2plab/metadata"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/olekukonko/tablewriter"
 )
 
-type tablePrinter struct{}
+type tablePrinter struct {
+	// wide adds columns for the details of clusters, nodes, scenarios and
+	// benchmarks that are too long for most terminals.
+	wide bool
+}
 
 func NewTablePrinter() Printer {
 	return &tablePrinter{}
 }
 
+func NewWideTablePrinter() Printer {
+	return &tablePrinter{wide: true}
+}
+
 func (p *tablePrinter) Print(v interface{}) error {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetAutoFormatHeaders(false)
@@ -48,6 +58,86 @@ func (p *tablePrinter) Print(v interface{}) error {
 		}
 	case metadata.Report:
 		return printReport(t)
+	case metadata.Dashboard:
+		return printDashboard(t)
+	case metadata.ReportComparison:
+		table.SetHeader([]string{"METRIC", "BASE", "HEAD", "DELTA", "CHANGE", "P-VALUE", "REGRESSION"})
+		for _, m := range t.Metrics {
+			regression := ""
+			if m.Regression {
+				regression = "yes"
+			}
+			table.Append([]string{
+				m.Metric,
+				m.Base,
+				m.Head,
+				m.Delta,
+				fmt.Sprintf("%+.1f%%", m.Change*100),
+				fmt.Sprintf("%.3f", m.PValue),
+				regression,
+			})
+		}
+	case metadata.ReportDiff:
+		header := []string{"METRIC", "BASE", "HEAD", "DELTA", "CHANGE", "REGRESSION"}
+		if t.By == metadata.DiffByNode {
+			header = append([]string{"KEY", "BASE NODE", "HEAD NODE"}, header...)
+		} else {
+			header = append([]string{strings.ToUpper(string(t.By))}, header...)
+		}
+		table.SetHeader(header)
+		for _, entry := range t.Entries {
+			for _, m := range entry.Metrics {
+				change := "-"
+				if m.Change != 0 {
+					change = fmt.Sprintf("%+.1f%%", m.Change*100)
+				}
+				regression := ""
+				if m.Regression {
+					regression = "yes"
+				}
+
+				row := []string{entry.Key}
+				if t.By == metadata.DiffByNode {
+					row = append(row, entry.BaseNode, entry.HeadNode)
+				}
+				table.Append(append(row, m.Metric, m.Base, m.Head, m.Delta, change, regression))
+			}
+		}
+		if unmatched := len(t.UnmatchedBase) + len(t.UnmatchedHead); unmatched > 0 {
+			table.SetCaption(true, fmt.Sprintf("%d unmatched in base, %d unmatched in head", len(t.UnmatchedBase), len(t.UnmatchedHead)))
+		}
+	case metadata.ExperimentPivot:
+		// The header's first cell names the row and column variables, such as
+		// "objectSize \ clusterSize".
+		corner := t.Row
+		if t.Column != "" {
+			corner = fmt.Sprintf("%s \\ %s", t.Row, t.Column)
+		}
+		table.SetHeader(append([]string{corner}, t.Columns...))
+		for i, row := range t.Rows {
+			table.Append(append([]string{row}, t.Cells[i]...))
+		}
+		table.SetCaption(true, t.Metric)
+	case metadata.QueryExplanation:
+		table.SetHeader([]string{"QUERY", "MATCHES", "NODES"})
+		appendExplanation(table, t, 0)
+	case metadata.CostReport:
+		table.SetHeader([]string{"CLUSTER", "HOURLY", "ACCRUED"})
+		var ids []string
+		for id := range t.Clusters {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			cost := t.Clusters[id]
+			table.Append([]string{
+				id,
+				fmt.Sprintf("$%.4f", cost.Hourly),
+				fmt.Sprintf("$%.2f", cost.Accrued),
+			})
+		}
+		table.SetFooter([]string{"TOTAL", fmt.Sprintf("$%.4f", t.Hourly), fmt.Sprintf("$%.2f", t.Accrued)})
 	default:
 		p.addHeader(table, t)
 		p.addRow(table, t)
@@ -60,61 +150,155 @@ func (p *tablePrinter) Print(v interface{}) error {
 func (p *tablePrinter) addHeader(table *tablewriter.Table, v interface{}) {
 	switch v.(type) {
 	case metadata.Cluster:
-		table.SetHeader([]string{"ID", "STATUS", "SIZE", "LABELS", "CREATEDAT", "UPDATEDAT"})
+		header := []string{"ID", "STATUS", "SIZE", "LABELS", "CREATEDAT", "UPDATEDAT"}
+		if p.wide {
+			header = append(header, "GROUPS")
+		}
+		table.SetHeader(header)
 	case metadata.Node:
-		table.SetHeader([]string{"ID", "ADDRESS", "GITREFERENCE", "LABELS", "CREATEDAT", "UPDATEDAT"})
+		header := []string{"ID", "ADDRESS", "GITREFERENCE", "LABELS", "CREATEDAT", "UPDATEDAT"}
+		if p.wide {
+			header = append(header, "REGION", "INSTANCETYPE", "ARCH")
+		}
+		table.SetHeader(header)
 	case metadata.Scenario:
-		table.SetHeader([]string{"ID", "LABELS", "CREATEDAT", "UPDATEDAT"})
+		header := []string{"ID", "LABELS", "CREATEDAT", "UPDATEDAT"}
+		if p.wide {
+			header = append(header, "BASELINE")
+		}
+		table.SetHeader(header)
 	case metadata.Benchmark:
-		table.SetHeader([]string{"ID", "STATUS", "CLUSTER", "SCENARIO", "LABELS", "CREATEDAT", "UPDATEDAT"})
+		header := []string{"ID", "STATUS", "CLUSTER", "SCENARIO", "REGRESSIONS", "LABELS", "CREATEDAT", "UPDATEDAT"}
+		if p.wide {
+			header = append(header, "BASELINE", "PUBLISHED")
+		}
+		table.SetHeader(header)
+	case metadata.BenchmarkView:
+		table.SetHeader([]string{"NAME", "QUERY", "LIMIT"})
+	case metadata.LabContext:
+		table.SetHeader([]string{"CURRENT", "NAME", "ADDRESS", "TOKEN"})
 	case metadata.Experiment:
-		table.SetHeader([]string{"ID", "STATUS", "LABELS", "CREATEDAT", "UPDATEDAT"})
+		table.SetHeader([]string{"ID", "STATUS", "TRIALS", "LABELS", "CREATEDAT", "UPDATEDAT"})
 	case metadata.Build:
 		table.SetHeader([]string{"ID", "LINK", "CREATEDAT", "UPDATEDAT"})
+	case metadata.BenchmarkProgress:
+		table.SetHeader([]string{"ID", "STATUS", "TRIAL", "STAGES", "ACTIONS", "RUNNING", "ELAPSED", "ETA"})
+	case metadata.Schedule:
+		table.SetHeader([]string{"ID", "CLUSTER", "SCENARIO", "CRON", "BENCHMARKS", "LASTRUN", "NEXTRUN"})
+	case metadata.Diagnostic:
+		table.SetHeader([]string{"SEVERITY", "LINE", "PATH", "MESSAGE"})
+	case metadata.FieldChange:
+		table.SetHeader([]string{"CHANGE", "PATH", "OLD", "NEW"})
 	}
 }
 
 func (p *tablePrinter) addRow(table *tablewriter.Table, v interface{}) {
 	switch t := v.(type) {
 	case metadata.Cluster:
-		table.Append([]string{
+		row := []string{
 			t.ID,
 			string(t.Status),
 			strconv.Itoa(t.Definition.Size()),
 			strings.Join(t.Labels, ","),
 			humanize.Time(t.CreatedAt),
 			humanize.Time(t.UpdatedAt),
-		})
+		}
+		if p.wide {
+			var groups []string
+			for _, g := range t.Definition.Groups {
+				groups = append(groups, fmt.Sprintf("%dx %s (%s)", g.Size, g.InstanceType, g.Region))
+			}
+			row = append(row, strings.Join(groups, ","))
+		}
+		table.Append(row)
 	case metadata.Node:
-		table.Append([]string{
+		row := []string{
 			t.ID,
 			t.Address,
 			t.Peer.GitReference,
 			strings.Join(t.Labels, ","),
 			humanize.Time(t.CreatedAt),
 			humanize.Time(t.UpdatedAt),
-		})
+		}
+		if p.wide {
+			row = append(row, t.Region, t.InstanceType, t.Arch)
+		}
+		table.Append(row)
 	case metadata.Scenario:
-		table.Append([]string{
+		row := []string{
 			t.ID,
 			strings.Join(t.Labels, ","),
 			humanize.Time(t.CreatedAt),
 			humanize.Time(t.UpdatedAt),
-		})
+		}
+		if p.wide {
+			baseline := "-"
+			if t.Baseline != "" {
+				baseline = t.Baseline
+			}
+			row = append(row, baseline)
+		}
+		table.Append(row)
 	case metadata.Benchmark:
-		table.Append([]string{
+		// Regressions are only known for benchmarks of a scenario with a
+		// pinned baseline.
+		regressions := "-"
+		if t.Baseline != "" {
+			regressions = strconv.Itoa(len(t.Regressions))
+		}
+		row := []string{
 			t.ID,
 			string(t.Status),
 			t.Cluster.ID,
 			t.Scenario.ID,
+			regressions,
 			strings.Join(t.Labels, ","),
 			humanize.Time(t.CreatedAt),
 			humanize.Time(t.UpdatedAt),
+		}
+		if p.wide {
+			var published []string
+			for name := range t.Published {
+				published = append(published, name)
+			}
+			sort.Strings(published)
+
+			baseline := "-"
+			if t.Baseline != "" {
+				baseline = t.Baseline
+			}
+			row = append(row, baseline, strings.Join(published, ","))
+		}
+		table.Append(row)
+	case metadata.BenchmarkView:
+		limit := "-"
+		if t.Limit > 0 {
+			limit = strconv.Itoa(t.Limit)
+		}
+		table.Append([]string{
+			t.Name,
+			t.Query,
+			limit,
+		})
+	case metadata.LabContext:
+		current, token := "", ""
+		if t.Current {
+			current = "*"
+		}
+		if t.Token != "" {
+			token = "yes"
+		}
+		table.Append([]string{
+			current,
+			t.Name,
+			t.Address,
+			token,
 		})
 	case metadata.Experiment:
 		table.Append([]string{
 			t.ID,
 			string(t.Status),
+			strconv.Itoa(len(t.Trials)),
 			strings.Join(t.Labels, ","),
 			humanize.Time(t.CreatedAt),
 			humanize.Time(t.UpdatedAt),
@@ -126,5 +310,78 @@ func (p *tablePrinter) addRow(table *tablewriter.Table, v interface{}) {
 			humanize.Time(t.CreatedAt),
 			humanize.Time(t.UpdatedAt),
 		})
+	case metadata.BenchmarkProgress:
+		trial, stages, actions := "-", "-", "-"
+		if t.Trials > 0 && t.Trial > 0 {
+			trial = fmt.Sprintf("%d/%d", t.Trial, t.Trials)
+		}
+		if t.StagesTotal > 0 {
+			stages = fmt.Sprintf("%d/%d", t.StagesCompleted, t.StagesTotal)
+		}
+		if t.ActionsTotal > 0 {
+			actions = fmt.Sprintf("%d/%d (%.0f%%)", t.ActionsCompleted, t.ActionsTotal, 100*float64(t.ActionsCompleted)/float64(t.ActionsTotal))
+		}
+		eta := "unknown"
+		switch {
+		case t.Done():
+			eta = "completed"
+		case !t.ETA.IsZero():
+			eta = fmt.Sprintf("%s (%s)", t.ETA.Format(time.Kitchen), humanize.Time(t.ETA))
+		}
+		table.Append([]string{
+			t.ID,
+			string(t.Status),
+			trial,
+			stages,
+			actions,
+			strings.Join(t.Stages, ","),
+			t.Elapsed.Round(time.Second).String(),
+			eta,
+		})
+	case metadata.Schedule:
+		lastRun := "never"
+		if !t.LastRun.IsZero() {
+			lastRun = humanize.Time(t.LastRun)
+		}
+		table.Append([]string{
+			t.ID,
+			t.Definition.Cluster,
+			t.Definition.Scenario,
+			t.Definition.Cron,
+			strconv.Itoa(len(t.Benchmarks)),
+			lastRun,
+			humanize.Time(t.Next()),
+		})
+	case metadata.Diagnostic:
+		line := "-"
+		if t.Line > 0 {
+			line = strconv.Itoa(t.Line)
+		}
+		table.Append([]string{
+			string(t.Severity),
+			line,
+			t.Path,
+			t.Message,
+		})
+	case metadata.FieldChange:
+		table.Append([]string{
+			string(t.Kind),
+			t.Path,
+			t.Old,
+			t.New,
+		})
+	}
+}
+
+// appendExplanation appends a row for a query and then its subqueries,
+// indented by their depth in the query's syntax tree.
+func appendExplanation(table *tablewriter.Table, e metadata.QueryExplanation, depth int) {
+	table.Append([]string{
+		strings.Repeat("  ", depth) + e.Query,
+		strconv.Itoa(len(e.Matches)),
+		strings.Join(e.Matches, ","),
+	})
+	for _, se := range e.Subqueries {
+		appendExplanation(table, se, depth+1)
 	}
 }
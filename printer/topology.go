@@ -0,0 +1,92 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package printer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// WriteTopologyDOT writes the connection graph of a report as a Graphviz
+// graph labelled with the transports of each connection. When both snapshots
+// were captured, connections opened during the benchmark are green and those
+// closed during it are dashed and red.
+func WriteTopologyDOT(w io.Writer, topology metadata.ReportTopology) error {
+	ids := make(map[string]struct{})
+	for id := range topology.Start {
+		ids[id] = struct{}{}
+	}
+	for id := range topology.End {
+		ids[id] = struct{}{}
+	}
+	var sorted []string
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	type pair [2]string
+	start := make(map[pair]metadata.TopologyEdge)
+	for _, edge := range topology.Start.Edges() {
+		start[pair{edge.A, edge.B}] = edge
+	}
+	end := make(map[pair]metadata.TopologyEdge)
+	for _, edge := range topology.End.Edges() {
+		end[pair{edge.A, edge.B}] = edge
+	}
+
+	var edges []metadata.TopologyEdge
+	for key, edge := range start {
+		if _, ok := end[key]; !ok {
+			edges = append(edges, edge)
+		}
+	}
+	for _, edge := range end {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].A != edges[j].A {
+			return edges[i].A < edges[j].A
+		}
+		return edges[i].B < edges[j].B
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "graph topology {")
+	fmt.Fprintln(bw, "\tnode [shape=circle];")
+	for _, id := range sorted {
+		fmt.Fprintf(bw, "\t%q;\n", id)
+	}
+	for _, edge := range edges {
+		attrs := []string{fmt.Sprintf("label=%q", strings.Join(edge.Transports, ","))}
+		key := pair{edge.A, edge.B}
+		_, atStart := start[key]
+		_, atEnd := end[key]
+		switch {
+		case !atEnd && len(topology.End) > 0:
+			attrs = append(attrs, "style=dashed", "color=red")
+		case !atStart && len(topology.Start) > 0:
+			attrs = append(attrs, "color=green")
+		}
+		fmt.Fprintf(bw, "\t%q -- %q [%s];\n", edge.A, edge.B, strings.Join(attrs, ", "))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
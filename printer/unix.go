@@ -45,6 +45,23 @@ func (p *unixPrinter) Print(v interface{}) error {
 		fmt.Printf("%s\n", t.ID)
 	case metadata.Experiment:
 		fmt.Printf("%s\n", t.ID)
+	case metadata.LabContext:
+		fmt.Printf("%s\n", t.Name)
+	case metadata.Diagnostic:
+		if t.Line > 0 {
+			fmt.Printf("%s: line %d: %s: %s\n", t.Severity, t.Line, t.Path, t.Message)
+		} else {
+			fmt.Printf("%s: %s: %s\n", t.Severity, t.Path, t.Message)
+		}
+	case metadata.FieldChange:
+		switch t.Kind {
+		case metadata.ChangeAdded:
+			fmt.Printf("+ %s: %s\n", t.Path, t.New)
+		case metadata.ChangeRemoved:
+			fmt.Printf("- %s: %s\n", t.Path, t.Old)
+		default:
+			fmt.Printf("~ %s: %s -> %s\n", t.Path, t.Old, t.New)
+		}
 	}
 
 	return nil
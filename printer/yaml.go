@@ -0,0 +1,51 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type yamlPrinter struct{}
+
+func NewYAMLPrinter() Printer {
+	return &yamlPrinter{}
+}
+
+// Print converts v to YAML through JSON, so that fields are named by their
+// json tags just like the JSON printer.
+func (p *yamlPrinter) Print(v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var obj interface{}
+	err = yaml.Unmarshal(content, &obj)
+	if err != nil {
+		return err
+	}
+
+	content, err = yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(content))
+	return nil
+}
@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/labagent"
@@ -30,9 +32,13 @@ import (
 
 type provider struct {
 	root      string
-	nodes     map[string][]*node
 	logger    *zerolog.Logger
 	agentOpts []labagent.LabagentOption
+
+	mu sync.Mutex
+	// nodes maps a node group ID to its nodes. It is read by the reaper while
+	// requests create and destroy node groups.
+	nodes map[string][]*node
 }
 
 func New(root string, db metadata.DB, logger *zerolog.Logger, agentOpts ...labagent.LabagentOption) (p2plab.NodeProvider, error) {
@@ -65,7 +71,7 @@ func New(root string, db metadata.DB, logger *zerolog.Logger, agentOpts ...labag
 			if err != nil {
 				return nil, err
 			}
-			p.nodes[node.ID] = append(p.nodes[node.ID], n)
+			p.nodes[cluster.ID] = append(p.nodes[cluster.ID], n)
 		}
 	}
 
@@ -73,8 +79,19 @@ func New(root string, db metadata.DB, logger *zerolog.Logger, agentOpts ...labag
 }
 
 func (p *provider) CreateNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*p2plab.NodeGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(cdef.Connectivity) > 0 {
+		zerolog.Ctx(ctx).Warn().Msg("Connectivity rules are not supported by this provider and will be ignored")
+	}
+
 	var ns []metadata.Node
 	for _, group := range cdef.Groups {
+		if group.Bootstrap != "" {
+			zerolog.Ctx(ctx).Warn().Msg("Bootstrap scripts are not supported by this provider and will be ignored")
+		}
+
 		for i := 0; i < group.Size; i++ {
 			freePorts, err := freeport.GetFreePorts(2)
 			if err != nil {
@@ -82,19 +99,21 @@ func (p *provider) CreateNodeGroup(ctx context.Context, id string, cdef metadata
 			}
 			agentPort, appPort := freePorts[0], freePorts[1]
 
-			id := xid.New().String()
-			n, err := p.newNode(id, agentPort, appPort)
+			n, err := p.newNode(xid.New().String(), agentPort, appPort)
 			if err != nil {
 				return nil, err
 			}
 			p.nodes[id] = append(p.nodes[id], n)
 
 			ns = append(ns, metadata.Node{
-				ID:        n.ID,
-				Address:   "127.0.0.1",
-				AgentPort: n.AgentPort,
-				AppPort:   n.AppPort,
-				Peer:      *group.Peer,
+				ID:           n.ID,
+				Address:      "127.0.0.1",
+				AgentPort:    n.AgentPort,
+				AppPort:      n.AppPort,
+				Region:       group.Region,
+				InstanceType: group.InstanceType,
+				Arch:         runtime.GOARCH,
+				Peer:         *group.Peer,
 				Labels: append([]string{
 					n.ID,
 					group.InstanceType,
@@ -110,7 +129,59 @@ func (p *provider) CreateNodeGroup(ctx context.Context, id string, cdef metadata
 	}, nil
 }
 
+func (p *provider) ReplaceNodes(ctx context.Context, ng *p2plab.NodeGroup, cdef metadata.ClusterDefinition, failed []metadata.Node) ([]metadata.Node, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var ns []metadata.Node
+	for _, f := range failed {
+		for i, n := range p.nodes[ng.ID] {
+			if n.ID != f.ID {
+				continue
+			}
+
+			err := n.Close()
+			if err != nil {
+				return nil, err
+			}
+			p.nodes[ng.ID] = append(p.nodes[ng.ID][:i], p.nodes[ng.ID][i+1:]...)
+			break
+		}
+
+		freePorts, err := freeport.GetFreePorts(2)
+		if err != nil {
+			return nil, err
+		}
+		agentPort, appPort := freePorts[0], freePorts[1]
+
+		n, err := p.newNode(xid.New().String(), agentPort, appPort)
+		if err != nil {
+			return nil, err
+		}
+		p.nodes[ng.ID] = append(p.nodes[ng.ID], n)
+
+		// The replacement takes over the failed node's labels, except for the
+		// leading ID label.
+		ns = append(ns, metadata.Node{
+			ID:           n.ID,
+			Address:      "127.0.0.1",
+			AgentPort:    n.AgentPort,
+			AppPort:      n.AppPort,
+			Region:       f.Region,
+			InstanceType: f.InstanceType,
+			Arch:         runtime.GOARCH,
+			Peer:         f.Peer,
+			Labels:       append([]string{n.ID}, f.Labels[1:]...),
+		})
+	}
+
+	return ns, nil
+}
+
 func (p *provider) DestroyNodeGroup(ctx context.Context, ng *p2plab.NodeGroup) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	for _, n := range p.nodes[ng.ID] {
 		err := n.Close()
 		if err != nil {
@@ -122,6 +193,60 @@ func (p *provider) DestroyNodeGroup(ctx context.Context, ng *p2plab.NodeGroup) e
 	return nil
 }
 
+func (p *provider) StopNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, n := range p.nodes[id] {
+		err := n.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *provider) StartNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, n := range p.nodes[id] {
+		// Reuse the same ports and state directories so the node metadata
+		// remains valid.
+		restarted, err := p.newNode(n.ID, n.AgentPort, n.AppPort)
+		if err != nil {
+			return err
+		}
+		p.nodes[id][i] = restarted
+	}
+	return nil
+}
+
+func (p *provider) ListNodeGroups(ctx context.Context) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var ids []string
+	for id := range p.nodes {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (p *provider) PlanNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*p2plab.NodeGroupPlan, error) {
+	var resources []string
+	for i, group := range cdef.Groups {
+		for j := 0; j < group.Size; j++ {
+			resources = append(resources, fmt.Sprintf("labagent.%d.%d", i, j))
+		}
+	}
+
+	return &p2plab.NodeGroupPlan{
+		ID:        id,
+		Resources: resources,
+	}, nil
+}
+
 type node struct {
 	ID        string
 	AgentPort int
@@ -137,7 +262,11 @@ func (p *provider) newNode(id string, agentPort, appPort int) (*node, error) {
 	appRoot := filepath.Join(p.root, id, "labapp")
 	appAddr := fmt.Sprintf("http://localhost:%d", appPort)
 
-	la, err := labagent.New(agentRoot, agentAddr, appRoot, appAddr, p.logger, p.agentOpts...)
+	// In-memory nodes share the host's network interfaces, so impairing one
+	// would shape the host's own traffic. Without an interface, labagent
+	// rejects network impairments instead.
+	opts := append(append([]labagent.LabagentOption{}, p.agentOpts...), labagent.WithNetworkInterface(""))
+	la, err := labagent.New(agentRoot, agentAddr, appRoot, appAddr, p.logger, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -24,14 +24,17 @@ import (
 	"github.com/Netflix/p2plab/labagent"
 	"github.com/Netflix/p2plab/metadata"
 	"github.com/Netflix/p2plab/providers/inmemory"
+	"github.com/Netflix/p2plab/providers/static"
 	"github.com/Netflix/p2plab/providers/terraform"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
 type ProviderSettings struct {
-	DB     metadata.DB
-	Logger *zerolog.Logger
+	DB        metadata.DB
+	Logger    *zerolog.Logger
+	Static    static.StaticProviderSettings
+	Terraform terraform.TerraformProviderSettings
 }
 
 func GetNodeProvider(root, providerType string, settings ProviderSettings) (p2plab.NodeProvider, error) {
@@ -45,7 +48,9 @@ func GetNodeProvider(root, providerType string, settings ProviderSettings) (p2pl
 		}),
 		)
 	case "terraform":
-		return terraform.New(root)
+		return terraform.New(root, settings.Terraform)
+	case "static":
+		return static.New(settings.DB, settings.Logger, settings.Static)
 	default:
 		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized node provider type %q", providerType)
 	}
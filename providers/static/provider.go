@@ -0,0 +1,379 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/stringutil"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog"
+)
+
+var (
+	DefaultAgentPort = 7002
+	DefaultAppPort   = 7003
+)
+
+type StaticProviderSettings struct {
+	// Inventory is the path to a JSON file describing the hosts that are
+	// already running labagent and can be registered into clusters.
+	Inventory string
+}
+
+// Inventory is a static list of hosts available to the provider.
+type Inventory struct {
+	Hosts []Host
+}
+
+// Host is a pre-provisioned machine with labagent installed.
+type Host struct {
+	Address      string
+	AgentPort    int
+	AppPort      int
+	InstanceType string
+	Region       string
+
+	// Arch is the CPU architecture of the host, which defaults to the
+	// architecture of its instance type.
+	Arch string
+
+	Labels []string
+}
+
+func (h Host) arch() string {
+	if h.Arch != "" {
+		return h.Arch
+	}
+	return metadata.InstanceArch(h.InstanceType)
+}
+
+func (h Host) key() string {
+	return fmt.Sprintf("%s:%d", h.Address, h.AgentPort)
+}
+
+type provider struct {
+	hosts  []Host
+	logger *zerolog.Logger
+
+	mu sync.Mutex
+	// leases maps a host key to the node group that has checked it out.
+	leases map[string]string
+}
+
+func New(db metadata.DB, logger *zerolog.Logger, settings StaticProviderSettings) (p2plab.NodeProvider, error) {
+	inventory, err := ReadInventory(settings.Inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &provider{
+		hosts:  inventory.Hosts,
+		logger: logger,
+		leases: make(map[string]string),
+	}
+
+	// Hosts that are already registered into a cluster must not be handed out
+	// again after labd restarts.
+	ctx := context.Background()
+	clusters, err := db.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cluster := range clusters {
+		nodes, err := db.ListNodes(ctx, cluster.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range nodes {
+			h := Host{Address: node.Address, AgentPort: node.AgentPort}
+			p.leases[h.key()] = cluster.ID
+		}
+	}
+
+	return p, nil
+}
+
+// ReadInventory reads and validates an inventory file.
+func ReadInventory(path string) (Inventory, error) {
+	var inventory Inventory
+	if path == "" {
+		return inventory, errors.Wrap(errdefs.ErrInvalidArgument, "static provider requires an inventory")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return inventory, errors.Wrapf(err, "failed to open inventory %q", path)
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&inventory)
+	if err != nil {
+		return inventory, errors.Wrapf(err, "failed to decode inventory %q", path)
+	}
+
+	seen := make(map[string]struct{})
+	for i, h := range inventory.Hosts {
+		if h.Address == "" {
+			return inventory, errors.Wrapf(errdefs.ErrInvalidArgument, "inventory host %d has no address", i)
+		}
+		if h.AgentPort == 0 {
+			h.AgentPort = DefaultAgentPort
+		}
+		if h.AppPort == 0 {
+			h.AppPort = DefaultAppPort
+		}
+
+		_, ok := seen[h.key()]
+		if ok {
+			return inventory, errors.Wrapf(errdefs.ErrInvalidArgument, "inventory host %q is listed more than once", h.key())
+		}
+		seen[h.key()] = struct{}{}
+
+		inventory.Hosts[i] = h
+	}
+
+	return inventory, nil
+}
+
+func (p *provider) CreateNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*p2plab.NodeGroup, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(cdef.Connectivity) > 0 {
+		zerolog.Ctx(ctx).Warn().Msg("Connectivity rules are not supported by this provider and will be ignored")
+	}
+
+	var (
+		ns     []metadata.Node
+		leased []string
+	)
+	for _, group := range cdef.Groups {
+		if group.Bootstrap != "" {
+			zerolog.Ctx(ctx).Warn().Msg("Bootstrap scripts are not supported by this provider and will be ignored")
+		}
+
+		hosts := p.availableHosts(group)
+		if len(hosts) < group.Size {
+			for _, key := range leased {
+				delete(p.leases, key)
+			}
+			return nil, errors.Wrapf(errdefs.ErrUnavailable, "only %d of %d hosts available for instance type %q in region %q", len(hosts), group.Size, group.InstanceType, group.Region)
+		}
+
+		for _, h := range hosts[:group.Size] {
+			p.leases[h.key()] = id
+			leased = append(leased, h.key())
+
+			peer := metadata.DefaultPeerDefinition
+			if group.Peer != nil {
+				peer = *group.Peer
+			}
+
+			nodeID := xid.New().String()
+			labels := append([]string{nodeID}, h.Labels...)
+			labels = append(labels, group.Labels...)
+			if h.InstanceType != "" {
+				labels = append(labels, h.InstanceType)
+			}
+			if h.Region != "" {
+				labels = append(labels, h.Region)
+			}
+
+			ns = append(ns, metadata.Node{
+				ID:           nodeID,
+				Address:      h.Address,
+				AgentPort:    h.AgentPort,
+				AppPort:      h.AppPort,
+				Region:       h.Region,
+				InstanceType: h.InstanceType,
+				Arch:         h.arch(),
+				Peer:         peer,
+				Labels:       labels,
+			})
+		}
+	}
+
+	zerolog.Ctx(ctx).Debug().Int("hosts", len(ns)).Msg("Leased hosts from static inventory")
+	return &p2plab.NodeGroup{
+		ID:    id,
+		Nodes: ns,
+	}, nil
+}
+
+func (p *provider) ReplaceNodes(ctx context.Context, ng *p2plab.NodeGroup, cdef metadata.ClusterDefinition, failed []metadata.Node) ([]metadata.Node, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var ns []metadata.Node
+	for _, f := range failed {
+		// Failed hosts stay leased to the node group so that they aren't handed
+		// out again until the cluster is destroyed.
+		fh, ok := p.host(f.Address, f.AgentPort)
+		if !ok {
+			return nil, errors.Wrapf(errdefs.ErrNotFound, "host %s:%d not in inventory", f.Address, f.AgentPort)
+		}
+
+		hosts := p.availableHosts(metadata.ClusterGroup{
+			InstanceType: fh.InstanceType,
+			Region:       fh.Region,
+		})
+		if len(hosts) == 0 {
+			zerolog.Ctx(ctx).Warn().Str("instanceType", fh.InstanceType).Str("region", fh.Region).Msg("No spare hosts available to replace failed node")
+			continue
+		}
+
+		h := hosts[0]
+		p.leases[h.key()] = ng.ID
+
+		nodeID := xid.New().String()
+		labels := append([]string{nodeID}, h.Labels...)
+		for _, l := range f.Labels[1:] {
+			if !stringutil.Contains(fh.Labels, l) {
+				labels = append(labels, l)
+			}
+		}
+
+		ns = append(ns, metadata.Node{
+			ID:           nodeID,
+			Address:      h.Address,
+			AgentPort:    h.AgentPort,
+			AppPort:      h.AppPort,
+			Region:       h.Region,
+			InstanceType: h.InstanceType,
+			Arch:         h.arch(),
+			Peer:         f.Peer,
+			Labels:       labels,
+		})
+	}
+
+	return ns, nil
+}
+
+func (p *provider) StopNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) error {
+	return errors.Wrap(errdefs.ErrInvalidArgument, "static hosts cannot be stopped")
+}
+
+func (p *provider) StartNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) error {
+	return errors.Wrap(errdefs.ErrInvalidArgument, "static hosts cannot be started")
+}
+
+func (p *provider) ListNodeGroups(ctx context.Context) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	set := make(map[string]struct{})
+	for _, owner := range p.leases {
+		set[owner] = struct{}{}
+	}
+
+	var ids []string
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (p *provider) PlanNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*p2plab.NodeGroupPlan, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		resources []string
+		planned   = make(map[string]struct{})
+	)
+	for _, group := range cdef.Groups {
+		var hosts []Host
+		for _, h := range p.availableHosts(group) {
+			_, ok := planned[h.key()]
+			if !ok {
+				hosts = append(hosts, h)
+			}
+		}
+
+		if len(hosts) < group.Size {
+			return nil, errors.Wrapf(errdefs.ErrUnavailable, "only %d of %d hosts available for instance type %q in region %q", len(hosts), group.Size, group.InstanceType, group.Region)
+		}
+
+		for _, h := range hosts[:group.Size] {
+			planned[h.key()] = struct{}{}
+			resources = append(resources, h.key())
+		}
+	}
+
+	return &p2plab.NodeGroupPlan{
+		ID:        id,
+		Resources: resources,
+	}, nil
+}
+
+func (p *provider) DestroyNodeGroup(ctx context.Context, ng *p2plab.NodeGroup) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Static hosts are never torn down, they are only returned to the
+	// inventory so that the next cluster can use them.
+	for key, owner := range p.leases {
+		if owner == ng.ID {
+			delete(p.leases, key)
+		}
+	}
+
+	zerolog.Ctx(ctx).Debug().Msg("Released hosts to static inventory")
+	return nil
+}
+
+// host returns the inventory host with the given address and agent port.
+func (p *provider) host(address string, agentPort int) (Host, bool) {
+	for _, h := range p.hosts {
+		if h.Address == address && h.AgentPort == agentPort {
+			return h, true
+		}
+	}
+	return Host{}, false
+}
+
+// availableHosts returns the unleased hosts that satisfy a cluster group. An
+// empty instance type or region on either side matches anything.
+func (p *provider) availableHosts(group metadata.ClusterGroup) []Host {
+	var hosts []Host
+	for _, h := range p.hosts {
+		_, ok := p.leases[h.key()]
+		if ok {
+			continue
+		}
+
+		if group.InstanceType != "" && h.InstanceType != "" && group.InstanceType != h.InstanceType {
+			continue
+		}
+
+		if group.Region != "" && h.Region != "" && group.Region != h.Region {
+			continue
+		}
+
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
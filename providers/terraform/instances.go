@@ -21,18 +21,42 @@ import (
 	"io"
 	"os"
 	"os/exec"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
 )
 
 type EC2Instance struct {
-	InstanceId   string `json:"InstanceId"`
-	InstanceType string `json:"InstanceType"`
-	PrivateIp    string `json:"PrivateIpAddress"`
+	InstanceId        string `json:"InstanceId"`
+	InstanceType      string `json:"InstanceType"`
+	PrivateIp         string `json:"PrivateIpAddress"`
+	NetworkInterfaces []struct {
+		Ipv6Addresses []struct {
+			Ipv6Address string `json:"Ipv6Address"`
+		} `json:"Ipv6Addresses"`
+	} `json:"NetworkInterfaces"`
+}
+
+// Address returns the address labd should reach the instance on for a network
+// stack.
+func (i EC2Instance) Address(stack metadata.NetworkStack) string {
+	if stack != metadata.NetworkIPv6 {
+		return i.PrivateIp
+	}
+
+	for _, ni := range i.NetworkInterfaces {
+		for _, addr := range ni.Ipv6Addresses {
+			return addr.Ipv6Address
+		}
+	}
+	return i.PrivateIp
 }
 
 func DiscoverInstances(ctx context.Context, asg, region string) ([]EC2Instance, error) {
 	asgStdout := new(bytes.Buffer)
 	err := awscliWithStdio(ctx, asgStdout, nil, "autoscaling", "describe-auto-scaling-groups",
-		"--query", "AutoScalingGroups[].Instances[].InstanceId",
+		"--query", "AutoScalingGroups[].Instances[] | [?LifecycleState=='InService'].InstanceId",
 		"--output", "json",
 		"--region", region,
 		"--auto-scaling-group-names", asg,
@@ -47,6 +71,12 @@ func DiscoverInstances(ctx context.Context, asg, region string) ([]EC2Instance,
 		return nil, err
 	}
 
+	// Describing instances without instance IDs would return every instance
+	// in the region.
+	if len(instanceIds) == 0 {
+		return nil, nil
+	}
+
 	instancesStdout := new(bytes.Buffer)
 	err = awscliWithStdio(ctx, instancesStdout, nil, append([]string{"ec2", "describe-instances",
 		"--query", "Reservations[].Instances[]",
@@ -67,6 +97,240 @@ func DiscoverInstances(ctx context.Context, asg, region string) ([]EC2Instance,
 	return instances, nil
 }
 
+// TerminateInstances terminates instances in an ASG without decrementing its
+// desired capacity, so that the ASG launches replacements.
+func TerminateInstances(ctx context.Context, instanceIds []string, region string) error {
+	for _, instanceId := range instanceIds {
+		err := awscli(ctx, "autoscaling", "terminate-instance-in-auto-scaling-group",
+			"--region", region,
+			"--instance-id", instanceId,
+			"--no-should-decrement-desired-capacity",
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StopInstances suspends the ASG so that it doesn't replace the instances, and
+// then stops them.
+func StopInstances(ctx context.Context, asg, region string) error {
+	err := awscli(ctx, "autoscaling", "suspend-processes",
+		"--region", region,
+		"--auto-scaling-group-name", asg,
+	)
+	if err != nil {
+		return err
+	}
+
+	instances, err := DiscoverInstances(ctx, asg, region)
+	if err != nil {
+		return err
+	}
+
+	var instanceIds []string
+	for _, instance := range instances {
+		instanceIds = append(instanceIds, instance.InstanceId)
+	}
+
+	// The instance commands reject an empty list of instance IDs, and with no
+	// instances to stop the ASG needn't stay suspended.
+	if len(instanceIds) == 0 {
+		return resumeProcesses(ctx, asg, region)
+	}
+
+	err = awscli(ctx, append([]string{"ec2", "stop-instances",
+		"--region", region,
+		"--instance-ids"}, instanceIds...)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	return awscli(ctx, append([]string{"ec2", "wait", "instance-stopped",
+		"--region", region,
+		"--instance-ids"}, instanceIds...)...,
+	)
+}
+
+// StartInstances starts the stopped instances of an ASG and then resumes the
+// ASG.
+func StartInstances(ctx context.Context, asg, region string) error {
+	instances, err := DiscoverInstances(ctx, asg, region)
+	if err != nil {
+		return err
+	}
+
+	var instanceIds []string
+	for _, instance := range instances {
+		instanceIds = append(instanceIds, instance.InstanceId)
+	}
+
+	// The instance commands reject an empty list of instance IDs.
+	if len(instanceIds) == 0 {
+		return resumeProcesses(ctx, asg, region)
+	}
+
+	err = awscli(ctx, append([]string{"ec2", "start-instances",
+		"--region", region,
+		"--instance-ids"}, instanceIds...)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = awscli(ctx, append([]string{"ec2", "wait", "instance-running",
+		"--region", region,
+		"--instance-ids"}, instanceIds...)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	return resumeProcesses(ctx, asg, region)
+}
+
+// resumeProcesses resumes an ASG suspended by StopInstances.
+func resumeProcesses(ctx context.Context, asg, region string) error {
+	return awscli(ctx, "autoscaling", "resume-processes",
+		"--region", region,
+		"--auto-scaling-group-name", asg,
+	)
+}
+
+type AutoScalingGroup struct {
+	Name string `json:"AutoScalingGroupName"`
+	Tags []struct {
+		Key   string `json:"Key"`
+		Value string `json:"Value"`
+	} `json:"Tags"`
+}
+
+// Tag returns the value of a tag on the ASG.
+func (g AutoScalingGroup) Tag(key string) string {
+	for _, tag := range g.Tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// DiscoverClusters returns the IDs of clusters with ASGs tagged with the lab
+// ID in a region.
+func DiscoverClusters(ctx context.Context, labID, region string) ([]string, error) {
+	stdout := new(bytes.Buffer)
+	err := awscliWithStdio(ctx, stdout, nil, "autoscaling", "describe-auto-scaling-groups",
+		"--query", "AutoScalingGroups[]",
+		"--output", "json",
+		"--region", region,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var asgs []AutoScalingGroup
+	err = json.NewDecoder(stdout).Decode(&asgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, asg := range asgs {
+		if asg.Tag(TagLab) != labID {
+			continue
+		}
+
+		id := asg.Tag(TagCluster)
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+type EC2Image struct {
+	ImageId      string `json:"ImageId"`
+	State        string `json:"State"`
+	Architecture string `json:"Architecture"`
+	Platform     string `json:"Platform"`
+	// RootDeviceName is the device the image's root volume is attached at.
+	RootDeviceName string `json:"RootDeviceName"`
+	Tags           []struct {
+		Key   string `json:"Key"`
+		Value string `json:"Value"`
+	} `json:"Tags"`
+}
+
+// HasLabagent returns true if the image was baked with labagent installed.
+func (i EC2Image) HasLabagent() bool {
+	for _, tag := range i.Tags {
+		if tag.Key == "labagent" || (tag.Key == "Name" && tag.Value == "labagent") {
+			return true
+		}
+	}
+	return false
+}
+
+func DescribeImage(ctx context.Context, image, region string) (EC2Image, error) {
+	stdout := new(bytes.Buffer)
+	err := awscliWithStdio(ctx, stdout, nil, "ec2", "describe-images",
+		"--query", "Images[]",
+		"--output", "json",
+		"--region", region,
+		"--image-ids", image,
+	)
+	if err != nil {
+		return EC2Image{}, err
+	}
+
+	var images []EC2Image
+	err = json.NewDecoder(stdout).Decode(&images)
+	if err != nil {
+		return EC2Image{}, err
+	}
+
+	if len(images) == 0 {
+		return EC2Image{}, errors.Wrapf(errdefs.ErrNotFound, "image %q in %q", image, region)
+	}
+
+	return images[0], nil
+}
+
+// ValidateImages checks that custom images of a cluster definition exist and
+// are able to run labagent, and returns them by their ID.
+func ValidateImages(ctx context.Context, cdef metadata.ClusterDefinition) (map[string]EC2Image, error) {
+	images := make(map[string]EC2Image)
+	for _, group := range cdef.Groups {
+		if group.Image == "" {
+			continue
+		}
+
+		image, err := DescribeImage(ctx, group.Image, group.Region)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe image %q", group.Image)
+		}
+
+		if image.State != "available" {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "image %q is %s", group.Image, image.State)
+		}
+
+		if image.Platform == "windows" || image.Architecture != "x86_64" {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "image %q must be linux x86_64 to run labagent", group.Image)
+		}
+
+		if !image.HasLabagent() {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "image %q is not tagged with labagent", group.Image)
+		}
+
+		images[image.ImageId] = image
+	}
+
+	return images, nil
+}
+
 func awscli(ctx context.Context, args ...string) error {
 	return awscliWithStdio(ctx, os.Stdout, os.Stderr, args...)
 }
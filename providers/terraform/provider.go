@@ -16,10 +16,13 @@ package terraform
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"text/template"
 
 	"github.com/Netflix/p2plab"
@@ -32,12 +35,41 @@ import (
 var (
 	DefaultAgentPort = 7002
 	DefaultAppPort   = 7003
+	DefaultLabID     = "p2plab"
+
+	// Regions are the AWS regions supported by the terraform templates.
+	Regions = []string{"us-west-2", "us-east-1", "eu-west-1"}
 )
 
+const (
+	// TagLab is the tag key identifying the lab that provisioned a resource.
+	TagLab = "p2plab-lab"
+
+	// TagCluster is the tag key identifying the cluster a resource belongs to.
+	TagCluster = "p2plab-cluster"
+)
+
+type TerraformProviderSettings struct {
+	// LabID is tagged on all provisioned resources so that they can be
+	// reconciled against this lab's metadata.
+	LabID string
+
+	// LabdCIDRs are the addresses labd reaches nodes from, which are allowed
+	// to reach every port of clusters with connectivity rules so that labd's
+	// seeder can dial their peers. Defaults to the addresses of labd's host.
+	LabdCIDRs []string
+}
+
 type provider struct {
-	root          string
-	tfvars        *template.Template
-	maintf        *template.Template
+	root      string
+	labID     string
+	labdCIDRs []string
+	tfvars    *template.Template
+	maintf    *template.Template
+
+	// mu guards terraformById, which the reaper accesses when it destroys
+	// orphaned node groups while requests create and destroy others.
+	mu            sync.Mutex
 	terraformById map[string]*Terraform
 }
 
@@ -49,22 +81,56 @@ type BackendVars struct {
 
 type ClusterVars struct {
 	ID                    string
+	LabID                 string
+	LabdCIDRs             []string
+	NetworkStack          metadata.NetworkStack
 	RegionalClusterGroups []RegionalClusterGroups
 }
 
 type RegionalClusterGroups struct {
 	Region string
-	Groups []metadata.ClusterGroup
+	Groups []ClusterGroupVars
 }
 
-func New(root string) (p2plab.NodeProvider, error) {
+type ClusterGroupVars struct {
+	metadata.ClusterGroup
+
+	// ASG is the name of the group's autoscaling group, which is unique across
+	// regions.
+	ASG string
+
+	// RootDeviceName is the root device of the group's custom image, or empty
+	// for the default labagent image.
+	RootDeviceName string
+
+	// Isolated is true when the cluster declares connectivity rules, in which
+	// case the group gets its own security group that only allows Ingress.
+	Isolated bool
+	Ingress  []IngressVars
+}
+
+type IngressVars struct {
+	From     string
+	Protocol string
+	FromPort int
+	ToPort   int
+}
+
+func New(root string, settings TerraformProviderSettings) (p2plab.NodeProvider, error) {
+	labID := settings.LabID
+	if labID == "" {
+		labID = DefaultLabID
+	}
+
 	tfvarsPath := filepath.Join(root, "templates/terraform.tfvars")
 	tfvarsContent, err := ioutil.ReadFile(tfvarsPath)
 	if err != nil {
 		return nil, err
 	}
 
-	tfvars, err := template.New("terraform.tfvars").Parse(string(tfvarsContent))
+	tfvars, err := template.New("terraform.tfvars").Funcs(template.FuncMap{
+		"userdata": UserData,
+	}).Parse(string(tfvarsContent))
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +146,18 @@ func New(root string) (p2plab.NodeProvider, error) {
 		return nil, err
 	}
 
+	labdCIDRs := settings.LabdCIDRs
+	if len(labdCIDRs) == 0 {
+		labdCIDRs, err = hostCIDRs()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get addresses of labd's host")
+		}
+	}
+
 	return &provider{
 		root:          root,
+		labID:         labID,
+		labdCIDRs:     labdCIDRs,
 		tfvars:        tfvars,
 		maintf:        maintf,
 		terraformById: make(map[string]*Terraform),
@@ -89,15 +165,22 @@ func New(root string) (p2plab.NodeProvider, error) {
 }
 
 func (p *provider) CreateNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*p2plab.NodeGroup, error) {
+	zerolog.Ctx(ctx).Debug().Msg("Validating images")
+	images, err := ValidateImages(ctx, cdef)
+	if err != nil {
+		return nil, err
+	}
+
 	zerolog.Ctx(ctx).Debug().Msg("Preparing cluster directory")
-	clusterDir, err := p.prepareClusterDir(id)
+	clusterDir := filepath.Join(p.root, id)
+	err = p.prepareClusterDir(clusterDir, id)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to prepare cluster directory")
 	}
 	logger := zerolog.Ctx(ctx).With().Str("dir", clusterDir).Logger()
 
 	logger.Debug().Msg("Executing tfvars template")
-	err = p.executeTfvarsTemplate(id, cdef)
+	err = p.executeTfvarsTemplate(clusterDir, id, cdef, images)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to execute tfvars template")
 	}
@@ -107,7 +190,9 @@ func (p *provider) CreateNodeGroup(ctx context.Context, id string, cdef metadata
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create terraform handler")
 	}
+	p.mu.Lock()
 	p.terraformById[id] = t
+	p.mu.Unlock()
 
 	logger.Debug().Msg("Terraform applying")
 	ns, err := t.Apply(ctx, id, cdef)
@@ -121,17 +206,152 @@ func (p *provider) CreateNodeGroup(ctx context.Context, id string, cdef metadata
 	}, nil
 }
 
+func (p *provider) ReplaceNodes(ctx context.Context, ng *p2plab.NodeGroup, cdef metadata.ClusterDefinition, failed []metadata.Node) ([]metadata.Node, error) {
+	exclude := make(map[string]struct{})
+	for _, n := range ng.Nodes {
+		exclude[n.ID] = struct{}{}
+	}
+
+	instanceIdsByRegion := make(map[string][]string)
+	for _, n := range failed {
+		instanceIdsByRegion[n.Region] = append(instanceIdsByRegion[n.Region], n.ID)
+	}
+
+	for region, instanceIds := range instanceIdsByRegion {
+		zerolog.Ctx(ctx).Debug().Strs("instances", instanceIds).Str("region", region).Msg("Terminating failed instances")
+		err := TerminateInstances(ctx, instanceIds, region)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to terminate instances in %q", region)
+		}
+	}
+
+	return DiscoverNodes(ctx, ng.ID, cdef, exclude)
+}
+
+func (p *provider) PlanNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) (*p2plab.NodeGroupPlan, error) {
+	zerolog.Ctx(ctx).Debug().Msg("Validating images")
+	images, err := ValidateImages(ctx, cdef)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plans are made in a temporary directory so that they never collide with
+	// the directory of a cluster being created with the same ID.
+	zerolog.Ctx(ctx).Debug().Msg("Preparing plan directory")
+	planDir, err := ioutil.TempDir("", "labd-plan-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create plan directory")
+	}
+	defer os.RemoveAll(planDir)
+
+	clusterDir := filepath.Join(planDir, id)
+	err = p.prepareClusterDir(clusterDir, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to prepare cluster directory")
+	}
+	logger := zerolog.Ctx(ctx).With().Str("dir", clusterDir).Logger()
+
+	logger.Debug().Msg("Executing tfvars template")
+	err = p.executeTfvarsTemplate(clusterDir, id, cdef, images)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute tfvars template")
+	}
+
+	logger.Debug().Msg("Creating terraform handler")
+	t, err := NewTerraform(ctx, clusterDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create terraform handler")
+	}
+	defer t.Close()
+
+	logger.Debug().Msg("Terraform planning")
+	resources, err := t.Plan(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to terraform plan")
+	}
+
+	return &p2plab.NodeGroupPlan{
+		ID:        id,
+		Resources: resources,
+	}, nil
+}
+
+func (p *provider) StopNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) error {
+	for i, group := range cdef.Groups {
+		asg := fmt.Sprintf("%s-%d", id, i)
+		zerolog.Ctx(ctx).Debug().Str("asg", asg).Msg("Stopping instances in ASG")
+		err := StopInstances(ctx, asg, group.Region)
+		if err != nil {
+			return errors.Wrapf(err, "failed to stop instances for ASG %q in %q", asg, group.Region)
+		}
+	}
+	return nil
+}
+
+func (p *provider) StartNodeGroup(ctx context.Context, id string, cdef metadata.ClusterDefinition) error {
+	for i, group := range cdef.Groups {
+		asg := fmt.Sprintf("%s-%d", id, i)
+		zerolog.Ctx(ctx).Debug().Str("asg", asg).Msg("Starting instances in ASG")
+		err := StartInstances(ctx, asg, group.Region)
+		if err != nil {
+			return errors.Wrapf(err, "failed to start instances for ASG %q in %q", asg, group.Region)
+		}
+	}
+	return nil
+}
+
+func (p *provider) ListNodeGroups(ctx context.Context) ([]string, error) {
+	set := make(map[string]struct{})
+	for _, region := range Regions {
+		ids, err := DiscoverClusters(ctx, p.labID, region)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to discover clusters in %q", region)
+		}
+
+		for _, id := range ids {
+			set[id] = struct{}{}
+		}
+	}
+
+	var ids []string
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 func (p *provider) DestroyNodeGroup(ctx context.Context, ng *p2plab.NodeGroup) error {
+	p.mu.Lock()
 	t, ok := p.terraformById[ng.ID]
+	p.mu.Unlock()
 	if !ok {
-		zerolog.Ctx(ctx).Debug().Msg("Creating terraform handler")
 		var err error
 		clusterDir := filepath.Join(p.root, ng.ID)
+		_, err = os.Stat(clusterDir)
+		if os.IsNotExist(err) {
+			// The cluster directory may be missing if labd crashed or the
+			// infrastructure was orphaned, but terraform can still recover the
+			// state from the remote backend.
+			zerolog.Ctx(ctx).Debug().Msg("Recreating cluster directory")
+			err = p.prepareClusterDir(clusterDir, ng.ID)
+			if err != nil {
+				return errors.Wrap(err, "failed to prepare cluster directory")
+			}
+
+			err = p.executeTfvarsTemplate(clusterDir, ng.ID, metadata.ClusterDefinition{}, nil)
+			if err != nil {
+				return errors.Wrap(err, "failed to execute tfvars template")
+			}
+		}
+
+		zerolog.Ctx(ctx).Debug().Msg("Creating terraform handler")
 		t, err = NewTerraform(ctx, clusterDir)
 		if err != nil {
 			return errors.Wrap(err, "failed to create terraform handler")
 		}
+		p.mu.Lock()
 		p.terraformById[ng.ID] = t
+		p.mu.Unlock()
 	}
 
 	zerolog.Ctx(ctx).Debug().Msg("Terraform destroying")
@@ -141,7 +361,10 @@ func (p *provider) DestroyNodeGroup(ctx context.Context, ng *p2plab.NodeGroup) e
 	}
 	defer t.Close()
 
+	p.mu.Lock()
 	delete(p.terraformById, ng.ID)
+	p.mu.Unlock()
+
 	zerolog.Ctx(ctx).Debug().Msg("Removing cluster directory")
 	err = p.destroyClusterDir(ng.ID)
 	if err != nil {
@@ -151,17 +374,16 @@ func (p *provider) DestroyNodeGroup(ctx context.Context, ng *p2plab.NodeGroup) e
 	return nil
 }
 
-func (p *provider) prepareClusterDir(id string) (clusterDir string, err error) {
-	clusterDir = filepath.Join(p.root, id)
+func (p *provider) prepareClusterDir(clusterDir, id string) (err error) {
 	_, err = os.Stat(clusterDir)
 	if err == nil {
-		return clusterDir, errors.Errorf("cluster terraform dir already exists: %q", clusterDir)
+		return errors.Errorf("cluster terraform dir already exists: %q", clusterDir)
 	}
 
 	moduleDir := filepath.Join(clusterDir, "modules/labagent")
 	err = os.MkdirAll(moduleDir, 0775)
 	if err != nil {
-		return clusterDir, err
+		return err
 	}
 	defer func() {
 		if err != nil {
@@ -179,24 +401,24 @@ func (p *provider) prepareClusterDir(id string) (clusterDir string, err error) {
 	} {
 		dst, err := filepath.Abs(filepath.Join(terraformDir, p))
 		if err != nil {
-			return clusterDir, err
+			return err
 		}
 
 		src, err := filepath.Abs(filepath.Join(clusterDir, p))
 		if err != nil {
-			return clusterDir, err
+			return err
 		}
 
 		err = os.Symlink(dst, src)
 		if err != nil {
-			return clusterDir, err
+			return err
 		}
 	}
 
-	maintfPath := filepath.Join(p.root, id, "main.tf")
+	maintfPath := filepath.Join(clusterDir, "main.tf")
 	f, err := os.Create(maintfPath)
 	if err != nil {
-		return clusterDir, err
+		return err
 	}
 	defer f.Close()
 
@@ -208,10 +430,10 @@ func (p *provider) prepareClusterDir(id string) (clusterDir string, err error) {
 
 	err = p.maintf.Execute(f, &vars)
 	if err != nil {
-		return clusterDir, err
+		return err
 	}
 
-	return clusterDir, nil
+	return nil
 }
 
 func (p *provider) destroyClusterDir(id string) error {
@@ -219,22 +441,101 @@ func (p *provider) destroyClusterDir(id string) error {
 	return os.RemoveAll(clusterDir)
 }
 
-func (p *provider) executeTfvarsTemplate(id string, cdef metadata.ClusterDefinition) error {
-	vars := ClusterVars{ID: id}
+// ingressFromConnectivity converts the connectivity rules of a cluster
+// definition into security group ingress rules for each group.
+func ingressFromConnectivity(id string, cdef metadata.ClusterDefinition) (map[int][]IngressVars, error) {
+	indexByName := make(map[string]int)
+	for i := range cdef.Groups {
+		indexByName[cdef.GroupName(i)] = i
+	}
+
+	ingressByGroup := make(map[int][]IngressVars)
+	for _, rule := range cdef.Connectivity {
+		from, ok := indexByName[rule.From]
+		if !ok {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "connectivity rule references unknown group %q", rule.From)
+		}
+
+		to, ok := indexByName[rule.To]
+		if !ok {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "connectivity rule references unknown group %q", rule.To)
+		}
+
+		if cdef.Groups[from].Region != cdef.Groups[to].Region {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "connectivity rule from %q to %q crosses regions, which security groups cannot express", rule.From, rule.To)
+		}
+
+		source := fmt.Sprintf("%s-%d", id, from)
+		if len(rule.Ports) == 0 {
+			// AWS requires rules of all protocols to have no port range.
+			protocol, toPort := rule.Protocol, 65535
+			if protocol == "" {
+				protocol, toPort = "-1", 0
+			}
+
+			ingressByGroup[to] = append(ingressByGroup[to], IngressVars{
+				From:     source,
+				Protocol: protocol,
+				FromPort: 0,
+				ToPort:   toPort,
+			})
+			continue
+		}
+
+		protocols := []string{rule.Protocol}
+		if rule.Protocol == "" {
+			protocols = []string{"tcp", "udp"}
+		}
+
+		for _, ports := range rule.Ports {
+			fromPort, toPort, err := metadata.PortRange(ports)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, protocol := range protocols {
+				ingressByGroup[to] = append(ingressByGroup[to], IngressVars{
+					From:     source,
+					Protocol: protocol,
+					FromPort: fromPort,
+					ToPort:   toPort,
+				})
+			}
+		}
+	}
+
+	return ingressByGroup, nil
+}
+
+func (p *provider) executeTfvarsTemplate(clusterDir, id string, cdef metadata.ClusterDefinition, images map[string]EC2Image) error {
+	vars := ClusterVars{ID: id, LabID: p.labID, LabdCIDRs: p.labdCIDRs, NetworkStack: cdef.NetworkStack}
+	if vars.NetworkStack == "" {
+		vars.NetworkStack = metadata.NetworkIPv4
+	}
 
-	clusterGroupsByRegion := map[string]RegionalClusterGroups{
-		"us-west-2": RegionalClusterGroups{Region: "us-west-2"},
-		"us-east-1": RegionalClusterGroups{Region: "us-east-1"},
-		"eu-west-1": RegionalClusterGroups{Region: "eu-west-1"},
+	clusterGroupsByRegion := make(map[string]RegionalClusterGroups)
+	for _, region := range Regions {
+		clusterGroupsByRegion[region] = RegionalClusterGroups{Region: region}
 	}
 
-	for _, group := range cdef.Groups {
+	ingressByGroup, err := ingressFromConnectivity(id, cdef)
+	if err != nil {
+		return err
+	}
+
+	for i, group := range cdef.Groups {
 		rcg, ok := clusterGroupsByRegion[group.Region]
 		if !ok {
 			return errors.Wrapf(errdefs.ErrInvalidArgument, "unsupported region %q", group.Region)
 		}
 
-		rcg.Groups = append(rcg.Groups, group)
+		rcg.Groups = append(rcg.Groups, ClusterGroupVars{
+			ClusterGroup:   group,
+			ASG:            fmt.Sprintf("%s-%d", id, i),
+			RootDeviceName: images[group.Image].RootDeviceName,
+			Isolated:       len(cdef.Connectivity) > 0,
+			Ingress:        ingressByGroup[i],
+		})
 		clusterGroupsByRegion[group.Region] = rcg
 	}
 
@@ -245,7 +546,7 @@ func (p *provider) executeTfvarsTemplate(id string, cdef metadata.ClusterDefinit
 		return vars.RegionalClusterGroups[i].Region < vars.RegionalClusterGroups[j].Region
 	})
 
-	tfvarsPath := filepath.Join(p.root, id, "terraform.tfvars")
+	tfvarsPath := filepath.Join(clusterDir, "terraform.tfvars")
 	f, err := os.Create(tfvarsPath)
 	if err != nil {
 		return err
@@ -259,3 +560,33 @@ func (p *provider) executeTfvarsTemplate(id string, cdef metadata.ClusterDefinit
 
 	return nil
 }
+
+// hostCIDRs returns the addresses of the host's network interfaces as single
+// address CIDRs, excluding loopback and link-local addresses which nodes
+// cannot be reached from.
+func hostCIDRs() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var cidrs []string
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		ip := ipnet.IP
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+
+		if ip.To4() != nil {
+			cidrs = append(cidrs, fmt.Sprintf("%s/32", ip))
+		} else {
+			cidrs = append(cidrs, fmt.Sprintf("%s/128", ip))
+		}
+	}
+	return cidrs, nil
+}
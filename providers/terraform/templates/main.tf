@@ -31,6 +31,9 @@ module "labagent_us-west-2" {
   }
 
   cluster_id                = var.cluster_id
+  lab_id                    = var.lab_id
+  network_stack             = var.network_stack
+  labd_cidr_blocks          = var.labd_cidr_blocks
   labagents                 = var.labagents["us-west-2"]
   labagent_instance_profile = var.labagent_instance_profile
   internal_subnets          = var.internal_subnets["us-west-2"]
@@ -44,6 +47,9 @@ module "labagent_us-east-1" {
   }
 
   cluster_id                = var.cluster_id
+  lab_id                    = var.lab_id
+  network_stack             = var.network_stack
+  labd_cidr_blocks          = var.labd_cidr_blocks
   labagents                 = var.labagents["us-east-1"]
   labagent_instance_profile = var.labagent_instance_profile
   internal_subnets          = var.internal_subnets["us-east-1"]
@@ -57,6 +63,9 @@ module "labagent_eu-west-1" {
   }
 
   cluster_id                = var.cluster_id
+  lab_id                    = var.lab_id
+  network_stack             = var.network_stack
+  labd_cidr_blocks          = var.labd_cidr_blocks
   labagents                 = var.labagents["eu-west-1"]
   labagent_instance_profile = var.labagent_instance_profile
   internal_subnets          = var.internal_subnets["eu-west-1"]
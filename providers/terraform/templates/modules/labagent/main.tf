@@ -19,6 +19,92 @@ data "aws_security_group" "labagent" {
   }
 }
 
+data "aws_vpc" "labagent" {
+  id = data.aws_security_group.labagent.vpc_id
+}
+
+# Groups of clusters with connectivity rules get their own security group so
+# that traffic between groups is denied unless explicitly allowed.
+resource "aws_security_group" "group" {
+  for_each = { for k, v in var.labagents : k => v if v.isolated }
+
+  name   = "p2plab-${each.key}"
+  vpc_id = data.aws_security_group.labagent.vpc_id
+
+  # Allow labd to reach labagent and labapp.
+  ingress {
+    from_port        = 7002
+    to_port          = 7003
+    protocol         = "tcp"
+    cidr_blocks      = [data.aws_vpc.labagent.cidr_block]
+    ipv6_cidr_blocks = var.network_stack == "ipv4" ? [] : [data.aws_vpc.labagent.ipv6_cidr_block]
+  }
+
+  # Allow labd's seeder to dial peers, whose libp2p port is random.
+  dynamic "ingress" {
+    for_each = length(var.labd_cidr_blocks) > 0 ? [var.labd_cidr_blocks] : []
+
+    content {
+      from_port        = 0
+      to_port          = 0
+      protocol         = "-1"
+      cidr_blocks      = [for cidr in ingress.value : cidr if length(regexall(":", cidr)) == 0]
+      ipv6_cidr_blocks = [for cidr in ingress.value : cidr if length(regexall(":", cidr)) > 0]
+    }
+  }
+
+  # Connectivity rules only restrict traffic between groups, so nodes of the
+  # same group can always reach each other.
+  ingress {
+    from_port = 0
+    to_port   = 0
+    protocol  = "-1"
+    self      = true
+  }
+
+  egress {
+    from_port        = 0
+    to_port          = 0
+    protocol         = "-1"
+    cidr_blocks      = ["0.0.0.0/0"]
+    ipv6_cidr_blocks = ["::/0"]
+  }
+
+  tags = {
+    p2plab-lab     = var.lab_id
+    p2plab-cluster = var.cluster_id
+  }
+}
+
+resource "aws_security_group_rule" "ingress" {
+  for_each = {
+    for rule in flatten([
+      for k, v in var.labagents : [
+        for i, ingress in v.ingress : merge(ingress, { key = "${k}-${i}", to = k })
+      ]
+    ]) : rule.key => rule
+  }
+
+  type                     = "ingress"
+  security_group_id        = aws_security_group.group[each.value.to].id
+  source_security_group_id = aws_security_group.group[each.value.from].id
+  protocol                 = each.value.protocol
+  from_port                = each.value.from_port
+  to_port                  = each.value.to_port
+}
+
+# Spread placement groups hold at most 7 running instances per availability
+# zone, so larger spread groups are only distributed across zones.
+resource "aws_placement_group" "labagent" {
+  for_each = {
+    for k, v in var.labagents : k => v
+    if v.placement == "pack" || v.size <= 7 * length(var.internal_subnets)
+  }
+
+  name     = each.key
+  strategy = each.value.placement == "pack" ? "cluster" : "spread"
+}
+
 resource "aws_autoscaling_group" "labagent" {
   for_each = var.labagents
 
@@ -27,22 +113,78 @@ resource "aws_autoscaling_group" "labagent" {
   min_size            = each.value.size
   desired_capacity    = each.value.size
   health_check_type   = "EC2"
-  vpc_zone_identifier = var.internal_subnets
+  placement_group     = lookup(aws_placement_group.labagent, each.key, null) != null ? aws_placement_group.labagent[each.key].id : null
+  vpc_zone_identifier = each.value.placement == "pack" ? [var.internal_subnets[0]] : var.internal_subnets
+
+  # Capacity shortfalls are handled by labd, which replaces failed instances
+  # or tolerates them, so terraform shouldn't block on a full ASG.
+  wait_for_capacity_timeout = "0"
 
   launch_template {
     id = aws_launch_template.labagent[each.key].id
   }
+
+  tag {
+    key                 = "p2plab-lab"
+    value               = var.lab_id
+    propagate_at_launch = true
+  }
+
+  tag {
+    key                 = "p2plab-cluster"
+    value               = var.cluster_id
+    propagate_at_launch = true
+  }
 }
 
 resource "aws_launch_template" "labagent" {
   for_each = var.labagents
 
-  image_id               = data.aws_ami.labagent.id
+  image_id               = each.value.image_id != "" ? each.value.image_id : data.aws_ami.labagent.id
   name                   = each.key
   instance_type          = each.value.instance_type
-  vpc_security_group_ids = [data.aws_security_group.labagent.id]
+  vpc_security_group_ids = var.network_stack == "ipv4" ? [each.value.isolated ? aws_security_group.group[each.key].id : data.aws_security_group.labagent.id] : null
+  user_data              = each.value.user_data != "" ? each.value.user_data : null
 
   iam_instance_profile {
     name = var.labagent_instance_profile
   }
+
+  dynamic "block_device_mappings" {
+    for_each = each.value.volume_size > 0 ? [each.value] : []
+
+    content {
+      device_name = each.value.root_device != "" ? each.value.root_device : data.aws_ami.labagent.root_device_name
+
+      ebs {
+        volume_size           = block_device_mappings.value.volume_size
+        volume_type           = block_device_mappings.value.volume_type != "" ? block_device_mappings.value.volume_type : "gp2"
+        iops                  = block_device_mappings.value.volume_iops > 0 ? block_device_mappings.value.volume_iops : null
+        delete_on_termination = true
+      }
+    }
+  }
+
+  # Instance stores are mapped from /dev/sdb onwards.
+  dynamic "block_device_mappings" {
+    for_each = range(each.value.instance_stores)
+
+    content {
+      device_name  = "/dev/sd${substr("bcdefghijklmnopqrstuvwxy", block_device_mappings.value, 1)}"
+      virtual_name = "ephemeral${block_device_mappings.value}"
+    }
+  }
+
+  dynamic "network_interfaces" {
+    for_each = var.network_stack == "ipv4" ? [] : [var.network_stack]
+
+    content {
+      ipv6_address_count = 1
+      security_groups    = [each.value.isolated ? aws_security_group.group[each.key].id : data.aws_security_group.labagent.id]
+    }
+  }
+
+  placement {
+    tenancy = each.value.dedicated_instances ? "dedicated" : "default"
+  }
 }
@@ -2,10 +2,39 @@ variable "cluster_id" {
 	type = string
 }
 
+variable "lab_id" {
+	type = string
+}
+
+variable "network_stack" {
+	type = string
+}
+
+variable "labd_cidr_blocks" {
+  type    = list(string)
+  default = []
+}
+
 variable "labagents" {
   type = map(map(object({
     size          = number
     instance_type = string
+    image_id      = string
+    user_data     = string
+    placement     = string
+    dedicated_instances = bool
+    volume_size   = number
+    volume_type   = string
+    volume_iops   = number
+    root_device   = string
+    instance_stores = number
+    isolated      = bool
+    ingress = list(object({
+      from      = string
+      protocol  = string
+      from_port = number
+      to_port   = number
+    }))
   })))
 }
 
@@ -15,7 +15,9 @@
 package terraform
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os/exec"
@@ -29,6 +31,11 @@ import (
 	"github.com/rs/zerolog"
 )
 
+var (
+	// LaunchTimeout is how long to wait for an ASG to launch its instances.
+	LaunchTimeout = 10 * time.Minute
+)
+
 type Terraform struct {
 	root    string
 	leaseCh chan struct{}
@@ -73,22 +80,37 @@ func (t *Terraform) Apply(ctx context.Context, id string, cdef metadata.ClusterD
 		return nil, errors.Wrap(err, "failed to auto-approve apply templates")
 	}
 
+	return DiscoverNodes(ctx, id, cdef, nil)
+}
+
+// DiscoverNodes waits for the ASGs of a cluster to launch their instances and
+// returns them as nodes, skipping instances in exclude. Instances that fail to
+// launch in time are left out, so callers must handle a shortfall.
+func DiscoverNodes(ctx context.Context, id string, cdef metadata.ClusterDefinition, exclude map[string]struct{}) ([]metadata.Node, error) {
 	var ns []metadata.Node
 	for i, cg := range cdef.Groups {
 		asg := fmt.Sprintf("%s-%d", id, i)
 
 		zerolog.Ctx(ctx).Debug().Str("asg", asg).Msg("Discovering instances in ASG")
-		instances, err := DiscoverInstances(ctx, asg, cg.Region)
+		instances, err := waitForInstances(ctx, asg, cg.Region, cg.Size)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to discover instances for ASG %q in %q", asg, cg.Region)
 		}
 
 		for _, instance := range instances {
+			_, ok := exclude[instance.InstanceId]
+			if ok {
+				continue
+			}
+
 			n := metadata.Node{
-				ID:        instance.InstanceId,
-				Address:   instance.PrivateIp,
-				AgentPort: DefaultAgentPort,
-				AppPort:   DefaultAppPort,
+				ID:           instance.InstanceId,
+				Address:      instance.Address(cdef.NetworkStack),
+				AgentPort:    DefaultAgentPort,
+				AppPort:      DefaultAppPort,
+				Region:       cg.Region,
+				InstanceType: instance.InstanceType,
+				Arch:         metadata.InstanceArch(instance.InstanceType),
 				Labels: append([]string{
 					instance.InstanceId,
 					instance.InstanceType,
@@ -105,6 +127,88 @@ func (t *Terraform) Apply(ctx context.Context, id string, cdef metadata.ClusterD
 	return ns, nil
 }
 
+// waitForInstances polls an ASG until it has size instances in service or
+// LaunchTimeout elapses, in which case the instances launched so far are
+// returned.
+func waitForInstances(ctx context.Context, asg, region string, size int) ([]EC2Instance, error) {
+	timeout := time.After(LaunchTimeout)
+	for {
+		instances, err := DiscoverInstances(ctx, asg, region)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(instances) >= size {
+			return instances, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout:
+			zerolog.Ctx(ctx).Warn().Str("asg", asg).Int("size", size).Int("instances", len(instances)).Msg("Timed out waiting for instances to launch")
+			return instances, nil
+		case <-time.After(15 * time.Second):
+		}
+	}
+}
+
+// Plan returns the addresses of the resources terraform would create without
+// applying the configuration.
+func (t *Terraform) Plan(ctx context.Context, id string) ([]string, error) {
+	err := t.acquireLease()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		t.leaseCh <- struct{}{}
+	}()
+
+	span, ctx := traceutil.StartSpanFromContext(ctx, "terraform.Plan")
+	defer span.Finish()
+	span.SetTag("cluster", id)
+
+	err = t.terraform(ctx, "plan", "-input=false", "-out=tfplan")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to plan templates")
+	}
+
+	logger := zerolog.Ctx(ctx).With().Str("exec", "show").Logger()
+	logWriter := logutil.NewWriter(&logger, zerolog.DebugLevel)
+	defer logWriter.Close()
+
+	stdout := new(bytes.Buffer)
+	err = t.terraformWithStdio(ctx, stdout, logWriter, "show", "-json", "tfplan")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to show plan")
+	}
+
+	var plan struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	err = json.NewDecoder(stdout).Decode(&plan)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode plan")
+	}
+
+	var resources []string
+	for _, rc := range plan.ResourceChanges {
+		for _, action := range rc.Change.Actions {
+			if action == "create" {
+				resources = append(resources, rc.Address)
+				break
+			}
+		}
+	}
+
+	return resources, nil
+}
+
 func (t *Terraform) Destroy(ctx context.Context, id string) error {
 	err := t.acquireLease()
 	if err != nil {
@@ -0,0 +1,81 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// userDataTemplate installs the bootstrap script and a systemd drop-in that
+// runs it before labagent starts. It uses cloud-init's bootcmd, which runs
+// before sysinit.target and therefore before labagent is started on boot.
+const userDataTemplate = `#cloud-config
+bootcmd:
+- mkdir -p %[1]s /etc/systemd/system/labagent.service.d
+- echo %[2]s | base64 -d > %[1]s/bootstrap
+- echo %[3]s | base64 -d > %[1]s/run-bootstrap
+- chmod +x %[1]s/bootstrap %[1]s/run-bootstrap
+- echo %[4]s | base64 -d > /etc/systemd/system/labagent.service.d/p2plab-bootstrap.conf
+- systemctl daemon-reload
+`
+
+// bootstrapTemplate runs the bootstrap script once per instance. It never
+// keeps labagent from starting, so that a failed script is reported by
+// labagent's health check instead of leaving the node unreachable until
+// cluster creation times out.
+const bootstrapTemplate = `#!/bin/sh
+dir=%[1]s
+if [ -e $dir/bootstrap.done ] || [ -e %[2]s ]; then
+	exit 0
+fi
+
+if $dir/bootstrap > $dir/bootstrap.log 2>&1; then
+	touch $dir/bootstrap.done
+else
+	echo "bootstrap script exited with status $?, see $dir/bootstrap.log" > %[2]s
+fi
+`
+
+// bootstrapDropIn orders the bootstrap script before labagent, without a
+// timeout since the script may take a while, e.g. to install packages.
+const bootstrapDropIn = `[Service]
+TimeoutStartSec=infinity
+ExecStartPre=%s/run-bootstrap
+`
+
+// UserData returns the base64 encoded EC2 user data that runs a bootstrap
+// script before labagent starts, or an empty string if there is none.
+func UserData(bootstrap string) string {
+	if bootstrap == "" {
+		return ""
+	}
+
+	dir := filepath.Dir(metadata.BootstrapFailedPath)
+	userData := fmt.Sprintf(userDataTemplate,
+		dir,
+		encode(bootstrap),
+		encode(fmt.Sprintf(bootstrapTemplate, dir, metadata.BootstrapFailedPath)),
+		encode(fmt.Sprintf(bootstrapDropIn, dir)),
+	)
+	return encode(userData)
+}
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
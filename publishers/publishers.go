@@ -0,0 +1,54 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publishers
+
+import (
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/pkg/httputil"
+	"github.com/Netflix/p2plab/publishers/s3publisher"
+	"github.com/pkg/errors"
+)
+
+// GCSEndpoint is the S3 compatible endpoint of Google Cloud Storage, which
+// authenticates with HMAC keys in place of AWS credentials.
+const GCSEndpoint = "https://storage.googleapis.com"
+
+type PublisherSettings struct {
+	Client *httputil.Client
+	S3     s3publisher.S3PublisherSettings
+}
+
+// GetPublisher returns a publisher of the given type, or nil if the type is
+// empty and publishing is disabled.
+func GetPublisher(publisherType string, settings PublisherSettings) (p2plab.Publisher, error) {
+	switch publisherType {
+	case "":
+		return nil, nil
+	case "s3":
+		return s3publisher.New(settings.Client.HTTPClient, settings.S3)
+	case "gcs":
+		s3Settings := settings.S3
+		if s3Settings.Endpoint == "" {
+			s3Settings.Endpoint = GCSEndpoint
+		}
+		if s3Settings.Region == "" {
+			s3Settings.Region = "auto"
+		}
+		return s3publisher.New(settings.Client.HTTPClient, s3Settings)
+	default:
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized publisher type %q", publisherType)
+	}
+}
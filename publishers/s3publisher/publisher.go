@@ -0,0 +1,135 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/s3manager"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// DefaultExpiry is how long presigned links remain valid, which is the most
+// that S3 allows.
+const DefaultExpiry = 7 * 24 * time.Hour
+
+type S3PublisherSettings struct {
+	Bucket string
+	Prefix string
+	Region string
+
+	// Endpoint is the URL of an S3 compatible object store, such as GCS. When
+	// empty, AWS S3 is used.
+	Endpoint string
+
+	// BaseURL is the URL that objects in the bucket are publicly served
+	// under, such as a CDN. When empty, links are presigned instead.
+	BaseURL string
+
+	// Expiry is how long presigned links remain valid. Defaults to
+	// DefaultExpiry.
+	Expiry time.Duration
+}
+
+type publisher struct {
+	bucket        string
+	prefix        string
+	baseURL       string
+	expiry        time.Duration
+	client        *s3.Client
+	uploadManager *s3manager.Uploader
+}
+
+func New(client *http.Client, settings S3PublisherSettings) (p2plab.Publisher, error) {
+	if settings.Bucket == "" {
+		return nil, errors.New("s3 publisher requires a bucket")
+	}
+
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load aws config")
+	}
+	cfg.Region = settings.Region
+	cfg.HTTPClient = client
+	if settings.Endpoint != "" {
+		cfg.EndpointResolver = aws.ResolveWithEndpointURL(settings.Endpoint)
+	}
+
+	expiry := settings.Expiry
+	if expiry == 0 {
+		expiry = DefaultExpiry
+	}
+
+	s3Client := s3.New(cfg)
+	if settings.Endpoint != "" {
+		// Buckets of other object stores are not necessarily resolvable as
+		// subdomains of the endpoint.
+		s3Client.ForcePathStyle = true
+	}
+
+	return &publisher{
+		bucket:        settings.Bucket,
+		prefix:        settings.Prefix,
+		baseURL:       strings.TrimSuffix(settings.BaseURL, "/"),
+		expiry:        expiry,
+		client:        s3Client,
+		uploadManager: s3manager.NewUploaderWithClient(s3Client),
+	}, nil
+}
+
+func (p *publisher) Close() error {
+	return nil
+}
+
+func (p *publisher) Publish(ctx context.Context, key, contentType string, r io.Reader) (string, error) {
+	key = path.Join(p.prefix, key)
+	zerolog.Ctx(ctx).Debug().Str("bucket", p.bucket).Str("key", key).Msg("Publishing object")
+
+	_, err := p.uploadManager.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(p.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        r,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to upload %q", key)
+	}
+
+	if p.baseURL != "" {
+		return fmt.Sprintf("%s/%s", p.baseURL, key), nil
+	}
+
+	req := p.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(key),
+	})
+	link, err := req.Presign(p.expiry)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to presign %q", key)
+	}
+
+	return link, nil
+}
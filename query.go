@@ -25,6 +25,15 @@ type Labeled interface {
 	Labels() []string
 }
 
+// Attributed is a labeled resource that also has structured attributes that
+// can be queried, such as the region of a node.
+type Attributed interface {
+	Labeled
+
+	// Attributes returns the values of the resource's attributes by name.
+	Attributes() map[string]string
+}
+
 // LabeledSet is a set of labeled resources, duplicate resources are detected
 // by the ID of the labeled resource.
 type LabeledSet interface {
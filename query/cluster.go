@@ -0,0 +1,96 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+)
+
+type clustersKey struct{}
+
+// WithClusters returns a context in which cluster queries match the nodes of
+// other clusters by the cluster's name.
+func WithClusters(ctx context.Context, clusters map[string]p2plab.LabeledSet) context.Context {
+	return context.WithValue(ctx, clustersKey{}, clusters)
+}
+
+// Clusters returns the names of the clusters that a query selects nodes
+// from with cluster queries, so that only their nodes need to be listed.
+func Clusters(q string) []string {
+	// A query with an unterminated quotation names no clusters, and its
+	// error is returned once it is executed.
+	tokens, _ := tokenize(q)
+
+	var names []string
+	for i := 2; i < len(tokens); i++ {
+		if tokens[i-2].value == "(" && tokens[i-1].value == "cluster" {
+			names = append(names, strings.Trim(tokens[i].value, "'"))
+		}
+	}
+	return names
+}
+
+type clusterQuery struct {
+	name  string
+	query p2plab.Query
+}
+
+func newClusterQuery(arg string, queries []p2plab.Query) (p2plab.Query, error) {
+	if len(queries) != 1 {
+		return nil, errors.New("cluster query must have a cluster name and exactly 1 argument")
+	}
+
+	name := strings.Trim(arg, "'")
+	if name == "" || strings.ContainsAny(name, "'") {
+		return nil, errors.Errorf("invalid cluster name %q", arg)
+	}
+
+	return &clusterQuery{name, queries[0]}, nil
+}
+
+func (q *clusterQuery) String() string {
+	return fmt.Sprintf("(cluster '%s' %s)", q.name, q.query)
+}
+
+// nodes returns the nodes of the query's cluster.
+func (q *clusterQuery) nodes(ctx context.Context) (p2plab.LabeledSet, error) {
+	clusters, ok := ctx.Value(clustersKey{}).(map[string]p2plab.LabeledSet)
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrUnavailable, "%s query requires the nodes of other clusters", q)
+	}
+
+	cset, ok := clusters[q.name]
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrNotFound, "cluster %q", q.name)
+	}
+	return cset, nil
+}
+
+// Match returns the nodes of the query's cluster that its subquery matches,
+// instead of the nodes of lset.
+func (q *clusterQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	cset, err := q.nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.query.Match(ctx, cset)
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCluster(t *testing.T) {
+	ns := []p2plab.Labeled{
+		NewLabeled("client-a", []string{"us-west"}),
+		NewLabeled("client-b", []string{"us-east"}),
+	}
+	edge := []p2plab.Labeled{
+		NewLabeled("gateway-a", []string{"us-west"}),
+		NewLabeled("gateway-b", []string{"us-east"}),
+	}
+
+	eset := NewLabeledSet()
+	for _, l := range edge {
+		eset.Add(l)
+	}
+	ctx := WithClusters(context.Background(), map[string]p2plab.LabeledSet{"edge": eset})
+
+	for _, test := range []struct {
+		in  string
+		out []p2plab.Labeled
+	}{
+		{"'us-west'", []p2plab.Labeled{ns[0]}},
+		{"(cluster 'edge' 'us-west')", []p2plab.Labeled{edge[0]}},
+		{"(cluster 'edge' (not 'us-west'))", []p2plab.Labeled{edge[1]}},
+		{"(or 'us-west' (cluster 'edge' 'us-west'))", []p2plab.Labeled{ns[0], edge[0]}},
+		{"(xor 'us-west' (cluster 'edge' 'us-west'))", []p2plab.Labeled{ns[0], edge[0]}},
+		{"(and 'us-west' (cluster 'edge' 'us-west'))", nil},
+	} {
+		mset, err := Execute(ctx, ns, test.in)
+		require.NoError(t, err)
+		require.Equal(t, test.out, mset.Slice(), test.in)
+	}
+
+	_, err := Execute(ctx, ns, "(cluster 'core' '*')")
+	require.True(t, errdefs.IsNotFound(err))
+
+	// Without the nodes of other clusters, cluster queries cannot be matched.
+	_, err = Execute(context.Background(), ns, "(cluster 'edge' '*')")
+	require.True(t, errdefs.IsUnavailable(err))
+}
+
+func TestClusters(t *testing.T) {
+	require.Equal(t, []string{"edge", "core"}, Clusters("(or (cluster 'edge' 'a') (cluster core 'b'))"))
+	require.Empty(t, Clusters("(and 'cluster' 'edge')"))
+}
@@ -24,9 +24,9 @@ import (
 )
 
 var ls = []p2plab.Labeled{
-	NewLabeled("apple", []string{"everyone", "apple", "slowdisk", "region=us-west-2"}),
-	NewLabeled("banana", []string{"everyone", "banana", "region=us-west-2"}),
-	NewLabeled("cherry", []string{"everyone", "cherry", "region=us-east-1"}),
+	NewLabeled("apple", []string{"everyone", "apple", "slowdisk", "region=us-west-2", "latency=100", "size=8"}),
+	NewLabeled("banana", []string{"everyone", "banana", "region=us-west-2", "latency=250", "size=large"}),
+	NewLabeled("cherry", []string{"everyone", "cherry", "region=us-east-1", "timeout=5s"}),
 }
 
 var executetest = []struct {
@@ -38,6 +38,19 @@ var executetest = []struct {
 	{"(and 'slowdisk' 'region=us-west-2')", []p2plab.Labeled{ls[0]}},
 	{"(or 'region=us-west-2' 'region=us-east-1')", ls},
 	{"(or (not 'slowdisk') 'banana')", []p2plab.Labeled{ls[1], ls[2]}},
+	{"(> latency 100)", []p2plab.Labeled{ls[1]}},
+	{"(>= latency 100)", []p2plab.Labeled{ls[0], ls[1]}},
+	{"(and (< 'latency' 200) 'everyone')", []p2plab.Labeled{ls[0]}},
+	{"(not (> latency 100))", []p2plab.Labeled{ls[0], ls[2]}},
+	{"(>= size 8)", []p2plab.Labeled{ls[0]}},
+	{"(!= latency 100)", []p2plab.Labeled{ls[1]}},
+	{"(< timeout 10s)", []p2plab.Labeled{ls[2]}},
+	{"(diff 'everyone' 'apple')", []p2plab.Labeled{ls[1], ls[2]}},
+	{"(diff 'everyone' 'apple' 'region=us-east-1')", []p2plab.Labeled{ls[1]}},
+	{"(diff 'slowdisk' 'everyone')", nil},
+	{"(xor 'region=us-west-2' 'slowdisk')", []p2plab.Labeled{ls[1]}},
+	{"(xor 'apple' 'cherry')", []p2plab.Labeled{ls[0], ls[2]}},
+	{"(or (diff 'everyone' 'region=us-west-2') 'slowdisk')", []p2plab.Labeled{ls[0], ls[2]}},
 }
 
 func TestExecute(t *testing.T) {
@@ -50,3 +63,92 @@ func TestExecute(t *testing.T) {
 		require.Equal(t, execute.out, labeledSet.Slice())
 	}
 }
+
+func TestExecuteInvalidComparison(t *testing.T) {
+	ctx := context.Background()
+
+	for _, q := range []string{
+		"(> latency)",
+		"(> latency 100 200)",
+		"(> latency fast)",
+		"(diff 'everyone')",
+		"(xor 'apple')",
+		"(xor 'apple' 'banana' 'cherry')",
+	} {
+		_, err := Execute(ctx, ls, q)
+		require.Error(t, err, q)
+	}
+}
+
+var ms = []p2plab.Labeled{
+	NewLabeled("i-0a1b2c", []string{"region=us-west-2"}),
+	NewLabeled("i-0d3e4f", []string{"region=us-east-1", "slowdisk"}),
+	NewLabeled("i-9f8e7d", []string{"region=eu-west-1"}),
+}
+
+var matchtest = []struct {
+	in  string
+	out []p2plab.Labeled
+}{
+	{"'i-0*'", []p2plab.Labeled{ms[0], ms[1]}},
+	{"'region=*-west-*'", []p2plab.Labeled{ms[0], ms[2]}},
+	{"(match 'region=us-.*')", []p2plab.Labeled{ms[0], ms[1]}},
+	{"(match 'i-[0-9]{1}[a-f].*')", []p2plab.Labeled{ms[0], ms[1], ms[2]}},
+	{"(match 'region=us-(west|east)-[0-9]')", []p2plab.Labeled{ms[0], ms[1]}},
+	{"(match 'west')", nil},
+	{"(and (match '.*-west-.*') (not 'i-9*'))", []p2plab.Labeled{ms[0]}},
+}
+
+func TestExecuteMatch(t *testing.T) {
+	ctx := context.Background()
+
+	for _, match := range matchtest {
+		labeledSet, err := Execute(ctx, ms, match.in)
+		require.NoError(t, err)
+		require.Equal(t, match.out, labeledSet.Slice(), match.in)
+	}
+}
+
+func TestExecuteInvalidMatch(t *testing.T) {
+	ctx := context.Background()
+
+	for _, q := range []string{
+		"(match)",
+		"(match 'a' 'b')",
+		"(match us-west)",
+		"(match 'us-(west')",
+	} {
+		_, err := Execute(ctx, ms, q)
+		require.Error(t, err, q)
+	}
+}
+
+var as = []p2plab.Labeled{
+	NewAttributed("i-0a1b2c", []string{"everyone"}, map[string]string{"region": "us-west-2", "arch": "arm64"}),
+	NewAttributed("i-0d3e4f", []string{"everyone"}, map[string]string{"region": "us-east-1", "arch": "amd64"}),
+	NewLabeled("i-9f8e7d", []string{"everyone", "region=us-west-2"}),
+}
+
+var attrtest = []struct {
+	in  string
+	out []p2plab.Labeled
+}{
+	{"(attr region us-west-2)", []p2plab.Labeled{as[0]}},
+	{"(attr region 'us-*')", []p2plab.Labeled{as[0], as[1]}},
+	{"(attr arch arm64)", []p2plab.Labeled{as[0]}},
+	{"(attr zone us-west-2a)", nil},
+	{"(and 'everyone' (not (attr arch arm64)))", []p2plab.Labeled{as[1], as[2]}},
+}
+
+func TestExecuteAttr(t *testing.T) {
+	ctx := context.Background()
+
+	for _, attr := range attrtest {
+		labeledSet, err := Execute(ctx, as, attr.in)
+		require.NoError(t, err)
+		require.Equal(t, attr.out, labeledSet.Slice(), attr.in)
+	}
+
+	_, err := Execute(ctx, as, "(attr region)")
+	require.Error(t, err)
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// Explain matches a query and each of its subqueries against lset, returning
+// the query's syntax tree with the resources that each subquery matches.
+func Explain(ctx context.Context, qry p2plab.Query, lset p2plab.LabeledSet) (metadata.QueryExplanation, error) {
+	e := metadata.QueryExplanation{
+		Query: qry.String(),
+	}
+
+	mset, err := qry.Match(ctx, lset)
+	if err != nil {
+		return e, err
+	}
+
+	for _, l := range mset.Slice() {
+		e.Matches = append(e.Matches, l.ID())
+	}
+
+	// Subqueries of a cluster query are matched against the nodes of its
+	// cluster.
+	if cq, ok := qry.(*clusterQuery); ok {
+		lset, err = cq.nodes(ctx)
+		if err != nil {
+			return e, err
+		}
+	}
+
+	for _, subquery := range subqueries(qry) {
+		se, err := Explain(ctx, subquery, lset)
+		if err != nil {
+			return e, err
+		}
+		e.Subqueries = append(e.Subqueries, se)
+	}
+
+	return e, nil
+}
+
+func subqueries(qry p2plab.Query) []p2plab.Query {
+	switch q := qry.(type) {
+	case *notQuery:
+		return []p2plab.Query{q.query}
+	case *andQuery:
+		return q.queries
+	case *orQuery:
+		return q.queries
+	case *diffQuery:
+		return q.queries
+	case *xorQuery:
+		return q.queries
+	case *sampleQuery:
+		return []p2plab.Query{q.query}
+	case *firstQuery:
+		return []p2plab.Query{q.query}
+	case *hopsQuery:
+		return []p2plab.Query{q.query}
+	case *splitQuery:
+		return []p2plab.Query{q.query}
+	case *clusterQuery:
+		return []p2plab.Query{q.query}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	ctx := context.Background()
+
+	lset := NewLabeledSet()
+	for _, l := range ls {
+		lset.Add(l)
+	}
+
+	qry, err := Parse(ctx, "(and 'region=us-west-2' (not 'slowdisk'))")
+	require.NoError(t, err)
+
+	explanation, err := Explain(ctx, qry, lset)
+	require.NoError(t, err)
+	require.Equal(t, metadata.QueryExplanation{
+		Query:   "(and 'region=us-west-2' (not 'slowdisk'))",
+		Matches: []string{"banana"},
+		Subqueries: []metadata.QueryExplanation{
+			{
+				Query:   "'region=us-west-2'",
+				Matches: []string{"apple", "banana"},
+			},
+			{
+				Query:   "(not 'slowdisk')",
+				Matches: []string{"banana", "cherry"},
+				Subqueries: []metadata.QueryExplanation{
+					{
+						Query:   "'slowdisk'",
+						Matches: []string{"apple"},
+					},
+				},
+			},
+		},
+	}, explanation)
+}
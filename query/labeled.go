@@ -32,3 +32,18 @@ func (l *labeled) ID() string {
 func (l *labeled) Labels() []string {
 	return l.labels
 }
+
+type attributed struct {
+	labeled
+	attrs map[string]string
+}
+
+// NewAttributed returns a labeled resource that can also be queried by its
+// attributes.
+func NewAttributed(id string, labels []string, attrs map[string]string) p2plab.Labeled {
+	return &attributed{labeled{id, labels}, attrs}
+}
+
+func (a *attributed) Attributes() map[string]string {
+	return a.attrs
+}
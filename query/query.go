@@ -17,7 +17,12 @@ package query
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/errdefs"
@@ -27,107 +32,388 @@ import (
 )
 
 // query := label
+//        | selection
 //        | '(' func expr ')'
+//        | '(' op key value ')'
+//        | '(' ‘match’ regexp ')'
+//        | '(' ‘sample’ fraction query ')'
+//        | '(' ‘first’ count query ')'
+//        | '(' ‘attr’ key value ')'
+//        | '(' ‘neighbors’ query ')'
+//        | '(' ‘hops’ count query ')'
 // expr := query
 //       | query expr
 // func := ‘not’
 //       | ‘and’
 //       | ‘or’
+//       | ‘diff’
+//       | ‘xor’
+// op := ‘<’
+//     | ‘<=’
+//     | ‘>’
+//     | ‘>=’
+//     | ‘=’
+//     | ‘!=’
 // label := quoted_string
+// selection := '@' name
+// regexp := quoted_string
+// fraction := number
+// count := integer
+//
+// A label is a glob pattern that matches either the labels or the ID of a
+// labeled resource, and a regexp must match one of them entirely.
+//
+// A comparison matches the labels of the form "<key>=<value>" whose value
+// compares to the query's value, as numbers or as durations such as "100ms".
+//
+// A sample chooses a random fraction of its query's matches, seeded by
+// WithRandomSeed, and first chooses the first count matches ordered by ID.
+//
+// A selection matches the resources of a named selection in the context,
+// see WithSelections.
+//
+// An attr query matches resources whose attribute, such as the region of a
+// node, matches the value as a glob pattern.
+//
+// Neighbors and hops match the nodes connected to the query's nodes directly
+// or within count connections, in the topology of WithTopology.
 func Parse(ctx context.Context, q string) (p2plab.Query, error) {
-	tokens := tokenize(q)
-	if len(tokens) == 0 {
-		tokens = []string{"*"}
-	}
+	return ParseVersion(ctx, q, Version)
+}
 
-	var (
-		qry p2plab.Query
-		err error
-	)
-	if len(tokens) == 1 {
-		label := strings.Trim(tokens[0], "'")
-		qry, err = newLabelQuery(fmt.Sprintf("'%s'", label))
-	} else {
-		qry, err = buildQuery(tokens)
+// ParseVersion parses a query written for a version of the query language,
+// where zero is the latest version. Syntax errors are returned as a
+// *ParseError.
+func ParseVersion(ctx context.Context, q string, version int) (p2plab.Query, error) {
+	if version == 0 {
+		version = Version
+	} else if version < 0 || version > Version {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "query version %d is not supported, must be at most %d", version, Version)
 	}
+
+	qry, err := parse(q, version)
 	if err != nil {
-		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "%s", err)
+		if perr, ok := err.(*ParseError); ok {
+			perr.Query = q
+		}
+		return nil, err
 	}
 
 	zerolog.Ctx(ctx).Debug().Msgf("Parsed query as %q", qry)
 	return qry, nil
 }
 
-func tokenize(q string) []string {
-	q = strings.ReplaceAll(q, "(", " ( ")
-	q = strings.ReplaceAll(q, ")", " ) ")
+func parse(q string, version int) (p2plab.Query, error) {
+	tokens, err := tokenize(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		tokens = []token{{"*", 0}}
+	}
+
+	err = checkVersion(tokens, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 1 && !strings.HasPrefix(tokens[0].value, SelectionPrefix) {
+		label := strings.Trim(tokens[0].value, "'")
+		qry, err := newLabelQuery(fmt.Sprintf("'%s'", label))
+		if err != nil {
+			return nil, newParseError(tokens[0].offset, err.Error())
+		}
+		return qry, nil
+	}
+
+	return buildQuery(tokens)
+}
+
+// Version is the latest version of the query language. Version 1 has labels
+// and the not, and and or functions, version 2 adds the other functions,
+// comparisons and selections, and version 3 adds split and cluster.
+const Version = 3
+
+// functionVersions are the versions of the query language that introduced
+// each function.
+var functionVersions = map[string]int{
+	"not": 1, "and": 1, "or": 1,
+	"diff": 2, "xor": 2, "match": 2, "attr": 2, "sample": 2, "first": 2,
+	"neighbors": 2, "hops": 2, "<": 2, "<=": 2, ">": 2, ">=": 2, "=": 2, "!=": 2,
+	"split": 3, "cluster": 3,
+}
 
-	var tokens []string
-	rawTokens := strings.Split(q, " ")
-	for _, t := range rawTokens {
-		stripped := strings.TrimSpace(t)
-		if stripped == "" {
+// functions returns the functions of a version of the query language, as the
+// expected tokens of a parse error.
+func functions(version int) []string {
+	var names []string
+	for name, v := range functionVersions {
+		if v <= version {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkVersion returns a parse error at the first function or selection that
+// the version of the query language does not have.
+func checkVersion(tokens []token, version int) error {
+	for i, t := range tokens {
+		if strings.HasPrefix(t.value, SelectionPrefix) && version < 2 {
+			return newParseError(t.offset, "selections require query version 2")
+		}
+
+		if t.value != "(" || i+1 == len(tokens) {
 			continue
 		}
-		tokens = append(tokens, stripped)
+
+		next := tokens[i+1]
+		if v, ok := functionVersions[next.value]; ok && v > version {
+			return newParseError(next.offset, fmt.Sprintf("function %q requires query version %d", next.value, v), functions(version)...)
+		}
+	}
+	return nil
+}
+
+// ParseError is a syntax error at a byte offset of a query, with the tokens
+// that were expected there if known.
+type ParseError struct {
+	Query    string
+	Offset   int
+	Message  string
+	Expected []string
+}
+
+func newParseError(offset int, message string, expected ...string) *ParseError {
+	return &ParseError{
+		Offset:   offset,
+		Message:  message,
+		Expected: expected,
+	}
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%s at offset %d", e.Message, e.Offset)
+	if len(e.Expected) > 0 {
+		var expected []string
+		for _, t := range e.Expected {
+			expected = append(expected, fmt.Sprintf("%q", t))
+		}
+		msg = fmt.Sprintf("%s, expected %s", msg, strings.Join(expected, " or "))
+	}
+	return msg
+}
+
+// Cause returns errdefs.ErrInvalidArgument so that parse errors are reported
+// as invalid arguments.
+func (e *ParseError) Cause() error {
+	return errdefs.ErrInvalidArgument
+}
+
+type token struct {
+	value  string
+	offset int
+}
+
+func values(tokens []token) []string {
+	var vs []string
+	for _, t := range tokens {
+		vs = append(vs, t.value)
+	}
+	return vs
+}
+
+// tokenize splits a query into parentheses and space separated tokens with
+// their byte offsets. Quoted strings are kept as a single token so that
+// patterns may contain parentheses.
+func tokenize(q string) ([]token, error) {
+	var (
+		tokens []token
+		value  strings.Builder
+		offset int
+		quoted bool
+	)
+
+	flush := func() {
+		if value.Len() > 0 {
+			tokens = append(tokens, token{value.String(), offset})
+			value.Reset()
+		}
+	}
+
+	for i, r := range q {
+		if value.Len() == 0 {
+			offset = i
+		}
+
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			value.WriteRune(r)
+		case quoted:
+			value.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, token{string(r), i})
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			value.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, newParseError(offset, "unterminated quotation", "'")
+	}
+	flush()
+
+	return tokens, nil
+}
+
+// closingParen returns the index of the parenthesis that closes the one at
+// tokens[0], or -1 if it is never closed.
+func closingParen(tokens []token) int {
+	parens := 0
+	for i, t := range tokens {
+		switch t.value {
+		case "(":
+			parens++
+		case ")":
+			parens--
+			if parens == 0 {
+				return i
+			}
+		}
 	}
-	return tokens
+	return -1
 }
 
-func buildQuery(tokens []string) (p2plab.Query, error) {
+func buildQuery(tokens []token) (p2plab.Query, error) {
 	// First token is either a start of a function or a label query.
-	if tokens[0] != "(" {
+	if tokens[0].value != "(" {
 		if len(tokens) > 1 {
-			return nil, errors.New("unexpected trailing tokens")
+			return nil, newParseError(tokens[1].offset, fmt.Sprintf("unexpected token %q", tokens[1].value), "end of query")
+		}
+
+		var (
+			qry p2plab.Query
+			err error
+		)
+		switch {
+		case tokens[0].value == ")":
+			return nil, newParseError(tokens[0].offset, "unexpected closing parenthesis", "label", "(")
+		case strings.HasPrefix(tokens[0].value, SelectionPrefix):
+			qry, err = newSelectionQuery(tokens[0].value)
+		default:
+			qry, err = newLabelQuery(tokens[0].value)
+		}
+		if err != nil {
+			return nil, newParseError(tokens[0].offset, err.Error())
 		}
+		return qry, nil
+	}
 
-		return newLabelQuery(tokens[0])
+	end := closingParen(tokens)
+	if end < 0 {
+		return nil, newParseError(tokens[0].offset, "unclosed parenthesis", ")")
+	} else if end < len(tokens)-1 {
+		return nil, newParseError(tokens[end+1].offset, fmt.Sprintf("unexpected token %q", tokens[end+1].value), "end of query")
+	} else if end < 2 {
+		return nil, newParseError(tokens[end].offset, "query must have a function and an expression", functions(Version)...)
 	}
 
-	if len(tokens[1:]) < 2 {
-		return nil, errors.New("query must have function and expression")
-	} else if tokens[len(tokens)-1] != ")" {
-		return nil, errors.New("query must end in a closing parenthesis")
+	fn := tokens[1]
+	if _, ok := functionVersions[fn.value]; !ok {
+		return nil, newParseError(fn.offset, fmt.Sprintf("unrecognized function %q", fn.value), functions(Version)...)
 	}
 
-	queries, err := buildExpression(tokens[2 : len(tokens)-1])
+	args := tokens[2:end]
+	qry, err := buildFunction(fn.value, args)
+	if err != nil {
+		if _, ok := err.(*ParseError); ok {
+			return nil, err
+		}
+		return nil, newParseError(fn.offset, err.Error())
+	}
+	return qry, nil
+}
+
+func buildFunction(fn string, args []token) (p2plab.Query, error) {
+	switch fn {
+	case "<", "<=", ">", ">=", "=", "!=":
+		return newCompareQuery(fn, values(args))
+	case "match":
+		return newMatchQuery(values(args))
+	case "attr":
+		return newAttrQuery(values(args))
+	case "split":
+		// Ratios precede the expression being split.
+		i := 0
+		for i < len(args)-1 && args[i].value != "(" {
+			i++
+		}
+
+		queries, err := buildExpression(args[i:])
+		if err != nil {
+			return nil, err
+		}
+		return newSplitQuery(values(args[:i]), queries)
+	case "sample", "first", "hops", "cluster":
+		if len(args) < 2 {
+			return nil, errors.Errorf("%s query must have an argument and an expression", fn)
+		}
+
+		queries, err := buildExpression(args[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		switch fn {
+		case "sample":
+			return newSampleQuery(args[0].value, queries)
+		case "first":
+			return newFirstQuery(args[0].value, queries)
+		case "cluster":
+			return newClusterQuery(args[0].value, queries)
+		default:
+			return newHopsQuery(args[0].value, queries)
+		}
+	}
+
+	queries, err := buildExpression(args)
 	if err != nil {
 		return nil, err
 	}
 
-	switch tokens[1] {
+	switch fn {
 	case "not":
 		return newNotQuery(queries)
 	case "and":
 		return newAndQuery(queries)
 	case "or":
 		return newOrQuery(queries)
+	case "diff":
+		return newDiffQuery(queries)
+	case "xor":
+		return newXorQuery(queries)
 	default:
-		return nil, errors.Errorf("unrecognized function %q", tokens[1])
+		return newNeighborsQuery(queries)
 	}
 }
 
-func buildExpression(tokens []string) ([]p2plab.Query, error) {
+func buildExpression(tokens []token) ([]p2plab.Query, error) {
 	var queries []p2plab.Query
 
 	for i := 0; i < len(tokens); {
 		// If the next token is a label, then it is a single element.
 		// Otherwise it is a beginning of a longer query.
 		j := i + 1
-		if tokens[i] == "(" {
-			parens := 1
-			for ; j < len(tokens) && parens > 0; j++ {
-				switch tokens[j] {
-				case "(":
-					parens++
-				case ")":
-					parens--
-				}
-			}
-
-			if parens != 0 {
-				return nil, errors.New("no matching parenthesis")
+		if tokens[i].value == "(" {
+			end := closingParen(tokens[i:])
+			if end < 0 {
+				return nil, newParseError(tokens[i].offset, "unclosed parenthesis", ")")
 			}
+			j = i + end + 1
 		}
 
 		qry, err := buildQuery(tokens[i:j])
@@ -201,8 +487,15 @@ func (q *andQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.La
 		qsets = append(qsets, qset)
 	}
 
+	// Subqueries may match nodes of other clusters, so the intersection is
+	// taken from their matches rather than lset.
+	base := lset
+	if len(qsets) > 0 {
+		base = qsets[0]
+	}
+
 	andSet := NewLabeledSet()
-	for _, l := range lset.Slice() {
+	for _, l := range base.Slice() {
 		allContains := true
 		for _, qset := range qsets {
 			if !qset.Contains(l.ID()) {
@@ -246,21 +539,104 @@ func (q *orQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.Lab
 	}
 
 	orSet := NewLabeledSet()
-	for _, l := range lset.Slice() {
-		oneContains := false
-		for _, qset := range qsets {
+	for _, qset := range qsets {
+		for _, l := range qset.Slice() {
+			orSet.Add(l)
+		}
+	}
+
+	return orSet, nil
+}
+
+type diffQuery struct {
+	queries []p2plab.Query
+}
+
+func newDiffQuery(queries []p2plab.Query) (p2plab.Query, error) {
+	if len(queries) < 2 {
+		return nil, errors.New("diff query must have at least 2 arguments")
+	}
+	return &diffQuery{queries}, nil
+}
+
+func (q *diffQuery) String() string {
+	var r []string
+	for _, q := range q.queries {
+		r = append(r, q.String())
+	}
+	return fmt.Sprintf("(diff %s)", strings.Join(r, " "))
+}
+
+// Match returns the matches of the first query that none of the other queries
+// match.
+func (q *diffQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	var qsets []p2plab.LabeledSet
+	for _, q := range q.queries {
+		qset, err := q.Match(ctx, lset)
+		if err != nil {
+			return nil, err
+		}
+		qsets = append(qsets, qset)
+	}
+
+	diffSet := NewLabeledSet()
+	for _, l := range qsets[0].Slice() {
+		excluded := false
+		for _, qset := range qsets[1:] {
 			if qset.Contains(l.ID()) {
-				oneContains = true
+				excluded = true
 				break
 			}
 		}
 
-		if oneContains {
-			orSet.Add(l)
+		if !excluded {
+			diffSet.Add(l)
 		}
 	}
 
-	return orSet, nil
+	return diffSet, nil
+}
+
+type xorQuery struct {
+	queries []p2plab.Query
+}
+
+func newXorQuery(queries []p2plab.Query) (p2plab.Query, error) {
+	if len(queries) != 2 {
+		return nil, errors.New("xor query must have exactly 2 arguments")
+	}
+	return &xorQuery{queries}, nil
+}
+
+func (q *xorQuery) String() string {
+	return fmt.Sprintf("(xor %s %s)", q.queries[0], q.queries[1])
+}
+
+// Match returns the resources matched by exactly one of the two queries.
+func (q *xorQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	aset, err := q.queries[0].Match(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	bset, err := q.queries[1].Match(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	xorSet := NewLabeledSet()
+	for _, l := range aset.Slice() {
+		if !bset.Contains(l.ID()) {
+			xorSet.Add(l)
+		}
+	}
+	for _, l := range bset.Slice() {
+		if !aset.Contains(l.ID()) {
+			xorSet.Add(l)
+		}
+	}
+
+	return xorSet, nil
 }
 
 type labelQuery struct {
@@ -295,12 +671,12 @@ func (q *labelQuery) String() string {
 func (q *labelQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
 	labelSet := NewLabeledSet()
 	for _, l := range lset.Slice() {
-		found := false
+		found := q.glob.Match(l.ID())
 		for _, label := range l.Labels() {
-			if q.glob.Match(label) {
-				found = true
+			if found {
 				break
 			}
+			found = q.glob.Match(label)
 		}
 
 		if found || (q.pattern == "*" && len(l.Labels()) == 0) {
@@ -310,3 +686,194 @@ func (q *labelQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.
 
 	return labelSet, nil
 }
+
+type matchQuery struct {
+	pattern string
+	regexp  *regexp.Regexp
+}
+
+func newMatchQuery(args []string) (p2plab.Query, error) {
+	if len(args) != 1 {
+		return nil, errors.New("match query must have exactly 1 argument")
+	}
+
+	label := args[0]
+	if len(label) < 3 || label[0] != '\'' || label[len(label)-1] != '\'' {
+		return nil, errors.Errorf("match pattern must be in quotations: %q", label)
+	}
+
+	pattern := label[1 : len(label)-1]
+	re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", pattern))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile match pattern %q", pattern)
+	}
+
+	return &matchQuery{
+		pattern: pattern,
+		regexp:  re,
+	}, nil
+}
+
+func (q *matchQuery) String() string {
+	return fmt.Sprintf("(match '%s')", q.pattern)
+}
+
+func (q *matchQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	matchSet := NewLabeledSet()
+	for _, l := range lset.Slice() {
+		found := q.regexp.MatchString(l.ID())
+		for _, label := range l.Labels() {
+			if found {
+				break
+			}
+			found = q.regexp.MatchString(label)
+		}
+
+		if found {
+			matchSet.Add(l)
+		}
+	}
+
+	return matchSet, nil
+}
+
+type attrQuery struct {
+	key     string
+	pattern string
+	glob    glob.Glob
+}
+
+func newAttrQuery(args []string) (p2plab.Query, error) {
+	if len(args) != 2 {
+		return nil, errors.New("attr query must have a key and a value")
+	}
+
+	pattern := strings.Trim(args[1], "'")
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attrQuery{
+		key:     strings.Trim(args[0], "'"),
+		pattern: pattern,
+		glob:    g,
+	}, nil
+}
+
+func (q *attrQuery) String() string {
+	return fmt.Sprintf("(attr %s '%s')", q.key, q.pattern)
+}
+
+func (q *attrQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	attrSet := NewLabeledSet()
+	for _, l := range lset.Slice() {
+		// Resources without attributes never match.
+		a, ok := l.(p2plab.Attributed)
+		if !ok {
+			continue
+		}
+
+		v, ok := a.Attributes()[q.key]
+		if ok && q.glob.Match(v) {
+			attrSet.Add(l)
+		}
+	}
+
+	return attrSet, nil
+}
+
+type compareQuery struct {
+	op    string
+	key   string
+	value string
+
+	// parse parses the values of labels the same way as the query's value.
+	parse     func(string) (float64, error)
+	threshold float64
+}
+
+func newCompareQuery(op string, args []string) (p2plab.Query, error) {
+	if len(args) != 2 {
+		return nil, errors.Errorf("%s query must have a key and a value", op)
+	}
+
+	q := &compareQuery{
+		op:    op,
+		key:   strings.Trim(args[0], "'"),
+		value: strings.Trim(args[1], "'"),
+	}
+
+	var err error
+	q.threshold, err = parseNumber(q.value)
+	if err == nil {
+		q.parse = parseNumber
+		return q, nil
+	}
+
+	q.threshold, err = parseDuration(q.value)
+	if err == nil {
+		q.parse = parseDuration
+		return q, nil
+	}
+
+	return nil, errors.Errorf("%s query value %q must be a number or a duration", op, q.value)
+}
+
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseDuration(s string) (float64, error) {
+	d, err := time.ParseDuration(s)
+	return float64(d), err
+}
+
+func (q *compareQuery) String() string {
+	return fmt.Sprintf("(%s %s %s)", q.op, q.key, q.value)
+}
+
+func (q *compareQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	prefix := q.key + "="
+
+	compareSet := NewLabeledSet()
+	for _, l := range lset.Slice() {
+		for _, label := range l.Labels() {
+			if !strings.HasPrefix(label, prefix) {
+				continue
+			}
+
+			// Labels whose values cannot be compared do not match.
+			v, err := q.parse(strings.TrimPrefix(label, prefix))
+			if err != nil {
+				continue
+			}
+
+			if q.compare(v) {
+				compareSet.Add(l)
+				break
+			}
+		}
+	}
+
+	return compareSet, nil
+}
+
+func (q *compareQuery) compare(v float64) bool {
+	switch q.op {
+	case "<":
+		return v < q.threshold
+	case "<=":
+		return v <= q.threshold
+	case ">":
+		return v > q.threshold
+	case ">=":
+		return v >= q.threshold
+	case "=":
+		return v == q.threshold
+	case "!=":
+		return v != q.threshold
+	default:
+		return false
+	}
+}
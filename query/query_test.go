@@ -0,0 +1,119 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/stretchr/testify/require"
+)
+
+var parseerrortest = []struct {
+	in       string
+	offset   int
+	expected []string
+}{
+	{"(and 'a' 'b'", 0, []string{")"}},
+	{"(and 'a' (or 'b' 'c')", 0, []string{")"}},
+	{"(and 'a') 'b'", 10, []string{"end of query"}},
+	{"(and 'a' ) 'b')", 11, []string{"end of query"}},
+	{"(and 'a' 'b)", 9, []string{"'"}},
+	{"(nand 'a' 'b')", 1, functions(Version)},
+	{"()", 1, functions(Version)},
+	{"(and 'a' b)", 9, nil},
+	{"(not 'a' 'b')", 1, nil},
+	{"(and 'a' (> latency))", 10, nil},
+}
+
+func TestParseError(t *testing.T) {
+	ctx := context.Background()
+
+	for _, test := range parseerrortest {
+		_, err := Parse(ctx, test.in)
+		require.Error(t, err, test.in)
+		require.True(t, errdefs.IsInvalidArgument(err), test.in)
+
+		perr, ok := err.(*ParseError)
+		require.True(t, ok, test.in)
+		require.Equal(t, test.in, perr.Query)
+		require.Equal(t, test.offset, perr.Offset, test.in)
+		require.Equal(t, test.expected, perr.Expected, test.in)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := ParseVersion(ctx, "(or 'a' (not 'b'))", 1)
+	require.NoError(t, err)
+
+	_, err = ParseVersion(ctx, "(or 'a' (diff 'b' 'c'))", 1)
+	perr, ok := err.(*ParseError)
+	require.True(t, ok)
+	require.Equal(t, 9, perr.Offset)
+	require.Equal(t, []string{"and", "not", "or"}, perr.Expected)
+
+	_, err = ParseVersion(ctx, "@seeders", 1)
+	require.Error(t, err)
+
+	_, err = ParseVersion(ctx, "(or 'a' (diff 'b' 'c'))", 0)
+	require.NoError(t, err)
+
+	_, err = ParseVersion(ctx, "'a'", Version+1)
+	require.True(t, errdefs.IsInvalidArgument(err))
+}
+
+var parsestringtest = []struct {
+	in      string
+	version int
+	valid   bool
+}{
+	{"(cluster 'edge' (and 'us-west' 'gateway'))", Version, true},
+	{"(cluster 'edge' 'us-west')", 2, false},
+	{"(split 0.7 0.3 'leecher')", Version, true},
+	{"(split 0.5 0.25 0.25 (and 'leecher' (match 'node-0[0-4]')))", Version, true},
+	{"(split 'leecher')", Version, false},
+	{"(split 1 'leecher')", Version, false},
+	{"(split 0.7 0.4 'leecher')", Version, false},
+	{"(split 0 1 'leecher')", Version, false},
+	{"(split 0.5 0.5 'leecher' 'seeder')", Version, false},
+	{"(split 0.5 x 'leecher')", Version, false},
+	{"(split 0.5 0.5 'leecher')", 2, false},
+	{"(sample 0.2 'leecher')", Version, true},
+	{"(first 3 (and 'leecher' (match 'node-0[0-4]')))", Version, true},
+	{"(sample 'leecher')", Version, false},
+	{"(sample 0 'leecher')", Version, false},
+	{"(sample 1.5 'leecher')", Version, false},
+	{"(sample 0.5 'leecher' 'seeder')", Version, false},
+	{"(first 0 'leecher')", Version, false},
+	{"(first 1.5 'leecher')", Version, false},
+	{"(first 2)", Version, false},
+}
+
+func TestParseString(t *testing.T) {
+	ctx := context.Background()
+
+	for _, test := range parsestringtest {
+		qry, err := ParseVersion(ctx, test.in, test.version)
+		if !test.valid {
+			require.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		require.Equal(t, test.in, qry.String(), test.in)
+	}
+}
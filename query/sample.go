@@ -0,0 +1,134 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/Netflix/p2plab"
+	"github.com/pkg/errors"
+)
+
+type randomSeedKey struct{}
+
+// WithRandomSeed returns a context whose sample queries choose their nodes
+// with seed, so that the same nodes are chosen whenever the seed is reused.
+func WithRandomSeed(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, randomSeedKey{}, seed)
+}
+
+// RandomSeed returns the random seed of sample queries, or zero if ctx has no
+// seed.
+func RandomSeed(ctx context.Context) int64 {
+	seed, _ := ctx.Value(randomSeedKey{}).(int64)
+	return seed
+}
+
+type sampleQuery struct {
+	fraction float64
+	query    p2plab.Query
+}
+
+func newSampleQuery(arg string, queries []p2plab.Query) (p2plab.Query, error) {
+	if len(queries) != 1 {
+		return nil, errors.New("sample query must have a fraction and exactly 1 argument")
+	}
+
+	fraction, err := strconv.ParseFloat(arg, 64)
+	if err != nil || fraction <= 0 || fraction > 1 {
+		return nil, errors.Errorf("sample fraction must be in (0, 1]: %q", arg)
+	}
+
+	return &sampleQuery{fraction, queries[0]}, nil
+}
+
+func (q *sampleQuery) String() string {
+	return fmt.Sprintf("(sample %s %s)", strconv.FormatFloat(q.fraction, 'f', -1, 64), q.query)
+}
+
+// Match returns a random fraction of the subquery's matches, rounded but at
+// least one. The random source is derived from the context's seed and the
+// subquery, so that the same query chooses the same nodes in every stage and
+// trial of a benchmark.
+func (q *sampleQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	mset, err := q.query.Match(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := mset.Slice()
+	n := int(math.Round(q.fraction * float64(len(ls))))
+	if n == 0 && len(ls) > 0 {
+		n = 1
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(q.query.String()))
+	rng := rand.New(rand.NewSource(RandomSeed(ctx) ^ int64(h.Sum64())))
+
+	sampleSet := NewLabeledSet()
+	for _, i := range rng.Perm(len(ls))[:n] {
+		sampleSet.Add(ls[i])
+	}
+
+	return sampleSet, nil
+}
+
+type firstQuery struct {
+	count int
+	query p2plab.Query
+}
+
+func newFirstQuery(arg string, queries []p2plab.Query) (p2plab.Query, error) {
+	if len(queries) != 1 {
+		return nil, errors.New("first query must have a count and exactly 1 argument")
+	}
+
+	count, err := strconv.Atoi(arg)
+	if err != nil || count <= 0 {
+		return nil, errors.Errorf("first count must be a positive integer: %q", arg)
+	}
+
+	return &firstQuery{count, queries[0]}, nil
+}
+
+func (q *firstQuery) String() string {
+	return fmt.Sprintf("(first %d %s)", q.count, q.query)
+}
+
+// Match returns the subquery's first matches ordered by ID.
+func (q *firstQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	mset, err := q.query.Match(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := mset.Slice()
+	if len(ls) > q.count {
+		ls = ls[:q.count]
+	}
+
+	firstSet := NewLabeledSet()
+	for _, l := range ls {
+		firstSet.Add(l)
+	}
+
+	return firstSet, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Netflix/p2plab"
+	"github.com/stretchr/testify/require"
+)
+
+func newLeechers(n int) []p2plab.Labeled {
+	var ls []p2plab.Labeled
+	for i := 0; i < n; i++ {
+		ls = append(ls, NewLabeled(fmt.Sprintf("node-%02d", i), []string{"leecher"}))
+	}
+	ls = append(ls, NewLabeled("seeder", []string{"seeder"}))
+	return ls
+}
+
+func TestSample(t *testing.T) {
+	ls := newLeechers(10)
+
+	ctx := WithRandomSeed(context.Background(), 42)
+	sample, err := Execute(ctx, ls, "(sample 0.2 'leecher')")
+	require.NoError(t, err)
+	require.Len(t, sample.Slice(), 2)
+	for _, l := range sample.Slice() {
+		require.Contains(t, l.Labels(), "leecher")
+	}
+
+	// The same seed chooses the same nodes.
+	again, err := Execute(ctx, ls, "(sample 0.2 'leecher')")
+	require.NoError(t, err)
+	require.Equal(t, sample.Slice(), again.Slice())
+
+	// The complement of a sample is every other node.
+	rest, err := Execute(ctx, ls, "(not (sample 0.2 'leecher'))")
+	require.NoError(t, err)
+	require.Len(t, rest.Slice(), 9)
+
+	// Small fractions choose at least one node.
+	one, err := Execute(ctx, ls, "(sample 0.01 'leecher')")
+	require.NoError(t, err)
+	require.Len(t, one.Slice(), 1)
+}
+
+func TestFirst(t *testing.T) {
+	ls := newLeechers(10)
+	ctx := context.Background()
+
+	first, err := Execute(ctx, ls, "(first 3 'leecher')")
+	require.NoError(t, err)
+	require.Equal(t, ls[:3], first.Slice())
+
+	all, err := Execute(ctx, ls, "(first 20 (or 'leecher' 'seeder'))")
+	require.NoError(t, err)
+	require.Equal(t, ls, all.Slice())
+}
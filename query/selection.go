@@ -0,0 +1,84 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+)
+
+// SelectionPrefix prefixes the name of a selection referenced in a query.
+const SelectionPrefix = "@"
+
+type selectionsKey struct{}
+
+// WithSelections returns a context in which queries can reference the named
+// sets of resources as "@<name>".
+func WithSelections(ctx context.Context, selections map[string]p2plab.LabeledSet) context.Context {
+	return context.WithValue(ctx, selectionsKey{}, selections)
+}
+
+// References returns the names of the selections referenced by a query.
+func References(q string) []string {
+	// A query with an unterminated quotation references no selections, as
+	// none of its tokens can be told apart.
+	tokens, _ := tokenize(q)
+
+	var names []string
+	for _, t := range tokens {
+		if strings.HasPrefix(t.value, SelectionPrefix) {
+			names = append(names, strings.TrimPrefix(t.value, SelectionPrefix))
+		}
+	}
+	return names
+}
+
+type selectionQuery struct {
+	name string
+}
+
+func newSelectionQuery(token string) (p2plab.Query, error) {
+	name := strings.TrimPrefix(token, SelectionPrefix)
+	if name == "" || strings.ContainsAny(name, "'@") {
+		return nil, errors.Errorf("invalid selection reference %q", token)
+	}
+
+	return &selectionQuery{name}, nil
+}
+
+func (q *selectionQuery) String() string {
+	return SelectionPrefix + q.name
+}
+
+func (q *selectionQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	selections, _ := ctx.Value(selectionsKey{}).(map[string]p2plab.LabeledSet)
+	selection, ok := selections[q.name]
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrNotFound, "selection %q", q.name)
+	}
+
+	selectionSet := NewLabeledSet()
+	for _, l := range lset.Slice() {
+		if selection.Contains(l.ID()) {
+			selectionSet.Add(l)
+		}
+	}
+
+	return selectionSet, nil
+}
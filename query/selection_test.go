@@ -0,0 +1,54 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelection(t *testing.T) {
+	ls := newLeechers(4)
+
+	leechers := NewLabeledSet()
+	leechers.Add(ls[0])
+	leechers.Add(ls[2])
+	ctx := WithSelections(context.Background(), map[string]p2plab.LabeledSet{
+		"leechers": leechers,
+	})
+
+	mset, err := Execute(ctx, ls, "@leechers")
+	require.NoError(t, err)
+	require.Equal(t, []p2plab.Labeled{ls[0], ls[2]}, mset.Slice())
+
+	mset, err = Execute(ctx, ls, "(or (not @leechers) 'seeder')")
+	require.NoError(t, err)
+	require.Equal(t, []p2plab.Labeled{ls[1], ls[3], ls[4]}, mset.Slice())
+
+	_, err = Execute(ctx, ls, "@observers")
+	require.True(t, errdefs.IsNotFound(err))
+
+	_, err = Execute(ctx, ls, "@")
+	require.Error(t, err)
+}
+
+func TestReferences(t *testing.T) {
+	require.Equal(t, []string{"seeders", "leechers"}, References("(or @seeders (and @leechers 'us-west-2'))"))
+	require.Empty(t, References("(not 'apple')"))
+}
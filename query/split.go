@@ -0,0 +1,175 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/pkg/errors"
+)
+
+// Select matches a named selection's query. If the query splits its nodes,
+// each part is also selected as "<name>.<n>", counting from 1, so that
+// "(split 0.7 0.3 'leecher')" selected as "roles" is referenced as "@roles.1"
+// and "@roles.2".
+func Select(ctx context.Context, name string, qry p2plab.Query, lset p2plab.LabeledSet) (map[string]p2plab.LabeledSet, error) {
+	mset, err := qry.Match(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]p2plab.LabeledSet{name: mset}
+
+	sq, ok := qry.(*splitQuery)
+	if !ok {
+		return selected, nil
+	}
+
+	parts, err := sq.Split(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, part := range parts {
+		selected[partName(name, i)] = part
+	}
+	return selected, nil
+}
+
+// SelectionNames returns the names that a selection defines, which are the
+// selection itself and the parts of its query if it splits its nodes.
+func SelectionNames(name, q string) []string {
+	names := []string{name}
+
+	// A malformed query cannot split the selection, so only the selection's
+	// own name is defined.
+	qry, err := parse(q, Version)
+	if err != nil {
+		return names
+	}
+
+	if sq, ok := qry.(*splitQuery); ok {
+		for i := range sq.ratios {
+			names = append(names, partName(name, i))
+		}
+	}
+	return names
+}
+
+func partName(name string, i int) string {
+	return fmt.Sprintf("%s.%d", name, i+1)
+}
+
+type splitQuery struct {
+	ratios []float64
+	query  p2plab.Query
+}
+
+func newSplitQuery(args []string, queries []p2plab.Query) (p2plab.Query, error) {
+	if len(args) < 2 || len(queries) != 1 {
+		return nil, errors.New("split query must have at least 2 ratios and exactly 1 argument")
+	}
+
+	var (
+		ratios []float64
+		total  float64
+	)
+	for _, arg := range args {
+		ratio, err := strconv.ParseFloat(arg, 64)
+		if err != nil || ratio <= 0 || ratio > 1 {
+			return nil, errors.Errorf("split ratio must be in (0, 1]: %q", arg)
+		}
+		ratios = append(ratios, ratio)
+		total += ratio
+	}
+
+	// Allow for ratios like 0.1 that are not exact in floating point.
+	if total > 1+1e-9 {
+		return nil, errors.Errorf("split ratios must not add up to more than 1: %s", strings.Join(args, " "))
+	}
+
+	return &splitQuery{ratios, queries[0]}, nil
+}
+
+func (q *splitQuery) String() string {
+	var ratios []string
+	for _, ratio := range q.ratios {
+		ratios = append(ratios, strconv.FormatFloat(ratio, 'f', -1, 64))
+	}
+	return fmt.Sprintf("(split %s %s)", strings.Join(ratios, " "), q.query)
+}
+
+// Split divides the nodes that the query's argument matches into a part for
+// each ratio. Nodes are shuffled with the context's random seed, so the same
+// seed splits the same nodes into the same parts.
+func (q *splitQuery) Split(ctx context.Context, lset p2plab.LabeledSet) ([]p2plab.LabeledSet, error) {
+	mset, err := q.query.Match(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := mset.Slice()
+
+	h := fnv.New64a()
+	h.Write([]byte(q.String()))
+	rng := rand.New(rand.NewSource(RandomSeed(ctx) ^ int64(h.Sum64())))
+	perm := rng.Perm(len(ls))
+
+	// Parts end at the rounded cumulative ratios so that rounding never
+	// assigns more nodes than were matched.
+	var (
+		parts []p2plab.LabeledSet
+		start int
+		total float64
+	)
+	for _, ratio := range q.ratios {
+		total += ratio
+		end := int(math.Round(math.Min(total, 1) * float64(len(ls))))
+
+		part := NewLabeledSet()
+		for _, i := range perm[start:end] {
+			part.Add(ls[i])
+		}
+		parts = append(parts, part)
+		start = end
+	}
+
+	return parts, nil
+}
+
+// Match returns the nodes of every part, which are all of the argument's
+// nodes if the ratios add up to 1.
+func (q *splitQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	parts, err := q.Split(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	splitSet := NewLabeledSet()
+	for _, part := range parts {
+		for _, l := range part.Slice() {
+			splitSet.Add(l)
+		}
+	}
+
+	return splitSet, nil
+}
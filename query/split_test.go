@@ -0,0 +1,66 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	ls := newLeechers(10)
+	ctx := WithRandomSeed(context.Background(), 42)
+
+	qry, err := Parse(ctx, "(split 0.7 0.3 'leecher')")
+	require.NoError(t, err)
+
+	selected, err := Select(ctx, "roles", qry, NewLabeledSet())
+	require.NoError(t, err)
+	require.Empty(t, selected["roles"].Slice())
+
+	lset := NewLabeledSet()
+	for _, l := range ls {
+		lset.Add(l)
+	}
+
+	selected, err = Select(ctx, "roles", qry, lset)
+	require.NoError(t, err)
+	require.Len(t, selected, 3)
+	require.Len(t, selected["roles"].Slice(), 10)
+	require.Len(t, selected["roles.1"].Slice(), 7)
+	require.Len(t, selected["roles.2"].Slice(), 3)
+
+	// Parts don't overlap.
+	for _, l := range selected["roles.1"].Slice() {
+		require.False(t, selected["roles.2"].Contains(l.ID()))
+	}
+
+	// The same seed splits the same nodes.
+	again, err := Select(ctx, "roles", qry, lset)
+	require.NoError(t, err)
+	require.Equal(t, selected["roles.1"].Slice(), again["roles.1"].Slice())
+
+	// Ratios that don't add up to 1 leave out the remaining nodes.
+	some, err := Execute(ctx, ls, "(split 0.2 0.3 (or 'leecher' 'seeder'))")
+	require.NoError(t, err)
+	require.Len(t, some.Slice(), 6)
+}
+
+func TestSelectionNames(t *testing.T) {
+	require.Equal(t, []string{"roles", "roles.1", "roles.2"}, SelectionNames("roles", "(split 0.7 0.3 'leecher')"))
+	require.Equal(t, []string{"seeders"}, SelectionNames("seeders", "(first 1 'seeder')"))
+}
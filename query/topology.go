@@ -0,0 +1,127 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+type topologyKey struct{}
+
+// WithTopology returns a context in which neighbors and hops queries traverse
+// the connections between nodes.
+func WithTopology(ctx context.Context, edges []metadata.TopologyEdge) context.Context {
+	adjacency := make(map[string][]string)
+	for _, edge := range edges {
+		adjacency[edge.A] = append(adjacency[edge.A], edge.B)
+		adjacency[edge.B] = append(adjacency[edge.B], edge.A)
+	}
+	return context.WithValue(ctx, topologyKey{}, adjacency)
+}
+
+// UsesTopology returns whether a query has neighbors or hops queries, which
+// can only be matched with a topology.
+func UsesTopology(q string) bool {
+	tokens, _ := tokenize(q)
+	for i := 1; i < len(tokens); i++ {
+		if tokens[i-1].value == "(" && (tokens[i].value == "neighbors" || tokens[i].value == "hops") {
+			return true
+		}
+	}
+	return false
+}
+
+type hopsQuery struct {
+	hops  int
+	query p2plab.Query
+}
+
+func newNeighborsQuery(queries []p2plab.Query) (p2plab.Query, error) {
+	if len(queries) != 1 {
+		return nil, errors.New("neighbors query must have exactly 1 argument")
+	}
+	return &hopsQuery{1, queries[0]}, nil
+}
+
+func newHopsQuery(arg string, queries []p2plab.Query) (p2plab.Query, error) {
+	if len(queries) != 1 {
+		return nil, errors.New("hops query must have a count and exactly 1 argument")
+	}
+
+	hops, err := strconv.Atoi(arg)
+	if err != nil || hops <= 0 {
+		return nil, errors.Errorf("hops count must be a positive integer: %q", arg)
+	}
+
+	return &hopsQuery{hops, queries[0]}, nil
+}
+
+func (q *hopsQuery) String() string {
+	if q.hops == 1 {
+		return fmt.Sprintf("(neighbors %s)", q.query)
+	}
+	return fmt.Sprintf("(hops %d %s)", q.hops, q.query)
+}
+
+// Match returns the resources within a number of connections of the
+// subquery's matches, excluding the matches themselves.
+func (q *hopsQuery) Match(ctx context.Context, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	adjacency, ok := ctx.Value(topologyKey{}).(map[string][]string)
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrUnavailable, "%s query requires the topology of the nodes", q)
+	}
+
+	mset, err := q.query.Match(ctx, lset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Breadth-first search from the subquery's matches, which may traverse
+	// nodes outside of lset.
+	visited := make(map[string]struct{})
+	var frontier []string
+	for _, l := range mset.Slice() {
+		visited[l.ID()] = struct{}{}
+		frontier = append(frontier, l.ID())
+	}
+
+	hopsSet := NewLabeledSet()
+	for hop := 0; hop < q.hops && len(frontier) > 0; hop++ {
+		var next []string
+		for _, id := range frontier {
+			for _, neighbor := range adjacency[id] {
+				if _, ok := visited[neighbor]; ok {
+					continue
+				}
+				visited[neighbor] = struct{}{}
+				next = append(next, neighbor)
+
+				if l := lset.Get(neighbor); l != nil {
+					hopsSet.Add(l)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return hopsSet, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHops(t *testing.T) {
+	// A line of nodes: seeder - a - b - c, and d is disconnected.
+	ns := []p2plab.Labeled{
+		NewLabeled("a", []string{"leecher"}),
+		NewLabeled("b", []string{"leecher"}),
+		NewLabeled("c", []string{"leecher"}),
+		NewLabeled("d", []string{"leecher"}),
+		NewLabeled("seeder", []string{"seeder"}),
+	}
+	ctx := WithTopology(context.Background(), []metadata.TopologyEdge{
+		{A: "a", B: "seeder"},
+		{A: "a", B: "b"},
+		{A: "b", B: "c"},
+	})
+
+	for _, test := range []struct {
+		in  string
+		out []p2plab.Labeled
+	}{
+		{"(neighbors 'seeder')", []p2plab.Labeled{ns[0]}},
+		{"(hops 1 'seeder')", []p2plab.Labeled{ns[0]}},
+		{"(hops 2 'seeder')", []p2plab.Labeled{ns[0], ns[1]}},
+		{"(hops 10 'seeder')", []p2plab.Labeled{ns[0], ns[1], ns[2]}},
+		{"(neighbors 'b')", []p2plab.Labeled{ns[0], ns[2]}},
+		{"(diff (hops 2 'seeder') (neighbors 'seeder'))", []p2plab.Labeled{ns[1]}},
+		{"(neighbors 'd')", nil},
+	} {
+		mset, err := Execute(ctx, ns, test.in)
+		require.NoError(t, err)
+		require.Equal(t, test.out, mset.Slice(), test.in)
+	}
+
+	// Without a topology, neighbors cannot be matched.
+	_, err := Execute(context.Background(), ns, "(neighbors 'seeder')")
+	require.True(t, errdefs.IsUnavailable(err))
+
+	for _, q := range []string{"(hops 0 'seeder')", "(hops 'seeder')", "(neighbors 'a' 'b')"} {
+		_, err = Execute(ctx, ns, q)
+		require.Error(t, err, q)
+	}
+}
+
+func TestUsesTopology(t *testing.T) {
+	require.True(t, UsesTopology("(and 'leecher' (neighbors 'seeder'))"))
+	require.True(t, UsesTopology("(hops 2 'seeder')"))
+	require.False(t, UsesTopology("(and 'neighbors' 'hops')"))
+}
@@ -34,34 +34,122 @@ type float64Pair struct {
 func ComputeAggregates(reportByNodeId map[string]metadata.ReportNode) metadata.ReportAggregates {
 	var aggregates metadata.ReportAggregates
 	for _, reportNode := range reportByNodeId {
-		bswap := reportNode.Bitswap
-
-		for _, pair := range []uint64Pair{
-			{bswap.BlocksReceived, &aggregates.Totals.Bitswap.BlocksReceived},
-			{bswap.DataReceived, &aggregates.Totals.Bitswap.DataReceived},
-			{bswap.BlocksSent, &aggregates.Totals.Bitswap.BlocksSent},
-			{bswap.DataSent, &aggregates.Totals.Bitswap.DataSent},
-			{bswap.DupBlksReceived, &aggregates.Totals.Bitswap.DupBlksReceived},
-			{bswap.DupDataReceived, &aggregates.Totals.Bitswap.DupDataReceived},
-			{bswap.MessagesReceived, &aggregates.Totals.Bitswap.MessagesReceived},
-		} {
-			*pair.aggregate += pair.single
-		}
+		addNode(&aggregates, reportNode)
+	}
+	return aggregates
+}
 
-		bandwidth := reportNode.Bandwidth.Totals
-		for _, pair := range []int64Pair{
-			{bandwidth.TotalIn, &aggregates.Totals.Bandwidth.Totals.TotalIn},
-			{bandwidth.TotalOut, &aggregates.Totals.Bandwidth.Totals.TotalOut},
-		} {
-			*pair.aggregate += pair.single
-		}
+// MergeTrials returns the aggregates of every trial combined, so that
+// latency distributions cover the operations of all the trials.
+func MergeTrials(trials []metadata.ReportTrial) metadata.ReportAggregates {
+	var aggregates metadata.ReportAggregates
+	for _, trial := range trials {
+		addNode(&aggregates, trial.Aggregates.Totals)
+	}
+	return aggregates
+}
+
+// addNode adds the metrics of a node's report to the aggregates.
+func addNode(aggregates *metadata.ReportAggregates, reportNode metadata.ReportNode) {
+	bswap := reportNode.Bitswap
+
+	for _, pair := range []uint64Pair{
+		{bswap.BlocksReceived, &aggregates.Totals.Bitswap.BlocksReceived},
+		{bswap.DataReceived, &aggregates.Totals.Bitswap.DataReceived},
+		{bswap.BlocksSent, &aggregates.Totals.Bitswap.BlocksSent},
+		{bswap.DataSent, &aggregates.Totals.Bitswap.DataSent},
+		{bswap.DupBlksReceived, &aggregates.Totals.Bitswap.DupBlksReceived},
+		{bswap.DupDataReceived, &aggregates.Totals.Bitswap.DupDataReceived},
+		{bswap.MessagesReceived, &aggregates.Totals.Bitswap.MessagesReceived},
+	} {
+		*pair.aggregate += pair.single
+	}
+
+	bandwidth := reportNode.Bandwidth.Totals
+	for _, pair := range []int64Pair{
+		{bandwidth.TotalIn, &aggregates.Totals.Bandwidth.Totals.TotalIn},
+		{bandwidth.TotalOut, &aggregates.Totals.Bandwidth.Totals.TotalOut},
+	} {
+		*pair.aggregate += pair.single
+	}
+
+	for _, pair := range []float64Pair{
+		{bandwidth.RateIn, &aggregates.Totals.Bandwidth.Totals.RateIn},
+		{bandwidth.RateOut, &aggregates.Totals.Bandwidth.Totals.RateOut},
+	} {
+		*pair.aggregate += pair.single
+	}
 
-		for _, pair := range []float64Pair{
-			{bandwidth.RateIn, &aggregates.Totals.Bandwidth.Totals.RateIn},
-			{bandwidth.RateOut, &aggregates.Totals.Bandwidth.Totals.RateOut},
-		} {
-			*pair.aggregate += pair.single
+	conns := reportNode.Connections
+	for _, pair := range []int64Pair{
+		{conns.Peers, &aggregates.Totals.Connections.Peers},
+		{conns.Conns, &aggregates.Totals.Connections.Conns},
+	} {
+		*pair.aggregate += pair.single
+	}
+
+	ps := reportNode.Pubsub
+	for _, pair := range []int64Pair{
+		{ps.MessagesPublished, &aggregates.Totals.Pubsub.MessagesPublished},
+		{ps.MessagesReceived, &aggregates.Totals.Pubsub.MessagesReceived},
+		{ps.MessagesLost, &aggregates.Totals.Pubsub.MessagesLost},
+	} {
+		*pair.aggregate += pair.single
+	}
+
+	aggregates.Totals.Pubsub.Latency.Merge(ps.Latency)
+
+	dht := reportNode.DHT
+	aggregates.Totals.DHT.Provide.Add(dht.Provide)
+	aggregates.Totals.DHT.FindProviders.Add(dht.FindProviders)
+	aggregates.Totals.DHT.FindPeer.Add(dht.FindPeer)
+
+	load := reportNode.Load
+	aggregates.Totals.Load.Requests += load.Requests
+	aggregates.Totals.Load.Failures += load.Failures
+	aggregates.Totals.Load.Missed += load.Missed
+	if load.Elapsed > aggregates.Totals.Load.Elapsed {
+		aggregates.Totals.Load.Elapsed = load.Elapsed
+	}
+	aggregates.Totals.Load.Latency.Merge(load.Latency)
+
+	retrieval := reportNode.Retrieval
+	aggregates.Totals.Retrieval.Failures += retrieval.Failures
+	aggregates.Totals.Retrieval.Time.Merge(retrieval.Time)
+
+	add := reportNode.Add
+	aggregates.Totals.Add.Files += add.Files
+	aggregates.Totals.Add.Failures += add.Failures
+	aggregates.Totals.Add.Bytes += add.Bytes
+	aggregates.Totals.Add.Time.Merge(add.Time)
+
+	gateway := reportNode.Gateway
+	aggregates.Totals.Gateway.Requests += gateway.Requests
+	aggregates.Totals.Gateway.Failures += gateway.Failures
+	aggregates.Totals.Gateway.Bytes += gateway.Bytes
+	aggregates.Totals.Gateway.FirstByte.Merge(gateway.FirstByte)
+	aggregates.Totals.Gateway.Time.Merge(gateway.Time)
+
+	update := reportNode.Update
+	aggregates.Totals.Update.Published += update.Published
+	aggregates.Totals.Update.Received += update.Received
+	aggregates.Totals.Update.Lost += update.Lost
+	aggregates.Totals.Update.Failures += update.Failures
+	aggregates.Totals.Update.Propagation.Merge(update.Propagation)
+
+	resources := reportNode.Resources
+	for _, pair := range []uint64Pair{
+		{resources.HeapAlloc, &aggregates.Totals.Resources.HeapAlloc},
+		{resources.HeapObjects, &aggregates.Totals.Resources.HeapObjects},
+	} {
+		*pair.aggregate += pair.single
+	}
+	aggregates.Totals.Resources.Goroutines += resources.Goroutines
+
+	for name, value := range reportNode.Custom {
+		if aggregates.Totals.Custom == nil {
+			aggregates.Totals.Custom = make(map[string]float64)
 		}
+		aggregates.Totals.Custom[name] += value
 	}
-	return aggregates
 }
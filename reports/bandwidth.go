@@ -0,0 +1,56 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reports
+
+import "github.com/Netflix/p2plab/metadata"
+
+// ComputeBandwidthMatrix returns the bytes each node exchanged with each other
+// node, resolving the peers of their bandwidth counters to node IDs. Traffic
+// with peers outside of the nodes is left out.
+func ComputeBandwidthMatrix(reportByNodeId map[string]metadata.ReportNode) metadata.ReportBandwidthMatrix {
+	nodeIdByPeerId := make(map[string]string)
+	for id, reportNode := range reportByNodeId {
+		if reportNode.PeerID != "" {
+			nodeIdByPeerId[reportNode.PeerID] = id
+		}
+	}
+	if len(nodeIdByPeerId) == 0 {
+		return nil
+	}
+
+	matrix := make(metadata.ReportBandwidthMatrix)
+	for id, reportNode := range reportByNodeId {
+		for peerId, stats := range reportNode.Bandwidth.Peers {
+			peerNodeId, ok := nodeIdByPeerId[peerId]
+			if !ok || peerNodeId == id {
+				continue
+			}
+			if stats.TotalIn == 0 && stats.TotalOut == 0 {
+				continue
+			}
+
+			row, ok := matrix[id]
+			if !ok {
+				row = make(map[string]metadata.ReportPairBandwidth)
+				matrix[id] = row
+			}
+			row[peerNodeId] = metadata.ReportPairBandwidth{
+				Sent:     stats.TotalOut,
+				Received: stats.TotalIn,
+			}
+		}
+	}
+	return matrix
+}
@@ -0,0 +1,214 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// DiffReports compares the nodes of two reports, matching them by the queries
+// of the scenario that selected them and by their labels, since the nodes of
+// two benchmarks are rarely the same. Nodes with the same ID are matched
+// first, and the remaining nodes with the same queries and labels are matched
+// in the order of their IDs.
+func DiffReports(base, head metadata.Report, by metadata.DiffGrouping, threshold float64) metadata.ReportDiff {
+	diff := metadata.ReportDiff{
+		By:        by,
+		Threshold: threshold,
+	}
+
+	switch by {
+	case metadata.DiffByNode:
+		baseKeys, headKeys := nodeKeys(base), nodeKeys(head)
+		for _, key := range unionKeys(baseKeys, headKeys) {
+			baseIds, headIds := baseKeys[key], headKeys[key]
+			pairs, unmatchedBase, unmatchedHead := matchNodes(baseIds, headIds)
+			for _, pair := range pairs {
+				entry := metadata.DiffEntry{
+					Key:      key,
+					BaseNode: pair[0],
+					HeadNode: pair[1],
+					Metrics:  metadata.DiffNodes(base.Nodes[pair[0]], head.Nodes[pair[1]], threshold),
+				}
+				if len(entry.Metrics) > 0 {
+					diff.Entries = append(diff.Entries, entry)
+				}
+			}
+			diff.UnmatchedBase = append(diff.UnmatchedBase, unmatchedBase...)
+			diff.UnmatchedHead = append(diff.UnmatchedHead, unmatchedHead...)
+		}
+	default:
+		groupIds := queryGroups
+		if by == metadata.DiffByLabel {
+			groupIds = labelGroups
+		}
+
+		baseGroups, headGroups := groupIds(base), groupIds(head)
+		for _, key := range unionKeys(baseGroups, headGroups) {
+			baseIds, inBase := baseGroups[key]
+			headIds, inHead := headGroups[key]
+			switch {
+			case !inHead:
+				diff.UnmatchedBase = append(diff.UnmatchedBase, key)
+				continue
+			case !inBase:
+				diff.UnmatchedHead = append(diff.UnmatchedHead, key)
+				continue
+			}
+
+			entry := metadata.DiffEntry{
+				Key:     key,
+				Metrics: metadata.DiffNodes(aggregateNodes(base, baseIds), aggregateNodes(head, headIds), threshold),
+			}
+			if len(entry.Metrics) > 0 {
+				diff.Entries = append(diff.Entries, entry)
+			}
+		}
+	}
+
+	return diff
+}
+
+// nodeKeys returns the IDs of the nodes of a report in order by the queries
+// that matched them and their labels.
+func nodeKeys(report metadata.Report) map[string][]string {
+	queriesByNodeId := nodeQueries(report)
+
+	keys := make(map[string][]string)
+	for id, reportNode := range report.Nodes {
+		key := queriesByNodeId[id]
+		if len(reportNode.Labels) > 0 {
+			key = fmt.Sprintf("%s %s", key, strings.Join(reportNode.Labels, ","))
+		}
+		keys[key] = append(keys[key], id)
+	}
+	for _, ids := range keys {
+		sort.Strings(ids)
+	}
+	return keys
+}
+
+// queryGroups returns the IDs of the nodes of a report by the queries that
+// matched them.
+func queryGroups(report metadata.Report) map[string][]string {
+	groups := make(map[string][]string)
+	for id, queries := range nodeQueries(report) {
+		groups[queries] = append(groups[queries], id)
+	}
+	return groups
+}
+
+// labelGroups returns the IDs of the nodes of a report by each of their
+// labels.
+func labelGroups(report metadata.Report) map[string][]string {
+	groups := make(map[string][]string)
+	for id, reportNode := range report.Nodes {
+		for _, label := range reportNode.Labels {
+			groups[label] = append(groups[label], id)
+		}
+	}
+	return groups
+}
+
+// nodeQueries returns the queries that matched each node of a report, such as
+// "(and 'neighbors' 'leechers')", or "-" if none did.
+func nodeQueries(report metadata.Report) map[string]string {
+	queriesByNodeId := make(map[string][]string)
+	for q, ids := range report.Queries {
+		for _, id := range ids {
+			queriesByNodeId[id] = append(queriesByNodeId[id], q)
+		}
+	}
+
+	keys := make(map[string]string)
+	for id := range report.Nodes {
+		queries := queriesByNodeId[id]
+		sort.Strings(queries)
+		switch len(queries) {
+		case 0:
+			keys[id] = "-"
+		case 1:
+			keys[id] = queries[0]
+		default:
+			keys[id] = fmt.Sprintf("(and %s)", strings.Join(queries, " "))
+		}
+	}
+	return keys
+}
+
+// matchNodes pairs nodes with the same ID, then pairs the remaining nodes in
+// order, and returns the nodes left over on either side.
+func matchNodes(baseIds, headIds []string) (pairs [][2]string, unmatchedBase, unmatchedHead []string) {
+	inHead := make(map[string]bool)
+	for _, id := range headIds {
+		inHead[id] = true
+	}
+
+	matched := make(map[string]bool)
+	var restBase, restHead []string
+	for _, id := range baseIds {
+		if inHead[id] {
+			pairs = append(pairs, [2]string{id, id})
+			matched[id] = true
+		} else {
+			restBase = append(restBase, id)
+		}
+	}
+	for _, id := range headIds {
+		if !matched[id] {
+			restHead = append(restHead, id)
+		}
+	}
+
+	for len(restBase) > 0 && len(restHead) > 0 {
+		pairs = append(pairs, [2]string{restBase[0], restHead[0]})
+		restBase, restHead = restBase[1:], restHead[1:]
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0] < pairs[j][0]
+	})
+	return pairs, restBase, restHead
+}
+
+// aggregateNodes returns the totals of some nodes of a report.
+func aggregateNodes(report metadata.Report, ids []string) metadata.ReportNode {
+	reportByNodeId := make(map[string]metadata.ReportNode)
+	for _, id := range ids {
+		reportByNodeId[id] = report.Nodes[id]
+	}
+	return ComputeAggregates(reportByNodeId).Totals
+}
+
+// unionKeys returns the keys of either map in order.
+func unionKeys(a, b map[string][]string) []string {
+	set := make(map[string]struct{})
+	for key := range a {
+		set[key] = struct{}{}
+	}
+	for key := range b {
+		set[key] = struct{}{}
+	}
+
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reports
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// SummarizeTrials returns a summary with the mean total and stage times of the
+// trials, the standard deviation of the total time, and the stages skipped in
+// any trial.
+func SummarizeTrials(trials []metadata.ReportTrial) metadata.ReportSummary {
+	summary := metadata.ReportSummary{
+		Stages: make(map[string]time.Duration),
+	}
+	if len(trials) == 0 {
+		return summary
+	}
+
+	n := time.Duration(len(trials))
+	skipped := make(map[string]struct{})
+	for _, trial := range trials {
+		summary.TotalTime += trial.Summary.TotalTime / n
+		for name, d := range trial.Summary.Stages {
+			summary.Stages[name] += d / n
+		}
+		for _, name := range trial.Summary.Skipped {
+			skipped[name] = struct{}{}
+		}
+	}
+
+	for name := range skipped {
+		summary.Skipped = append(summary.Skipped, name)
+	}
+	sort.Strings(summary.Skipped)
+
+	var variance float64
+	for _, trial := range trials {
+		diff := float64(trial.Summary.TotalTime - summary.TotalTime)
+		variance += diff * diff / float64(len(trials))
+	}
+	summary.TotalTimeStdDev = time.Duration(math.Sqrt(variance))
+
+	return summary
+}
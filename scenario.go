@@ -35,6 +35,10 @@ type ScenarioAPI interface {
 	List(ctx context.Context, opts ...ListOption) ([]Scenario, error)
 
 	Remove(ctx context.Context, names ...string) error
+
+	// Validate checks a scenario definition without creating it, returning
+	// diagnostics for each problem found.
+	Validate(ctx context.Context, sdef metadata.ScenarioDefinition) ([]metadata.Diagnostic, error)
 }
 
 // Scenario is a schema for benchmarks that describes objects to benchmark, how
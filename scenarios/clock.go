@@ -0,0 +1,61 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// startMargin is added to the slowest round trip when scheduling a stage's
+// start, to leave time for every node to receive its task.
+const startMargin = time.Second
+
+type clockKey struct{}
+
+// withClocks returns a context whose stages start at a scheduled instant,
+// translated to the clock of each node by its offset.
+func withClocks(ctx context.Context, offsets map[string]metadata.ClockOffset) context.Context {
+	return context.WithValue(ctx, clockKey{}, offsets)
+}
+
+// scheduleStart returns the local instant at which the nodes of a stage
+// start together, or a zero instant if ctx has no clock offsets.
+func scheduleStart(ctx context.Context) time.Time {
+	offsets, ok := ctx.Value(clockKey{}).(map[string]metadata.ClockOffset)
+	if !ok {
+		return time.Time{}
+	}
+
+	var slowest time.Duration
+	for _, offset := range offsets {
+		if offset.RoundTrip > slowest {
+			slowest = offset.RoundTrip
+		}
+	}
+	return time.Now().Add(slowest + startMargin)
+}
+
+// nodeTime translates a local instant to the clock of a node. Nodes without a
+// measured offset are assumed to agree with the local clock.
+func nodeTime(ctx context.Context, id string, t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	offsets, _ := ctx.Value(clockKey{}).(map[string]metadata.ClockOffset)
+	return t.Add(offsets[id].Offset)
+}
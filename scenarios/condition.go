@@ -0,0 +1,82 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/nodes"
+	"github.com/Netflix/p2plab/reports"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Conditions returns a StageCondition that evaluates the conditions of a
+// stage against the nodes' reports so far, where the total time is the time
+// since the condition was created. A stage whose conditions do not hold is
+// skipped, unless it aborts otherwise.
+func Conditions(ns []p2plab.Node) StageCondition {
+	start := time.Now()
+	return func(ctx context.Context, stage metadata.StagePlan, executions map[string]StageExecution) (bool, error) {
+		if len(stage.When) == 0 {
+			return true, nil
+		}
+
+		reportByNodeID, err := nodes.CollectReports(ctx, ns)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to collect reports for stage conditions")
+		}
+
+		report := metadata.Report{
+			Summary: metadata.ReportSummary{
+				TotalTime: time.Since(start),
+				Stages:    make(map[string]time.Duration),
+			},
+			Aggregates: reports.ComputeAggregates(reportByNodeID),
+		}
+		for name, execution := range executions {
+			if !execution.Skipped {
+				report.Summary.Stages[name] = execution.End.Sub(execution.Start)
+			}
+		}
+
+		results, err := metadata.EvaluateExpectations(report, stage.When)
+		if err != nil {
+			return false, err
+		}
+
+		var failed []string
+		for _, result := range results {
+			if !result.Passed {
+				failed = append(failed, fmt.Sprintf("%s (actual %s)", result.Expectation, result.Actual))
+			}
+		}
+		if len(failed) == 0 {
+			return true, nil
+		}
+
+		if stage.Otherwise == metadata.OtherwiseAbort {
+			return false, errors.Errorf("conditions not met: %s", strings.Join(failed, ", "))
+		}
+
+		zerolog.Ctx(ctx).Info().Strs("conditions", failed).Msg("Skipping stage whose conditions are not met")
+		return false, nil
+	}
+}
@@ -15,23 +15,64 @@
 package scenarios
 
 import (
-	"encoding/json"
+	"bytes"
 	"io/ioutil"
+	"text/template"
 
+	"github.com/Netflix/p2plab/errdefs"
 	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/defutil"
+	"github.com/pkg/errors"
 )
 
-func Parse(filename string) (metadata.ScenarioDefinition, error) {
+// Parse reads a scenario definition from a JSON or YAML file. The file is
+// executed as a template with vars, such as {{.objectSize}}, so that a single
+// definition can drive a sweep over parameters. Referencing a variable that is
+// not set is an error.
+func Parse(filename string, vars map[string]interface{}) (metadata.ScenarioDefinition, error) {
 	var sdef metadata.ScenarioDefinition
 	content, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return sdef, err
 	}
 
-	err = json.Unmarshal(content, &sdef)
+	return ParseContent(filename, content, vars)
+}
+
+// ParseContent reads a scenario definition from the content of a template,
+// executed with vars as in Parse. The content is YAML if name has a YAML
+// extension, and JSON otherwise.
+func ParseContent(name string, content []byte, vars map[string]interface{}) (metadata.ScenarioDefinition, error) {
+	var sdef metadata.ScenarioDefinition
+	content, err := Render(name, content, vars)
+	if err != nil {
+		return sdef, err
+	}
+
+	err = defutil.Decode(name, content, &sdef)
 	if err != nil {
 		return sdef, err
 	}
 
 	return sdef, nil
 }
+
+// Render executes a scenario definition template with vars.
+func Render(name string, content []byte, vars map[string]interface{}) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "failed to parse scenario template: %s", err)
+	}
+
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, vars)
+	if err != nil {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "failed to execute scenario template: %s", err)
+	}
+
+	return buf.Bytes(), nil
+}
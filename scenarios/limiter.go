@@ -0,0 +1,121 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// limiterPollInterval is how often a limiter checks whether its limit has
+// grown during a ramp-up.
+var limiterPollInterval = 100 * time.Millisecond
+
+// limiter limits how many tasks of a stage run at once, growing the limit
+// over the stage's ramp-up. It is acquired by a single goroutine, so that
+// tasks start in order.
+type limiter struct {
+	max    int
+	rampUp *metadata.RampUpPlan
+	start  time.Time
+
+	mu       sync.Mutex
+	running  int
+	released chan struct{}
+}
+
+// newLimiter returns a limiter for the tasks of a stage, or nil if they all
+// start at once.
+func newLimiter(stage metadata.StagePlan) *limiter {
+	max := len(stage.Tasks)
+	if stage.MaxConcurrency > 0 && stage.MaxConcurrency < max {
+		max = stage.MaxConcurrency
+	}
+	if max == len(stage.Tasks) && stage.RampUp == nil {
+		return nil
+	}
+
+	return &limiter{
+		max:      max,
+		rampUp:   stage.RampUp,
+		start:    time.Now(),
+		released: make(chan struct{}, 1),
+	}
+}
+
+// limit returns how many tasks may run at once after elapsed. During a
+// ramp-up, at least one task may run.
+func (l *limiter) limit(elapsed time.Duration) int {
+	if l.rampUp == nil || elapsed >= l.rampUp.Duration {
+		return l.max
+	}
+
+	var (
+		progress = float64(elapsed) / float64(l.rampUp.Duration)
+		limit    float64
+	)
+	switch l.rampUp.Profile {
+	case metadata.RampUpLinear:
+		limit = float64(l.max) * progress
+	case metadata.RampUpStep:
+		steps := float64(l.rampUp.Steps)
+		limit = float64(l.max) * (math.Floor(progress*steps) + 1) / steps
+	case metadata.RampUpExponential:
+		limit = math.Pow(float64(l.max), progress)
+	default:
+		limit = float64(l.max)
+	}
+
+	if limit < 1 {
+		return 1
+	}
+	return int(limit)
+}
+
+// acquire waits until another task may run.
+func (l *limiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.running < l.limit(time.Since(l.start)) {
+			l.running++
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-l.released:
+		case <-time.After(limiterPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release marks a task as completed.
+func (l *limiter) release() {
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+
+	select {
+	case l.released <- struct{}{}:
+	default:
+	}
+}
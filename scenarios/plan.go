@@ -16,23 +16,90 @@ package scenarios
 
 import (
 	"context"
+	"math/rand"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Netflix/p2plab"
 	"github.com/Netflix/p2plab/actions"
+	"github.com/Netflix/p2plab/errdefs"
 	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/nodes"
 	"github.com/Netflix/p2plab/query"
 	"github.com/Netflix/p2plab/transformers"
 	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"golang.org/x/sync/errgroup"
 )
 
-func Plan(ctx context.Context, sdef metadata.ScenarioDefinition, ts *transformers.Transformers, peer p2plab.Peer, lset p2plab.LabeledSet) (plan metadata.ScenarioPlan, queries map[string][]string, err error) {
+// TrialSeed returns the random seed of a trial derived from a scenario's seed.
+func TrialSeed(seed int64, trial int) int64 {
+	return seed + int64(trial)
+}
+
+// UsesTopology returns whether the queries of a scenario's stages, network
+// impairments or traffic traverse the topology of the nodes.
+func UsesTopology(sdef metadata.ScenarioDefinition) bool {
+	var qs []string
+	for _, stage := range sdef.StageDefinitions() {
+		for q := range stage.Actions {
+			qs = append(qs, q)
+		}
+	}
+	for q, impairment := range sdef.Network {
+		qs = append(qs, q, impairment.Peers)
+	}
+	for q, traffic := range sdef.Traffic {
+		qs = append(qs, q, traffic.Peers)
+	}
+
+	for _, q := range qs {
+		if query.UsesTopology(q) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSelections matches each of a scenario's named selections once,
+// returning a context in which the scenario's queries reference the matched
+// nodes, so that every stage and trial selects the same nodes. Selections
+// that split their nodes also define a selection for each part.
+func ResolveSelections(ctx context.Context, selections map[string]string, lset p2plab.LabeledSet) (context.Context, error) {
+	resolved := make(map[string]p2plab.LabeledSet)
+	for name, q := range selections {
+		qry, err := query.Parse(ctx, q)
+		if err != nil {
+			return nil, errors.Wrapf(err, "selection %q", name)
+		}
+
+		selected, err := query.Select(ctx, name, qry, lset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "selection %q", name)
+		}
+
+		for selection, mset := range selected {
+			var ids []string
+			for _, l := range mset.Slice() {
+				ids = append(ids, l.ID())
+			}
+			zerolog.Ctx(ctx).Debug().Str("selection", selection).Str("query", q).Strs("ids", ids).Msg("Resolved selection")
+
+			resolved[selection] = mset
+		}
+	}
+
+	return query.WithSelections(ctx, resolved), nil
+}
+
+// Plan resolves the scenario's queries and actions into tasks for each node.
+// Random choices are made with rng so that plans can be reproduced.
+func Plan(ctx context.Context, sdef metadata.ScenarioDefinition, ts *transformers.Transformers, peer p2plab.Peer, lset p2plab.LabeledSet, rng *rand.Rand) (plan metadata.ScenarioPlan, queries map[string][]string, err error) {
 	plan = metadata.ScenarioPlan{
-		Objects:   make(map[string]cid.Cid),
-		Seed:      make(map[string]metadata.Task),
-		Benchmark: make(map[string]metadata.Task),
+		Objects: make(map[string]cid.Cid),
 	}
 
 	objects, gctx := errgroup.WithContext(ctx)
@@ -66,51 +133,205 @@ func Plan(ctx context.Context, sdef metadata.ScenarioDefinition, ts *transformer
 		return plan, nil, err
 	}
 
-	zerolog.Ctx(ctx).Info().Msg("Planning scenario seed")
-	for q, a := range sdef.Seed {
-		qry, err := query.Parse(ctx, q)
-		if err != nil {
-			return plan, nil, err
+	stages, err := metadata.SortStages(sdef.StageDefinitions())
+	if err != nil {
+		return plan, nil, err
+	}
+
+	// Queries that traverse the topology match the connections the nodes have
+	// open when the trial is planned.
+	if UsesTopology(sdef) {
+		zerolog.Ctx(ctx).Info().Msg("Collecting topology for queries")
+		var ns []p2plab.Node
+		for _, l := range lset.Slice() {
+			ns = append(ns, l.(p2plab.Node))
+		}
+		ctx = query.WithTopology(ctx, nodes.CollectTopology(ctx, ns).Edges())
+	}
+
+	queries = make(map[string][]string)
+	for _, stageDef := range stages {
+		zerolog.Ctx(ctx).Info().Str("stage", stageDef.Name).Msg("Planning scenario stage")
+		stage := metadata.StagePlan{
+			Name:           stageDef.Name,
+			DependsOn:      stageDef.DependsOn,
+			Seed:           stageDef.Seed,
+			Warmup:         stageDef.Warmup,
+			OnFailure:      stageDef.OnFailure,
+			When:           stageDef.When,
+			Otherwise:      stageDef.Otherwise,
+			MaxConcurrency: stageDef.MaxConcurrency,
+			Tasks:          make(metadata.ScenarioStage),
+		}
+		if stageDef.RampUp != nil {
+			rampUp, err := stageDef.RampUp.Plan()
+			if err != nil {
+				return plan, nil, errors.Wrapf(err, "stage %q", stageDef.Name)
+			}
+			stage.RampUp = &rampUp
+		}
+		if stageDef.Timeout != "" {
+			stage.Timeout, err = time.ParseDuration(stageDef.Timeout)
+			if err != nil {
+				return plan, nil, errors.Wrapf(errdefs.ErrInvalidArgument, "stage %q has invalid timeout %q", stageDef.Name, stageDef.Timeout)
+			}
 		}
 
-		mset, err := qry.Match(ctx, lset)
+		// Actions are planned in a stable order so that random choices are
+		// reproducible from the seed.
+		var qs []string
+		for q := range stageDef.Actions {
+			qs = append(qs, q)
+		}
+		sort.Strings(qs)
+
+		for _, q := range qs {
+			a := stageDef.Actions[q]
+			qry, err := query.ParseVersion(ctx, q, sdef.QueryVersion)
+			if err != nil {
+				return plan, nil, err
+			}
+
+			mset, err := qry.Match(ctx, lset)
+			if err != nil {
+				return plan, nil, err
+			}
+
+			var ids []string
+			for _, l := range mset.Slice() {
+				ids = append(ids, l.ID())
+			}
+			zerolog.Ctx(ctx).Debug().Str("query", qry.String()).Strs("ids", ids).Msg("Matched query")
+
+			// Only queries of measured stages are reported.
+			if !stageDef.Seed && !stageDef.Warmup {
+				queries[qry.String()] = ids
+			}
+
+			action, err := actions.Parse(plan.Objects, a, rng)
+			if err != nil {
+				return plan, nil, err
+			}
+
+			var ns []p2plab.Node
+			for _, l := range mset.Slice() {
+				ns = append(ns, l.(p2plab.Node))
+			}
+
+			taskMap, err := action.Tasks(ctx, ns)
+			if err != nil {
+				return plan, nil, err
+			}
+			mergeTasks(stage.Tasks, taskMap)
+		}
+
+		plan.Stages = append(plan.Stages, stage)
+	}
+
+	zerolog.Ctx(ctx).Info().Msg("Planning network impairments")
+	plan.Network = make(map[string][]metadata.NetworkRule)
+	for q, impairment := range sdef.Network {
+		mset, err := matchQuery(ctx, q, lset)
 		if err != nil {
 			return plan, nil, err
 		}
 
-		var ids []string
+		var destinations []string
+		if impairment.Peers != "" {
+			pset, err := matchQuery(ctx, impairment.Peers, lset)
+			if err != nil {
+				return plan, nil, err
+			}
+
+			for _, l := range pset.Slice() {
+				destinations = append(destinations, l.(p2plab.Node).Metadata().Address)
+			}
+		}
+
 		for _, l := range mset.Slice() {
-			ids = append(ids, l.ID())
+			plan.Network[l.ID()] = append(plan.Network[l.ID()], metadata.NetworkRule{
+				NetworkImpairment: impairment,
+				Destinations:      destinations,
+			})
 		}
-		zerolog.Ctx(ctx).Debug().Str("query", qry.String()).Strs("ids", ids).Msg("Matched query")
+	}
 
-		action, err := actions.Parse(plan.Objects, a)
+	if sdef.Topology != nil {
+		zerolog.Ctx(ctx).Info().Str("shape", string(sdef.Topology.Shape)).Msg("Planning topology")
+		plan.Topology, err = PlanTopology(ctx, *sdef.Topology, lset, rng)
 		if err != nil {
 			return plan, nil, err
 		}
+	}
 
-		var ns []p2plab.Node
-		for _, l := range mset.Slice() {
-			ns = append(ns, l.(p2plab.Node))
+	zerolog.Ctx(ctx).Info().Msg("Planning background traffic")
+	plan.Traffic = make(map[string]metadata.Task)
+	for q, traffic := range sdef.Traffic {
+		mset, err := matchQuery(ctx, q, lset)
+		if err != nil {
+			return plan, nil, err
+		}
+
+		pset, err := matchQuery(ctx, traffic.Peers, lset)
+		if err != nil {
+			return plan, nil, err
 		}
 
-		taskMap, err := action.Tasks(ctx, ns)
+		rate, err := traffic.BytesPerSecond()
 		if err != nil {
 			return plan, nil, err
 		}
 
-		plan.Seed = taskMap
+		streams := traffic.Streams
+		if streams == 0 {
+			streams = 1
+		}
+
+		for _, l := range mset.Slice() {
+			if _, ok := plan.Traffic[l.ID()]; ok {
+				return plan, nil, errors.Wrapf(errdefs.ErrInvalidArgument, "node %q matches more than one traffic query", l.ID())
+			}
+
+			var peers []string
+			for _, other := range pset.Slice() {
+				if other.ID() != l.ID() {
+					peers = append(peers, other.ID())
+				}
+			}
+			if len(peers) == 0 {
+				continue
+			}
+
+			plan.Traffic[l.ID()] = metadata.Task{
+				Type:    metadata.TaskTraffic,
+				Subject: strings.Join(peers, ","),
+				Count:   streams,
+				Rate:    rate,
+			}
+		}
 	}
 
-	zerolog.Ctx(ctx).Info().Msg("Planning scenario benchmark")
-	queries = make(map[string][]string)
-	for q, a := range sdef.Benchmark {
-		qry, err := query.Parse(ctx, q)
+	if sdef.Soak != nil {
+		soak, err := sdef.Soak.Plan()
 		if err != nil {
 			return plan, nil, err
 		}
+		plan.Soak = &soak
+	}
+
+	plan.SampleInterval, err = metadata.ParseSampleInterval(sdef.SampleInterval)
+	if err != nil {
+		return plan, nil, err
+	}
 
-		mset, err := qry.Match(ctx, lset)
+	// Peer queries are reported so that mixed clusters can be compared by
+	// the peer definition each node ran.
+	plan.Peers, err = PlanPeers(ctx, sdef.Peers, lset)
+	if err != nil {
+		return plan, nil, err
+	}
+	for q := range sdef.Peers {
+		mset, err := matchQuery(ctx, q, lset)
 		if err != nil {
 			return plan, nil, err
 		}
@@ -119,29 +340,54 @@ func Plan(ctx context.Context, sdef metadata.ScenarioDefinition, ts *transformer
 		for _, l := range mset.Slice() {
 			ids = append(ids, l.ID())
 		}
+		queries[q] = ids
+	}
 
-		zerolog.Ctx(ctx).Debug().Str("query", qry.String()).Strs("ids", ids).Msg("Matched query")
-		queries[qry.String()] = ids
+	return plan, queries, nil
+}
 
-		action, err := actions.Parse(plan.Objects, a)
+// mergeTasks adds the tasks of an action to the tasks of a stage. A node
+// matched by several queries of a stage runs the task of the query planned
+// last, which is the last in sorted order.
+func mergeTasks(tasks metadata.ScenarioStage, taskMap map[string]metadata.Task) {
+	for id, task := range taskMap {
+		tasks[id] = task
+	}
+}
+
+// PlanPeers resolves the scenario's peer definitions into the peer definition
+// each matched node runs for the benchmark, as its own peer definition with
+// the scenario's overrides applied.
+func PlanPeers(ctx context.Context, peers map[string]metadata.PeerDefinition, lset p2plab.LabeledSet) (map[string]metadata.PeerDefinition, error) {
+	if len(peers) == 0 {
+		return nil, nil
+	}
+
+	pdefs := make(map[string]metadata.PeerDefinition)
+	for q, pdef := range peers {
+		mset, err := matchQuery(ctx, q, lset)
 		if err != nil {
-			return plan, nil, err
+			return nil, err
 		}
 
-		var ns []p2plab.Node
 		for _, l := range mset.Slice() {
-			ns = append(ns, l.(p2plab.Node))
+			if _, ok := pdefs[l.ID()]; ok {
+				return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "node %q matches more than one peers query", l.ID())
+			}
+			pdefs[l.ID()] = l.(p2plab.Node).Metadata().Peer.Override(pdef)
 		}
+	}
 
-		taskMap, err := action.Tasks(ctx, ns)
-		if err != nil {
-			return plan, nil, err
-		}
+	return pdefs, nil
+}
 
-		plan.Benchmark = taskMap
+func matchQuery(ctx context.Context, q string, lset p2plab.LabeledSet) (p2plab.LabeledSet, error) {
+	qry, err := query.Parse(ctx, q)
+	if err != nil {
+		return nil, err
 	}
 
-	return plan, queries, nil
+	return qry.Match(ctx, lset)
 }
 
 func AddOptionsFromDefinition(odef metadata.ObjectDefinition) []p2plab.AddOption {
@@ -16,7 +16,10 @@ package scenarios
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Netflix/p2plab"
@@ -34,20 +37,401 @@ import (
 type Execution struct {
 	Start  time.Time
 	End    time.Time
+	Stages map[string]StageExecution
 	Report map[string]metadata.ReportNode
 	Span   opentracing.Span
+
+	// Snapshots are the interim reports collected during a soak.
+	Snapshots []metadata.ReportSnapshot
+
+	// Trace is the sequence of tasks executed by the measured stages.
+	Trace metadata.Trace
+
+	// Series are the samples of each node taken during the measured stages.
+	Series map[string][]metadata.ReportSample
+
+	// Topology is the connection graph of the nodes when the measured stages
+	// started and ended.
+	Topology metadata.ReportTopology
+
+	// Profiles are the profiles of each node's labapp captured while the
+	// measured stages ran, by the node's ID.
+	Profiles map[string]map[metadata.ProfileKind][]byte
+}
+
+// StageExecution records when a stage started and ended.
+type StageExecution struct {
+	Start time.Time
+	End   time.Time
+
+	// Skipped is whether the stage was skipped because its conditions did
+	// not hold.
+	Skipped bool
+}
+
+// StageCondition returns whether a stage should run, given the executions of
+// the stages that have completed so far.
+type StageCondition func(ctx context.Context, stage metadata.StagePlan, executions map[string]StageExecution) (bool, error)
+
+// RunOption configures how a plan is run.
+type RunOption func(*RunSettings) error
+
+// RunSettings are the settings of a run.
+type RunSettings struct {
+	// Replay is a trace replayed in place of the measured stages.
+	Replay *metadata.Trace
+
+	// Snapshot is called with the snapshots so far and the nodes' interim
+	// reports each time a snapshot is collected during a soak.
+	Snapshot SnapshotFunc
+
+	// Stage is called each time a stage starts, completes or is skipped.
+	Stage StageFunc
+
+	// Task is called each time a node completes a task.
+	Task TaskFunc
+
+	// Profiles are the kinds of profiles captured from each node's labapp
+	// while the measured stages run.
+	Profiles []metadata.ProfileKind
+}
+
+// StageFunc observes the execution of a stage. A stage that has started but
+// not completed has a zero End.
+type StageFunc func(name string, execution StageExecution)
+
+// TaskFunc observes a node completing a task, whether or not it succeeded.
+type TaskFunc func(id string, task metadata.Task)
+
+// SnapshotFunc handles the interim reports collected during a soak.
+type SnapshotFunc func(ctx context.Context, snapshots []metadata.ReportSnapshot, reports map[string]metadata.ReportNode) error
+
+// WithReplay replays a trace in place of the measured stages.
+func WithReplay(trace metadata.Trace) RunOption {
+	return func(s *RunSettings) error {
+		s.Replay = &trace
+		return nil
+	}
+}
+
+// WithSnapshot calls fn each time an interim report snapshot is collected
+// during a soak.
+func WithSnapshot(fn SnapshotFunc) RunOption {
+	return func(s *RunSettings) error {
+		s.Snapshot = fn
+		return nil
+	}
 }
 
-func Run(ctx context.Context, lset p2plab.LabeledSet, plan metadata.ScenarioPlan, seederAddrs []string) (*Execution, error) {
+// WithStageFunc calls fn each time a stage starts, completes or is skipped,
+// including seed and warmup stages.
+func WithStageFunc(fn StageFunc) RunOption {
+	return func(s *RunSettings) error {
+		s.Stage = fn
+		return nil
+	}
+}
+
+// WithTaskFunc calls fn each time a node completes a task, including the
+// tasks of seed and warmup stages.
+func WithTaskFunc(fn TaskFunc) RunOption {
+	return func(s *RunSettings) error {
+		s.Task = fn
+		return nil
+	}
+}
+
+// WithProfiles captures profiles of each node's labapp while the measured
+// stages run.
+func WithProfiles(kinds []metadata.ProfileKind) RunOption {
+	return func(s *RunSettings) error {
+		s.Profiles = kinds
+		return nil
+	}
+}
+
+type stageFuncKey struct{}
+
+type taskFuncKey struct{}
+
+// observeStage calls the StageFunc in ctx, if any, with a stage's execution.
+func observeStage(ctx context.Context, name string, execution StageExecution) {
+	fn, ok := ctx.Value(stageFuncKey{}).(StageFunc)
+	if ok && fn != nil {
+		fn(name, execution)
+	}
+}
+
+// observeTask calls the TaskFunc in ctx, if any, with a completed task.
+func observeTask(ctx context.Context, id string, task metadata.Task) {
+	fn, ok := ctx.Value(taskFuncKey{}).(TaskFunc)
+	if ok && fn != nil {
+		fn(id, task)
+	}
+}
+
+// Run executes the seed stages of a plan and then the remaining stages in a
+// benchmarking session.
+func Run(ctx context.Context, lset p2plab.LabeledSet, plan metadata.ScenarioPlan, seederAddrs []string, opts ...RunOption) (*Execution, error) {
 	span, ctx := traceutil.StartSpanFromContext(ctx, "scenarios.Run")
 	defer span.Finish()
 
-	err := Seed(ctx, lset, plan.Seed, seederAddrs)
+	var settings RunSettings
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = context.WithValue(ctx, stageFuncKey{}, settings.Stage)
+	ctx = context.WithValue(ctx, taskFuncKey{}, settings.Task)
+
+	var seeds, warmups, stages []metadata.StagePlan
+	for _, stage := range plan.Stages {
+		switch {
+		case stage.Seed:
+			seeds = append(seeds, stage)
+		case stage.Warmup:
+			warmups = append(warmups, stage)
+		default:
+			stages = append(stages, stage)
+		}
+	}
+
+	_, err := RunStages(ctx, seeds, nil, func(ctx context.Context, stage metadata.StagePlan) error {
+		return Seed(ctx, lset, stage.Tasks, seederAddrs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(plan.Network) > 0 {
+		err = Impair(ctx, lset, plan.Network)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			// The benchmark's context may be cancelled by now, so the
+			// impairments are removed with a context of their own so that
+			// they are not left on the nodes.
+			ictx, cancel := context.WithTimeout(context.Background(), unimpairTimeout)
+			defer cancel()
+			ictx = zerolog.Ctx(ctx).WithContext(ictx)
+
+			err := Impair(ictx, lset, nil)
+			if err != nil {
+				zerolog.Ctx(ictx).Warn().Err(err).Msg("Failed to remove network impairments")
+			}
+		}()
+	}
+
+	return Session(ctx, lset, plan, warmups, stages, settings)
+}
+
+// unimpairTimeout is how long the network impairments of a benchmark are
+// removed for once it ends.
+const unimpairTimeout = time.Minute
+
+// Impair applies network rules to nodes by their IDs. Nodes without rules
+// have their impairments removed.
+func Impair(ctx context.Context, lset p2plab.LabeledSet, network map[string][]metadata.NetworkRule) error {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "scenarios.Impair")
+	defer span.Finish()
+
+	ns, err := LabeledSetToNodes(lset)
+	if err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Int("nodes", len(network)).Msg("Updating network impairments")
+	eg, gctx := errgroup.WithContext(ctx)
+	for _, n := range ns {
+		n := n
+		eg.Go(func() error {
+			err := n.Impair(gctx, network[n.ID()])
+			if err != nil {
+				return errors.Wrapf(err, "failed to impair network of node %q", n.ID())
+			}
+			return nil
+		})
+	}
+
+	return eg.Wait()
+}
+
+// RunStages executes stages concurrently, starting each stage once all of its
+// dependencies have completed. Dependencies outside of stages are assumed to
+// have completed already. If cond is not nil, a stage is skipped when cond
+// returns false.
+func RunStages(ctx context.Context, stages []metadata.StagePlan, cond StageCondition, fn func(context.Context, metadata.StagePlan) error) (map[string]StageExecution, error) {
+	doneByName := make(map[string]chan struct{})
+	for _, stage := range stages {
+		doneByName[stage.Name] = make(chan struct{})
+	}
+
+	var (
+		mu         sync.Mutex
+		executions = make(map[string]StageExecution)
+	)
+	eg, gctx := errgroup.WithContext(ctx)
+	for _, stage := range stages {
+		stage := stage
+		eg.Go(func() error {
+			for _, dep := range stage.DependsOn {
+				done, ok := doneByName[dep]
+				if !ok {
+					continue
+				}
+
+				select {
+				case <-done:
+				case <-gctx.Done():
+					return gctx.Err()
+				}
+			}
+
+			span, sctx := traceutil.StartSpanFromContext(gctx, "scenarios.Stage")
+			defer span.Finish()
+			span.SetTag("stage", stage.Name)
+
+			logger := zerolog.Ctx(ctx).With().Str("stage", stage.Name).Logger()
+			sctx = logger.WithContext(sctx)
+
+			if cond != nil {
+				mu.Lock()
+				completed := make(map[string]StageExecution)
+				for name, execution := range executions {
+					completed[name] = execution
+				}
+				mu.Unlock()
+
+				ok, err := cond(sctx, stage, completed)
+				if err != nil {
+					return errors.Wrapf(err, "failed to run stage %q", stage.Name)
+				}
+
+				if !ok {
+					now := time.Now()
+					execution := StageExecution{Start: now, End: now, Skipped: true}
+					mu.Lock()
+					executions[stage.Name] = execution
+					mu.Unlock()
+					observeStage(ctx, stage.Name, execution)
+
+					close(doneByName[stage.Name])
+					return nil
+				}
+			}
+
+			logger.Info().Msg("Starting stage")
+			execution := StageExecution{Start: time.Now()}
+			observeStage(ctx, stage.Name, execution)
+			err := runStage(sctx, stage, fn)
+			if err != nil {
+				return errors.Wrapf(err, "failed to run stage %q", stage.Name)
+			}
+			execution.End = time.Now()
+			logger.Info().Msg("Completed stage")
+
+			mu.Lock()
+			executions[stage.Name] = execution
+			mu.Unlock()
+			observeStage(ctx, stage.Name, execution)
+
+			close(doneByName[stage.Name])
+			return nil
+		})
+	}
+
+	err := eg.Wait()
 	if err != nil {
 		return nil, err
 	}
 
-	return Session(ctx, lset, plan.Benchmark)
+	return executions, nil
+}
+
+// runStage runs a stage under its timeout and failure policy.
+func runStage(ctx context.Context, stage metadata.StagePlan, fn func(context.Context, metadata.StagePlan) error) error {
+	policy, err := metadata.ParseFailurePolicy(stage.OnFailure)
+	if err != nil {
+		return err
+	}
+
+	return retry(ctx, policy, func() error {
+		sctx := ctx
+		if stage.Timeout > 0 {
+			var cancel context.CancelFunc
+			sctx, cancel = context.WithTimeout(ctx, stage.Timeout)
+			defer cancel()
+		}
+
+		err := fn(sctx, stage)
+		if err != nil && sctx.Err() == context.DeadlineExceeded {
+			return errors.Wrapf(err, "timed out after %s", stage.Timeout)
+		}
+		return err
+	})
+}
+
+// runTask runs a task on a node under its deadline and failure policy, and
+// records it if ctx has a recorder. A scheduled start is given in the local
+// clock and translated to the node's clock.
+func runTask(ctx context.Context, n p2plab.Node, task metadata.Task) error {
+	policy, err := metadata.ParseFailurePolicy(task.OnFailure)
+	if err != nil {
+		return err
+	}
+
+	// Each task is an action whose ID is carried by its requests, so that
+	// the samples and logs of every daemon involved can be correlated.
+	action := traceutil.NewActionID()
+	ctx = traceutil.WithActionID(ctx, action)
+	logger := zerolog.Ctx(ctx).With().Str("action", action).Logger()
+	ctx = logger.WithContext(ctx)
+
+	start := time.Now()
+	if task.StartAt.After(start) {
+		start = task.StartAt
+	}
+	defer record(ctx, n.ID(), task, start)
+	defer observeTask(ctx, n.ID(), task)
+	task.StartAt = nodeTime(ctx, n.ID(), task.StartAt)
+
+	return retry(ctx, policy, func() error {
+		tctx := ctx
+		if task.Deadline > 0 {
+			var cancel context.CancelFunc
+			tctx, cancel = context.WithTimeout(ctx, task.Deadline)
+			defer cancel()
+		}
+
+		err := n.Run(tctx, task)
+		if err != nil && tctx.Err() == context.DeadlineExceeded {
+			return errors.Wrapf(err, "task %q timed out after %s", task.Type, task.Deadline)
+		}
+		return err
+	})
+}
+
+// retry calls fn until it succeeds or the failure policy is out of retries.
+// A failure is ignored if the policy continues, unless ctx is done.
+func retry(ctx context.Context, policy metadata.FailurePolicy, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || ctx.Err() != nil || attempt >= policy.Retries {
+			break
+		}
+		zerolog.Ctx(ctx).Warn().Err(err).Int("attempt", attempt+1).Int("retries", policy.Retries).Msg("Retrying after failure")
+	}
+
+	if err != nil && policy.Continue && ctx.Err() == nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Continuing after failure")
+		return nil
+	}
+	return err
 }
 
 func LabeledSetToNodes(lset p2plab.LabeledSet) ([]p2plab.Node, error) {
@@ -91,7 +475,7 @@ func Seed(ctx context.Context, lset p2plab.LabeledSet, seed metadata.ScenarioSta
 			}
 
 			logger.Debug().Str("task", string(task.Type)).Msg("Executing seeding task")
-			err = n.Run(gctx, task)
+			err = runTask(logger.WithContext(gctx), n, task)
 			if err != nil {
 				return errors.Wrap(err, "failed to run seeding task")
 			}
@@ -118,7 +502,18 @@ func Seed(ctx context.Context, lset p2plab.LabeledSet, seed metadata.ScenarioSta
 	return nil
 }
 
-func Session(ctx context.Context, lset p2plab.LabeledSet, benchmark metadata.ScenarioStage) (*Execution, error) {
+// Session executes stages in a benchmarking session and collects the nodes'
+// reports. Warmup stages execute first and are excluded from the reports.
+// Nodes are connected along the plan's topology if it is not nil, and
+// otherwise to every other node. Background traffic runs for the duration of
+// the stages, which loop if the plan soaks. The tasks of the measured stages
+// are recorded in the execution's trace, and if the settings have a replay,
+// it is replayed in place of the stages. Nodes are sampled while the measured
+// stages run if the plan has a sample interval, and the utilization of their
+// hosts is collected from their labagents. The connections between nodes are
+// captured when the measured stages start and end, and so are the profiles of
+// their labapps if the settings have any.
+func Session(ctx context.Context, lset p2plab.LabeledSet, plan metadata.ScenarioPlan, warmups, stages []metadata.StagePlan, settings RunSettings) (*Execution, error) {
 	ns, err := LabeledSetToNodes(lset)
 	if err != nil {
 		return nil, err
@@ -126,23 +521,97 @@ func Session(ctx context.Context, lset p2plab.LabeledSet, benchmark metadata.Sce
 
 	var execution Execution
 	execution.Span, err = nodes.Session(ctx, ns, func(sctx context.Context) error {
-		err := nodes.Connect(ctx, ns)
+		var err error
+		if plan.Topology != nil {
+			err = nodes.ConnectTopology(ctx, ns, plan.Topology)
+		} else {
+			err = nodes.Connect(ctx, ns)
+		}
 		if err != nil {
 			return err
 		}
 
+		offsets := nodes.MeasureClocks(sctx, ns)
+		sctx = withClocks(sctx, offsets)
+
+		stopTraffic, err := Traffic(sctx, lset, plan.Traffic)
+		if err != nil {
+			return err
+		}
+		defer stopTraffic()
+
+		if len(warmups) > 0 {
+			zerolog.Ctx(ctx).Info().Msg("Warming up cluster")
+			_, err = RunStages(sctx, warmups, Conditions(ns), func(ctx context.Context, stage metadata.StagePlan) error {
+				return Benchmark(ctx, lset, stage)
+			})
+			if err != nil {
+				return err
+			}
+
+			err = nodes.ResetReports(sctx, ns)
+			if err != nil {
+				return errors.Wrap(err, "failed to reset reports")
+			}
+		}
+
+		execution.Topology.Start = nodes.CollectTopology(sctx, ns)
+		if len(settings.Profiles) > 0 {
+			nodes.StartProfiles(sctx, ns, settings.Profiles)
+		}
 		execution.Start = time.Now()
-		err = Benchmark(sctx, lset, benchmark)
+		rec := newRecorder(execution.Start)
+		rctx := withRecorder(sctx, rec)
+		stopSampling := Sample(sctx, ns, plan.SampleInterval, execution.Start)
+		switch {
+		case settings.Replay != nil:
+			execution.Stages, err = Replay(rctx, lset, *settings.Replay)
+		case plan.Soak != nil:
+			execution.Stages, execution.Snapshots, err = Soak(rctx, lset, *plan.Soak, stages, Conditions(ns), settings.Snapshot)
+		default:
+			execution.Stages, err = RunStages(rctx, stages, Conditions(ns), func(ctx context.Context, stage metadata.StagePlan) error {
+				return Benchmark(withStage(ctx, stage.Name), lset, stage)
+			})
+		}
+		execution.Series = stopSampling()
+		if len(settings.Profiles) > 0 {
+			execution.Profiles = nodes.CollectProfiles(ctx, ns, settings.Profiles)
+		}
 		if err != nil {
 			return err
 		}
 		execution.End = time.Now()
+		execution.Trace = rec.trace(ns)
+		execution.Topology.End = nodes.CollectTopology(ctx, ns)
 
 		execution.Report, err = nodes.CollectReports(ctx, ns)
 		if err != nil {
 			return errors.Wrap(err, "failed to collect reports")
 		}
 
+		for _, n := range ns {
+			reportNode, ok := execution.Report[n.ID()]
+			if !ok {
+				continue
+			}
+			for _, label := range n.Labels() {
+				if label != n.ID() {
+					reportNode.Labels = append(reportNode.Labels, label)
+				}
+			}
+			sort.Strings(reportNode.Labels)
+			execution.Report[n.ID()] = reportNode
+		}
+
+		for id, host := range nodes.CollectHosts(ctx, ns, offsets, execution.Start, execution.End) {
+			reportNode, ok := execution.Report[id]
+			if !ok {
+				continue
+			}
+			reportNode.Host = host
+			execution.Report[id] = reportNode
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -152,17 +621,117 @@ func Session(ctx context.Context, lset p2plab.LabeledSet, benchmark metadata.Sce
 	return &execution, nil
 }
 
-func Benchmark(ctx context.Context, lset p2plab.LabeledSet, benchmark metadata.ScenarioStage) error {
+// Traffic starts the background traffic of nodes by their IDs, after
+// resolving the node IDs in the subject of each task to peer addresses. The
+// returned function stops the traffic.
+func Traffic(ctx context.Context, lset p2plab.LabeledSet, traffic map[string]metadata.Task) (func(), error) {
+	if len(traffic) == 0 {
+		return func() {}, nil
+	}
+
+	span, ctx := traceutil.StartSpanFromContext(ctx, "scenarios.Traffic")
+	defer span.Finish()
+
+	addrsByID := make(map[string][]string)
+	for _, task := range traffic {
+		for _, id := range strings.Split(task.Subject, ",") {
+			if _, ok := addrsByID[id]; ok {
+				continue
+			}
+
+			n, ok := lset.Get(id).(p2plab.Node)
+			if !ok {
+				return nil, errors.Wrapf(errdefs.ErrNotFound, "could not find node %q in labeled set", id)
+			}
+
+			info, err := n.PeerInfo(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get peer info for node %q", id)
+			}
+
+			var addrs []string
+			for _, ma := range info.Addrs {
+				addrs = append(addrs, fmt.Sprintf("%s/p2p/%s", ma, info.ID))
+			}
+			addrsByID[id] = addrs
+		}
+	}
+
+	zerolog.Ctx(ctx).Info().Int("nodes", len(traffic)).Msg("Starting background traffic")
+	tctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for id, task := range traffic {
+		n, ok := lset.Get(id).(p2plab.Node)
+		if !ok {
+			cancel()
+			wg.Wait()
+			return nil, errors.Wrapf(errdefs.ErrNotFound, "could not find node %q in labeled set", id)
+		}
+
+		var addrs []string
+		for _, peer := range strings.Split(task.Subject, ",") {
+			addrs = append(addrs, addrsByID[peer]...)
+		}
+		task.Subject = strings.Join(addrs, ",")
+
+		wg.Add(1)
+		go func(id string, task metadata.Task) {
+			defer wg.Done()
+			err := n.Run(tctx, task)
+			if err != nil && tctx.Err() == nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Str("node", id).Msg("Background traffic stopped")
+			}
+		}(id, task)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+		zerolog.Ctx(ctx).Info().Msg("Stopped background traffic")
+	}, nil
+}
+
+// Benchmark executes the tasks of a stage. If the stage limits its
+// concurrency, tasks start in the order of their node IDs as the limit
+// allows. Otherwise, if the clocks of the nodes were measured, every node
+// starts its task at the same scheduled instant.
+func Benchmark(ctx context.Context, lset p2plab.LabeledSet, stage metadata.StagePlan) error {
 	span, ctx := traceutil.StartSpanFromContext(ctx, "scenarios.Benchmark")
 	defer span.Finish()
 
 	benchmarking, gctx := errgroup.WithContext(ctx)
 
+	var ids []string
+	for id := range stage.Tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
 	zerolog.Ctx(ctx).Info().Msg("Benchmarking cluster")
 	go logutil.Elapsed(gctx, 20*time.Second, "Benchmarking cluster")
-	for id, task := range benchmark {
-		id, task := id, task
+	lim := newLimiter(stage)
+	var startAt time.Time
+	if lim == nil {
+		startAt = scheduleStart(ctx)
+	}
+	for _, id := range ids {
+		id, task := id, stage.Tasks[id]
+		task.StartAt = startAt
+		if lim != nil {
+			err := lim.acquire(gctx)
+			if err != nil {
+				benchmarking.Go(func() error {
+					return err
+				})
+				break
+			}
+		}
+
 		benchmarking.Go(func() error {
+			if lim != nil {
+				defer lim.release()
+			}
+
 			labeled := lset.Get(id)
 			if labeled == nil {
 				return errors.Wrapf(errdefs.ErrNotFound, "could not find %q in labeled set", id)
@@ -175,7 +744,7 @@ func Benchmark(ctx context.Context, lset p2plab.LabeledSet, benchmark metadata.S
 			}
 
 			logger.Debug().Str("task", string(task.Type)).Msg("Executing benchmarking task")
-			return n.Run(gctx, task)
+			return runTask(logger.WithContext(gctx), n, task)
 		})
 	}
 
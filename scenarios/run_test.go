@@ -0,0 +1,93 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStages(t *testing.T) {
+	stages := []metadata.StagePlan{
+		{Name: "fetch", DependsOn: []string{"warm", "seed"}},
+		{Name: "warm", DependsOn: []string{"seed"}},
+		{Name: "seed"},
+	}
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	executions, err := RunStages(context.Background(), stages, nil, func(ctx context.Context, stage metadata.StagePlan) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, stage.Name)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"seed", "warm", "fetch"}, order)
+	require.Len(t, executions, 3)
+
+	// Every stage starts after the stages it depends on ended.
+	for _, stage := range stages {
+		for _, dep := range stage.DependsOn {
+			require.False(t, executions[stage.Name].Start.Before(executions[dep].End), "%s after %s", stage.Name, dep)
+		}
+	}
+}
+
+func TestRunStagesSkipped(t *testing.T) {
+	stages := []metadata.StagePlan{
+		{Name: "seed"},
+		{Name: "fetch", DependsOn: []string{"seed"}},
+	}
+
+	var ran []string
+	cond := func(ctx context.Context, stage metadata.StagePlan, executions map[string]StageExecution) (bool, error) {
+		return stage.Name != "seed", nil
+	}
+	executions, err := RunStages(context.Background(), stages, cond, func(ctx context.Context, stage metadata.StagePlan) error {
+		ran = append(ran, stage.Name)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Stages that depend on a skipped stage still run.
+	require.Equal(t, []string{"fetch"}, ran)
+	require.True(t, executions["seed"].Skipped)
+	require.False(t, executions["fetch"].Skipped)
+}
+
+func TestMergeTasks(t *testing.T) {
+	tasks := make(metadata.ScenarioStage)
+	mergeTasks(tasks, map[string]metadata.Task{
+		"a": {Type: metadata.TaskGet, Subject: "x"},
+		"b": {Type: metadata.TaskGet, Subject: "x"},
+	})
+	mergeTasks(tasks, map[string]metadata.Task{
+		"b": {Type: metadata.TaskPin, Subject: "y"},
+		"c": {Type: metadata.TaskPin, Subject: "y"},
+	})
+
+	require.Equal(t, metadata.ScenarioStage{
+		"a": {Type: metadata.TaskGet, Subject: "x"},
+		"b": {Type: metadata.TaskPin, Subject: "y"},
+		"c": {Type: metadata.TaskPin, Subject: "y"},
+	}, tasks)
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/rs/zerolog"
+)
+
+// Sample samples the bitswap, bandwidth and connection counters of nodes
+// every interval until the returned function is called, which returns the
+// samples of each node by its ID. Samples are timed from start, and nodes
+// that fail to report are left out of a sample with a warning.
+func Sample(ctx context.Context, ns []p2plab.Node, interval time.Duration, start time.Time) func() map[string][]metadata.ReportSample {
+	if interval <= 0 {
+		return func() map[string][]metadata.ReportSample {
+			return nil
+		}
+	}
+
+	var mu sync.Mutex
+	series := make(map[string][]metadata.ReportSample)
+
+	sctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			elapsed := time.Since(start)
+			var wg sync.WaitGroup
+			for _, n := range ns {
+				n := n
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					report, err := n.Report(sctx)
+					if err != nil {
+						if sctx.Err() == nil {
+							zerolog.Ctx(ctx).Warn().Err(err).Str("node", n.ID()).Msg("Failed to sample node")
+						}
+						return
+					}
+
+					mu.Lock()
+					series[n.ID()] = append(series[n.ID()], metadata.ReportSample{
+						Elapsed:     elapsed,
+						Bitswap:     report.Bitswap,
+						Bandwidth:   report.Bandwidth.Totals,
+						Connections: report.Connections,
+					})
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+		}
+	}()
+
+	return func() map[string][]metadata.ReportSample {
+		cancel()
+		<-done
+
+		mu.Lock()
+		defer mu.Unlock()
+		return series
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/nodes"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	"github.com/Netflix/p2plab/reports"
+	"github.com/rs/zerolog"
+)
+
+// Soak loops the stages until the soak's duration has elapsed, collecting an
+// interim report snapshot every interval and passing the snapshots so far to
+// fn if it is not nil. Each stage is measured from its first iteration
+// starting to its last iteration completing, and the conditions of stages are
+// evaluated every iteration.
+func Soak(ctx context.Context, lset p2plab.LabeledSet, soak metadata.SoakPlan, stages []metadata.StagePlan, cond StageCondition, fn SnapshotFunc) (map[string]StageExecution, []metadata.ReportSnapshot, error) {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "scenarios.Soak")
+	defer span.Finish()
+
+	ns, err := LabeledSetToNodes(lset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		mu         sync.Mutex
+		iterations int
+		snapshots  []metadata.ReportSnapshot
+	)
+
+	start := time.Now()
+	snapshot := func() {
+		reportByNodeID, err := nodes.CollectReports(ctx, ns)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to collect soak snapshot")
+			return
+		}
+
+		mu.Lock()
+		snapshots = append(snapshots, metadata.ReportSnapshot{
+			Elapsed:    time.Since(start),
+			Iterations: iterations,
+			Aggregates: reports.ComputeAggregates(reportByNodeID),
+		})
+		l := make([]metadata.ReportSnapshot, len(snapshots))
+		copy(l, snapshots)
+		mu.Unlock()
+
+		if fn != nil {
+			err = fn(ctx, l, reportByNodeID)
+			if err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to persist soak snapshot")
+			}
+		}
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(soak.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				snapshot()
+			case <-sctx.Done():
+				return
+			}
+		}
+	}()
+
+	zerolog.Ctx(ctx).Info().Str("duration", soak.Duration.String()).Str("interval", soak.Interval.String()).Msg("Soaking cluster")
+	executions := make(map[string]StageExecution)
+	for time.Since(start) < soak.Duration {
+		iteration, err := RunStages(ctx, stages, cond, func(ctx context.Context, stage metadata.StagePlan) error {
+			return Benchmark(withStage(ctx, stage.Name), lset, stage)
+		})
+		if err != nil {
+			cancel()
+			<-done
+			return nil, nil, err
+		}
+
+		for name, execution := range iteration {
+			existing, ok := executions[name]
+			switch {
+			case ok && execution.Skipped:
+				continue
+			case ok && !existing.Skipped:
+				execution.Start = existing.Start
+			}
+			executions[name] = execution
+		}
+
+		mu.Lock()
+		iterations++
+		mu.Unlock()
+		zerolog.Ctx(ctx).Info().Int("iterations", iterations).Str("elapsed", time.Since(start).String()).Msg("Completed soak iteration")
+	}
+	cancel()
+	<-done
+
+	// A final snapshot is collected so that the snapshots cover the whole
+	// soak.
+	snapshot()
+
+	return executions, snapshots, nil
+}
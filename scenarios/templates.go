@@ -0,0 +1,139 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/pkg/errors"
+)
+
+// templates are commented starter scenario definitions in YAML, by the
+// subsystem they benchmark. They are not executed as templates themselves,
+// so they must not reference variables.
+var templates = map[string]string{
+	"bitswap": `# A bitswap scenario measures how long nodes take to fetch an object from
+# the nodes that already have it.
+#
+# Create the scenario with "labctl scenario create <file>", then run it with
+# "labctl benchmark create <cluster> <scenario>".
+
+# Objects are converted into IPLD DAGs before the benchmark. An object is an
+# OCI image pulled from a registry, or inline content.
+objects:
+  golang:
+    type: oci
+    source: docker.io/library/golang:latest
+
+# Seed maps a query to an action that distributes objects to the matching
+# nodes before the benchmark. Seeding is not measured. Queries select nodes by
+# their labels, such as "neighbors" or "(not 'neighbors')".
+seed:
+  neighbors: golang
+
+# Benchmark maps a query to an action that is measured. Naming an object
+# fetches it on the matching nodes.
+benchmark:
+  "(not 'neighbors')": golang
+
+# Trials runs the scenario more than once, and the report aggregates them.
+trials: 3
+
+# Expectations fail the benchmark when the report does not meet them, so that
+# it can gate a pipeline.
+expectations:
+  - retrievalTime.p95 < 2m
+`,
+
+	"dht": `# A DHT scenario measures how long nodes take to announce that they provide
+# an object and to find its providers and other peers.
+#
+# Create the scenario with "labctl scenario create <file>", then run it with
+# "labctl benchmark create <cluster> <scenario>".
+
+# Objects are converted into IPLD DAGs before the benchmark. An object is an
+# OCI image pulled from a registry, or inline content.
+objects:
+  golang:
+    type: oci
+    source: docker.io/library/golang:latest
+
+# Stages are named phases that run in the order of their dependencies. Each
+# maps a query selecting nodes by their labels to an action.
+stages:
+  # The seed stage distributes the object to the nodes labeled neighbors, and
+  # is not measured.
+  - name: seed
+    seed: true
+    actions:
+      neighbors: golang
+
+  # The neighbors announce themselves as providers of the object.
+  - name: provide
+    dependsOn: [seed]
+    actions:
+      neighbors: provide golang
+
+  # The other nodes look up providers of the object...
+  - name: find
+    dependsOn: [provide]
+    actions:
+      "(not 'neighbors')": findprovs golang count=3 timeout=30s
+
+  # ...while the neighbors look up other peers.
+  - name: lookup
+    dependsOn: [provide]
+    actions:
+      neighbors: findpeer peers=5 timeout=30s
+`,
+
+	"pubsub": `# A pubsub scenario measures how quickly messages published on a topic reach
+# its subscribers.
+#
+# Create the scenario with "labctl scenario create <file>", then run it with
+# "labctl benchmark create <cluster> <scenario>".
+
+# Pubsub messages are generated, so no objects are needed.
+objects: {}
+
+# Benchmark maps a query selecting nodes by their labels to an action that is
+# measured. The neighbors subscribe to the topic "blocks" while the other
+# nodes publish to it, waiting for the subscribers to join first.
+benchmark:
+  neighbors: subscribe blocks count=100 timeout=1m
+  "(not 'neighbors')": publish blocks count=100 size=4096 interval=50ms after=5s
+`,
+}
+
+// TemplateNames returns the names of the starter scenario templates.
+func TemplateNames() []string {
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Template returns the commented YAML of a starter scenario template.
+func Template(name string) ([]byte, error) {
+	content, ok := templates[name]
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unknown scenario template %q, must be one of: %s", name, strings.Join(TemplateNames(), ", "))
+	}
+	return []byte(content), nil
+}
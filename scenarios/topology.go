@@ -0,0 +1,157 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/pkg/errors"
+)
+
+// PlanTopology resolves a topology into the IDs of the nodes each node dials.
+// Every connection is dialed by only one of its nodes.
+func PlanTopology(ctx context.Context, tdef metadata.TopologyDefinition, lset p2plab.LabeledSet, rng *rand.Rand) (map[string][]string, error) {
+	t := newTopology()
+	switch tdef.Shape {
+	case metadata.TopologyRing:
+		ids, err := matchIDs(ctx, tdef.Nodes, lset)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range ids {
+			t.connect(ids[i], ids[(i+1)%len(ids)])
+		}
+	case metadata.TopologyStar:
+		centers, err := matchIDs(ctx, tdef.Center, lset)
+		if err != nil {
+			return nil, err
+		}
+
+		ids, err := matchIDs(ctx, tdef.Nodes, lset)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, center := range centers {
+			for _, id := range ids {
+				t.connect(center, id)
+			}
+		}
+	case metadata.TopologyRegular:
+		ids, err := matchIDs(ctx, tdef.Nodes, lset)
+		if err != nil {
+			return nil, err
+		}
+
+		n, k := len(ids), tdef.Degree
+		if k >= n {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "degree %d must be less than the %d nodes", k, n)
+		}
+		if n*k%2 != 0 {
+			return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "degree %d must be even for an odd number of nodes", k)
+		}
+
+		// Connect the nodes as a circulant graph over a random ordering, where
+		// each node is connected to its k/2 nearest nodes on either side, and
+		// to the opposite node if k is odd.
+		perm := rng.Perm(n)
+		for i := 0; i < n; i++ {
+			for j := 1; j <= k/2; j++ {
+				t.connect(ids[perm[i]], ids[perm[(i+j)%n]])
+			}
+			if k%2 != 0 && i < n/2 {
+				t.connect(ids[perm[i]], ids[perm[i+n/2]])
+			}
+		}
+	case metadata.TopologyCustom:
+		for q, peers := range tdef.Adjacency {
+			ids, err := matchIDs(ctx, q, lset)
+			if err != nil {
+				return nil, err
+			}
+
+			peerIDs, err := matchIDs(ctx, peers, lset)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, id := range ids {
+				for _, peerID := range peerIDs {
+					t.connect(id, peerID)
+				}
+			}
+		}
+	default:
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized topology shape %q", tdef.Shape)
+	}
+
+	return t.dials, nil
+}
+
+// matchIDs returns the sorted IDs of the nodes matching a query, or of all
+// nodes if the query is empty.
+func matchIDs(ctx context.Context, q string, lset p2plab.LabeledSet) ([]string, error) {
+	mset := lset
+	if q != "" {
+		var err error
+		mset, err = matchQuery(ctx, q, lset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var ids []string
+	for _, l := range mset.Slice() {
+		ids = append(ids, l.ID())
+	}
+	return ids, nil
+}
+
+// topology accumulates the connections between nodes.
+type topology struct {
+	dials     map[string][]string
+	connected map[[2]string]struct{}
+}
+
+func newTopology() *topology {
+	return &topology{
+		dials:     make(map[string][]string),
+		connected: make(map[[2]string]struct{}),
+	}
+}
+
+// connect adds a connection dialed from a to b, unless a and b are the same
+// node or are already connected.
+func (t *topology) connect(a, b string) {
+	if a == b {
+		return
+	}
+
+	edge := [2]string{a, b}
+	if b < a {
+		edge = [2]string{b, a}
+	}
+	if _, ok := t.connected[edge]; ok {
+		return
+	}
+
+	t.connected[edge] = struct{}{}
+	t.dials[a] = append(t.dials[a], b)
+}
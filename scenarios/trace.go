@@ -0,0 +1,200 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+)
+
+// recorder records the tasks executed during a benchmark into a trace.
+type recorder struct {
+	start time.Time
+
+	mu     sync.Mutex
+	events []metadata.TraceEvent
+}
+
+func newRecorder(start time.Time) *recorder {
+	return &recorder{start: start}
+}
+
+type recorderKey struct{}
+
+type recorderValue struct {
+	rec   *recorder
+	stage string
+}
+
+// withRecorder returns a context whose tasks are recorded by rec.
+func withRecorder(ctx context.Context, rec *recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, recorderValue{rec: rec})
+}
+
+// withStage returns a context whose tasks are recorded as part of a stage, if
+// ctx has a recorder.
+func withStage(ctx context.Context, stage string) context.Context {
+	v, ok := ctx.Value(recorderKey{}).(recorderValue)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, recorderKey{}, recorderValue{v.rec, stage})
+}
+
+// record adds a task that started at start to the trace of the recorder in
+// ctx, if any.
+func record(ctx context.Context, id string, task metadata.Task, start time.Time) {
+	v, ok := ctx.Value(recorderKey{}).(recorderValue)
+	if !ok {
+		return
+	}
+
+	// The scheduled start is only meaningful to the run that scheduled it.
+	task.StartAt = time.Time{}
+
+	v.rec.mu.Lock()
+	defer v.rec.mu.Unlock()
+	v.rec.events = append(v.rec.events, metadata.TraceEvent{
+		Node:     id,
+		Stage:    v.stage,
+		Task:     task,
+		Offset:   start.Sub(v.rec.start),
+		Duration: time.Since(start),
+		Action:   traceutil.ActionID(ctx),
+	})
+}
+
+// trace returns the recorded events in the order they started.
+func (r *recorder) trace(ns []p2plab.Node) metadata.Trace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]metadata.TraceEvent, len(r.events))
+	copy(events, r.events)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Offset < events[j].Offset
+	})
+
+	trace := metadata.Trace{Events: events}
+	for _, n := range ns {
+		trace.Nodes = append(trace.Nodes, n.ID())
+	}
+	return trace
+}
+
+// Replay executes the events of a trace on the nodes of lset, starting each
+// task at the same offset it started in the trace. Nodes of the traced
+// cluster are mapped to nodes of lset by their position in sorted order, and
+// node IDs in task subjects are rewritten accordingly. The time taken by each
+// stage is measured from its first task starting to its last task completing.
+func Replay(ctx context.Context, lset p2plab.LabeledSet, trace metadata.Trace) (map[string]StageExecution, error) {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "scenarios.Replay")
+	defer span.Finish()
+
+	ns, err := LabeledSetToNodes(lset)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ns) < len(trace.Nodes) {
+		return nil, errors.Wrapf(errdefs.ErrInvalidArgument, "trace requires %d nodes but cluster only has %d", len(trace.Nodes), len(ns))
+	}
+
+	nodeByID := make(map[string]p2plab.Node)
+	idByTraced := make(map[string]string)
+	for i, id := range trace.Nodes {
+		nodeByID[id] = ns[i]
+		idByTraced[id] = ns[i].ID()
+	}
+
+	for _, event := range trace.Events {
+		if _, ok := nodeByID[event.Node]; !ok {
+			return nil, errors.Wrapf(errdefs.ErrNotFound, "trace event references unknown node %q", event.Node)
+		}
+	}
+
+	var (
+		mu         sync.Mutex
+		executions = make(map[string]StageExecution)
+	)
+
+	zerolog.Ctx(ctx).Info().Int("events", len(trace.Events)).Msg("Replaying trace")
+	start := time.Now()
+	eg, gctx := errgroup.WithContext(ctx)
+	for _, event := range trace.Events {
+		event := event
+		n := nodeByID[event.Node]
+		task := event.Task
+		subjects := strings.Split(task.Subject, ",")
+		for i, subject := range subjects {
+			if id, ok := idByTraced[subject]; ok {
+				subjects[i] = id
+			}
+		}
+		task.Subject = strings.Join(subjects, ",")
+
+		eg.Go(func() error {
+			timer := time.NewTimer(time.Until(start.Add(event.Offset)))
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+
+			logger := zerolog.Ctx(ctx).With().Str("stage", event.Stage).Str("node", n.ID()).Logger()
+			logger.Debug().Str("task", string(task.Type)).Msg("Replaying task")
+
+			taskStart := time.Now()
+			err := runTask(withStage(logger.WithContext(gctx), event.Stage), n, task)
+			if err != nil {
+				return errors.Wrapf(err, "failed to replay task in stage %q", event.Stage)
+			}
+			taskEnd := time.Now()
+
+			mu.Lock()
+			defer mu.Unlock()
+			execution, ok := executions[event.Stage]
+			if !ok || taskStart.Before(execution.Start) {
+				execution.Start = taskStart
+			}
+			if taskEnd.After(execution.End) {
+				execution.End = taskEnd
+			}
+			executions[event.Stage] = execution
+			return nil
+		})
+	}
+
+	err = eg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	zerolog.Ctx(ctx).Info().Msg("Replay completed")
+	return executions, nil
+}
@@ -0,0 +1,352 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenarios
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Netflix/p2plab/actions"
+	"github.com/Netflix/p2plab/metadata"
+	"github.com/Netflix/p2plab/query"
+	"github.com/Netflix/p2plab/transformers/tree"
+	cid "github.com/ipfs/go-cid"
+	multihash "github.com/multiformats/go-multihash"
+)
+
+// Validate checks a scenario definition without a cluster, returning a
+// diagnostic for each problem found in its objects, queries, actions, stages
+// and expectations.
+func Validate(ctx context.Context, sdef metadata.ScenarioDefinition) []metadata.Diagnostic {
+	v := &validator{ctx: ctx, selections: make(map[string]bool), version: sdef.QueryVersion}
+	if v.version < 0 || v.version > query.Version {
+		v.errorf("queryVersion", "unsupported query version %d, expected 1 to %d", v.version, query.Version)
+		v.version = query.Version
+	}
+
+	// Selections that split their nodes also define a selection for each
+	// part, which may collide with another selection's name.
+	for _, name := range sortedKeys(sdef.Selections) {
+		for _, selection := range query.SelectionNames(name, sdef.Selections[name]) {
+			if v.selections[selection] {
+				v.errorf(fmt.Sprintf("selections.%s", name), "selection %q is defined more than once", selection)
+			}
+			v.selections[selection] = true
+		}
+	}
+
+	for _, name := range sortedKeys(sdef.Selections) {
+		path := fmt.Sprintf("selections.%s", name)
+		if name == "" || strings.ContainsAny(name, " ()'@") {
+			v.errorf(path, "invalid selection name %q", name)
+		}
+
+		q := sdef.Selections[name]
+		if len(query.References(q)) > 0 {
+			v.errorf(path, "selections may not reference other selections")
+			continue
+		}
+		v.query(path, q)
+		v.noTopology(path, q)
+	}
+
+	// Objects are only transformed when the scenario is planned, so actions
+	// are parsed with a placeholder cid for each object.
+	objects := make(map[string]cid.Cid)
+	for _, name := range sortedKeys(sdef.Objects) {
+		odef := sdef.Objects[name]
+		path := fmt.Sprintf("objects.%s", name)
+		switch odef.Type {
+		case "oci", "inline":
+		case "tree":
+			_, err := tree.ParseSpec(odef.Source)
+			if err != nil {
+				v.errorf(path+".source", "%s", err)
+			}
+		default:
+			v.errorf(path+".type", "unrecognized object type %q", odef.Type)
+		}
+		if odef.Source == "" {
+			v.errorf(path+".source", "source must be provided")
+		}
+
+		mh, err := multihash.Sum([]byte(name), multihash.SHA2_256, -1)
+		if err != nil {
+			v.errorf(path, "%s", err)
+			continue
+		}
+		objects[name] = cid.NewCidV1(cid.Raw, mh)
+	}
+
+	if len(sdef.Stages) > 0 && (len(sdef.Seed) > 0 || len(sdef.Benchmark) > 0) {
+		v.warnf("stages", "seed and benchmark are ignored when stages are defined")
+	}
+
+	stages := sdef.StageDefinitions()
+	_, err := metadata.SortStages(stages)
+	if err != nil {
+		v.errorf("stages", "%s", err)
+	}
+
+	// Actions are parsed with a fixed seed since only their syntax matters.
+	rng := rand.New(rand.NewSource(0))
+	used := make(map[string]bool)
+	for _, stage := range stages {
+		path := fmt.Sprintf("stages.%s", stage.Name)
+		if stage.Timeout != "" {
+			_, err = time.ParseDuration(stage.Timeout)
+			if err != nil {
+				v.errorf(path+".timeout", "invalid timeout %q", stage.Timeout)
+			}
+		}
+
+		_, err = metadata.ParseFailurePolicy(stage.OnFailure)
+		if err != nil {
+			v.errorf(path+".onFailure", "%s", err)
+		}
+
+		err = stage.ValidateConditions()
+		if err != nil {
+			v.errorf(path+".when", "%s", err)
+		}
+
+		if stage.MaxConcurrency < 0 {
+			v.errorf(path+".maxConcurrency", "max concurrency must not be negative")
+		}
+
+		if stage.RampUp != nil {
+			_, err = stage.RampUp.Plan()
+			if err != nil {
+				v.errorf(path+".rampUp", "%s", err)
+			}
+		}
+
+		if len(stage.Actions) == 0 {
+			v.warnf(path+".actions", "stage has no actions")
+		}
+
+		for _, q := range sortedKeys(stage.Actions) {
+			a := stage.Actions[q]
+			apath := fmt.Sprintf("%s.actions.%s", path, q)
+			v.query(apath, q)
+
+			_, err = actions.Parse(objects, a, rng)
+			if err != nil {
+				v.errorf(apath, "%s", err)
+			}
+
+			// Objects are named by fields, or by the values of arguments
+			// such as exec's script.
+			for _, field := range strings.Fields(a) {
+				if i := strings.Index(field, "="); i >= 0 {
+					field = field[i+1:]
+				}
+				for _, name := range strings.Split(field, ",") {
+					used[name] = true
+				}
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(sdef.Objects) {
+		if !used[name] {
+			v.warnf(fmt.Sprintf("objects.%s", name), "object is not used by any action")
+		}
+	}
+
+	for _, q := range sortedKeys(sdef.Network) {
+		impairment := sdef.Network[q]
+		path := fmt.Sprintf("network.%s", q)
+		v.query(path, q)
+		if impairment.Peers != "" {
+			v.query(path+".peers", impairment.Peers)
+		}
+
+		err = impairment.Validate()
+		if err != nil {
+			v.errorf(path, "%s", err)
+		}
+	}
+
+	for _, q := range sortedKeys(sdef.Traffic) {
+		traffic := sdef.Traffic[q]
+		path := fmt.Sprintf("traffic.%s", q)
+		v.query(path, q)
+		if traffic.Peers != "" {
+			v.query(path+".peers", traffic.Peers)
+		}
+
+		err = traffic.Validate()
+		if err != nil {
+			v.errorf(path, "%s", err)
+		}
+	}
+
+	for _, q := range sortedKeys(sdef.Peers) {
+		path := fmt.Sprintf("peers.%s", q)
+		v.query(path, q)
+		v.noTopology(path, q)
+
+		err = sdef.Peers[q].Validate()
+		if err != nil {
+			v.errorf(path, "%s", err)
+		}
+	}
+
+	if sdef.Topology != nil {
+		t := sdef.Topology
+		err = t.Validate()
+		if err != nil {
+			v.errorf("topology", "%s", err)
+		}
+
+		if t.Nodes != "" {
+			v.query("topology.nodes", t.Nodes)
+			v.noTopology("topology.nodes", t.Nodes)
+		}
+		if t.Center != "" {
+			v.query("topology.center", t.Center)
+			v.noTopology("topology.center", t.Center)
+		}
+		for _, q := range sortedKeys(t.Adjacency) {
+			path := fmt.Sprintf("topology.adjacency.%s", q)
+			v.query(path, q)
+			v.query(path, t.Adjacency[q])
+			v.noTopology(path, q)
+			v.noTopology(path, t.Adjacency[q])
+		}
+	}
+
+	if sdef.Soak != nil {
+		_, err = sdef.Soak.Plan()
+		if err != nil {
+			v.errorf("soak", "%s", err)
+		}
+	}
+
+	_, err = metadata.ParseSampleInterval(sdef.SampleInterval)
+	if err != nil {
+		v.errorf("sampleInterval", "%s", err)
+	}
+
+	for i, kind := range sdef.Profiles {
+		switch kind {
+		case metadata.ProfileCPU, metadata.ProfileHeap:
+		default:
+			v.errorf(fmt.Sprintf("profiles.%d", i), "profile %q must be %q or %q", kind, metadata.ProfileCPU, metadata.ProfileHeap)
+		}
+	}
+
+	for i, e := range sdef.Expectations {
+		_, err = metadata.ParseExpectation(e)
+		if err != nil {
+			v.errorf(fmt.Sprintf("expectations.%d", i), "%s", err)
+		}
+	}
+
+	if sdef.Trials < 0 {
+		v.errorf("trials", "trials must not be negative")
+	}
+
+	return v.diagnostics
+}
+
+// validator accumulates diagnostics.
+type validator struct {
+	ctx         context.Context
+	selections  map[string]bool
+	version     int
+	diagnostics []metadata.Diagnostic
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, metadata.Diagnostic{
+		Severity: metadata.SeverityError,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) warnf(path, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, metadata.Diagnostic{
+		Severity: metadata.SeverityWarning,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// query adds an error if a query is malformed, uses operators newer than the
+// scenario's query version, references a selection that is not defined or
+// selects nodes of other clusters.
+func (v *validator) query(path, q string) {
+	_, err := query.ParseVersion(v.ctx, q, v.version)
+	if err != nil {
+		v.errorf(path, "invalid query %q: %s", q, err)
+		return
+	}
+
+	for _, name := range query.References(q) {
+		if !v.selections[name] {
+			v.errorf(path, "query %q references undefined selection %q", q, name)
+		}
+	}
+
+	// Benchmarks run on the nodes of a single cluster.
+	if len(query.Clusters(q)) > 0 {
+		v.errorf(path, "query %q may not select nodes of other clusters in a scenario", q)
+	}
+}
+
+// noTopology adds an error if a query traverses the topology where it is
+// matched before the nodes are connected.
+func (v *validator) noTopology(path, q string) {
+	if query.UsesTopology(q) {
+		v.errorf(path, "query %q may not use neighbors or hops since the nodes are not connected yet", q)
+	}
+}
+
+// sortedKeys returns the keys of a map with string keys in sorted order, so
+// that diagnostics are reported in a stable order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch t := m.(type) {
+	case map[string]string:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]metadata.ObjectDefinition:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]metadata.NetworkImpairment:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]metadata.TrafficDefinition:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]metadata.PeerDefinition:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,44 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p2plab
+
+import (
+	"context"
+
+	"github.com/Netflix/p2plab/metadata"
+)
+
+// ScheduleAPI defines API for schedules that periodically benchmark a
+// scenario and alert on regressions.
+type ScheduleAPI interface {
+	// Create saves a schedule for the given schedule definition.
+	Create(ctx context.Context, name string, sdef metadata.ScheduleDefinition) (Schedule, error)
+
+	// Get returns a schedule.
+	Get(ctx context.Context, name string) (Schedule, error)
+
+	// List returns available schedules.
+	List(ctx context.Context) ([]Schedule, error)
+
+	Remove(ctx context.Context, names ...string) error
+
+	// Run runs a benchmark of the schedule now, and returns its ID.
+	Run(ctx context.Context, name string) (id string, err error)
+}
+
+// Schedule runs benchmarks of a scenario on a cluster periodically.
+type Schedule interface {
+	Metadata() metadata.Schedule
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inline
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	cid "github.com/ipfs/go-cid"
+)
+
+type transformer struct{}
+
+// New returns a transformer whose sources are the content of a file, such as
+// a script defined within a scenario.
+func New() p2plab.Transformer {
+	return &transformer{}
+}
+
+func (t *transformer) Close() error {
+	return nil
+}
+
+func (t *transformer) Transform(ctx context.Context, p p2plab.Peer, source string, opts ...p2plab.AddOption) (cid.Cid, error) {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "inline.Transform")
+	defer span.Finish()
+
+	nd, err := p.Add(ctx, strings.NewReader(source), opts...)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return nd.Cid(), nil
+}
@@ -20,7 +20,9 @@ import (
 	"sync"
 
 	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/transformers/inline"
 	"github.com/Netflix/p2plab/transformers/oci"
+	"github.com/Netflix/p2plab/transformers/tree"
 	"github.com/pkg/errors"
 )
 
@@ -70,6 +72,10 @@ func (t *Transformers) newTransformer(objectType string) (p2plab.Transformer, er
 	switch objectType {
 	case "oci":
 		return oci.New(root, t.client)
+	case "tree":
+		return tree.New(), nil
+	case "inline":
+		return inline.New(), nil
 	default:
 		return nil, errors.Errorf("unrecognized object type: %q", objectType)
 	}
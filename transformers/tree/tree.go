@@ -0,0 +1,282 @@
+// Copyright 2019 Netflix, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tree
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/Netflix/p2plab"
+	"github.com/Netflix/p2plab/errdefs"
+	"github.com/Netflix/p2plab/pkg/traceutil"
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	unixfs "github.com/ipfs/go-unixfs"
+	multihash "github.com/multiformats/go-multihash"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Spec defines a synthetic UnixFS directory tree of random files.
+type Spec struct {
+	// Files is the number of files in the tree.
+	Files int
+
+	// Size is the distribution the size of each file is drawn from.
+	Size SizeDistribution
+
+	// Depth is the number of directory levels below the root. Files are
+	// spread evenly across the directories at the deepest level.
+	Depth int
+
+	// Fanout is the number of subdirectories in each directory above the
+	// deepest level.
+	Fanout int
+
+	// Seed seeds the random sizes and contents of the files, so that the same
+	// spec always produces the same tree.
+	Seed int64
+}
+
+// ParseSpec parses a spec from space separated arguments of the form
+// key=value, such as "files=1000 size=4KiB-64KiB depth=2 fanout=8".
+func ParseSpec(source string) (Spec, error) {
+	spec := Spec{
+		Size:   SizeDistribution{Min: 4096, Max: 4096},
+		Fanout: 10,
+	}
+
+	for _, arg := range strings.Fields(source) {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return spec, errors.Wrapf(errdefs.ErrInvalidArgument, "tree argument %q must be of the form key=value", arg)
+		}
+
+		key, value := parts[0], parts[1]
+		var err error
+		switch key {
+		case "files":
+			spec.Files, err = strconv.Atoi(value)
+		case "size":
+			spec.Size, err = ParseSizeDistribution(value)
+		case "depth":
+			spec.Depth, err = strconv.Atoi(value)
+		case "fanout":
+			spec.Fanout, err = strconv.Atoi(value)
+		case "seed":
+			spec.Seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return spec, errors.Wrapf(errdefs.ErrInvalidArgument, "unrecognized tree argument %q", key)
+		}
+		if err != nil {
+			return spec, errors.Wrapf(errdefs.ErrInvalidArgument, "tree argument %s=%q: %s", key, value, err)
+		}
+	}
+
+	if spec.Files <= 0 {
+		return spec, errors.Wrap(errdefs.ErrInvalidArgument, "tree must have a positive number of files")
+	}
+	if spec.Depth < 0 {
+		return spec, errors.Wrap(errdefs.ErrInvalidArgument, "tree depth must not be negative")
+	}
+	if spec.Fanout <= 0 {
+		return spec, errors.Wrap(errdefs.ErrInvalidArgument, "tree fanout must be positive")
+	}
+	if math.Pow(float64(spec.Fanout), float64(spec.Depth)) > float64(spec.Files) {
+		return spec, errors.Wrapf(errdefs.ErrInvalidArgument, "tree of depth %d and fanout %d has more directories than its %d files", spec.Depth, spec.Fanout, spec.Files)
+	}
+
+	return spec, nil
+}
+
+// SizeDistribution is a distribution of file sizes in bytes.
+type SizeDistribution struct {
+	// Min and Max bound a uniform distribution, which is a fixed size if they
+	// are equal.
+	Min, Max int64
+
+	// Mean is the mean of an exponential distribution if positive, in which
+	// case Min and Max are ignored.
+	Mean int64
+}
+
+// ParseSizeDistribution parses a fixed size such as "4KiB", a uniform range
+// such as "4KiB-64KiB", or an exponential distribution by its mean such as
+// "exp:16KiB".
+func ParseSizeDistribution(s string) (SizeDistribution, error) {
+	var d SizeDistribution
+	if strings.HasPrefix(s, "exp:") {
+		mean, err := humanize.ParseBytes(strings.TrimPrefix(s, "exp:"))
+		if err != nil {
+			return d, err
+		}
+		if mean == 0 {
+			return d, errors.New("mean must be positive")
+		}
+		d.Mean = int64(mean)
+		return d, nil
+	}
+
+	parts := strings.SplitN(s, "-", 2)
+	min, err := humanize.ParseBytes(parts[0])
+	if err != nil {
+		return d, err
+	}
+	max := min
+	if len(parts) == 2 {
+		max, err = humanize.ParseBytes(parts[1])
+		if err != nil {
+			return d, err
+		}
+	}
+	if max < min {
+		return d, errors.Errorf("maximum %s is less than minimum %s", parts[1], parts[0])
+	}
+
+	d.Min, d.Max = int64(min), int64(max)
+	return d, nil
+}
+
+// Sample draws a size from the distribution.
+func (d SizeDistribution) Sample(rng *rand.Rand) int64 {
+	if d.Mean > 0 {
+		return int64(rng.ExpFloat64() * float64(d.Mean))
+	}
+	if d.Max == d.Min {
+		return d.Min
+	}
+	return d.Min + rng.Int63n(d.Max-d.Min+1)
+}
+
+type transformer struct{}
+
+// New returns a transformer whose sources are specs of synthetic directory
+// trees, for benchmarking directory fetches and small-file heavy workloads.
+func New() p2plab.Transformer {
+	return &transformer{}
+}
+
+func (t *transformer) Close() error {
+	return nil
+}
+
+func (t *transformer) Transform(ctx context.Context, p p2plab.Peer, source string, opts ...p2plab.AddOption) (cid.Cid, error) {
+	span, ctx := traceutil.StartSpanFromContext(ctx, "tree.Transform")
+	defer span.Finish()
+
+	spec, err := ParseSpec(source)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	settings := p2plab.AddSettings{
+		HashFunc: "sha2-256",
+	}
+	for _, opt := range opts {
+		err := opt(&settings)
+		if err != nil {
+			return cid.Undef, err
+		}
+	}
+
+	b := &builder{
+		peer:   p,
+		spec:   spec,
+		rng:    rand.New(rand.NewSource(spec.Seed)),
+		prefix: cid.V1Builder{MhType: multihash.Names[settings.HashFunc]},
+		opts:   opts,
+		leaves: int(math.Pow(float64(spec.Fanout), float64(spec.Depth))),
+	}
+
+	zerolog.Ctx(ctx).Info().Int("files", spec.Files).Int("depth", spec.Depth).Int("fanout", spec.Fanout).Msg("Generating directory tree")
+	nd, err := b.directory(ctx, 0, 0)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return nd.Cid(), nil
+}
+
+// builder adds the files and directories of a tree to a peer. Files are
+// generated in a fixed order from the spec's seed so that the tree is
+// reproducible.
+type builder struct {
+	peer   p2plab.Peer
+	spec   Spec
+	rng    *rand.Rand
+	prefix cid.Builder
+	opts   []p2plab.AddOption
+
+	// leaves is the number of directories at the deepest level.
+	leaves int
+
+	// file is the index of the next file.
+	file int
+}
+
+// directory adds the directory at a level of the tree that is the leaf-th
+// directory of its level, and returns its node.
+func (b *builder) directory(ctx context.Context, level, leaf int) (ipld.Node, error) {
+	dir := unixfs.EmptyDirNode()
+	dir.SetCidBuilder(b.prefix)
+
+	if level == b.spec.Depth {
+		// The first leaves take the remainder when files do not divide
+		// evenly.
+		count := b.spec.Files / b.leaves
+		if leaf < b.spec.Files%b.leaves {
+			count++
+		}
+
+		for i := 0; i < count; i++ {
+			size := b.spec.Size.Sample(b.rng)
+			nd, err := b.peer.Add(ctx, io.LimitReader(b.rng, size), b.opts...)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to add file %d", b.file)
+			}
+
+			err = dir.AddNodeLink(fmt.Sprintf("file-%d", b.file), nd)
+			if err != nil {
+				return nil, err
+			}
+			b.file++
+		}
+	} else {
+		for i := 0; i < b.spec.Fanout; i++ {
+			nd, err := b.directory(ctx, level+1, leaf*b.spec.Fanout+i)
+			if err != nil {
+				return nil, err
+			}
+
+			err = dir.AddNodeLink(fmt.Sprintf("dir-%d", i), nd)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err := b.peer.DAGService().Add(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return dir, nil
+}